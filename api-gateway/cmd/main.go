@@ -10,11 +10,18 @@ import (
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/accountdeletion"
 	"github.com/trackfy/api-gateway/internal/api"
+	"github.com/trackfy/api-gateway/internal/archive"
 	"github.com/trackfy/api-gateway/internal/auth"
 	"github.com/trackfy/api-gateway/internal/config"
 	"github.com/trackfy/api-gateway/internal/db"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/payments"
+	"github.com/trackfy/api-gateway/internal/push"
 	"github.com/trackfy/api-gateway/internal/services"
+	"github.com/trackfy/pkg/dbtls"
+	"github.com/trackfy/pkg/tracing"
 )
 
 func main() {
@@ -27,8 +34,18 @@ func main() {
 	// Cargar configuración
 	cfg := config.Load()
 
+	// Inicializar tracing (OpenTelemetry)
+	shutdownTracing, err := tracing.Init(context.Background(), "api-gateway")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
 	// Conectar a PostgreSQL
-	postgres, err := db.NewPostgresDB(cfg.Database.URL, cfg.Database.MaxConns)
+	postgres, err := db.NewPostgresDB(cfg.Database.URL, cfg.Database.MaxConns, dbtls.Config{
+		CertFile: cfg.Database.SSLCert,
+		KeyFile:  cfg.Database.SSLKey,
+		CAFile:   cfg.Database.SSLCA,
+	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to connect to PostgreSQL")
 	}
@@ -45,13 +62,54 @@ func main() {
 	jwtManager := auth.NewJWTManager(cfg.JWT.Secret, cfg.JWT.AccessTokenTTL, cfg.JWT.RefreshTokenTTL)
 
 	// Crear cliente de Fy Engine
-	fyEngine := services.NewFyEngineClient(cfg.FyEngine.URL, cfg.FyEngine.Timeout)
+	fyEngine := services.NewFyEngineClient(cfg.FyEngine.URL, cfg.FyEngine.Timeout, resilienceConfig(cfg.FyEngine.Resilience))
 
 	// Crear cliente de Fy Analysis (para reportes)
-	fyAnalysis := services.NewFyAnalysisClient(cfg.FyAnalysis.URL, cfg.FyAnalysis.Timeout)
+	fyAnalysis := services.NewFyAnalysisClient(cfg.FyAnalysis.URL, cfg.FyAnalysis.SigningSecret, cfg.FyAnalysis.Timeout, resilienceConfig(cfg.FyAnalysis.Resilience))
+
+	// Notificaciones push (alertas de reclasificación)
+	var pushDispatcher *push.Dispatcher
+	if cfg.Push.FCMCredentialsFile != "" {
+		fcm, err := push.NewFCMProvider(cfg.Push.FCMCredentialsFile, cfg.Push.Timeout)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to initialize FCM")
+		}
+		pushDispatcher = push.NewDispatcher(postgres, fcm, cfg.Push.AlertWindow)
+	} else {
+		log.Warn().Msg("FCM_CREDENTIALS_FILE not set - push notifications disabled")
+	}
+
+	// Borrados de cuenta (RGPD) en segundo plano
+	accountDeletion := accountdeletion.NewWorker(postgres, redis, fyAnalysis)
+	if cfg.Stripe.SecretKey != "" {
+		accountDeletion.SetSubscriptionCanceller(payments.NewStripeClient(cfg.Stripe.SecretKey, cfg.Stripe.Timeout))
+	} else {
+		log.Warn().Msg("STRIPE_SECRET_KEY not set - account deletions with an active subscription will stay pending")
+	}
+	accountDeletion.Start()
+
+	// Archivado semanal de conversaciones antiguas y cortas
+	var archiver *archive.Archiver
+	if cfg.Archive.Enabled {
+		archiver = archive.NewArchiver(postgres, redis, archive.Config{
+			Interval:    cfg.Archive.Interval,
+			MaxAge:      cfg.Archive.MaxAge,
+			MaxMessages: cfg.Archive.MaxMessages,
+		})
+		archiver.Start()
+	}
 
 	// Crear router
-	router := api.NewRouter(postgres, redis, jwtManager, fyEngine, fyAnalysis)
+	router := api.NewRouter(postgres, redis, jwtManager, fyEngine, fyAnalysis, pushDispatcher, accountDeletion, cfg.FyAnalysis.SigningSecret, api.MemoryFallback{
+		MaxMessages: cfg.FyEngine.MemoryFallbackMessages,
+		MaxChars:    cfg.FyEngine.MemoryFallbackChars,
+	}, middleware.CORSConfig{
+		AllowedOrigins:   cfg.CORS.AllowedOrigins,
+		AllowedMethods:   cfg.CORS.AllowedMethods,
+		AllowedHeaders:   cfg.CORS.AllowedHeaders,
+		MaxAge:           cfg.CORS.MaxAge,
+		AllowCredentials: cfg.CORS.AllowCredentials,
+	}, cfg.AdminAPIKey, cfg.Environment == "development")
 
 	// Configurar servidor
 	server := &http.Server{
@@ -84,5 +142,28 @@ func main() {
 		log.Error().Err(err).Msg("Server forced to shutdown")
 	}
 
+	// Termina el borrado en curso; los encolados siguen en Redis para el próximo arranque
+	accountDeletion.Stop()
+	if archiver != nil {
+		archiver.Stop()
+	}
+
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
+
 	log.Info().Msg("Server stopped")
 }
+
+// resilienceConfig reintentos y circuito de un cliente de servicio interno
+func resilienceConfig(c config.DownstreamConfig) services.ResilienceConfig {
+	return services.ResilienceConfig{
+		MaxRetries:          c.MaxRetries,
+		RetryBackoff:        c.RetryBackoff,
+		CallTimeout:         c.CallTimeout,
+		HealthTimeout:       c.HealthTimeout,
+		BreakerThreshold:    c.BreakerThreshold,
+		BreakerCooldown:     c.BreakerCooldown,
+		MaxIdleConnsPerHost: c.MaxIdleConnsPerHost,
+	}
+}
@@ -0,0 +1,102 @@
+// openapi-gen escribe el documento OpenAPI de api-gateway (internal/api/openapi.json) y
+// comprueba que documenta todas las rutas del router.
+//
+// Uso:
+//
+//	go generate ./internal/api                                  # regenerar
+//	go run ./cmd/openapi-gen -check -o internal/api/openapi.json  # CI: falla si está desactualizado
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/trackfy/api-gateway/internal/api"
+	"github.com/trackfy/api-gateway/internal/middleware"
+)
+
+func main() {
+	output := flag.String("o", "openapi.json", "Fichero del documento")
+	check := flag.Bool("check", false, "No escribir: fallar si el fichero no coincide con el documento generado")
+	flag.Parse()
+
+	doc := api.OpenAPIDocument()
+
+	// Router real sin dependencias: solo se recorren las rutas, no se sirve nada
+	router := api.NewRouter(nil, nil, nil, nil, nil, nil, nil, "", api.MemoryFallback{}, middleware.CORSConfig{}, "", false)
+	routes, err := routerRoutes(router.(chi.Routes))
+	if err != nil {
+		fail("Failed to walk router: %v", err)
+	}
+	if missing := doc.Undocumented(routes); len(missing) > 0 {
+		fail("Rutas sin documentar en OpenAPIDocument:\n  %s", strings.Join(missing, "\n  "))
+	}
+
+	spec, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		fail("Failed to encode document: %v", err)
+	}
+	spec = append(spec, '\n')
+
+	if *check {
+		current, err := os.ReadFile(*output)
+		if err != nil {
+			fail("Failed to read %s: %v", *output, err)
+		}
+		if !bytes.Equal(current, spec) {
+			fail("%s está desactualizado: ejecuta go generate ./internal/api", *output)
+		}
+		fmt.Printf("%s al día (%d rutas)\n", *output, len(routes))
+		return
+	}
+
+	if err := os.WriteFile(*output, spec, 0o644); err != nil {
+		fail("Failed to write %s: %v", *output, err)
+	}
+	fmt.Printf("%s escrito (%d rutas)\n", *output, len(routes))
+}
+
+// routerRoutes método y ruta de cada endpoint, sin la barra final de los r.Get("/") de
+// los grupos. Los r.Handle (registrados para todos los métodos, CONNECT y TRACE incluidos)
+// cuentan como GET.
+func routerRoutes(router chi.Routes) ([][2]string, error) {
+	methods := make(map[string]map[string]bool)
+	var order []string
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if len(route) > 1 {
+			route = strings.TrimSuffix(route, "/")
+		}
+		if methods[route] == nil {
+			methods[route] = make(map[string]bool)
+			order = append(order, route)
+		}
+		methods[route][method] = true
+		return nil
+	})
+
+	var routes [][2]string
+	for _, route := range order {
+		set := methods[route]
+		if set[http.MethodConnect] && set[http.MethodTrace] {
+			routes = append(routes, [2]string{http.MethodGet, route})
+			continue
+		}
+		for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			if set[method] {
+				routes = append(routes, [2]string{method, route})
+			}
+		}
+	}
+	return routes, err
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
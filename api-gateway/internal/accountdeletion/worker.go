@@ -0,0 +1,284 @@
+// Package accountdeletion ejecuta en segundo plano los borrados de cuenta (derecho de
+// supresión, RGPD). El handler solo verifica al usuario y encola: el borrado toca
+// PostgreSQL, Redis, fy-analysis y la suscripción, y al terminar comprueba que no queda
+// ningún dato personal consultable antes de darlo por completado.
+package accountdeletion
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/db"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+const (
+	// pollTimeout espera bloqueante en la cola; acota lo que tarda Stop
+	pollTimeout = 5 * time.Second
+	// jobTimeout tiempo máximo de un borrado
+	jobTimeout = 2 * time.Minute
+)
+
+var deletionsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "account_deletions_total",
+	Help: "Borrados de cuenta procesados por resultado (completed, failed)",
+}, []string{"result"})
+
+// SubscriptionCanceller cancela la suscripción de pago activa del usuario
+type SubscriptionCanceller interface {
+	CancelUserSubscription(ctx context.Context, userID uuid.UUID) error
+}
+
+// Worker consume la cola de borrados de Redis de uno en uno
+type Worker struct {
+	postgres      *db.PostgresDB
+	redis         *db.RedisDB
+	fyAnalysis    *services.FyAnalysisClient
+	subscriptions SubscriptionCanceller
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewWorker crea el worker; fyAnalysis puede ser nil (no se anonimizan los reportes)
+func NewWorker(postgres *db.PostgresDB, redis *db.RedisDB, fyAnalysis *services.FyAnalysisClient) *Worker {
+	return &Worker{
+		postgres:   postgres,
+		redis:      redis,
+		fyAnalysis: fyAnalysis,
+		stopCh:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+}
+
+// SetSubscriptionCanceller configura el proveedor de pagos. Sin él, una cuenta con una
+// suscripción que sigue cobrando queda pendiente (no se borra con el cobro en marcha).
+func (w *Worker) SetSubscriptionCanceller(canceller SubscriptionCanceller) {
+	w.subscriptions = canceller
+}
+
+// Enqueue crea el job del usuario, o devuelve el que ya tiene en curso (created=false)
+func (w *Worker) Enqueue(ctx context.Context, userID uuid.UUID, ipAddress string) (*models.AccountDeletionJob, bool, error) {
+	now := time.Now()
+	return w.redis.EnqueueAccountDeletion(ctx, &models.AccountDeletionJob{
+		ID:        uuid.New(),
+		UserID:    userID,
+		IPAddress: ipAddress,
+		Status:    models.AccountDeletionQueued,
+		CreatedAt: now,
+		UpdatedAt: now,
+	})
+}
+
+// Job estado de un job (nil si no existe)
+func (w *Worker) Job(ctx context.Context, jobID uuid.UUID) (*models.AccountDeletionJob, error) {
+	return w.redis.GetAccountDeletionJob(ctx, jobID)
+}
+
+// Start reencola los jobs que quedaron a medias y arranca el consumo de la cola
+func (w *Worker) Start() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if n, err := w.redis.RequeueStalledAccountDeletions(ctx); err != nil {
+		log.Error().Err(err).Msg("[AccountDeletion] Failed to requeue stalled jobs")
+	} else if n > 0 {
+		log.Warn().Int("jobs", n).Msg("[AccountDeletion] Requeued stalled jobs")
+	}
+	cancel()
+
+	go w.run()
+	log.Info().Msg("[AccountDeletion] Worker started")
+}
+
+// Stop espera a que termine el job en curso
+func (w *Worker) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stopCh)
+	})
+	<-w.done
+}
+
+func (w *Worker) run() {
+	defer close(w.done)
+
+	for {
+		select {
+		case <-w.stopCh:
+			return
+		default:
+		}
+
+		jobID, err := w.redis.NextAccountDeletion(context.Background(), pollTimeout)
+		if err != nil {
+			log.Error().Err(err).Msg("[AccountDeletion] Failed to read queue")
+			select {
+			case <-w.stopCh:
+				return
+			case <-time.After(pollTimeout):
+			}
+			continue
+		}
+		if jobID == uuid.Nil {
+			continue
+		}
+
+		w.process(jobID)
+	}
+}
+
+// process ejecuta un job. Un fallo a mitad deja el job en failed con los pasos pendientes;
+// cada paso es idempotente, así que puede relanzarse encolándolo de nuevo.
+func (w *Worker) process(jobID uuid.UUID) {
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	defer cancel()
+	defer func() {
+		if err := w.redis.AckAccountDeletion(ctx, jobID); err != nil {
+			log.Error().Err(err).Str("job_id", jobID.String()).Msg("[AccountDeletion] Failed to ack job")
+		}
+	}()
+
+	job, err := w.redis.GetAccountDeletionJob(ctx, jobID)
+	if err != nil || job == nil {
+		log.Error().Err(err).Str("job_id", jobID.String()).Msg("[AccountDeletion] Job not found")
+		return
+	}
+
+	job.Status = models.AccountDeletionRunning
+	job.DataRemoved, job.Pending = nil, nil
+	job.UpdatedAt = time.Now()
+	w.save(ctx, job)
+
+	w.execute(ctx, job)
+
+	now := time.Now()
+	job.Status = models.AccountDeletionCompleted
+	if len(job.Pending) > 0 {
+		job.Status = models.AccountDeletionFailed
+	}
+	job.IPAddress = ""
+	job.UpdatedAt = now
+	job.CompletedAt = &now
+	w.save(ctx, job)
+	deletionsProcessed.WithLabelValues(job.Status).Inc()
+
+	event := log.Info()
+	if job.Status == models.AccountDeletionFailed {
+		event = log.Error()
+	}
+	event.
+		Str("job_id", job.ID.String()).
+		Str("user_id", job.UserID.String()).
+		Strs("data_removed", job.DataRemoved).
+		Strs("pending", job.Pending).
+		Msg("[AccountDeletion] Job finished")
+}
+
+// execute borra los datos del usuario y verifica que no queda nada. Rellena DataRemoved
+// y Pending del job.
+func (w *Worker) execute(ctx context.Context, job *models.AccountDeletionJob) {
+	userID := job.UserID
+	logger := log.With().Str("job_id", job.ID.String()).Str("user_id", userID.String()).Logger()
+
+	// Lo primero: si no se puede cancelar el cobro, la cuenta no se borra todavía
+	if w.subscriptions != nil {
+		if err := w.subscriptions.CancelUserSubscription(ctx, userID); err != nil {
+			logger.Error().Err(err).Msg("[AccountDeletion] Failed to cancel subscription")
+			job.Pending = append(job.Pending, "subscription")
+			return
+		}
+		job.DataRemoved = append(job.DataRemoved, "subscription")
+	} else {
+		sub, err := w.postgres.GetSubscriptionStatus(ctx, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			logger.Error().Err(err).Msg("[AccountDeletion] Failed to read subscription")
+			job.Pending = append(job.Pending, "subscription")
+			return
+		}
+		if err == nil && sub.Entitled() && sub.Status != "" && !sub.CancelAtPeriodEnd {
+			logger.Error().Str("status", sub.Status).Msg("[AccountDeletion] Active subscription and no payment provider configured")
+			job.Pending = append(job.Pending, "subscription")
+			return
+		}
+	}
+
+	// Antes del borrado: las claves de cache de conversación van por id de conversación
+	conversationIDs, err := w.postgres.GetUserConversationIDs(ctx, userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("[AccountDeletion] Failed to list conversations")
+		job.Pending = append(job.Pending, "user")
+		return
+	}
+
+	removed, err := w.postgres.DeleteUserAccount(ctx, userID, job.IPAddress)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Reintento de un job que ya anonimizó la cuenta: se siguen los pasos restantes
+		logger.Info().Msg("[AccountDeletion] Account already anonymized")
+	case err != nil:
+		logger.Error().Err(err).Msg("[AccountDeletion] Failed to delete account")
+		job.Pending = append(job.Pending, "user")
+		return
+	default:
+		job.DataRemoved = append(job.DataRemoved, removed...)
+	}
+
+	if err := w.redis.PurgeUserData(ctx, userID, conversationIDs); err != nil {
+		logger.Error().Err(err).Msg("[AccountDeletion] Failed to purge Redis data")
+		job.Pending = append(job.Pending, "cache")
+	} else {
+		job.DataRemoved = append(job.DataRemoved, "cache")
+	}
+
+	if w.fyAnalysis != nil {
+		if err := w.fyAnalysis.AnonymizeUserReports(ctx, userID.String()); err != nil {
+			logger.Error().Err(err).Msg("[AccountDeletion] Failed to anonymize URL reports")
+			job.Pending = append(job.Pending, "reports")
+		} else {
+			job.DataRemoved = append(job.DataRemoved, "reports")
+		}
+	}
+
+	// Verificación: nada personal debe seguir siendo consultable
+	remaining, err := w.postgres.UserPIIRemaining(ctx, userID)
+	if err != nil {
+		logger.Error().Err(err).Msg("[AccountDeletion] Failed to verify PostgreSQL data")
+		job.Pending = append(job.Pending, "verification")
+		return
+	}
+	keys, err := w.redis.UserDataRemaining(ctx, userID, conversationIDs)
+	if err != nil {
+		logger.Error().Err(err).Msg("[AccountDeletion] Failed to verify Redis data")
+		job.Pending = append(job.Pending, "verification")
+		return
+	}
+	if len(keys) > 0 {
+		remaining = append(remaining, "cache")
+	}
+	for _, table := range remaining {
+		logger.Error().Str("data", table).Msg("[AccountDeletion] Personal data still present after deletion")
+		job.Pending = appendUnique(job.Pending, table)
+	}
+}
+
+func (w *Worker) save(ctx context.Context, job *models.AccountDeletionJob) {
+	if err := w.redis.SaveAccountDeletionJob(ctx, job); err != nil {
+		log.Error().Err(err).Str("job_id", job.ID.String()).Msg("[AccountDeletion] Failed to save job status")
+	}
+}
+
+func appendUnique(list []string, value string) []string {
+	for _, v := range list {
+		if v == value {
+			return list
+		}
+	}
+	return append(list, value)
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+type DeleteAccountRequest struct {
+	Code string `json:"code"` // Código SMS de POST /api/v1/me/deletion-code
+}
+
+// AccountDeletionStatus estado público de un borrado (sin user_id ni IP)
+type AccountDeletionStatus struct {
+	JobID       string     `json:"job_id"`
+	Status      string     `json:"status"` // queued, running, completed, failed
+	DataRemoved []string   `json:"data_removed,omitempty"`
+	Pending     []string   `json:"pending,omitempty"`
+	StatusURL   string     `json:"status_url"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// SendAccountDeletionCode envía un código SMS al teléfono de la cuenta para confirmar el borrado
+// (simulado por ahora, como SendVerificationCode)
+func (h *Handler) SendAccountDeletionCode(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	user, err := h.postgres.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+
+	code, err := h.postgres.GenerateVerificationCode(r.Context(), user.Phone)
+	if err != nil {
+		log.Error().Err(err).Msg("[DeleteAccount] Failed to generate code")
+		respondError(w, http.StatusInternalServerError, "code_error", "Failed to generate code")
+		return
+	}
+
+	// El código nunca se registra: con él se puede borrar la cuenta
+	log.Info().Str("phone", maskPhone(user.Phone)).Msg("[DeleteAccount] Verification code generated")
+
+	// TODO: Integrar servicio SMS real
+	resp := SendCodeResponse{
+		Message:   "Código enviado",
+		ExpiresIn: 300,
+	}
+	if h.development {
+		resp.Code = code
+	}
+	respondJSON(w, http.StatusOK, resp)
+}
+
+// DeleteAccount borra la cuenta del usuario (derecho de supresión, RGPD). Exige un código SMS
+// recién pedido y encola el borrado: responde 202 con el job, cuyo estado se consulta en
+// GET /api/v1/account-deletions/{id} (sin sesión: el borrado revoca todas).
+func (h *Handler) DeleteAccount(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	if h.accountDeletion == nil {
+		respondError(w, http.StatusServiceUnavailable, "deletion_unavailable", "Account deletion is not available")
+		return
+	}
+
+	var req DeleteAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Code) == "" {
+		respondError(w, http.StatusBadRequest, "code_required", "Verification code required (POST /api/v1/me/deletion-code)")
+		return
+	}
+
+	user, err := h.postgres.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusNotFound, "user_not_found", "User not found")
+		return
+	}
+
+	valid, err := h.postgres.VerifyCode(r.Context(), user.Phone, strings.TrimSpace(req.Code))
+	if err != nil {
+		log.Error().Err(err).Msg("[DeleteAccount] Verification error")
+		respondError(w, http.StatusInternalServerError, "verify_error", "Verification failed")
+		return
+	}
+	if !valid {
+		respondError(w, http.StatusUnauthorized, "invalid_code", "Invalid or expired code")
+		return
+	}
+
+	job, created, err := h.accountDeletion.Enqueue(r.Context(), userID, getClientIP(r))
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("[DeleteAccount] Failed to enqueue deletion")
+		respondError(w, http.StatusInternalServerError, "queue_error", "Failed to schedule account deletion")
+		return
+	}
+
+	if created {
+		log.Info().Str("user_id", userID.String()).Str("job_id", job.ID.String()).Msg("[DeleteAccount] Account deletion queued")
+	}
+	respondJSON(w, http.StatusAccepted, accountDeletionStatus(job))
+}
+
+// GetAccountDeletionStatus estado de un borrado de cuenta. Público: el id del job es
+// aleatorio y la respuesta no contiene datos del usuario.
+func (h *Handler) GetAccountDeletionStatus(w http.ResponseWriter, r *http.Request) {
+	jobID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil || h.accountDeletion == nil {
+		respondError(w, http.StatusNotFound, "job_not_found", "Account deletion job not found")
+		return
+	}
+
+	job, err := h.accountDeletion.Job(r.Context(), jobID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "cache_error", "Failed to get account deletion status")
+		return
+	}
+	if job == nil {
+		respondError(w, http.StatusNotFound, "job_not_found", "Account deletion job not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, accountDeletionStatus(job))
+}
+
+func accountDeletionStatus(job *models.AccountDeletionJob) AccountDeletionStatus {
+	return AccountDeletionStatus{
+		JobID:       job.ID.String(),
+		Status:      job.Status,
+		DataRemoved: job.DataRemoved,
+		Pending:     job.Pending,
+		StatusURL:   "/api/v1/account-deletions/" + job.ID.String(),
+		CreatedAt:   job.CreatedAt,
+		UpdatedAt:   job.UpdatedAt,
+		CompletedAt: job.CompletedAt,
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+const (
+	// analyticsCacheTTL los agregados se recalculan como mucho cada 5 minutos
+	analyticsCacheTTL = 5 * time.Minute
+	// analyticsQueryTimeout las agregaciones recorren días de analysis_results
+	analyticsQueryTimeout = 15 * time.Second
+
+	trendingLimit = 10
+	// trendingMinUsers usuarios distintos que deben haber analizado un dominio para mostrarlo a los usuarios
+	trendingMinUsers = 3
+	// checkerCoverageWindow ventana de la cobertura de checkers
+	checkerCoverageWindow = 7 * 24 * time.Hour
+)
+
+// ==================== ANALYTICS (ADMIN) ====================
+
+// GetTrendingAnalytics top 10 de dominios, URLs, emails y teléfonos más analizados en 24h y 7 días.
+// GET /api/v1/analytics/trending
+func (h *Handler) GetTrendingAnalytics(w http.ResponseWriter, r *http.Request) {
+	var trending models.TrendingThreats
+	h.respondCachedAnalytics(w, r, "trending", &trending, func(ctx context.Context) (interface{}, error) {
+		now := time.Now().UTC()
+		last24h, err := h.postgres.GetTrendingEntities(ctx, now.Add(-24*time.Hour), trendingLimit)
+		if err != nil {
+			return nil, err
+		}
+		last7d, err := h.postgres.GetTrendingEntities(ctx, now.Add(-7*24*time.Hour), trendingLimit)
+		if err != nil {
+			return nil, err
+		}
+		return &models.TrendingThreats{Last24h: last24h, Last7d: last7d, GeneratedAt: now}, nil
+	})
+}
+
+// GetRiskDistribution histograma de risk scores del último día.
+// GET /api/v1/analytics/risk-distribution
+func (h *Handler) GetRiskDistribution(w http.ResponseWriter, r *http.Request) {
+	var dist models.RiskDistribution
+	h.respondCachedAnalytics(w, r, "risk_distribution", &dist, func(ctx context.Context) (interface{}, error) {
+		now := time.Now().UTC()
+		result, err := h.postgres.GetRiskDistribution(ctx, now.Add(-24*time.Hour))
+		if err != nil {
+			return nil, err
+		}
+		result.GeneratedAt = now
+		return result, nil
+	})
+}
+
+// GetCheckerCoverage porcentaje de análisis de los últimos 7 días en los que cada checker dio positivo.
+// GET /api/v1/analytics/checker-coverage
+func (h *Handler) GetCheckerCoverage(w http.ResponseWriter, r *http.Request) {
+	var coverage models.CheckerCoverage
+	h.respondCachedAnalytics(w, r, "checker_coverage", &coverage, func(ctx context.Context) (interface{}, error) {
+		now := time.Now().UTC()
+		result, err := h.postgres.GetCheckerCoverage(ctx, now.Add(-checkerCoverageWindow))
+		if err != nil {
+			return nil, err
+		}
+		result.GeneratedAt = now
+		return result, nil
+	})
+}
+
+// ==================== TENDENCIAS (USUARIOS) ====================
+
+// GetTrendingThreats dominios peligrosos más analizados en las últimas 24h (sin URLs, emails
+// ni teléfonos concretos, que pueden contener datos personales).
+// GET /api/v1/threats/trending
+func (h *Handler) GetTrendingThreats(w http.ResponseWriter, r *http.Request) {
+	var trending struct {
+		Domains     []models.TrendingDomain `json:"domains"`
+		GeneratedAt time.Time               `json:"generated_at"`
+	}
+	h.respondCachedAnalytics(w, r, "trending_public", &trending, func(ctx context.Context) (interface{}, error) {
+		now := time.Now().UTC()
+		domains, err := h.postgres.GetTrendingThreatDomains(ctx, now.Add(-24*time.Hour), trendingMinUsers, trendingLimit)
+		if err != nil {
+			return nil, err
+		}
+		trending.Domains = domains
+		trending.GeneratedAt = now
+		return &trending, nil
+	})
+}
+
+// respondCachedAnalytics responde con el agregado cacheado en Redis (en dest) o lo calcula con
+// build y lo cachea analyticsCacheTTL. Un fallo de Redis no impide responder.
+func (h *Handler) respondCachedAnalytics(w http.ResponseWriter, r *http.Request, name string, dest interface{}, build func(ctx context.Context) (interface{}, error)) {
+	found, err := h.redis.GetCachedAnalytics(r.Context(), name, dest)
+	if err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("[Analytics] Failed to read cached aggregate")
+	}
+	if found {
+		respondJSON(w, http.StatusOK, dest)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), analyticsQueryTimeout)
+	defer cancel()
+
+	result, err := build(ctx)
+	if err != nil {
+		log.Error().Err(err).Str("name", name).Msg("[Analytics] Failed to build aggregate")
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to compute analytics")
+		return
+	}
+	if err := h.redis.CacheAnalytics(r.Context(), name, result, analyticsCacheTTL); err != nil {
+		log.Warn().Err(err).Str("name", name).Msg("[Analytics] Failed to cache aggregate")
+	}
+
+	respondJSON(w, http.StatusOK, result)
+}
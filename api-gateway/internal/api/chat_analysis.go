@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+const (
+	// chatAnalysisMaxEntities entidades analizadas como mucho por mensaje
+	chatAnalysisMaxEntities = 5
+	// chatAnalysisTimeout presupuesto de cada análisis (una entidad lenta no retrasa la respuesta entera)
+	chatAnalysisTimeout = 4 * time.Second
+)
+
+// ChatEntityVerdict veredicto de una entidad del mensaje (tarjeta bajo la burbuja del chat)
+type ChatEntityVerdict struct {
+	Type              string   `json:"type"`
+	Value             string   `json:"value"`
+	RiskScore         int      `json:"risk_score"`
+	RiskLevel         string   `json:"risk_level"` // safe, warning, danger
+	Verdict           string   `json:"verdict"`    // safe, suspicious, dangerous
+	Reasons           []string `json:"reasons,omitempty"`
+	RecommendedAction string   `json:"recommended_action,omitempty"`
+	Checkers          []string `json:"checkers,omitempty"` // Checkers que dieron positivo
+}
+
+// ChatEntityFailure entidad que no se pudo analizar
+type ChatEntityFailure struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// ChatAnalysisSummary resultado del análisis que hace el gateway de las entidades del mensaje
+type ChatAnalysisSummary struct {
+	Verdicts  []ChatEntityVerdict `json:"verdicts"`
+	Failed    []ChatEntityFailure `json:"failed,omitempty"`
+	Partial   bool                `json:"partial"`    // Alguna entidad falló
+	RiskLevel string              `json:"risk_level"` // Peor nivel de los veredictos
+}
+
+// chatEntity entidad pendiente de análisis
+type chatEntity struct {
+	Type  string
+	Value string
+}
+
+// analyzeChatEntities analiza las entidades que fy-engine extrajo pero no analizó él mismo.
+// Retorna nil si el intent no es de análisis o no queda nada por analizar.
+func (h *Handler) analyzeChatEntities(ctx context.Context, fyResp *services.FyChatResponse) *ChatAnalysisSummary {
+	if h.fyAnalysis == nil || fyResp.Intent != "analysis" || fyResp.Entities == nil {
+		return nil
+	}
+
+	entities := pendingChatEntities(fyResp)
+	if len(entities) == 0 {
+		return nil
+	}
+
+	verdicts := make([]*ChatEntityVerdict, len(entities))
+	errs := make([]error, len(entities))
+
+	var wg sync.WaitGroup
+	for i, e := range entities {
+		wg.Add(1)
+		go func(i int, e chatEntity) {
+			defer wg.Done()
+
+			analysisCtx, cancel := context.WithTimeout(ctx, chatAnalysisTimeout)
+			defer cancel()
+
+			result, err := h.fyAnalysis.Analyze(analysisCtx, e.Value, e.Type)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			verdicts[i] = &ChatEntityVerdict{
+				Type:              e.Type,
+				Value:             e.Value,
+				RiskScore:         result.RiskScore,
+				RiskLevel:         result.RiskLevel,
+				Verdict:           verdictFromRiskLevel(result.RiskLevel),
+				Reasons:           result.Reasons,
+				RecommendedAction: result.RecommendedAction,
+				Checkers:          result.PositiveSources(),
+			}
+		}(i, e)
+	}
+	wg.Wait()
+
+	// Se conserva el orden del mensaje y se informa de lo que sí se pudo analizar
+	summary := &ChatAnalysisSummary{Verdicts: []ChatEntityVerdict{}, RiskLevel: "safe"}
+	for i, e := range entities {
+		if errs[i] != nil {
+			log.Warn().Err(errs[i]).Str("type", e.Type).Msg("[Chat] Análisis de entidad fallido")
+			summary.Failed = append(summary.Failed, ChatEntityFailure{Type: e.Type, Value: e.Value, Error: "analysis_unavailable"})
+			continue
+		}
+		summary.Verdicts = append(summary.Verdicts, *verdicts[i])
+		if riskLevelRank(verdicts[i].RiskLevel) > riskLevelRank(summary.RiskLevel) {
+			summary.RiskLevel = verdicts[i].RiskLevel
+		}
+	}
+	summary.Partial = len(summary.Failed) > 0
+	if len(summary.Verdicts) == 0 {
+		summary.RiskLevel = "unknown"
+	}
+
+	log.Debug().
+		Int("analyzed", len(summary.Verdicts)).
+		Int("failed", len(summary.Failed)).
+		Str("risk_level", summary.RiskLevel).
+		Msg("[Chat] Entidades analizadas por el gateway")
+	return summary
+}
+
+// entitiesFound formato de Message.EntitiesFound para el mensaje de Fy
+func (s *ChatAnalysisSummary) entitiesFound() map[string]interface{} {
+	found := map[string]interface{}{
+		"verdicts":   s.Verdicts,
+		"risk_level": s.RiskLevel,
+	}
+	if len(s.Failed) > 0 {
+		found["failed"] = s.Failed
+	}
+	return found
+}
+
+// pendingChatEntities entidades del mensaje (URLs > emails > teléfonos) sin la que ya cubre el trace de fy-engine
+func pendingChatEntities(fyResp *services.FyChatResponse) []chatEntity {
+	var analyzed string
+	if fyResp.AnalysisPerformed && fyResp.Trace != nil {
+		analyzed = strings.ToLower(fyResp.Trace.EntityValue)
+	}
+
+	seen := make(map[string]bool)
+	var entities []chatEntity
+	add := func(entityType string, values []string) {
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			key := strings.ToLower(v)
+			if v == "" || key == analyzed || seen[key] || len(entities) == chatAnalysisMaxEntities {
+				continue
+			}
+			seen[key] = true
+			entities = append(entities, chatEntity{Type: entityType, Value: v})
+		}
+	}
+	add("url", fyResp.Entities.URLs)
+	add("email", fyResp.Entities.Emails)
+	add("phone", fyResp.Entities.Phones)
+	return entities
+}
+
+// verdictFromRiskLevel traduce el nivel de fy-analysis a los veredictos de analysis_results
+func verdictFromRiskLevel(level string) string {
+	switch level {
+	case "danger":
+		return "dangerous"
+	case "warning":
+		return "suspicious"
+	case "safe":
+		return "safe"
+	}
+	return "unknown"
+}
+
+func riskLevelRank(level string) int {
+	switch level {
+	case "danger":
+		return 2
+	case "warning":
+		return 1
+	}
+	return 0
+}
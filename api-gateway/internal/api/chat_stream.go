@@ -0,0 +1,108 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+// ChatStreamToken evento SSE con un fragmento de la respuesta de Fy
+type ChatStreamToken struct {
+	Type string `json:"type"` // token
+	Text string `json:"text"`
+}
+
+// ChatStreamFinal último evento SSE con la respuesta completa (mismos campos que /chat)
+type ChatStreamFinal struct {
+	Type string `json:"type"` // final
+	ChatResponse
+}
+
+// ChatStreamError evento SSE cuando fy-engine falla a mitad de la respuesta
+type ChatStreamError struct {
+	Type           string `json:"type"` // error
+	ConversationID string `json:"conversation_id"`
+	Error          string `json:"error"`
+	Message        string `json:"message"`
+}
+
+// ChatStream maneja POST /api/v1/chat/stream - mismo body que /chat, responde por SSE
+// los fragmentos de la respuesta según los genera fy-engine, el resultado final (con el
+// análisis de entidades) y [DONE]. El mensaje completo se guarda al terminar el stream;
+// si el cliente se desconecta se cancela la petición a fy-engine y no se guarda nada.
+func (h *Handler) ChatStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, http.StatusInternalServerError, "streaming_unsupported", "Streaming not supported")
+		return
+	}
+
+	// Antes de empezar el stream: los errores de validación van con su status
+	turn, ok := h.beginChatTurn(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Nginx: no bufferizar el stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// onToken se ejecuta en esta goroutine: las escrituras no necesitan lock
+	onToken := func(text string) {
+		writeSSE(w, flusher, ChatStreamToken{Type: "token", Text: text})
+	}
+
+	fyResp, err := h.fyEngine.ChatStream(r.Context(), turn.userID.String(), turn.message, turn.context, turn.allowlist, onToken)
+	if errors.Is(err, services.ErrChatStreamUnsupported) {
+		// fy-engine sin streaming: la respuesta completa llega como un solo fragmento
+		log.Debug().Msg("[Chat] fy-engine sin /chat/stream, usando /chat")
+		fyResp, err = h.fyEngine.Chat(r.Context(), turn.userID.String(), turn.message, turn.context, turn.allowlist)
+		if err == nil {
+			onToken(fyResp.Response)
+		}
+	}
+
+	if err != nil {
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			log.Info().Str("conversation_id", turn.convID.String()).Msg("[Chat] Cliente desconectado durante el stream")
+			return
+		}
+		log.Error().Err(err).Msg("[Chat] Fy Engine stream error")
+		writeSSE(w, flusher, ChatStreamError{
+			Type:           "error",
+			ConversationID: turn.convID.String(),
+			Error:          "fy_error",
+			Message:        "Failed to process message",
+		})
+		writeSSEDone(w, flusher)
+		return
+	}
+
+	resp := h.completeChatTurn(r.Context(), turn, fyResp)
+	writeSSE(w, flusher, ChatStreamFinal{Type: "final", ChatResponse: resp})
+	writeSSEDone(w, flusher)
+}
+
+// writeSSE envía un evento data: con el payload en JSON
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
+
+// writeSSEDone evento terminal del stream
+func writeSSEDone(w http.ResponseWriter, flusher http.Flusher) {
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
@@ -0,0 +1,127 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/push"
+)
+
+// reclassificationDispatchTimeout presupuesto del envío de alertas tras responder al webhook
+const reclassificationDispatchTimeout = 2 * time.Minute
+
+// ==================== DISPOSITIVOS (PUSH) ====================
+
+type DeviceRequest struct {
+	Token    string `json:"token"`    // Token de FCM (Android e iOS)
+	Platform string `json:"platform"` // android, ios
+}
+
+// RegisterDevice registra el token push del dispositivo y lo liga a la sesión actual.
+// POST /api/v1/me/devices
+func (h *Handler) RegisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+	sessionID, _ := middleware.GetSessionID(r.Context())
+
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	req.Token = strings.TrimSpace(req.Token)
+	req.Platform = strings.ToLower(strings.TrimSpace(req.Platform))
+	if req.Token == "" || len(req.Token) > 4096 {
+		respondError(w, http.StatusBadRequest, "invalid_token", "A valid push token is required")
+		return
+	}
+	if req.Platform != "android" && req.Platform != "ios" {
+		respondError(w, http.StatusBadRequest, "invalid_platform", "platform must be android or ios")
+		return
+	}
+
+	device, err := h.postgres.RegisterDevice(r.Context(), userID, sessionID, req.Token, req.Platform)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to register device")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, device)
+}
+
+// UnregisterDevice elimina el token push del dispositivo.
+// DELETE /api/v1/me/devices
+func (h *Handler) UnregisterDevice(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var req DeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || strings.TrimSpace(req.Token) == "" {
+		respondError(w, http.StatusBadRequest, "invalid_body", "token is required")
+		return
+	}
+
+	deleted, err := h.postgres.DeleteDevice(r.Context(), userID, strings.TrimSpace(req.Token))
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to unregister device")
+		return
+	}
+	if !deleted {
+		respondError(w, http.StatusNotFound, "not_found", "Device not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Device unregistered"})
+}
+
+// ReclassificationWebhook recibe de fy-analysis los inputs reclasificados como peligrosos
+// y avisa en segundo plano a quienes los analizaron recientemente.
+// POST /internal/webhooks/reclassification (firmado con INTERNAL_SIGNING_SECRET)
+func (h *Handler) ReclassificationWebhook(w http.ResponseWriter, r *http.Request) {
+	var event push.Reclassification
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+	if hash, err := hex.DecodeString(event.InputHash); err != nil || len(hash) != sha256.Size || event.Verdict == "" {
+		respondError(w, http.StatusBadRequest, "invalid_event", "input_hash (hex SHA-256) and verdict are required")
+		return
+	}
+
+	if event.Verdict != "dangerous" {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"dispatched": false, "reason": "verdict_not_dangerous"})
+		return
+	}
+	if h.pushDispatcher == nil {
+		respondJSON(w, http.StatusOK, map[string]interface{}{"dispatched": false, "reason": "push_disabled"})
+		return
+	}
+
+	// fy-analysis no espera al envío: se responde y las alertas salen en background
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), reclassificationDispatchTimeout)
+		defer cancel()
+
+		result, err := h.pushDispatcher.NotifyReclassification(ctx, &event)
+		if err != nil {
+			log.Error().Err(err).Str("input_type", event.InputType).Msg("[Push] Reclassification dispatch failed")
+			return
+		}
+		log.Info().
+			Str("input_type", event.InputType).
+			Str("reason", event.Reason).
+			Int("recipients", result.Recipients).
+			Int("sent", result.Sent).
+			Int("pruned", result.Pruned).
+			Int("failed", result.Failed).
+			Msg("[Push] Reclassification alerts dispatched")
+	}()
+
+	respondJSON(w, http.StatusAccepted, map[string]interface{}{"dispatched": true})
+}
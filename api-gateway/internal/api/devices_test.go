@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/push"
+)
+
+type stubDeviceStore struct {
+	recipients []models.AlertRecipient
+}
+
+func (s *stubDeviceStore) GetAlertRecipients(ctx context.Context, inputHash []byte, since time.Time) ([]models.AlertRecipient, error) {
+	return s.recipients, nil
+}
+
+func (s *stubDeviceStore) DeleteDeviceTokens(ctx context.Context, tokens []string) error {
+	return nil
+}
+
+// stubPushProvider publica cada mensaje enviado en sent
+type stubPushProvider struct {
+	sent chan *push.Message
+}
+
+func (p *stubPushProvider) Send(ctx context.Context, msg *push.Message) error {
+	p.sent <- msg
+	return nil
+}
+
+func (p *stubPushProvider) Name() string { return "stub" }
+
+func TestReclassificationWebhook(t *testing.T) {
+	hash := sha256.Sum256([]byte("http://correos-pagos.top/track"))
+	validHash := hex.EncodeToString(hash[:])
+
+	provider := &stubPushProvider{sent: make(chan *push.Message, 1)}
+	store := &stubDeviceStore{recipients: []models.AlertRecipient{
+		{UserID: uuid.New(), Token: "device-1", EntityType: "url", EntityValue: "http://correos-pagos.top/track"},
+	}}
+	h := &Handler{pushDispatcher: push.NewDispatcher(store, provider, time.Hour)}
+
+	tests := []struct {
+		name       string
+		handler    *Handler
+		body       string
+		wantStatus int
+		wantPush   bool
+	}{
+		{"dangerous", h, `{"input_type":"url","input_hash":"` + validHash + `","verdict":"dangerous","reason":"community_reports"}`, http.StatusAccepted, true},
+		{"not dangerous", h, `{"input_type":"url","input_hash":"` + validHash + `","verdict":"suspicious"}`, http.StatusOK, false},
+		{"push disabled", &Handler{}, `{"input_type":"url","input_hash":"` + validHash + `","verdict":"dangerous"}`, http.StatusOK, false},
+		{"invalid hash", h, `{"input_type":"url","input_hash":"1234","verdict":"dangerous"}`, http.StatusBadRequest, false},
+		{"invalid body", h, `{`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/internal/webhooks/reclassification", bytes.NewBufferString(tt.body))
+			rec := httptest.NewRecorder()
+			tt.handler.ReclassificationWebhook(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+
+			select {
+			case msg := <-provider.sent:
+				if !tt.wantPush {
+					t.Fatalf("unexpected push to %s", msg.Token)
+				}
+				if msg.Token != "device-1" || msg.Data["verdict"] != "dangerous" {
+					t.Errorf("push = %+v", msg)
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.wantPush {
+					t.Fatal("no push sent")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+// GetMyEntities maneja GET /api/v1/me/entities?type=&limit=&offset= - URLs, emails y teléfonos
+// que el usuario ha consultado en el chat, sin duplicados y con el último veredicto de cada uno
+func (h *Handler) GetMyEntities(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+	query := r.URL.Query()
+
+	entityType := query.Get("type")
+	if entityType != "" && entityType != "url" && entityType != "email" && entityType != "phone" {
+		respondError(w, http.StatusBadRequest, "invalid_type", "type must be url, email or phone")
+		return
+	}
+
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	entities, total, err := h.postgres.GetUserEntities(r.Context(), userID, entityType, limit, offset)
+	if err != nil {
+		log.Error().Err(err).Msg("[Entities] Failed to get user entities")
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get entities")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, models.UserEntityPage{
+		Entities: entities,
+		Total:    total,
+		Limit:    limit,
+		Offset:   offset,
+	})
+}
+
+// messageEntities entidades del mensaje del usuario (las que extrajo fy-engine más la del
+// trace) con el veredicto del trace o del análisis del gateway cuando lo hay
+func messageEntities(fyResp *services.FyChatResponse, analysis *ChatAnalysisSummary) []models.MessageEntity {
+	var entities []models.MessageEntity
+	index := make(map[string]int)
+
+	add := func(entityType, value, verdict string, riskScore *int) {
+		value = strings.TrimSpace(value)
+		key := entityKey(entityType, value)
+		if key == "" {
+			return
+		}
+		id := entityType + ":" + key
+		i, ok := index[id]
+		if !ok {
+			index[id] = len(entities)
+			entities = append(entities, models.MessageEntity{Type: entityType, Value: value, Key: key})
+			i = len(entities) - 1
+		}
+		// unknown = fy-analysis no respondió: no es un veredicto
+		if verdict != "" && verdict != "unknown" && entities[i].Verdict == "" {
+			entities[i].Verdict = verdict
+			entities[i].RiskScore = riskScore
+		}
+	}
+
+	if fyResp.Entities != nil {
+		for _, v := range fyResp.Entities.URLs {
+			add("url", v, "", nil)
+		}
+		for _, v := range fyResp.Entities.Emails {
+			add("email", v, "", nil)
+		}
+		for _, v := range fyResp.Entities.Phones {
+			add("phone", v, "", nil)
+		}
+	}
+	if trace := fyResp.Trace; fyResp.AnalysisPerformed && trace != nil && trace.EntityType != "" {
+		score := trace.RiskScore
+		add(trace.EntityType, trace.EntityValue, trace.Verdict, &score)
+	}
+	if analysis != nil {
+		for _, v := range analysis.Verdicts {
+			score := v.RiskScore
+			add(v.Type, v.Value, v.Verdict, &score)
+		}
+	}
+	return entities
+}
+
+// entityKey valor normalizado de una entidad para deduplicar: URL sin esquema, www ni barra
+// final; email en minúsculas; teléfono en formato +CC. Vacío si no es válida.
+func entityKey(entityType, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+
+	switch entityType {
+	case "url":
+		if i := strings.Index(value, "://"); i >= 0 {
+			value = value[i+3:]
+		}
+		return strings.TrimSuffix(strings.TrimPrefix(value, "www."), "/")
+	case "email", "phone":
+		return normalizeAllowlistValue(entityType, value)
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/export"
+	"github.com/trackfy/api-gateway/internal/middleware"
+)
+
+const (
+	// defaultExportTimezone zona horaria de las fechas si el cliente no envía ?tz=
+	defaultExportTimezone = "Europe/Madrid"
+	// exportsPerDay exportaciones por usuario cada 24h (conversación o cuenta, contador común)
+	exportsPerDay = 5
+)
+
+// exportOptions idioma del usuario y zona horaria de ?tz= (IANA). false si tz no es válida
+// (ya respondido con 400).
+func (h *Handler) exportOptions(w http.ResponseWriter, r *http.Request, language string) (export.Options, bool) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		tz = defaultExportTimezone
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_timezone", "tz must be an IANA time zone (e.g. Europe/Madrid)")
+		return export.Options{}, false
+	}
+	return export.Options{Language: language, Location: loc}, true
+}
+
+// ExportConversation maneja GET /api/v1/conversations/{id}/export?format=json|text&tz=
+// Conversación completa (también archivada) para guardarla o adjuntarla a una denuncia
+func (h *Handler) ExportConversation(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	convID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_id", "Invalid conversation ID")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "text" {
+		respondError(w, http.StatusBadRequest, "invalid_format", "format must be json or text")
+		return
+	}
+
+	user, err := h.postgres.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get user")
+		return
+	}
+	opts, ok := h.exportOptions(w, r, user.Language)
+	if !ok {
+		return
+	}
+
+	conv, err := h.postgres.GetExportConversation(r.Context(), userID, convID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, http.StatusNotFound, "not_found", "Conversation not found")
+		return
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("[Export] Failed to get conversation")
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get conversation")
+		return
+	}
+
+	filename := "trackfy-conversation-" + conv.ID.String()
+	if format == "text" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".txt"))
+		err = export.WriteConversationText(r.Context(), w, h.postgres, userID, *conv, opts)
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".json"))
+		err = export.WriteConversationJSON(r.Context(), w, h.postgres, userID, *conv, opts)
+	}
+	if err != nil {
+		// La respuesta ya ha empezado: el cliente recibe un fichero truncado
+		log.Error().Err(err).Str("conversation_id", conv.ID.String()).Msg("[Export] Conversation export failed")
+	}
+}
+
+// ExportMyData maneja GET /api/v1/me/export?tz= - archivo JSON con el perfil, las
+// estadísticas y todas las conversaciones del usuario, generado en streaming
+func (h *Handler) ExportMyData(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	user, err := h.postgres.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get user")
+		return
+	}
+	opts, ok := h.exportOptions(w, r, user.Language)
+	if !ok {
+		return
+	}
+
+	stats, err := h.postgres.GetUserStats(r.Context(), userID)
+	if err != nil {
+		// Las estadísticas son accesorias: se exporta sin ellas
+		log.Warn().Err(err).Msg("[Export] Failed to get user stats")
+		stats = nil
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "trackfy-export-"+time.Now().Format("2006-01-02")+".json"))
+	if err := export.WriteUserArchive(r.Context(), w, h.postgres, user, stats, opts); err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("[Export] Account export failed")
+	}
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"net"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
@@ -12,10 +16,12 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/accountdeletion"
 	"github.com/trackfy/api-gateway/internal/auth"
 	"github.com/trackfy/api-gateway/internal/db"
 	"github.com/trackfy/api-gateway/internal/middleware"
 	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/push"
 	"github.com/trackfy/api-gateway/internal/services"
 )
 
@@ -25,6 +31,11 @@ type Handler struct {
 	jwtManager *auth.JWTManager
 	fyEngine   *services.FyEngineClient
 	fyAnalysis *services.FyAnalysisClient
+
+	pushDispatcher  *push.Dispatcher // nil = notificaciones push deshabilitadas
+	memoryFallback  MemoryFallback
+	accountDeletion *accountdeletion.Worker
+	development     bool // ENVIRONMENT=development: los códigos SMS se devuelven en la respuesta
 }
 
 func NewHandler(postgres *db.PostgresDB, redis *db.RedisDB, jwtManager *auth.JWTManager, fyEngine *services.FyEngineClient) *Handler {
@@ -41,6 +52,26 @@ func (h *Handler) SetFyAnalysisClient(client *services.FyAnalysisClient) {
 	h.fyAnalysis = client
 }
 
+// SetPushDispatcher configura el envío de alertas push
+func (h *Handler) SetPushDispatcher(dispatcher *push.Dispatcher) {
+	h.pushDispatcher = dispatcher
+}
+
+// SetAccountDeletionWorker configura la cola de borrados de cuenta
+func (h *Handler) SetAccountDeletionWorker(worker *accountdeletion.Worker) {
+	h.accountDeletion = worker
+}
+
+// SetDevelopment activa el modo desarrollo (códigos SMS en la respuesta mientras no haya SMS real)
+func (h *Handler) SetDevelopment(enabled bool) {
+	h.development = enabled
+}
+
+// SetMemoryFallback configura el contexto que se recupera de PostgreSQL si Redis no tiene la memoria de Fy
+func (h *Handler) SetMemoryFallback(fallback MemoryFallback) {
+	h.memoryFallback = fallback
+}
+
 // ==================== AUTH ====================
 
 type RegisterRequest struct {
@@ -307,6 +338,49 @@ func (h *Handler) LogoutAll(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, map[string]string{"message": "All sessions logged out"})
 }
 
+// RevokeSession invalida una sesión concreta del usuario (p. ej. un dispositivo desconocido).
+// DELETE /api/v1/me/sessions/{id}
+func (h *Handler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+	currentSessionID, _ := middleware.GetSessionID(r.Context())
+
+	sessionID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_id", "Invalid session ID")
+		return
+	}
+
+	// La sesión actual se cierra igual que con Logout
+	if sessionID == currentSessionID {
+		h.Logout(w, r)
+		return
+	}
+
+	tokenHash, err := h.postgres.GetActiveSessionTokenHash(r.Context(), userID, sessionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			respondError(w, http.StatusNotFound, "not_found", "Session not found")
+			return
+		}
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to revoke session")
+		return
+	}
+
+	if err := h.postgres.InvalidateSession(r.Context(), sessionID, "revoked_by_user"); err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to revoke session")
+		return
+	}
+	// Redis indexa la sesión por el hash hex del token (el mismo SHA-256 que guarda PostgreSQL)
+	_ = h.redis.DeleteSession(r.Context(), hex.EncodeToString(tokenHash), userID)
+
+	log.Info().
+		Str("user_id", userID.String()).
+		Str("session_id", sessionID.String()).
+		Msg("[RevokeSession] Session revoked by user")
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Session revoked"})
+}
+
 // ==================== USER ====================
 
 // GetMe devuelve el perfil del usuario actual
@@ -398,7 +472,9 @@ func (h *Handler) GetConversations(w http.ResponseWriter, r *http.Request) {
 		offset = 0
 	}
 
-	conversations, err := h.postgres.GetUserConversations(r.Context(), userID, limit, offset)
+	includeArchived := r.URL.Query().Get("include_archived") == "true"
+
+	conversations, err := h.postgres.GetUserConversations(r.Context(), userID, limit, offset, includeArchived)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get conversations")
 		return
@@ -407,6 +483,41 @@ func (h *Handler) GetConversations(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, conversations)
 }
 
+// ArchiveConversation mueve una conversación del usuario al archivo
+func (h *Handler) ArchiveConversation(w http.ResponseWriter, r *http.Request) {
+	h.moveConversation(w, r, h.postgres.ArchiveConversation, "Conversation archived")
+}
+
+// UnarchiveConversation devuelve una conversación archivada a la lista activa
+func (h *Handler) UnarchiveConversation(w http.ResponseWriter, r *http.Request) {
+	h.moveConversation(w, r, h.postgres.UnarchiveConversation, "Conversation unarchived")
+}
+
+func (h *Handler) moveConversation(w http.ResponseWriter, r *http.Request, move func(context.Context, uuid.UUID, uuid.UUID) (bool, error), message string) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	convID, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_id", "Invalid conversation ID")
+		return
+	}
+
+	moved, err := move(r.Context(), convID, userID)
+	if err != nil {
+		log.Error().Err(err).Str("conversation_id", convID.String()).Msg("[Archive] Failed to move conversation")
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to update conversation")
+		return
+	}
+	if !moved {
+		respondError(w, http.StatusNotFound, "not_found", "Conversation not found")
+		return
+	}
+
+	h.redis.InvalidateConversationCache(r.Context(), convID)
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": message})
+}
+
 // GetConversation obtiene una conversación específica
 func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) {
 	userID, _ := middleware.GetUserID(r.Context())
@@ -427,7 +538,11 @@ func (h *Handler) GetConversation(w http.ResponseWriter, r *http.Request) {
 	respondJSON(w, http.StatusOK, conv)
 }
 
-// GetConversationMessages obtiene los mensajes de una conversación
+// GetConversationMessages obtiene los mensajes de una conversación.
+// GET /api/v1/conversations/{id}/messages?limit=&offset= (legacy, orden cronológico)
+// GET /api/v1/conversations/{id}/messages?before=latest|<cursor> (más recientes primero)
+// GET /api/v1/conversations/{id}/messages?after=<cursor> (mensajes nuevos, orden cronológico)
+// El cursor es el ID de un mensaje (next_cursor de la página anterior) o un timestamp RFC 3339.
 func (h *Handler) GetConversationMessages(w http.ResponseWriter, r *http.Request) {
 	userID, _ := middleware.GetUserID(r.Context())
 	convIDStr := chi.URLParam(r, "id")
@@ -439,7 +554,7 @@ func (h *Handler) GetConversationMessages(w http.ResponseWriter, r *http.Request
 	}
 
 	// Verificar propiedad
-	_, err = h.postgres.GetConversation(r.Context(), convID, userID)
+	conv, err := h.postgres.GetConversation(r.Context(), convID, userID)
 	if err != nil {
 		respondError(w, http.StatusNotFound, "not_found", "Conversation not found")
 		return
@@ -450,15 +565,77 @@ func (h *Handler) GetConversationMessages(w http.ResponseWriter, r *http.Request
 		limit = 50
 	}
 
-	offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+	// Sin before/after se mantiene la paginación por offset (orden cronológico, array plano)
+	query := r.URL.Query()
+	if query.Get("before") == "" && query.Get("after") == "" {
+		offset, _ := strconv.Atoi(query.Get("offset"))
+
+		messages, err := h.postgres.GetConversationMessages(r.Context(), convID, limit, offset)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "db_error", "Failed to get messages")
+			return
+		}
+
+		respondJSON(w, http.StatusOK, messages)
+		return
+	}
+
+	// before=latest pide la página más reciente; after=<cursor> los mensajes nuevos
+	before := query.Get("after") == ""
+	rawCursor := query.Get("after")
+	if before {
+		rawCursor = query.Get("before")
+	}
+
+	var cursor *models.MessageCursor
+	if rawCursor != "latest" {
+		cursor, err = h.parseMessageCursor(r, convID, rawCursor)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid_cursor", "Cursor must be a message ID of this conversation or an RFC 3339 timestamp")
+			return
+		}
+	}
 
-	messages, err := h.postgres.GetConversationMessages(r.Context(), convID, limit, offset)
+	messages, err := h.postgres.GetConversationMessagesPage(r.Context(), convID, cursor, before, limit)
 	if err != nil {
 		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get messages")
 		return
 	}
 
-	respondJSON(w, http.StatusOK, messages)
+	page := newMessagePage(messages, limit, conv.MessageCount)
+	page.OldestAt, page.NewestAt, err = h.postgres.GetConversationMessageBounds(r.Context(), convID)
+	if err != nil {
+		log.Warn().Err(err).Str("conversation_id", convID.String()).Msg("[Messages] Failed to get message bounds")
+	}
+
+	respondJSON(w, http.StatusOK, page)
+}
+
+// newMessagePage página a partir de las limit+1 filas de GetConversationMessagesPage: la fila
+// de más solo indica que hay otra página, cuyo cursor es el último mensaje devuelto
+func newMessagePage(messages []models.Message, limit, messageCount int) *models.MessagePage {
+	page := &models.MessagePage{Messages: messages, MessageCount: messageCount}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = page.Messages[limit-1].ID.String()
+	}
+	if page.Messages == nil {
+		page.Messages = []models.Message{}
+	}
+	return page
+}
+
+// parseMessageCursor interpreta un cursor: ID de un mensaje de la conversación o timestamp RFC 3339
+func (h *Handler) parseMessageCursor(r *http.Request, convID uuid.UUID, raw string) (*models.MessageCursor, error) {
+	if messageID, err := uuid.Parse(raw); err == nil {
+		return h.postgres.GetMessageCursor(r.Context(), convID, messageID)
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, err
+	}
+	return &models.MessageCursor{CreatedAt: ts}, nil
 }
 
 // ==================== CHAT ====================
@@ -485,21 +662,52 @@ type ChatResponse struct {
 	Mood           string            `json:"mood"`
 	Intent         string            `json:"intent"`
 	Trace          *ChatResponseTrace `json:"trace,omitempty"`
+	// Analysis veredictos de las entidades que fy-engine no analizó (tarjeta bajo la burbuja)
+	Analysis *ChatAnalysisSummary `json:"analysis,omitempty"`
+}
+
+// chatTurn turno de chat validado: conversación, contexto y allowlist listos para fy-engine
+type chatTurn struct {
+	userID    uuid.UUID
+	convID    uuid.UUID
+	messageID uuid.UUID // Mensaje del usuario ya guardado
+	message   string
+	context   []services.ContextMessage
+	allowlist []string
 }
 
 // Chat envía un mensaje a Fy
 func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
+	turn, ok := h.beginChatTurn(w, r)
+	if !ok {
+		return
+	}
+
+	// Enviar a Fy Engine
+	fyResp, err := h.fyEngine.Chat(r.Context(), turn.userID.String(), turn.message, turn.context, turn.allowlist)
+	if err != nil {
+		log.Error().Err(err).Msg("[Chat] Fy Engine error")
+		respondError(w, http.StatusServiceUnavailable, "fy_error", "Failed to process message")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, h.completeChatTurn(r.Context(), turn, fyResp))
+}
+
+// beginChatTurn valida el body, obtiene o crea la conversación, recupera el contexto y
+// guarda el mensaje del usuario. Si devuelve false ya ha respondido con el error.
+func (h *Handler) beginChatTurn(w http.ResponseWriter, r *http.Request) (*chatTurn, bool) {
 	userID, _ := middleware.GetUserID(r.Context())
 
 	var req ChatRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
-		return
+		return nil, false
 	}
 
 	if strings.TrimSpace(req.Message) == "" {
 		respondError(w, http.StatusBadRequest, "empty_message", "Message is required")
-		return
+		return nil, false
 	}
 
 	// Obtener o crear conversación
@@ -509,12 +717,12 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		convID, err = uuid.Parse(req.ConversationID)
 		if err != nil {
 			respondError(w, http.StatusBadRequest, "invalid_conversation", "Invalid conversation ID")
-			return
+			return nil, false
 		}
 		// Verificar propiedad
 		if _, err := h.postgres.GetConversation(r.Context(), convID, userID); err != nil {
 			respondError(w, http.StatusNotFound, "conversation_not_found", "Conversation not found")
-			return
+			return nil, false
 		}
 	} else {
 		// Crear nueva conversación con título basado en el primer mensaje
@@ -522,7 +730,7 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		conv, err := h.postgres.CreateConversation(r.Context(), userID, title)
 		if err != nil {
 			respondError(w, http.StatusInternalServerError, "db_error", "Failed to create conversation")
-			return
+			return nil, false
 		}
 		convID = conv.ID
 	}
@@ -537,6 +745,9 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 			})
 		}
 		log.Debug().Int("context_messages", len(context)).Msg("[Chat] Contexto recuperado de Redis")
+	} else if req.ConversationID != "" {
+		// Redis frío (TTL expirado o flush): los mensajes siguen en PostgreSQL
+		context = h.loadContextFromPostgres(r, userID, convID)
 	} else {
 		log.Debug().Msg("[Chat] Sin contexto previo en Redis")
 	}
@@ -551,18 +762,36 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 	}
 	_ = h.postgres.AddMessage(r.Context(), userMsg)
 
-	// Enviar a Fy Engine
-	fyResp, err := h.fyEngine.Chat(r.Context(), userID.String(), req.Message, context)
-	if err != nil {
-		log.Error().Err(err).Msg("[Chat] Fy Engine error")
-		respondError(w, http.StatusServiceUnavailable, "fy_error", "Failed to process message")
-		return
+	// Lista de confianza personal (si falla, se analiza sin ella)
+	var allowlist []string
+	if entries, err := h.postgres.GetUserAllowlist(r.Context(), userID); err != nil {
+		log.Warn().Err(err).Msg("[Chat] No se pudo obtener la allowlist del usuario")
+	} else {
+		for _, e := range entries {
+			allowlist = append(allowlist, e.IndicatorValue)
+		}
 	}
 
+	return &chatTurn{
+		userID:    userID,
+		convID:    convID,
+		messageID: userMsg.ID,
+		message:   req.Message,
+		context:   context,
+		allowlist: allowlist,
+	}, true
+}
+
+// completeChatTurn analiza las entidades pendientes, guarda la respuesta de Fy y las entidades
+// del mensaje, actualiza la memoria corta y las estadísticas, y construye la respuesta para el cliente
+func (h *Handler) completeChatTurn(ctx context.Context, turn *chatTurn, fyResp *services.FyChatResponse) ChatResponse {
+	// Entidades que fy-engine extrajo pero no analizó (intent de análisis)
+	analysis := h.analyzeChatEntities(ctx, fyResp)
+
 	// Guardar respuesta de Fy
 	fyMsg := &models.Message{
 		ID:                uuid.New(),
-		ConversationID:    convID,
+		ConversationID:    turn.convID,
 		Role:              "assistant",
 		Content:           fyResp.Response,
 		Intent:            fyResp.Intent,
@@ -570,10 +799,19 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		AnalysisPerformed: fyResp.AnalysisPerformed,
 		CreatedAt:         time.Now(),
 	}
-	_ = h.postgres.AddMessage(r.Context(), fyMsg)
+	if analysis != nil {
+		fyMsg.EntitiesFound = analysis.entitiesFound()
+		fyMsg.AnalysisPerformed = fyMsg.AnalysisPerformed || len(analysis.Verdicts) > 0
+	}
+	_ = h.postgres.AddMessage(ctx, fyMsg)
+
+	// URLs, emails y teléfonos del mensaje del usuario ("mis enlaces comprobados")
+	if err := h.postgres.RecordMessageEntities(ctx, turn.userID, turn.messageID, messageEntities(fyResp, analysis)); err != nil {
+		log.Warn().Err(err).Msg("[Chat] No se pudieron guardar las entidades del mensaje")
+	}
 
 	// Actualizar memoria corta de Fy
-	recentMessages := append(context, services.ContextMessage{Role: "user", Content: req.Message})
+	recentMessages := append(turn.context, services.ContextMessage{Role: "user", Content: turn.message})
 	recentMessages = append(recentMessages, services.ContextMessage{Role: "assistant", Content: fyResp.Response})
 	// Mantener solo los últimos 10 mensajes
 	if len(recentMessages) > 10 {
@@ -583,18 +821,41 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 	for _, m := range recentMessages {
 		fyMemory = append(fyMemory, db.FyMemoryMessage{Role: m.Role, Content: m.Content})
 	}
-	_ = h.redis.StoreFyMemory(r.Context(), userID, convID, fyMemory, fyResp.Intent, fyResp.Mood)
+	_ = h.redis.StoreFyMemory(ctx, turn.userID, turn.convID, fyMemory, fyResp.Intent, fyResp.Mood)
 
 	// Actualizar estadísticas
 	isThreat := fyResp.Mood == "danger" || fyResp.Mood == "warning"
-	_ = h.postgres.UpdateUserStats(r.Context(), userID, fyResp.AnalysisPerformed, isThreat)
+	if analysis != nil && riskLevelRank(analysis.RiskLevel) > 0 {
+		isThreat = true
+	}
+	_ = h.postgres.UpdateUserStats(ctx, turn.userID, fyMsg.AnalysisPerformed, isThreat)
+	if fyResp.AnalysisPerformed && fyResp.Trace != nil && fyResp.Trace.EntityType != "" {
+		trace := fyResp.Trace
+		checkers := []string{}
+		if trace.FoundInDB && trace.Source != "" {
+			checkers = append(checkers, trace.Source)
+		}
+		if err := h.postgres.RecordAnalysisResult(ctx, turn.userID, trace.EntityType, trace.EntityValue,
+			entityDomain(trace.EntityType, trace.EntityValue), trace.RiskScore, trace.Verdict, checkers); err != nil {
+			log.Warn().Err(err).Msg("[Chat] No se pudo registrar el análisis")
+		}
+	}
+	if analysis != nil {
+		for _, v := range analysis.Verdicts {
+			if err := h.postgres.RecordAnalysisResult(ctx, turn.userID, v.Type, v.Value,
+				entityDomain(v.Type, v.Value), v.RiskScore, v.Verdict, v.Checkers); err != nil {
+				log.Warn().Err(err).Msg("[Chat] No se pudo registrar el análisis")
+			}
+		}
+	}
 
 	// Construir respuesta con trace si existe
 	resp := ChatResponse{
-		ConversationID: convID.String(),
+		ConversationID: turn.convID.String(),
 		Response:       fyResp.Response,
 		Mood:           fyResp.Mood,
 		Intent:         fyResp.Intent,
+		Analysis:       analysis,
 	}
 
 	if fyResp.Trace != nil {
@@ -610,25 +871,113 @@ func (h *Handler) Chat(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	respondJSON(w, http.StatusOK, resp)
+	return resp
+}
+
+// ==================== ALLOWLIST ====================
+
+type AllowlistRequest struct {
+	IndicatorType  string `json:"indicator_type"` // url, email, phone
+	IndicatorValue string `json:"indicator_value"`
+}
+
+// GetAllowlist lista la lista de confianza personal del usuario
+func (h *Handler) GetAllowlist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	entries, err := h.postgres.GetUserAllowlist(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get allowlist")
+		return
+	}
+	if entries == nil {
+		entries = []models.AllowlistEntry{}
+	}
+
+	respondJSON(w, http.StatusOK, entries)
+}
+
+// AddAllowlistEntry añade un dominio, email o teléfono a la lista de confianza
+func (h *Handler) AddAllowlistEntry(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var req AllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	value := normalizeAllowlistValue(req.IndicatorType, req.IndicatorValue)
+	if value == "" {
+		respondError(w, http.StatusBadRequest, "invalid_indicator", "indicator_type must be url, email or phone with a valid value")
+		return
+	}
+
+	entry, err := h.postgres.AddAllowlistEntry(r.Context(), userID, req.IndicatorType, value)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to add allowlist entry")
+		return
+	}
+
+	respondJSON(w, http.StatusCreated, entry)
+}
+
+// DeleteAllowlistEntry elimina una entrada de la lista de confianza
+func (h *Handler) DeleteAllowlistEntry(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	var req AllowlistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+
+	value := normalizeAllowlistValue(req.IndicatorType, req.IndicatorValue)
+	if value == "" {
+		respondError(w, http.StatusBadRequest, "invalid_indicator", "indicator_type must be url, email or phone with a valid value")
+		return
+	}
+
+	deleted, err := h.postgres.DeleteAllowlistEntry(r.Context(), userID, req.IndicatorType, value)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to delete allowlist entry")
+		return
+	}
+	if !deleted {
+		respondError(w, http.StatusNotFound, "not_found", "Allowlist entry not found")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]string{"message": "Allowlist entry deleted"})
 }
 
 // ==================== HEALTH ====================
 
 func (h *Handler) Health(w http.ResponseWriter, r *http.Request) {
-	// Verificar dependencias
+	// Verificar dependencias (con el circuito abierto el health check falla sin llamar)
 	fyHealth := h.fyEngine.Health(r.Context())
+	resp := HealthResponse{
+		Status:   "ok",
+		Service:  "api-gateway",
+		FyEngine: fyHealth,
+		Dependencies: map[string]DependencyHealth{
+			"fy_engine": {Healthy: fyHealth, Circuit: h.fyEngine.Circuit()},
+		},
+	}
+	if h.fyAnalysis != nil {
+		resp.Dependencies["fy_analysis"] = DependencyHealth{
+			Healthy: h.fyAnalysis.Health(r.Context()),
+			Circuit: h.fyAnalysis.Circuit(),
+		}
+	}
 
-	status := "ok"
-	if !fyHealth {
-		status = "degraded"
+	for _, dep := range resp.Dependencies {
+		if !dep.Healthy {
+			resp.Status = "degraded"
+		}
 	}
 
-	respondJSON(w, http.StatusOK, map[string]interface{}{
-		"status":    status,
-		"service":   "api-gateway",
-		"fy_engine": fyHealth,
-	})
+	respondJSON(w, http.StatusOK, resp)
 }
 
 // ==================== HELPERS ====================
@@ -674,6 +1023,66 @@ func normalizePhone(phone string) string {
 	return ""
 }
 
+// normalizeAllowlistValue normaliza una entrada de la allowlist igual que fy-analysis compara:
+// dominio en minúsculas para URLs, email en minúsculas, teléfono en formato +CC
+func normalizeAllowlistValue(indicatorType, value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" {
+		return ""
+	}
+
+	switch indicatorType {
+	case "url":
+		if strings.Contains(value, "://") {
+			parsed, err := url.Parse(value)
+			if err != nil {
+				return ""
+			}
+			value = parsed.Hostname()
+		}
+		value = strings.TrimPrefix(strings.SplitN(value, "/", 2)[0], "www.")
+		if !strings.Contains(value, ".") {
+			return ""
+		}
+		return value
+	case "email":
+		if strings.Count(value, "@") != 1 {
+			return ""
+		}
+		return value
+	case "phone":
+		cleaned := phoneRegex.ReplaceAllString(value, "")
+		if strings.HasPrefix(cleaned, "00") {
+			cleaned = "+" + cleaned[2:]
+		}
+		if !strings.HasPrefix(cleaned, "+") {
+			if len(cleaned) == 9 {
+				cleaned = "+34" + cleaned
+			} else {
+				cleaned = "+" + cleaned
+			}
+		}
+		if len(cleaned) < 8 {
+			return ""
+		}
+		return cleaned
+	}
+	return ""
+}
+
+// entityDomain dominio de una URL o email analizado ("" para teléfonos)
+func entityDomain(entityType, value string) string {
+	switch entityType {
+	case "email":
+		if at := strings.LastIndex(value, "@"); at >= 0 {
+			return strings.ToLower(value[at+1:])
+		}
+	case "url":
+		return normalizeAllowlistValue("url", value)
+	}
+	return ""
+}
+
 func maskPhone(phone string) string {
 	if len(phone) > 6 {
 		return phone[:4] + "***" + phone[len(phone)-3:]
@@ -808,3 +1217,108 @@ func (h *Handler) ReportURL(w http.ResponseWriter, r *http.Request) {
 		URLScore: result.URLScore,
 	})
 }
+
+// WhitelistRequestBody propuesta de dominio legítimo desde la app
+type WhitelistRequestBody struct {
+	Domain      string `json:"domain"`
+	Brand       string `json:"brand"`
+	Reason      string `json:"reason"`
+	EvidenceURL string `json:"evidence_url"`
+}
+
+// RequestWhitelist permite a un usuario proponer un dominio legítimo para la whitelist.
+// La revisa un admin en fy-admin y el usuario recibe la decisión en sus notificaciones.
+// POST /api/v1/whitelist/request
+func (h *Handler) RequestWhitelist(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	if h.fyAnalysis == nil {
+		respondError(w, http.StatusServiceUnavailable, "service_unavailable", "Servicio de reportes no disponible")
+		return
+	}
+
+	var req WhitelistRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondError(w, http.StatusBadRequest, "invalid_body", "Invalid request body")
+		return
+	}
+	if strings.TrimSpace(req.Domain) == "" {
+		respondError(w, http.StatusBadRequest, "missing_domain", "Domain is required")
+		return
+	}
+	if strings.TrimSpace(req.Reason) == "" {
+		respondError(w, http.StatusBadRequest, "missing_reason", "Reason is required")
+		return
+	}
+
+	result, err := h.fyAnalysis.SubmitWhitelistRequest(r.Context(), &services.WhitelistRequest{
+		UserID:      userID.String(),
+		Domain:      req.Domain,
+		Brand:       req.Brand,
+		Reason:      req.Reason,
+		EvidenceURL: req.EvidenceURL,
+	})
+	if err != nil {
+		log.Error().Err(err).Msg("[Whitelist] Failed to submit whitelist request")
+		respondError(w, http.StatusServiceUnavailable, "analysis_error", "Failed to process whitelist request")
+		return
+	}
+
+	log.Info().
+		Str("user_id", userID.String()).
+		Str("domain", result.Domain).
+		Bool("success", result.Success).
+		Str("status", result.Status).
+		Msg("[Whitelist] Whitelist request submitted")
+
+	status := http.StatusOK
+	if result.Success && result.Status == "pending" && result.RequestID != "" {
+		status = http.StatusCreated
+	}
+	respondJSON(w, status, result)
+}
+
+// GetMyNotifications lista las notificaciones in-app del usuario (p. ej. reportes confirmados).
+// GET /api/v1/me/notifications?limit=20
+func (h *Handler) GetMyNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	if h.fyAnalysis == nil {
+		respondError(w, http.StatusServiceUnavailable, "service_unavailable", "Servicio de reportes no disponible")
+		return
+	}
+
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	notifications, err := h.fyAnalysis.GetUserNotifications(r.Context(), userID.String(), limit)
+	if err != nil {
+		log.Error().Err(err).Msg("[Notifications] Failed to get notifications")
+		respondError(w, http.StatusServiceUnavailable, "analysis_error", "Failed to get notifications")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, notifications)
+}
+
+// MarkMyNotificationsRead marca como leídas las notificaciones del usuario.
+// POST /api/v1/me/notifications/read
+func (h *Handler) MarkMyNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	if h.fyAnalysis == nil {
+		respondError(w, http.StatusServiceUnavailable, "service_unavailable", "Servicio de reportes no disponible")
+		return
+	}
+
+	marked, err := h.fyAnalysis.MarkNotificationsRead(r.Context(), userID.String())
+	if err != nil {
+		log.Error().Err(err).Msg("[Notifications] Failed to mark notifications as read")
+		respondError(w, http.StatusServiceUnavailable, "analysis_error", "Failed to update notifications")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
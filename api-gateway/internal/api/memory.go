@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/db"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+// MemoryFallback límites del contexto que se reconstruye desde PostgreSQL
+// para no desbordar el prompt de fy-engine en conversaciones largas
+type MemoryFallback struct {
+	MaxMessages int // 0 = 10
+	MaxChars    int // 0 = 4000
+}
+
+func (f MemoryFallback) limits() (int, int) {
+	maxMessages, maxChars := f.MaxMessages, f.MaxChars
+	if maxMessages <= 0 {
+		maxMessages = 10
+	}
+	if maxChars <= 0 {
+		maxChars = 4000
+	}
+	return maxMessages, maxChars
+}
+
+// conversationHistory mensajes guardados en PostgreSQL (db.PostgresDB)
+type conversationHistory interface {
+	GetConversationMessagesPage(ctx context.Context, conversationID uuid.UUID, cursor *models.MessageCursor, before bool, limit int) ([]models.Message, error)
+}
+
+// fyMemoryStore memoria corta de Fy en Redis (db.RedisDB)
+type fyMemoryStore interface {
+	StoreFyMemory(ctx context.Context, userID, conversationID uuid.UUID, messages []db.FyMemoryMessage, intent, mood string) error
+}
+
+// loadContextFromPostgres reconstruye la memoria de Fy con los últimos mensajes de la conversación
+// y la vuelve a guardar en Redis para que los siguientes turnos no repitan la consulta
+func (h *Handler) loadContextFromPostgres(r *http.Request, userID, convID uuid.UUID) []services.ContextMessage {
+	return rebuildFyMemory(r.Context(), h.postgres, h.redis, h.memoryFallback, userID, convID)
+}
+
+func rebuildFyMemory(ctx context.Context, history conversationHistory, store fyMemoryStore, fallback MemoryFallback, userID, convID uuid.UUID) []services.ContextMessage {
+	maxMessages, maxChars := fallback.limits()
+
+	// Más recientes primero
+	messages, err := history.GetConversationMessagesPage(ctx, convID, nil, true, maxMessages)
+	if err != nil {
+		log.Warn().Err(err).Str("conversation_id", convID.String()).Msg("[Chat] No se pudo recuperar el contexto de PostgreSQL")
+		return nil
+	}
+
+	context := contextFromMessages(messages, maxMessages, maxChars)
+	if len(context) == 0 {
+		return nil
+	}
+
+	// Intent y mood del último mensaje de Fy, como los guarda un turno normal
+	var intent, mood string
+	for _, m := range messages {
+		if m.Role == "assistant" {
+			intent, mood = m.Intent, m.Mood
+			break
+		}
+	}
+
+	memory := make([]db.FyMemoryMessage, 0, len(context))
+	for _, m := range context {
+		memory = append(memory, db.FyMemoryMessage{Role: m.Role, Content: m.Content})
+	}
+	if err := store.StoreFyMemory(ctx, userID, convID, memory, intent, mood); err != nil {
+		log.Warn().Err(err).Msg("[Chat] No se pudo repoblar la memoria de Fy en Redis")
+	}
+
+	log.Debug().
+		Int("context_messages", len(context)).
+		Str("conversation_id", convID.String()).
+		Msg("[Chat] Contexto recuperado de PostgreSQL")
+	return context
+}
+
+// contextFromMessages convierte mensajes (más recientes primero) en contexto cronológico,
+// quedándose con los más recientes que caben en maxMessages y maxChars
+func contextFromMessages(newestFirst []models.Message, maxMessages, maxChars int) []services.ContextMessage {
+	var selected []services.ContextMessage
+	chars := 0
+	for _, m := range newestFirst {
+		if len(selected) == maxMessages {
+			break
+		}
+		if m.Content == "" {
+			continue
+		}
+		chars += len([]rune(m.Content))
+		if chars > maxChars {
+			break
+		}
+		selected = append(selected, services.ContextMessage{Role: m.Role, Content: m.Content})
+	}
+
+	// Orden cronológico, como la memoria de Redis
+	for i, j := 0, len(selected)-1; i < j; i, j = i+1, j-1 {
+		selected[i], selected[j] = selected[j], selected[i]
+	}
+	return selected
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/db"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/services"
+)
+
+// historyStub devuelve los mensajes tal cual (más recientes primero), hasta limit
+type historyStub struct {
+	messages []models.Message
+	err      error
+	limit    int
+}
+
+func (s *historyStub) GetConversationMessagesPage(_ context.Context, _ uuid.UUID, cursor *models.MessageCursor, before bool, limit int) ([]models.Message, error) {
+	if cursor != nil || !before {
+		return nil, errors.New("unexpected page request")
+	}
+	s.limit = limit
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.messages[:min(limit, len(s.messages))], nil
+}
+
+type memoryStoreStub struct {
+	calls    int
+	messages []db.FyMemoryMessage
+	intent   string
+	mood     string
+	err      error
+}
+
+func (s *memoryStoreStub) StoreFyMemory(_ context.Context, _, _ uuid.UUID, messages []db.FyMemoryMessage, intent, mood string) error {
+	s.calls++
+	s.messages, s.intent, s.mood = messages, intent, mood
+	return s.err
+}
+
+func assertContext(t *testing.T, got []services.ContextMessage, want ...string) {
+	t.Helper()
+	contents := make([]string, len(got))
+	for i, m := range got {
+		contents[i] = m.Role + ":" + m.Content
+	}
+	if strings.Join(contents, "|") != strings.Join(want, "|") {
+		t.Errorf("context = %v, want %v", contents, want)
+	}
+}
+
+func TestRebuildFyMemoryColdRedis(t *testing.T) {
+	history := &historyStub{messages: []models.Message{
+		{Role: "assistant", Content: "Es una estafa", Intent: "analysis", Mood: "alert"},
+		{Role: "user", Content: "¿Y este SMS?"},
+		{Role: "assistant", Content: "Hola, soy Fy", Intent: "greeting", Mood: "happy"},
+		{Role: "user", Content: "Hola"},
+	}}
+	store := &memoryStoreStub{}
+
+	got := rebuildFyMemory(context.Background(), history, store, MemoryFallback{}, uuid.New(), uuid.New())
+
+	// Orden cronológico, como la memoria de Redis
+	assertContext(t, got, "user:Hola", "assistant:Hola, soy Fy", "user:¿Y este SMS?", "assistant:Es una estafa")
+	if history.limit != 10 {
+		t.Errorf("history limit = %d, want the default 10", history.limit)
+	}
+
+	// Redis se repuebla con el mismo contexto y el intent/mood del último mensaje de Fy
+	if store.calls != 1 || len(store.messages) != len(got) {
+		t.Fatalf("store calls = %d with %d messages, want 1 with %d", store.calls, len(store.messages), len(got))
+	}
+	for i, m := range store.messages {
+		if m.Role != got[i].Role || m.Content != got[i].Content {
+			t.Errorf("stored[%d] = %+v, want %+v", i, m, got[i])
+		}
+	}
+	if store.intent != "analysis" || store.mood != "alert" {
+		t.Errorf("stored intent/mood = %q/%q, want analysis/alert", store.intent, store.mood)
+	}
+}
+
+func TestRebuildFyMemoryAssistantOnly(t *testing.T) {
+	// Conversación con solo mensajes de Fy (p. ej. alertas push abiertas desde el chat)
+	history := &historyStub{messages: []models.Message{
+		{Role: "assistant", Content: "Tercera alerta", Intent: "alert", Mood: "worried"},
+		{Role: "assistant", Content: "Segunda alerta", Intent: "alert", Mood: "neutral"},
+		{Role: "assistant", Content: "Primera alerta", Intent: "greeting", Mood: "happy"},
+	}}
+	store := &memoryStoreStub{}
+
+	got := rebuildFyMemory(context.Background(), history, store, MemoryFallback{}, uuid.New(), uuid.New())
+
+	assertContext(t, got, "assistant:Primera alerta", "assistant:Segunda alerta", "assistant:Tercera alerta")
+	if store.calls != 1 || len(store.messages) != 3 {
+		t.Fatalf("store calls = %d with %d messages, want 1 with 3", store.calls, len(store.messages))
+	}
+	if store.intent != "alert" || store.mood != "worried" {
+		t.Errorf("stored intent/mood = %q/%q, want alert/worried", store.intent, store.mood)
+	}
+}
+
+func TestRebuildFyMemoryLimits(t *testing.T) {
+	messages := []models.Message{
+		{Role: "user", Content: "mensaje 6"},
+		{Role: "assistant", Content: "mensaje 5"},
+		{Role: "user", Content: ""}, // Sin texto: no cuenta
+		{Role: "user", Content: "mensaje 4"},
+		{Role: "assistant", Content: "mensaje 3"},
+		{Role: "user", Content: "mensaje 2"},
+	}
+
+	t.Run("message count", func(t *testing.T) {
+		history := &historyStub{messages: messages}
+		got := rebuildFyMemory(context.Background(), history, &memoryStoreStub{}, MemoryFallback{MaxMessages: 2}, uuid.New(), uuid.New())
+		if history.limit != 2 {
+			t.Errorf("history limit = %d, want 2", history.limit)
+		}
+		assertContext(t, got, "assistant:mensaje 5", "user:mensaje 6")
+	})
+
+	t.Run("message count after empty messages", func(t *testing.T) {
+		// La consulta trae limit filas aunque alguna no tenga texto: nunca más de MaxMessages
+		got := contextFromMessages(messages, 3, 4000)
+		assertContext(t, got, "user:mensaje 4", "assistant:mensaje 5", "user:mensaje 6")
+	})
+
+	t.Run("char budget", func(t *testing.T) {
+		// 9 caracteres por mensaje: caben dos en 20 y el tercero se descarta entero
+		got := rebuildFyMemory(context.Background(), &historyStub{messages: messages}, &memoryStoreStub{}, MemoryFallback{MaxChars: 20}, uuid.New(), uuid.New())
+		assertContext(t, got, "assistant:mensaje 5", "user:mensaje 6")
+	})
+
+	t.Run("char budget counts runes", func(t *testing.T) {
+		long := []models.Message{{Role: "user", Content: strings.Repeat("ñ", 5)}}
+		got := contextFromMessages(long, 10, 5)
+		assertContext(t, got, "user:ñññññ")
+	})
+
+	t.Run("newest message over budget", func(t *testing.T) {
+		store := &memoryStoreStub{}
+		long := []models.Message{{Role: "user", Content: strings.Repeat("a", 50)}, {Role: "assistant", Content: "corto"}}
+		got := rebuildFyMemory(context.Background(), &historyStub{messages: long}, store, MemoryFallback{MaxChars: 20}, uuid.New(), uuid.New())
+		if got != nil || store.calls != 0 {
+			t.Errorf("context = %v, store calls = %d; want no context and no store", got, store.calls)
+		}
+	})
+}
+
+func TestRebuildFyMemoryFailures(t *testing.T) {
+	t.Run("history error", func(t *testing.T) {
+		store := &memoryStoreStub{}
+		got := rebuildFyMemory(context.Background(), &historyStub{err: errors.New("connection refused")}, store, MemoryFallback{}, uuid.New(), uuid.New())
+		if got != nil || store.calls != 0 {
+			t.Errorf("context = %v, store calls = %d; want no context and no store", got, store.calls)
+		}
+	})
+
+	t.Run("empty conversation", func(t *testing.T) {
+		store := &memoryStoreStub{}
+		got := rebuildFyMemory(context.Background(), &historyStub{}, store, MemoryFallback{}, uuid.New(), uuid.New())
+		if got != nil || store.calls != 0 {
+			t.Errorf("context = %v, store calls = %d; want no context and no store", got, store.calls)
+		}
+	})
+
+	t.Run("store error keeps context", func(t *testing.T) {
+		// Si Redis sigue caído el turno usa igualmente el contexto de PostgreSQL
+		store := &memoryStoreStub{err: errors.New("redis down")}
+		history := &historyStub{messages: []models.Message{{Role: "user", Content: "Hola"}}}
+		got := rebuildFyMemory(context.Background(), history, store, MemoryFallback{}, uuid.New(), uuid.New())
+		assertContext(t, got, "user:Hola")
+		if store.calls != 1 {
+			t.Errorf("store calls = %d, want 1", store.calls)
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+func TestNewMessagePage(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	messages := make([]models.Message, 4)
+	for i := range messages {
+		messages[i] = models.Message{ID: uuid.New(), CreatedAt: at} // Mismo instante
+	}
+
+	// limit+1 filas: hay otra página y su cursor es el último mensaje devuelto, no la fila de más
+	page := newMessagePage(messages, 3, 40)
+	if len(page.Messages) != 3 || page.NextCursor != messages[2].ID.String() || page.MessageCount != 40 {
+		t.Errorf("page = %d messages, cursor %s, count %d; want 3, %s, 40",
+			len(page.Messages), page.NextCursor, page.MessageCount, messages[2].ID)
+	}
+
+	// Última página: sin cursor
+	page = newMessagePage(messages[:3], 3, 40)
+	if len(page.Messages) != 3 || page.NextCursor != "" {
+		t.Errorf("last page = %d messages, cursor %q; want 3 and no cursor", len(page.Messages), page.NextCursor)
+	}
+
+	// Sin mensajes: array vacío en el JSON, no null
+	page = newMessagePage(nil, 3, 0)
+	if page.Messages == nil || page.NextCursor != "" {
+		t.Errorf("empty page = %#v", page)
+	}
+}
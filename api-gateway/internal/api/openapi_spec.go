@@ -0,0 +1,334 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+	"time"
+
+	"github.com/trackfy/api-gateway/internal/export"
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/push"
+	"github.com/trackfy/api-gateway/internal/services"
+	"github.com/trackfy/pkg/openapi"
+)
+
+// openapi.json se regenera con go generate ./internal/api tras cambiar rutas o tipos;
+// CI falla si el fichero commiteado no coincide con OpenAPIDocument.
+//
+//go:generate go run ../../cmd/openapi-gen -o openapi.json
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Cuerpos que los handlers construyen como map: solo existen para documentarlos
+
+// ErrorResponse cuerpo de respondError
+type ErrorResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// MessageResponse confirmación simple
+type MessageResponse struct {
+	Message string `json:"message"`
+}
+
+// HealthResponse estado del servicio y de fy-engine
+type HealthResponse struct {
+	Status       string                      `json:"status"` // ok, degraded
+	Service      string                      `json:"service"`
+	FyEngine     bool                        `json:"fy_engine"`
+	Dependencies map[string]DependencyHealth `json:"dependencies"` // fy_engine, fy_analysis
+}
+
+// DependencyHealth estado de un servicio interno y de su circuito
+type DependencyHealth struct {
+	Healthy bool                  `json:"healthy"`
+	Circuit services.CircuitState `json:"circuit"`
+}
+
+// MarkedResponse notificaciones marcadas como leídas
+type MarkedResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+// AvailableReportsResponse meses con informe según el plan
+type AvailableReportsResponse struct {
+	Months        []models.ReportMonth `json:"months"`
+	Premium       bool                 `json:"premium"`
+	HistoryMonths int                  `json:"history_months"`
+}
+
+// TrendingDomainsResponse respuesta de GET /api/v1/threats/trending
+type TrendingDomainsResponse struct {
+	Domains     []models.TrendingDomain `json:"domains"`
+	GeneratedAt time.Time               `json:"generated_at"`
+}
+
+// ReclassificationResult respuesta del webhook de reclasificación
+type ReclassificationResult struct {
+	Dispatched bool   `json:"dispatched"`
+	Reason     string `json:"reason,omitempty"` // push_disabled, verdict_not_dangerous
+}
+
+const (
+	tagSystem        = "system"
+	tagAuth          = "auth"
+	tagMe            = "me"
+	tagConversations = "conversations"
+	tagChat          = "chat"
+	tagAllowlist     = "allowlist"
+	tagReports       = "reports"
+	tagAnalytics     = "analytics"
+	tagInternal      = "internal"
+)
+
+// OpenAPIDocument documento OpenAPI de todas las rutas de NewRouter. cmd/openapi-gen
+// comprueba que no falte ninguna.
+func OpenAPIDocument() *openapi.Document {
+	doc := openapi.New("Trackfy API Gateway", "1.0.0",
+		"API pública de Trackfy para la app móvil: autenticación por SMS, chat con Fy, conversaciones, informes y cuenta del usuario.")
+
+	doc.AddSecurityScheme("bearerAuth", &openapi.SecurityScheme{
+		Type:         "http",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+		Description:  "Access token de POST /auth/verify",
+	}, true)
+	doc.AddSecurityScheme("adminKey", &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: "X-Admin-Key",
+		Description: "ADMIN_API_KEY (solo /api/v1/analytics)",
+	}, false)
+	doc.AddSecurityScheme("signature", &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: "X-Trackfy-Signature",
+		Description: "HMAC-SHA256 con INTERNAL_SIGNING_SECRET; requiere también X-Trackfy-Timestamp y X-Trackfy-Nonce",
+	}, false)
+
+	doc.AddTag(tagSystem, "Salud, métricas y documentación")
+	doc.AddTag(tagAuth, "Registro y login por código SMS")
+	doc.AddTag(tagMe, "Usuario, suscripción, sesiones, dispositivos, notificaciones y borrado de cuenta")
+	doc.AddTag(tagConversations, "Historial de conversaciones con Fy")
+	doc.AddTag(tagChat, "Chat con Fy")
+	doc.AddTag(tagAllowlist, "Lista de confianza personal")
+	doc.AddTag(tagReports, "Reportes de URLs, propuestas de whitelist, informes mensuales y tendencias")
+	doc.AddTag(tagAnalytics, "Agregados para administración")
+	doc.AddTag(tagInternal, "Webhooks de otros servicios")
+
+	e := ErrorResponse{}
+
+	// Sistema
+	doc.Op(http.MethodGet, "/health", tagSystem, "Estado del servicio").Public().
+		JSON(http.StatusOK, HealthResponse{})
+	doc.Op(http.MethodGet, "/metrics", tagSystem, "Métricas Prometheus").Public().
+		Content(http.StatusOK, "text/plain", "Métricas en formato de exposición de Prometheus")
+	doc.Op(http.MethodGet, "/api/openapi.json", tagSystem, "Este documento OpenAPI").Public().
+		Content(http.StatusOK, "application/json", "Documento OpenAPI 3.0")
+	doc.Op(http.MethodGet, "/docs", tagSystem, "Swagger UI").Public().
+		Content(http.StatusOK, "text/html", "Página de Swagger UI")
+
+	// Webhooks internos
+	doc.Op(http.MethodPost, "/internal/webhooks/reclassification", tagInternal, "Input reclasificado como peligroso").
+		Describe("fy-analysis avisa de una reclasificación; se envía push a quienes analizaron el input.").
+		Security("signature").
+		Body(push.Reclassification{}).
+		JSON(http.StatusOK, ReclassificationResult{}).
+		JSON(http.StatusAccepted, ReclassificationResult{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable)
+
+	// Analytics (admin)
+	doc.Op(http.MethodGet, "/api/v1/analytics/trending", tagAnalytics, "Entidades más analizadas en 24h y 7 días").
+		Security("adminKey").
+		JSON(http.StatusOK, models.TrendingThreats{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/analytics/risk-distribution", tagAnalytics, "Histograma de risk scores del último día").
+		Security("adminKey").
+		JSON(http.StatusOK, models.RiskDistribution{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/analytics/checker-coverage", tagAnalytics, "Aportación de cada checker en 7 días").
+		Security("adminKey").
+		JSON(http.StatusOK, models.CheckerCoverage{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError, http.StatusServiceUnavailable)
+
+	// Auth
+	doc.Op(http.MethodPost, "/auth/register", tagAuth, "Registrar usuario").Public().
+		Body(RegisterRequest{}).
+		JSON(http.StatusCreated, RegisterResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/auth/send-code", tagAuth, "Enviar código SMS de login").Public().
+		Body(SendCodeRequest{}).
+		JSON(http.StatusOK, SendCodeResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/auth/verify", tagAuth, "Verificar código y obtener tokens").Public().
+		Body(VerifyCodeRequest{}).
+		JSON(http.StatusOK, VerifyCodeResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError)
+
+	// Borrado de cuenta
+	doc.Op(http.MethodGet, "/api/v1/account-deletions/{id}", tagMe, "Estado de un borrado de cuenta").Public().
+		JSON(http.StatusOK, AccountDeletionStatus{}).
+		Errors(e, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError)
+	deleteAccount := func(path, summary string) {
+		doc.Op(http.MethodDelete, path, tagMe, summary).
+			Describe("Requiere un código de POST /api/v1/me/deletion-code. El borrado se encola; su estado se consulta en status_url.").
+			Body(DeleteAccountRequest{}).
+			JSON(http.StatusAccepted, AccountDeletionStatus{}).
+			Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	}
+	deleteAccount("/api/v1/me", "Borrar la cuenta (RGPD)")
+	deleteAccount("/api/v1/account", "Borrar la cuenta (alias de DELETE /api/v1/me)")
+	doc.Op(http.MethodPost, "/api/v1/me/deletion-code", tagMe, "Enviar código SMS para confirmar el borrado").
+		JSON(http.StatusOK, SendCodeResponse{}).
+		Errors(e, http.StatusNotFound, http.StatusInternalServerError)
+
+	// Usuario
+	doc.Op(http.MethodGet, "/api/v1/me", tagMe, "Usuario actual").
+		JSON(http.StatusOK, models.UserPublic{}).
+		Errors(e, http.StatusUnauthorized, http.StatusNotFound)
+	doc.Op(http.MethodGet, "/api/v1/me/sessions", tagMe, "Sesiones activas").
+		JSON(http.StatusOK, []models.Session{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodDelete, "/api/v1/me/sessions/{id}", tagMe, "Revocar una sesión").
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/me/stats", tagMe, "Estadísticas del usuario").
+		JSON(http.StatusOK, models.UserStats{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/api/v1/me/logout", tagMe, "Cerrar la sesión actual").
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/me/logout-all", tagMe, "Cerrar todas las sesiones").
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/me/devices", tagMe, "Registrar token push del dispositivo").
+		Body(DeviceRequest{}).
+		JSON(http.StatusCreated, models.Device{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodDelete, "/api/v1/me/devices", tagMe, "Dar de baja un token push").
+		Body(DeviceRequest{}).
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/me/notifications", tagMe, "Notificaciones in-app").
+		Query("limit", "integer", "Máximo de notificaciones (1-100, por defecto 20)").
+		JSON(http.StatusOK, []services.UserNotification{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/me/notifications/read", tagMe, "Marcar notificaciones como leídas").
+		JSON(http.StatusOK, MarkedResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/me/entities", tagMe, "Entidades consultadas en el chat").
+		Describe("URLs, emails y teléfonos que el usuario ha escrito en el chat, uno por valor normalizado, "+
+			"con el último veredicto conocido (sin verdict si nunca se analizó). Más recientes primero.").
+		Query("type", "string", "url, email o phone (por defecto todas)").
+		Query("limit", "integer", "Máximo de entidades (1-100, por defecto 50)").
+		Query("offset", "integer", "Desplazamiento").
+		JSON(http.StatusOK, models.UserEntityPage{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/me/export", tagMe, "Exportar todos los datos").
+		Describe("Perfil, estadísticas y todas las conversaciones (también archivadas) con sus mensajes, en un único JSON "+
+			"generado en streaming. Comparte con la exportación de conversaciones un límite de 5 al día por usuario.").
+		Query("tz", "string", "Zona horaria IANA de las fechas (por defecto Europe/Madrid)").
+		JSON(http.StatusOK, export.UserArchive{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusInternalServerError)
+
+	// Conversaciones
+	doc.Op(http.MethodGet, "/api/v1/conversations", tagConversations, "Listar conversaciones").
+		Query("limit", "integer", "Máximo de conversaciones (1-50, por defecto 20)").
+		Query("offset", "integer", "Desplazamiento").
+		Query("include_archived", "boolean", "true para incluir las archivadas (is_archived)").
+		JSON(http.StatusOK, []models.Conversation{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/api/v1/conversations", tagConversations, "Crear conversación").
+		Body(CreateConversationRequest{}).
+		JSON(http.StatusCreated, models.Conversation{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/conversations/{id}", tagConversations, "Obtener conversación").
+		JSON(http.StatusOK, models.Conversation{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound)
+	doc.Op(http.MethodGet, "/api/v1/conversations/{id}/messages", tagConversations, "Mensajes de una conversación").
+		Describe("Sin before/after devuelve un array en orden cronológico paginado por offset. "+
+			"Con before (latest o cursor) o after (cursor) devuelve un MessagePage; el cursor es el ID de un mensaje o un timestamp RFC 3339.").
+		Query("limit", "integer", "Máximo de mensajes (1-100, por defecto 50)").
+		Query("offset", "integer", "Desplazamiento (paginación por offset)").
+		Query("before", "string", "latest o cursor: mensajes anteriores, más recientes primero").
+		Query("after", "string", "Cursor: mensajes posteriores, orden cronológico").
+		JSON(http.StatusOK, models.MessagePage{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/api/v1/conversations/{id}/archive", tagConversations, "Archivar conversación").
+		Describe("Las conversaciones archivadas solo aparecen en el listado con include_archived=true y no admiten mensajes hasta desarchivarlas. "+
+			"El servidor archiva además cada semana las de más de 90 días sin actividad y menos de 5 mensajes.").
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/api/v1/conversations/{id}/unarchive", tagConversations, "Desarchivar conversación").
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/conversations/{id}/export", tagConversations, "Exportar conversación").
+		Describe("Conversación completa (también archivada) como adjunto. En texto incluye el veredicto de cada enlace, "+
+			"email o teléfono analizado, con las fechas en el idioma del usuario. Límite de 5 exportaciones al día por usuario.").
+		Query("format", "string", "json (por defecto) o text").
+		Query("tz", "string", "Zona horaria IANA de las fechas (por defecto Europe/Madrid)").
+		JSON(http.StatusOK, export.ConversationExport{}).
+		Content(http.StatusOK, "text/plain", "Conversación en texto legible (format=text)").
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests, http.StatusInternalServerError)
+
+	// Allowlist
+	doc.Op(http.MethodGet, "/api/v1/allowlist", tagAllowlist, "Listar la lista de confianza").
+		JSON(http.StatusOK, []models.AllowlistEntry{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodPost, "/api/v1/allowlist", tagAllowlist, "Añadir entrada").
+		Body(AllowlistRequest{}).
+		JSON(http.StatusCreated, models.AllowlistEntry{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodDelete, "/api/v1/allowlist", tagAllowlist, "Eliminar entrada").
+		Body(AllowlistRequest{}).
+		JSON(http.StatusOK, MessageResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+
+	// Chat
+	doc.Op(http.MethodPost, "/api/v1/chat", tagChat, "Enviar mensaje a Fy").
+		Body(ChatRequest{}).
+		JSON(http.StatusOK, ChatResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/chat/stream", tagChat, "Enviar mensaje a Fy con respuesta por SSE").
+		Describe("Eventos data: con JSON: ChatStreamToken (type=token) por fragmento, ChatStreamFinal (type=final) "+
+			"o ChatStreamError (type=error), y data: [DONE] al terminar.").
+		Body(ChatRequest{}).
+		Content(http.StatusOK, "text/event-stream", "Stream de eventos del chat").
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusNotFound, http.StatusInternalServerError)
+	// Los eventos del stream no aparecen en ninguna respuesta JSON: se registran a mano
+	doc.SchemaOf(ChatStreamToken{})
+	doc.SchemaOf(ChatStreamFinal{})
+	doc.SchemaOf(ChatStreamError{})
+
+	// Reportes y whitelist
+	doc.Op(http.MethodPost, "/api/v1/report", tagReports, "Reportar una URL sospechosa").
+		Body(ReportURLRequest{}).
+		JSON(http.StatusOK, ReportURLResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/whitelist/request", tagReports, "Proponer un dominio legítimo").
+		Body(WhitelistRequestBody{}).
+		JSON(http.StatusOK, services.WhitelistRequestResponse{}).
+		JSON(http.StatusCreated, services.WhitelistRequestResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/reports/monthly", tagReports, "Informe mensual de amenazas").
+		Describe("Meses anteriores al actual requieren plan premium (hasta 12 meses).").
+		Query("year", "integer", "Año (por defecto el actual)").
+		Query("month", "integer", "Mes 1-12 (por defecto el actual)").
+		Query("format", "string", "json (por defecto), html o pdf").
+		JSON(http.StatusOK, models.MonthlyReport{}).
+		Content(http.StatusOK, "text/html", "Informe en HTML (format=html)").
+		Content(http.StatusOK, "application/pdf", "Informe en PDF (format=pdf)").
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/reports/available", tagReports, "Meses con informe disponible").
+		JSON(http.StatusOK, AvailableReportsResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/threats/trending", tagReports, "Dominios peligrosos en tendencia (24h)").
+		JSON(http.StatusOK, TrendingDomainsResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+	doc.Op(http.MethodGet, "/api/v1/subscription/status", tagMe, "Estado de la suscripción").
+		Describe("premium indica el acceso actual: en prueba, activa (aunque esté cancelada para fin de periodo) "+
+			"y con un cobro fallido que Stripe sigue reintentando. Con cancel_at_period_end el acceso "+
+			"termina en current_period_end.").
+		JSON(http.StatusOK, SubscriptionStatusResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+
+	return doc
+}
@@ -0,0 +1,277 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// premiumHistoryMonths meses de historial de informes del plan premium (incluido el actual)
+const premiumHistoryMonths = 12
+
+// ==================== INFORMES MENSUALES ====================
+
+// GetMonthlyReport genera el informe mensual de amenazas del usuario.
+// GET /api/v1/reports/monthly?year=2024&month=1[&format=html|pdf]
+func (h *Handler) GetMonthlyReport(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	now := time.Now().UTC()
+	year, month := now.Year(), int(now.Month())
+	if v := r.URL.Query().Get("year"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, "invalid_period", "Invalid year")
+			return
+		}
+		year = parsed
+	}
+	if v := r.URL.Query().Get("month"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed < 1 || parsed > 12 {
+			respondError(w, http.StatusBadRequest, "invalid_period", "Invalid month")
+			return
+		}
+		month = parsed
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json", "html", "pdf":
+	default:
+		respondError(w, http.StatusBadRequest, "invalid_format", "Invalid format. Use: json, html, pdf")
+		return
+	}
+
+	// Antigüedad del mes pedido: 0 = mes actual
+	monthsBack := (now.Year()-year)*12 + int(now.Month()) - month
+	if monthsBack < 0 {
+		respondError(w, http.StatusBadRequest, "invalid_period", "Report period is in the future")
+		return
+	}
+
+	if monthsBack > 0 {
+		premium, err := h.postgres.IsUserPremium(r.Context(), userID)
+		if err != nil {
+			respondError(w, http.StatusInternalServerError, "db_error", "Failed to get user plan")
+			return
+		}
+		if !premium {
+			respondError(w, http.StatusForbidden, "premium_required", "Report history requires a premium plan")
+			return
+		}
+		if monthsBack >= premiumHistoryMonths {
+			respondError(w, http.StatusBadRequest, "period_out_of_range", "Reports are available for the last 12 months")
+			return
+		}
+	}
+
+	report, err := h.redis.GetCachedMonthlyReport(r.Context(), userID, year, month)
+	if err != nil {
+		log.Warn().Err(err).Msg("[Reports] Failed to read cached report")
+	}
+	if report == nil {
+		report, err = h.buildMonthlyReport(r.Context(), userID, year, month)
+		if err != nil {
+			log.Error().Err(err).Str("user_id", userID.String()).Msg("[Reports] Failed to build monthly report")
+			respondError(w, http.StatusInternalServerError, "db_error", "Failed to generate report")
+			return
+		}
+		if err := h.redis.CacheMonthlyReport(r.Context(), userID, year, month, report); err != nil {
+			log.Warn().Err(err).Msg("[Reports] Failed to cache report")
+		}
+	}
+
+	switch format {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := monthlyReportTemplate.Execute(w, report); err != nil {
+			log.Error().Err(err).Msg("[Reports] Failed to render HTML report")
+		}
+		return
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="trackfy-informe-%d-%02d.pdf"`, year, month))
+		if err := writeMonthlyReportPDF(w, report); err != nil {
+			log.Error().Err(err).Msg("[Reports] Failed to write PDF report")
+		}
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+}
+
+// GetAvailableReports lista los meses con informe disponible para el plan del usuario.
+// GET /api/v1/reports/available
+func (h *Handler) GetAvailableReports(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	premium, err := h.postgres.IsUserPremium(r.Context(), userID)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get user plan")
+		return
+	}
+
+	historyMonths := 1
+	if premium {
+		historyMonths = premiumHistoryMonths
+	}
+
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -(historyMonths - 1), 0)
+
+	months, err := h.postgres.GetAnalysisMonths(r.Context(), userID, since)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get available reports")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, map[string]interface{}{
+		"months":         months,
+		"premium":        premium,
+		"history_months": historyMonths,
+	})
+}
+
+// buildMonthlyReport agrega los análisis del mes, los reportes enviados a fy-analysis
+// y la comparación con el mes anterior
+func (h *Handler) buildMonthlyReport(ctx context.Context, userID uuid.UUID, year, month int) (*models.MonthlyReport, error) {
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 1, 0)
+
+	report, err := h.postgres.GetMonthlyAnalysisReport(ctx, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	prevTotal, prevThreats, err := h.postgres.GetAnalysisTotals(ctx, userID, from.AddDate(0, -1, 0), from)
+	if err != nil {
+		return nil, err
+	}
+	report.PreviousMonth = &models.MonthComparison{
+		TotalAnalyses: prevTotal,
+		ThreatsFound:  prevThreats,
+		AnalysesDelta: percentChange(prevTotal, report.TotalAnalyses),
+		ThreatsDelta:  percentChange(prevThreats, report.ThreatsFound),
+	}
+
+	// Reportes del usuario (si fy-analysis no responde, el informe sale sin ellos)
+	if h.fyAnalysis != nil {
+		summary, err := h.fyAnalysis.GetUserReportsSummary(ctx, userID.String(), year, month)
+		if err != nil {
+			log.Warn().Err(err).Msg("[Reports] Failed to get user reports from fy-analysis")
+		} else {
+			report.Reports = &models.MonthlyReportsInfo{
+				Total:        summary.Total,
+				ByThreatType: summary.ByThreatType,
+			}
+			for _, d := range summary.TopDomains {
+				report.TopDomains = append(report.TopDomains, models.DomainCount{Domain: d.Domain, Count: d.Count})
+			}
+			report.TopDomains = mergeTopDomains(report.TopDomains, 5)
+		}
+	}
+
+	report.GeneratedAt = time.Now().UTC()
+	return report, nil
+}
+
+// mergeTopDomains suma las apariciones de dominios repetidos y deja los limit más frecuentes
+func mergeTopDomains(domains []models.DomainCount, limit int) []models.DomainCount {
+	counts := make(map[string]int)
+	var merged []models.DomainCount
+	for _, d := range domains {
+		if _, ok := counts[d.Domain]; !ok {
+			merged = append(merged, models.DomainCount{Domain: d.Domain})
+		}
+		counts[d.Domain] += d.Count
+	}
+	for i := range merged {
+		merged[i].Count = counts[merged[i].Domain]
+	}
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Count > merged[j].Count })
+	if len(merged) > limit {
+		merged = merged[:limit]
+	}
+	return merged
+}
+
+// percentChange variación porcentual de prev a curr (0 si prev es 0)
+func percentChange(prev, curr int) float64 {
+	if prev == 0 {
+		return 0
+	}
+	return float64(curr-prev) / float64(prev) * 100
+}
+
+// monthlyReportTemplate versión HTML del informe (la app la muestra en un WebView o la comparte)
+var monthlyReportTemplate = template.Must(template.New("monthly_report").Funcs(template.FuncMap{
+	"pct": reportPct,
+	// barHeight altura (0-100%) de la barra del día respecto al día con más análisis
+	"barHeight": func(v int, timeline []models.DailyCount) int {
+		max := 0
+		for _, d := range timeline {
+			if d.Analyses > max {
+				max = d.Analyses
+			}
+		}
+		if max == 0 {
+			return 0
+		}
+		return v * 100 / max
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Informe Trackfy {{printf "%02d" .Month}}/{{.Year}}</title>
+<style>
+body { font-family: -apple-system, sans-serif; margin: 24px; color: #1f2937; }
+h1 { font-size: 22px; } h2 { font-size: 16px; margin-top: 24px; }
+table { border-collapse: collapse; width: 100%; }
+td, th { text-align: left; padding: 6px 8px; border-bottom: 1px solid #e5e7eb; }
+.stat { display: inline-block; margin-right: 24px; }
+.stat b { display: block; font-size: 28px; }
+.chart { display: flex; align-items: flex-end; height: 80px; gap: 2px; }
+.bar { flex: 1; background: #93c5fd; min-height: 1px; }
+.bar.threat { background: #f87171; }
+</style>
+</head>
+<body>
+<h1>Informe de amenazas {{printf "%02d" .Month}}/{{.Year}}</h1>
+
+<div class="stat"><b>{{.TotalAnalyses}}</b>análisis</div>
+<div class="stat"><b>{{.ThreatsFound}}</b>amenazas</div>
+{{with .Reports}}<div class="stat"><b>{{.Total}}</b>reportes enviados</div>{{end}}
+
+{{with .PreviousMonth}}
+<p>Mes anterior: {{.TotalAnalyses}} análisis ({{pct .AnalysesDelta}}) y {{.ThreatsFound}} amenazas ({{pct .ThreatsDelta}}).</p>
+{{end}}
+
+<h2>Por tipo</h2>
+<table>{{range $type, $count := .ByType}}<tr><td>{{$type}}</td><td>{{$count}}</td></tr>{{else}}<tr><td>Sin análisis</td></tr>{{end}}</table>
+
+<h2>Por nivel de riesgo</h2>
+<table>{{range $level, $count := .ByRiskLevel}}<tr><td>{{$level}}</td><td>{{$count}}</td></tr>{{else}}<tr><td>Sin análisis</td></tr>{{end}}</table>
+
+<h2>Dominios peligrosos más vistos</h2>
+<table>{{range .TopDomains}}<tr><td>{{.Domain}}</td><td>{{.Count}}</td></tr>{{else}}<tr><td>Ninguno</td></tr>{{end}}</table>
+
+<h2>Actividad diaria</h2>
+<div class="chart">{{range .Timeline}}<div class="bar{{if .Threats}} threat{{end}}" style="height: {{barHeight .Analyses $.Timeline}}%" title="{{.Date}}: {{.Analyses}} análisis, {{.Threats}} amenazas"></div>{{end}}</div>
+
+<p><small>Generado el {{.GeneratedAt.Format "02/01/2006 15:04"}} UTC</small></p>
+</body>
+</html>
+`))
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/api-gateway/internal/pdf"
+)
+
+// Maquetación del informe en PDF (puntos, A4)
+const (
+	reportPDFMargin    = 48.0
+	reportPDFRowHeight = 18.0
+	reportPDFChartSize = 90.0
+)
+
+var (
+	reportPDFAnalysisColor = pdf.Color{R: 0.58, G: 0.77, B: 0.99} // Mismos colores que la versión HTML
+	reportPDFThreatColor   = pdf.Color{R: 0.97, G: 0.44, B: 0.44}
+	reportPDFRuleColor     = pdf.Color{R: 0.9, G: 0.91, B: 0.92}
+)
+
+// reportPDF cursor vertical sobre el documento: añade página cuando no cabe lo siguiente
+type reportPDF struct {
+	doc *pdf.Document
+	y   float64
+}
+
+func (p *reportPDF) ensure(height float64) {
+	if p.y+height > pdf.PageHeight-reportPDFMargin {
+		p.doc.AddPage()
+		p.y = reportPDFMargin
+	}
+}
+
+func (p *reportPDF) heading(text string) {
+	p.ensure(24 + reportPDFRowHeight)
+	p.y += 24
+	p.doc.Text(reportPDFMargin, p.y, pdf.FontBold, 13, pdf.Black, text)
+	p.y += 8
+}
+
+func (p *reportPDF) paragraph(text string, size float64, color pdf.Color) {
+	p.ensure(size + 6)
+	p.y += size + 6
+	p.doc.Text(reportPDFMargin, p.y, pdf.FontRegular, size, color, text)
+}
+
+// table filas etiqueta / valor; empty si no hay ninguna
+func (p *reportPDF) table(rows [][2]string, empty string) {
+	if len(rows) == 0 {
+		rows = [][2]string{{empty, ""}}
+	}
+	right := pdf.PageWidth - reportPDFMargin
+	for _, row := range rows {
+		p.ensure(reportPDFRowHeight)
+		p.y += reportPDFRowHeight
+		p.doc.Text(reportPDFMargin+8, p.y-5, pdf.FontRegular, 10, pdf.Black, row[0])
+		p.doc.Text(right-8-pdf.TextWidth(row[1], 10), p.y-5, pdf.FontRegular, 10, pdf.Black, row[1])
+		p.doc.Line(reportPDFMargin, p.y, right, p.y, 0.5, reportPDFRuleColor)
+	}
+}
+
+// chart barras de análisis por día (en rojo los días con amenazas), como la versión HTML
+func (p *reportPDF) chart(timeline []models.DailyCount) {
+	if len(timeline) == 0 {
+		return
+	}
+	max := 0
+	for _, d := range timeline {
+		if d.Analyses > max {
+			max = d.Analyses
+		}
+	}
+
+	p.ensure(reportPDFChartSize + 12)
+	p.y += 12
+	width := (pdf.PageWidth - 2*reportPDFMargin) / float64(len(timeline))
+	bottom := p.y + reportPDFChartSize
+	for i, d := range timeline {
+		height := 1.0
+		if max > 0 && d.Analyses > 0 {
+			height = float64(d.Analyses) / float64(max) * reportPDFChartSize
+		}
+		color := reportPDFAnalysisColor
+		if d.Threats > 0 {
+			color = reportPDFThreatColor
+		}
+		p.doc.Rect(reportPDFMargin+float64(i)*width+1, bottom-height, width-2, height, color)
+	}
+	p.y = bottom
+}
+
+// writeMonthlyReportPDF versión PDF del informe (mismo contenido que monthlyReportTemplate)
+func writeMonthlyReportPDF(w io.Writer, report *models.MonthlyReport) error {
+	period := fmt.Sprintf("%02d/%d", report.Month, report.Year)
+	p := &reportPDF{doc: pdf.New("Informe Trackfy " + period), y: reportPDFMargin}
+	p.doc.AddPage()
+
+	p.y += 20
+	p.doc.Text(reportPDFMargin, p.y, pdf.FontBold, 20, pdf.Black, "Informe de amenazas "+period)
+
+	stats := fmt.Sprintf("%d análisis   ·   %d amenazas", report.TotalAnalyses, report.ThreatsFound)
+	if report.Reports != nil {
+		stats += fmt.Sprintf("   ·   %d reportes enviados", report.Reports.Total)
+	}
+	p.y += 8
+	p.paragraph(stats, 12, pdf.Black)
+
+	if prev := report.PreviousMonth; prev != nil {
+		p.paragraph(fmt.Sprintf("Mes anterior: %d análisis (%s) y %d amenazas (%s).",
+			prev.TotalAnalyses, reportPct(prev.AnalysesDelta), prev.ThreatsFound, reportPct(prev.ThreatsDelta)), 10, pdf.Gray)
+	}
+
+	p.heading("Por tipo")
+	p.table(countRows(report.ByType), "Sin análisis")
+
+	p.heading("Por nivel de riesgo")
+	p.table(countRows(report.ByRiskLevel), "Sin análisis")
+
+	p.heading("Dominios peligrosos más vistos")
+	domains := make([][2]string, 0, len(report.TopDomains))
+	for _, d := range report.TopDomains {
+		domains = append(domains, [2]string{d.Domain, strconv.Itoa(d.Count)})
+	}
+	p.table(domains, "Ninguno")
+
+	p.heading("Actividad diaria")
+	p.chart(report.Timeline)
+
+	p.y += 16
+	p.paragraph("Generado el "+report.GeneratedAt.Format("02/01/2006 15:04")+" UTC", 8, pdf.Gray)
+
+	_, err := p.doc.WriteTo(w)
+	return err
+}
+
+// countRows filas de un recuento ordenadas por clave (el orden de range en el HTML)
+func countRows(counts map[string]int) [][2]string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	rows := make([][2]string, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, [2]string{k, strconv.Itoa(counts[k])})
+	}
+	return rows
+}
+
+func reportPct(v float64) string {
+	return strconv.FormatFloat(v, 'f', 0, 64) + "%"
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+func TestWriteMonthlyReportPDF(t *testing.T) {
+	report := &models.MonthlyReport{
+		Year:          2024,
+		Month:         3,
+		TotalAnalyses: 42,
+		ThreatsFound:  7,
+		ByType:        map[string]int{"url": 30, "email": 8, "phone": 4},
+		ByRiskLevel:   map[string]int{"safe": 35, "dangerous": 7},
+		TopDomains:    []models.DomainCount{{Domain: "bbva-clientes.xyz", Count: 3}},
+		Timeline:      []models.DailyCount{{Date: "2024-03-01", Analyses: 4, Threats: 1}, {Date: "2024-03-02", Analyses: 2}},
+		Reports:       &models.MonthlyReportsInfo{Total: 2},
+		PreviousMonth: &models.MonthComparison{TotalAnalyses: 21, ThreatsFound: 7, AnalysesDelta: 100},
+		GeneratedAt:   time.Date(2024, 4, 1, 8, 30, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := writeMonthlyReportPDF(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+
+	for _, want := range []string{
+		"%PDF-1.4",
+		"(Informe de amenazas 03/2024)",
+		`(42 an\341lisis   \267   7 amenazas   \267   2 reportes enviados)`,
+		`(Mes anterior: 21 an\341lisis \(100%\) y 7 amenazas \(0%\).)`,
+		"(bbva-clientes.xyz)",
+		"(Generado el 01/04/2024 08:30 UTC)",
+		"/Count 1",
+	} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("PDF does not contain %q", want)
+		}
+	}
+}
+
+func TestWriteMonthlyReportPDFPageBreak(t *testing.T) {
+	report := &models.MonthlyReport{Year: 2024, Month: 3, GeneratedAt: time.Now()}
+	for i := 0; i < 60; i++ {
+		report.TopDomains = append(report.TopDomains, models.DomainCount{Domain: fmt.Sprintf("phish-%d.top", i), Count: 60 - i})
+	}
+
+	var buf bytes.Buffer
+	if err := writeMonthlyReportPDF(&buf, report); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("/Count 2")) {
+		t.Error("60 domains should spill onto a second page")
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("(phish-59.top)")) {
+		t.Error("last domain missing")
+	}
+}
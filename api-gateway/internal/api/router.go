@@ -2,46 +2,92 @@ package api
 
 import (
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/cors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/accountdeletion"
 	"github.com/trackfy/api-gateway/internal/auth"
 	"github.com/trackfy/api-gateway/internal/db"
 	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/push"
 	"github.com/trackfy/api-gateway/internal/services"
+	"github.com/trackfy/pkg/httpgzip"
+	"github.com/trackfy/pkg/openapi"
 )
 
-func NewRouter(postgres *db.PostgresDB, redis *db.RedisDB, jwtManager *auth.JWTManager, fyEngine *services.FyEngineClient, fyAnalysis *services.FyAnalysisClient) http.Handler {
+// compressedResponses respuestas enviadas con gzip por httpgzip
+var compressedResponses = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "trackfy_compressed_responses_total",
+	Help: "Respuestas HTTP enviadas comprimidas con gzip",
+})
+
+func NewRouter(postgres *db.PostgresDB, redis *db.RedisDB, jwtManager *auth.JWTManager, fyEngine *services.FyEngineClient, fyAnalysis *services.FyAnalysisClient, pushDispatcher *push.Dispatcher, accountDeletion *accountdeletion.Worker, signingSecret string, memoryFallback MemoryFallback, corsConfig middleware.CORSConfig, adminAPIKey string, development bool) http.Handler {
 	r := chi.NewRouter()
 
 	// Middleware global
 	r.Use(chimiddleware.RequestID)
+	r.Use(middleware.CorrelationID)
+	r.Use(middleware.TracingMiddleware)
 	r.Use(chimiddleware.RealIP)
-	r.Use(chimiddleware.Logger)
+	r.Use(middleware.RequestLogger)
 	r.Use(chimiddleware.Recoverer)
+	r.Use(httpgzip.New(httpgzip.Options{OnCompress: compressedResponses.Inc}))
 	r.Use(chimiddleware.Timeout(60 * time.Second))
 
 	// CORS
-	r.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Device-ID"},
-		ExposedHeaders:   []string{"X-RateLimit-Limit", "X-RateLimit-Remaining"},
-		AllowCredentials: true,
-		MaxAge:           300,
-	}))
+	corsConfig.ExposedHeaders = []string{"X-RateLimit-Limit", "X-RateLimit-Remaining", "X-Correlation-ID"}
+	r.Use(middleware.CORS(corsConfig))
 
 	// Crear handler y middlewares
 	h := NewHandler(postgres, redis, jwtManager, fyEngine)
 	h.SetFyAnalysisClient(fyAnalysis)
+	h.SetPushDispatcher(pushDispatcher)
+	h.SetAccountDeletionWorker(accountDeletion)
+	h.SetMemoryFallback(memoryFallback)
+	h.SetDevelopment(development)
 	authMw := middleware.NewAuthMiddleware(jwtManager, redis)
 	rateLimiter := middleware.NewRateLimiter(redis)
 
 	// Health check (sin auth)
 	r.Get("/health", h.Health)
 
+	// Métricas Prometheus (sin auth)
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Documento OpenAPI y Swagger UI (sin auth)
+	r.Get("/api/openapi.json", openapi.SpecHandler(openAPISpec))
+	r.Get("/docs", openapi.SwaggerUIHandler("Trackfy API Gateway", "/api/openapi.json"))
+
+	// Webhooks internos de fy-analysis (firma HMAC, sin JWT)
+	r.Route("/internal/webhooks", func(r chi.Router) {
+		r.Use(middleware.SignatureValidation(signingSecret, redis))
+
+		r.Post("/reclassification", h.ReclassificationWebhook)
+	})
+
+	// Analytics de administración (API key, sin JWT). Se registra antes que /api/v1:
+	// chi resuelve por el prefijo más específico.
+	r.Route("/api/v1/analytics", func(r chi.Router) {
+		r.Use(middleware.AdminAPIKey(adminAPIKey))
+
+		r.Get("/trending", h.GetTrendingAnalytics)
+		r.Get("/risk-distribution", h.GetRiskDistribution)
+		r.Get("/checker-coverage", h.GetCheckerCoverage)
+	})
+
+	// Estado de un borrado de cuenta (sin JWT: el borrado revoca las sesiones)
+	r.Route("/api/v1/account-deletions", func(r chi.Router) {
+		r.Use(rateLimiter.Limit(30, time.Minute))
+
+		r.Get("/{id}", h.GetAccountDeletionStatus)
+	})
+
 	// Rutas públicas de autenticación
 	r.Route("/auth", func(r chi.Router) {
 		// Rate limit más estricto para auth
@@ -60,26 +106,96 @@ func NewRouter(postgres *db.PostgresDB, redis *db.RedisDB, jwtManager *auth.JWTM
 		// Usuario
 		r.Route("/me", func(r chi.Router) {
 			r.Get("/", h.GetMe)
+			r.Delete("/", h.DeleteAccount)
+			r.Post("/deletion-code", h.SendAccountDeletionCode)
 			r.Get("/sessions", h.GetMySessions)
+			r.Delete("/sessions/{id}", h.RevokeSession)
 			r.Get("/stats", h.GetMyStats)
 			r.Post("/logout", h.Logout)
 			r.Post("/logout-all", h.LogoutAll)
+
+			// Dispositivos para notificaciones push
+			r.Post("/devices", h.RegisterDevice)
+			r.Delete("/devices", h.UnregisterDevice)
+
+			// Notificaciones in-app (reportes confirmados por fy-analysis)
+			r.Get("/notifications", h.GetMyNotifications)
+			r.Post("/notifications/read", h.MarkMyNotificationsRead)
+
+			// Entidades consultadas en el chat (pantalla "mis enlaces comprobados")
+			r.Get("/entities", h.GetMyEntities)
+
+			// Exportación del historial (RGPD, portabilidad): pocas al día por usuario
+			r.With(rateLimiter.LimitByUserAction("export", exportsPerDay, 24*time.Hour)).Get("/export", h.ExportMyData)
 		})
 
+		// Borrado de cuenta (RGPD); alias de DELETE /me
+		r.Delete("/account", h.DeleteAccount)
+
 		// Conversaciones
 		r.Route("/conversations", func(r chi.Router) {
 			r.Get("/", h.GetConversations)
 			r.Post("/", h.CreateConversation)
 			r.Get("/{id}", h.GetConversation)
 			r.Get("/{id}/messages", h.GetConversationMessages)
+			r.Post("/{id}/archive", h.ArchiveConversation)
+			r.Post("/{id}/unarchive", h.UnarchiveConversation)
+			r.With(rateLimiter.LimitByUserAction("export", exportsPerDay, 24*time.Hour)).Get("/{id}/export", h.ExportConversation)
+		})
+
+		// Lista de confianza personal
+		r.Route("/allowlist", func(r chi.Router) {
+			r.Get("/", h.GetAllowlist)
+			r.Post("/", h.AddAllowlistEntry)
+			r.Delete("/", h.DeleteAllowlistEntry)
 		})
 
 		// Chat con Fy
 		r.Post("/chat", h.Chat)
+		r.Post("/chat/stream", h.ChatStream)
 
 		// Reportes de URLs sospechosas
 		r.Post("/report", h.ReportURL)
+
+		// Propuestas de dominios legítimos (revisión en fy-admin)
+		r.Post("/whitelist/request", h.RequestWhitelist)
+
+		// Informes mensuales de amenazas
+		r.Route("/reports", func(r chi.Router) {
+			r.Get("/monthly", h.GetMonthlyReport)
+			r.Get("/available", h.GetAvailableReports)
+		})
+
+		// Dominios peligrosos en tendencia
+		r.Get("/threats/trending", h.GetTrendingThreats)
+
+		// Plan del usuario (prueba, cobro fallido, cancelada a fin de periodo)
+		r.Get("/subscription/status", h.GetSubscriptionStatus)
 	})
 
+	if len(corsConfig.AllowedOrigins) > 0 {
+		warnUnallowedCORSMethods(r, corsConfig.AllowedMethods)
+	}
+
 	return r
 }
+
+// warnUnallowedCORSMethods avisa de las rutas cuyo método rechazaría el preflight CORS
+func warnUnallowedCORSMethods(routes chi.Routes, allowed []string) {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, m := range allowed {
+		allowedSet[strings.ToUpper(m)] = true
+	}
+
+	missing := make(map[string]bool)
+	_ = chi.Walk(routes, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if !allowedSet[method] && method != http.MethodOptions && method != http.MethodHead {
+			missing[method] = true
+		}
+		return nil
+	})
+
+	for method := range missing {
+		log.Warn().Str("method", method).Msg("[CORS] Hay rutas con un método fuera de CORS_ALLOWED_METHODS")
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/middleware"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// SubscriptionStatusResponse plan del usuario para la app. Con cancel_at_period_end el
+// acceso premium dura hasta current_period_end ("premium hasta el 3 de marzo (cancelada)").
+type SubscriptionStatusResponse struct {
+	Premium           bool       `json:"premium"`
+	Status            string     `json:"status"` // Estado de Stripe; "none" sin suscripción
+	CurrentPeriodEnd  *time.Time `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd bool       `json:"cancel_at_period_end"`
+	Trialing          bool       `json:"trialing"`
+	PastDue           bool       `json:"past_due"` // Cobro fallido: premium mientras Stripe reintenta
+}
+
+// GetSubscriptionStatus estado de la suscripción del usuario.
+// GET /api/v1/subscription/status
+func (h *Handler) GetSubscriptionStatus(w http.ResponseWriter, r *http.Request) {
+	userID, _ := middleware.GetUserID(r.Context())
+
+	sub, err := h.postgres.GetSubscriptionStatus(r.Context(), userID)
+	if err != nil {
+		log.Error().Err(err).Str("user_id", userID.String()).Msg("[Subscription] Failed to get subscription")
+		respondError(w, http.StatusInternalServerError, "db_error", "Failed to get subscription")
+		return
+	}
+
+	respondJSON(w, http.StatusOK, subscriptionStatusResponse(sub))
+}
+
+func subscriptionStatusResponse(sub *models.Subscription) SubscriptionStatusResponse {
+	status := sub.Status
+	if status == "" {
+		status = "none"
+		if sub.LegacyPremium {
+			status = models.SubscriptionActive
+		}
+	}
+	return SubscriptionStatusResponse{
+		Premium:           sub.Entitled(),
+		Status:            status,
+		CurrentPeriodEnd:  sub.CurrentPeriodEnd,
+		CancelAtPeriodEnd: sub.CancelAtPeriodEnd,
+		Trialing:          sub.Status == models.SubscriptionTrialing,
+		PastDue:           sub.Status == models.SubscriptionPastDue,
+	}
+}
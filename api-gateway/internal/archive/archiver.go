@@ -0,0 +1,128 @@
+// Package archive mueve las conversaciones antiguas y cortas a archived_conversations y
+// archived_messages para que el listado de conversaciones no cargue con ellas.
+package archive
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/db"
+)
+
+const (
+	// batchSize conversaciones por transacción
+	batchSize = 500
+	// runTimeout tiempo máximo de una pasada
+	runTimeout = 30 * time.Minute
+)
+
+var conversationsArchived = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "conversations_archived_total",
+	Help: "Conversaciones movidas a archived_conversations por el archiver",
+})
+
+// Config criterios del archiver
+type Config struct {
+	Interval    time.Duration // Entre pasadas
+	MaxAge      time.Duration // Sin actividad (updated_at) desde hace al menos MaxAge
+	MaxMessages int           // Y con menos de MaxMessages mensajes
+}
+
+// Archiver archiva en segundo plano las conversaciones sin actividad con pocos mensajes.
+// Las transacciones usan SKIP LOCKED: varias réplicas del gateway pueden ejecutarlo a la vez.
+type Archiver struct {
+	postgres *db.PostgresDB
+	redis    *db.RedisDB
+	config   Config
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// NewArchiver crea el archiver
+func NewArchiver(postgres *db.PostgresDB, redis *db.RedisDB, config Config) *Archiver {
+	if config.Interval <= 0 {
+		config.Interval = 7 * 24 * time.Hour
+	}
+	return &Archiver{
+		postgres: postgres,
+		redis:    redis,
+		config:   config,
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start ejecuta una pasada al arrancar y luego cada Interval
+func (a *Archiver) Start() {
+	log.Info().
+		Dur("interval", a.config.Interval).
+		Dur("max_age", a.config.MaxAge).
+		Int("max_messages", a.config.MaxMessages).
+		Msg("[Archiver] Starting conversation archiver")
+
+	go func() {
+		defer close(a.done)
+
+		ticker := time.NewTicker(a.config.Interval)
+		defer ticker.Stop()
+
+		for {
+			ctx, cancel := context.WithTimeout(context.Background(), runTimeout)
+			if n, err := a.Run(ctx); err != nil {
+				log.Error().Err(err).Int("archived", n).Msg("[Archiver] Archive run failed")
+			} else if n > 0 {
+				log.Info().Int("archived", n).Msg("[Archiver] Conversations archived")
+			}
+			cancel()
+
+			select {
+			case <-a.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop espera a que termine la pasada en curso
+func (a *Archiver) Stop() {
+	a.stopOnce.Do(func() { close(a.stopCh) })
+	<-a.done
+}
+
+// Run archiva por lotes todas las candidatas. Retorna cuántas archivó (también si falla a mitad).
+func (a *Archiver) Run(ctx context.Context) (int, error) {
+	before := time.Now().Add(-a.config.MaxAge)
+	total := 0
+
+	for {
+		ids, err := a.postgres.ArchiveInactiveConversations(ctx, before, a.config.MaxMessages, batchSize)
+		if err != nil {
+			return total, err
+		}
+		total += len(ids)
+		conversationsArchived.Add(float64(len(ids)))
+
+		// La cache de mensajes apunta a tablas de las que ya no se leen
+		for _, id := range ids {
+			if err := a.redis.InvalidateConversationCache(ctx, id); err != nil {
+				log.Warn().Err(err).Str("conversation_id", id.String()).Msg("[Archiver] Failed to invalidate conversation cache")
+			}
+		}
+
+		if len(ids) < batchSize {
+			return total, nil
+		}
+		select {
+		case <-a.stopCh:
+			return total, nil
+		default:
+		}
+	}
+}
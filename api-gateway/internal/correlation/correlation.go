@@ -0,0 +1,51 @@
+package correlation
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// Header identificador de una petición de usuario a lo largo de todos los servicios
+// (api-gateway -> fy-analysis / fy-engine)
+const Header = "X-Correlation-ID"
+
+// validID ids aceptados del cliente: UUIDs u otros tokens cortos sin caracteres de control
+var validID = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type ctxKey struct{}
+
+// Valid indica si id se puede propagar tal cual
+func Valid(id string) bool {
+	return validID.MatchString(id)
+}
+
+// NewContext guarda id en ctx junto con un logger que lo añade a cada línea
+func NewContext(ctx context.Context, id string) context.Context {
+	logger := log.With().Str("correlation_id", id).Logger()
+	return logger.WithContext(context.WithValue(ctx, ctxKey{}, id))
+}
+
+// FromContext correlation ID de ctx ("" si no hay)
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// Logger logger de ctx con correlation_id, o el global si ctx no tiene
+func Logger(ctx context.Context) *zerolog.Logger {
+	if FromContext(ctx) == "" {
+		return &log.Logger
+	}
+	return zerolog.Ctx(ctx)
+}
+
+// Inject añade el correlation ID del contexto de req a sus cabeceras
+func Inject(req *http.Request) {
+	if id := FromContext(req.Context()); id != "" {
+		req.Header.Set(Header, id)
+	}
+}
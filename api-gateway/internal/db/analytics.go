@@ -0,0 +1,195 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// PrefixAnalyticsCache claves de los agregados de analytics (globales, no por usuario)
+const PrefixAnalyticsCache = "analytics:"
+
+// riskBuckets tramos del histograma de risk scores
+var riskBuckets = []struct{ min, max int }{
+	{0, 10}, {10, 30}, {30, 50}, {50, 70}, {70, 90}, {90, 100},
+}
+
+// ==================== ANALYTICS (POSTGRES) ====================
+
+// GetTrendingEntities top limit de dominios, URLs, emails y teléfonos más analizados desde since.
+// Los dominios salen de la columna domain (URLs y emails); el resto, del valor en minúsculas.
+func (p *PostgresDB) GetTrendingEntities(ctx context.Context, since time.Time, limit int) (*models.TrendingWindow, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		WITH recent AS (
+			SELECT entity_type, lower(entity_value) AS value, domain, verdict
+			FROM analysis_results
+			WHERE created_at >= $1
+		), counts AS (
+			SELECT 'domain' AS category, domain AS value, COUNT(*) AS analyses,
+				COUNT(*) FILTER (WHERE `+threatVerdictsSQL+`) AS threats
+			FROM recent
+			WHERE domain IS NOT NULL
+			GROUP BY domain
+			UNION ALL
+			SELECT entity_type, value, COUNT(*),
+				COUNT(*) FILTER (WHERE `+threatVerdictsSQL+`)
+			FROM recent
+			GROUP BY entity_type, value
+		)
+		SELECT category, value, analyses, threats
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY category ORDER BY analyses DESC, value) AS rank
+			FROM counts
+		) ranked
+		WHERE rank <= $2
+		ORDER BY category, analyses DESC, value
+	`, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	window := &models.TrendingWindow{
+		Since: since,
+		Categories: map[string][]models.TrendingEntry{
+			"domain": {}, "url": {}, "email": {}, "phone": {},
+		},
+	}
+	for rows.Next() {
+		var category string
+		var entry models.TrendingEntry
+		if err := rows.Scan(&category, &entry.Value, &entry.Analyses, &entry.Threats); err != nil {
+			return nil, err
+		}
+		window.Categories[category] = append(window.Categories[category], entry)
+	}
+	return window, rows.Err()
+}
+
+// GetRiskDistribution histograma de risk scores de los análisis desde since (todos los tramos, aunque estén vacíos)
+func (p *PostgresDB) GetRiskDistribution(ctx context.Context, since time.Time) (*models.RiskDistribution, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT CASE
+				WHEN risk_score < 10 THEN 0
+				WHEN risk_score < 30 THEN 1
+				WHEN risk_score < 50 THEN 2
+				WHEN risk_score < 70 THEN 3
+				WHEN risk_score < 90 THEN 4
+				ELSE 5
+			END AS bucket, COUNT(*)
+		FROM analysis_results
+		WHERE created_at >= $1
+		GROUP BY 1
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	dist := &models.RiskDistribution{Since: since, Buckets: make([]models.RiskBucket, len(riskBuckets))}
+	for i, b := range riskBuckets {
+		dist.Buckets[i] = models.RiskBucket{Range: fmt.Sprintf("%d-%d", b.min, b.max), Min: b.min, Max: b.max}
+	}
+	for rows.Next() {
+		var bucket, count int
+		if err := rows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		dist.Buckets[bucket].Count = count
+		dist.Total += count
+	}
+	return dist, rows.Err()
+}
+
+// GetCheckerCoverage porcentaje de análisis desde since en los que cada checker dio positivo
+func (p *PostgresDB) GetCheckerCoverage(ctx context.Context, since time.Time) (*models.CheckerCoverage, error) {
+	coverage := &models.CheckerCoverage{Since: since, Checkers: []models.CheckerCoverageEntry{}}
+
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM analysis_results WHERE created_at >= $1 AND checkers IS NOT NULL
+	`, since).Scan(&coverage.Analyses)
+	if err != nil || coverage.Analyses == 0 {
+		return coverage, err
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT checker, COUNT(*)
+		FROM analysis_results, unnest(checkers) AS checker
+		WHERE created_at >= $1
+		GROUP BY checker
+		ORDER BY COUNT(*) DESC, checker
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry models.CheckerCoverageEntry
+		if err := rows.Scan(&entry.Checker, &entry.Analyses); err != nil {
+			return nil, err
+		}
+		entry.Percent = math.Round(float64(entry.Analyses)*1000/float64(coverage.Analyses)) / 10
+		coverage.Checkers = append(coverage.Checkers, entry)
+	}
+	return coverage, rows.Err()
+}
+
+// GetTrendingThreatDomains dominios con más análisis peligrosos desde since. Solo dominios
+// analizados por al menos minUsers usuarios distintos, para no exponer lo que analiza uno solo.
+func (p *PostgresDB) GetTrendingThreatDomains(ctx context.Context, since time.Time, minUsers, limit int) ([]models.TrendingDomain, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT domain, COUNT(*)
+		FROM analysis_results
+		WHERE created_at >= $1 AND domain IS NOT NULL AND `+threatVerdictsSQL+`
+		GROUP BY domain
+		HAVING COUNT(DISTINCT user_id) >= $2
+		ORDER BY COUNT(*) DESC, domain
+		LIMIT $3
+	`, since, minUsers, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	domains := []models.TrendingDomain{}
+	for rows.Next() {
+		var d models.TrendingDomain
+		if err := rows.Scan(&d.Domain, &d.Threats); err != nil {
+			return nil, err
+		}
+		domains = append(domains, d)
+	}
+	return domains, rows.Err()
+}
+
+// ==================== ANALYTICS (CACHE) ====================
+
+// CacheAnalytics guarda un agregado de analytics en JSON durante ttl
+func (r *RedisDB) CacheAnalytics(ctx context.Context, name string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, PrefixAnalyticsCache+name, data, ttl).Err()
+}
+
+// GetCachedAnalytics lee un agregado cacheado en dest. Retorna false si no está en cache.
+func (r *RedisDB) GetCachedAnalytics(ctx context.Context, name string, dest interface{}) (bool, error) {
+	data, err := r.client.Get(ctx, PrefixAnalyticsCache+name).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,130 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// ==================== ARCHIVED CONVERSATIONS ====================
+
+// moveToArchiveSQL pasa las conversaciones $1 (uuid[]) y sus mensajes a las tablas de archivo
+var moveToArchiveSQL = []string{
+	`INSERT INTO archived_conversations (id, user_id, title, created_at, updated_at, is_active, message_count)
+	 SELECT id, user_id, title, created_at, updated_at, is_active, message_count
+	 FROM conversations WHERE id = ANY($1::uuid[])`,
+	`INSERT INTO archived_messages (id, conversation_id, role, content, intent, mood, analysis_performed, entities_found, created_at)
+	 SELECT id, conversation_id, role, content, intent, mood, analysis_performed, entities_found, created_at
+	 FROM messages WHERE conversation_id = ANY($1::uuid[])`,
+	`DELETE FROM conversations WHERE id = ANY($1::uuid[])`, // Sus mensajes por CASCADE
+}
+
+// moveFromArchiveSQL devuelve las conversaciones $1 a las tablas calientes. updated_at pasa a
+// NOW(): si no, el archiver la volvería a archivar en la siguiente pasada.
+var moveFromArchiveSQL = []string{
+	`INSERT INTO conversations (id, user_id, title, created_at, updated_at, is_active, message_count)
+	 SELECT id, user_id, title, created_at, NOW(), is_active, message_count
+	 FROM archived_conversations WHERE id = ANY($1::uuid[])`,
+	`INSERT INTO messages (id, conversation_id, role, content, intent, mood, analysis_performed, entities_found, created_at)
+	 SELECT id, conversation_id, role, content, intent, mood, analysis_performed, entities_found, created_at
+	 FROM archived_messages WHERE conversation_id = ANY($1::uuid[])`,
+	`DELETE FROM archived_conversations WHERE id = ANY($1::uuid[])`,
+}
+
+func (p *PostgresDB) moveConversations(ctx context.Context, tx *sql.Tx, steps []string, ids []uuid.UUID) error {
+	array := make([]string, len(ids))
+	for i, id := range ids {
+		array[i] = id.String()
+	}
+	for _, query := range steps {
+		if _, err := tx.ExecContext(ctx, query, pq.Array(array)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ArchiveConversation archiva una conversación del usuario. false si no existe o ya está archivada.
+func (p *PostgresDB) ArchiveConversation(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	return p.moveConversation(ctx, `
+		SELECT id FROM conversations WHERE id = $1 AND user_id = $2 AND is_active = true FOR UPDATE
+	`, moveToArchiveSQL, conversationID, userID)
+}
+
+// UnarchiveConversation devuelve una conversación archivada del usuario. false si no está archivada.
+func (p *PostgresDB) UnarchiveConversation(ctx context.Context, conversationID, userID uuid.UUID) (bool, error) {
+	return p.moveConversation(ctx, `
+		SELECT id FROM archived_conversations WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, moveFromArchiveSQL, conversationID, userID)
+}
+
+func (p *PostgresDB) moveConversation(ctx context.Context, lockQuery string, steps []string, conversationID, userID uuid.UUID) (bool, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var id uuid.UUID
+	err = tx.QueryRowContext(ctx, lockQuery, conversationID, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := p.moveConversations(ctx, tx, steps, []uuid.UUID{id}); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// ArchiveInactiveConversations archiva hasta limit conversaciones sin actividad desde before
+// y con menos de maxMessages mensajes. Retorna las archivadas (para invalidar su cache).
+func (p *PostgresDB) ArchiveInactiveConversations(ctx context.Context, before time.Time, maxMessages, limit int) ([]uuid.UUID, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// SKIP LOCKED: las que se están escribiendo ahora mismo quedan para la siguiente pasada
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id FROM conversations
+		WHERE updated_at < $1 AND message_count < $2 AND is_active = true
+		ORDER BY updated_at
+		LIMIT $3
+		FOR UPDATE SKIP LOCKED
+	`, before, maxMessages, limit)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	if err := p.moveConversations(ctx, tx, moveToArchiveSQL, ids); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
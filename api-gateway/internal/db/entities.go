@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// ==================== USER ENTITIES ====================
+
+// RecordMessageEntities guarda las entidades en el mensaje del usuario (entities_found) y
+// actualiza user_entities: una fila por valor normalizado, con el último veredicto conocido.
+// Una entidad sin analizar no borra el veredicto de una consulta anterior.
+func (p *PostgresDB) RecordMessageEntities(ctx context.Context, userID, messageID uuid.UUID, entities []models.MessageEntity) error {
+	if len(entities) == 0 {
+		return nil
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entitiesJSON, _ := json.Marshal(map[string]interface{}{"entities": entities})
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE messages SET entities_found = $1 WHERE id = $2
+	`, entitiesJSON, messageID); err != nil {
+		return err
+	}
+
+	for _, e := range entities {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_entities (user_id, entity_type, entity_key, entity_value, risk_score, verdict, analyzed_at, last_message_id)
+			VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), CASE WHEN $6 = '' THEN NULL ELSE NOW() END, $7)
+			ON CONFLICT (user_id, entity_type, entity_key) DO UPDATE SET
+				entity_value = EXCLUDED.entity_value,
+				risk_score = COALESCE(EXCLUDED.risk_score, user_entities.risk_score),
+				verdict = COALESCE(EXCLUDED.verdict, user_entities.verdict),
+				analyzed_at = COALESCE(EXCLUDED.analyzed_at, user_entities.analyzed_at),
+				times_seen = user_entities.times_seen + 1,
+				last_seen_at = NOW(),
+				last_message_id = EXCLUDED.last_message_id
+		`, userID, e.Type, e.Key, e.Value, e.RiskScore, e.Verdict, messageID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetUserEntities entidades consultadas por el usuario, las más recientes primero.
+// entityType filtra por tipo (vacío = todas). Retorna también el total para paginar.
+func (p *PostgresDB) GetUserEntities(ctx context.Context, userID uuid.UUID, entityType string, limit, offset int) ([]models.UserEntity, int, error) {
+	var total int
+	if err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM user_entities
+		WHERE user_id = $1 AND ($2 = '' OR entity_type = $2)
+	`, userID, entityType).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT entity_type, entity_value, COALESCE(verdict, ''), risk_score, analyzed_at,
+			times_seen, first_seen_at, last_seen_at, last_message_id
+		FROM user_entities
+		WHERE user_id = $1 AND ($2 = '' OR entity_type = $2)
+		ORDER BY last_seen_at DESC, entity_key
+		LIMIT $3 OFFSET $4
+	`, userID, entityType, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	entities := []models.UserEntity{}
+	for rows.Next() {
+		var e models.UserEntity
+		if err := rows.Scan(&e.Type, &e.Value, &e.Verdict, &e.RiskScore, &e.AnalyzedAt,
+			&e.TimesSeen, &e.FirstSeenAt, &e.LastSeenAt, &e.LastMessageID); err != nil {
+			return nil, 0, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, total, rows.Err()
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// ==================== EXPORT ====================
+
+// GetExportConversations todas las conversaciones del usuario (incluidas las archivadas),
+// las más antiguas primero. Sin mensajes: se leen con ForEachExportMessage.
+func (p *PostgresDB) GetExportConversations(ctx context.Context, userID uuid.UUID) ([]models.Conversation, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, user_id, title, created_at, updated_at, is_active, message_count, NULL::timestamp
+		FROM conversations WHERE user_id = $1 AND is_active = true
+		UNION ALL
+		SELECT id, user_id, title, created_at, updated_at, is_active, message_count, archived_at
+		FROM archived_conversations WHERE user_id = $1
+		ORDER BY created_at
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	conversations := []models.Conversation{}
+	for rows.Next() {
+		conv, err := scanExportConversation(rows)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, *conv)
+	}
+	return conversations, rows.Err()
+}
+
+// GetExportConversation conversación del usuario, activa o archivada (sql.ErrNoRows si no es suya)
+func (p *PostgresDB) GetExportConversation(ctx context.Context, userID, conversationID uuid.UUID) (*models.Conversation, error) {
+	row := p.db.QueryRowContext(ctx, `
+		SELECT id, user_id, title, created_at, updated_at, is_active, message_count, NULL::timestamp
+		FROM conversations WHERE user_id = $1 AND id = $2 AND is_active = true
+		UNION ALL
+		SELECT id, user_id, title, created_at, updated_at, is_active, message_count, archived_at
+		FROM archived_conversations WHERE user_id = $1 AND id = $2
+	`, userID, conversationID)
+	return scanExportConversation(row)
+}
+
+func scanExportConversation(row interface{ Scan(...interface{}) error }) (*models.Conversation, error) {
+	var conv models.Conversation
+	var title sql.NullString
+	var archivedAt sql.NullTime
+	if err := row.Scan(&conv.ID, &conv.UserID, &title, &conv.CreatedAt, &conv.UpdatedAt,
+		&conv.IsActive, &conv.MessageCount, &archivedAt); err != nil {
+		return nil, err
+	}
+	conv.Title = title.String
+	if archivedAt.Valid {
+		conv.IsArchived = true
+		conv.ArchivedAt = &archivedAt.Time
+	}
+	return &conv, nil
+}
+
+// ForEachExportMessage recorre en orden cronológico los mensajes de la conversación sin
+// cargarlos todos en memoria. El JOIN con la conversación limita el resultado a las del usuario.
+func (p *PostgresDB) ForEachExportMessage(ctx context.Context, userID, conversationID uuid.UUID, fn func(models.Message) error) error {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT m.id, m.conversation_id, m.role, m.content, m.intent, m.mood, m.analysis_performed, m.entities_found, m.created_at
+		FROM messages m JOIN conversations c ON c.id = m.conversation_id
+		WHERE m.conversation_id = $1 AND c.user_id = $2
+		UNION ALL
+		SELECT m.id, m.conversation_id, m.role, m.content, m.intent, m.mood, m.analysis_performed, m.entities_found, m.created_at
+		FROM archived_messages m JOIN archived_conversations c ON c.id = m.conversation_id
+		WHERE m.conversation_id = $1 AND c.user_id = $2
+		ORDER BY created_at, id
+	`, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m models.Message
+		var intent, mood sql.NullString
+		var entitiesJSON []byte
+		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &intent, &mood,
+			&m.AnalysisPerformed, &entitiesJSON, &m.CreatedAt); err != nil {
+			return err
+		}
+		m.Intent = intent.String
+		m.Mood = mood.String
+		if len(entitiesJSON) > 0 {
+			_ = json.Unmarshal(entitiesJSON, &m.EntitiesFound)
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,260 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// fakeMessagesDB conexión en memoria que responde a la consulta de GetConversationMessagesPage
+// con la semántica de PostgreSQL: comparación de tuplas (created_at, id) y ORDER BY + LIMIT
+type fakeMessagesDB struct {
+	messages []models.Message
+	rows     [][]driver.Value // Si no es nil, se devuelven tal cual (filas corruptas)
+	rowsErr  error            // Error de la consulta tras las filas
+}
+
+func (c *fakeMessagesDB) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *fakeMessagesDB) Driver() driver.Driver                        { return nil }
+func (c *fakeMessagesDB) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *fakeMessagesDB) Close() error              { return nil }
+func (c *fakeMessagesDB) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *fakeMessagesDB) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if c.rows != nil {
+		return &fakeRows{values: c.rows, err: c.rowsErr}, nil
+	}
+
+	desc := strings.Contains(query, "created_at DESC, id DESC")
+	limit := int(args[1].Value.(int64))
+	var cursorAt time.Time
+	var cursorID string
+	if len(args) > 2 {
+		cursorAt = args[2].Value.(time.Time)
+	}
+	if len(args) > 3 {
+		cursorID = args[3].Value.(string)
+	}
+
+	// (created_at, id) < ($3, $4) es lexicográfico; el uuid se compara como sus bytes
+	compare := func(m models.Message) int {
+		if c := m.CreatedAt.Compare(cursorAt); c != 0 || cursorID == "" {
+			return c
+		}
+		return strings.Compare(m.ID.String(), cursorID)
+	}
+
+	older := strings.Contains(query, " < ")
+	var page []models.Message
+	for _, m := range c.messages {
+		if len(args) == 2 || older && compare(m) < 0 || !older && compare(m) > 0 {
+			page = append(page, m)
+		}
+	}
+	sort.Slice(page, func(i, j int) bool {
+		a, b := page[i], page[j]
+		less := a.CreatedAt.Before(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID.String() < b.ID.String()
+		if desc {
+			return !less
+		}
+		return less
+	})
+	if len(page) > limit {
+		page = page[:limit]
+	}
+
+	rows := &fakeRows{err: c.rowsErr}
+	for _, m := range page {
+		rows.values = append(rows.values, []driver.Value{
+			m.ID.String(), m.ConversationID.String(), m.Role, m.Content, nil, nil, false, []byte(nil), m.CreatedAt,
+		})
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	values [][]driver.Value
+	err    error
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"id", "conversation_id", "role", "content", "intent", "mood", "analysis_performed", "entities_found", "created_at"}
+}
+func (r *fakeRows) Close() error { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		if r.err != nil {
+			return r.err
+		}
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// sameSecondConversation mensajes con created_at repetido: varios en el mismo microsegundo
+// (lo que guarda PostgreSQL) y el resto en el mismo segundo
+func sameSecondConversation() (uuid.UUID, []models.Message) {
+	convID := uuid.New()
+	second := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	offsets := []time.Duration{0, 0, 0, 0, 250 * time.Microsecond, 250 * time.Microsecond, 999 * time.Millisecond}
+
+	messages := make([]models.Message, 0, len(offsets))
+	for i, offset := range offsets {
+		messages = append(messages, models.Message{
+			ID:             uuid.New(),
+			ConversationID: convID,
+			Role:           "user",
+			Content:        string(rune('a' + i)),
+			CreatedAt:      second.Add(offset),
+		})
+	}
+	return convID, messages
+}
+
+// walkPages recorre la conversación con el cursor de cada página (el último mensaje, como
+// next_cursor) y retorna los ids en el orden recibido
+func walkPages(t *testing.T, p *PostgresDB, convID uuid.UUID, before bool, limit int) []uuid.UUID {
+	t.Helper()
+	var cursor *models.MessageCursor
+	var ids []uuid.UUID
+	for pages := 0; ; pages++ {
+		if pages > 20 {
+			t.Fatal("pagination does not terminate")
+		}
+		messages, err := p.GetConversationMessagesPage(context.Background(), convID, cursor, before, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		more := len(messages) > limit
+		if more {
+			messages = messages[:limit]
+		}
+		for _, m := range messages {
+			ids = append(ids, m.ID)
+		}
+		if !more {
+			return ids
+		}
+		last := messages[len(messages)-1]
+		cursor = &models.MessageCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+	}
+}
+
+func sortedIDs(messages []models.Message, desc bool) []uuid.UUID {
+	sorted := append([]models.Message(nil), messages...)
+	sort.Slice(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		less := a.CreatedAt.Before(b.CreatedAt) || a.CreatedAt.Equal(b.CreatedAt) && a.ID.String() < b.ID.String()
+		return less != desc
+	})
+	ids := make([]uuid.UUID, len(sorted))
+	for i, m := range sorted {
+		ids[i] = m.ID
+	}
+	return ids
+}
+
+func TestMessagePagesWithSameSecondMessages(t *testing.T) {
+	convID, messages := sameSecondConversation()
+	p := &PostgresDB{db: sql.OpenDB(&fakeMessagesDB{messages: messages})}
+	defer p.db.Close()
+
+	for _, limit := range []int{1, 2, 3, 4, 7, 10} {
+		// Hacia atrás (before) de más reciente a más antiguo y hacia delante (after) en orden
+		// cronológico: cada mensaje aparece una vez aunque compartan instante con el cursor
+		for _, before := range []bool{true, false} {
+			got := walkPages(t, p, convID, before, limit)
+			want := sortedIDs(messages, before)
+			if len(got) != len(want) {
+				t.Fatalf("limit=%d before=%v: got %d messages, want %d", limit, before, len(got), len(want))
+			}
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("limit=%d before=%v: message %d is %s, want %s", limit, before, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestMessageCursorCondition(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+	id := uuid.New()
+
+	tests := []struct {
+		name   string
+		cursor *models.MessageCursor
+		before bool
+		where  string
+		args   int
+	}{
+		{"latest page", nil, true, "", 0},
+		{"before message", &models.MessageCursor{CreatedAt: at, ID: id}, true, " AND (created_at, id) < ($3, $4)", 2},
+		{"after message", &models.MessageCursor{CreatedAt: at, ID: id}, false, " AND (created_at, id) > ($3, $4)", 2},
+		{"before timestamp", &models.MessageCursor{CreatedAt: at}, true, " AND created_at < $3", 1},
+		{"after timestamp", &models.MessageCursor{CreatedAt: at}, false, " AND created_at > $3", 1},
+	}
+	for _, tt := range tests {
+		where, args := messageCursorCondition(tt.cursor, tt.before)
+		if where != tt.where || len(args) != tt.args {
+			t.Errorf("%s: %q %v, want %q with %d args", tt.name, where, args, tt.where, tt.args)
+		}
+	}
+}
+
+func TestTimestampCursorExcludesSameInstant(t *testing.T) {
+	convID, messages := sameSecondConversation()
+	p := &PostgresDB{db: sql.OpenDB(&fakeMessagesDB{messages: messages})}
+	defer p.db.Close()
+
+	// Un cursor de solo timestamp excluye su instante: los 2 mensajes de +250µs no aparecen
+	cursor := &models.MessageCursor{CreatedAt: messages[4].CreatedAt}
+	older, err := p.GetConversationMessagesPage(context.Background(), convID, cursor, true, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newer, err := p.GetConversationMessagesPage(context.Background(), convID, cursor, false, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(older) != 4 || len(newer) != 1 {
+		t.Errorf("timestamp cursor returned %d older and %d newer, want 4 and 1", len(older), len(newer))
+	}
+}
+
+func TestMessagePageReturnsScanErrors(t *testing.T) {
+	convID := uuid.New()
+	good := []driver.Value{uuid.NewString(), convID.String(), "user", "hola", nil, nil, false, []byte(nil), time.Now()}
+	bad := []driver.Value{uuid.NewString(), convID.String(), "user", "hola", nil, nil, false, []byte(nil), "not a timestamp"}
+
+	tests := map[string]*fakeMessagesDB{
+		"scan error":  {rows: [][]driver.Value{good, bad, good}},
+		"query error": {rows: [][]driver.Value{good}, rowsErr: errors.New("connection reset")},
+	}
+	for name, fake := range tests {
+		p := &PostgresDB{db: sql.OpenDB(fake)}
+		messages, err := p.GetConversationMessagesPage(context.Background(), convID, nil, true, 10)
+		if err == nil {
+			t.Errorf("%s: got %d messages and no error", name, len(messages))
+		}
+		messages, err = p.GetConversationMessages(context.Background(), convID, 10, 0)
+		if err == nil {
+			t.Errorf("%s (offset): got %d messages and no error", name, len(messages))
+		}
+		p.db.Close()
+	}
+}
@@ -2,22 +2,35 @@ package db
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/api-gateway/internal/models"
+	"github.com/trackfy/pkg/dbtls"
+	"github.com/trackfy/pkg/dbutil"
 )
 
 type PostgresDB struct {
-	db *sql.DB
+	db  *sql.DB
+	dsn string
+	tls dbtls.Config
 }
 
-func NewPostgresDB(databaseURL string, maxConns int) (*PostgresDB, error) {
-	db, err := sql.Open("postgres", databaseURL)
+// NewPostgresDB abre el pool de conexiones. Con certificados en tlsCfg la conexión usa mTLS
+// (sslmode=verify-full) y se comprueba el handshake al arrancar.
+func NewPostgresDB(databaseURL string, maxConns int, tlsCfg dbtls.Config) (*PostgresDB, error) {
+	if err := dbutil.ValidateDatabaseURL(databaseURL); err != nil {
+		return nil, err
+	}
+
+	dsn := tlsCfg.ApplyToDSN(databaseURL)
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -33,8 +46,36 @@ func NewPostgresDB(databaseURL string, maxConns int) (*PostgresDB, error) {
 		return nil, err
 	}
 
-	log.Info().Msg("[PostgresDB] Connected successfully")
-	return &PostgresDB{db: db}, nil
+	p := &PostgresDB{db: db, dsn: dsn, tls: tlsCfg}
+	if tlsCfg.Enabled() {
+		if err := p.ValidateDBTLSConnection(ctx); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+
+	log.Info().Bool("mtls", tlsCfg.Enabled()).Msg("[PostgresDB] Connected successfully")
+	return p, nil
+}
+
+// ValidateDBTLSConnection hace un handshake TLS con el servidor, verifica que el SAN del
+// certificado coincide con el host de la DB y registra la versión y el cipher suite
+func (p *PostgresDB) ValidateDBTLSConnection(ctx context.Context) error {
+	if !p.tls.Enabled() {
+		return fmt.Errorf("database TLS not configured")
+	}
+
+	state, err := p.tls.DialTLS(ctx, p.dsn)
+	if err != nil {
+		return fmt.Errorf("database TLS validation failed: %w", err)
+	}
+
+	log.Info().
+		Str("tls_version", tls.VersionName(state.Version)).
+		Str("cipher_suite", tls.CipherSuiteName(state.CipherSuite)).
+		Str("server_cn", state.PeerCertificates[0].Subject.CommonName).
+		Msg("[PostgresDB] TLS connection verified")
+	return nil
 }
 
 func (p *PostgresDB) Close() error {
@@ -130,6 +171,151 @@ func (p *PostgresDB) VerifyCode(ctx context.Context, phone, code string) (bool,
 	return valid, err
 }
 
+// DeleteUserAccount borra la cuenta (derecho de supresión, RGPD) en una sola transacción:
+// anonimiza la fila del usuario (se conserva el id por las FKs; teléfono, nombre y apellidos
+// a NULL), borra sus códigos de verificación, revoca sus sesiones sin datos del dispositivo,
+// borra conversaciones, mensajes y datos derivados, y deja constancia en audit_log.
+// Retorna las categorías de datos eliminadas (sql.ErrNoRows si ya estaba borrada).
+func (p *PostgresDB) DeleteUserAccount(ctx context.Context, userID uuid.UUID, ipAddress string) ([]string, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Antes de anonimizar: los códigos solo se pueden enlazar por el teléfono
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM verification_codes
+		WHERE phone = (SELECT phone FROM users WHERE id = $1 AND deleted_at IS NULL)
+	`, userID); err != nil {
+		return nil, fmt.Errorf("verification_codes: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE users SET
+			is_active = false,
+			deleted_at = NOW(),
+			phone = NULL,
+			nombre = NULL,
+			apellidos = NULL,
+			last_login = NULL,
+			notifications_enabled = false,
+			is_premium = false,
+			subscription_status = CASE WHEN subscription_status IS NULL THEN NULL ELSE 'canceled' END,
+			subscription_canceled_at = CASE WHEN subscription_status IS NULL OR subscription_status = 'canceled'
+				THEN subscription_canceled_at ELSE NOW() END
+		WHERE id = $1 AND deleted_at IS NULL
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, sql.ErrNoRows
+	}
+
+	steps := []struct {
+		name  string
+		query string
+	}{
+		{"sessions", `UPDATE sessions SET is_active = false, revoked_at = COALESCE(revoked_at, NOW()),
+			revoke_reason = COALESCE(revoke_reason, 'account_deleted'),
+			ip_address = NULL, user_agent = NULL, device_id = NULL, device_name = NULL WHERE user_id = $1`},
+		{"conversations", `DELETE FROM messages WHERE conversation_id IN (SELECT id FROM conversations WHERE user_id = $1)`},
+		{"conversations", `UPDATE conversations SET is_active = false, title = NULL, message_count = 0 WHERE user_id = $1`},
+		{"conversations", `DELETE FROM archived_conversations WHERE user_id = $1`}, // Sus mensajes por CASCADE
+		{"allowlist", `DELETE FROM user_allowlist WHERE user_id = $1`},
+		{"devices", `DELETE FROM user_devices WHERE user_id = $1`},
+		{"analysis_results", `DELETE FROM analysis_results WHERE user_id = $1`},
+		{"entities", `DELETE FROM user_entities WHERE user_id = $1`},
+		{"stats", `DELETE FROM user_stats WHERE user_id = $1`},
+		// El registro contable se conserva, pero ya no se puede enlazar con la cuenta
+		{"payments", `UPDATE payments SET user_id = '` + uuid.Nil.String() + `' WHERE user_id = $1`},
+	}
+
+	var removed []string
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, userID); err != nil {
+			return nil, fmt.Errorf("%s: %w", step.name, err)
+		}
+		if len(removed) == 0 || removed[len(removed)-1] != step.name {
+			removed = append(removed, step.name)
+		}
+	}
+	removed = append([]string{"user"}, removed...)
+
+	details, _ := json.Marshal(map[string]interface{}{"data_removed": removed})
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO audit_log (action, user_id, details, ip_address)
+		VALUES ('account_deleted', $1, $2, NULLIF($3, '')::inet)
+	`, userID, details, ipAddress); err != nil {
+		return nil, fmt.Errorf("audit_log: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return removed, nil
+}
+
+// GetUserConversationIDs conversaciones del usuario (incluidas las archivadas), para purgar
+// su cache en Redis al borrar la cuenta
+func (p *PostgresDB) GetUserConversationIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id FROM conversations WHERE user_id = $1
+		UNION ALL
+		SELECT id FROM archived_conversations WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// UserPIIRemaining comprueba tras el borrado que no queda ningún dato personal del usuario
+// consultable. Retorna las tablas que aún lo tienen (vacío si el borrado fue completo).
+func (p *PostgresDB) UserPIIRemaining(ctx context.Context, userID uuid.UUID) ([]string, error) {
+	checks := []struct {
+		table string
+		query string
+	}{
+		{"users", `SELECT EXISTS(SELECT 1 FROM users WHERE id = $1
+			AND (phone IS NOT NULL OR nombre IS NOT NULL OR apellidos IS NOT NULL OR last_login IS NOT NULL))`},
+		{"sessions", `SELECT EXISTS(SELECT 1 FROM sessions WHERE user_id = $1
+			AND (is_active OR ip_address IS NOT NULL OR user_agent IS NOT NULL OR device_id IS NOT NULL OR device_name IS NOT NULL))`},
+		{"conversations", `SELECT EXISTS(SELECT 1 FROM conversations WHERE user_id = $1 AND title IS NOT NULL)`},
+		{"messages", `SELECT EXISTS(SELECT 1 FROM messages m JOIN conversations c ON c.id = m.conversation_id WHERE c.user_id = $1)`},
+		{"archived_conversations", `SELECT EXISTS(SELECT 1 FROM archived_conversations WHERE user_id = $1)`},
+		{"allowlist", `SELECT EXISTS(SELECT 1 FROM user_allowlist WHERE user_id = $1)`},
+		{"devices", `SELECT EXISTS(SELECT 1 FROM user_devices WHERE user_id = $1)`},
+		{"analysis_results", `SELECT EXISTS(SELECT 1 FROM analysis_results WHERE user_id = $1)`},
+		{"entities", `SELECT EXISTS(SELECT 1 FROM user_entities WHERE user_id = $1)`},
+		{"stats", `SELECT EXISTS(SELECT 1 FROM user_stats WHERE user_id = $1)`},
+		{"payments", `SELECT EXISTS(SELECT 1 FROM payments WHERE user_id = $1)`},
+	}
+
+	var remaining []string
+	for _, check := range checks {
+		var exists bool
+		if err := p.db.QueryRowContext(ctx, check.query, userID).Scan(&exists); err != nil {
+			return nil, fmt.Errorf("%s: %w", check.table, err)
+		}
+		if exists {
+			remaining = append(remaining, check.table)
+		}
+	}
+	return remaining, nil
+}
+
 // ==================== SESSIONS ====================
 
 func (p *PostgresDB) CreateSession(ctx context.Context, session *models.Session, tokenHash []byte) error {
@@ -151,6 +337,17 @@ func (p *PostgresDB) InvalidateSession(ctx context.Context, sessionID uuid.UUID,
 	return err
 }
 
+// GetActiveSessionTokenHash hash del token de una sesión activa del usuario
+// (sql.ErrNoRows si no existe, es de otro usuario o ya no está activa)
+func (p *PostgresDB) GetActiveSessionTokenHash(ctx context.Context, userID, sessionID uuid.UUID) ([]byte, error) {
+	var tokenHash []byte
+	err := p.db.QueryRowContext(ctx, `
+		SELECT token_hash FROM sessions
+		WHERE id = $1 AND user_id = $2 AND is_active = true
+	`, sessionID, userID).Scan(&tokenHash)
+	return tokenHash, err
+}
+
 func (p *PostgresDB) InvalidateAllUserSessions(ctx context.Context, userID uuid.UUID) error {
 	_, err := p.db.ExecContext(ctx, `
 		UPDATE sessions
@@ -229,27 +426,41 @@ func (p *PostgresDB) UpdateConversationTitle(ctx context.Context, conversationID
 	return err
 }
 
-func (p *PostgresDB) GetUserConversations(ctx context.Context, userID uuid.UUID, limit, offset int) ([]models.Conversation, error) {
-	rows, err := p.db.QueryContext(ctx, `
+// conversationListSQL SELECT del listado de conversaciones sobre un par de tablas
+// (conversations/messages o archived_conversations/archived_messages)
+func conversationListSQL(conversations, messages, archivedAt string) string {
+	return `
 		SELECT
 			c.id, c.user_id, c.title, c.created_at, c.updated_at, c.is_active, c.message_count,
 			COALESCE(
-				(SELECT content FROM messages WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1),
+				(SELECT content FROM ` + messages + ` WHERE conversation_id = c.id ORDER BY created_at DESC LIMIT 1),
 				''
 			) as last_message,
 			COALESCE(
-				(SELECT intent FROM messages WHERE conversation_id = c.id AND intent IS NOT NULL AND intent != '' ORDER BY created_at DESC LIMIT 1),
+				(SELECT intent FROM ` + messages + ` WHERE conversation_id = c.id AND intent IS NOT NULL AND intent != '' ORDER BY created_at DESC LIMIT 1),
 				''
 			) as last_intent,
 			COALESCE(
-				(SELECT COUNT(*) > 0 FROM messages WHERE conversation_id = c.id AND mood = 'danger'),
+				(SELECT COUNT(*) > 0 FROM ` + messages + ` WHERE conversation_id = c.id AND mood = 'danger'),
 				false
-			) as has_threats
-		FROM conversations c
-		WHERE c.user_id = $1 AND c.is_active = true
-		ORDER BY c.updated_at DESC
-		LIMIT $2 OFFSET $3
-	`, userID, limit, offset)
+			) as has_threats,
+			` + archivedAt + ` as archived_at
+		FROM ` + conversations + ` c
+		WHERE c.user_id = $1 AND c.is_active = true`
+}
+
+// GetUserConversations conversaciones del usuario, las de actividad más reciente primero.
+// Con includeArchived se mezclan las archivadas (is_archived = true).
+func (p *PostgresDB) GetUserConversations(ctx context.Context, userID uuid.UUID, limit, offset int, includeArchived bool) ([]models.Conversation, error) {
+	query := conversationListSQL("conversations", "messages", "NULL::timestamp")
+	if includeArchived {
+		query += " UNION ALL " + conversationListSQL("archived_conversations", "archived_messages", "c.archived_at")
+	}
+	query += `
+		ORDER BY updated_at DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := p.db.QueryContext(ctx, query, userID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -259,7 +470,8 @@ func (p *PostgresDB) GetUserConversations(ctx context.Context, userID uuid.UUID,
 	for rows.Next() {
 		var c models.Conversation
 		var title, lastMessage, lastIntent sql.NullString
-		if err := rows.Scan(&c.ID, &c.UserID, &title, &c.CreatedAt, &c.UpdatedAt, &c.IsActive, &c.MessageCount, &lastMessage, &lastIntent, &c.HasThreats); err != nil {
+		var archivedAt sql.NullTime
+		if err := rows.Scan(&c.ID, &c.UserID, &title, &c.CreatedAt, &c.UpdatedAt, &c.IsActive, &c.MessageCount, &lastMessage, &lastIntent, &c.HasThreats, &archivedAt); err != nil {
 			continue
 		}
 		if title.Valid {
@@ -271,6 +483,10 @@ func (p *PostgresDB) GetUserConversations(ctx context.Context, userID uuid.UUID,
 		if lastIntent.Valid {
 			c.LastIntent = lastIntent.String
 		}
+		if archivedAt.Valid {
+			c.IsArchived = true
+			c.ArchivedAt = &archivedAt.Time
+		}
 		conversations = append(conversations, c)
 	}
 	return conversations, nil
@@ -310,6 +526,81 @@ func (p *PostgresDB) GetConversationMessages(ctx context.Context, conversationID
 	}
 	defer rows.Close()
 
+	return scanMessages(rows)
+}
+
+// GetMessageCursor posición de un mensaje de la conversación (sql.ErrNoRows si no pertenece a ella)
+func (p *PostgresDB) GetMessageCursor(ctx context.Context, conversationID, messageID uuid.UUID) (*models.MessageCursor, error) {
+	cursor := &models.MessageCursor{ID: messageID}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT created_at FROM messages WHERE id = $1 AND conversation_id = $2
+	`, messageID, conversationID).Scan(&cursor.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return cursor, nil
+}
+
+// GetConversationMessagesPage página de mensajes a un lado del cursor.
+// before=true: anteriores al cursor, de más reciente a más antiguo (sin cursor, la página más reciente);
+// before=false: posteriores al cursor en orden cronológico. Retorna limit+1 filas como máximo
+// para que el llamante sepa si hay más.
+func (p *PostgresDB) GetConversationMessagesPage(ctx context.Context, conversationID uuid.UUID, cursor *models.MessageCursor, before bool, limit int) ([]models.Message, error) {
+	where, args := messageCursorCondition(cursor, before)
+	order := "created_at ASC, id ASC"
+	if before {
+		order = "created_at DESC, id DESC"
+	}
+
+	args = append([]interface{}{conversationID, limit + 1}, args...)
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT id, conversation_id, role, content, intent, mood, analysis_performed, entities_found, created_at
+		FROM messages
+		WHERE conversation_id = $1`+where+`
+		ORDER BY `+order+`
+		LIMIT $2
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanMessages(rows)
+}
+
+// messageCursorCondition condición SQL ($3, $4) para los mensajes a un lado del cursor.
+// Con ID, (created_at, id) se compara como tupla: los mensajes del mismo instante no se
+// repiten ni se pierden entre páginas. Con solo timestamp, el instante del cursor se excluye.
+func messageCursorCondition(cursor *models.MessageCursor, before bool) (string, []interface{}) {
+	if cursor == nil {
+		return "", nil
+	}
+
+	op := ">"
+	if before {
+		op = "<"
+	}
+	if cursor.ID == uuid.Nil {
+		return " AND created_at " + op + " $3", []interface{}{cursor.CreatedAt}
+	}
+	return " AND (created_at, id) " + op + " ($3, $4)", []interface{}{cursor.CreatedAt, cursor.ID}
+}
+
+// GetConversationMessageBounds fecha del mensaje más antiguo y del más reciente (nil si no hay mensajes)
+func (p *PostgresDB) GetConversationMessageBounds(ctx context.Context, conversationID uuid.UUID) (*time.Time, *time.Time, error) {
+	var oldest, newest sql.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT MIN(created_at), MAX(created_at) FROM messages WHERE conversation_id = $1
+	`, conversationID).Scan(&oldest, &newest)
+	if err != nil || !oldest.Valid {
+		return nil, nil, err
+	}
+	return &oldest.Time, &newest.Time, nil
+}
+
+// scanMessages lee las filas de mensajes; un error de Scan o de la consulta se retorna
+// en vez de devolver una página incompleta que el cursor daría por leída
+func scanMessages(rows *sql.Rows) ([]models.Message, error) {
 	var messages []models.Message
 	for rows.Next() {
 		var m models.Message
@@ -317,7 +608,7 @@ func (p *PostgresDB) GetConversationMessages(ctx context.Context, conversationID
 		var entitiesJSON []byte
 		if err := rows.Scan(&m.ID, &m.ConversationID, &m.Role, &m.Content, &intent, &mood,
 			&m.AnalysisPerformed, &entitiesJSON, &m.CreatedAt); err != nil {
-			continue
+			return nil, err
 		}
 		if intent.Valid {
 			m.Intent = intent.String
@@ -330,7 +621,131 @@ func (p *PostgresDB) GetConversationMessages(ctx context.Context, conversationID
 		}
 		messages = append(messages, m)
 	}
-	return messages, nil
+	return messages, rows.Err()
+}
+
+// ==================== ALLOWLIST ====================
+
+func (p *PostgresDB) GetUserAllowlist(ctx context.Context, userID uuid.UUID) ([]models.AllowlistEntry, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT indicator_type, indicator_value, created_at
+		FROM user_allowlist
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.AllowlistEntry
+	for rows.Next() {
+		var e models.AllowlistEntry
+		if err := rows.Scan(&e.IndicatorType, &e.IndicatorValue, &e.CreatedAt); err != nil {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (p *PostgresDB) AddAllowlistEntry(ctx context.Context, userID uuid.UUID, indicatorType, indicatorValue string) (*models.AllowlistEntry, error) {
+	entry := &models.AllowlistEntry{IndicatorType: indicatorType, IndicatorValue: indicatorValue}
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO user_allowlist (user_id, indicator_type, indicator_value)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (user_id, indicator_type, indicator_value) DO UPDATE SET indicator_value = EXCLUDED.indicator_value
+		RETURNING created_at
+	`, userID, indicatorType, indicatorValue).Scan(&entry.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (p *PostgresDB) DeleteAllowlistEntry(ctx context.Context, userID uuid.UUID, indicatorType, indicatorValue string) (bool, error) {
+	result, err := p.db.ExecContext(ctx, `
+		DELETE FROM user_allowlist
+		WHERE user_id = $1 AND indicator_type = $2 AND indicator_value = $3
+	`, userID, indicatorType, indicatorValue)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// ==================== DEVICES ====================
+
+// RegisterDevice registra (o reasigna a la sesión actual) un token push
+func (p *PostgresDB) RegisterDevice(ctx context.Context, userID, sessionID uuid.UUID, token, platform string) (*models.Device, error) {
+	device := &models.Device{Token: token, Platform: platform, SessionID: sessionID}
+	err := p.db.QueryRowContext(ctx, `
+		INSERT INTO user_devices (token, user_id, session_id, platform)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (token) DO UPDATE SET
+			user_id = EXCLUDED.user_id,
+			session_id = EXCLUDED.session_id,
+			platform = EXCLUDED.platform,
+			updated_at = NOW()
+		RETURNING created_at, updated_at
+	`, token, userID, sessionID, platform).Scan(&device.CreatedAt, &device.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+func (p *PostgresDB) DeleteDevice(ctx context.Context, userID uuid.UUID, token string) (bool, error) {
+	result, err := p.db.ExecContext(ctx, `
+		DELETE FROM user_devices WHERE user_id = $1 AND token = $2
+	`, userID, token)
+	if err != nil {
+		return false, err
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteDeviceTokens elimina tokens que el proveedor push ha rechazado
+func (p *PostgresDB) DeleteDeviceTokens(ctx context.Context, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+	_, err := p.db.ExecContext(ctx, `DELETE FROM user_devices WHERE token = ANY($1)`, pq.Array(tokens))
+	return err
+}
+
+// GetAlertRecipients dispositivos de los usuarios que analizaron el input desde since.
+// Solo usuarios activos con notificaciones habilitadas y tokens de sesiones activas.
+func (p *PostgresDB) GetAlertRecipients(ctx context.Context, inputHash []byte, since time.Time) ([]models.AlertRecipient, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT DISTINCT ON (d.token) d.user_id, d.token, d.platform, a.entity_type, a.entity_value, COALESCE(u.language, 'es')
+		FROM analysis_results a
+		JOIN users u ON u.id = a.user_id
+		JOIN user_devices d ON d.user_id = a.user_id
+		JOIN sessions s ON s.id = d.session_id
+		WHERE a.input_hash = $1
+		  AND a.created_at >= $2
+		  AND u.notifications_enabled = true
+		  AND u.deleted_at IS NULL
+		  AND s.is_active = true
+		ORDER BY d.token, a.created_at DESC
+	`, inputHash, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var recipients []models.AlertRecipient
+	for rows.Next() {
+		var r models.AlertRecipient
+		if err := rows.Scan(&r.UserID, &r.Token, &r.Platform, &r.EntityType, &r.EntityValue, &r.Language); err != nil {
+			continue
+		}
+		recipients = append(recipients, r)
+	}
+	return recipients, rows.Err()
 }
 
 // ==================== STATS ====================
@@ -353,13 +768,217 @@ func (p *PostgresDB) GetUserStats(ctx context.Context, userID uuid.UUID) (*model
 	stats := &models.UserStats{}
 	err := p.db.QueryRowContext(ctx, `
 		SELECT user_id, total_messages, total_analyses, threats_detected, safe_verified,
-			   urls_analyzed, emails_analyzed, phones_analyzed, updated_at
+			   urls_analyzed, emails_analyzed, phones_analyzed,
+			   (SELECT COUNT(*) FROM conversations WHERE user_id = $1 AND is_active = true),
+			   (SELECT COUNT(*) FROM archived_conversations WHERE user_id = $1),
+			   updated_at
 		FROM user_stats
 		WHERE user_id = $1
 	`, userID).Scan(
 		&stats.UserID, &stats.TotalMessages, &stats.TotalAnalyses, &stats.ThreatsDetected,
 		&stats.SafeVerified, &stats.URLsAnalyzed, &stats.EmailsAnalyzed, &stats.PhonesAnalyzed,
-		&stats.UpdatedAt,
+		&stats.Conversations, &stats.ArchivedConversations, &stats.UpdatedAt,
 	)
 	return stats, err
 }
+
+// ==================== ANALYSIS RESULTS ====================
+
+// threatVerdictsSQL veredictos que cuentan como amenaza en los informes
+const threatVerdictsSQL = `verdict IN ('suspicious', 'dangerous')`
+
+// RecordAnalysisResult registra un análisis del chat (fuente de los informes mensuales y de analytics).
+// checkers son los que dieron positivo (vacío = ninguno).
+func (p *PostgresDB) RecordAnalysisResult(ctx context.Context, userID uuid.UUID, entityType, entityValue, domain string, riskScore int, verdict string, checkers []string) error {
+	if verdict == "" {
+		verdict = "unknown"
+	}
+	if checkers == nil {
+		checkers = []string{}
+	}
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO analysis_results (user_id, entity_type, entity_value, input_hash, domain, risk_score, verdict, checkers)
+		VALUES ($1, $2, $3, digest(lower($3), 'sha256'), NULLIF($4, ''), $5, $6, $7)
+	`, userID, entityType, entityValue, domain, riskScore, verdict, pq.Array(checkers))
+	return err
+}
+
+// IsUserPremium indica si el usuario tiene acceso premium (ver models.Subscription.Entitled)
+func (p *PostgresDB) IsUserPremium(ctx context.Context, userID uuid.UUID) (bool, error) {
+	sub, err := p.GetSubscriptionStatus(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return sub.Entitled(), nil
+}
+
+// GetSubscriptionStatus estado de la suscripción del usuario
+func (p *PostgresDB) GetSubscriptionStatus(ctx context.Context, userID uuid.UUID) (*models.Subscription, error) {
+	sub := &models.Subscription{}
+	var periodEnd sql.NullTime
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COALESCE(subscription_status, ''), subscription_period_end,
+		       COALESCE(subscription_cancel_at_period_end, false), COALESCE(is_premium, false)
+		FROM users WHERE id = $1
+	`, userID).Scan(&sub.Status, &periodEnd, &sub.CancelAtPeriodEnd, &sub.LegacyPremium)
+	if err != nil {
+		return nil, err
+	}
+	if periodEnd.Valid {
+		t := periodEnd.Time
+		sub.CurrentPeriodEnd = &t
+	}
+	return sub, nil
+}
+
+// UpdateSubscription guarda el estado de la suscripción de Stripe (customer.subscription.*).
+// is_premium se mantiene sincronizado para quien aún lo lea directamente. started_at y
+// canceled_at (estadísticas de fy-admin) marcan la primera alta y la cancelación pedida;
+// una suscripción reactivada borra canceled_at.
+func (p *PostgresDB) UpdateSubscription(ctx context.Context, userID uuid.UUID, sub *models.Subscription) error {
+	_, err := p.db.ExecContext(ctx, `
+		UPDATE users SET
+			subscription_status = NULLIF($2, ''),
+			subscription_period_end = $3,
+			subscription_cancel_at_period_end = $4,
+			is_premium = $5,
+			subscription_started_at = CASE WHEN $2 IN ('trialing', 'active')
+				THEN COALESCE(subscription_started_at, NOW()) ELSE subscription_started_at END,
+			subscription_canceled_at = CASE WHEN $2 = 'canceled' OR $4
+				THEN COALESCE(subscription_canceled_at, NOW()) ELSE NULL END,
+			updated_at = NOW()
+		WHERE id = $1
+	`, userID, sub.Status, sub.CurrentPeriodEnd, sub.CancelAtPeriodEnd, sub.Entitled())
+	return err
+}
+
+// RecordPayment registra un cobro de Stripe (invoice.paid / invoice.payment_failed).
+// Idempotente: Stripe reenvía los webhooks.
+func (p *PostgresDB) RecordPayment(ctx context.Context, userID uuid.UUID, invoiceID string, amountCents int64, currency, status string) error {
+	_, err := p.db.ExecContext(ctx, `
+		INSERT INTO payments (user_id, stripe_invoice_id, amount_cents, currency, status)
+		VALUES ($1, $2, $3, lower($4), $5)
+		ON CONFLICT (stripe_invoice_id, status) DO NOTHING
+	`, userID, invoiceID, amountCents, currency, status)
+	return err
+}
+
+// GetAnalysisTotals cuenta los análisis y amenazas del usuario en [from, to)
+func (p *PostgresDB) GetAnalysisTotals(ctx context.Context, userID uuid.UUID, from, to time.Time) (int, int, error) {
+	var total, threats int
+	err := p.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE `+threatVerdictsSQL+`)
+		FROM analysis_results
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+	`, userID, from, to).Scan(&total, &threats)
+	return total, threats, err
+}
+
+// GetMonthlyAnalysisReport agrega los análisis del usuario en [from, to): totales, desglose
+// por tipo y nivel de riesgo, top 5 de dominios con amenazas y análisis por día
+func (p *PostgresDB) GetMonthlyAnalysisReport(ctx context.Context, userID uuid.UUID, from, to time.Time) (*models.MonthlyReport, error) {
+	report := &models.MonthlyReport{
+		Year:        from.Year(),
+		Month:       int(from.Month()),
+		ByType:      make(map[string]int),
+		ByRiskLevel: make(map[string]int),
+		TopDomains:  []models.DomainCount{},
+	}
+
+	// Desglose por tipo, veredicto y día en una sola pasada
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT entity_type, verdict, to_char(created_at, 'YYYY-MM-DD'), COUNT(*)
+		FROM analysis_results
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY 1, 2, 3
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	daily := make(map[string]*models.DailyCount)
+	for rows.Next() {
+		var entityType, verdict, day string
+		var count int
+		if err := rows.Scan(&entityType, &verdict, &day, &count); err != nil {
+			return nil, err
+		}
+		report.TotalAnalyses += count
+		report.ByType[entityType] += count
+		report.ByRiskLevel[verdict] += count
+
+		d, ok := daily[day]
+		if !ok {
+			d = &models.DailyCount{Date: day}
+			daily[day] = d
+		}
+		d.Analyses += count
+		if verdict == "suspicious" || verdict == "dangerous" {
+			report.ThreatsFound += count
+			d.Threats += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Serie completa del mes (los días sin análisis van a 0 para la gráfica)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format("2006-01-02")
+		if d, ok := daily[key]; ok {
+			report.Timeline = append(report.Timeline, *d)
+		} else {
+			report.Timeline = append(report.Timeline, models.DailyCount{Date: key})
+		}
+	}
+
+	domainRows, err := p.db.QueryContext(ctx, `
+		SELECT domain, COUNT(*)
+		FROM analysis_results
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		  AND domain IS NOT NULL AND `+threatVerdictsSQL+`
+		GROUP BY domain
+		ORDER BY COUNT(*) DESC, domain
+		LIMIT 5
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer domainRows.Close()
+
+	for domainRows.Next() {
+		var dc models.DomainCount
+		if err := domainRows.Scan(&dc.Domain, &dc.Count); err != nil {
+			return nil, err
+		}
+		report.TopDomains = append(report.TopDomains, dc)
+	}
+
+	return report, domainRows.Err()
+}
+
+// GetAnalysisMonths meses (desde since) en los que el usuario tiene análisis, del más reciente al más antiguo
+func (p *PostgresDB) GetAnalysisMonths(ctx context.Context, userID uuid.UUID, since time.Time) ([]models.ReportMonth, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT EXTRACT(YEAR FROM created_at)::int, EXTRACT(MONTH FROM created_at)::int, COUNT(*)
+		FROM analysis_results
+		WHERE user_id = $1 AND created_at >= $2
+		GROUP BY 1, 2
+		ORDER BY 1 DESC, 2 DESC
+	`, userID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	months := []models.ReportMonth{}
+	for rows.Next() {
+		var m models.ReportMonth
+		if err := rows.Scan(&m.Year, &m.Month, &m.TotalAnalyses); err != nil {
+			return nil, err
+		}
+		months = append(months, m)
+	}
+	return months, rows.Err()
+}
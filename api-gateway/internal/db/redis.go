@@ -23,8 +23,15 @@ const (
 	PrefixRateLimit    = "rate_limit:"
 	PrefixConvCache    = "conv_cache:"
 	PrefixUserCache    = "user_cache:"
+	PrefixReportCache  = "monthly_report:"
+	PrefixNonce        = "sig_nonce:"
+
+	PrefixAccountDeletion = "account_deletion:"
 )
 
+// monthlyReportTTL tiempo que se cachea un informe mensual generado
+const monthlyReportTTL = time.Hour
+
 func NewRedisDB(url, password string, db int) (*RedisDB, error) {
 	client := redis.NewClient(&redis.Options{
 		Addr:     url,
@@ -39,6 +46,8 @@ func NewRedisDB(url, password string, db int) (*RedisDB, error) {
 		return nil, err
 	}
 
+	client.AddHook(newTracingHook())
+
 	log.Info().Str("addr", url).Msg("[RedisDB] Connected successfully")
 	return &RedisDB{client: client}, nil
 }
@@ -147,6 +156,173 @@ func (r *RedisDB) CheckRateLimit(ctx context.Context, key string, limit int, win
 	return count <= limit, count, nil
 }
 
+// UseNonce marca el nonce de una petición firmada; retorna false si ya se había usado
+func (r *RedisDB) UseNonce(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, PrefixNonce+nonce, 1, ttl).Result()
+}
+
+// PurgeUserData borra todas las claves del usuario: sesiones, cache de perfil,
+// memoria corta de Fy, informes mensuales y cache de sus conversaciones
+func (r *RedisDB) PurgeUserData(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) error {
+	if err := r.DeleteAllUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	keys, err := r.userDataKeys(ctx, userID, conversationIDs)
+	if err != nil {
+		return err
+	}
+	return r.client.Del(ctx, keys...).Err()
+}
+
+// UserDataRemaining claves del usuario que siguen existiendo tras PurgeUserData
+func (r *RedisDB) UserDataRemaining(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) ([]string, error) {
+	keys, err := r.userDataKeys(ctx, userID, conversationIDs)
+	if err != nil {
+		return nil, err
+	}
+	keys = append(keys, PrefixUserSessions+userID.String())
+
+	var remaining []string
+	for _, key := range keys {
+		n, err := r.client.Exists(ctx, key).Result()
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			remaining = append(remaining, key)
+		}
+	}
+	return remaining, nil
+}
+
+// userDataKeys claves con datos del usuario (las de sesión las gestiona DeleteAllUserSessions)
+func (r *RedisDB) userDataKeys(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) ([]string, error) {
+	keys := []string{PrefixUserCache + userID.String()}
+	for _, convID := range conversationIDs {
+		keys = append(keys, PrefixConvCache+convID.String())
+	}
+
+	for _, pattern := range []string{
+		"fy_memory:" + userID.String() + ":*",
+		PrefixReportCache + userID.String() + ":*",
+	} {
+		iter := r.client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// ==================== BORRADO DE CUENTA ====================
+
+// accountDeletionJobTTL tiempo que se puede consultar el estado de un borrado
+const accountDeletionJobTTL = 30 * 24 * time.Hour
+
+const (
+	keyAccountDeletionQueue      = "account_deletion:queue"
+	keyAccountDeletionProcessing = "account_deletion:processing"
+)
+
+// EnqueueAccountDeletion encola el borrado si el usuario no tiene ya uno. Retorna el job
+// encolado o el existente (created=false).
+func (r *RedisDB) EnqueueAccountDeletion(ctx context.Context, job *models.AccountDeletionJob) (*models.AccountDeletionJob, bool, error) {
+	userKey := PrefixAccountDeletion + "user:" + job.UserID.String()
+	ok, err := r.client.SetNX(ctx, userKey, job.ID.String(), accountDeletionJobTTL).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		existingID, err := r.client.Get(ctx, userKey).Result()
+		if err != nil {
+			return nil, false, err
+		}
+		id, err := uuid.Parse(existingID)
+		if err != nil {
+			return nil, false, err
+		}
+		existing, err := r.GetAccountDeletionJob(ctx, id)
+		if err != nil || existing == nil {
+			return nil, false, fmt.Errorf("account deletion job %s not found", existingID)
+		}
+		return existing, false, nil
+	}
+
+	if err := r.SaveAccountDeletionJob(ctx, job); err != nil {
+		r.client.Del(ctx, userKey)
+		return nil, false, err
+	}
+	if err := r.client.LPush(ctx, keyAccountDeletionQueue, job.ID.String()).Err(); err != nil {
+		r.client.Del(ctx, userKey)
+		return nil, false, err
+	}
+	return job, true, nil
+}
+
+// SaveAccountDeletionJob guarda el estado del job
+func (r *RedisDB) SaveAccountDeletionJob(ctx context.Context, job *models.AccountDeletionJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, PrefixAccountDeletion+job.ID.String(), data, accountDeletionJobTTL).Err()
+}
+
+// GetAccountDeletionJob estado de un job (nil si no existe o ha expirado)
+func (r *RedisDB) GetAccountDeletionJob(ctx context.Context, jobID uuid.UUID) (*models.AccountDeletionJob, error) {
+	data, err := r.client.Get(ctx, PrefixAccountDeletion+jobID.String()).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var job models.AccountDeletionJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// NextAccountDeletion espera hasta timeout el siguiente job y lo mueve a la lista de
+// procesamiento (uuid.Nil si no hay ninguno). Hay que confirmarlo con AckAccountDeletion.
+func (r *RedisDB) NextAccountDeletion(ctx context.Context, timeout time.Duration) (uuid.UUID, error) {
+	id, err := r.client.BLMove(ctx, keyAccountDeletionQueue, keyAccountDeletionProcessing, "RIGHT", "LEFT", timeout).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return uuid.Nil, nil
+		}
+		return uuid.Nil, err
+	}
+	return uuid.Parse(id)
+}
+
+// AckAccountDeletion saca el job de la lista de procesamiento
+func (r *RedisDB) AckAccountDeletion(ctx context.Context, jobID uuid.UUID) error {
+	return r.client.LRem(ctx, keyAccountDeletionProcessing, 0, jobID.String()).Err()
+}
+
+// RequeueStalledAccountDeletions devuelve a la cola los jobs que quedaron a medias
+// (el proceso se detuvo mientras los ejecutaba). Retorna cuántos se reencolaron.
+func (r *RedisDB) RequeueStalledAccountDeletions(ctx context.Context) (int, error) {
+	requeued := 0
+	for {
+		err := r.client.LMove(ctx, keyAccountDeletionProcessing, keyAccountDeletionQueue, "RIGHT", "RIGHT").Err()
+		if err == redis.Nil {
+			return requeued, nil
+		}
+		if err != nil {
+			return requeued, err
+		}
+		requeued++
+	}
+}
+
 // ==================== CONVERSATION CACHE ====================
 
 type ConversationCache struct {
@@ -215,6 +391,35 @@ func (r *RedisDB) GetCachedUser(ctx context.Context, userID uuid.UUID) (*models.
 	return &user, nil
 }
 
+// ==================== INFORMES MENSUALES ====================
+
+func (r *RedisDB) CacheMonthlyReport(ctx context.Context, userID uuid.UUID, year, month int, report *models.MonthlyReport) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%s:%04d-%02d", PrefixReportCache, userID.String(), year, month)
+	return r.client.Set(ctx, key, data, monthlyReportTTL).Err()
+}
+
+func (r *RedisDB) GetCachedMonthlyReport(ctx context.Context, userID uuid.UUID, year, month int) (*models.MonthlyReport, error) {
+	key := fmt.Sprintf("%s%s:%04d-%02d", PrefixReportCache, userID.String(), year, month)
+	data, err := r.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var report models.MonthlyReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+
+	return &report, nil
+}
+
 // ==================== MEMORIA CORTA FY ====================
 
 // FyMemory almacena el contexto corto de la conversación para Fy
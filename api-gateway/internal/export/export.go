@@ -0,0 +1,308 @@
+// Package export genera la copia del historial de chat del usuario: una conversación en
+// JSON o en texto legible (para imprimir o adjuntar a una denuncia) y el archivo completo
+// de la cuenta en JSON. Todo se escribe en streaming, mensaje a mensaje.
+package export
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// ErrForeignData la fuente devolvió una conversación o un mensaje que no pertenece al
+// usuario o a la conversación que se exporta. La exportación se aborta sin escribirlo.
+var ErrForeignData = errors.New("export: data from another user or conversation")
+
+// Store datos que se exportan. Las implementaciones filtran por usuario; el writer lo
+// vuelve a comprobar en cada conversación y mensaje.
+type Store interface {
+	GetExportConversations(ctx context.Context, userID uuid.UUID) ([]models.Conversation, error)
+	ForEachExportMessage(ctx context.Context, userID, conversationID uuid.UUID, fn func(models.Message) error) error
+}
+
+// Options idioma (es, en) y zona horaria de las fechas
+type Options struct {
+	Language string
+	Location *time.Location
+}
+
+func (o Options) location() *time.Location {
+	if o.Location == nil {
+		return time.UTC
+	}
+	return o.Location
+}
+
+// ConversationExport cabecera de la exportación JSON de una conversación
+type ConversationExport struct {
+	ExportedAt   time.Time           `json:"exported_at"`
+	Timezone     string              `json:"timezone"`
+	Conversation models.Conversation `json:"conversation"`
+	Messages     []models.Message    `json:"messages"`
+}
+
+// UserArchive cabecera de la exportación JSON de la cuenta
+type UserArchive struct {
+	ExportedAt    time.Time            `json:"exported_at"`
+	Timezone      string               `json:"timezone"`
+	Profile       *models.User         `json:"profile"`
+	Stats         *models.UserStats    `json:"stats,omitempty"`
+	Conversations []ConversationExport `json:"conversations"`
+}
+
+// checkConversation la conversación es del usuario
+func checkConversation(userID uuid.UUID, conv models.Conversation) error {
+	if conv.UserID != userID {
+		return fmt.Errorf("%w: conversation %s", ErrForeignData, conv.ID)
+	}
+	return nil
+}
+
+// forEachMessage recorre los mensajes de la conversación comprobando que sean suyos
+func forEachMessage(ctx context.Context, store Store, userID uuid.UUID, conv models.Conversation, fn func(models.Message) error) error {
+	return store.ForEachExportMessage(ctx, userID, conv.ID, func(m models.Message) error {
+		if m.ConversationID != conv.ID {
+			return fmt.Errorf("%w: message %s", ErrForeignData, m.ID)
+		}
+		return fn(m)
+	})
+}
+
+// WriteConversationJSON escribe la conversación con sus mensajes como ConversationExport
+func WriteConversationJSON(ctx context.Context, w io.Writer, store Store, userID uuid.UUID, conv models.Conversation, opts Options) error {
+	if err := checkConversation(userID, conv); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := writeConversationJSON(ctx, bw, store, userID, conv, opts); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeConversationJSON {"exported_at":..., "conversation":..., "messages":[...]} con los
+// mensajes codificados uno a uno
+func writeConversationJSON(ctx context.Context, w *bufio.Writer, store Store, userID uuid.UUID, conv models.Conversation, opts Options) error {
+	loc := opts.location()
+	conv.CreatedAt = conv.CreatedAt.In(loc)
+	conv.UpdatedAt = conv.UpdatedAt.In(loc)
+	if conv.ArchivedAt != nil {
+		archivedAt := conv.ArchivedAt.In(loc)
+		conv.ArchivedAt = &archivedAt
+	}
+
+	header, err := json.Marshal(ConversationExport{ExportedAt: time.Now().In(loc), Timezone: loc.String(), Conversation: conv})
+	if err != nil {
+		return err
+	}
+	// Se abre el objeto sin "messages":null} y se escriben los mensajes a continuación
+	w.Write(header[:len(header)-len(`"messages":null}`)])
+	w.WriteString(`"messages":[`)
+
+	first := true
+	err = forEachMessage(ctx, store, userID, conv, func(m models.Message) error {
+		m.CreatedAt = m.CreatedAt.In(loc)
+		data, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		if !first {
+			w.WriteByte(',')
+		}
+		first = false
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.WriteString("]}")
+	return err
+}
+
+// WriteUserArchive escribe el archivo completo de la cuenta (UserArchive): perfil,
+// estadísticas y todas las conversaciones, activas y archivadas
+func WriteUserArchive(ctx context.Context, w io.Writer, store Store, user *models.User, stats *models.UserStats, opts Options) error {
+	conversations, err := store.GetExportConversations(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	for _, conv := range conversations {
+		if err := checkConversation(user.ID, conv); err != nil {
+			return err
+		}
+	}
+
+	loc := opts.location()
+	header, err := json.Marshal(UserArchive{ExportedAt: time.Now().In(loc), Timezone: loc.String(), Profile: user, Stats: stats})
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header[:len(header)-len(`"conversations":null}`)])
+	bw.WriteString(`"conversations":[`)
+	for i, conv := range conversations {
+		if i > 0 {
+			bw.WriteByte(',')
+		}
+		if err := writeConversationJSON(ctx, bw, store, user.ID, conv, opts); err != nil {
+			return err
+		}
+		// Se vacía por conversación: el cliente recibe el archivo según se genera
+		if err := bw.Flush(); err != nil {
+			return err
+		}
+	}
+	bw.WriteString("]}")
+	return bw.Flush()
+}
+
+// entitySummary veredicto de una entidad guardado en Message.EntitiesFound
+type entitySummary struct {
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Verdict   string `json:"verdict"`
+	RiskScore *int   `json:"risk_score"`
+}
+
+// messageVerdicts entidades analizadas del mensaje: "entities" en los del usuario,
+// "verdicts" en las respuestas de Fy con análisis del gateway
+func messageVerdicts(found map[string]interface{}) []entitySummary {
+	if len(found) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(found)
+	if err != nil {
+		return nil
+	}
+	var parsed struct {
+		Entities []entitySummary `json:"entities"`
+		Verdicts []entitySummary `json:"verdicts"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil
+	}
+
+	var verdicts []entitySummary
+	for _, e := range append(parsed.Entities, parsed.Verdicts...) {
+		if e.Verdict != "" && e.Verdict != "unknown" {
+			verdicts = append(verdicts, e)
+		}
+	}
+	return verdicts
+}
+
+// WriteConversationText escribe la conversación como texto legible con las fechas en la
+// zona y el idioma del usuario y el veredicto de cada enlace, email o teléfono analizado
+func WriteConversationText(ctx context.Context, w io.Writer, store Store, userID uuid.UUID, conv models.Conversation, opts Options) error {
+	if err := checkConversation(userID, conv); err != nil {
+		return err
+	}
+
+	t := textsFor(opts.Language)
+	loc := opts.location()
+	bw := bufio.NewWriter(w)
+
+	title := conv.Title
+	if title == "" {
+		title = t.untitled
+	}
+	fmt.Fprintf(bw, "%s: %s\n", t.conversation, title)
+	fmt.Fprintf(bw, "%s: %s\n", t.started, formatTime(conv.CreatedAt, loc, t))
+	fmt.Fprintf(bw, "%s: %s (%s)\n", t.exported, formatTime(time.Now(), loc, t), loc.String())
+	bw.WriteString(strings.Repeat("=", 60) + "\n")
+
+	// Veredictos ya mostrados bajo el mensaje del usuario (la respuesta de Fy suele repetirlos)
+	shown := make(map[string]bool)
+	err := forEachMessage(ctx, store, userID, conv, func(m models.Message) error {
+		author := t.fy
+		if m.Role == "user" {
+			author = t.you
+			shown = make(map[string]bool)
+		}
+		fmt.Fprintf(bw, "\n[%s] %s:\n", formatTime(m.CreatedAt, loc, t), author)
+		for _, line := range strings.Split(m.Content, "\n") {
+			fmt.Fprintf(bw, "  %s\n", line)
+		}
+
+		for _, v := range messageVerdicts(m.EntitiesFound) {
+			key := v.Type + ":" + strings.ToLower(v.Value)
+			if shown[key] {
+				continue
+			}
+			shown[key] = true
+			fmt.Fprintf(bw, "  > %s %s: %s", t.entityLabel(v.Type), v.Value, t.verdictLabel(v.Verdict))
+			if v.RiskScore != nil {
+				fmt.Fprintf(bw, " (%s %d/100)", t.risk, *v.RiskScore)
+			}
+			bw.WriteString("\n")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return bw.Flush()
+}
+
+// texts etiquetas del export de texto en un idioma
+type texts struct {
+	conversation, untitled, started, exported string
+	you, fy, risk                             string
+	dateLayout                                string
+	entities                                  map[string]string
+	verdicts                                  map[string]string
+}
+
+var (
+	textsES = texts{
+		conversation: "Conversación", untitled: "Sin título", started: "Iniciada", exported: "Exportada",
+		you: "Tú", fy: "Fy", risk: "riesgo",
+		dateLayout: "02/01/2006 15:04",
+		entities:   map[string]string{"url": "Enlace", "email": "Email", "phone": "Teléfono"},
+		verdicts:   map[string]string{"safe": "seguro", "suspicious": "sospechoso", "dangerous": "peligroso"},
+	}
+	textsEN = texts{
+		conversation: "Conversation", untitled: "Untitled", started: "Started", exported: "Exported",
+		you: "You", fy: "Fy", risk: "risk",
+		dateLayout: "2006-01-02 15:04",
+		entities:   map[string]string{"url": "Link", "email": "Email", "phone": "Phone"},
+		verdicts:   map[string]string{"safe": "safe", "suspicious": "suspicious", "dangerous": "dangerous"},
+	}
+)
+
+// textsFor etiquetas en el idioma del usuario (español por defecto)
+func textsFor(language string) texts {
+	if language == "en" {
+		return textsEN
+	}
+	return textsES
+}
+
+func (t texts) entityLabel(entityType string) string {
+	if label, ok := t.entities[entityType]; ok {
+		return label
+	}
+	return entityType
+}
+
+func (t texts) verdictLabel(verdict string) string {
+	if label, ok := t.verdicts[verdict]; ok {
+		return label
+	}
+	return verdict
+}
+
+func formatTime(ts time.Time, loc *time.Location, t texts) string {
+	return ts.In(loc).Format(t.dateLayout)
+}
@@ -0,0 +1,234 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Europe/Madrid aunque el sistema no tenga zoneinfo
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// Los writers contra una fuente en memoria que mezcla datos de otro usuario (conversaciones y
+// mensajes ajenos): nunca aparecen en la exportación. Además, formato JSON y texto localizado.
+
+// foreignMarker contenido de los datos del otro usuario: no debe aparecer en ninguna salida
+const foreignMarker = "FOREIGN-SECRET"
+
+var (
+	ownerID     = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	strangerID  = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	exportStart = time.Date(2026, 3, 29, 0, 30, 0, 0, time.UTC) // Cambio a horario de verano en Madrid
+)
+
+// fakeStore devuelve lo que tenga cargado sin filtrar por usuario (una consulta con el
+// WHERE roto): el filtro de los writers es lo único que separa los datos
+type fakeStore struct {
+	conversations []models.Conversation
+	messages      map[uuid.UUID][]models.Message
+}
+
+func (s *fakeStore) GetExportConversations(ctx context.Context, userID uuid.UUID) ([]models.Conversation, error) {
+	return s.conversations, nil
+}
+
+func (s *fakeStore) ForEachExportMessage(ctx context.Context, userID, conversationID uuid.UUID, fn func(models.Message) error) error {
+	for _, m := range s.messages[conversationID] {
+		if err := fn(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newConversation(userID uuid.UUID, title string) models.Conversation {
+	return models.Conversation{ID: uuid.New(), UserID: userID, Title: title, CreatedAt: exportStart, UpdatedAt: exportStart, IsActive: true}
+}
+
+func newMessage(conversationID uuid.UUID, role, content string, offset time.Duration, entities map[string]interface{}) models.Message {
+	return models.Message{ID: uuid.New(), ConversationID: conversationID, Role: role, Content: content,
+		EntitiesFound: entities, CreatedAt: exportStart.Add(offset)}
+}
+
+// ownerStore conversación del usuario con un enlace analizado (en el mensaje y en la respuesta)
+func ownerStore() (*fakeStore, models.Conversation) {
+	conv := newConversation(ownerID, "SMS de Correos")
+	store := &fakeStore{
+		conversations: []models.Conversation{conv},
+		messages: map[uuid.UUID][]models.Message{conv.ID: {
+			newMessage(conv.ID, "user", "Me ha llegado esto: correos-entrega.xyz/pago", 0, map[string]interface{}{
+				"entities": []interface{}{map[string]interface{}{"type": "url", "value": "correos-entrega.xyz/pago", "verdict": "dangerous", "risk_score": 92}},
+			}),
+			newMessage(conv.ID, "assistant", "No lo abras, es phishing.", 2*time.Hour, map[string]interface{}{
+				"verdicts": []interface{}{map[string]interface{}{"type": "url", "value": "correos-entrega.xyz/pago", "verdict": "dangerous", "risk_score": 92}},
+			}),
+		}},
+	}
+	return store, conv
+}
+
+// exportScenarios escenarios de exportación; run devuelve el error si la salida no es la esperada
+var exportScenarios = []struct {
+	name string
+	run  func() error
+}{
+	{"conversation JSON is valid and complete", func() error {
+		store, conv := ownerStore()
+		var buf bytes.Buffer
+		if err := WriteConversationJSON(context.Background(), &buf, store, ownerID, conv, Options{}); err != nil {
+			return err
+		}
+		var out ConversationExport
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if out.Conversation.ID != conv.ID || len(out.Messages) != 2 {
+			return fmt.Errorf("got conversation %s with %d messages", out.Conversation.ID, len(out.Messages))
+		}
+		return nil
+	}},
+	{"conversation JSON without messages", func() error {
+		conv := newConversation(ownerID, "")
+		var buf bytes.Buffer
+		if err := WriteConversationJSON(context.Background(), &buf, &fakeStore{}, ownerID, conv, Options{}); err != nil {
+			return err
+		}
+		var out ConversationExport
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if out.Messages == nil || len(out.Messages) != 0 {
+			return fmt.Errorf("messages = %v, want []", out.Messages)
+		}
+		return nil
+	}},
+	{"text uses the user's language and time zone", func() error {
+		store, conv := ownerStore()
+		madrid, err := time.LoadLocation("Europe/Madrid")
+		if err != nil {
+			return err
+		}
+		var buf bytes.Buffer
+		if err := WriteConversationText(context.Background(), &buf, store, ownerID, conv, Options{Language: "es", Location: madrid}); err != nil {
+			return err
+		}
+		text := buf.String()
+		// 00:30 UTC es la 01:30 en Madrid (CET); dos horas después ya es horario de verano (04:30)
+		for _, want := range []string{"[29/03/2026 01:30] Tú:", "[29/03/2026 04:30] Fy:", "Enlace correos-entrega.xyz/pago: peligroso (riesgo 92/100)"} {
+			if !strings.Contains(text, want) {
+				return fmt.Errorf("missing %q in:\n%s", want, text)
+			}
+		}
+		if n := strings.Count(text, "> Enlace"); n != 1 {
+			return fmt.Errorf("verdict printed %d times, want 1 (user message and reply)", n)
+		}
+
+		buf.Reset()
+		if err := WriteConversationText(context.Background(), &buf, store, ownerID, conv, Options{Language: "en"}); err != nil {
+			return err
+		}
+		if !strings.Contains(buf.String(), "[2026-03-29 00:30] You:") || !strings.Contains(buf.String(), "Link correos-entrega.xyz/pago: dangerous") {
+			return fmt.Errorf("english export:\n%s", buf.String())
+		}
+		return nil
+	}},
+	{"another user's conversation is rejected", func() error {
+		conv := newConversation(strangerID, foreignMarker)
+		store := &fakeStore{messages: map[uuid.UUID][]models.Message{conv.ID: {newMessage(conv.ID, "user", foreignMarker, 0, nil)}}}
+		for name, write := range map[string]func(*bytes.Buffer) error{
+			"json": func(buf *bytes.Buffer) error {
+				return WriteConversationJSON(context.Background(), buf, store, ownerID, conv, Options{})
+			},
+			"text": func(buf *bytes.Buffer) error {
+				return WriteConversationText(context.Background(), buf, store, ownerID, conv, Options{})
+			},
+		} {
+			var buf bytes.Buffer
+			if err := write(&buf); !errors.Is(err, ErrForeignData) {
+				return fmt.Errorf("%s: err = %v, want ErrForeignData", name, err)
+			}
+			if strings.Contains(buf.String(), foreignMarker) {
+				return fmt.Errorf("%s: foreign data in output: %s", name, buf.String())
+			}
+		}
+		return nil
+	}},
+	{"message from another conversation is never written", func() error {
+		store, conv := ownerStore()
+		foreign := newConversation(strangerID, "")
+		store.messages[conv.ID] = append(store.messages[conv.ID], newMessage(foreign.ID, "user", foreignMarker, 3*time.Hour, nil))
+		for name, write := range map[string]func(*bytes.Buffer) error{
+			"json": func(buf *bytes.Buffer) error {
+				return WriteConversationJSON(context.Background(), buf, store, ownerID, conv, Options{})
+			},
+			"text": func(buf *bytes.Buffer) error {
+				return WriteConversationText(context.Background(), buf, store, ownerID, conv, Options{})
+			},
+		} {
+			var buf bytes.Buffer
+			if err := write(&buf); !errors.Is(err, ErrForeignData) {
+				return fmt.Errorf("%s: err = %v, want ErrForeignData", name, err)
+			}
+			if strings.Contains(buf.String(), foreignMarker) {
+				return fmt.Errorf("%s: foreign data in output", name)
+			}
+		}
+		return nil
+	}},
+	{"account archive with a foreign conversation writes nothing", func() error {
+		store, _ := ownerStore()
+		foreign := newConversation(strangerID, foreignMarker)
+		store.conversations = append(store.conversations, foreign)
+		store.messages[foreign.ID] = []models.Message{newMessage(foreign.ID, "user", foreignMarker, 0, nil)}
+
+		var buf bytes.Buffer
+		user := &models.User{ID: ownerID, Language: "es"}
+		if err := WriteUserArchive(context.Background(), &buf, store, user, nil, Options{}); !errors.Is(err, ErrForeignData) {
+			return fmt.Errorf("err = %v, want ErrForeignData", err)
+		}
+		if buf.Len() != 0 {
+			return fmt.Errorf("%d bytes written before rejecting the archive", buf.Len())
+		}
+		return nil
+	}},
+	{"account archive is valid JSON", func() error {
+		store, conv := ownerStore()
+		second := newConversation(ownerID, "Llamada del banco")
+		store.conversations = append(store.conversations, second)
+		store.messages[second.ID] = []models.Message{newMessage(second.ID, "user", "Me llamó el +34 600 000 000", 0, nil)}
+
+		var buf bytes.Buffer
+		user := &models.User{ID: ownerID, Language: "es"}
+		stats := &models.UserStats{UserID: ownerID, TotalMessages: 3}
+		if err := WriteUserArchive(context.Background(), &buf, store, user, stats, Options{}); err != nil {
+			return err
+		}
+		var out UserArchive
+		if err := json.Unmarshal(buf.Bytes(), &out); err != nil {
+			return fmt.Errorf("invalid JSON: %v", err)
+		}
+		if out.Profile == nil || out.Profile.ID != ownerID || out.Stats == nil || len(out.Conversations) != 2 {
+			return fmt.Errorf("unexpected archive: %+v", out)
+		}
+		if out.Conversations[0].Conversation.ID != conv.ID || len(out.Conversations[1].Messages) != 1 {
+			return fmt.Errorf("conversations out of order or missing messages")
+		}
+		return nil
+	}},
+}
+
+func TestExport(t *testing.T) {
+	for _, scenario := range exportScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			if err := scenario.run(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
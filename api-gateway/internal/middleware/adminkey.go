@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+)
+
+// HeaderAdminKey cabecera con la API key de los endpoints de administración
+const HeaderAdminKey = "X-Admin-Key"
+
+// AdminAPIKey exige la API key de administración en X-Admin-Key (401 si falta o no coincide).
+// Sin key configurada los endpoints quedan deshabilitados.
+func AdminAPIKey(key string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if key == "" {
+				respondError(w, http.StatusServiceUnavailable, "admin_disabled", "Admin endpoints require ADMIN_API_KEY")
+				return
+			}
+
+			provided := r.Header.Get(HeaderAdminKey)
+			if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(key)) != 1 {
+				log.Warn().
+					Str("path", r.URL.Path).
+					Str("remote_addr", r.RemoteAddr).
+					Msg("[Admin] Invalid admin API key")
+				respondError(w, http.StatusUnauthorized, "invalid_admin_key", "Invalid admin API key")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
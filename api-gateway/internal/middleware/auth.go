@@ -9,6 +9,8 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/api-gateway/internal/auth"
 	"github.com/trackfy/api-gateway/internal/db"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type contextKey string
@@ -86,6 +88,8 @@ func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
 		ctx = context.WithValue(ctx, ContextKeySessionID, claims.SessionID)
 		ctx = context.WithValue(ctx, ContextKeyClaims, claims)
 
+		trace.SpanFromContext(ctx).SetAttributes(attribute.String("user.id", claims.UserID.String()))
+
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/correlation"
+)
+
+// CorrelationID reutiliza el X-Correlation-ID del cliente o genera uno (UUID) y lo deja en el
+// contexto para los logs y las llamadas a fy-analysis y fy-engine. Se devuelve en la respuesta.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(correlation.Header)
+		if !correlation.Valid(id) {
+			id = uuid.NewString()
+		}
+
+		w.Header().Set(correlation.Header, id)
+		next.ServeHTTP(w, r.WithContext(correlation.NewContext(r.Context(), id)))
+	})
+}
+
+// RequestLogger registra cada petición en JSON con su correlation_id (sustituye al logger de chi)
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			correlation.Logger(r.Context()).Info().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_addr", r.RemoteAddr).
+				Str("request_id", chimiddleware.GetReqID(r.Context())).
+				Int("status", ww.Status()).
+				Int("bytes", ww.BytesWritten()).
+				Dur("duration", time.Since(start)).
+				Msg("request completed")
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
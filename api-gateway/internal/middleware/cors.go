@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/cors"
+)
+
+// CORSConfig política CORS del gateway (variables CORS_*)
+type CORSConfig struct {
+	// Orígenes exactos ("https://app.trackfy.es"), subdominios ("https://*.trackfy.es") o "*".
+	// Vacío = ningún origen cruzado (la app móvil no envía Origin).
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	MaxAge           int // Segundos que el navegador cachea el preflight
+	AllowCredentials bool
+}
+
+// CORS aplica la política de cfg. A los orígenes no permitidos se les responde sin
+// cabeceras CORS (el navegador bloquea la respuesta) en lugar de con un error.
+func CORS(cfg CORSConfig) func(http.Handler) http.Handler {
+	return cors.Handler(cors.Options{
+		AllowOriginFunc: func(r *http.Request, origin string) bool {
+			return OriginAllowed(cfg.AllowedOrigins, origin)
+		},
+		AllowedMethods:   cfg.AllowedMethods,
+		AllowedHeaders:   cfg.AllowedHeaders,
+		ExposedHeaders:   cfg.ExposedHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
+	})
+}
+
+// OriginAllowed indica si origin coincide con alguno de los permitidos: igualdad exacta,
+// "*" o comodín de subdominio ("https://*.trackfy.es" admite a.trackfy.es pero no trackfy.es)
+func OriginAllowed(allowed []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	origin = strings.ToLower(origin)
+
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "*" || a == origin {
+			return true
+		}
+
+		scheme, suffix, ok := strings.Cut(a, "://*.")
+		if !ok || suffix == "" {
+			continue
+		}
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if ok && len(host) > len(suffix)+1 && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins:   []string{"https://app.trackfy.es", "https://*.trackfy.dev"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Correlation-ID"},
+		MaxAge:           300,
+		AllowCredentials: true,
+	}
+}
+
+// corsRequest pasa r por CORS(cfg) e indica si llegó al handler
+func corsRequest(cfg CORSConfig, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	CORS(cfg)(next).ServeHTTP(rec, r)
+	return rec, reached
+}
+
+func preflightRequest(origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/api/v1/conversations/1", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     func(*CORSConfig)
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{name: "DELETE from allowed origin", origin: "https://app.trackfy.es", method: "DELETE", headers: "Authorization", allowed: true},
+		{name: "subdomain wildcard", origin: "https://web.trackfy.dev", method: "POST", headers: "Content-Type", allowed: true},
+		{name: "DELETE from disallowed origin", origin: "https://evil.example", method: "DELETE", headers: "Authorization"},
+		{name: "GET from disallowed origin", origin: "https://evil.example", method: "GET"},
+		{name: "wildcard does not match apex", origin: "https://trackfy.dev", method: "GET"},
+		{name: "disallowed header", origin: "https://app.trackfy.es", method: "POST", headers: "X-Admin-Key"},
+		{
+			name:   "DELETE not in CORS_ALLOWED_METHODS",
+			cfg:    func(cfg *CORSConfig) { cfg.AllowedMethods = []string{"GET", "POST"} },
+			origin: "https://app.trackfy.es", method: "DELETE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testCORSConfig()
+			if tt.cfg != nil {
+				tt.cfg(&cfg)
+			}
+			rec, reached := corsRequest(cfg, preflightRequest(tt.origin, tt.method, tt.headers))
+
+			// El preflight nunca llega a las rutas (ni a la autenticación)
+			if reached {
+				t.Fatal("preflight reached the handler")
+			}
+			origin := rec.Header().Get("Access-Control-Allow-Origin")
+			if !tt.allowed {
+				if origin != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+					t.Errorf("disallowed preflight got CORS headers: %v", rec.Header())
+				}
+				return
+			}
+			if origin != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", origin, tt.origin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != tt.method {
+				t.Errorf("Access-Control-Allow-Methods = %q, want %q", got, tt.method)
+			}
+			if rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+				t.Error("missing Access-Control-Allow-Credentials")
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "300" {
+				t.Errorf("Access-Control-Max-Age = %q, want 300", got)
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/conversations", nil)
+	r.Header.Set("Origin", "https://app.trackfy.es")
+	rec, reached := corsRequest(testCORSConfig(), r)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "https://app.trackfy.es" {
+		t.Errorf("allowed origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "X-Correlation-Id" {
+		t.Errorf("Access-Control-Expose-Headers = %q", got)
+	}
+
+	// Origen no permitido: la petición se sirve pero el navegador no deja leer la respuesta
+	r = httptest.NewRequest(http.MethodGet, "/api/v1/conversations", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	rec, reached = corsRequest(testCORSConfig(), r)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("disallowed origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+
+	// App móvil (sin Origin) con la política vacía por defecto
+	rec, reached = corsRequest(CORSConfig{}, httptest.NewRequest(http.MethodGet, "/api/v1/conversations", nil))
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("no origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://app.trackfy.es", " HTTPS://*.Trackfy.dev "}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.trackfy.es", true},
+		{"HTTPS://APP.TRACKFY.ES", true},
+		{"https://a.b.trackfy.dev", true},
+		{"https://trackfy.dev", false},
+		{"http://a.trackfy.dev", false},
+		{"https://eviltrackfy.dev", false},
+		{"https://app.trackfy.es.evil.example", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := OriginAllowed(allowed, tt.origin); got != tt.want {
+			t.Errorf("OriginAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if !OriginAllowed([]string{"*"}, "https://any.example") || OriginAllowed([]string{"*"}, "") {
+		t.Error("wildcard origin")
+	}
+}
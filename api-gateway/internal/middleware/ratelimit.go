@@ -80,6 +80,40 @@ func (rl *RateLimiter) LimitByUser(requests int, window time.Duration) func(http
 	}
 }
 
+// LimitByUserAction rate limit por usuario para una acción concreta (p. ej. "export"),
+// con su propio contador independiente del límite global por usuario
+func (rl *RateLimiter) LimitByUserAction(action string, requests int, window time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := GetUserID(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			key := "user:" + userID.String() + ":" + action
+
+			allowed, count, err := rl.redis.CheckRateLimit(r.Context(), key, requests, window)
+			if err != nil {
+				log.Error().Err(err).Str("action", action).Msg("[RateLimit] Redis error")
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set("X-RateLimit-Limit", fmt.Sprintf("%d", requests))
+			w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", max(0, requests-count)))
+
+			if !allowed {
+				w.Header().Set("Retry-After", fmt.Sprintf("%d", int(window.Seconds())))
+				respondError(w, http.StatusTooManyRequests, "rate_limit_exceeded", "Too many requests")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func getClientIdentifier(r *http.Request) string {
 	// Intentar obtener UserID del contexto
 	if userID, ok := GetUserID(r.Context()); ok {
@@ -0,0 +1,46 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/trackfy/api-gateway/internal/db"
+	"github.com/trackfy/pkg/signature"
+)
+
+// redisNonces nonces de la firma en el Redis del gateway (compartidos entre réplicas)
+type redisNonces struct {
+	redis *db.RedisDB
+}
+
+func (n redisNonces) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return n.redis.UseNonce(ctx, nonce, ttl)
+}
+
+// SignatureValidation valida la firma HMAC-SHA256 de los webhooks internos (pkg/signature).
+// A diferencia de fy-analysis, sin secret rechaza todo: los webhooks envían notificaciones a usuarios.
+func SignatureValidation(secret string, redis *db.RedisDB) func(http.Handler) http.Handler {
+	if secret == "" {
+		return func(http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				respondError(w, http.StatusServiceUnavailable, "webhooks_disabled", "Internal webhooks require INTERNAL_SIGNING_SECRET")
+			})
+		}
+	}
+
+	return signature.Middleware(signature.Options{
+		Secret: secret,
+		Nonces: redisNonces{redis: redis},
+		Reject: rejectSignature,
+	})
+}
+
+// rejectSignature responde con el formato de error del gateway
+func rejectSignature(w http.ResponseWriter, status int) {
+	if status == http.StatusServiceUnavailable {
+		respondError(w, status, "nonce_store_unavailable", "Cannot verify internal request signature")
+		return
+	}
+	respondError(w, status, "invalid_signature", "Invalid internal request signature")
+}
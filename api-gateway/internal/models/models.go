@@ -65,16 +65,18 @@ type Session struct {
 
 // Conversation representa una conversación
 type Conversation struct {
-	ID           uuid.UUID `json:"id"`
-	UserID       uuid.UUID `json:"user_id"`
-	Title        string    `json:"title,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	IsActive     bool      `json:"is_active"`
-	MessageCount int       `json:"message_count"`
-	LastMessage  string    `json:"last_message,omitempty"`
-	LastIntent   string    `json:"last_intent,omitempty"`
-	HasThreats   bool      `json:"has_threats"`
+	ID           uuid.UUID  `json:"id"`
+	UserID       uuid.UUID  `json:"user_id"`
+	Title        string     `json:"title,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	IsActive     bool       `json:"is_active"`
+	MessageCount int        `json:"message_count"`
+	LastMessage  string     `json:"last_message,omitempty"`
+	LastIntent   string     `json:"last_intent,omitempty"`
+	HasThreats   bool       `json:"has_threats"`
+	IsArchived   bool       `json:"is_archived"` // En archived_conversations (solo con include_archived)
+	ArchivedAt   *time.Time `json:"archived_at,omitempty"`
 }
 
 // Message representa un mensaje en una conversación
@@ -90,15 +92,254 @@ type Message struct {
 	CreatedAt         time.Time              `json:"created_at"`
 }
 
+// MessageCursor posición en una conversación: (created_at, id) desempata mensajes del mismo instante.
+// Con ID nulo el cursor es solo un timestamp.
+type MessageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// MessagePage página de mensajes con paginación por cursor
+type MessagePage struct {
+	Messages     []Message  `json:"messages"`
+	NextCursor   string     `json:"next_cursor,omitempty"` // ID del último mensaje de la página; vacío si no hay más
+	MessageCount int        `json:"message_count"`
+	OldestAt     *time.Time `json:"oldest_at,omitempty"`
+	NewestAt     *time.Time `json:"newest_at,omitempty"`
+}
+
+// MessageEntity URL, email o teléfono de un mensaje del usuario (Message.EntitiesFound["entities"])
+type MessageEntity struct {
+	Type      string `json:"type"` // url, email, phone
+	Value     string `json:"value"`
+	Verdict   string `json:"verdict,omitempty"`    // safe, suspicious, dangerous; vacío = sin analizar
+	RiskScore *int   `json:"risk_score,omitempty"` // nil = sin analizar
+	Key       string `json:"-"`                    // Valor normalizado (deduplicación en user_entities)
+}
+
+// UserEntity entidad que el usuario ha consultado alguna vez, con el último veredicto
+type UserEntity struct {
+	Type          string     `json:"type"` // url, email, phone
+	Value         string     `json:"value"`
+	Verdict       string     `json:"verdict,omitempty"` // Vacío = nunca analizada
+	RiskScore     *int       `json:"risk_score,omitempty"`
+	AnalyzedAt    *time.Time `json:"analyzed_at,omitempty"`
+	TimesSeen     int        `json:"times_seen"`
+	FirstSeenAt   time.Time  `json:"first_seen_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at"`
+	LastMessageID *uuid.UUID `json:"last_message_id,omitempty"`
+}
+
+// UserEntityPage página de GET /api/v1/me/entities (más recientes primero)
+type UserEntityPage struct {
+	Entities []UserEntity `json:"entities"`
+	Total    int          `json:"total"`
+	Limit    int          `json:"limit"`
+	Offset   int          `json:"offset"`
+}
+
 // UserStats estadísticas del usuario
 type UserStats struct {
-	UserID          uuid.UUID `json:"user_id"`
-	TotalMessages   int       `json:"total_messages"`
-	TotalAnalyses   int       `json:"total_analyses"`
-	ThreatsDetected int       `json:"threats_detected"`
-	SafeVerified    int       `json:"safe_verified"`
-	URLsAnalyzed    int       `json:"urls_analyzed"`
-	EmailsAnalyzed  int       `json:"emails_analyzed"`
-	PhonesAnalyzed  int       `json:"phones_analyzed"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	UserID                uuid.UUID `json:"user_id"`
+	TotalMessages         int       `json:"total_messages"`
+	TotalAnalyses         int       `json:"total_analyses"`
+	ThreatsDetected       int       `json:"threats_detected"`
+	SafeVerified          int       `json:"safe_verified"`
+	URLsAnalyzed          int       `json:"urls_analyzed"`
+	EmailsAnalyzed        int       `json:"emails_analyzed"`
+	PhonesAnalyzed        int       `json:"phones_analyzed"`
+	Conversations         int       `json:"conversations"` // Sin archivar
+	ArchivedConversations int       `json:"archived_conversations"`
+	UpdatedAt             time.Time `json:"updated_at"`
+}
+
+// AllowlistEntry entrada de la lista de confianza personal del usuario
+type AllowlistEntry struct {
+	IndicatorType  string    `json:"indicator_type"` // url, email, phone
+	IndicatorValue string    `json:"indicator_value"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// Device dispositivo registrado para notificaciones push
+type Device struct {
+	Token     string    `json:"token"`
+	Platform  string    `json:"platform"` // android, ios
+	SessionID uuid.UUID `json:"session_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlertRecipient dispositivo de un usuario que analizó un input reclasificado
+type AlertRecipient struct {
+	UserID      uuid.UUID
+	Token       string
+	Platform    string
+	EntityType  string
+	EntityValue string
+	Language    string
+}
+
+// Estados de un borrado de cuenta
+const (
+	AccountDeletionQueued    = "queued"
+	AccountDeletionRunning   = "running"
+	AccountDeletionCompleted = "completed"
+	AccountDeletionFailed    = "failed"
+)
+
+// AccountDeletionJob borrado de cuenta en cola (RGPD). La IP solo se guarda hasta
+// escribir audit_log; el estado se consulta por ID sin sesión (las sesiones se revocan).
+type AccountDeletionJob struct {
+	ID          uuid.UUID  `json:"id"`
+	UserID      uuid.UUID  `json:"user_id"`
+	IPAddress   string     `json:"ip_address,omitempty"`
+	Status      string     `json:"status"`
+	DataRemoved []string   `json:"data_removed,omitempty"`
+	Pending     []string   `json:"pending,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// MonthlyReport informe mensual de amenazas del usuario
+type MonthlyReport struct {
+	Year          int                 `json:"year"`
+	Month         int                 `json:"month"`
+	TotalAnalyses int                 `json:"total_analyses"`
+	ThreatsFound  int                 `json:"threats_found"`
+	ByType        map[string]int      `json:"by_type"`       // url, email, phone
+	ByRiskLevel   map[string]int      `json:"by_risk_level"` // safe, suspicious, dangerous, unknown
+	TopDomains    []DomainCount       `json:"top_domains"`
+	Timeline      []DailyCount        `json:"timeline"` // Análisis por día (todos los días del mes)
+	Reports       *MonthlyReportsInfo `json:"reports,omitempty"`
+	PreviousMonth *MonthComparison    `json:"previous_month"`
+	GeneratedAt   time.Time           `json:"generated_at"`
+}
+
+// DomainCount dominio con amenazas y número de apariciones
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int    `json:"count"`
+}
+
+// DailyCount análisis realizados en un día
+type DailyCount struct {
+	Date     string `json:"date"` // YYYY-MM-DD
+	Analyses int    `json:"analyses"`
+	Threats  int    `json:"threats"`
+}
+
+// MonthlyReportsInfo reportes de URLs enviados por el usuario en el mes (fy-analysis)
+type MonthlyReportsInfo struct {
+	Total        int            `json:"total"`
+	ByThreatType map[string]int `json:"by_threat_type"`
+}
+
+// MonthComparison comparación con el mes anterior
+type MonthComparison struct {
+	TotalAnalyses int     `json:"total_analyses"`
+	ThreatsFound  int     `json:"threats_found"`
+	AnalysesDelta float64 `json:"analyses_delta_pct"` // Variación porcentual (0 si el mes anterior está vacío)
+	ThreatsDelta  float64 `json:"threats_delta_pct"`
+}
+
+// ReportMonth mes con informe disponible
+type ReportMonth struct {
+	Year          int `json:"year"`
+	Month         int `json:"month"`
+	TotalAnalyses int `json:"total_analyses"`
+}
+
+// ==================== SUSCRIPCIONES ====================
+
+// Estados de una suscripción de Stripe (subscription.status)
+const (
+	SubscriptionTrialing   = "trialing"
+	SubscriptionActive     = "active"
+	SubscriptionPastDue    = "past_due"
+	SubscriptionCanceled   = "canceled"
+	SubscriptionUnpaid     = "unpaid"
+	SubscriptionIncomplete = "incomplete"
+)
+
+// Subscription plan del usuario. Status vacío = usuario sin datos de Stripe: manda is_premium.
+type Subscription struct {
+	Status            string     `json:"status"`
+	CurrentPeriodEnd  *time.Time `json:"current_period_end,omitempty"`
+	CancelAtPeriodEnd bool       `json:"cancel_at_period_end"` // Cancelada: premium hasta CurrentPeriodEnd
+	LegacyPremium     bool       `json:"-"`                    // users.is_premium
+}
+
+// Entitled indica si la suscripción da acceso premium. En prueba, activa (aunque esté
+// cancelada para fin de periodo) y con un pago fallido (past_due: Stripe sigue reintentando
+// el cobro) el usuario conserva el acceso; canceled, unpaid e incomplete lo pierden.
+func (s *Subscription) Entitled() bool {
+	switch s.Status {
+	case "":
+		return s.LegacyPremium
+	case SubscriptionTrialing, SubscriptionActive, SubscriptionPastDue:
+		return true
+	default:
+		return false
+	}
+}
+
+// ==================== ANALYTICS ====================
+
+// TrendingEntry entidad más analizada en una ventana
+type TrendingEntry struct {
+	Value    string `json:"value"`
+	Analyses int    `json:"analyses"`
+	Threats  int    `json:"threats"` // Análisis con veredicto suspicious o dangerous
+}
+
+// TrendingWindow top de entidades por categoría (domain, url, email, phone) en una ventana
+type TrendingWindow struct {
+	Since      time.Time                  `json:"since"`
+	Categories map[string][]TrendingEntry `json:"categories"`
+}
+
+// TrendingThreats entidades más analizadas en las últimas 24h y 7 días
+type TrendingThreats struct {
+	Last24h     *TrendingWindow `json:"last_24h"`
+	Last7d      *TrendingWindow `json:"last_7d"`
+	GeneratedAt time.Time       `json:"generated_at"`
+}
+
+// RiskBucket tramo del histograma de risk scores: [Min, Max), el último incluye 100
+type RiskBucket struct {
+	Range string `json:"range"`
+	Min   int    `json:"min"`
+	Max   int    `json:"max"`
+	Count int    `json:"count"`
+}
+
+// RiskDistribution histograma de risk scores de los análisis desde Since
+type RiskDistribution struct {
+	Since       time.Time    `json:"since"`
+	Total       int          `json:"total"`
+	Buckets     []RiskBucket `json:"buckets"`
+	GeneratedAt time.Time    `json:"generated_at"`
+}
+
+// CheckerCoverageEntry análisis en los que un checker dio positivo
+type CheckerCoverageEntry struct {
+	Checker  string  `json:"checker"`
+	Analyses int     `json:"analyses"`
+	Percent  float64 `json:"percent"`
+}
+
+// CheckerCoverage aportación de cada checker desde Since. Solo cuentan los análisis que
+// registran sus checkers (los anteriores a la columna checkers quedan fuera).
+type CheckerCoverage struct {
+	Since       time.Time              `json:"since"`
+	Analyses    int                    `json:"analyses"`
+	Checkers    []CheckerCoverageEntry `json:"checkers"`
+	GeneratedAt time.Time              `json:"generated_at"`
+}
+
+// TrendingDomain dominio peligroso en tendencia (versión para usuarios)
+type TrendingDomain struct {
+	Domain  string `json:"domain"`
+	Threats int    `json:"threats"`
 }
@@ -0,0 +1,159 @@
+// Package payments habla con el proveedor de pagos (Stripe) para las operaciones que no
+// llegan por webhook, como cancelar la suscripción de una cuenta que se borra.
+package payments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	stripeAPIURL = "https://api.stripe.com"
+	// stripeSearchLimit suscripciones por página de la búsqueda (máximo de Stripe)
+	stripeSearchLimit = 100
+)
+
+// stripeFinalStatuses estados en los que la suscripción ya no cobra
+var stripeFinalStatuses = map[string]bool{
+	"canceled":           true,
+	"incomplete_expired": true,
+}
+
+// StripeClient cliente mínimo de la API REST de Stripe. Las suscripciones se enlazan con el
+// usuario por metadata.user_id (lo fija el checkout al crearlas).
+type StripeClient struct {
+	secretKey  string
+	baseURL    string
+	httpClient *http.Client
+}
+
+// NewStripeClient crea el cliente con la clave secreta de la cuenta (sk_...)
+func NewStripeClient(secretKey string, timeout time.Duration) *StripeClient {
+	return &StripeClient{
+		secretKey:  secretKey,
+		baseURL:    stripeAPIURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// stripeSubscription campos usados de una suscripción
+type stripeSubscription struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// stripeSearchResult página de /v1/subscriptions/search
+type stripeSearchResult struct {
+	Data     []stripeSubscription `json:"data"`
+	HasMore  bool                 `json:"has_more"`
+	NextPage string               `json:"next_page"`
+}
+
+// stripeError cuerpo de error de la API
+type stripeError struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// CancelUserSubscription cancela de inmediato todas las suscripciones del usuario que aún
+// cobran. Idempotente: sin suscripciones pendientes no hace nada.
+func (c *StripeClient) CancelUserSubscription(ctx context.Context, userID uuid.UUID) error {
+	subscriptions, err := c.userSubscriptions(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("failed to search subscriptions: %w", err)
+	}
+
+	for _, sub := range subscriptions {
+		if stripeFinalStatuses[sub.Status] {
+			continue
+		}
+		if err := c.cancelSubscription(ctx, sub.ID); err != nil {
+			return fmt.Errorf("failed to cancel subscription %s: %w", sub.ID, err)
+		}
+		log.Info().
+			Str("user_id", userID.String()).
+			Str("subscription_id", sub.ID).
+			Str("status", sub.Status).
+			Msg("[Stripe] Subscription cancelled")
+	}
+	return nil
+}
+
+// userSubscriptions suscripciones con metadata.user_id del usuario (todas las páginas)
+func (c *StripeClient) userSubscriptions(ctx context.Context, userID uuid.UUID) ([]stripeSubscription, error) {
+	query := url.Values{}
+	query.Set("query", fmt.Sprintf("metadata['user_id']:'%s'", userID))
+	query.Set("limit", fmt.Sprint(stripeSearchLimit))
+
+	var subscriptions []stripeSubscription
+	for {
+		var page stripeSearchResult
+		if err := c.call(ctx, http.MethodGet, "/v1/subscriptions/search?"+query.Encode(), &page); err != nil {
+			return nil, err
+		}
+		subscriptions = append(subscriptions, page.Data...)
+		if !page.HasMore || page.NextPage == "" {
+			return subscriptions, nil
+		}
+		query.Set("page", page.NextPage)
+	}
+}
+
+// cancelSubscription DELETE /v1/subscriptions/{id}: cancelación inmediata, sin más cobros.
+// Una suscripción que ya no existe se da por cancelada.
+func (c *StripeClient) cancelSubscription(ctx context.Context, subscriptionID string) error {
+	err := c.call(ctx, http.MethodDelete, "/v1/subscriptions/"+url.PathEscape(subscriptionID), nil)
+	if apiErr, ok := err.(*stripeAPIError); ok && apiErr.status == http.StatusNotFound {
+		return nil
+	}
+	return err
+}
+
+// stripeAPIError respuesta no 2xx de Stripe
+type stripeAPIError struct {
+	status  int
+	code    string
+	message string
+}
+
+func (e *stripeAPIError) Error() string {
+	return fmt.Sprintf("stripe returned %d: %s %s", e.status, e.code, e.message)
+}
+
+// call hace la petición autenticada y decodifica la respuesta en out (si no es nil)
+func (c *StripeClient) call(ctx context.Context, method, path string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.baseURL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.secretKey, "")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var body stripeError
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		json.Unmarshal(data, &body)
+		return &stripeAPIError{status: resp.StatusCode, code: body.Error.Code, message: body.Error.Message}
+	}
+	if out == nil {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
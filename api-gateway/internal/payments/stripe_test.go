@@ -0,0 +1,72 @@
+package payments
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCancelUserSubscription(t *testing.T) {
+	userID := uuid.New()
+	var cancelled []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key, _, _ := r.BasicAuth(); key != "sk_test" {
+			t.Errorf("secret key = %q, want sk_test", key)
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/subscriptions/search":
+			if want := "metadata['user_id']:'" + userID.String() + "'"; r.URL.Query().Get("query") != want {
+				t.Errorf("query = %q, want %q", r.URL.Query().Get("query"), want)
+			}
+			if r.URL.Query().Get("page") == "" {
+				w.Write([]byte(`{"data":[{"id":"sub_active","status":"active"},{"id":"sub_old","status":"canceled"}],"has_more":true,"next_page":"p2"}`))
+				return
+			}
+			w.Write([]byte(`{"data":[{"id":"sub_gone","status":"past_due"}],"has_more":false}`))
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, "/v1/subscriptions/"):
+			id := strings.TrimPrefix(r.URL.Path, "/v1/subscriptions/")
+			cancelled = append(cancelled, id)
+			if id == "sub_gone" {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"error":{"code":"resource_missing","message":"No such subscription"}}`))
+				return
+			}
+			w.Write([]byte(`{"id":"` + id + `","status":"canceled"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	client := NewStripeClient("sk_test", 5*time.Second)
+	client.baseURL = server.URL
+
+	if err := client.CancelUserSubscription(context.Background(), userID); err != nil {
+		t.Fatalf("CancelUserSubscription: %v", err)
+	}
+	if got := strings.Join(cancelled, ","); got != "sub_active,sub_gone" {
+		t.Errorf("cancelled = %s, want sub_active,sub_gone", got)
+	}
+}
+
+func TestCancelUserSubscriptionError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(`{"error":{"code":"api_key_invalid","message":"Invalid API Key"}}`))
+	}))
+	defer server.Close()
+
+	client := NewStripeClient("sk_bad", 5*time.Second)
+	client.baseURL = server.URL
+
+	if err := client.CancelUserSubscription(context.Background(), uuid.New()); err == nil {
+		t.Fatal("CancelUserSubscription succeeded with an invalid key")
+	}
+}
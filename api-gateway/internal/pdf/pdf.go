@@ -0,0 +1,167 @@
+// Package pdf escribe documentos PDF 1.4 sencillos: páginas A4 con texto en Helvetica
+// (normal y negrita, codificación WinAnsi) y rectángulos de color. Suficiente para los
+// informes de la app sin depender de una librería externa.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tamaño de página A4 en puntos
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Fuentes estándar (no se incrustan: todos los lectores PDF las incluyen)
+const (
+	FontRegular = "F1"
+	FontBold    = "F2"
+)
+
+// Color RGB con componentes entre 0 y 1
+type Color struct {
+	R, G, B float64
+}
+
+var (
+	Black = Color{0, 0, 0}
+	Gray  = Color{0.42, 0.45, 0.5}
+)
+
+// Document páginas en construcción. Las coordenadas tienen el origen arriba a la izquierda
+// (y crece hacia abajo), al revés que PDF, para maquetar de arriba abajo.
+type Document struct {
+	title string
+	pages []*bytes.Buffer
+}
+
+// New documento vacío; title va a los metadatos (/Title)
+func New(title string) *Document {
+	return &Document{title: title}
+}
+
+// AddPage añade una página y la deja como página actual
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+// PageCount número de páginas añadidas
+func (d *Document) PageCount() int {
+	return len(d.pages)
+}
+
+func (d *Document) current() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text escribe s con la línea base en (x, y)
+func (d *Document) Text(x, y float64, font string, size float64, color Color, s string) {
+	fmt.Fprintf(d.current(), "BT %s rg /%s %s Tf %s %s Td (%s) Tj ET\n",
+		color.operands(), font, num(size), num(x), num(PageHeight-y), escape(s))
+}
+
+// Rect rectángulo relleno con la esquina superior izquierda en (x, y)
+func (d *Document) Rect(x, y, width, height float64, color Color) {
+	fmt.Fprintf(d.current(), "%s rg %s %s %s %s re f\n",
+		color.operands(), num(x), num(PageHeight-y-height), num(width), num(height))
+}
+
+// Line línea de (x1, y1) a (x2, y2)
+func (d *Document) Line(x1, y1, x2, y2, width float64, color Color) {
+	fmt.Fprintf(d.current(), "%s RG %s w %s %s m %s %s l S\n",
+		color.operands(), num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// WriteTo serializa el documento: objetos, tabla xref y trailer
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	// 1 catálogo, 2 páginas, 3-4 fuentes, 5 metadatos y, por página, la página y su contenido
+	const firstPage = 6
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (Trackfy) >>", escape(d.title)))
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+			"/Resources << /Font << /%s 3 0 R /%s 4 0 R >> >> /Contents %d 0 R >>",
+			num(PageWidth), num(PageHeight), FontRegular, FontBold, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	n, err := w.Write(buf.Bytes())
+	return int64(n), err
+}
+
+// TextWidth ancho aproximado de s en puntos (Helvetica: ~0.5 em de media por carácter)
+func TextWidth(s string, size float64) float64 {
+	return float64(len([]rune(s))) * size * 0.5
+}
+
+func (c Color) operands() string {
+	return num(c.R) + " " + num(c.G) + " " + num(c.B)
+}
+
+// num número con dos decimales como mucho (PDF no admite notación exponencial)
+func num(v float64) string {
+	s := strings.TrimRight(strings.TrimRight(strconv.FormatFloat(v, 'f', 2, 64), "0"), ".")
+	if s == "" || s == "-0" {
+		return "0"
+	}
+	return s
+}
+
+// escape cadena literal de PDF en WinAnsi: caracteres fuera de Latin-1 se sustituyen por '?'
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n' || r == '\r' || r == '\t':
+			b.WriteByte(' ')
+		case r == '€':
+			b.WriteString(`\200`)
+		case r < 0x20 || (r >= 0x7f && r < 0xa0) || r > 0xff:
+			b.WriteByte('?')
+		case r >= 0x80:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,88 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// checkXref comprueba que cada entrada de la tabla xref apunta al inicio de su objeto
+func checkXref(t *testing.T, out []byte) {
+	t.Helper()
+	match := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(out)
+	if match == nil {
+		t.Fatal("missing startxref trailer")
+	}
+	xref, _ := strconv.Atoi(string(match[1]))
+	if !bytes.HasPrefix(out[xref:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to the xref table", xref)
+	}
+
+	lines := strings.Split(string(out[xref:]), "\n")
+	var count int
+	fmt.Sscanf(lines[1], "0 %d", &count)
+	for i := 1; i < count; i++ {
+		offset, _ := strconv.Atoi(lines[2+i][:10])
+		if want := fmt.Sprintf("%d 0 obj\n", i); !bytes.HasPrefix(out[offset:], []byte(want)) {
+			t.Errorf("xref entry %d (offset %d) does not point to %q", i, offset, want)
+		}
+	}
+}
+
+func TestDocumentStructure(t *testing.T) {
+	doc := New("Informe (prueba)")
+	doc.AddPage()
+	doc.Text(48, 60, FontBold, 20, Black, "Informe de amenazas")
+	doc.Rect(48, 100, 10, 40, Color{R: 1})
+	doc.AddPage()
+	doc.Line(48, 60, 500, 60, 0.5, Gray)
+
+	var buf bytes.Buffer
+	if _, err := doc.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.Bytes()
+
+	if !bytes.HasPrefix(out, []byte("%PDF-1.4\n")) {
+		t.Fatal("missing PDF header")
+	}
+	if !bytes.Contains(out, []byte("/Count 2")) {
+		t.Error("page tree does not count 2 pages")
+	}
+	if !bytes.Contains(out, []byte(`/Title (Informe \(prueba\))`)) {
+		t.Error("title not escaped in metadata")
+	}
+	// y = 60 desde arriba es PageHeight-60 desde abajo
+	if !bytes.Contains(out, []byte("/F2 20 Tf 48 781.89 Td (Informe de amenazas) Tj")) {
+		t.Error("text not placed in PDF coordinates")
+	}
+	checkXref(t, out)
+}
+
+func TestEscape(t *testing.T) {
+	tests := map[string]string{
+		`a(b)c\d`:    `a\(b\)c\\d`,
+		"análisis":   `an\341lisis`,
+		"Niño":       `Ni\361o`,
+		"12 €":       `12 \200`,
+		"línea\ndos": `l\355nea dos`,
+		"emoji 🚨":    "emoji ?",
+	}
+	for in, want := range tests {
+		if got := escape(in); got != want {
+			t.Errorf("escape(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNum(t *testing.T) {
+	tests := map[float64]string{0: "0", 100: "100", 841.89: "841.89", 0.5: "0.5", -0.001: "0", 1e-7: "0", 12.345: "12.35"}
+	for in, want := range tests {
+		if got := num(in); got != want {
+			t.Errorf("num(%v) = %q, want %q", in, got, want)
+		}
+	}
+}
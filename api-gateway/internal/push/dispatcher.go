@@ -0,0 +1,128 @@
+package push
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+var alertsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "push_threat_alerts_total",
+	Help: "Alertas push de reclasificación por resultado (sent, pruned, failed)",
+}, []string{"result"})
+
+// DeviceStore destinatarios de las alertas y poda de tokens (PostgresDB)
+type DeviceStore interface {
+	GetAlertRecipients(ctx context.Context, inputHash []byte, since time.Time) ([]models.AlertRecipient, error)
+	DeleteDeviceTokens(ctx context.Context, tokens []string) error
+}
+
+// Reclassification evento de fy-analysis: un input pasó a considerarse peligroso
+type Reclassification struct {
+	InputType  string `json:"input_type"` // url, email, phone
+	InputHash  string `json:"input_hash"` // hex(SHA-256(lower(valor)))
+	Verdict    string `json:"verdict"`
+	ThreatType string `json:"threat_type,omitempty"`
+	Reason     string `json:"reason,omitempty"` // community_reports, feed, manual
+}
+
+// DispatchResult resumen de un envío
+type DispatchResult struct {
+	Recipients int `json:"recipients"`
+	Sent       int `json:"sent"`
+	Pruned     int `json:"pruned"`
+	Failed     int `json:"failed"`
+}
+
+// Dispatcher avisa a quienes analizaron un input que ha sido reclasificado como peligroso
+type Dispatcher struct {
+	store    DeviceStore
+	provider Provider
+	window   time.Duration // Antigüedad máxima del análisis para avisar
+}
+
+// NewDispatcher crea el dispatcher de alertas
+func NewDispatcher(store DeviceStore, provider Provider, window time.Duration) *Dispatcher {
+	return &Dispatcher{store: store, provider: provider, window: window}
+}
+
+// NotifyReclassification envía la alerta a los dispositivos de los usuarios que analizaron
+// el input dentro de la ventana y poda los tokens que el proveedor rechaza
+func (d *Dispatcher) NotifyReclassification(ctx context.Context, event *Reclassification) (*DispatchResult, error) {
+	inputHash, err := hex.DecodeString(event.InputHash)
+	if err != nil || len(inputHash) != 32 {
+		return nil, fmt.Errorf("input_hash must be a hex SHA-256")
+	}
+
+	recipients, err := d.store.GetAlertRecipients(ctx, inputHash, time.Now().Add(-d.window))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DispatchResult{Recipients: len(recipients)}
+	var invalid []string
+	for _, r := range recipients {
+		title, body := alertText(r.Language, r.EntityType, r.EntityValue)
+		err := d.provider.Send(ctx, &Message{
+			Token: r.Token,
+			Title: title,
+			Body:  body,
+			Data: map[string]string{
+				"type":        "threat_reclassified",
+				"input_type":  event.InputType,
+				"verdict":     event.Verdict,
+				"threat_type": event.ThreatType,
+			},
+		})
+		switch {
+		case err == nil:
+			result.Sent++
+			alertsSent.WithLabelValues("sent").Inc()
+		case errors.Is(err, ErrInvalidToken):
+			invalid = append(invalid, r.Token)
+			alertsSent.WithLabelValues("pruned").Inc()
+		default:
+			result.Failed++
+			alertsSent.WithLabelValues("failed").Inc()
+			log.Warn().Err(err).Str("user_id", r.UserID.String()).Str("provider", d.provider.Name()).Msg("[Push] Failed to send alert")
+		}
+	}
+
+	if len(invalid) > 0 {
+		if err := d.store.DeleteDeviceTokens(ctx, invalid); err != nil {
+			log.Error().Err(err).Int("tokens", len(invalid)).Msg("[Push] Failed to prune invalid tokens")
+		} else {
+			result.Pruned = len(invalid)
+		}
+	}
+
+	return result, nil
+}
+
+// alertText título y cuerpo de la alerta en el idioma del usuario (español por defecto)
+func alertText(language, entityType, value string) (string, string) {
+	if len(value) > 60 {
+		value = value[:57] + "..."
+	}
+
+	if language == "en" {
+		kind := map[string]string{"url": "link", "email": "email", "phone": "phone number"}[entityType]
+		if kind == "" {
+			kind = "item"
+		}
+		return "Security alert", fmt.Sprintf("A %s you checked is now considered dangerous: %s", kind, value)
+	}
+
+	kind := map[string]string{"url": "Un enlace", "email": "Un email", "phone": "Un teléfono"}[entityType]
+	if kind == "" {
+		kind = "Algo"
+	}
+	return "Alerta de seguridad", fmt.Sprintf("%s que analizaste ahora se considera peligroso: %s", kind, value)
+}
@@ -0,0 +1,112 @@
+package push
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/trackfy/api-gateway/internal/models"
+)
+
+// stubStore destinatarios fijos; registra los tokens podados
+type stubStore struct {
+	recipients []models.AlertRecipient
+	since      time.Time
+	pruned     []string
+}
+
+func (s *stubStore) GetAlertRecipients(ctx context.Context, inputHash []byte, since time.Time) ([]models.AlertRecipient, error) {
+	s.since = since
+	return s.recipients, nil
+}
+
+func (s *stubStore) DeleteDeviceTokens(ctx context.Context, tokens []string) error {
+	s.pruned = append(s.pruned, tokens...)
+	return nil
+}
+
+// stubProvider registra los mensajes y responde con el error configurado por token
+type stubProvider struct {
+	mu     sync.Mutex
+	errors map[string]error
+	sent   []*Message
+}
+
+func (p *stubProvider) Send(ctx context.Context, msg *Message) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sent = append(p.sent, msg)
+	return p.errors[msg.Token]
+}
+
+func (p *stubProvider) Name() string { return "stub" }
+
+func inputHash(value string) string {
+	hash := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
+
+func TestNotifyReclassification(t *testing.T) {
+	store := &stubStore{recipients: []models.AlertRecipient{
+		{UserID: uuid.New(), Token: "tok-es", EntityType: "url", EntityValue: "http://bbva-clientes.xyz/login", Language: "es"},
+		{UserID: uuid.New(), Token: "tok-en", EntityType: "url", EntityValue: "http://bbva-clientes.xyz/login", Language: "en"},
+		{UserID: uuid.New(), Token: "tok-gone", EntityType: "url", EntityValue: "http://bbva-clientes.xyz/login"},
+		{UserID: uuid.New(), Token: "tok-fail", EntityType: "url", EntityValue: "http://bbva-clientes.xyz/login"},
+	}}
+	provider := &stubProvider{errors: map[string]error{
+		"tok-gone": ErrInvalidToken,
+		"tok-fail": errors.New("fcm unavailable"),
+	}}
+	dispatcher := NewDispatcher(store, provider, 24*time.Hour)
+
+	result, err := dispatcher.NotifyReclassification(context.Background(), &Reclassification{
+		InputType:  "url",
+		InputHash:  inputHash("http://bbva-clientes.xyz/login"),
+		Verdict:    "dangerous",
+		ThreatType: "phishing",
+		Reason:     "community_reports",
+	})
+	if err != nil {
+		t.Fatalf("NotifyReclassification: %v", err)
+	}
+
+	want := DispatchResult{Recipients: 4, Sent: 2, Pruned: 1, Failed: 1}
+	if *result != want {
+		t.Errorf("result = %+v, want %+v", *result, want)
+	}
+	if len(store.pruned) != 1 || store.pruned[0] != "tok-gone" {
+		t.Errorf("pruned = %v, want [tok-gone]", store.pruned)
+	}
+	if age := time.Since(store.since); age < 24*time.Hour || age > 25*time.Hour {
+		t.Errorf("recipients since %v ago, want the 24h alert window", age)
+	}
+
+	for _, msg := range provider.sent {
+		if msg.Data["type"] != "threat_reclassified" || msg.Data["threat_type"] != "phishing" {
+			t.Errorf("message data = %v", msg.Data)
+		}
+		switch msg.Token {
+		case "tok-en":
+			if msg.Title != "Security alert" {
+				t.Errorf("english title = %q", msg.Title)
+			}
+		case "tok-es":
+			if msg.Title != "Alerta de seguridad" {
+				t.Errorf("spanish title = %q", msg.Title)
+			}
+		}
+	}
+}
+
+func TestNotifyReclassificationInvalidHash(t *testing.T) {
+	dispatcher := NewDispatcher(&stubStore{}, &stubProvider{}, time.Hour)
+
+	if _, err := dispatcher.NotifyReclassification(context.Background(), &Reclassification{InputHash: "abc", Verdict: "dangerous"}); err == nil {
+		t.Fatal("NotifyReclassification accepted a hash that is not SHA-256")
+	}
+}
@@ -0,0 +1,212 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	fcmScope    = "https://www.googleapis.com/auth/firebase.messaging"
+	fcmSendURL  = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+	fcmTokenTTL = time.Hour
+)
+
+// fcmPrunableErrors códigos de FcmError que indican un token que ya no sirve
+var fcmPrunableErrors = map[string]bool{
+	"UNREGISTERED":       true,
+	"INVALID_ARGUMENT":   true, // El payload es fijo: un argumento inválido es el token
+	"SENDER_ID_MISMATCH": true,
+}
+
+// serviceAccount campos usados de la cuenta de servicio de Firebase
+type serviceAccount struct {
+	ProjectID   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenURI    string `json:"token_uri"`
+}
+
+// FCMProvider envía notificaciones con la API HTTP v1 de Firebase Cloud Messaging.
+// FCM entrega también a iOS (APNs) cuando la app registra el token de FCM.
+type FCMProvider struct {
+	account    serviceAccount
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMProvider crea el proveedor a partir del JSON de la cuenta de servicio
+func NewFCMProvider(credentialsFile string, timeout time.Duration) (*FCMProvider, error) {
+	data, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read FCM credentials: %w", err)
+	}
+
+	var account serviceAccount
+	if err := json.Unmarshal(data, &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM credentials: %w", err)
+	}
+	if account.ProjectID == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("FCM credentials must include project_id, client_email and private_key")
+	}
+	if account.TokenURI == "" {
+		account.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+
+	return &FCMProvider{
+		account:    account,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name nombre del proveedor
+func (p *FCMProvider) Name() string {
+	return "fcm"
+}
+
+// Send envía el mensaje a un token
+func (p *FCMProvider) Send(ctx context.Context, msg *Message) error {
+	accessToken, err := p.token(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": msg.Token,
+			"notification": map[string]string{
+				"title": msg.Title,
+				"body":  msg.Body,
+			},
+			"data":    msg.Data,
+			"android": map[string]string{"priority": "high"},
+			"apns": map[string]interface{}{
+				"headers": map[string]string{"apns-priority": "10"},
+			},
+		},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(fcmSendURL, p.account.ProjectID), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if code := fcmErrorCode(respBody); fcmPrunableErrors[code] {
+		return fmt.Errorf("%w: %s", ErrInvalidToken, code)
+	}
+	return fmt.Errorf("fcm returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+}
+
+// fcmErrorCode extrae el errorCode de FcmError (o el status genérico si no viene)
+func fcmErrorCode(body []byte) string {
+	var parsed struct {
+		Error struct {
+			Status  string `json:"status"`
+			Details []struct {
+				Type      string `json:"@type"`
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return ""
+	}
+	for _, d := range parsed.Error.Details {
+		if strings.HasSuffix(d.Type, "google.firebase.fcm.v1.FcmError") && d.ErrorCode != "" {
+			return d.ErrorCode
+		}
+	}
+	if parsed.Error.Status == "NOT_FOUND" {
+		return "UNREGISTERED"
+	}
+	return parsed.Error.Status
+}
+
+// token retorna un access token OAuth2 vigente, renovándolo con un JWT firmado por la cuenta de servicio
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.accessToken != "" && time.Now().Before(p.expiresAt.Add(-time.Minute)) {
+		return p.accessToken, nil
+	}
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(p.account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid FCM private key: %w", err)
+	}
+
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.account.TokenURI,
+		"iat":   now.Unix(),
+		"exp":   now.Add(fcmTokenTTL).Unix(),
+	}).SignedString(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign FCM assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.account.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get FCM access token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("FCM token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("invalid FCM token response: %w", err)
+	}
+
+	p.accessToken = result.AccessToken
+	p.expiresAt = now.Add(time.Duration(result.ExpiresIn) * time.Second)
+	return p.accessToken, nil
+}
@@ -0,0 +1,25 @@
+package push
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrInvalidToken el proveedor rechaza el token (desinstalado, caducado o de otro proyecto):
+// el token debe eliminarse
+var ErrInvalidToken = errors.New("push token is no longer valid")
+
+// Message notificación push a un dispositivo
+type Message struct {
+	Token string
+	Title string
+	Body  string
+	Data  map[string]string // Payload para la app (tipo de alerta, input, veredicto)
+}
+
+// Provider envía notificaciones push (FCM en producción; stub en tests)
+type Provider interface {
+	// Send envía el mensaje; retorna ErrInvalidToken si el token debe podarse
+	Send(ctx context.Context, msg *Message) error
+	Name() string
+}
@@ -2,30 +2,95 @@ package services
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/correlation"
+	"github.com/trackfy/pkg/signature"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 // FyAnalysisClient cliente para comunicarse con fy-analysis
 type FyAnalysisClient struct {
-	baseURL    string
-	httpClient *http.Client
+	baseURL       string
+	signingSecret string // Secreto HMAC de las peticiones internas (vacío = sin firmar)
+	httpClient    *resilientClient
 }
 
-// NewFyAnalysisClient crea un nuevo cliente de fy-analysis
-func NewFyAnalysisClient(baseURL string, timeout time.Duration) *FyAnalysisClient {
-	return &FyAnalysisClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+// NewFyAnalysisClient crea un nuevo cliente de fy-analysis. timeout es el máximo de cualquier
+// petición; resilience.CallTimeout el de cada intento.
+func NewFyAnalysisClient(baseURL, signingSecret string, timeout time.Duration, resilience ResilienceConfig) *FyAnalysisClient {
+	c := &FyAnalysisClient{
+		baseURL:       baseURL,
+		signingSecret: signingSecret,
+		httpClient:    newResilientClient("fy-analysis", timeout, resilience),
 	}
+	c.httpClient.prepare = func(req *http.Request) error {
+		if err := signature.SignRequest(req, c.signingSecret); err != nil {
+			return fmt.Errorf("failed to sign request: %w", err)
+		}
+		return nil
+	}
+	return c
+}
+
+// Circuit estado del circuito de fy-analysis
+func (c *FyAnalysisClient) Circuit() CircuitState {
+	return c.httpClient.Circuit()
+}
+
+// gzipBody descomprime el body y cierra también la respuesta original
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (g *gzipBody) Close() error {
+	g.Reader.Close()
+	return g.body.Close()
+}
+
+// callerHeader identifica a api-gateway ante fy-analysis (cuotas de análisis por llamante)
+const callerHeader = "X-Trackfy-Caller"
+
+// do envía la petición (firmada en cada intento) aceptando gzip y descomprime la respuesta si
+// viene comprimida. Al fijar Accept-Encoding manualmente, net/http ya no descomprime de forma
+// transparente.
+func (c *FyAnalysisClient) do(req *http.Request) (*http.Response, error) {
+	return c.doTimeout(req, c.httpClient.config.CallTimeout)
+}
+
+// doTimeout do con un timeout por intento distinto del de la configuración
+func (c *FyAnalysisClient) doTimeout(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set(callerHeader, "api-gateway")
+	correlation.Inject(req)
+
+	resp, err := c.httpClient.Do(req, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to decompress response: %w", err)
+		}
+		resp.Body = &gzipBody{Reader: reader, body: resp.Body}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+	}
+
+	return resp, nil
 }
 
 // ReportURLRequest petición para reportar una URL
@@ -61,14 +126,15 @@ func (c *FyAnalysisClient) ReportURL(ctx context.Context, req *ReportURLRequest,
 	httpReq.Header.Set("Content-Type", "application/json")
 	httpReq.Header.Set("X-Forwarded-For", userIP)
 	httpReq.Header.Set("User-Agent", userAgent)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
-	log.Debug().
+	correlation.Logger(ctx).Debug().
 		Str("url", req.URL).
 		Str("user_id", req.UserID).
 		Str("threat_type", req.ThreatType).
 		Msg("[FyAnalysis] Sending report request")
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -80,7 +146,7 @@ func (c *FyAnalysisClient) ReportURL(ctx context.Context, req *ReportURLRequest,
 	}
 
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		log.Error().
+		correlation.Logger(ctx).Error().
 			Int("status", resp.StatusCode).
 			Str("body", string(body)).
 			Msg("[FyAnalysis] Report request failed")
@@ -92,7 +158,7 @@ func (c *FyAnalysisClient) ReportURL(ctx context.Context, req *ReportURLRequest,
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	log.Debug().
+	correlation.Logger(ctx).Debug().
 		Bool("success", reportResp.Success).
 		Int("url_score", reportResp.URLScore).
 		Msg("[FyAnalysis] Report response received")
@@ -106,8 +172,9 @@ func (c *FyAnalysisClient) GetReportsStats(ctx context.Context) (map[string]inte
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -125,6 +192,237 @@ func (c *FyAnalysisClient) GetReportsStats(ctx context.Context) (map[string]inte
 	return stats, nil
 }
 
+// UserReportsSummary reportes enviados por un usuario en un mes
+type UserReportsSummary struct {
+	Total        int            `json:"total"`
+	ByThreatType map[string]int `json:"by_threat_type"`
+	ByDay        map[string]int `json:"by_day"`
+	TopDomains   []struct {
+		Domain string `json:"domain"`
+		Count  int    `json:"count"`
+	} `json:"top_domains"`
+}
+
+// GetUserReportsSummary obtiene los reportes de URLs de un usuario en un mes
+func (c *FyAnalysisClient) GetUserReportsSummary(ctx context.Context, userID string, year, month int) (*UserReportsSummary, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/reports/users/%s?year=%d&month=%d", c.baseURL, url.PathEscape(userID), year, month)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+
+	var summary UserReportsSummary
+	if err := json.NewDecoder(resp.Body).Decode(&summary); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &summary, nil
+}
+
+// AnonymizeUserReports desvincula los reportes de URLs de un usuario (borrado de cuenta)
+func (c *FyAnalysisClient) AnonymizeUserReports(ctx context.Context, userID string) error {
+	httpReq, err := http.NewRequestWithContext(ctx, "DELETE", c.baseURL+"/api/v1/reports/users/"+url.PathEscape(userID), nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// AnalyzeResult veredicto del endpoint unificado de fy-analysis
+type AnalyzeResult struct {
+	Input             string   `json:"input"`
+	Type              string   `json:"type"`
+	RiskScore         int      `json:"risk_score"`
+	RiskLevel         string   `json:"risk_level"` // safe, warning, danger
+	Reasons           []string `json:"reasons"`
+	RecommendedAction string   `json:"recommended_action"`
+	Sources           []struct {
+		Name  string `json:"name"`
+		Found bool   `json:"found"`
+	} `json:"sources"`
+}
+
+// PositiveSources checkers que encontraron el input
+func (r *AnalyzeResult) PositiveSources() []string {
+	var names []string
+	for _, s := range r.Sources {
+		if s.Found {
+			names = append(names, s.Name)
+		}
+	}
+	return names
+}
+
+// Analyze analiza una URL, email o teléfono con POST /api/v1/analyze
+func (c *FyAnalysisClient) Analyze(ctx context.Context, input, inputType string) (*AnalyzeResult, error) {
+	jsonBody, err := json.Marshal(map[string]string{"input": input, "type": inputType})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v1/analyze", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+
+	var result AnalyzeResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// WhitelistRequest propuesta de un usuario para añadir un dominio a la whitelist
+type WhitelistRequest struct {
+	UserID      string `json:"user_id"`
+	Domain      string `json:"domain"`
+	Brand       string `json:"brand,omitempty"`
+	Reason      string `json:"reason"`
+	EvidenceURL string `json:"evidence_url,omitempty"`
+}
+
+// WhitelistRequestResponse respuesta de fy-analysis a la propuesta
+type WhitelistRequestResponse struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Domain    string `json:"domain,omitempty"`
+	Status    string `json:"status,omitempty"` // pending, whitelisted
+}
+
+// SubmitWhitelistRequest envía la propuesta a fy-analysis (la revisa un admin en fy-admin)
+func (c *FyAnalysisClient) SubmitWhitelistRequest(ctx context.Context, req *WhitelistRequest) (*WhitelistRequestResponse, error) {
+	jsonBody, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v1/whitelist/requests", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+
+	var result WhitelistRequestResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &result, nil
+}
+
+// UserNotification notificación in-app generada por fy-analysis
+type UserNotification struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
+}
+
+// GetUserNotifications obtiene las últimas notificaciones de un usuario
+func (c *FyAnalysisClient) GetUserNotifications(ctx context.Context, userID string, limit int) ([]UserNotification, error) {
+	endpoint := fmt.Sprintf("%s/api/v1/reports/users/%s/notifications?limit=%d", c.baseURL, url.PathEscape(userID), limit)
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+
+	var notifications []UserNotification
+	if err := json.NewDecoder(resp.Body).Decode(&notifications); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return notifications, nil
+}
+
+// MarkNotificationsRead marca como leídas las notificaciones de un usuario
+func (c *FyAnalysisClient) MarkNotificationsRead(ctx context.Context, userID string) (int64, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/api/v1/reports/users/"+url.PathEscape(userID)+"/notifications/read", nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create request: %w", err)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(httpReq.Header))
+
+	resp, err := c.do(httpReq)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("fy-analysis returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Marked int64 `json:"marked"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return result.Marked, nil
+}
+
 // Health verifica si fy-analysis está disponible
 func (c *FyAnalysisClient) Health(ctx context.Context) bool {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/health", nil)
@@ -132,7 +430,7 @@ func (c *FyAnalysisClient) Health(ctx context.Context) bool {
 		return false
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.doTimeout(req, c.httpClient.config.HealthTimeout)
 	if err != nil {
 		return false
 	}
@@ -1,27 +1,33 @@
 package services
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
-	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/correlation"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
 )
 
 type FyEngineClient struct {
 	baseURL    string
-	httpClient *http.Client
+	httpClient *resilientClient
 }
 
 // FyChatRequest request al chat de Fy
 type FyChatRequest struct {
-	UserID  string          `json:"user_id"`
-	Message string          `json:"message"`
-	Context []ContextMessage `json:"context"`
+	UserID         string           `json:"user_id"`
+	Message        string           `json:"message"`
+	Context        []ContextMessage `json:"context"`
+	AllowlistItems []string         `json:"allowlist_items,omitempty"` // Lista de confianza personal
 }
 
 type ContextMessage struct {
@@ -41,6 +47,13 @@ type AnalysisTrace struct {
 	LatencyMs   int64    `json:"latency_ms,omitempty"`
 }
 
+// ChatEntities URLs, emails y teléfonos extraídos del mensaje (en cualquier intent)
+type ChatEntities struct {
+	URLs   []string `json:"urls,omitempty"`
+	Emails []string `json:"emails,omitempty"`
+	Phones []string `json:"phones,omitempty"`
+}
+
 // FyChatResponse respuesta del chat de Fy
 type FyChatResponse struct {
 	Response          string         `json:"response"`
@@ -49,24 +62,31 @@ type FyChatResponse struct {
 	Intent            string         `json:"intent"`
 	AnalysisPerformed bool           `json:"analysis_performed"`
 	Trace             *AnalysisTrace `json:"trace,omitempty"`
+	Entities          *ChatEntities  `json:"entities,omitempty"`
 	Error             string         `json:"error,omitempty"`
 }
 
-func NewFyEngineClient(baseURL string, timeout time.Duration) *FyEngineClient {
+// NewFyEngineClient crea el cliente de fy-engine. timeout es el máximo de cualquier petición
+// (también de los streams); resilience.CallTimeout el de cada intento de Chat.
+func NewFyEngineClient(baseURL string, timeout time.Duration, resilience ResilienceConfig) *FyEngineClient {
 	return &FyEngineClient{
-		baseURL: baseURL,
-		httpClient: &http.Client{
-			Timeout: timeout,
-		},
+		baseURL:    baseURL,
+		httpClient: newResilientClient("fy-engine", timeout, resilience),
 	}
 }
 
+// Circuit estado del circuito de fy-engine
+func (c *FyEngineClient) Circuit() CircuitState {
+	return c.httpClient.Circuit()
+}
+
 // Chat envía un mensaje al chat de Fy
-func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conversationContext []ContextMessage) (*FyChatResponse, error) {
+func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conversationContext []ContextMessage, allowlist []string) (*FyChatResponse, error) {
 	reqBody := FyChatRequest{
-		UserID:  userID,
-		Message: message,
-		Context: conversationContext,
+		UserID:         userID,
+		Message:        message,
+		Context:        conversationContext,
+		AllowlistItems: allowlist,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
@@ -80,13 +100,15 @@ func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conve
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	correlation.Inject(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
 
-	log.Debug().
+	correlation.Logger(ctx).Debug().
 		Str("user_id", userID).
 		Str("message", truncate(message, 50)).
 		Msg("[FyEngine] Sending chat request")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req, c.httpClient.config.CallTimeout)
 	if err != nil {
 		return nil, fmt.Errorf("failed to send request: %w", err)
 	}
@@ -98,7 +120,7 @@ func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conve
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		log.Error().
+		correlation.Logger(ctx).Error().
 			Int("status", resp.StatusCode).
 			Str("body", string(body)).
 			Msg("[FyEngine] Request failed")
@@ -110,7 +132,7 @@ func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conve
 		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
 	}
 
-	log.Debug().
+	correlation.Logger(ctx).Debug().
 		Str("mood", fyResp.Mood).
 		Str("intent", fyResp.Intent).
 		Bool("analysis", fyResp.AnalysisPerformed).
@@ -119,6 +141,122 @@ func (c *FyEngineClient) Chat(ctx context.Context, userID, message string, conve
 	return &fyResp, nil
 }
 
+// ErrChatStreamUnsupported fy-engine no expone /chat/stream (versión anterior): usar Chat
+var ErrChatStreamUnsupported = errors.New("fy-engine does not support chat streaming")
+
+// fyChatStreamEvent evento SSE de POST /chat/stream: "token" con un fragmento de texto,
+// "final" con la respuesta completa (campos de /chat) o "error". Termina con data: [DONE].
+type fyChatStreamEvent struct {
+	Type string `json:"type"`
+	Text string `json:"text,omitempty"`
+	FyChatResponse
+}
+
+// ChatStream envía un mensaje al chat de Fy en modo streaming: onToken recibe cada
+// fragmento según llega y devuelve la respuesta completa del evento final. Cancelar ctx
+// corta la petición a fy-engine. Devuelve ErrChatStreamUnsupported si fy-engine no
+// tiene el endpoint; si responde JSON en lugar de SSE, se entrega como un solo fragmento.
+func (c *FyEngineClient) ChatStream(ctx context.Context, userID, message string, conversationContext []ContextMessage, allowlist []string, onToken func(string)) (*FyChatResponse, error) {
+	reqBody := FyChatRequest{
+		UserID:         userID,
+		Message:        message,
+		Context:        conversationContext,
+		AllowlistItems: allowlist,
+	}
+
+	jsonBody, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/chat/stream", bytes.NewBuffer(jsonBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	correlation.Inject(req)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	correlation.Logger(ctx).Debug().
+		Str("user_id", userID).
+		Str("message", truncate(message, 50)).
+		Msg("[FyEngine] Sending chat stream request")
+
+	// Sin timeout por intento: el stream dura lo que tarde Fy en generar la respuesta
+	resp, err := c.httpClient.Do(req, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrChatStreamUnsupported
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		correlation.Logger(ctx).Error().
+			Int("status", resp.StatusCode).
+			Str("body", string(body)).
+			Msg("[FyEngine] Stream request failed")
+		return nil, fmt.Errorf("fy-engine returned status %d", resp.StatusCode)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		var fyResp FyChatResponse
+		if err := json.NewDecoder(resp.Body).Decode(&fyResp); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		onToken(fyResp.Response)
+		return &fyResp, nil
+	}
+
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue // líneas vacías, comentarios (: keepalive) y event:/id:
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var event fyChatStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "token":
+			text.WriteString(event.Text)
+			onToken(event.Text)
+		case "error":
+			return nil, fmt.Errorf("fy-engine stream error: %s", event.Error)
+		case "final":
+			fyResp := event.FyChatResponse
+			if fyResp.Response == "" {
+				fyResp.Response = text.String()
+			}
+			correlation.Logger(ctx).Debug().
+				Str("mood", fyResp.Mood).
+				Str("intent", fyResp.Intent).
+				Bool("analysis", fyResp.AnalysisPerformed).
+				Msg("[FyEngine] Chat stream completed")
+			return &fyResp, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stream: %w", err)
+	}
+	return nil, errors.New("fy-engine stream ended without final event")
+}
+
 // Health verifica si fy-engine está disponible
 func (c *FyEngineClient) Health(ctx context.Context) bool {
 	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/health", nil)
@@ -126,7 +264,7 @@ func (c *FyEngineClient) Health(ctx context.Context) bool {
 		return false
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.httpClient.Do(req, c.httpClient.config.HealthTimeout)
 	if err != nil {
 		return false
 	}
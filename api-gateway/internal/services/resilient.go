@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/api-gateway/internal/correlation"
+)
+
+var (
+	downstreamRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "downstream_retries_total",
+		Help: "Reintentos de peticiones a fy-engine y fy-analysis",
+	}, []string{"service"})
+
+	downstreamRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "downstream_circuit_rejected_total",
+		Help: "Peticiones rechazadas sin enviar por tener el circuito abierto",
+	}, []string{"service"})
+
+	downstreamCircuitOpen = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "downstream_circuit_open",
+		Help: "1 si el circuito del servicio está abierto o en prueba (half-open)",
+	}, []string{"service"})
+)
+
+// ErrCircuitOpen el servicio ha fallado repetidamente y se rechaza la petición sin enviarla
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// ResilienceConfig reintentos, circuito y pool de conexiones de un cliente de otro servicio
+type ResilienceConfig struct {
+	MaxRetries          int           // Reintentos tras el primer intento (0 = sin reintentos)
+	RetryBackoff        time.Duration // Espera antes del primer reintento; se duplica en cada uno
+	CallTimeout         time.Duration // Máximo por intento (0 = solo el timeout global del cliente)
+	HealthTimeout       time.Duration // Máximo del health check
+	BreakerThreshold    int           // Fallos consecutivos que abren el circuito (0 = sin circuito)
+	BreakerCooldown     time.Duration // Tiempo con el circuito abierto antes de dejar pasar una prueba
+	MaxIdleConnsPerHost int           // Conexiones keep-alive reutilizables con el servicio
+}
+
+// resilientClient cliente HTTP compartido por FyEngineClient y FyAnalysisClient.
+//
+//   - GET y HEAD se reintentan con backoff ante errores de red, timeouts del intento y 502/503/504.
+//   - El resto de métodos solo ante errores de conexión (rechazada, reset, cierre): un timeout o
+//     un 5xx pueden significar que el servicio ya procesó la petición.
+//   - Tras BreakerThreshold fallos consecutivos (errores de red o 5xx) el circuito se abre y las
+//     peticiones fallan con ErrCircuitOpen durante BreakerCooldown; después pasa una de prueba.
+type resilientClient struct {
+	service string
+	client  *http.Client
+	config  ResilienceConfig
+	breaker *circuitBreaker
+
+	// prepare se aplica a cada intento (firma con nonce nuevo: fy-analysis rechaza repetidos)
+	prepare func(*http.Request) error
+}
+
+func newResilientClient(service string, timeout time.Duration, config ResilienceConfig) *resilientClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerHost
+		if transport.MaxIdleConns < config.MaxIdleConnsPerHost {
+			transport.MaxIdleConns = config.MaxIdleConnsPerHost
+		}
+	}
+
+	return &resilientClient{
+		service: service,
+		client:  &http.Client{Timeout: timeout, Transport: transport},
+		config:  config,
+		breaker: newCircuitBreaker(service, config.BreakerThreshold, config.BreakerCooldown),
+	}
+}
+
+// Do envía la petición con reintentos y circuito. timeout es el máximo de cada intento
+// (0 = sin límite propio, para streams); el body de la respuesta lo mantiene vivo hasta Close.
+func (c *resilientClient) Do(req *http.Request, timeout time.Duration) (*http.Response, error) {
+	idempotent := req.Method == http.MethodGet || req.Method == http.MethodHead
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	backoff := c.config.RetryBackoff
+
+	for attempt := 0; ; attempt++ {
+		if err := c.breaker.allow(); err != nil {
+			downstreamRejected.WithLabelValues(c.service).Inc()
+			return nil, fmt.Errorf("%s: %w", c.service, err)
+		}
+
+		resp, cancel, err := c.attempt(req, attempt, timeout)
+
+		var retry bool
+		switch {
+		case err != nil && req.Context().Err() != nil:
+			// El llamante ha cancelado: no es un fallo del servicio
+			c.breaker.release()
+			cancel()
+			return nil, err
+		case err != nil:
+			c.breaker.failure()
+			retry = idempotent || isConnectionError(err)
+		case resp.StatusCode >= http.StatusInternalServerError:
+			c.breaker.failure()
+			retry = idempotent && isRetryableStatus(resp.StatusCode)
+		default:
+			c.breaker.success()
+		}
+
+		if !retry || !replayable || attempt >= c.config.MaxRetries {
+			if err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+			return resp, nil
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+			resp.Body.Close()
+		}
+		cancel()
+
+		downstreamRetries.WithLabelValues(c.service).Inc()
+		correlation.Logger(req.Context()).Debug().
+			Err(err).
+			Str("service", c.service).
+			Str("path", req.URL.Path).
+			Int("attempt", attempt+1).
+			Dur("backoff", backoff).
+			Msg("[Downstream] Retrying request")
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// attempt envía un intento con su propio timeout y una copia fresca del body
+func (c *resilientClient) attempt(req *http.Request, attempt int, timeout time.Duration) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := req.Context(), context.CancelFunc(func() {})
+	if timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+	}
+
+	attemptReq := req.Clone(ctx)
+	if attempt > 0 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, cancel, err
+		}
+		attemptReq.Body = body
+	}
+	if c.prepare != nil {
+		if err := c.prepare(attemptReq); err != nil {
+			return nil, cancel, err
+		}
+	}
+
+	resp, err := c.client.Do(attemptReq)
+	return resp, cancel, err
+}
+
+// Circuit estado del circuito del servicio
+func (c *resilientClient) Circuit() CircuitState {
+	return c.breaker.state()
+}
+
+// cancelBody libera el timeout del intento al cerrar el body
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isConnectionError el servicio no llegó a recibir o a responder la petición por un problema
+// de la conexión (rechazada, reset, cerrada). Los timeouts no cuentan.
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func isRetryableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// Estados del circuito
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half_open"
+)
+
+// CircuitState estado del circuito de un servicio (health check del gateway)
+type CircuitState struct {
+	State               string     `json:"state"` // closed, open, half_open
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+	RetryAt             *time.Time `json:"retry_at,omitempty"` // Fin del cool-down (circuito abierto)
+}
+
+// circuitBreaker circuito por servicio: closed -> open tras threshold fallos consecutivos,
+// open -> half_open pasado el cooldown (una sola petición de prueba), half_open -> closed
+// si la prueba va bien o -> open si falla
+type circuitBreaker struct {
+	service   string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	current  string
+	failures int
+	openedAt time.Time
+	probing  bool // Hay una petición de prueba en curso (half_open)
+}
+
+func newCircuitBreaker(service string, threshold int, cooldown time.Duration) *circuitBreaker {
+	downstreamCircuitOpen.WithLabelValues(service).Set(0)
+	return &circuitBreaker{service: service, threshold: threshold, cooldown: cooldown, current: CircuitClosed}
+}
+
+// allow indica si la petición puede enviarse
+func (b *circuitBreaker) allow() error {
+	if b.threshold <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.current {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.current = CircuitHalfOpen
+		b.probing = true
+		return nil
+	case CircuitHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+func (b *circuitBreaker) success() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.current != CircuitClosed {
+		log.Info().Str("service", b.service).Msg("[Downstream] Circuit closed")
+		downstreamCircuitOpen.WithLabelValues(b.service).Set(0)
+	}
+	b.current = CircuitClosed
+	b.failures = 0
+	b.probing = false
+}
+
+func (b *circuitBreaker) failure() {
+	if b.threshold <= 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	b.probing = false
+	if b.current == CircuitHalfOpen || (b.current == CircuitClosed && b.failures >= b.threshold) {
+		b.current = CircuitOpen
+		b.openedAt = time.Now()
+		downstreamCircuitOpen.WithLabelValues(b.service).Set(1)
+		log.Warn().
+			Str("service", b.service).
+			Int("consecutive_failures", b.failures).
+			Dur("cooldown", b.cooldown).
+			Msg("[Downstream] Circuit opened")
+	}
+}
+
+// release libera la prueba de half_open sin resultado (petición cancelada por el llamante)
+func (b *circuitBreaker) release() {
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+func (b *circuitBreaker) state() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	state := CircuitState{State: b.current, ConsecutiveFailures: b.failures}
+	if b.current != CircuitClosed {
+		openedAt := b.openedAt
+		state.OpenedAt = &openedAt
+		if b.current == CircuitOpen {
+			retryAt := b.openedAt.Add(b.cooldown)
+			state.RetryAt = &retryAt
+		}
+	}
+	return state
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// FyEngineClient y FyAnalysisClient contra servidores locales que fallan de forma intermitente
+// (503, 500, conexiones cortadas, respuestas lentas): reintentos, timeouts por intento y circuito
+// sin levantar fy-engine ni fy-analysis.
+
+// Comportamientos del servidor en cada petición
+const (
+	stepOK          = "ok"
+	step500         = "500"
+	step503         = "503"
+	stepReset       = "reset" // Cierra la conexión sin responder (RST)
+	stepSlow        = "slow"  // Responde tras 300ms
+	slowResponse    = 300 * time.Millisecond
+	testCallTimeout = 100 * time.Millisecond
+)
+
+// flappingServer responde según steps (el último se repite) y guarda los nonces de la firma
+type flappingServer struct {
+	*httptest.Server
+
+	mu     sync.Mutex
+	steps  []string
+	hits   int
+	nonces map[string]bool
+}
+
+func newFlappingServer(steps ...string) *flappingServer {
+	s := &flappingServer{steps: steps, nonces: make(map[string]bool)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+func (s *flappingServer) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	step := s.steps[len(s.steps)-1]
+	if s.hits < len(s.steps) {
+		step = s.steps[s.hits]
+	}
+	s.hits++
+	if nonce := r.Header.Get("X-Trackfy-Nonce"); nonce != "" {
+		s.nonces[nonce] = true
+	}
+	s.mu.Unlock()
+
+	switch step {
+	case step500:
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	case step503:
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	case stepReset:
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err == nil {
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+			conn.Close()
+		}
+		return
+	case stepSlow:
+		select {
+		case <-time.After(slowResponse):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"response":"ok","total":1}`))
+}
+
+func (s *flappingServer) Hits() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits
+}
+
+func (s *flappingServer) Nonces() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.nonces)
+}
+
+func testConfig() ResilienceConfig {
+	return ResilienceConfig{
+		MaxRetries:          2,
+		RetryBackoff:        5 * time.Millisecond,
+		CallTimeout:         testCallTimeout,
+		HealthTimeout:       testCallTimeout,
+		BreakerThreshold:    3,
+		BreakerCooldown:     150 * time.Millisecond,
+		MaxIdleConnsPerHost: 4,
+	}
+}
+
+// resilienceScenarios escenarios contra un servidor que falla de forma intermitente;
+// run devuelve el error si el cliente no se comporta como se espera
+var resilienceScenarios = []struct {
+	name string
+	run  func() error
+}{
+	{"GET retried on 503 until success", func() error {
+		srv := newFlappingServer(step503, step503, stepOK)
+		defer srv.Close()
+		client := NewFyAnalysisClient(srv.URL, "", 5*time.Second, testConfig())
+		if _, err := client.GetReportsStats(context.Background()); err != nil {
+			return err
+		}
+		return wantHits(srv, 3)
+	}},
+	{"GET gives up after MaxRetries", func() error {
+		srv := newFlappingServer(step503)
+		defer srv.Close()
+		config := testConfig()
+		config.BreakerThreshold = 0
+		client := NewFyAnalysisClient(srv.URL, "", 5*time.Second, config)
+		if _, err := client.GetReportsStats(context.Background()); err == nil {
+			return errors.New("expected an error")
+		}
+		return wantHits(srv, 3)
+	}},
+	{"GET retried after per-call timeout", func() error {
+		srv := newFlappingServer(stepSlow, stepOK)
+		defer srv.Close()
+		client := NewFyAnalysisClient(srv.URL, "", 5*time.Second, testConfig())
+		start := time.Now()
+		if _, err := client.GetReportsStats(context.Background()); err != nil {
+			return err
+		}
+		if elapsed := time.Since(start); elapsed >= slowResponse {
+			return fmt.Errorf("took %s, per-call timeout not applied", elapsed)
+		}
+		return wantHits(srv, 2)
+	}},
+	{"POST retried on connection reset", func() error {
+		srv := newFlappingServer(stepReset, stepOK)
+		defer srv.Close()
+		client := NewFyEngineClient(srv.URL, 5*time.Second, testConfig())
+		if _, err := client.Chat(context.Background(), "u", "hola", nil, nil); err != nil {
+			return err
+		}
+		return wantHits(srv, 2)
+	}},
+	{"POST not retried on 500", func() error {
+		srv := newFlappingServer(step500, stepOK)
+		defer srv.Close()
+		client := NewFyEngineClient(srv.URL, 5*time.Second, testConfig())
+		if _, err := client.Chat(context.Background(), "u", "hola", nil, nil); err == nil {
+			return errors.New("expected an error")
+		}
+		return wantHits(srv, 1)
+	}},
+	{"POST not retried on timeout", func() error {
+		srv := newFlappingServer(stepSlow, stepOK)
+		defer srv.Close()
+		client := NewFyAnalysisClient(srv.URL, "", 5*time.Second, testConfig())
+		if _, err := client.Analyze(context.Background(), "http://evil.com", "url"); err == nil {
+			return errors.New("expected an error")
+		}
+		return wantHits(srv, 1)
+	}},
+	{"each attempt signed with a new nonce", func() error {
+		srv := newFlappingServer(step503, step503, stepOK)
+		defer srv.Close()
+		client := NewFyAnalysisClient(srv.URL, "secret", 5*time.Second, testConfig())
+		if _, err := client.GetReportsStats(context.Background()); err != nil {
+			return err
+		}
+		if nonces := srv.Nonces(); nonces != 3 {
+			return fmt.Errorf("%d distinct nonces for 3 attempts", nonces)
+		}
+		return nil
+	}},
+	{"circuit opens, fails fast and recovers", func() error {
+		srv := newFlappingServer(step500, step500, step500, stepOK)
+		defer srv.Close()
+		client := NewFyEngineClient(srv.URL, 5*time.Second, testConfig())
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			client.Chat(ctx, "u", "hola", nil, nil)
+		}
+		if state := client.Circuit(); state.State != CircuitOpen || state.RetryAt == nil {
+			return fmt.Errorf("circuit %+v after 3 failures, want open", state)
+		}
+		if _, err := client.Chat(ctx, "u", "hola", nil, nil); !errors.Is(err, ErrCircuitOpen) {
+			return fmt.Errorf("open circuit returned %v, want ErrCircuitOpen", err)
+		}
+		if client.Health(ctx) {
+			return errors.New("health check passed with the circuit open")
+		}
+		if err := wantHits(srv, 3); err != nil {
+			return err
+		}
+
+		time.Sleep(testConfig().BreakerCooldown)
+		if _, err := client.Chat(ctx, "u", "hola", nil, nil); err != nil {
+			return fmt.Errorf("probe after cooldown: %w", err)
+		}
+		if state := client.Circuit(); state.State != CircuitClosed || state.ConsecutiveFailures != 0 {
+			return fmt.Errorf("circuit %+v after successful probe, want closed", state)
+		}
+		return nil
+	}},
+	{"failed probe reopens the circuit", func() error {
+		srv := newFlappingServer(step500)
+		defer srv.Close()
+		client := NewFyEngineClient(srv.URL, 5*time.Second, testConfig())
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			client.Chat(ctx, "u", "hola", nil, nil)
+		}
+		time.Sleep(testConfig().BreakerCooldown)
+		client.Chat(ctx, "u", "hola", nil, nil)
+		if state := client.Circuit(); state.State != CircuitOpen {
+			return fmt.Errorf("circuit %+v after failed probe, want open", state)
+		}
+		return wantHits(srv, 4)
+	}},
+	{"cancelled caller does not count as failure", func() error {
+		srv := newFlappingServer(stepSlow)
+		defer srv.Close()
+		config := testConfig()
+		config.CallTimeout = 0
+		client := NewFyEngineClient(srv.URL, 5*time.Second, config)
+		for i := 0; i < 3; i++ {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			client.Chat(ctx, "u", "hola", nil, nil)
+			cancel()
+		}
+		if state := client.Circuit(); state.State != CircuitClosed || state.ConsecutiveFailures != 0 {
+			return fmt.Errorf("circuit %+v after cancelled calls, want closed", state)
+		}
+		return nil
+	}},
+}
+
+func wantHits(srv *flappingServer, want int) error {
+	if got := srv.Hits(); got != want {
+		return fmt.Errorf("server received %d requests, want %d", got, want)
+	}
+	return nil
+}
+
+func TestResilientClients(t *testing.T) {
+	for _, scenario := range resilienceScenarios {
+		t.Run(scenario.name, func(t *testing.T) {
+			if err := scenario.run(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
@@ -12,12 +12,12 @@ CREATE EXTENSION IF NOT EXISTS "uuid-ossp";
 -- ============================================
 CREATE TABLE IF NOT EXISTS users (
     id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
-    phone VARCHAR(15) NOT NULL UNIQUE,  -- Número normalizado: +34612345678
+    phone VARCHAR(64) UNIQUE,  -- Número normalizado: +34612345678 (NULL tras borrar la cuenta)
     country_code VARCHAR(4) DEFAULT '34',
 
-    -- Datos personales
-    nombre VARCHAR(50) NOT NULL,
-    apellidos VARCHAR(100) NOT NULL,
+    -- Datos personales (NULL tras borrar la cuenta)
+    nombre VARCHAR(50),
+    apellidos VARCHAR(100),
 
     -- Estado
     is_active BOOLEAN DEFAULT true,
@@ -27,12 +27,36 @@ CREATE TABLE IF NOT EXISTS users (
     created_at TIMESTAMP NOT NULL DEFAULT NOW(),
     updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
     last_login TIMESTAMP,
+    deleted_at TIMESTAMP,  -- Borrado de cuenta (RGPD): la fila queda anonimizada
 
     -- Configuración
     language VARCHAR(5) DEFAULT 'es',
-    notifications_enabled BOOLEAN DEFAULT true
+    notifications_enabled BOOLEAN DEFAULT true,
+
+    -- Plan
+    is_premium BOOLEAN DEFAULT false,
+
+    -- Suscripción de Stripe (NULL = sin suscripción: manda is_premium)
+    subscription_status VARCHAR(20),  -- trialing, active, past_due, canceled, unpaid...
+    subscription_period_end TIMESTAMP,  -- current_period_end
+    subscription_cancel_at_period_end BOOLEAN NOT NULL DEFAULT false,
+    subscription_started_at TIMESTAMP,  -- Primera vez en trialing/active
+    subscription_canceled_at TIMESTAMP  -- Cancelación pedida (fin de periodo o inmediata)
 );
 
+-- Bases de datos existentes (creadas antes de la columna is_premium)
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_premium BOOLEAN DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS subscription_status VARCHAR(20);
+ALTER TABLE users ADD COLUMN IF NOT EXISTS subscription_period_end TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS subscription_cancel_at_period_end BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS subscription_started_at TIMESTAMP;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS subscription_canceled_at TIMESTAMP;
+ALTER TABLE users ALTER COLUMN phone TYPE VARCHAR(64);
+ALTER TABLE users ALTER COLUMN phone DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN nombre DROP NOT NULL;
+ALTER TABLE users ALTER COLUMN apellidos DROP NOT NULL;
+
 CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone);
 CREATE INDEX IF NOT EXISTS idx_users_active ON users(id) WHERE is_active = true;
 
@@ -144,6 +168,48 @@ CREATE TABLE IF NOT EXISTS messages (
 
 CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_id, created_at);
 
+-- ============================================
+-- TABLAS: archived_conversations, archived_messages
+-- Conversaciones archivadas (archiver semanal o a mano) fuera de las tablas calientes.
+-- Mismas columnas que conversations/messages; al desarchivar vuelven a ellas.
+-- ============================================
+CREATE TABLE IF NOT EXISTS archived_conversations (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    title VARCHAR(100),
+
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+
+    is_active BOOLEAN DEFAULT true,
+    message_count INTEGER DEFAULT 0,
+
+    archived_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_conversations_user ON archived_conversations(user_id, updated_at DESC);
+
+CREATE TABLE IF NOT EXISTS archived_messages (
+    id UUID PRIMARY KEY,
+    conversation_id UUID NOT NULL REFERENCES archived_conversations(id) ON DELETE CASCADE,
+
+    role VARCHAR(10) NOT NULL,
+    content TEXT NOT NULL,
+
+    intent VARCHAR(20),
+    mood VARCHAR(20),
+    analysis_performed BOOLEAN DEFAULT false,
+    entities_found JSONB,
+
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_archived_messages_conversation ON archived_messages(conversation_id, created_at);
+
+-- Candidatas del archiver: conversaciones sin actividad reciente
+CREATE INDEX IF NOT EXISTS idx_conversations_updated ON conversations(updated_at) WHERE is_active = true;
+
 -- ============================================
 -- TABLA: user_stats
 -- Estadísticas del usuario
@@ -166,6 +232,126 @@ CREATE TABLE IF NOT EXISTS user_stats (
     updated_at TIMESTAMP NOT NULL DEFAULT NOW()
 );
 
+-- ============================================
+-- TABLA: user_allowlist
+-- Lista de confianza personal (dominios, emails y teléfonos propios)
+-- Independiente de whitelist_domains (global)
+-- ============================================
+CREATE TABLE IF NOT EXISTS user_allowlist (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    indicator_type TEXT NOT NULL CHECK (indicator_type IN ('url', 'email', 'phone')),
+    indicator_value TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (user_id, indicator_type, indicator_value)
+);
+
+-- ============================================
+-- TABLA: analysis_results
+-- Un registro por análisis realizado en el chat (informes mensuales)
+-- ============================================
+CREATE TABLE IF NOT EXISTS analysis_results (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    entity_type VARCHAR(10) NOT NULL,  -- url, email, phone
+    entity_value TEXT NOT NULL,
+    domain VARCHAR(253),               -- Dominio de la URL o del email (NULL en teléfonos)
+
+    risk_score SMALLINT NOT NULL DEFAULT 0,
+    verdict VARCHAR(20) NOT NULL DEFAULT 'unknown',  -- safe, suspicious, dangerous, unknown
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_analysis_results_user ON analysis_results(user_id, created_at DESC);
+
+-- SHA-256 del valor en minúsculas: el webhook de reclasificación de fy-analysis identifica el input por este hash
+ALTER TABLE analysis_results ADD COLUMN IF NOT EXISTS input_hash BYTEA;
+CREATE INDEX IF NOT EXISTS idx_analysis_results_hash ON analysis_results(input_hash, created_at DESC);
+
+-- Checkers de fy-analysis que dieron positivo (NULL en los análisis anteriores a la columna)
+ALTER TABLE analysis_results ADD COLUMN IF NOT EXISTS checkers TEXT[];
+-- Agregados globales de /api/v1/analytics y /api/v1/threats/trending por ventana de tiempo
+CREATE INDEX IF NOT EXISTS idx_analysis_results_created ON analysis_results(created_at DESC) INCLUDE (entity_type, domain, verdict, risk_score);
+
+-- ============================================
+-- TABLA: user_entities
+-- URLs, emails y teléfonos que el usuario ha escrito en el chat, uno por valor
+-- normalizado, con el último veredicto (pantalla "mis enlaces comprobados")
+-- ============================================
+CREATE TABLE IF NOT EXISTS user_entities (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    entity_type VARCHAR(10) NOT NULL,  -- url, email, phone
+    entity_key TEXT NOT NULL,          -- Valor normalizado (deduplicación)
+    entity_value TEXT NOT NULL,        -- Tal como lo escribió la última vez
+
+    risk_score SMALLINT,               -- NULL = nunca analizado
+    verdict VARCHAR(20),               -- safe, suspicious, dangerous
+    analyzed_at TIMESTAMP,
+
+    times_seen INTEGER NOT NULL DEFAULT 1,
+    first_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_message_id UUID,              -- Sin FK: el mensaje puede archivarse
+
+    PRIMARY KEY (user_id, entity_type, entity_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_entities_recent ON user_entities(user_id, last_seen_at DESC);
+
+-- ============================================
+-- TABLA: user_devices
+-- Tokens push (FCM/APNs) de los dispositivos, ligados a la sesión que los registró
+-- ============================================
+CREATE TABLE IF NOT EXISTS user_devices (
+    token TEXT PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id UUID NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    platform VARCHAR(10) NOT NULL CHECK (platform IN ('android', 'ios')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_devices_user ON user_devices(user_id);
+
+-- ============================================
+-- TABLA: audit_log
+-- Operaciones sensibles sobre cuentas (borrados RGPD)
+-- ============================================
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    action VARCHAR(50) NOT NULL,  -- account_deleted
+    user_id UUID NOT NULL,        -- Sin FK: el registro sobrevive a la cuenta
+    details JSONB,
+    ip_address INET,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, created_at DESC);
+
+-- ============================================
+-- TABLA: payments
+-- Historial de cobros de Stripe (invoice.paid / invoice.payment_failed).
+-- Importes en céntimos de su moneda: no se suman monedas distintas.
+-- ============================================
+CREATE TABLE IF NOT EXISTS payments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL,  -- Sin FK: el registro contable sobrevive a la cuenta (al borrarla, uuid nil)
+    stripe_invoice_id VARCHAR(64) NOT NULL,
+    amount_cents BIGINT NOT NULL,
+    currency CHAR(3) NOT NULL,  -- ISO 4217 en minúsculas (como Stripe)
+    status VARCHAR(20) NOT NULL CHECK (status IN ('succeeded', 'failed', 'refunded')),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    -- Un cobro fallido y su reintento correcto son dos filas de la misma factura
+    UNIQUE (stripe_invoice_id, status)
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_user ON payments(user_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_payments_created ON payments(created_at DESC) INCLUDE (status, currency, amount_cents);
+
 -- ============================================
 -- FUNCIONES
 -- ============================================
@@ -275,6 +461,6 @@ BEGIN
     RAISE NOTICE '==========================================';
     RAISE NOTICE 'API Gateway Database Schema - Instalado';
     RAISE NOTICE '==========================================';
-    RAISE NOTICE 'Tablas: users, verification_codes, sessions, conversations, messages, user_stats';
+    RAISE NOTICE 'Tablas: users, verification_codes, sessions, conversations, messages, archived_conversations, archived_messages, user_stats, user_allowlist, analysis_results, user_entities, user_devices, audit_log, payments';
     RAISE NOTICE '==========================================';
 END $$;
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// adminPermission permiso de un token de administración
+type adminPermission string
+
+const (
+	// permFinancial datos de negocio: suscripciones, ingresos y pagos
+	permFinancial adminPermission = "financial"
+)
+
+// knownAdminPermissions permisos válidos en ADMIN_TOKENS
+var knownAdminPermissions = map[adminPermission]bool{
+	permFinancial: true,
+}
+
+// adminToken token de ADMIN_TOKENS (solo se guarda su SHA256)
+type adminToken struct {
+	name        string
+	hash        [sha256.Size]byte
+	permissions map[adminPermission]bool
+}
+
+// adminAuth tokens de administración para los endpoints que exponen datos sensibles. El resto
+// del panel no pide token (acceso restringido por red); las acciones de alto riesgo piden TOTP.
+type adminAuth struct {
+	tokens []adminToken
+}
+
+// parseAdminTokens interpreta ADMIN_TOKENS: "nombre:token:permiso|permiso,nombre:token:permiso"
+func parseAdminTokens(spec string) (*adminAuth, error) {
+	auth := &adminAuth{}
+	for i, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" {
+			// Sin el contenido: la entrada mal formada puede ser el propio token
+			return nil, fmt.Errorf("invalid ADMIN_TOKENS entry #%d (expected name:token:permissions)", i+1)
+		}
+		token := adminToken{
+			name:        parts[0],
+			hash:        sha256.Sum256([]byte(parts[1])),
+			permissions: make(map[adminPermission]bool),
+		}
+		for _, p := range strings.Split(parts[2], "|") {
+			perm := adminPermission(strings.TrimSpace(p))
+			if perm == "" {
+				continue
+			}
+			if !knownAdminPermissions[perm] {
+				return nil, fmt.Errorf("unknown permission %q for admin token %q", perm, token.name)
+			}
+			token.permissions[perm] = true
+		}
+		auth.tokens = append(auth.tokens, token)
+	}
+	return auth, nil
+}
+
+// authenticate token de Authorization: Bearer; nil si no coincide con ninguno
+func (a *adminAuth) authenticate(r *http.Request) *adminToken {
+	raw := strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	if a == nil || raw == "" {
+		return nil
+	}
+
+	hash := sha256.Sum256([]byte(raw))
+	var match *adminToken
+	for i := range a.tokens {
+		// Se comparan todos para no revelar por tiempos qué entrada coincide
+		if subtle.ConstantTimeCompare(hash[:], a.tokens[i].hash[:]) == 1 {
+			match = &a.tokens[i]
+		}
+	}
+	return match
+}
+
+// adminAuthMiddleware exige un token de ADMIN_TOKENS con el permiso perm. Sin tokens
+// configurados el endpoint queda deshabilitado.
+func (s *Server) adminAuthMiddleware(perm adminPermission, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.adminAuth == nil || len(s.adminAuth.tokens) == 0 {
+			respondAdminAuth(w, http.StatusForbidden, "admin_auth_disabled", "No admin tokens configured (ADMIN_TOKENS)")
+			return
+		}
+
+		token := s.adminAuth.authenticate(r)
+		if token == nil {
+			log.Warn().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_addr", adminIP(r)).
+				Msg("[AdminAuth] Invalid token")
+			respondAdminAuth(w, http.StatusUnauthorized, "unauthorized", "Missing or invalid admin token")
+			return
+		}
+		if !token.permissions[perm] {
+			log.Warn().
+				Str("admin", token.name).
+				Str("permission", string(perm)).
+				Str("path", r.URL.Path).
+				Msg("[AdminAuth] Permission denied")
+			respondAdminAuth(w, http.StatusForbidden, "forbidden", "Admin token lacks the "+string(perm)+" permission")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func respondAdminAuth(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   code,
+		"message": message,
+	})
+}
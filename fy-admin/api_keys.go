@@ -0,0 +1,429 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// API keys de partners para la API de inteligencia de amenazas (/v1/ti, ver threat_api.go)
+const (
+	apiKeyPrefix    = "tfy_" // Las claves son tfy_ + 40 hex: reconocibles si se filtran
+	apiKeyBytes     = 20
+	apiKeyCacheTTL  = 30 * time.Second
+	apiKeyCacheSize = 1000
+
+	defaultAPIKeyRateLimit = 60
+	maxAPIKeyRateLimit     = 6000
+	maxAPIKeyNameLen       = 100
+	maxAPIKeyUsageDays     = 366
+)
+
+var errAPIKeyNotFound = errors.New("API key not found or already revoked")
+
+// apiKey clave válida (no revocada)
+type apiKey struct {
+	id        string
+	name      string
+	rateLimit int
+}
+
+type apiKeyCacheEntry struct {
+	key       *apiKey // nil = la clave no existe o está revocada
+	expiresAt time.Time
+}
+
+// apiKeyStore valida claves contra api_keys (cacheadas apiKeyCacheTTL para no consultar en
+// cada petición), aplica el límite por minuto de cada una y registra el uso
+type apiKeyStore struct {
+	db *sql.DB
+
+	mu      sync.Mutex
+	cache   map[string]apiKeyCacheEntry // Clave: hex(SHA256(api key))
+	windows map[string]budgetWindow     // Ventana de un minuto por id de clave
+}
+
+// budgetWindow peticiones de una clave en la ventana que empieza en start
+type budgetWindow struct {
+	start time.Time
+	count int
+}
+
+func newAPIKeyStore(db *sql.DB) *apiKeyStore {
+	return &apiKeyStore{
+		db:      db,
+		cache:   make(map[string]apiKeyCacheEntry),
+		windows: make(map[string]budgetWindow),
+	}
+}
+
+// hashAPIKey SHA256 de la clave tal y como se guarda en api_keys.key_hash
+func hashAPIKey(key string) []byte {
+	sum := sha256.Sum256([]byte(key))
+	return sum[:]
+}
+
+// authenticate clave de la petición (X-API-Key o Authorization: Bearer); nil si no es válida
+func (k *apiKeyStore) authenticate(ctx context.Context, r *http.Request) (*apiKey, error) {
+	raw := strings.TrimSpace(r.Header.Get("X-API-Key"))
+	if raw == "" {
+		raw = strings.TrimSpace(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+	}
+	if !strings.HasPrefix(raw, apiKeyPrefix) || len(raw) != len(apiKeyPrefix)+2*apiKeyBytes {
+		return nil, nil
+	}
+
+	hash := hashAPIKey(raw)
+	cacheKey := hex.EncodeToString(hash)
+	now := time.Now()
+
+	k.mu.Lock()
+	entry, ok := k.cache[cacheKey]
+	k.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.key, nil
+	}
+
+	key := &apiKey{}
+	err := k.db.QueryRowContext(ctx, `
+		SELECT id::text, name, rate_limit_per_minute
+		FROM api_keys
+		WHERE key_hash = $1 AND revoked_at IS NULL
+	`, hash).Scan(&key.id, &key.name, &key.rateLimit)
+	if errors.Is(err, sql.ErrNoRows) {
+		key = nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	k.mu.Lock()
+	if len(k.cache) >= apiKeyCacheSize {
+		k.cache = make(map[string]apiKeyCacheEntry)
+	}
+	k.cache[cacheKey] = apiKeyCacheEntry{key: key, expiresAt: now.Add(apiKeyCacheTTL)}
+	k.mu.Unlock()
+
+	return key, nil
+}
+
+// allow consume una petición del minuto en curso de key; si está agotado retorna cuánto
+// falta para el siguiente. remaining peticiones que quedan en la ventana.
+func (k *apiKeyStore) allow(key *apiKey, now time.Time) (ok bool, remaining int, retryAfter time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	window, found := k.windows[key.id]
+	if !found || now.Sub(window.start) >= time.Minute {
+		window = budgetWindow{start: now}
+	}
+	if window.count >= key.rateLimit {
+		return false, 0, window.start.Add(time.Minute).Sub(now)
+	}
+	window.count++
+	k.windows[key.id] = window
+	return true, key.rateLimit - window.count, 0
+}
+
+// forget saca de la cache las entradas de una clave revocada (efecto inmediato en esta instancia)
+func (k *apiKeyStore) forget(id string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for hash, entry := range k.cache {
+		if entry.key != nil && entry.key.id == id {
+			delete(k.cache, hash)
+		}
+	}
+	delete(k.windows, id)
+}
+
+// recordUsage registra la petición en api_key_usage y actualiza los contadores de la clave
+func (k *apiKeyStore) recordUsage(ctx context.Context, key *apiKey, endpoint string, status int, found bool) {
+	_, err := k.db.ExecContext(ctx, `
+		WITH usage AS (
+			INSERT INTO api_key_usage (key_id, endpoint, status, found)
+			VALUES ($1::uuid, $2, $3, $4)
+		)
+		UPDATE api_keys SET request_count = request_count + 1, last_used_at = NOW()
+		WHERE id = $1::uuid
+	`, key.id, endpoint, status, found)
+	if err != nil {
+		log.Error().Err(err).Str("key_id", key.id).Msg("[ThreatAPI] Failed to record usage")
+	}
+}
+
+// generateAPIKey clave nueva (tfy_ + 40 hex)
+func generateAPIKey() (string, error) {
+	b := make([]byte, apiKeyBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return apiKeyPrefix + hex.EncodeToString(b), nil
+}
+
+// handleListAPIKeys lista las API keys con sus contadores de uso
+// GET /api/admin/api-keys?revoked=true (incluye las revocadas)
+func (s *Server) handleListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id::text, name, key_prefix, rate_limit_per_minute, request_count, last_used_at,
+		       COALESCE(created_by, ''), created_at, revoked_at
+		FROM api_keys
+		WHERE revoked_at IS NULL OR $1
+		ORDER BY created_at DESC
+	`, r.URL.Query().Get("revoked") == "true")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	keys := []map[string]interface{}{}
+	for rows.Next() {
+		var id, name, prefix, createdBy string
+		var rateLimit int
+		var requestCount int64
+		var lastUsed, revokedAt sql.NullTime
+		var createdAt time.Time
+		if err := rows.Scan(&id, &name, &prefix, &rateLimit, &requestCount, &lastUsed, &createdBy, &createdAt, &revokedAt); err != nil {
+			continue
+		}
+
+		item := map[string]interface{}{
+			"id":                    id,
+			"name":                  name,
+			"key_prefix":            prefix,
+			"rate_limit_per_minute": rateLimit,
+			"request_count":         requestCount,
+			"created_by":            createdBy,
+			"created_at":            createdAt.Format(time.RFC3339),
+			"revoked":               revokedAt.Valid,
+		}
+		if lastUsed.Valid {
+			item["last_used_at"] = lastUsed.Time.Format(time.RFC3339)
+		}
+		if revokedAt.Valid {
+			item["revoked_at"] = revokedAt.Time.Format(time.RFC3339)
+		}
+		keys = append(keys, item)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "keys": keys, "count": len(keys)})
+}
+
+// handleCreateAPIKey crea una API key. La clave solo se devuelve en esta respuesta.
+// POST /api/admin/api-keys/new {"name": "Banco X", "rate_limit_per_minute": 60}
+func (s *Server) handleCreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	var input struct {
+		Name      string `json:"name"`
+		RateLimit int    `json:"rate_limit_per_minute"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" || len([]rune(input.Name)) > maxAPIKeyNameLen {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": fmt.Sprintf("name is required (max %d characters)", maxAPIKeyNameLen)})
+		return
+	}
+	if input.RateLimit == 0 {
+		input.RateLimit = defaultAPIKeyRateLimit
+	}
+	if input.RateLimit < 1 || input.RateLimit > maxAPIKeyRateLimit {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": fmt.Sprintf("rate_limit_per_minute must be between 1 and %d", maxAPIKeyRateLimit)})
+		return
+	}
+
+	key, err := generateAPIKey()
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to generate key"})
+		return
+	}
+	prefix := key[:len(apiKeyPrefix)+8]
+
+	ctx, cancel := s.writeCtx(r.Context())
+	defer cancel()
+
+	var id string
+	err = s.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (name, key_hash, key_prefix, rate_limit_per_minute, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id::text
+	`, input.Name, hashAPIKey(key), prefix, input.RateLimit, "admin:"+adminIP(r)).Scan(&id)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	s.auditLog(r, &AuditEntry{
+		Action:   "create_api_key",
+		Table:    "api_keys",
+		RecordID: id,
+		NewValue: s.audit.Snapshot(r.Context(), "api_keys", id),
+	})
+	log.Info().Str("key_id", id).Str("name", input.Name).Msg("[ThreatAPI] API key created")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":               true,
+		"id":                    id,
+		"name":                  input.Name,
+		"key":                   key,
+		"key_prefix":            prefix,
+		"rate_limit_per_minute": input.RateLimit,
+	})
+}
+
+// handleRevokeAPIKey revoca una API key (deja de aceptarse en como mucho apiKeyCacheTTL en
+// las demás instancias; en esta, al momento)
+// POST /api/admin/api-keys/{id}/revoke
+func (s *Server) handleRevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	id, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/api-keys/"), "/")
+	if !ok || action != "revoke" || !uuidRegex.MatchString(id) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not found"})
+		return
+	}
+
+	err := s.auditWrite(r, "revoke_api_key", "api_keys", id, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE api_keys SET revoked_at = NOW() WHERE id = $1::uuid AND revoked_at IS NULL
+		`, id)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errAPIKeyNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	s.apiKeys.forget(id)
+	log.Info().Str("key_id", id).Msg("[ThreatAPI] API key revoked")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "id": id})
+}
+
+// handleAPIKeyUsage peticiones por día de una API key (facturación)
+// GET /api/admin/api-keys/usage?id=...&days=30
+func (s *Server) handleAPIKeyUsage(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if !uuidRegex.MatchString(id) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "id is required"})
+		return
+	}
+	days := getQueryInt(r, "days", 30)
+	if days < 1 || days > maxAPIKeyUsageDays {
+		days = 30
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT date_trunc('day', created_at)::date::text, endpoint,
+		       COUNT(*), COUNT(*) FILTER (WHERE found), COUNT(*) FILTER (WHERE status = 429)
+		FROM api_key_usage
+		WHERE key_id = $1::uuid AND created_at >= NOW() - make_interval(days => $2)
+		GROUP BY 1, 2
+		ORDER BY 1, 2
+	`, id, days)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	usage := []map[string]interface{}{}
+	var total int64
+	for rows.Next() {
+		var day, endpoint string
+		var requests, found, throttled int64
+		if err := rows.Scan(&day, &endpoint, &requests, &found, &throttled); err != nil {
+			continue
+		}
+		total += requests
+		usage = append(usage, map[string]interface{}{
+			"day":       day,
+			"endpoint":  endpoint,
+			"requests":  requests,
+			"found":     found,
+			"throttled": throttled,
+		})
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"days":    days,
+		"total":   total,
+		"usage":   usage,
+	})
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/pkg/clientip"
+)
+
+const (
+	auditDefaultLimit = 50
+	auditMaxLimit     = 200
+)
+
+// AuditEntry operación de escritura registrada en audit_log
+type AuditEntry struct {
+	ID        string          `json:"id"`
+	Action    string          `json:"action"`
+	Table     string          `json:"table"`
+	RecordID  string          `json:"record_id"`
+	OldValue  json.RawMessage `json:"old_value,omitempty"`
+	NewValue  json.RawMessage `json:"new_value,omitempty"`
+	AdminIP   string          `json:"admin_ip"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// AuditFilter filtros de consulta del audit log
+type AuditFilter struct {
+	Action string
+	Table  string
+	Since  time.Time
+	Until  time.Time
+	Cursor string
+	Limit  int
+}
+
+// AuditLogger escribe y consulta audit_log. Solo inserta: la tabla es append-only.
+type AuditLogger struct {
+	db *sql.DB
+}
+
+func NewAuditLogger(db *sql.DB) *AuditLogger {
+	return &AuditLogger{db: db}
+}
+
+// auditRecordKeys cláusula WHERE para leer un registro por su clave legible
+var auditRecordKeys = map[string]string{
+	"api_keys":           "id = $1::uuid",
+	"threat_phones":      "phone_national = $1",
+	"threat_emails":      "email_hash = sha256_bytea($1)",
+	"threat_domains":     "domain_hash = sha256_bytea($1)",
+	"threat_paths":       "path_hash = decode($1, 'hex')",
+	"user_trust_scores":  "user_id = $1",
+	"whitelist_domains":  "domain_hash = sha256_bytea($1)",
+	"whitelist_requests": "id = $1::uuid",
+}
+
+// Snapshot retorna el registro actual como JSON, o nil si no existe
+func (a *AuditLogger) Snapshot(ctx context.Context, table, recordID string) json.RawMessage {
+	if a == nil {
+		return nil
+	}
+	where, ok := auditRecordKeys[table]
+	if !ok {
+		return nil
+	}
+
+	var value []byte
+	query := fmt.Sprintf("SELECT row_to_json(t) FROM %s t WHERE %s", table, where)
+	if err := a.db.QueryRowContext(ctx, query, recordID).Scan(&value); err != nil {
+		if err != sql.ErrNoRows {
+			log.Warn().Err(err).Str("table", table).Str("record_id", recordID).Msg("[Audit] Snapshot failed")
+		}
+		return nil
+	}
+	return value
+}
+
+// Log inserta una entrada y completa su ID y timestamp
+func (a *AuditLogger) Log(ctx context.Context, entry *AuditEntry) error {
+	if a == nil {
+		return nil
+	}
+	return a.db.QueryRowContext(ctx, `
+		INSERT INTO audit_log (action, table_name, record_id, old_value, new_value, admin_ip)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`, entry.Action, entry.Table, entry.RecordID, nullableJSON(entry.OldValue), nullableJSON(entry.NewValue), entry.AdminIP,
+	).Scan(&entry.ID, &entry.Timestamp)
+}
+
+// Query lista entradas de más reciente a más antigua y retorna el cursor de la siguiente página
+func (a *AuditLogger) Query(ctx context.Context, filter AuditFilter) ([]AuditEntry, string, error) {
+	query := `
+		SELECT id, action, table_name, record_id, old_value, new_value, admin_ip, created_at
+		FROM audit_log
+		WHERE 1=1
+	`
+	args := []interface{}{}
+	argCount := 0
+
+	if filter.Action != "" {
+		argCount++
+		query += fmt.Sprintf(" AND action = $%d", argCount)
+		args = append(args, filter.Action)
+	}
+	if filter.Table != "" {
+		argCount++
+		query += fmt.Sprintf(" AND table_name = $%d", argCount)
+		args = append(args, filter.Table)
+	}
+	if !filter.Since.IsZero() {
+		argCount++
+		query += fmt.Sprintf(" AND created_at >= $%d", argCount)
+		args = append(args, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		argCount++
+		query += fmt.Sprintf(" AND created_at < $%d", argCount)
+		args = append(args, filter.Until)
+	}
+	if filter.Cursor != "" {
+		cursorTime, cursorID, err := decodeAuditCursor(filter.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		query += fmt.Sprintf(" AND (created_at, id) < ($%d, $%d)", argCount+1, argCount+2)
+		argCount += 2
+		args = append(args, cursorTime, cursorID)
+	}
+
+	// Se pide una fila extra para saber si hay más páginas
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT %d", filter.Limit+1)
+
+	rows, err := a.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	entries := []AuditEntry{}
+	for rows.Next() {
+		var e AuditEntry
+		var oldValue, newValue []byte
+		if err := rows.Scan(&e.ID, &e.Action, &e.Table, &e.RecordID, &oldValue, &newValue, &e.AdminIP, &e.Timestamp); err != nil {
+			return nil, "", err
+		}
+		e.OldValue = oldValue
+		e.NewValue = newValue
+		entries = append(entries, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	nextCursor := ""
+	if len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+		last := entries[len(entries)-1]
+		nextCursor = encodeAuditCursor(last.Timestamp, last.ID)
+	}
+	return entries, nextCursor, nil
+}
+
+// encodeAuditCursor cursor opaco "unixnano:id" en base64
+func encodeAuditCursor(ts time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(ts.UnixNano(), 10) + ":" + id))
+}
+
+func decodeAuditCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	nanos, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid cursor")
+	}
+	return time.Unix(0, nanos), parts[1], nil
+}
+
+// nullableJSON guarda NULL en lugar de un JSON vacío
+func nullableJSON(value json.RawMessage) interface{} {
+	if len(value) == 0 {
+		return nil
+	}
+	return []byte(value)
+}
+
+// auditWrite ejecuta una escritura sobre un registro guardando su estado antes y después.
+// Un fallo del audit log se registra pero no revierte la operación.
+func (s *Server) auditWrite(r *http.Request, action, table, recordID string, write func() error) error {
+	ctx := r.Context()
+	oldValue := s.audit.Snapshot(ctx, table, recordID)
+
+	if err := write(); err != nil {
+		return err
+	}
+
+	s.auditLog(r, &AuditEntry{
+		Action:   action,
+		Table:    table,
+		RecordID: recordID,
+		OldValue: oldValue,
+		NewValue: s.audit.Snapshot(ctx, table, recordID),
+	})
+	return nil
+}
+
+// auditLog registra una operación que no modifica un único registro (sync, importaciones)
+func (s *Server) auditLog(r *http.Request, entry *AuditEntry) {
+	entry.AdminIP = adminIP(r)
+	if err := s.audit.Log(r.Context(), entry); err != nil {
+		log.Error().Err(err).
+			Str("action", entry.Action).
+			Str("table", entry.Table).
+			Str("record_id", entry.RecordID).
+			Msg("[Audit] Failed to log entry")
+	}
+}
+
+// adminIP IP del admin: RemoteAddr ya viene resuelto por clientip.RealIP (TRUSTED_PROXIES)
+func adminIP(r *http.Request) string {
+	return clientip.RemoteHost(r)
+}
+
+// auditJSON serializa un valor para old_value/new_value
+func auditJSON(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// handleAuditLog consulta el audit log
+// GET /api/audit/log?action=&table=&since=&until=&cursor=&limit=
+func (s *Server) handleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.audit == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	filter := AuditFilter{
+		Action: r.URL.Query().Get("action"),
+		Table:  r.URL.Query().Get("table"),
+		Cursor: r.URL.Query().Get("cursor"),
+		Limit:  getQueryInt(r, "limit", auditDefaultLimit),
+	}
+	if filter.Limit <= 0 || filter.Limit > auditMaxLimit {
+		filter.Limit = auditMaxLimit
+	}
+
+	for key, target := range map[string]*time.Time{"since": &filter.Since, "until": &filter.Until} {
+		value := r.URL.Query().Get(key)
+		if value == "" {
+			continue
+		}
+		t, err := parseAuditTime(value)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid " + key + " (use RFC3339 or YYYY-MM-DD)"})
+			return
+		}
+		// "until" con solo fecha incluye el día completo
+		if key == "until" && len(value) == len("2006-01-02") {
+			t = t.Add(24 * time.Hour)
+		}
+		*target = t
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	entries, nextCursor, err := s.audit.Query(ctx, filter)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"data":        entries,
+		"next_cursor": nextCursor,
+		"limit":       filter.Limit,
+	})
+}
+
+// parseAuditTime acepta RFC3339 o solo fecha
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
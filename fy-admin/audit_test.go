@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestAnalysisDBSyncAudit(t *testing.T) {
+	var upstreamPath string
+	analysis := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamPath = r.URL.RequestURI()
+		w.Write([]byte(`{"status":"started"}`))
+	}))
+	defer analysis.Close()
+
+	fake := newTagsDB()
+	s := newTagsServer(t, fake)
+	s.config = &Config{AnalysisURL: analysis.URL, SigningSecret: "secret"}
+	s.client = analysis.Client()
+
+	rec := httptest.NewRecorder()
+	s.handleAnalysisDBSync(rec, httptest.NewRequest(http.MethodPost, "/api/services/analysis/sync?db=phishtank", nil))
+
+	if rec.Code != http.StatusOK || upstreamPath != "/admin/sync?db=phishtank" {
+		t.Fatalf("status %d, upstream %q", rec.Code, upstreamPath)
+	}
+	if want := []string{"analysis_db_sync phishtank"}; !reflect.DeepEqual(fake.audits, want) {
+		t.Errorf("audits = %q, want %q", fake.audits, want)
+	}
+
+	// Sin parámetro se sincronizan todas las bases de datos
+	s.handleAnalysisDBSync(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/services/analysis/sync", nil))
+	if want := []string{"analysis_db_sync phishtank", "analysis_db_sync all"}; !reflect.DeepEqual(fake.audits, want) {
+		t.Errorf("audits = %q, want %q", fake.audits, want)
+	}
+
+	// Un GET no lanza nada ni se audita
+	s.handleAnalysisDBSync(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/services/analysis/sync", nil))
+	if len(fake.audits) != 2 {
+		t.Errorf("GET was audited: %q", fake.audits)
+	}
+}
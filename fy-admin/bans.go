@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	defaultBanReason = "Abuso del sistema de reportes"
+	maxBanReasonLen  = 200
+)
+
+// handleUserBan banea o readmite a un usuario en el sistema de reportes
+// POST /api/admin/users/{userID}/ban   {"reason": "..."}
+// POST /api/admin/users/{userID}/unban
+func (s *Server) handleUserBan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	userID, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/users/"), "/")
+	if !ok || userID == "" || (action != "ban" && action != "unban") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not found"})
+		return
+	}
+
+	if action == "unban" {
+		s.unbanUser(w, r, userID)
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+			return
+		}
+	}
+	reason := strings.TrimSpace(input.Reason)
+	if reason == "" {
+		reason = defaultBanReason
+	}
+	if len([]rune(reason)) > maxBanReasonLen {
+		reason = string([]rune(reason)[:maxBanReasonLen])
+	}
+
+	// trust_score = 0 para que cuente como baneo (flag + trust < 10); el trust previo se guarda
+	// para restaurarlo al readmitir. Un usuario sin reportes previos también se puede banear.
+	err := s.auditWrite(r, "ban_user", "user_trust_scores", userID, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO user_trust_scores (user_id, trust_score, flags, ban_reason, banned_at, banned_by, trust_before_ban)
+			VALUES ($1, 0, `+flagBanned.sql()+`, $2, NOW(), 'admin', 50)
+			ON CONFLICT (user_id) DO UPDATE SET
+				flags = user_trust_scores.flags | `+flagBanned.sql()+`,
+				trust_before_ban = CASE
+					WHEN `+flagBanned.isSet("user_trust_scores.flags")+` AND user_trust_scores.trust_score < 10
+					THEN user_trust_scores.trust_before_ban
+					ELSE user_trust_scores.trust_score
+				END,
+				trust_score = 0,
+				ban_reason = EXCLUDED.ban_reason,
+				banned_at = NOW(),
+				banned_by = 'admin',
+				updated_at = NOW()
+		`, userID, reason)
+		return err
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().Str("user_id", userID).Str("reason", reason).Msg("[Bans] User banned from reporting")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "User banned", "reason": reason})
+}
+
+// unbanUser levanta el baneo y restaura el trust previo (mínimo 20 para no volver a caer
+// en el baneo automático de report_url con el siguiente rechazo)
+func (s *Server) unbanUser(w http.ResponseWriter, r *http.Request, userID string) {
+	var updated int64
+	err := s.auditWrite(r, "unban_user", "user_trust_scores", userID, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE user_trust_scores SET
+				flags = flags & ~`+flagBanned.sql()+`,
+				trust_score = GREATEST(COALESCE(trust_before_ban, 50), 20),
+				ban_reason = NULL,
+				banned_at = NULL,
+				banned_by = NULL,
+				trust_before_ban = NULL,
+				updated_at = NOW()
+			WHERE user_id = $1 AND `+flagBanned.isSet("flags")+`
+		`, userID)
+		if err != nil {
+			return err
+		}
+		updated, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if updated == 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "User is not banned"})
+		return
+	}
+
+	log.Info().Str("user_id", userID).Msg("[Bans] User unbanned")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "User unbanned"})
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+	"regexp"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// headerCorrelationID identificador de una acción del panel en fy-dbsync y fy-analysis
+const headerCorrelationID = "X-Correlation-ID"
+
+var correlationIDRegex = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+type correlationKey struct{}
+
+// correlationMiddleware reutiliza el X-Correlation-ID de la petición o genera uno y lo deja
+// en el contexto (logs y llamadas a fy-dbsync/fy-analysis). Se devuelve en la respuesta.
+func correlationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(headerCorrelationID)
+		if !correlationIDRegex.MatchString(id) {
+			id = newCorrelationID()
+		}
+
+		w.Header().Set(headerCorrelationID, id)
+		next.ServeHTTP(w, r.WithContext(withCorrelationID(r.Context(), id)))
+	})
+}
+
+// withCorrelationID guarda id en ctx junto con un logger que lo añade a cada línea
+func withCorrelationID(ctx context.Context, id string) context.Context {
+	logger := log.With().Str("correlation_id", id).Logger()
+	return logger.WithContext(context.WithValue(ctx, correlationKey{}, id))
+}
+
+// correlationID correlation ID de ctx ("" en las sincronizaciones programadas)
+func correlationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// ctxLogger logger de ctx con correlation_id, o el global si ctx no tiene
+func ctxLogger(ctx context.Context) *zerolog.Logger {
+	if correlationID(ctx) == "" {
+		return &log.Logger
+	}
+	return zerolog.Ctx(ctx)
+}
+
+// setCorrelationHeader propaga el correlation ID del contexto de req a fy-dbsync/fy-analysis
+func setCorrelationHeader(req *http.Request) {
+	if id := correlationID(req.Context()); id != "" {
+		req.Header.Set(headerCorrelationID, id)
+	}
+}
+
+// newCorrelationID UUID v4 aleatorio
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORSConfig política CORS del panel (variables CORS_*, mismas que api-gateway)
+type CORSConfig struct {
+	// Orígenes exactos ("https://admin.trackfy.es"), subdominios ("https://*.trackfy.es") o "*".
+	// Vacío = solo mismo origen (el panel se sirve desde este servidor).
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	MaxAge           int // Segundos que el navegador cachea el preflight
+	AllowCredentials bool
+}
+
+func loadCORSConfig() CORSConfig {
+	maxAge, err := strconv.Atoi(getEnv("CORS_MAX_AGE", "300"))
+	if err != nil || maxAge < 0 {
+		maxAge = 300
+	}
+	return CORSConfig{
+		AllowedOrigins:   splitList(getEnv("CORS_ALLOWED_ORIGINS", "")),
+		AllowedMethods:   splitList(getEnv("CORS_ALLOWED_METHODS", "GET,POST")),
+		AllowedHeaders:   splitList(getEnv("CORS_ALLOWED_HEADERS", "Content-Type,X-TOTP-Code")),
+		MaxAge:           maxAge,
+		AllowCredentials: getEnv("CORS_ALLOW_CREDENTIALS", "false") == "true",
+	}
+}
+
+// corsMiddleware aplica cfg. A los orígenes no permitidos (o a un preflight con un método
+// o cabecera no permitidos) se les responde sin cabeceras CORS: el navegador bloquea la
+// respuesta y el servidor no devuelve un error.
+func corsMiddleware(cfg CORSConfig, next http.Handler) http.Handler {
+	methods := strings.Join(upperAll(cfg.AllowedMethods), ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+		if origin != "" {
+			w.Header().Add("Vary", "Origin")
+		}
+		allowed := originAllowed(cfg.AllowedOrigins, origin)
+
+		if preflight {
+			w.Header().Add("Vary", "Access-Control-Request-Method")
+			w.Header().Add("Vary", "Access-Control-Request-Headers")
+			if allowed &&
+				containsFold(cfg.AllowedMethods, r.Header.Get("Access-Control-Request-Method")) &&
+				headersAllowed(cfg.AllowedHeaders, r.Header.Get("Access-Control-Request-Headers")) {
+				setCORSOrigin(w, cfg, origin)
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				if headers != "" {
+					w.Header().Set("Access-Control-Allow-Headers", headers)
+				}
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(cfg.MaxAge))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		if allowed {
+			setCORSOrigin(w, cfg, origin)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func setCORSOrigin(w http.ResponseWriter, cfg CORSConfig, origin string) {
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if cfg.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// originAllowed indica si origin coincide con alguno de los permitidos: igualdad exacta,
+// "*" o comodín de subdominio ("https://*.trackfy.es" admite a.trackfy.es pero no trackfy.es)
+func originAllowed(allowed []string, origin string) bool {
+	if origin == "" {
+		return false
+	}
+	origin = strings.ToLower(origin)
+
+	for _, a := range allowed {
+		a = strings.ToLower(strings.TrimSpace(a))
+		if a == "*" || a == origin {
+			return true
+		}
+
+		scheme, suffix, ok := strings.Cut(a, "://*.")
+		if !ok || suffix == "" {
+			continue
+		}
+		host, ok := strings.CutPrefix(origin, scheme+"://")
+		if ok && len(host) > len(suffix)+1 && strings.HasSuffix(host, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// headersAllowed comprueba las cabeceras pedidas en el preflight (las CORS-safelisted siempre valen)
+func headersAllowed(allowed []string, requested string) bool {
+	for _, h := range strings.Split(requested, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		switch strings.ToLower(h) {
+		case "accept", "accept-language", "content-language":
+			continue
+		}
+		if !containsFold(allowed, h) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func upperAll(list []string) []string {
+	upper := make([]string, len(list))
+	for i, item := range list {
+		upper[i] = strings.ToUpper(item)
+	}
+	return upper
+}
+
+// splitList lista separada por comas, sin elementos vacíos
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
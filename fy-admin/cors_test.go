@@ -0,0 +1,160 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedOrigins: []string{"https://admin.trackfy.es", "https://*.trackfy.dev"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-TOTP-Code"},
+		MaxAge:         300,
+	}
+}
+
+// corsRequest pasa r por corsMiddleware e indica si llegó al handler
+func corsRequest(cfg CORSConfig, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	reached := false
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+		w.WriteHeader(http.StatusOK)
+	})
+	rec := httptest.NewRecorder()
+	corsMiddleware(cfg, next).ServeHTTP(rec, r)
+	return rec, reached
+}
+
+func preflightRequest(origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/api/whitelist/1", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+		allowed bool
+	}{
+		{"allowed origin and method", "https://admin.trackfy.es", "POST", "Content-Type, X-TOTP-Code", true},
+		{"subdomain wildcard", "https://staging.trackfy.dev", "GET", "", true},
+		{"safelisted header", "https://admin.trackfy.es", "GET", "Accept-Language", true},
+		{"DELETE from disallowed origin", "https://evil.example", "DELETE", "", false},
+		{"allowed method from disallowed origin", "https://evil.example", "POST", "Content-Type", false},
+		{"DELETE from allowed origin", "https://admin.trackfy.es", "DELETE", "", false},
+		{"disallowed header", "https://admin.trackfy.es", "POST", "Authorization", false},
+		{"wildcard does not match apex", "https://trackfy.dev", "GET", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, reached := corsRequest(testCORSConfig(), preflightRequest(tt.origin, tt.method, tt.headers))
+
+			// El preflight se responde siempre aquí, sin llegar a la API ni devolver un error
+			if reached || rec.Code != http.StatusNoContent {
+				t.Fatalf("status = %d, reached handler = %v; want 204 without reaching it", rec.Code, reached)
+			}
+			origin := rec.Header().Get("Access-Control-Allow-Origin")
+			if !tt.allowed {
+				if origin != "" || rec.Header().Get("Access-Control-Allow-Methods") != "" {
+					t.Errorf("disallowed preflight got CORS headers: %v", rec.Header())
+				}
+				return
+			}
+			if origin != tt.origin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", origin, tt.origin)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+				t.Errorf("Access-Control-Allow-Methods = %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "Content-Type, X-TOTP-Code" {
+				t.Errorf("Access-Control-Allow-Headers = %q", got)
+			}
+			if got := rec.Header().Get("Access-Control-Max-Age"); got != "300" {
+				t.Errorf("Access-Control-Max-Age = %q, want 300", got)
+			}
+			if rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+				t.Error("credentials allowed without CORS_ALLOW_CREDENTIALS")
+			}
+		})
+	}
+}
+
+func TestCORSSimpleRequest(t *testing.T) {
+	cfg := testCORSConfig()
+	cfg.AllowCredentials = true
+
+	r := httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	r.Header.Set("Origin", "https://admin.trackfy.es")
+	rec, reached := corsRequest(cfg, r)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "https://admin.trackfy.es" ||
+		rec.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("allowed origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+	if rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("Vary = %q, want Origin", rec.Header().Get("Vary"))
+	}
+
+	// Origen no permitido: la petición se sirve pero el navegador no deja leer la respuesta
+	r = httptest.NewRequest(http.MethodGet, "/api/stats", nil)
+	r.Header.Set("Origin", "https://evil.example")
+	rec, reached = corsRequest(cfg, r)
+	if !reached || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("disallowed origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+
+	// Mismo origen (sin Origin): sin cabeceras CORS
+	rec, reached = corsRequest(CORSConfig{}, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+	if !reached || len(rec.Header().Values("Access-Control-Allow-Origin")) != 0 || rec.Header().Get("Vary") != "" {
+		t.Errorf("same origin: reached = %v, headers = %v", reached, rec.Header())
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	allowed := []string{"https://admin.trackfy.es", " HTTPS://*.Trackfy.dev "}
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://admin.trackfy.es", true},
+		{"HTTPS://ADMIN.TRACKFY.ES", true},
+		{"https://a.b.trackfy.dev", true},
+		{"https://trackfy.dev", false},
+		{"http://a.trackfy.dev", false},
+		{"https://eviltrackfy.dev", false},
+		{"https://admin.trackfy.es.evil.example", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(allowed, tt.origin); got != tt.want {
+			t.Errorf("originAllowed(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+	if !originAllowed([]string{"*"}, "https://any.example") || originAllowed([]string{"*"}, "") {
+		t.Error("wildcard origin")
+	}
+}
+
+func TestLoadCORSConfig(t *testing.T) {
+	t.Setenv("CORS_ALLOWED_ORIGINS", " https://admin.trackfy.es, ,https://*.trackfy.dev")
+	t.Setenv("CORS_MAX_AGE", "-5")
+	cfg := loadCORSConfig()
+	if len(cfg.AllowedOrigins) != 2 || cfg.AllowedOrigins[1] != "https://*.trackfy.dev" {
+		t.Errorf("AllowedOrigins = %q", cfg.AllowedOrigins)
+	}
+	if cfg.MaxAge != 300 {
+		t.Errorf("MaxAge = %d, want the 300 default for invalid values", cfg.MaxAge)
+	}
+	if len(cfg.AllowedMethods) != 2 || cfg.AllowCredentials {
+		t.Errorf("defaults = %+v", cfg)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// wantExactCount ?exact=true pide COUNT(*) exactos en lugar de estimaciones
+func wantExactCount(r *http.Request) bool {
+	exact, _ := strconv.ParseBool(r.URL.Query().Get("exact"))
+	return exact
+}
+
+// tableCount filas de table. Sin exact usa la estimación de pg_class.reltuples (la mantienen
+// VACUUM/ANALYZE, sin recorrer la tabla); si la tabla aún no tiene estadísticas o exact es true,
+// hace COUNT(*). Devuelve si el valor es una estimación.
+func (s *Server) tableCount(ctx context.Context, table string, exact bool) (int64, bool) {
+	var count int64
+	if !exact {
+		err := s.db.QueryRowContext(ctx, `SELECT reltuples::bigint FROM pg_class WHERE oid = to_regclass($1)`, table).Scan(&count)
+		if err == nil && count > 0 {
+			return count, true
+		}
+	}
+	s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+table).Scan(&count)
+	return count, false
+}
+
+// groupCounts filas (valor, count) de query como [{key: valor, "count": n}]
+func (s *Server) groupCounts(ctx context.Context, key, query string) []map[string]interface{} {
+	result := []map[string]interface{}{}
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return result
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var value string
+		var count int64
+		if rows.Scan(&value, &count) == nil {
+			result = append(result, map[string]interface{}{
+				key:     value,
+				"count": count,
+			})
+		}
+	}
+	return result
+}
+
+// parallelStats ejecuta las consultas de estadísticas a la vez (la latencia total es la de la
+// más lenta) y junta sus resultados por clave. El pool de conexiones limita la concurrencia real.
+type parallelStats struct {
+	mu    sync.Mutex
+	wg    sync.WaitGroup
+	stats map[string]interface{}
+}
+
+func newParallelStats() *parallelStats {
+	return &parallelStats{stats: make(map[string]interface{})}
+}
+
+func (p *parallelStats) Go(key string, query func() interface{}) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		value := query()
+		p.mu.Lock()
+		p.stats[key] = value
+		p.mu.Unlock()
+	}()
+}
+
+// Wait espera a todas las consultas y devuelve los resultados
+func (p *parallelStats) Wait() map[string]interface{} {
+	p.wg.Wait()
+	return p.stats
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// seededTable tabla sembrada: filas, activas y desgloses por fuente y tipo
+type seededTable struct {
+	rows, active int64
+	bySource     map[string]int64
+	byType       map[string]int64
+}
+
+// seededStatsDB BD en memoria con los tamaños de producción. Las consultas que recorren la
+// tabla (COUNT(*), GROUP BY) tardan scanCost por millón de filas; la estimación de
+// pg_class.reltuples tarda catalogCost. Respeta la cancelación del contexto como PostgreSQL.
+type seededStatsDB struct {
+	tables      map[string]seededTable
+	scanCost    time.Duration
+	catalogCost time.Duration
+
+	cancelled atomic.Int32
+}
+
+var countFromRegex = regexp.MustCompile(`FROM\s+(\w+)`)
+
+func (c *seededStatsDB) Connect(context.Context) (driver.Conn, error) {
+	return &seededStatsConn{c}, nil
+}
+func (c *seededStatsDB) Driver() driver.Driver { return nil }
+
+type seededStatsConn struct{ db *seededStatsDB }
+
+func (c *seededStatsConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *seededStatsConn) Close() error              { return nil }
+func (c *seededStatsConn) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *seededStatsConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, "reltuples") {
+		if err := c.wait(ctx, c.db.catalogCost); err != nil {
+			return nil, err
+		}
+		table := c.db.tables[args[0].Value.(string)]
+		return &statsRows{columns: []string{"reltuples"}, values: [][]driver.Value{{table.rows}}}, nil
+	}
+
+	match := countFromRegex.FindStringSubmatch(query)
+	if match == nil {
+		return nil, errors.New("unexpected query: " + query)
+	}
+	table, ok := c.db.tables[match[1]]
+	if !ok {
+		return nil, errors.New(`relation "` + match[1] + `" does not exist`)
+	}
+	if err := c.wait(ctx, time.Duration(table.rows)*c.db.scanCost/1_000_000); err != nil {
+		return nil, err
+	}
+
+	var groups map[string]int64
+	switch {
+	case strings.Contains(query, "GROUP BY source"):
+		groups = table.bySource
+	case strings.Contains(query, "GROUP BY threat_type"):
+		groups = table.byType
+	case strings.Contains(query, "WHERE"):
+		return &statsRows{columns: []string{"count"}, values: [][]driver.Value{{table.active}}}, nil
+	default:
+		return &statsRows{columns: []string{"count"}, values: [][]driver.Value{{table.rows}}}, nil
+	}
+	rows := &statsRows{columns: []string{"value", "count"}}
+	for value, count := range groups {
+		rows.values = append(rows.values, []driver.Value{value, count})
+	}
+	return rows, nil
+}
+
+func (c *seededStatsConn) wait(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		c.db.cancelled.Add(1)
+		return ctx.Err()
+	}
+}
+
+type statsRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *statsRows) Columns() []string { return r.columns }
+func (r *statsRows) Close() error      { return nil }
+func (r *statsRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// newSeededStatsServer servidor con la BD sembrada; maxConns 1 serializa las consultas
+func newSeededStatsServer(t *testing.T, seeded *seededStatsDB, maxConns int, queryTimeout time.Duration) *Server {
+	t.Helper()
+	db := sql.OpenDB(seeded)
+	db.SetMaxOpenConns(maxConns)
+	t.Cleanup(func() { db.Close() })
+
+	health := newDBHealth(db)
+	health.healthy.Store(true)
+	return &Server{db: db, dbHealth: health, queryTimeout: queryTimeout}
+}
+
+func newSeededStatsDB() *seededStatsDB {
+	return &seededStatsDB{
+		tables: map[string]seededTable{
+			"threat_domains": {
+				rows: 800_000, active: 650_000,
+				bySource: map[string]int64{"urlhaus": 500_000, "phishtank": 300_000},
+				byType:   map[string]int64{"phishing": 400_000, "malware": 250_000},
+			},
+			"threat_paths": {rows: 300_000, active: 280_000, byType: map[string]int64{"phishing": 280_000}},
+			"threat_emails": {
+				rows:     3_000_000,
+				bySource: map[string]int64{"stopforumspam": 2_900_000, "manual": 100_000},
+			},
+			"threat_phones":     {rows: 200_000},
+			"whitelist_domains": {rows: 50_000},
+		},
+		scanCost:    10 * time.Millisecond,
+		catalogCost: time.Millisecond,
+	}
+}
+
+func getDatabaseStats(s *Server, query string) (map[string]json.RawMessage, time.Duration) {
+	rec := httptest.NewRecorder()
+	start := time.Now()
+	s.handleDatabaseStats(rec, httptest.NewRequest(http.MethodGet, "/api/stats"+query, nil))
+	elapsed := time.Since(start)
+
+	var stats map[string]json.RawMessage
+	_ = json.Unmarshal(rec.Body.Bytes(), &stats)
+	return stats, elapsed
+}
+
+func TestDatabaseStatsLatency(t *testing.T) {
+	if testing.Short() {
+		t.Skip("latency measurement")
+	}
+	seeded := newSeededStatsDB()
+
+	// Antes: COUNT(*) exactos uno detrás de otro (una conexión), la latencia es la suma
+	before, beforeLatency := getDatabaseStats(newSeededStatsServer(t, seeded, 1, 10*time.Second), "?exact=true")
+	// Después: consultas concurrentes y totales estimados con pg_class.reltuples
+	after, afterLatency := getDatabaseStats(newSeededStatsServer(t, seeded, 20, 10*time.Second), "")
+	t.Logf("stats latency: before %v, after %v", beforeLatency, afterLatency)
+
+	// Los totales sembrados salen igual con estimación que con COUNT(*); el recuento exacto no se marca como estimado
+	for _, key := range []string{"domains", "paths", "emails", "phones", "whitelist", "by_source", "emails_by_source", "by_threat_type"} {
+		if len(before[key]) == 0 || len(after[key]) == 0 {
+			t.Errorf("stats[%q] missing: before %s, after %s", key, before[key], after[key])
+		}
+	}
+	if string(before["domains"]) != `{"active":650000,"total":800000}` || string(after["domains"]) != string(before["domains"]) {
+		t.Errorf("domains: before %s, after %s", before["domains"], after["domains"])
+	}
+	if string(after["emails"]) != `{"total":3000000}` {
+		t.Errorf("emails = %s", after["emails"])
+	}
+	if string(before["estimated"]) != "false" || string(after["estimated"]) != "true" {
+		t.Errorf("estimated: before %s, after %s", before["estimated"], after["estimated"])
+	}
+
+	// La suma de los recorridos es ~100ms y el más lento (threat_emails agrupado) 30ms
+	if afterLatency*2 > beforeLatency {
+		t.Errorf("stats latency %v is not well below the sequential exact %v", afterLatency, beforeLatency)
+	}
+}
+
+func TestDatabaseStatsTimeout(t *testing.T) {
+	seeded := newSeededStatsDB()
+	seeded.scanCost = time.Second // threat_emails tardaría 3s
+
+	s := newSeededStatsServer(t, seeded, 20, 50*time.Millisecond)
+	_, elapsed := getDatabaseStats(s, "?exact=true")
+	if elapsed > time.Second {
+		t.Errorf("stats took %v with a 50ms query timeout", elapsed)
+	}
+	if seeded.cancelled.Load() == 0 {
+		t.Error("no query was cancelled at the timeout")
+	}
+}
+
+func TestDatabaseStatsClientGone(t *testing.T) {
+	seeded := newSeededStatsDB()
+	seeded.scanCost = time.Second
+	s := newSeededStatsServer(t, seeded, 20, 10*time.Second)
+
+	// El cliente se va: las consultas se cancelan sin esperar al timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	r := httptest.NewRequest(http.MethodGet, "/api/stats?exact=true", nil).WithContext(ctx)
+	time.AfterFunc(20*time.Millisecond, cancel)
+
+	start := time.Now()
+	s.handleDatabaseStats(httptest.NewRecorder(), r)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("stats took %v after the client disconnected", elapsed)
+	}
+	if seeded.cancelled.Load() == 0 {
+		t.Error("no query was cancelled")
+	}
+}
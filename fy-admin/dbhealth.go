@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Arranque: reintentos con backoff exponencial (1s, 2s, 4s... hasta dbConnectMaxBackoff)
+	defaultDBConnectRetries = 5
+	dbConnectInitialBackoff = time.Second
+	dbConnectMaxBackoff     = 15 * time.Second
+
+	defaultDBHealthInterval = 10 * time.Second
+	dbPingTimeout           = 3 * time.Second
+)
+
+// dbHealth estado de la conexión a PostgreSQL. sql.Open no conecta: el estado real lo da
+// el Ping del arranque y del bucle de fondo, que permite recuperarse si la BD arranca tarde.
+type dbHealth struct {
+	db      *sql.DB
+	healthy atomic.Bool
+
+	stopCh chan struct{}
+}
+
+func newDBHealth(db *sql.DB) *dbHealth {
+	return &dbHealth{db: db, stopCh: make(chan struct{})}
+}
+
+// Healthy indica si el último Ping fue correcto
+func (h *dbHealth) Healthy() bool {
+	return h != nil && h.healthy.Load()
+}
+
+// WaitForDB hace Ping con reintentos y backoff. Si se agotan, el servidor arranca igualmente
+// y el bucle de fondo sigue intentándolo.
+func (h *dbHealth) WaitForDB(retries int) bool {
+	backoff := dbConnectInitialBackoff
+	for attempt := 1; attempt <= retries; attempt++ {
+		err := h.ping()
+		if err == nil {
+			h.setHealthy(true, nil)
+			return true
+		}
+		log.Warn().Err(err).Int("attempt", attempt).Int("max_attempts", retries).Msg("[DB] Connection attempt failed")
+		if attempt == retries {
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-h.stopCh:
+			return false
+		}
+		backoff *= 2
+		if backoff > dbConnectMaxBackoff {
+			backoff = dbConnectMaxBackoff
+		}
+	}
+
+	log.Error().Int("attempts", retries).Msg("[DB] Database unavailable, will keep retrying in background")
+	return false
+}
+
+// Start comprueba la conexión cada interval. database/sql reabre las conexiones caídas,
+// así que el Ping basta para reconectar.
+func (h *dbHealth) Start(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stopCh:
+				return
+			case <-ticker.C:
+				err := h.ping()
+				h.setHealthy(err == nil, err)
+			}
+		}
+	}()
+}
+
+// Stop detiene el bucle de comprobación
+func (h *dbHealth) Stop() {
+	close(h.stopCh)
+}
+
+func (h *dbHealth) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), dbPingTimeout)
+	defer cancel()
+	return h.db.PingContext(ctx)
+}
+
+// setHealthy actualiza el estado y solo registra los cambios
+func (h *dbHealth) setHealthy(healthy bool, err error) {
+	if h.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		log.Info().Msg("[DB] Database connection healthy")
+	} else {
+		log.Error().Err(err).Msg("[DB] Database connection lost")
+	}
+}
+
+// dbReady indica si hay base de datos configurada y respondiendo
+func (s *Server) dbReady() bool {
+	return s.db != nil && s.dbHealth.Healthy()
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	domainDetailTimeout  = 5 * time.Second
+	domainDetailRowLimit = 100
+)
+
+// seenRange rango first_seen/last_seen de una de las fuentes del detalle
+type seenRange struct {
+	first sql.NullTime
+	last  sql.NullTime
+}
+
+// handleDomainDetail agrega todo lo conocido sobre un dominio
+// GET /api/data/domains/{domain}
+func (s *Server) handleDomainDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	domain := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/data/domains/")))
+	domain = strings.TrimPrefix(strings.TrimSuffix(domain, "/"), "www.")
+	if domain == "" || strings.Contains(domain, "/") {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid domain"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), domainDetailTimeout)
+	defer cancel()
+
+	var (
+		wg        sync.WaitGroup
+		errMu     sync.Mutex
+		queryErrs = map[string]string{}
+
+		threat    map[string]interface{}
+		paths     map[string]interface{}
+		emails    map[string]interface{}
+		reports   map[string]interface{}
+		tags      []string
+		whitelist map[string]interface{}
+
+		threatSeen, pathsSeen, emailsSeen, reportsSeen seenRange
+	)
+
+	run := func(name string, query func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := query(); err != nil {
+				errMu.Lock()
+				queryErrs[name] = err.Error()
+				errMu.Unlock()
+			}
+		}()
+	}
+
+	run("threat", func() (err error) {
+		threat, threatSeen, err = s.domainThreat(ctx, domain)
+		return err
+	})
+	run("paths", func() (err error) {
+		paths, pathsSeen, err = s.domainPaths(ctx, domain)
+		return err
+	})
+	run("emails", func() (err error) {
+		emails, emailsSeen, err = s.domainEmails(ctx, domain)
+		return err
+	})
+	run("reports", func() (err error) {
+		reports, reportsSeen, err = s.domainReports(ctx, domain)
+		return err
+	})
+	run("tags", func() (err error) {
+		tags, err = s.domainTags(ctx, domain)
+		return err
+	})
+	run("whitelist", func() (err error) {
+		whitelist, err = s.domainWhitelist(ctx, domain)
+		return err
+	})
+	wg.Wait()
+
+	known := threat != nil || whitelist != nil ||
+		countOf(paths) > 0 || countOf(emails) > 0 || countOf(reports) > 0
+	if !known && len(queryErrs) == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Domain not found: " + domain})
+		return
+	}
+
+	// Resumen: primera y última aparición en cualquier tabla
+	summary := map[string]interface{}{
+		"is_threat":      threat != nil,
+		"is_whitelisted": whitelist != nil,
+		// Conflicto: el dominio está en la whitelist y a la vez marcado como amenaza o reportado
+		"whitelist_conflict": whitelist != nil && (threat != nil || countOf(reports) > 0),
+	}
+	var firstSeen, lastSeen time.Time
+	for _, seen := range []seenRange{threatSeen, pathsSeen, emailsSeen, reportsSeen} {
+		if seen.first.Valid && (firstSeen.IsZero() || seen.first.Time.Before(firstSeen)) {
+			firstSeen = seen.first.Time
+		}
+		if seen.last.Valid && seen.last.Time.After(lastSeen) {
+			lastSeen = seen.last.Time
+		}
+	}
+	if !firstSeen.IsZero() {
+		summary["first_seen_anywhere"] = firstSeen.Format(time.RFC3339)
+	}
+	if !lastSeen.IsZero() {
+		summary["last_seen_anywhere"] = lastSeen.Format(time.RFC3339)
+	}
+
+	response := map[string]interface{}{
+		"success":   len(queryErrs) == 0,
+		"domain":    domain,
+		"summary":   summary,
+		"threat":    threat,
+		"paths":     paths,
+		"emails":    emails,
+		"reports":   reports,
+		"tags":      tags,
+		"whitelist": whitelist,
+	}
+	if len(queryErrs) > 0 {
+		response["errors"] = queryErrs
+	}
+
+	json.NewEncoder(w).Encode(response)
+}
+
+// countOf lee el contador "total" de una sección del detalle
+func countOf(section map[string]interface{}) int64 {
+	if section == nil {
+		return 0
+	}
+	total, _ := section["total"].(int64)
+	return total
+}
+
+func (s *Server) domainThreat(ctx context.Context, domain string) (map[string]interface{}, seenRange, error) {
+	var threatType, severity, source string
+	var sourceID, tld, impersonates sql.NullString
+	var confidence, reportCount, flags int
+	var hitCount int64
+	var firstSeen, lastSeen time.Time
+	var expiresAt sql.NullTime
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT td.threat_type::text, td.severity::text, td.confidence, td.source::text, td.source_id,
+		       td.tld, wd.domain, COALESCE(td.hit_count, 0), COALESCE(td.report_count, 0),
+		       td.first_seen, td.last_seen, td.expires_at, COALESCE(td.flags, 0)
+		FROM threat_domains td
+		LEFT JOIN whitelist_domains wd ON wd.domain_hash = td.impersonates_hash
+		WHERE td.domain_hash = sha256_bytea($1)
+	`, domain).Scan(&threatType, &severity, &confidence, &source, &sourceID, &tld, &impersonates,
+		&hitCount, &reportCount, &firstSeen, &lastSeen, &expiresAt, &flags)
+	if err == sql.ErrNoRows {
+		return nil, seenRange{}, nil
+	}
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+
+	threat := map[string]interface{}{
+		"threat_type":  threatType,
+		"severity":     severity,
+		"confidence":   confidence,
+		"source":       source,
+		"hit_count":    hitCount,
+		"report_count": reportCount,
+		"first_seen":   firstSeen.Format(time.RFC3339),
+		"last_seen":    lastSeen.Format(time.RFC3339),
+		"active":       flagActive.has(flags),
+		"flags":        decodeFlags("threat_domains", flags),
+		"flags_raw":    flags,
+	}
+	if sourceID.Valid {
+		threat["source_id"] = sourceID.String
+	}
+	if tld.Valid {
+		threat["tld"] = tld.String
+	}
+	if impersonates.Valid {
+		threat["impersonates"] = impersonates.String
+	}
+	if expiresAt.Valid {
+		threat["expires_at"] = expiresAt.Time.Format(time.RFC3339)
+	}
+
+	seen := seenRange{first: sql.NullTime{Time: firstSeen, Valid: true}, last: sql.NullTime{Time: lastSeen, Valid: true}}
+	return threat, seen, nil
+}
+
+func (s *Server) domainPaths(ctx context.Context, domain string) (map[string]interface{}, seenRange, error) {
+	var total, active int64
+	var seen seenRange
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE `+flagActive.isSet("flags")+`), MIN(first_seen), MAX(last_seen)
+		FROM threat_paths
+		WHERE domain_hash = sha256_bytea($1)
+	`, domain).Scan(&total, &active, &seen.first, &seen.last)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT path, threat_type::text, severity::text, confidence, payload_type, target_brand,
+		       source::text, first_seen, last_seen, COALESCE(flags, 0)
+		FROM threat_paths
+		WHERE domain_hash = sha256_bytea($1)
+		ORDER BY last_seen DESC
+		LIMIT %d
+	`, domainDetailRowLimit), domain)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var path, payloadType, targetBrand sql.NullString
+		var threatType, severity, source string
+		var confidence, flags int
+		var firstSeen, lastSeen time.Time
+
+		if err := rows.Scan(&path, &threatType, &severity, &confidence, &payloadType, &targetBrand,
+			&source, &firstSeen, &lastSeen, &flags); err != nil {
+			return nil, seenRange{}, err
+		}
+		item := map[string]interface{}{
+			"path":        path.String,
+			"threat_type": threatType,
+			"severity":    severity,
+			"confidence":  confidence,
+			"source":      source,
+			"first_seen":  firstSeen.Format(time.RFC3339),
+			"last_seen":   lastSeen.Format(time.RFC3339),
+			"active":      flagActive.has(flags),
+			"flags":       decodeFlags("threat_paths", flags),
+		}
+		if payloadType.Valid {
+			item["payload_type"] = payloadType.String
+		}
+		if targetBrand.Valid {
+			item["target_brand"] = targetBrand.String
+		}
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{"total": total, "active": active, "data": items}, seen, rows.Err()
+}
+
+func (s *Server) domainEmails(ctx context.Context, domain string) (map[string]interface{}, seenRange, error) {
+	var total int64
+	var seen seenRange
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), MIN(first_seen), MAX(last_seen)
+		FROM threat_emails
+		WHERE domain_hash = sha256_bytea($1)
+	`, domain).Scan(&total, &seen.first, &seen.last)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT email, threat_type::text, severity::text, confidence, impersonates, source::text,
+		       COALESCE(report_count, 0), first_seen, last_seen
+		FROM threat_emails
+		WHERE domain_hash = sha256_bytea($1)
+		ORDER BY last_seen DESC
+		LIMIT %d
+	`, domainDetailRowLimit), domain)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var email, threatType, severity, source string
+		var impersonates sql.NullString
+		var confidence, reportCount int
+		var firstSeen, lastSeen time.Time
+
+		if err := rows.Scan(&email, &threatType, &severity, &confidence, &impersonates, &source,
+			&reportCount, &firstSeen, &lastSeen); err != nil {
+			return nil, seenRange{}, err
+		}
+		item := map[string]interface{}{
+			"email":        email,
+			"threat_type":  threatType,
+			"severity":     severity,
+			"confidence":   confidence,
+			"source":       source,
+			"report_count": reportCount,
+			"first_seen":   firstSeen.Format(time.RFC3339),
+			"last_seen":    lastSeen.Format(time.RFC3339),
+		}
+		if impersonates.Valid {
+			item["impersonates"] = impersonates.String
+		}
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{"total": total, "data": items}, seen, rows.Err()
+}
+
+func (s *Server) domainReports(ctx context.Context, domain string) (map[string]interface{}, seenRange, error) {
+	var total, totalReports int64
+	var seen seenRange
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*), COALESCE(SUM(total_reports), 0), MIN(first_reported_at), MAX(last_reported_at)
+		FROM reported_urls
+		WHERE domain = $1
+	`, domain).Scan(&total, &totalReports, &seen.first, &seen.last)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT url, primary_threat_type::text, aggregated_score, total_reports, unique_reporters,
+		       status::text, first_reported_at, last_reported_at, promoted_to_threats
+		FROM reported_urls
+		WHERE domain = $1
+		ORDER BY last_reported_at DESC
+		LIMIT %d
+	`, domainDetailRowLimit), domain)
+	if err != nil {
+		return nil, seenRange{}, err
+	}
+	defer rows.Close()
+
+	items := []map[string]interface{}{}
+	for rows.Next() {
+		var urlStr, status string
+		var threatType sql.NullString
+		var score, reportCount, uniqueReporters int
+		var firstReported, lastReported time.Time
+		var promoted sql.NullBool
+
+		if err := rows.Scan(&urlStr, &threatType, &score, &reportCount, &uniqueReporters,
+			&status, &firstReported, &lastReported, &promoted); err != nil {
+			return nil, seenRange{}, err
+		}
+		item := map[string]interface{}{
+			"url":              urlStr,
+			"aggregated_score": score,
+			"total_reports":    reportCount,
+			"unique_reporters": uniqueReporters,
+			"status":           status,
+			"first_reported":   firstReported.Format(time.RFC3339),
+			"last_reported":    lastReported.Format(time.RFC3339),
+			"promoted":         promoted.Bool,
+		}
+		if threatType.Valid {
+			item["threat_type"] = threatType.String
+		}
+		items = append(items, item)
+	}
+
+	return map[string]interface{}{"total": total, "total_reports": totalReports, "data": items}, seen, rows.Err()
+}
+
+func (s *Server) domainTags(ctx context.Context, domain string) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.name
+		FROM domain_tags dt
+		JOIN tags t ON t.id = dt.tag_id
+		WHERE dt.domain_hash = sha256_bytea($1)
+		ORDER BY t.name
+	`, domain)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		tags = append(tags, name)
+	}
+	return tags, rows.Err()
+}
+
+func (s *Server) domainWhitelist(ctx context.Context, domain string) (map[string]interface{}, error) {
+	var category, brand, country, officialName sql.NullString
+	var createdAt time.Time
+
+	err := s.db.QueryRowContext(ctx, `
+		SELECT category, brand, country, official_name, created_at
+		FROM whitelist_domains
+		WHERE domain_hash = sha256_bytea($1)
+	`, domain).Scan(&category, &brand, &country, &officialName, &createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"category":      category.String,
+		"brand":         brand.String,
+		"country":       country.String,
+		"official_name": officialName.String,
+		"created_at":    createdAt.Format(time.RFC3339),
+	}, nil
+}
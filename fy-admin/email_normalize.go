@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Recalcula email_normalized/email_normalized_hash de threat_emails (migraciones 007 y 025).
+// El trigger trg_threat_emails_normalize calcula la forma canónica al tocar email_normalized:
+// basta con ponerla a NULL en las filas cuyo valor no coincide con las reglas actuales.
+
+const (
+	// emailNormalizeSource entrada de syncStatus (progreso en /api/actions/sync/progress)
+	emailNormalizeSource = "email_normalize"
+	// emailNormalizeBatchSize filas revisadas por lote (una transacción corta por lote)
+	emailNormalizeBatchSize = 5000
+	// emailNormalizeTimeout duración máxima del recálculo completo
+	emailNormalizeTimeout = 2 * time.Hour
+)
+
+// handleEmailNormalize lanza en segundo plano el recálculo de la forma canónica de los emails
+// POST /api/actions/email-normalize
+func (s *Server) handleEmailNormalize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	if s.syncInProgress(emailNormalizeSource) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Email normalization already in progress"})
+		return
+	}
+	// Se marca antes de lanzar la goroutine: dos peticiones seguidas no arrancan dos recálculos
+	s.updateSyncStatus(emailNormalizeSource, true, "Starting email normalization...")
+
+	s.auditLog(r, &AuditEntry{
+		Action:   "email_normalize",
+		Table:    "threat_emails",
+		RecordID: emailNormalizeSource,
+	})
+
+	correlation := correlationID(r.Context())
+	go func() {
+		ctx, cancel := context.WithTimeout(withCorrelationID(context.Background(), correlation), emailNormalizeTimeout)
+		defer cancel()
+		s.normalizeEmails(ctx)
+	}()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":        true,
+		"message":        "Email normalization started",
+		"correlation_id": correlation,
+	})
+}
+
+// normalizeEmails recorre threat_emails por email_hash en lotes de emailNormalizeBatchSize
+// y recalcula las filas sin forma canónica o con una que no coincide con las reglas actuales
+func (s *Server) normalizeEmails(ctx context.Context) {
+	start := time.Now()
+	var scanned, updated, errors int64
+	defer func() {
+		s.updateSyncStatusComplete(emailNormalizeSource, updated, errors,
+			fmt.Sprintf("Email normalization: %d scanned, %d updated in %s", scanned, updated, time.Since(start).Round(time.Second)))
+		log.Info().
+			Int64("scanned", scanned).
+			Int64("updated", updated).
+			Int64("errors", errors).
+			Dur("duration", time.Since(start)).
+			Msg("[EmailNorm] Email normalization completed")
+	}()
+
+	lastHash := []byte{}
+	for {
+		var batchScanned, batchUpdated int64
+		var batchLast []byte
+		err := s.db.QueryRowContext(ctx, `
+			WITH batch AS (
+				SELECT email_hash, email FROM threat_emails
+				WHERE email_hash > $1
+				ORDER BY email_hash
+				LIMIT $2
+			), touched AS (
+				UPDATE threat_emails t SET email_normalized = NULL
+				FROM batch b
+				WHERE t.email_hash = b.email_hash
+				  AND (t.email_normalized_hash IS NULL
+				       OR t.email_normalized IS DISTINCT FROM normalize_email_for_lookup(b.email))
+				RETURNING 1
+			)
+			SELECT (SELECT COUNT(*) FROM batch), (SELECT COUNT(*) FROM touched),
+			       (SELECT email_hash FROM batch ORDER BY email_hash DESC LIMIT 1)
+		`, lastHash, emailNormalizeBatchSize).Scan(&batchScanned, &batchUpdated, &batchLast)
+		if err != nil {
+			errors++
+			log.Error().Err(err).Int64("scanned", scanned).Msg("[EmailNorm] Email normalization batch failed")
+			return
+		}
+
+		scanned += batchScanned
+		updated += batchUpdated
+		s.updateSyncCounters(emailNormalizeSource, updated, errors,
+			fmt.Sprintf("Normalizing emails: %d scanned, %d updated", scanned, updated))
+
+		if batchScanned < emailNormalizeBatchSize || batchLast == nil {
+			return
+		}
+		lastHash = batchLast
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// escalateSeverityQuery sube cada dominio reportado por varias fuentes a la severidad más alta
+// entre ellas si es high o critical (anotándolo en severity_history) y a la confianza máxima.
+// Solo revisa los dominios que alguna fuente ha visto desde $1.
+const escalateSeverityQuery = `
+	WITH candidates AS (
+		SELECT DISTINCT ON (ds.domain_hash)
+			ds.domain_hash, ds.source, ds.severity,
+			MAX(ds.confidence) OVER w AS max_confidence,
+			COUNT(*) OVER w AS sources
+		FROM domain_sources ds
+		WHERE ds.domain_hash IN (SELECT domain_hash FROM domain_sources WHERE last_seen >= $1)
+		WINDOW w AS (PARTITION BY ds.domain_hash)
+		ORDER BY ds.domain_hash, ds.severity DESC, ds.confidence DESC
+	), changes AS (
+		SELECT c.domain_hash, c.source, c.severity, c.max_confidence,
+			td.severity AS old_severity,
+			(c.severity >= 'high'::severity_enum AND c.severity > td.severity) AS escalate
+		FROM candidates c
+		JOIN threat_domains td ON td.domain_hash = c.domain_hash
+		WHERE c.sources > 1
+	)
+	UPDATE threat_domains td SET
+		severity = CASE WHEN ch.escalate THEN ch.severity ELSE td.severity END,
+		confidence = GREATEST(td.confidence, ch.max_confidence),
+		severity_history = CASE WHEN ch.escalate
+			THEN td.severity_history || jsonb_build_array(jsonb_build_object(
+				'source', ch.source,
+				'old_severity', ch.old_severity,
+				'new_severity', ch.severity,
+				'at', NOW()))
+			ELSE td.severity_history END
+	FROM changes ch
+	WHERE td.domain_hash = ch.domain_hash
+	  AND (ch.escalate OR ch.max_confidence > td.confidence)
+	RETURNING ch.escalate`
+
+// SeverityEscalator unifica la severidad de threat_domains con la de domain_sources (una fila
+// por fuente que reporta el dominio) al terminar cada sincronización
+type SeverityEscalator struct {
+	db *sql.DB
+}
+
+func NewSeverityEscalator(db *sql.DB) *SeverityEscalator {
+	return &SeverityEscalator{db: db}
+}
+
+// Run escala los dominios vistos desde since. Devuelve cuántos cambiaron de severidad y
+// cuántos solo de confianza.
+func (e *SeverityEscalator) Run(ctx context.Context, since time.Time) (escalated, rescored int, err error) {
+	if e == nil {
+		return 0, 0, nil
+	}
+
+	rows, err := e.db.QueryContext(ctx, escalateSeverityQuery, since)
+	if err != nil {
+		return 0, 0, fmt.Errorf("severity escalation: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var escalate bool
+		if err := rows.Scan(&escalate); err != nil {
+			return escalated, rescored, fmt.Errorf("severity escalation: %w", err)
+		}
+		if escalate {
+			escalated++
+		} else {
+			rescored++
+		}
+	}
+	return escalated, rescored, rows.Err()
+}
+
+// escalateAfterSync ejecuta el escalador tras una sincronización que empezó en startTime.
+// Un fallo no invalida la sincronización: se registra y se reintenta en la siguiente.
+func (s *Server) escalateAfterSync(ctx context.Context, source string, startTime time.Time) {
+	if s.escalator == nil {
+		return
+	}
+
+	logger := ctxLogger(ctx)
+	writeCtx, cancel := s.writeCtx(ctx)
+	defer cancel()
+
+	escalated, rescored, err := s.escalator.Run(writeCtx, startTime)
+	if err != nil {
+		logger.Warn().Err(err).Str("source", source).Msg("[Escalator] Severity escalation failed")
+		return
+	}
+	if escalated > 0 || rescored > 0 {
+		logger.Info().
+			Str("source", source).
+			Int("escalated", escalated).
+			Int("rescored", rescored).
+			Msg("[Escalator] Threat domains updated from other sources")
+	}
+}
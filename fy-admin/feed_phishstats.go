@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// phishStatsDefaultURL CSV público de PhishStats (URLs de los últimos 30 días con su score)
+const phishStatsDefaultURL = "https://phishstats.info/phish_score.csv"
+
+// phishStatsMinScore score mínimo importado. Escala de PhishStats: 0-2 probable,
+// 2-4 sospechosa, 4-6 phishing, 6-10 phishing seguro.
+const phishStatsMinScore = 4.0
+
+// phishStatsFeed CSV de PhishStats. No trae ids: el source_id es el hash de la URL.
+type phishStatsFeed struct {
+	url string
+}
+
+func (phishStatsFeed) Name() string { return "phishstats" }
+
+// Interval PhishStats regenera el CSV cada 90 minutos
+func (phishStatsFeed) Interval() time.Duration { return 90 * time.Minute }
+
+func (phishStatsFeed) Threat() feedThreat {
+	return feedThreat{sourceEnum: "phishstats", threatType: "phishing", confidence: 75}
+}
+
+func (f phishStatsFeed) Fetch(ctx context.Context) (io.ReadCloser, error) {
+	return fetchFeed(ctx, f.url, 120*time.Second)
+}
+
+// Parse formato: "date","score","url","ip"
+func (phishStatsFeed) Parse(line string) (*ThreatRecord, error) {
+	fields := parseCSVLine(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected at least 3 fields, got %d", len(fields))
+	}
+
+	score, err := strconv.ParseFloat(strings.TrimSpace(fields[1]), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid PhishStats score %q", fields[1])
+	}
+	if score < phishStatsMinScore {
+		return nil, nil
+	}
+
+	rawURL := strings.TrimSpace(fields[2])
+	return urlRecord(rawURL, hashedSourceID("phishstats", rawURL))
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// FeedSource feed público de URLs o dominios maliciosos. syncFeed se encarga de la descarga
+// línea a línea, la deduplicación, el upsert en threat_domains/threat_paths y sync_status:
+// añadir un feed es implementar esta interfaz y registrarlo en newFeedSources.
+type FeedSource interface {
+	// Name clave de la fuente en el panel (SyncProgress, ?source= de /api/actions/sync)
+	Name() string
+	// Fetch abre el feed; el llamante lo cierra
+	Fetch(ctx context.Context) (io.ReadCloser, error)
+	// Parse interpreta una línea no vacía ni comentada. nil, nil = línea que se ignora
+	// (cabeceras, IPs); un error cuenta como línea errónea.
+	Parse(line string) (*ThreatRecord, error)
+	// Interval cada cuánto se sincroniza
+	Interval() time.Duration
+	// Threat source_enum, tipo y confianza con los que se importan los registros
+	Threat() feedThreat
+}
+
+// ThreatRecord registro de un feed: un dominio y, si el feed trae URLs, su path. Tags
+// (normalizados con parseTagList) se asignan al dominio en domain_tags.
+type ThreatRecord struct {
+	Domain   string
+	Path     string
+	SourceID string
+	Tags     []string
+}
+
+// feedRegistration feed registrado con los datos que muestra el panel
+type feedRegistration struct {
+	source      FeedSource
+	label       string
+	description string
+	// scheduled fy-admin lo sincroniza cada Interval; los demás los programa fy-dbsync
+	// y aquí solo se fuerzan desde el panel
+	scheduled bool
+}
+
+// newFeedSources feeds disponibles según la configuración (los que necesitan URL propia
+// solo se registran si está definida)
+func newFeedSources(config *Config) []feedRegistration {
+	feeds := []feedRegistration{
+		{source: urlhausFeed{}, label: "URLhaus", description: "Malware URLs"},
+		{source: openPhishFeed{}, label: "OpenPhish", description: "Phishing URLs"},
+	}
+	if config.PhishStatsURL != "" && config.PhishStatsURL != "off" {
+		feeds = append(feeds, feedRegistration{
+			source: phishStatsFeed{url: config.PhishStatsURL}, label: "PhishStats", description: "Phishing URLs", scheduled: true,
+		})
+	}
+	if config.SpamhausDBLURL != "" {
+		feeds = append(feeds, feedRegistration{
+			source: spamhausDBLFeed{location: config.SpamhausDBLURL}, label: "Spamhaus DBL", description: "Spam/Phishing Domains", scheduled: true,
+		})
+	}
+	return feeds
+}
+
+// feed feed registrado con ese nombre
+func (s *Server) feed(name string) (feedRegistration, bool) {
+	for _, f := range s.feeds {
+		if f.source.Name() == name {
+			return f, true
+		}
+	}
+	return feedRegistration{}, false
+}
+
+// syncFeed descarga e importa un feed
+func (s *Server) syncFeed(ctx context.Context, feed FeedSource) (err error) {
+	source := feed.Name()
+	s.updateSyncStatus(source, true, fmt.Sprintf("Downloading %s feed...", source))
+
+	startTime := time.Now()
+	var records, errors int64
+	lineNum := 0
+	domains := feedDomains{}
+
+	defer func() {
+		if err != nil {
+			s.updateSyncStatusComplete(source, records, errors+1, err.Error())
+			return
+		}
+		duration := time.Since(startTime)
+		logSyncCompleted(source, records, errors, duration)
+		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v (%d unique domains from %d lines)", duration.Round(time.Second), len(domains), lineNum))
+	}()
+
+	body, err := feed.Fetch(ctx)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	s.updateSyncStatus(source, true, "Parsing feed...")
+
+	scanner := bufio.NewScanner(body)
+	buf := make([]byte, 0, 64*1024)
+	scanner.Buffer(buf, 1024*1024)
+
+	for scanner.Scan() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		lineNum++
+		if lineNum%syncProgressLogInterval == 0 {
+			logSyncProgress(source, lineNum, records, errors)
+		}
+
+		record, err := feed.Parse(line)
+		if err != nil {
+			errors++
+			continue
+		}
+		if record == nil {
+			continue
+		}
+		domains.add(record.Domain, record.SourceID, record.Path, record.Tags)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	logFeedDeduplicated(source, lineNum, len(domains))
+	s.updateSyncStatus(source, true, fmt.Sprintf("Importing %d unique domains...", len(domains)))
+
+	threat := feed.Threat()
+	threat.source = source
+	if err := s.upsertFeedDomains(ctx, threat, domains, &records, &errors); err != nil {
+		return err
+	}
+
+	s.exec(ctx, `
+		INSERT INTO sync_status (source, last_sync, last_count)
+		VALUES ($1::source_enum, NOW(), $2)
+		ON CONFLICT (source) DO UPDATE SET
+			last_sync = NOW(),
+			last_count = $2
+	`, threat.sourceEnum, records)
+
+	s.escalateAfterSync(ctx, source, startTime)
+	return nil
+}
+
+// fetchFeed GET de un feed por HTTP
+func fetchFeed(ctx context.Context, feedURL string, timeout time.Duration) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Fy-Admin/1.0")
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
+// urlRecord registro de la URL de un feed (nil para URLs con IP en lugar de dominio)
+func urlRecord(rawURL, sourceID string) (*ThreatRecord, error) {
+	parsedURL, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, err
+	}
+
+	domain := strings.ToLower(parsedURL.Hostname())
+	if domain == "" || len(domain) < 3 || !strings.Contains(domain, ".") {
+		return nil, fmt.Errorf("invalid domain in %q", rawURL)
+	}
+
+	// Saltar IPs
+	if net.ParseIP(domain) != nil {
+		return nil, nil
+	}
+
+	return &ThreatRecord{Domain: domain, Path: parsedURL.Path, SourceID: sourceID}, nil
+}
+
+// feedScheduler sincroniza cada Interval los feeds registrados con scheduled
+type feedScheduler struct {
+	server *Server
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// feedSchedulerInitialDelay espera antes de la primera sincronización tras arrancar
+const feedSchedulerInitialDelay = time.Minute
+
+func newFeedScheduler(server *Server) *feedScheduler {
+	return &feedScheduler{server: server}
+}
+
+// Start lanza un bucle por feed programado
+func (f *feedScheduler) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	f.cancel = cancel
+
+	for _, feed := range f.server.feeds {
+		if !feed.scheduled {
+			continue
+		}
+		f.wg.Add(1)
+		go f.loop(ctx, feed.source)
+		log.Info().Str("source", feed.source.Name()).Dur("interval", feed.source.Interval()).Msg("[Sync] Feed scheduled")
+	}
+
+	// Lote diario de re-evaluación de dominios (recheck.go); el tamaño se lee en cada ejecución
+	f.wg.Add(1)
+	go f.recheckLoop(ctx)
+}
+
+// Stop cancela las sincronizaciones en curso y espera a que terminen los bucles
+func (f *feedScheduler) Stop() {
+	if f.cancel != nil {
+		f.cancel()
+	}
+	f.wg.Wait()
+}
+
+func (f *feedScheduler) loop(ctx context.Context, feed FeedSource) {
+	defer f.wg.Done()
+
+	wait := feedSchedulerInitialDelay
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+		wait = feed.Interval()
+
+		// Una sincronización forzada desde el panel sigue en curso: se espera al siguiente turno
+		if f.server.syncInProgress(feed.Name()) {
+			continue
+		}
+		f.server.RetryableSync(ctx, feed.Name(), func(ctx context.Context) error {
+			return f.server.syncFeed(ctx, feed)
+		})
+	}
+}
+
+// syncInProgress indica si la fuente se está sincronizando
+func (s *Server) syncInProgress(source string) bool {
+	s.syncMutex.RLock()
+	defer s.syncMutex.RUnlock()
+
+	status, ok := s.syncStatus[source]
+	return ok && status.InProgress
+}
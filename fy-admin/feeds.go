@@ -0,0 +1,261 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// feedDomain dominio de un feed ya deduplicado: un solo upsert por ejecución aunque
+// aparezca en cientos de líneas. tags es la unión de los tags de todas sus URLs.
+type feedDomain struct {
+	sourceID string
+	paths    map[string]struct{}
+	tags     map[string]struct{}
+}
+
+// feedDomains dominios de un feed indexados por nombre
+type feedDomains map[string]*feedDomain
+
+// add registra una URL del feed. De todas las URLs de un dominio se conserva el menor
+// source_id (id numérico de URLhaus o hash de OpenPhish) para que no dependa del orden del feed.
+func (f feedDomains) add(domain, sourceID, path string, tags []string) {
+	entry, ok := f[domain]
+	if !ok {
+		entry = &feedDomain{sourceID: sourceID, paths: make(map[string]struct{}), tags: make(map[string]struct{})}
+		f[domain] = entry
+	} else if len(sourceID) < len(entry.sourceID) || (len(sourceID) == len(entry.sourceID) && sourceID < entry.sourceID) {
+		entry.sourceID = sourceID
+	}
+	if path != "" && path != "/" {
+		entry.paths[path] = struct{}{}
+	}
+	for _, tag := range tags {
+		entry.tags[tag] = struct{}{}
+	}
+}
+
+// tagNames tags distintos de todos los dominios del feed
+func (f feedDomains) tagNames() []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for _, entry := range f {
+		for tag := range entry.tags {
+			if _, ok := seen[tag]; !ok {
+				seen[tag] = struct{}{}
+				names = append(names, tag)
+			}
+		}
+	}
+	return names
+}
+
+// feedThreat clasificación con la que se importa un feed en threat_domains/threat_paths
+type feedThreat struct {
+	source     string // Nombre en syncStatus (lo rellena syncFeed)
+	sourceEnum string // Valor de source_enum
+	threatType string
+	confidence int
+}
+
+// upsertFeedDomains escribe los dominios deduplicados de un feed: hit_count sube una vez por
+// ejecución. source_id solo se fija al insertar; una fila existente conserva su identidad.
+// Los tags del feed se añaden a los que ya tenga el dominio (nunca se quitan).
+func (s *Server) upsertFeedDomains(ctx context.Context, threat feedThreat, domains feedDomains, records, errors *int64) error {
+	now := time.Now()
+	processed := 0
+
+	tagIDs, err := s.tagIDs(ctx, domains.tagNames(), true)
+	if err != nil {
+		// Sin tags se importa igual: los dominios son lo importante
+		log.Warn().Err(err).Str("source", threat.source).Msg("[Sync] Failed to create feed tags")
+		tagIDs = nil
+	}
+
+	for domain, entry := range domains {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		_, err := s.exec(ctx, `
+			INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, source_id, tld, first_seen, last_seen, flags)
+			VALUES (sha256_bytea($1), $1, $2::threat_type_enum, 'high'::severity_enum, $3, $4::source_enum, $5, $6, $7, $7, `+flagActive.sql()+`)
+			ON CONFLICT (domain_hash) DO UPDATE SET
+				last_seen = EXCLUDED.last_seen,
+				hit_count = threat_domains.hit_count + 1,
+				confidence = GREATEST(threat_domains.confidence, EXCLUDED.confidence)
+		`, domain, threat.threatType, threat.confidence, threat.sourceEnum, entry.sourceID, extractTLD(domain), now)
+		if err != nil {
+			*errors++
+			continue
+		}
+		*records++
+
+		// Severidad y confianza con las que lo reporta esta fuente (SeverityEscalator)
+		s.exec(ctx, `
+			INSERT INTO domain_sources (domain_hash, source, severity, confidence, first_seen, last_seen)
+			VALUES (sha256_bytea($1), $2::source_enum, 'high'::severity_enum, $3, $4, $4)
+			ON CONFLICT (domain_hash, source) DO UPDATE SET
+				severity = EXCLUDED.severity,
+				confidence = EXCLUDED.confidence,
+				last_seen = EXCLUDED.last_seen
+		`, domain, threat.sourceEnum, threat.confidence, now)
+
+		for path := range entry.paths {
+			s.exec(ctx, `
+				INSERT INTO threat_paths (path_hash, domain_hash, path, threat_type, severity, confidence, source, first_seen, last_seen, flags)
+				VALUES (sha256_bytea($1), sha256_bytea($2), $3, $4::threat_type_enum, 'high'::severity_enum, $5, $6::source_enum, $7, $7, `+flagActive.sql()+`)
+				ON CONFLICT (path_hash) DO UPDATE SET last_seen = EXCLUDED.last_seen
+			`, domain+path, domain, path, threat.threatType, threat.confidence, threat.sourceEnum, now)
+		}
+
+		if len(entry.tags) > 0 && tagIDs != nil {
+			ids := make([]int16, 0, len(entry.tags))
+			for tag := range entry.tags {
+				if id, ok := tagIDs[tag]; ok {
+					ids = append(ids, id)
+				}
+			}
+			if _, err := s.attachDomainTags(ctx, domain, ids); err != nil {
+				log.Debug().Err(err).Str("domain", domain).Msg("[Sync] Failed to attach feed tags")
+			}
+		}
+
+		processed++
+		if processed%1000 == 0 {
+			s.updateSyncStatus(threat.source, true, fmt.Sprintf("Imported %d/%d domains...", processed, len(domains)))
+		}
+	}
+	return nil
+}
+
+// urlhausFeed CSV de URLhaus. Se usa el CSV porque trae el id de cada URL en URLhaus, que
+// se guarda como source_id, y sus tags (familia de malware, arquitectura...).
+type urlhausFeed struct{}
+
+func (urlhausFeed) Name() string            { return "urlhaus" }
+func (urlhausFeed) Interval() time.Duration { return 5 * time.Minute }
+
+func (urlhausFeed) Threat() feedThreat {
+	return feedThreat{sourceEnum: "urlhaus", threatType: "malware", confidence: 85}
+}
+
+func (urlhausFeed) Fetch(ctx context.Context) (io.ReadCloser, error) {
+	body, err := fetchFeed(ctx, urlhausDownloadURL, 120*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	csv, release, err := openURLhausCSV(body)
+	if err != nil {
+		return nil, err
+	}
+	return readCloser{Reader: csv, close: release}, nil
+}
+
+// Parse formato: id,dateadded,url,url_status,last_online,threat,tags,urlhaus_link,reporter
+func (urlhausFeed) Parse(line string) (*ThreatRecord, error) {
+	fields := parseCSVLine(line)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("expected at least 3 fields, got %d", len(fields))
+	}
+	id := strings.TrimSpace(fields[0])
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid URLhaus id %q", id)
+	}
+	record, err := urlRecord(fields[2], "urlhaus-"+id)
+	if record != nil && len(fields) > 6 {
+		record.Tags = parseTagList(fields[6])
+	}
+	return record, err
+}
+
+// openPhishFeed feed de la comunidad de OpenPhish (una URL por línea). El feed no trae ids:
+// el source_id es el hash de la URL. En source_enum es 'phishtank'.
+type openPhishFeed struct{}
+
+func (openPhishFeed) Name() string            { return "openphish" }
+func (openPhishFeed) Interval() time.Duration { return time.Hour }
+
+func (openPhishFeed) Threat() feedThreat {
+	return feedThreat{sourceEnum: "phishtank", threatType: "phishing", confidence: 90}
+}
+
+func (openPhishFeed) Fetch(ctx context.Context) (io.ReadCloser, error) {
+	return fetchFeed(ctx, openPhishURL, 60*time.Second)
+}
+
+func (openPhishFeed) Parse(line string) (*ThreatRecord, error) {
+	return urlRecord(line, hashedSourceID("openphish", line))
+}
+
+// readCloser io.Reader con una función de cierre propia
+type readCloser struct {
+	io.Reader
+	close func()
+}
+
+func (r readCloser) Close() error {
+	r.close()
+	return nil
+}
+
+// hashedSourceID identidad estable de una URL de un feed que no trae ids (OpenPhish, PhishStats)
+func hashedSourceID(prefix, rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return prefix + "-" + hex.EncodeToString(sum[:8])
+}
+
+// openURLhausCSV abre el CSV de URLhaus descargado. El feed completo se sirve comprimido en
+// zip (que necesita acceso aleatorio, así que se vuelca a un temporal); si llega en claro se
+// lee tal cual. release libera el temporal.
+func openURLhausCSV(body io.Reader) (r io.Reader, release func(), err error) {
+	tmp, err := os.CreateTemp("", "urlhaus-*.csv")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	cleanup := func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}
+
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	zr, err := zip.NewReader(tmp, size)
+	if err != nil {
+		// No es un zip: CSV en claro
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			cleanup()
+			return nil, nil, err
+		}
+		return tmp, cleanup, nil
+	}
+	if len(zr.File) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("empty URLhaus archive")
+	}
+
+	f, err := zr.File[0].Open()
+	if err != nil {
+		cleanup()
+		return nil, nil, fmt.Errorf("failed to open %s: %w", zr.File[0].Name, err)
+	}
+	log.Debug().Str("file", zr.File[0].Name).Msg("[Sync] URLhaus archive opened")
+	return f, func() { f.Close(); cleanup() }, nil
+}
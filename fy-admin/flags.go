@@ -0,0 +1,194 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// threatFlag bit de la columna flags (SMALLINT). Copia de fy-analysis/internal/flags
+// (los módulos se compilan por separado): cualquier cambio de bits se hace en los dos.
+type threatFlag int16
+
+const (
+	// flagActive registro activo (threat_*, reported_urls)
+	flagActive threatFlag = 1 << 0
+	// flagBanned usuario baneado del sistema de reportes (user_trust_scores)
+	flagBanned threatFlag = 1 << 0
+
+	// threat_domains
+	flagVerified      threatFlag = 1 << 1 // Verificado por la fuente
+	flagTyposquatting threatFlag = 1 << 2
+	flagHasSSL        threatFlag = 1 << 3
+	flagParked        threatFlag = 1 << 4
+
+	// threat_phones
+	flagPremium threatFlag = 1 << 1 // Número de tarificación especial
+
+	// Marcas de analista (todas las tablas de amenazas)
+	flagFalsePositiveCandidate threatFlag = 1 << 5 // fy-analysis ignora el registro
+	flagAnalystVerified        threatFlag = 1 << 6
+
+	// Confianza decaída por antigüedad inferior a 0.1 (la pone LocalDB de fy-analysis)
+	flagStaleCandidate threatFlag = 1 << 7
+)
+
+type namedFlag struct {
+	flag threatFlag
+	name string
+}
+
+var analystFlags = []namedFlag{
+	{flagFalsePositiveCandidate, "false_positive_candidate"},
+	{flagAnalystVerified, "analyst_verified"},
+	{flagStaleCandidate, "stale_candidate"},
+}
+
+// tableFlags bits con nombre de cada tabla (decodeFlags)
+var tableFlags = map[string][]namedFlag{
+	"threat_domains": append([]namedFlag{
+		{flagActive, "active"}, {flagVerified, "verified"}, {flagTyposquatting, "typosquatting"},
+		{flagHasSSL, "has_ssl"}, {flagParked, "parked"},
+	}, analystFlags...),
+	"threat_paths":      append([]namedFlag{{flagActive, "active"}}, analystFlags...),
+	"threat_emails":     append([]namedFlag{{flagActive, "active"}}, analystFlags...),
+	"threat_phones":     append([]namedFlag{{flagActive, "active"}, {flagPremium, "premium"}}, analystFlags...),
+	"reported_urls":     {{flagActive, "active"}},
+	"user_trust_scores": {{flagBanned, "banned"}},
+}
+
+// settableFlags bits que un admin puede poner o quitar con POST /api/admin/flags
+var settableFlags = map[string]threatFlag{
+	"active":                   flagActive,
+	"false_positive_candidate": flagFalsePositiveCandidate,
+	"analyst_verified":         flagAnalystVerified,
+	"stale_candidate":          flagStaleCandidate,
+}
+
+// flagTargets tabla de cada tipo de registro de POST /api/admin/flags (la clave es la de auditRecordKeys)
+var flagTargets = map[string]string{
+	"domain": "threat_domains",
+	"email":  "threat_emails",
+	"phone":  "threat_phones",
+}
+
+func (f threatFlag) has(value int) bool {
+	return value&int(f) == int(f)
+}
+
+// isSet condición SQL "el bit está puesto" sobre column (p.ej. "td.flags")
+func (f threatFlag) isSet(column string) string {
+	return fmt.Sprintf("(%s & %d) = %d", column, f, f)
+}
+
+// activeThreat condición SQL "registro activo y no marcado como falso positivo" sobre column
+func activeThreat(column string) string {
+	return flagActive.isSet(column) + " AND NOT " + flagFalsePositiveCandidate.isSet(column)
+}
+
+// sql valor del bit como literal SQL (INSERT ... flags)
+func (f threatFlag) sql() string {
+	return fmt.Sprintf("%d", f)
+}
+
+// decodeFlags nombres de los bits puestos en value según la tabla
+func decodeFlags(table string, value int) []string {
+	names := []string{}
+	for _, n := range tableFlags[table] {
+		if n.flag.has(value) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
+
+// handleSetFlag pone o quita un bit de flags de un dominio, email o teléfono (con TOTP)
+// POST /api/admin/flags {"type": "domain|email|phone", "value": "...", "flag": "false_positive_candidate", "set": true}
+func (s *Server) handleSetFlag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	var input struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+		Flag  string `json:"flag"`
+		Set   *bool  `json:"set"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+
+	table, ok := flagTargets[input.Type]
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "type must be domain, email or phone"})
+		return
+	}
+	flag, ok := settableFlags[input.Flag]
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "flag must be active, false_positive_candidate, analyst_verified or stale_candidate"})
+		return
+	}
+	if input.Set == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "set is required"})
+		return
+	}
+	value := strings.TrimSpace(input.Value)
+	if input.Type != "phone" {
+		value = strings.ToLower(value)
+	}
+	if value == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "value is required"})
+		return
+	}
+
+	update := "COALESCE(flags, 0) | $2::smallint"
+	action := "set_flag"
+	if !*input.Set {
+		update = "COALESCE(flags, 0) & ~$2::smallint"
+		action = "clear_flag"
+	}
+
+	var flags int
+	err := s.auditWrite(r, action, table, value, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		return s.db.QueryRowContext(ctx, fmt.Sprintf(
+			`UPDATE %s SET flags = %s WHERE %s RETURNING flags`, table, update, auditRecordKeys[table],
+		), value, int16(flag)).Scan(&flags)
+	})
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Record not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().
+		Str("table", table).
+		Str("record_id", value).
+		Str("flag", input.Flag).
+		Bool("set", *input.Set).
+		Msg("[Flags] Record flag updated")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"flags":     decodeFlags(table, flags),
+		"flags_raw": flags,
+	})
+}
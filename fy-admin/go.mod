@@ -2,4 +2,19 @@ module github.com/trackfy/fy-admin
 
 go 1.21
 
-require github.com/lib/pq v1.10.9
+require (
+	github.com/lib/pq v1.10.9
+	github.com/rs/zerolog v1.31.0
+	github.com/trackfy/pkg v0.0.0-00010101000000-000000000000
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.20 // indirect
+	github.com/redis/go-redis/v9 v9.4.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+)
+
+replace github.com/trackfy/pkg => ../pkg
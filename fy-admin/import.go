@@ -0,0 +1,317 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	importMaxUploadSize = 20 << 20 // 20MB
+	importBatchSize     = 500
+	importSource        = "import"
+)
+
+// importColumns columnas reconocidas por tipo (la primera es el valor obligatorio)
+var importColumns = map[string][]string{
+	"phones":  {"phone", "country_code", "threat_type", "severity", "description"},
+	"emails":  {"email", "threat_type", "severity", "impersonates"},
+	"domains": {"domain", "threat_type", "severity"},
+}
+
+// ImportRowResult resultado de una fila del CSV
+type ImportRowResult struct {
+	Line   int    `json:"line"`
+	Value  string `json:"value,omitempty"`
+	Status string `json:"status"` // imported, valid (dry_run), invalid, failed
+	Error  string `json:"error,omitempty"`
+}
+
+// ImportReport resumen de la importación
+type ImportReport struct {
+	Success  bool              `json:"success"`
+	Type     string            `json:"type"`
+	DryRun   bool              `json:"dry_run"`
+	Total    int               `json:"total"`
+	Imported int               `json:"imported"`
+	Valid    int               `json:"valid"`
+	Invalid  int               `json:"invalid"`
+	Failed   int               `json:"failed"`
+	Duration string            `json:"duration"`
+	Rows     []ImportRowResult `json:"rows"`
+}
+
+// importRow fila validada pendiente de escribir
+type importRow struct {
+	line  int
+	entry *manualEntry
+}
+
+// handleImportCSV importa entradas manuales desde un CSV (multipart, campo "file")
+// POST /api/import/csv?type=phones|emails|domains&dry_run=true
+func (s *Server) handleImportCSV(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	importType := r.URL.Query().Get("type")
+	if importType == "" {
+		importType = r.FormValue("type")
+	}
+	columns, ok := importColumns[importType]
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "type must be phones, emails or domains"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	if !dryRun {
+		dryRun, _ = strconv.ParseBool(r.FormValue("dry_run"))
+	}
+
+	if !s.dbReady() && !dryRun {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	s.syncMutex.RLock()
+	inProgress := s.syncStatus[importSource].InProgress
+	s.syncMutex.RUnlock()
+	if inProgress {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Import already in progress"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "CSV file is required (field 'file')"})
+		return
+	}
+	defer file.Close()
+
+	// Los ficheros grandes superan el WriteTimeout del servidor
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+
+	report := s.importCSV(ctx, file, importType, columns, dryRun)
+	log.Info().
+		Str("type", importType).
+		Str("file", header.Filename).
+		Bool("dry_run", dryRun).
+		Int("total", report.Total).
+		Int("imported", report.Imported).
+		Int("invalid", report.Invalid).
+		Int("failed", report.Failed).
+		Str("duration", report.Duration).
+		Msg("[Import] CSV import completed")
+
+	// Una entrada por importación con el resumen (el detalle por fila va en la respuesta)
+	if !dryRun {
+		s.auditLog(r, &AuditEntry{
+			Action:   "import_csv",
+			Table:    "threat_" + importType,
+			RecordID: header.Filename,
+			NewValue: auditJSON(map[string]interface{}{
+				"total":    report.Total,
+				"imported": report.Imported,
+				"invalid":  report.Invalid,
+				"failed":   report.Failed,
+			}),
+		})
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// importCSV valida todas las filas y escribe las válidas en lotes, actualizando SyncProgress
+func (s *Server) importCSV(ctx context.Context, file io.Reader, importType string, columns []string, dryRun bool) *ImportReport {
+	startTime := time.Now()
+	report := &ImportReport{Type: importType, DryRun: dryRun, Rows: []ImportRowResult{}}
+
+	s.updateSyncStatus(importSource, true, fmt.Sprintf("Validating %s CSV...", importType))
+	defer func() {
+		report.Duration = time.Since(startTime).Round(time.Millisecond).String()
+		s.updateSyncStatusComplete(importSource, int64(report.Imported), int64(report.Invalid+report.Failed),
+			fmt.Sprintf("%s import completed in %s (dry_run=%t)", importType, report.Duration, dryRun))
+	}()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	// Posición de cada columna (por defecto, el orden de importColumns)
+	index := make(map[string]int, len(columns))
+	for i, col := range columns {
+		index[col] = i
+	}
+
+	var batch []importRow
+	first := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			line := 0
+			if parseErr, ok := err.(*csv.ParseError); ok {
+				line = parseErr.Line
+			}
+			report.addRow(ImportRowResult{Line: line, Status: "invalid", Error: err.Error()})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+		if len(record) == 0 || strings.TrimSpace(strings.Join(record, "")) == "" {
+			continue
+		}
+
+		// Cabecera opcional: si la primera fila nombra la columna principal, se usa para mapear
+		isFirst := first
+		first = false
+		if isFirst && hasHeader(record, columns[0]) {
+			index = make(map[string]int)
+			for i, name := range record {
+				index[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		entry, err := normalizeImportRow(importType, field)
+		if err != nil {
+			report.addRow(ImportRowResult{Line: line, Value: field(columns[0]), Status: "invalid", Error: err.Error()})
+			continue
+		}
+
+		if dryRun {
+			report.addRow(ImportRowResult{Line: line, Value: entry.Value, Status: "valid"})
+			continue
+		}
+
+		batch = append(batch, importRow{line: line, entry: entry})
+		if len(batch) >= importBatchSize {
+			s.writeImportBatch(ctx, importType, batch, report)
+			batch = batch[:0]
+		}
+	}
+
+	if len(batch) > 0 {
+		s.writeImportBatch(ctx, importType, batch, report)
+	}
+
+	report.Success = report.Failed == 0
+	return report
+}
+
+// writeImportBatch escribe un lote en una transacción; si falla una fila se reintenta fila a fila
+func (s *Server) writeImportBatch(ctx context.Context, importType string, batch []importRow, report *ImportReport) {
+	insert := insertManualDomain
+	switch importType {
+	case "phones":
+		insert = insertManualPhone
+	case "emails":
+		insert = insertManualEmail
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err == nil {
+		for _, row := range batch {
+			if err = insert(ctx, tx, row.entry); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+	}
+
+	if err == nil {
+		for _, row := range batch {
+			report.addRow(ImportRowResult{Line: row.line, Value: row.entry.Value, Status: "imported"})
+		}
+	} else {
+		// Aislar las filas problemáticas sin perder el resto del lote
+		for _, row := range batch {
+			if err := insert(ctx, s.db, row.entry); err != nil {
+				report.addRow(ImportRowResult{Line: row.line, Value: row.entry.Value, Status: "failed", Error: err.Error()})
+				continue
+			}
+			report.addRow(ImportRowResult{Line: row.line, Value: row.entry.Value, Status: "imported"})
+		}
+	}
+
+	s.updateSyncCounters(importSource, int64(report.Imported), int64(report.Invalid+report.Failed),
+		fmt.Sprintf("Imported %d %s...", report.Imported, importType))
+}
+
+// normalizeImportRow reutiliza la normalización de los endpoints /api/add/*
+func normalizeImportRow(importType string, field func(string) string) (*manualEntry, error) {
+	switch importType {
+	case "phones":
+		entry, err := normalizeManualPhone(field("phone"), field("country_code"), field("threat_type"), field("severity"))
+		if err != nil {
+			return nil, err
+		}
+		entry.Description = field("description")
+		return entry, nil
+	case "emails":
+		entry, err := normalizeManualEmail(field("email"), field("threat_type"), field("severity"))
+		if err != nil {
+			return nil, err
+		}
+		entry.Impersonates = field("impersonates")
+		return entry, nil
+	default:
+		return normalizeManualDomain(field("domain"), field("threat_type"), field("severity"))
+	}
+}
+
+// hasHeader detecta una cabecera que incluye la columna principal en otra posición
+func hasHeader(record []string, mainColumn string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), mainColumn) {
+			return true
+		}
+	}
+	return false
+}
+
+// addRow registra el resultado de una fila y actualiza los contadores
+func (rep *ImportReport) addRow(row ImportRowResult) {
+	rep.Total++
+	switch row.Status {
+	case "imported":
+		rep.Imported++
+	case "valid":
+		rep.Valid++
+	case "invalid":
+		rep.Invalid++
+	case "failed":
+		rep.Failed++
+	}
+	rep.Rows = append(rep.Rows, row)
+}
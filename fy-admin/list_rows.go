@@ -0,0 +1,157 @@
+package main
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Filas de los listados del panel (/api/domains, /api/paths, /api/emails, /api/phones,
+// /api/whitelist, /api/reports). Cada scan* lee las columnas en el orden del SELECT
+// de su handler.
+
+// DomainRow dominio de threat_domains
+type DomainRow struct {
+	Domain     string    `json:"domain"`
+	ThreatType string    `json:"threat_type"`
+	Severity   string    `json:"severity"`
+	Confidence int       `json:"confidence"`
+	Source     string    `json:"source"`
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+	HitCount   int       `json:"hit_count"`
+	Flags      []string  `json:"flags"`
+	// Paths activos del dominio (solo con include_paths=true)
+	Paths []PathRow `json:"paths,omitempty"`
+}
+
+func scanDomainRow(rows *sql.Rows) (DomainRow, error) {
+	var row DomainRow
+	var flags int
+	err := rows.Scan(&row.Domain, &row.ThreatType, &row.Severity, &row.Confidence, &row.Source,
+		&row.FirstSeen, &row.LastSeen, &row.HitCount, &flags)
+	row.Flags = decodeFlags("threat_domains", flags)
+	return row, err
+}
+
+// PathRow path de threat_paths con el dominio al que pertenece
+type PathRow struct {
+	PathHash    string    `json:"path_hash"` // Hex de sha256(dominio + path)
+	Domain      string    `json:"domain"`
+	Path        string    `json:"path"`
+	ThreatType  string    `json:"threat_type"`
+	Severity    string    `json:"severity"`
+	Confidence  int       `json:"confidence"`
+	PayloadType string    `json:"payload_type,omitempty"`
+	TargetBrand string    `json:"target_brand,omitempty"`
+	Source      string    `json:"source"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Flags       []string  `json:"flags"`
+}
+
+func scanPathRow(rows *sql.Rows) (PathRow, error) {
+	var row PathRow
+	var path, payloadType, targetBrand sql.NullString
+	var flags int
+	err := rows.Scan(&row.PathHash, &row.Domain, &path, &row.ThreatType, &row.Severity, &row.Confidence,
+		&payloadType, &targetBrand, &row.Source, &row.FirstSeen, &row.LastSeen, &flags)
+	row.Path = path.String
+	row.PayloadType = payloadType.String
+	row.TargetBrand = targetBrand.String
+	row.Flags = decodeFlags("threat_paths", flags)
+	return row, err
+}
+
+// EmailRow email de threat_emails
+type EmailRow struct {
+	Email        string    `json:"email"`
+	ThreatType   string    `json:"threat_type"`
+	Severity     string    `json:"severity"`
+	Confidence   int       `json:"confidence"`
+	Source       string    `json:"source"`
+	Impersonates string    `json:"impersonates,omitempty"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	ReportCount  int       `json:"report_count"`
+	Flags        []string  `json:"flags"`
+}
+
+func scanEmailRow(rows *sql.Rows) (EmailRow, error) {
+	var row EmailRow
+	var impersonates sql.NullString
+	var flags int
+	err := rows.Scan(&row.Email, &row.ThreatType, &row.Severity, &row.Confidence, &row.Source,
+		&impersonates, &row.FirstSeen, &row.LastSeen, &row.ReportCount, &flags)
+	row.Impersonates = impersonates.String
+	row.Flags = decodeFlags("threat_emails", flags)
+	return row, err
+}
+
+// PhoneRow teléfono de threat_phones
+type PhoneRow struct {
+	Phone       string    `json:"phone"`
+	CountryCode string    `json:"country_code"`
+	ThreatType  string    `json:"threat_type"`
+	Severity    string    `json:"severity"`
+	Confidence  int       `json:"confidence"`
+	Source      string    `json:"source"`
+	Description string    `json:"description,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+	Flags       []string  `json:"flags"`
+}
+
+func scanPhoneRow(rows *sql.Rows) (PhoneRow, error) {
+	var row PhoneRow
+	var description sql.NullString
+	var flags int
+	err := rows.Scan(&row.Phone, &row.CountryCode, &row.ThreatType, &row.Severity, &row.Confidence,
+		&row.Source, &description, &row.FirstSeen, &row.LastSeen, &flags)
+	row.Description = description.String
+	row.Flags = decodeFlags("threat_phones", flags)
+	return row, err
+}
+
+// WhitelistRow dominio de whitelist_domains
+type WhitelistRow struct {
+	Domain       string    `json:"domain"`
+	Category     string    `json:"category,omitempty"`
+	Brand        string    `json:"brand,omitempty"`
+	Country      string    `json:"country,omitempty"`
+	OfficialName string    `json:"official_name,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func scanWhitelistRow(rows *sql.Rows) (WhitelistRow, error) {
+	var row WhitelistRow
+	var category, brand, country, officialName sql.NullString
+	err := rows.Scan(&row.Domain, &category, &brand, &country, &officialName, &row.CreatedAt)
+	row.Category = category.String
+	row.Brand = brand.String
+	row.Country = country.String
+	row.OfficialName = officialName.String
+	return row, err
+}
+
+// ReportRow URL reportada por usuarios (reported_urls)
+type ReportRow struct {
+	URL             string    `json:"url"`
+	Domain          string    `json:"domain"`
+	ThreatType      string    `json:"threat_type,omitempty"`
+	AggregatedScore int       `json:"aggregated_score"`
+	TotalReports    int       `json:"total_reports"`
+	UniqueReporters int       `json:"unique_reporters"`
+	Status          string    `json:"status"`
+	FirstReported   time.Time `json:"first_reported"`
+	LastReported    time.Time `json:"last_reported"`
+	Promoted        bool      `json:"promoted"`
+}
+
+func scanReportRow(rows *sql.Rows) (ReportRow, error) {
+	var row ReportRow
+	var threatType sql.NullString
+	err := rows.Scan(&row.URL, &row.Domain, &threatType, &row.AggregatedScore, &row.TotalReports,
+		&row.UniqueReporters, &row.Status, &row.FirstReported, &row.LastReported, &row.Promoted)
+	row.ThreatType = threatType.String
+	return row, err
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// setupLogger misma configuración que fy-analysis y fy-dbsync: JSON en producción,
+// consola legible en development, nivel por LOG_LEVEL
+func setupLogger(environment, level string) {
+	if environment == "development" {
+		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
+	}
+
+	switch level {
+	case "debug":
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+	case "info":
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	case "warn":
+		zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	case "error":
+		zerolog.SetGlobalLevel(zerolog.ErrorLevel)
+	default:
+		zerolog.SetGlobalLevel(zerolog.InfoLevel)
+	}
+}
+
+// requestLogger registra cada petición (método, ruta, status, duración, IP). Los ficheros
+// estáticos y /api/health (sondeado por Docker) van a debug para no llenar los logs.
+func requestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			logger := ctxLogger(r.Context())
+			event := logger.Info()
+			api := strings.HasPrefix(r.URL.Path, "/api/") || strings.HasPrefix(r.URL.Path, "/v1/")
+			if !api || r.URL.Path == "/api/health" {
+				event = logger.Debug()
+			}
+			event.
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_addr", adminIP(r)).
+				Int("status", rec.status).
+				Int("bytes", rec.bytes).
+				Dur("duration", time.Since(start)).
+				Msg("request completed")
+		}()
+
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// statusRecorder captura status y bytes escritos (conserva Flush y Hijack del writer original)
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int
+	wroteHeader bool
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += n
+	return n, err
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	return hj.Hijack()
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,10 +19,16 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/pkg/clientip"
+	"github.com/trackfy/pkg/dbutil"
+	"github.com/trackfy/pkg/httpgzip"
+	"github.com/trackfy/pkg/signature"
 )
 
 //go:embed static/*
@@ -29,9 +36,47 @@ var staticFiles embed.FS
 
 type Config struct {
 	Port        string
+	// PartnerPort puerto de la API de partners (/v1/ti, STIX) con su propio listener: los
+	// partners no llegan al panel (PARTNER_PORT); vacío = deshabilitada
+	PartnerPort string
+	Environment string
+	LogLevel    string
 	DatabaseURL string
 	DBSyncURL   string
 	AnalysisURL string
+	// SigningSecret HMAC de las peticiones a fy-dbsync/fy-analysis (INTERNAL_SIGNING_SECRET)
+	SigningSecret string
+	// AnalysisAdminToken token de los endpoints /admin de fy-analysis (INTERNAL_ADMIN_TOKEN)
+	AnalysisAdminToken string
+	// QueryTimeout timeout de las consultas de lectura (DB_QUERY_TIMEOUT)
+	QueryTimeout time.Duration
+	// CORS orígenes externos que pueden llamar a la API (CORS_*)
+	CORS CORSConfig
+	// DBConnectRetries intentos de conexión al arrancar (DB_CONNECT_RETRIES)
+	DBConnectRetries int
+	// DBHealthInterval cada cuánto se comprueba la conexión (DB_HEALTH_INTERVAL)
+	DBHealthInterval time.Duration
+	// TOTPSecret secreto base32 del segundo factor de las acciones de alto riesgo (ADMIN_TOTP_SECRET).
+	// Vacío = se genera con /api/admin/totp/setup y se guarda en admin_totp.
+	TOTPSecret string
+	// PhishStatsURL CSV de PhishStats (PHISHSTATS_URL); "off" = feed deshabilitado
+	PhishStatsURL string
+	// SpamhausDBLURL fichero (rsync) o URL de la Spamhaus DBL (SPAMHAUS_DBL_URL); vacío = deshabilitado
+	SpamhausDBLURL string
+	// GatewayDatabaseURL BD de api-gateway (usuarios, suscripciones y pagos) para las
+	// estadísticas de negocio (GATEWAY_DATABASE_URL); vacío = deshabilitadas
+	GatewayDatabaseURL string
+	// AdminTokens tokens de los endpoints con datos sensibles (ADMIN_TOKENS):
+	// "nombre:token:permiso|permiso,...". Permisos: financial.
+	AdminTokens string
+	// RecheckDailyBatch dominios que se re-evalúan cada día (RECHECK_DAILY_BATCH); 0 = deshabilitado.
+	// Se puede cambiar en caliente con POST /api/actions/recheck {"daily_batch": N}.
+	RecheckDailyBatch int
+	// RecheckHour hora UTC del lote diario de re-evaluación (RECHECK_HOUR)
+	RecheckHour int
+	// TrustedProxies IPs o CIDRs de los proxies inversos cuyo X-Forwarded-For se respeta
+	// (TRUSTED_PROXIES); vacío = la IP del cliente es siempre la de la conexión
+	TrustedProxies string
 }
 
 type Server struct {
@@ -40,8 +85,33 @@ type Server struct {
 	client      *http.Client
 	syncStatus  map[string]*SyncProgress
 	syncMutex   sync.RWMutex
+	audit       *AuditLogger
+	timeseries  *timeseriesCache
+	dbHealth    *dbHealth
+	totp        *totpStore
+	apiKeys     *apiKeyStore
+	adminAuth   *adminAuth
+	feeds       []feedRegistration
+	escalator   *SeverityEscalator
+	// gatewayDB BD de api-gateway (solo lectura, estadísticas de suscripciones); nil = sin configurar
+	gatewayDB *sql.DB
+
+	// recheckDailyBatch dominios del lote diario de re-evaluación (0 = deshabilitado)
+	recheckDailyBatch atomic.Int32
+	// recheckMu evita dos re-evaluaciones en lote a la vez
+	recheckMu sync.Mutex
+
+	// Timeouts por consulta: una consulta lenta no retiene la conexión hasta que el cliente se desconecte
+	queryTimeout time.Duration
+	writeTimeout time.Duration
 }
 
+const (
+	defaultQueryTimeout = 5 * time.Second
+	defaultWriteTimeout = 30 * time.Second
+	defaultRecheckHour  = 4
+)
+
 // SyncProgress rastrea el progreso de una sincronización
 type SyncProgress struct {
 	Source     string    `json:"source"`
@@ -50,25 +120,94 @@ type SyncProgress struct {
 	Records    int64     `json:"records"`
 	Errors     int64     `json:"errors"`
 	Message    string    `json:"message"`
+	RetryCount int       `json:"retry_count"`
+	// CorrelationID de la petición del panel que lanzó la sincronización (vacío en las programadas)
+	CorrelationID string `json:"correlation_id,omitempty"`
+
+	// priorErrors errores de los intentos anteriores (Errors los acumula)
+	priorErrors int64
 }
 
 func main() {
 	config := &Config{
 		Port:        getEnv("PORT", "9092"),
+		PartnerPort: getEnv("PARTNER_PORT", "9093"),
+		Environment: getEnv("ENVIRONMENT", "development"),
+		LogLevel:    getEnv("LOG_LEVEL", "info"),
 		DatabaseURL: getEnv("DATABASE_URL", ""),
 		DBSyncURL:   getEnv("DBSYNC_URL", "http://fy-dbsync:9091"),
 		AnalysisURL: getEnv("ANALYSIS_URL", "http://fy-analysis:9090"),
+
+		SigningSecret:      getEnv("INTERNAL_SIGNING_SECRET", ""),
+		AnalysisAdminToken: getEnv("INTERNAL_ADMIN_TOKEN", ""),
+		QueryTimeout:       getEnvDuration("DB_QUERY_TIMEOUT", defaultQueryTimeout),
+		CORS:               loadCORSConfig(),
+		DBConnectRetries:   getEnvInt("DB_CONNECT_RETRIES", defaultDBConnectRetries),
+		DBHealthInterval:   getEnvDuration("DB_HEALTH_INTERVAL", defaultDBHealthInterval),
+		TOTPSecret:         getEnv("ADMIN_TOTP_SECRET", ""),
+		PhishStatsURL:      getEnv("PHISHSTATS_URL", phishStatsDefaultURL),
+		SpamhausDBLURL:     getEnv("SPAMHAUS_DBL_URL", ""),
+		GatewayDatabaseURL: getEnv("GATEWAY_DATABASE_URL", ""),
+		AdminTokens:        getEnv("ADMIN_TOKENS", ""),
+		RecheckDailyBatch:  getEnvInt("RECHECK_DAILY_BATCH", 0),
+		RecheckHour:        getEnvInt("RECHECK_HOUR", defaultRecheckHour),
+		TrustedProxies:     getEnv("TRUSTED_PROXIES", ""),
+	}
+	setupLogger(config.Environment, config.LogLevel)
+
+	if config.RecheckHour < 0 || config.RecheckHour > 23 {
+		log.Fatal().Int("hour", config.RecheckHour).Msg("Invalid RECHECK_HOUR (0-23)")
+	}
+	if config.RecheckDailyBatch < 0 || config.RecheckDailyBatch > recheckMaxBatch {
+		log.Fatal().Int("batch", config.RecheckDailyBatch).Msg("Invalid RECHECK_DAILY_BATCH (0-500)")
+	}
+
+	adminTokens, err := parseAdminTokens(config.AdminTokens)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid admin tokens configuration")
+	}
+	trustedProxies, err := clientip.ParseTrustedProxies(config.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid TRUSTED_PROXIES")
 	}
 
 	var db *sql.DB
+	var health *dbHealth
 	if config.DatabaseURL != "" {
+		// La conexión es opcional, pero una DATABASE_URL mal formada es un error de despliegue
+		if err := dbutil.ValidateDatabaseURL(config.DatabaseURL); err != nil {
+			log.Fatal().Err(err).Msg("Invalid database configuration")
+		}
+
 		var err error
 		db, err = sql.Open("postgres", config.DatabaseURL)
 		if err != nil {
-			fmt.Printf("Warning: Failed to connect to database: %v\n", err)
+			log.Warn().Err(err).Msg("Failed to connect to database")
+			db = nil
 		} else {
 			db.SetMaxOpenConns(5)
 			db.SetMaxIdleConns(2)
+
+			// Con docker-compose PostgreSQL puede no estar listo todavía
+			health = newDBHealth(db)
+			health.WaitForDB(config.DBConnectRetries)
+			health.Start(config.DBHealthInterval)
+		}
+	}
+
+	var gatewayDB *sql.DB
+	if config.GatewayDatabaseURL != "" {
+		if err := dbutil.ValidateDatabaseURL(config.GatewayDatabaseURL); err != nil {
+			log.Fatal().Err(err).Msg("Invalid gateway database configuration (GATEWAY_DATABASE_URL)")
+		}
+		// sql.Open no conecta: si la BD de api-gateway no responde fallan solo sus estadísticas
+		gatewayDB, err = sql.Open("postgres", config.GatewayDatabaseURL)
+		if err != nil {
+			log.Warn().Err(err).Msg("Failed to open gateway database")
+			gatewayDB = nil
+		} else {
+			gatewayDB.SetMaxOpenConns(2)
+			gatewayDB.SetMaxIdleConns(1)
 		}
 	}
 
@@ -77,11 +216,39 @@ func main() {
 		config: config,
 		client: &http.Client{Timeout: 30 * time.Second},
 		syncStatus: map[string]*SyncProgress{
-			"urlhaus":   {Source: "urlhaus"},
-			"openphish": {Source: "openphish"},
-			"emails":    {Source: "emails"},
-			"phones":    {Source: "phones"},
+			"emails":     {Source: "emails"},
+			"phones":     {Source: "phones"},
+			"phones_sfs": {Source: "phones_sfs"},
+			"import":     {Source: "import"},
+			"whitelist":  {Source: "whitelist"},
+
+			emailNormalizeSource: {Source: emailNormalizeSource},
 		},
+		feeds:        newFeedSources(config),
+		timeseries:   newTimeseriesCache(),
+		dbHealth:     health,
+		totp:         newTOTPStore(db, config.TOTPSecret),
+		apiKeys:      newAPIKeyStore(db),
+		adminAuth:    adminTokens,
+		gatewayDB:    gatewayDB,
+		queryTimeout: config.QueryTimeout,
+		writeTimeout: defaultWriteTimeout,
+	}
+	for _, feed := range server.feeds {
+		name := feed.source.Name()
+		server.syncStatus[name] = &SyncProgress{Source: name}
+	}
+	server.recheckDailyBatch.Store(int32(config.RecheckDailyBatch))
+	if db != nil {
+		server.audit = NewAuditLogger(db)
+		server.escalator = NewSeverityEscalator(db)
+	}
+
+	// Feeds que no programa fy-dbsync
+	var feedSync *feedScheduler
+	if db != nil {
+		feedSync = newFeedScheduler(server)
+		feedSync.Start()
 	}
 
 	mux := http.NewServeMux()
@@ -91,21 +258,72 @@ func main() {
 	mux.HandleFunc("/api/stats/database", server.handleDatabaseStats)
 	mux.HandleFunc("/api/stats/sources", server.handleSourcesStats)
 	mux.HandleFunc("/api/stats/sync", server.handleSyncStatus)
-	mux.HandleFunc("/api/actions/sync", server.handleForceSync)
+	mux.HandleFunc("/api/stats/timeseries", server.handleTimeseries)
+	mux.HandleFunc("/api/stats/subscriptions", server.adminAuthMiddleware(permFinancial, server.handleSubscriptionStats))
+	mux.HandleFunc("/api/database/performance", server.handleDatabasePerformance)
+	mux.HandleFunc("/api/actions/sync", server.totpMiddleware(server.handleForceSync))
 	mux.HandleFunc("/api/actions/sync/progress", server.handleSyncProgress)
+	mux.HandleFunc("/api/actions/email-normalize", server.totpMiddleware(server.handleEmailNormalize))
 	mux.HandleFunc("/api/services/status", server.handleServicesStatus)
+	mux.HandleFunc("/api/services/analysis/status", server.handleAnalysisDBStatus)
+	mux.HandleFunc("/api/services/analysis/sync", server.totpMiddleware(server.handleAnalysisDBSync))
 
 	// Data listing endpoints
 	mux.HandleFunc("/api/data/domains", server.handleListDomains)
+	mux.HandleFunc("/api/data/domains/", server.handleDomainDetail)
+	mux.HandleFunc("/api/data/paths", server.handleListPaths)
+	mux.HandleFunc("/api/data/paths/", server.totpMiddleware(server.handleDeletePath))
 	mux.HandleFunc("/api/data/emails", server.handleListEmails)
 	mux.HandleFunc("/api/data/phones", server.handleListPhones)
-	mux.HandleFunc("/api/data/whitelist", server.handleListWhitelist)
+	mux.HandleFunc("/api/data/whitelist", server.handleWhitelist)
+	mux.HandleFunc("/api/data/whitelist/", server.totpMiddleware(server.handleWhitelistDomain))
 	mux.HandleFunc("/api/data/reports", server.handleListReports)
 	mux.HandleFunc("/api/data/reports/stats", server.handleReportsStats)
 
+	// Búsqueda global
+	mux.HandleFunc("/api/search", server.handleSearch)
+
 	// Manual entry endpoints
 	mux.HandleFunc("/api/add/phone", server.handleAddPhone)
 	mux.HandleFunc("/api/add/email", server.handleAddEmail)
+	mux.HandleFunc("/api/import/csv", server.totpMiddleware(server.handleImportCSV))
+	mux.HandleFunc("/api/import/whitelist", server.totpMiddleware(server.handleImportWhitelist))
+	mux.HandleFunc("/api/export/whitelist", server.handleExportWhitelist)
+	mux.HandleFunc("/api/admin/whitelist/requests", server.handleListWhitelistRequests)
+	mux.HandleFunc("/api/admin/whitelist/requests/", server.totpMiddleware(server.handleReviewWhitelistRequest))
+
+	// Audit log
+	mux.HandleFunc("/api/audit/log", server.handleAuditLog)
+
+	// Baneos del sistema de reportes
+	mux.HandleFunc("/api/admin/users/", server.totpMiddleware(server.handleUserBan))
+
+	// Red de confianza entre reportadores (cuentas vinculadas y ajustes propagados)
+	mux.HandleFunc("/api/admin/trust-network", server.handleTrustNetwork)
+
+	// Marcas de analista (flags) de dominios, emails y teléfonos
+	mux.HandleFunc("/api/admin/flags", server.totpMiddleware(server.handleSetFlag))
+
+	// Tags de amenaza: alta, asignación a dominios y dominios por tag
+	mux.HandleFunc("/api/admin/tags", server.handleTags)
+	mux.HandleFunc("/api/admin/tags/domains", server.handleTagDomains)
+
+	// Re-evaluación de dominios con fy-analysis (veredicto nuevo frente al guardado)
+	mux.HandleFunc("/api/actions/recheck", server.totpMiddleware(server.handleRecheck))
+	mux.HandleFunc("/api/admin/recheck", server.handleRecheckLog)
+
+	// API keys de la API de inteligencia de amenazas
+	mux.HandleFunc("/api/admin/api-keys", server.handleListAPIKeys)
+	mux.HandleFunc("/api/admin/api-keys/new", server.totpMiddleware(server.handleCreateAPIKey))
+	mux.HandleFunc("/api/admin/api-keys/usage", server.handleAPIKeyUsage)
+	mux.HandleFunc("/api/admin/api-keys/", server.totpMiddleware(server.handleRevokeAPIKey))
+
+	// Entregas de los webhooks de amenazas de fy-analysis
+	mux.HandleFunc("/api/admin/webhooks/deliveries", server.handleListWebhookDeliveries)
+
+	// Segundo factor (TOTP) de las acciones de alto riesgo
+	mux.HandleFunc("/api/admin/totp/setup", server.handleTOTPSetup)
+	mux.HandleFunc("/api/admin/totp/confirm", server.handleTOTPConfirm)
 
 	// Static files
 	staticFS, _ := fs.Sub(staticFiles, "static")
@@ -113,18 +331,41 @@ func main() {
 
 	httpServer := &http.Server{
 		Addr:         ":" + config.Port,
-		Handler:      corsMiddleware(mux),
+		Handler:      correlationMiddleware(clientip.RealIP(trustedProxies)(requestLogger(corsMiddleware(config.CORS, httpgzip.Middleware(mux))))),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 	}
 
 	go func() {
-		fmt.Printf("Fy Admin Panel running on http://localhost:%s\n", config.Port)
+		log.Info().Str("port", config.Port).Msg("Fy Admin Panel running")
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			fmt.Printf("Server error: %v\n", err)
+			log.Fatal().Err(err).Msg("Server error")
 		}
 	}()
 
+	// API de inteligencia de amenazas para partners (API key, solo lectura) en otro listener:
+	// con su propio mux, una API key no da acceso a ningún endpoint del panel
+	var partnerServer *http.Server
+	if config.PartnerPort != "" {
+		partnerMux := http.NewServeMux()
+		partnerMux.HandleFunc("/v1/ti/", server.handleThreatIntel)
+		partnerMux.HandleFunc("/api/export/stix", server.handleExportSTIX)
+
+		partnerServer = &http.Server{
+			Addr:         ":" + config.PartnerPort,
+			Handler:      correlationMiddleware(clientip.RealIP(trustedProxies)(requestLogger(httpgzip.Middleware(partnerMux)))),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 15 * time.Second,
+		}
+
+		go func() {
+			log.Info().Str("port", config.PartnerPort).Msg("Partner threat intel API running")
+			if err := partnerServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Err(err).Msg("Partner API server error")
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -132,127 +373,116 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	httpServer.Shutdown(ctx)
+	if partnerServer != nil {
+		partnerServer.Shutdown(ctx)
+	}
 
+	if feedSync != nil {
+		feedSync.Stop()
+	}
+	if health != nil {
+		health.Stop()
+	}
 	if db != nil {
 		db.Close()
 	}
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
-}
-
+// handleHealth siempre responde 200 (el panel funciona sin BD); database indica el estado
+// de la conexión: connected, unavailable o not_configured
 func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "healthy"})
+
+	status, database := "healthy", "connected"
+	if s.db == nil {
+		database = "not_configured"
+	} else if !s.dbHealth.Healthy() {
+		status, database = "degraded", "unavailable"
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": status, "database": database})
 }
 
 func (s *Server) handleDatabaseStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
-	stats := make(map[string]interface{})
-
-	var domainsTotal, domainsActive int64
-	s.db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE (flags & 1) = 1) FROM threat_domains`).Scan(&domainsTotal, &domainsActive)
-	stats["domains"] = map[string]int64{"total": domainsTotal, "active": domainsActive}
-
-	var pathsTotal, pathsActive int64
-	s.db.QueryRow(`SELECT COUNT(*), COUNT(*) FILTER (WHERE (flags & 1) = 1) FROM threat_paths`).Scan(&pathsTotal, &pathsActive)
-	stats["paths"] = map[string]int64{"total": pathsTotal, "active": pathsActive}
-
-	var emailsTotal int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM threat_emails`).Scan(&emailsTotal)
-	stats["emails"] = map[string]int64{"total": emailsTotal}
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
 
-	// Estadísticas de emails por fuente
-	emailSourceStats := []map[string]interface{}{}
-	emailRows, err := s.db.Query(`
-		SELECT source::text, COUNT(*) as count
-		FROM threat_emails
-		GROUP BY source
-		ORDER BY count DESC
-	`)
-	if err == nil {
-		defer emailRows.Close()
-		for emailRows.Next() {
-			var source string
-			var count int64
-			if emailRows.Scan(&source, &count) == nil {
-				emailSourceStats = append(emailSourceStats, map[string]interface{}{
-					"source": source,
-					"count":  count,
-				})
-			}
+	// Los totales de tabla son estimaciones (pg_class.reltuples) salvo ?exact=true;
+	// activos y desgloses por fuente/tipo son siempre exactos
+	exact := wantExactCount(r)
+	var estimated atomic.Bool
+	tableTotal := func(table string) int64 {
+		total, isEstimate := s.tableCount(ctx, table, exact)
+		if isEstimate {
+			estimated.Store(true)
 		}
+		return total
 	}
-	stats["emails_by_source"] = emailSourceStats
 
-	var phonesTotal int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM threat_phones`).Scan(&phonesTotal)
-	stats["phones"] = map[string]int64{"total": phonesTotal}
-
-	var whitelistTotal int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM whitelist_domains`).Scan(&whitelistTotal)
-	stats["whitelist"] = map[string]int64{"total": whitelistTotal}
-
-	sourceStats := []map[string]interface{}{}
-	rows, err := s.db.Query(`
-		SELECT source::text, COUNT(*) as count
-		FROM threat_domains
-		GROUP BY source
-		ORDER BY count DESC
-	`)
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var source string
-			var count int64
-			if rows.Scan(&source, &count) == nil {
-				sourceStats = append(sourceStats, map[string]interface{}{
-					"source": source,
-					"count":  count,
-				})
-			}
-		}
-	}
-	stats["by_source"] = sourceStats
+	p := newParallelStats()
+	p.Go("domains", func() interface{} {
+		var active int64
+		s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM threat_domains WHERE `+flagActive.isSet("flags")).Scan(&active)
+		return map[string]int64{"total": tableTotal("threat_domains"), "active": active}
+	})
+	p.Go("paths", func() interface{} {
+		var active int64
+		s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM threat_paths WHERE `+flagActive.isSet("flags")).Scan(&active)
+		return map[string]int64{"total": tableTotal("threat_paths"), "active": active}
+	})
+	p.Go("emails", func() interface{} {
+		return map[string]int64{"total": tableTotal("threat_emails")}
+	})
+	p.Go("phones", func() interface{} {
+		return map[string]int64{"total": tableTotal("threat_phones")}
+	})
+	p.Go("whitelist", func() interface{} {
+		return map[string]int64{"total": tableTotal("whitelist_domains")}
+	})
+	// Estadísticas de emails por fuente
+	p.Go("emails_by_source", func() interface{} {
+		return s.groupCounts(ctx, "source", `
+			SELECT source::text, COUNT(*) as count
+			FROM threat_emails
+			GROUP BY source
+			ORDER BY count DESC
+		`)
+	})
+	p.Go("by_source", func() interface{} {
+		return s.groupCounts(ctx, "source", `
+			SELECT source::text, COUNT(*) as count
+			FROM threat_domains
+			GROUP BY source
+			ORDER BY count DESC
+		`)
+	})
+	p.Go("by_threat_type", func() interface{} {
+		return s.groupCounts(ctx, "type", `
+			SELECT threat_type::text, COUNT(*) as count
+			FROM threat_domains
+			WHERE `+flagActive.isSet("flags")+`
+			GROUP BY threat_type
+			ORDER BY count DESC
+		`)
+	})
+	p.Go("paths_by_threat_type", func() interface{} {
+		return s.groupCounts(ctx, "type", `
+			SELECT threat_type::text, COUNT(*) as count
+			FROM threat_paths
+			WHERE `+flagActive.isSet("flags")+`
+			GROUP BY threat_type
+			ORDER BY count DESC
+		`)
+	})
 
-	threatStats := []map[string]interface{}{}
-	rows2, err := s.db.Query(`
-		SELECT threat_type::text, COUNT(*) as count
-		FROM threat_domains
-		WHERE (flags & 1) = 1
-		GROUP BY threat_type
-		ORDER BY count DESC
-	`)
-	if err == nil {
-		defer rows2.Close()
-		for rows2.Next() {
-			var threatType string
-			var count int64
-			if rows2.Scan(&threatType, &count) == nil {
-				threatStats = append(threatStats, map[string]interface{}{
-					"type":  threatType,
-					"count": count,
-				})
-			}
-		}
-	}
-	stats["by_threat_type"] = threatStats
+	stats := p.Wait()
+	stats["estimated"] = estimated.Load()
 
 	json.NewEncoder(w).Encode(stats)
 }
@@ -260,77 +490,127 @@ func (s *Server) handleDatabaseStats(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleSourcesStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
-	// Intervalos de sincronización por defecto (deben coincidir con fy-dbsync)
-	// Nota: OpenPhish usa 'phishtank' en el enum de la BD
-	syncIntervals := map[string]time.Duration{
-		"urlhaus":   5 * time.Minute,
-		"phishtank": 1 * time.Hour,  // OpenPhish usa 'phishtank' en sync_status
-		"osint":     24 * time.Hour, // StopForumSpam
-	}
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
 
-	sources := []map[string]interface{}{}
+	// Último estado por valor de source_enum (OpenPhish usa 'phishtank')
+	type syncRow struct {
+		lastSync  time.Time
+		lastCount int64
+		lastError sql.NullString
+	}
+	statusRows := map[string]syncRow{}
+	var enumOrder []string
 
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT source::text, last_sync, last_count, last_error
 		FROM sync_status
 		ORDER BY source
 	`)
 	if err == nil {
 		defer rows.Close()
-		now := time.Now()
 		for rows.Next() {
 			var source string
-			var lastSync time.Time
-			var lastCount int64
-			var lastError sql.NullString
-
-			if rows.Scan(&source, &lastSync, &lastCount, &lastError) == nil {
-				// Mapear nombre para frontend (phishtank -> openphish)
-				displayName := source
-				if source == "phishtank" {
-					displayName = "openphish"
-				}
-
-				src := map[string]interface{}{
-					"name":       displayName,
-					"last_sync":  lastSync.Format(time.RFC3339),
-					"last_count": lastCount,
-					"status":     "ok",
-				}
-				if lastError.Valid && lastError.String != "" {
-					src["status"] = "error"
-					src["error"] = lastError.String
-				}
+			var row syncRow
+			if rows.Scan(&source, &row.lastSync, &row.lastCount, &row.lastError) == nil {
+				statusRows[source] = row
+				enumOrder = append(enumOrder, source)
+			}
+		}
+	}
 
-				// Calcular tiempo restante para próxima sincronización
-				if interval, ok := syncIntervals[source]; ok {
-					nextSync := lastSync.Add(interval)
-					remaining := nextSync.Sub(now)
-					if remaining < 0 {
-						remaining = 0 // Ya debería sincronizarse
-					}
-					src["interval_seconds"] = int64(interval.Seconds())
-					src["next_sync"] = nextSync.Format(time.RFC3339)
-					src["remaining_seconds"] = int64(remaining.Seconds())
-				}
+	// Fuentes conocidas: los feeds registrados más las listas de emails y teléfonos.
+	// Los intervalos deben coincidir con fy-dbsync para los feeds que programa él.
+	type sourceInfo struct {
+		name, enum, label, description string
+		interval                       time.Duration
+	}
+	known := make([]sourceInfo, 0, len(s.feeds)+3)
+	for _, feed := range s.feeds {
+		known = append(known, sourceInfo{
+			name:        feed.source.Name(),
+			enum:        feed.source.Threat().sourceEnum,
+			label:       feed.label,
+			description: feed.description,
+			interval:    feed.source.Interval(),
+		})
+	}
+	known = append(known,
+		sourceInfo{name: "emails", enum: "osint", label: "StopForumSpam", description: "Spam Emails", interval: 24 * time.Hour},
+		sourceInfo{name: "phones", enum: "listahu_phones", label: "Lista Hu", description: "Scam Phones", interval: 24 * time.Hour},
+		sourceInfo{name: "phones_sfs", enum: "sfs_phones", label: "StopForumSpam", description: "Spam Phones", interval: 24 * time.Hour},
+	)
 
-				sources = append(sources, src)
+	sources := []map[string]interface{}{}
+	now := time.Now()
+	seen := map[string]bool{}
+
+	for _, info := range known {
+		src := map[string]interface{}{
+			"name":             info.name,
+			"label":            info.label,
+			"description":      info.description,
+			"sync_key":         info.name,
+			"interval_seconds": int64(info.interval.Seconds()),
+		}
+		if row, ok := statusRows[info.enum]; ok && info.enum != "" {
+			seen[info.enum] = true
+			addSyncRowFields(src, row.lastSync, row.lastCount, row.lastError)
+
+			// Calcular tiempo restante para próxima sincronización
+			nextSync := row.lastSync.Add(info.interval)
+			remaining := nextSync.Sub(now)
+			if remaining < 0 {
+				remaining = 0 // Ya debería sincronizarse
 			}
+			src["next_sync"] = nextSync.Format(time.RFC3339)
+			src["remaining_seconds"] = int64(remaining.Seconds())
+		}
+		sources = append(sources, src)
+	}
+
+	// Fuentes de sync_status que no se sincronizan desde el panel (p. ej. misp)
+	for _, enum := range enumOrder {
+		if seen[enum] {
+			continue
 		}
+		row := statusRows[enum]
+		src := map[string]interface{}{"name": enum, "label": enum}
+		addSyncRowFields(src, row.lastSync, row.lastCount, row.lastError)
+		sources = append(sources, src)
 	}
 
 	json.NewEncoder(w).Encode(map[string]interface{}{"sources": sources})
 }
 
+// addSyncRowFields añade a una fuente de handleSourcesStats su última fila de sync_status
+func addSyncRowFields(src map[string]interface{}, lastSync time.Time, lastCount int64, lastError sql.NullString) {
+	src["last_sync"] = lastSync.Format(time.RFC3339)
+	src["last_count"] = lastCount
+	src["status"] = "ok"
+	if lastError.Valid && lastError.String != "" {
+		src["status"] = "error"
+		src["error"] = lastError.String
+	}
+}
+
 func (s *Server) handleSyncStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	resp, err := s.client.Get(s.config.DBSyncURL + "/status")
+	req, err := http.NewRequestWithContext(r.Context(), "GET", s.config.DBSyncURL+"/status", nil)
+	if err == nil {
+		setCorrelationHeader(req)
+		err = signature.SignRequest(req, s.config.SigningSecret)
+	}
+	var resp *http.Response
+	if err == nil {
+		resp, err = s.client.Do(req)
+	}
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"status": "offline",
@@ -356,7 +636,7 @@ func (s *Server) handleForceSync(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
 			"error":   "Database not connected",
@@ -368,6 +648,18 @@ func (s *Server) handleForceSync(w http.ResponseWriter, r *http.Request) {
 	if source == "" {
 		source = "all"
 	}
+	if source == "stopforumspam" {
+		source = "emails"
+	}
+
+	runners := s.syncRunners()
+	if _, ok := runners[source]; !ok && source != "all" {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Unknown source: " + source,
+		})
+		return
+	}
 
 	// Verificar si ya hay un sync en progreso para esta fuente
 	s.syncMutex.RLock()
@@ -383,38 +675,59 @@ func (s *Server) handleForceSync(w http.ResponseWriter, r *http.Request) {
 	}
 	s.syncMutex.RUnlock()
 
-	// Iniciar sync en background
+	s.auditLog(r, &AuditEntry{
+		Action:   "force_sync",
+		Table:    "sync_status",
+		RecordID: source,
+		NewValue: auditJSON(map[string]string{"source": source}),
+	})
+
+	// Iniciar sync en background (cada intento tiene su propio timeout en RetryableSync).
+	// El contexto conserva el correlation ID para los logs y SyncProgress.
+	correlation := correlationID(r.Context())
 	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-		defer cancel()
+		ctx := withCorrelationID(context.Background(), correlation)
+
+		if source != "all" {
+			s.RetryableSync(ctx, source, runners[source])
+			return
+		}
 
-		switch source {
-		case "urlhaus":
-			s.syncURLhaus(ctx)
-		case "openphish":
-			s.syncOpenPhish(ctx)
-		case "emails", "stopforumspam":
-			s.syncStopForumSpam(ctx)
-		case "phones":
-			s.syncPhones(ctx)
-		case "all":
-			// Sincronizar todas las fuentes en paralelo
-			var wg sync.WaitGroup
-			wg.Add(4)
-			go func() { defer wg.Done(); s.syncURLhaus(ctx) }()
-			go func() { defer wg.Done(); s.syncOpenPhish(ctx) }()
-			go func() { defer wg.Done(); s.syncStopForumSpam(ctx) }()
-			go func() { defer wg.Done(); s.syncPhones(ctx) }()
-			wg.Wait()
+		// Sincronizar todas las fuentes en paralelo
+		var wg sync.WaitGroup
+		for name, run := range runners {
+			wg.Add(1)
+			go func(name string, run func(context.Context) error) {
+				defer wg.Done()
+				s.RetryableSync(ctx, name, run)
+			}(name, run)
 		}
+		wg.Wait()
 	}()
 
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success": true,
-		"message": "Sync started for: " + source,
+		"success":        true,
+		"message":        "Sync started for: " + source,
+		"correlation_id": correlation,
 	})
 }
 
+// syncRunners sincronizaciones que se pueden forzar desde el panel, por nombre de fuente
+func (s *Server) syncRunners() map[string]func(context.Context) error {
+	runners := map[string]func(context.Context) error{
+		"emails":     s.syncStopForumSpam,
+		"phones":     s.syncPhones,
+		"phones_sfs": s.syncStopForumSpamPhones,
+	}
+	for _, feed := range s.feeds {
+		source := feed.source
+		runners[source.Name()] = func(ctx context.Context) error {
+			return s.syncFeed(ctx, source)
+		}
+	}
+	return runners
+}
+
 func (s *Server) handleSyncProgress(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
@@ -444,10 +757,8 @@ func (s *Server) handleServicesStatus(w http.ResponseWriter, r *http.Request) {
 	})
 
 	dbStatus := "offline"
-	if s.db != nil {
-		if err := s.db.Ping(); err == nil {
-			dbStatus = "online"
-		}
+	if s.dbReady() {
+		dbStatus = "online"
 	}
 	services = append(services, map[string]interface{}{
 		"name":   "PostgreSQL",
@@ -457,32 +768,97 @@ func (s *Server) handleServicesStatus(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"services": services})
 }
 
+// handleAnalysisDBStatus estado de las bases de datos locales de fy-analysis (PhishTank/URLhaus)
+func (s *Server) handleAnalysisDBStatus(w http.ResponseWriter, r *http.Request) {
+	s.proxyAnalysisAdmin(w, r, http.MethodGet, "/admin/status")
+}
+
+// handleAnalysisDBSync lanza la re-descarga de PhishTank/URLhaus en fy-analysis (?db=urlhaus|phishtank|all)
+func (s *Server) handleAnalysisDBSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	db := r.URL.Query().Get("db")
+	if db == "" {
+		db = "all"
+	}
+
+	s.auditLog(r, &AuditEntry{
+		Action:   "analysis_db_sync",
+		Table:    "sync_status",
+		RecordID: db,
+		NewValue: auditJSON(map[string]string{"service": "fy-analysis", "db": db}),
+	})
+	s.proxyAnalysisAdmin(w, r, http.MethodPost, "/admin/sync?db="+url.QueryEscape(db))
+}
+
+// proxyAnalysisAdmin reenvía la petición a los endpoints /admin de fy-analysis
+// con el token interno y la firma HMAC
+func (s *Server) proxyAnalysisAdmin(w http.ResponseWriter, r *http.Request, method, path string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	req, err := http.NewRequestWithContext(r.Context(), method, s.config.AnalysisURL+path, nil)
+	if err == nil {
+		req.Header.Set("X-Internal-Token", s.config.AnalysisAdminToken)
+		setCorrelationHeader(req)
+		err = signature.SignRequest(req, s.config.SigningSecret)
+	}
+	var resp *http.Response
+	if err == nil {
+		resp, err = s.client.Do(req)
+	}
+	if err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"status": "offline",
+			"error":  err.Error(),
+		})
+		return
+	}
+	defer resp.Body.Close()
+
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
 func (s *Server) handleListDomains(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	limit := getQueryInt(r, "limit", 50)
 	offset := getQueryInt(r, "offset", 0)
 	search := r.URL.Query().Get("search")
 	source := r.URL.Query().Get("source")
 	threatType := r.URL.Query().Get("threat_type")
+	// include_paths=true: la búsqueda también encuentra dominios por sus paths (threat_paths)
+	// y cada dominio incluye sus paths activos
+	includePaths := r.URL.Query().Get("include_paths") == "true"
 
 	query := `
 		SELECT domain, threat_type::text, severity::text, confidence, source::text,
-		       first_seen, last_seen, hit_count
+		       first_seen, last_seen, hit_count, COALESCE(flags, 0)
 		FROM threat_domains
-		WHERE (flags & 1) = 1
+		WHERE ` + flagActive.isSet("flags") + `
 	`
 	args := []interface{}{}
 	argCount := 0
 
 	if search != "" {
 		argCount++
-		query += fmt.Sprintf(" AND domain ILIKE $%d", argCount)
+		if includePaths {
+			query += fmt.Sprintf(" AND (domain ILIKE $%d OR %s)", argCount, pathSearchFilter(argCount))
+		} else {
+			query += fmt.Sprintf(" AND domain ILIKE $%d", argCount)
+		}
 		args = append(args, "%"+search+"%")
 	}
 	if source != "" {
@@ -499,55 +875,53 @@ func (s *Server) handleListDomains(w http.ResponseWriter, r *http.Request) {
 	query += " ORDER BY last_seen DESC"
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	domains := []map[string]interface{}{}
-	for rows.Next() {
-		var domain, threatType, severity, source string
-		var confidence int
-		var firstSeen, lastSeen time.Time
-		var hitCount int
-
-		if rows.Scan(&domain, &threatType, &severity, &confidence, &source, &firstSeen, &lastSeen, &hitCount) == nil {
-			domains = append(domains, map[string]interface{}{
-				"domain":      domain,
-				"threat_type": threatType,
-				"severity":    severity,
-				"confidence":  confidence,
-				"source":      source,
-				"first_seen":  firstSeen.Format(time.RFC3339),
-				"last_seen":   lastSeen.Format(time.RFC3339),
-				"hit_count":   hitCount,
-			})
+	domains, scanErrors, err := scanRows(rows, "threat_domains", scanDomainRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	if includePaths {
+		names := make([]string, len(domains))
+		for i, domain := range domains {
+			names[i] = domain.Domain
+		}
+		paths, err := s.listedDomainPaths(ctx, names, search)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+			return
+		}
+		for i := range domains {
+			domains[i].Paths = paths[domains[i].Domain]
 		}
 	}
 
 	// Get total count
-	countQuery := `SELECT COUNT(*) FROM threat_domains WHERE (flags & 1) = 1`
+	countQuery := `SELECT COUNT(*) FROM threat_domains WHERE ` + flagActive.isSet("flags")
 	var total int64
-	s.db.QueryRow(countQuery).Scan(&total)
+	s.db.QueryRowContext(ctx, countQuery).Scan(&total)
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":   domains,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	json.NewEncoder(w).Encode(listResponse(w, r, domains, scanErrors, total, limit, offset))
 }
 
 func (s *Server) handleListEmails(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	limit := getQueryInt(r, "limit", 50)
 	offset := getQueryInt(r, "offset", 0)
 	search := r.URL.Query().Get("search")
@@ -556,9 +930,9 @@ func (s *Server) handleListEmails(w http.ResponseWriter, r *http.Request) {
 
 	query := `
 		SELECT email, threat_type::text, severity::text, confidence, source::text,
-		       impersonates, first_seen, last_seen, report_count
+		       impersonates, first_seen, last_seen, report_count, COALESCE(flags, 0)
 		FROM threat_emails
-		WHERE (flags & 1) = 1
+		WHERE ` + flagActive.isSet("flags") + `
 	`
 	args := []interface{}{}
 	argCount := 0
@@ -582,40 +956,21 @@ func (s *Server) handleListEmails(w http.ResponseWriter, r *http.Request) {
 	query += " ORDER BY last_seen DESC"
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	emails := []map[string]interface{}{}
-	for rows.Next() {
-		var email, threatType, severity, source string
-		var confidence, reportCount int
-		var impersonates sql.NullString
-		var firstSeen, lastSeen time.Time
-
-		if rows.Scan(&email, &threatType, &severity, &confidence, &source, &impersonates, &firstSeen, &lastSeen, &reportCount) == nil {
-			item := map[string]interface{}{
-				"email":        email,
-				"threat_type":  threatType,
-				"severity":     severity,
-				"confidence":   confidence,
-				"source":       source,
-				"first_seen":   firstSeen.Format(time.RFC3339),
-				"last_seen":    lastSeen.Format(time.RFC3339),
-				"report_count": reportCount,
-			}
-			if impersonates.Valid {
-				item["impersonates"] = impersonates.String
-			}
-			emails = append(emails, item)
-		}
+	emails, scanErrors, err := scanRows(rows, "threat_emails", scanEmailRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
 	// Get total count with same filters
-	countQuery := `SELECT COUNT(*) FROM threat_emails WHERE (flags & 1) = 1`
+	countQuery := `SELECT COUNT(*) FROM threat_emails WHERE ` + flagActive.isSet("flags")
 	countArgs := []interface{}{}
 	argCount = 0
 	if search != "" {
@@ -635,33 +990,31 @@ func (s *Server) handleListEmails(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var total int64
-	s.db.QueryRow(countQuery, countArgs...).Scan(&total)
+	s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":   emails,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	json.NewEncoder(w).Encode(listResponse(w, r, emails, scanErrors, total, limit, offset))
 }
 
 func (s *Server) handleListPhones(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	limit := getQueryInt(r, "limit", 50)
 	offset := getQueryInt(r, "offset", 0)
 	search := r.URL.Query().Get("search")
 
 	query := `
 		SELECT phone_national, country_code, threat_type::text, severity::text,
-		       confidence, source::text, description, first_seen, last_seen
+		       confidence, source::text, description, first_seen, last_seen, COALESCE(flags, 0)
 		FROM threat_phones
-		WHERE (flags & 1) = 1
+		WHERE ` + flagActive.isSet("flags") + `
 	`
 	args := []interface{}{}
 
@@ -673,57 +1026,36 @@ func (s *Server) handleListPhones(w http.ResponseWriter, r *http.Request) {
 	query += " ORDER BY last_seen DESC"
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	phones := []map[string]interface{}{}
-	for rows.Next() {
-		var phone, countryCode, threatType, severity, source string
-		var confidence int
-		var description sql.NullString
-		var firstSeen, lastSeen time.Time
-
-		if rows.Scan(&phone, &countryCode, &threatType, &severity, &confidence, &source, &description, &firstSeen, &lastSeen) == nil {
-			item := map[string]interface{}{
-				"phone":        phone,
-				"country_code": countryCode,
-				"threat_type":  threatType,
-				"severity":     severity,
-				"confidence":   confidence,
-				"source":       source,
-				"first_seen":   firstSeen.Format(time.RFC3339),
-				"last_seen":    lastSeen.Format(time.RFC3339),
-			}
-			if description.Valid {
-				item["description"] = description.String
-			}
-			phones = append(phones, item)
-		}
+	phones, scanErrors, err := scanRows(rows, "threat_phones", scanPhoneRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
 	var total int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM threat_phones WHERE (flags & 1) = 1`).Scan(&total)
+	s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM threat_phones WHERE `+flagActive.isSet("flags")).Scan(&total)
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":   phones,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	json.NewEncoder(w).Encode(listResponse(w, r, phones, scanErrors, total, limit, offset))
 }
 
 func (s *Server) handleListWhitelist(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	limit := getQueryInt(r, "limit", 50)
 	offset := getQueryInt(r, "offset", 0)
 	search := r.URL.Query().Get("search")
@@ -743,49 +1075,24 @@ func (s *Server) handleListWhitelist(w http.ResponseWriter, r *http.Request) {
 	query += " ORDER BY brand, domain"
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	whitelist := []map[string]interface{}{}
-	for rows.Next() {
-		var domain string
-		var category, brand, country, officialName sql.NullString
-		var createdAt time.Time
-
-		if rows.Scan(&domain, &category, &brand, &country, &officialName, &createdAt) == nil {
-			item := map[string]interface{}{
-				"domain":     domain,
-				"created_at": createdAt.Format(time.RFC3339),
-			}
-			if category.Valid {
-				item["category"] = category.String
-			}
-			if brand.Valid {
-				item["brand"] = brand.String
-			}
-			if country.Valid {
-				item["country"] = country.String
-			}
-			if officialName.Valid {
-				item["official_name"] = officialName.String
-			}
-			whitelist = append(whitelist, item)
-		}
+	whitelist, scanErrors, err := scanRows(rows, "whitelist_domains", scanWhitelistRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
-	var total int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM whitelist_domains`).Scan(&total)
+	total, estimated := s.tableCount(ctx, "whitelist_domains", wantExactCount(r))
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":   whitelist,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	resp := listResponse(w, r, whitelist, scanErrors, total, limit, offset)
+	resp.TotalEstimated = &estimated
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (s *Server) handleAddPhone(w http.ResponseWriter, r *http.Request) {
@@ -796,7 +1103,7 @@ func (s *Server) handleAddPhone(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
 		return
 	}
@@ -819,23 +1126,18 @@ func (s *Server) handleAddPhone(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Valores por defecto
-	if input.ThreatType == "" {
-		input.ThreatType = "scam"
-	}
-	if input.Severity == "" {
-		input.Severity = "medium"
+	phone, err := normalizeManualPhone(input.Phone, input.CountryCode, input.ThreatType, input.Severity)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
 	}
+	phone.Description = input.Description
 
-	now := time.Now()
-	_, err := s.db.Exec(`
-		INSERT INTO threat_phones (phone_national, country_code, threat_type, severity, confidence, source, description, first_seen, last_seen, flags)
-		VALUES ($1, $2, $3::threat_type_enum, $4::severity_enum, 80, 'manual'::source_enum, $5, $6, $7, 1)
-		ON CONFLICT (phone_national) DO UPDATE SET
-			last_seen = EXCLUDED.last_seen,
-			report_count = threat_phones.report_count + 1
-	`, input.Phone, input.CountryCode, input.ThreatType, input.Severity, input.Description, now, now)
-
+	err = s.auditWrite(r, "add_phone", "threat_phones", phone.Value, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		return insertManualPhone(ctx, s.db, phone)
+	})
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
@@ -852,7 +1154,7 @@ func (s *Server) handleAddEmail(w http.ResponseWriter, r *http.Request) {
 
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
 		return
 	}
@@ -874,29 +1176,18 @@ func (s *Server) handleAddEmail(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Valores por defecto
-	if input.ThreatType == "" {
-		input.ThreatType = "phishing"
-	}
-	if input.Severity == "" {
-		input.Severity = "medium"
+	email, err := normalizeManualEmail(input.Email, input.ThreatType, input.Severity)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
 	}
+	email.Impersonates = input.Impersonates
 
-	// Extraer dominio del email
-	domain := ""
-	if idx := strings.LastIndex(input.Email, "@"); idx > 0 {
-		domain = input.Email[idx+1:]
-	}
-
-	now := time.Now()
-	_, err := s.db.Exec(`
-		INSERT INTO threat_emails (email_hash, email, domain_hash, threat_type, severity, confidence, source, impersonates, first_seen, last_seen, flags)
-		VALUES (sha256_bytea($1), $1, sha256_bytea($2), $3::threat_type_enum, $4::severity_enum, 80, 'manual'::source_enum, $5, $6, $7, 1)
-		ON CONFLICT (email_hash) DO UPDATE SET
-			last_seen = EXCLUDED.last_seen,
-			report_count = threat_emails.report_count + 1
-	`, input.Email, domain, input.ThreatType, input.Severity, input.Impersonates, now, now)
-
+	err = s.auditWrite(r, "add_email", "threat_emails", email.Value, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		return insertManualEmail(ctx, s.db, email)
+	})
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
 		return
@@ -905,285 +1196,259 @@ func (s *Server) handleAddEmail(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "message": "Email added successfully"})
 }
 
-func (s *Server) checkService(url string) string {
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
-	resp, err := s.client.Do(req)
-	if err != nil {
-		return "offline"
-	}
-	defer resp.Body.Close()
+// ============================================================================
+// MANUAL ENTRIES - Normalización e inserción compartida por /api/add/* y /api/import/csv
+// ============================================================================
 
-	if resp.StatusCode == http.StatusOK {
-		return "online"
-	}
-	return "error"
+var validThreatTypes = map[string]bool{
+	"phishing": true, "malware": true, "scam": true, "spam": true, "vishing": true,
+	"smishing": true, "premium_fraud": true, "ransomware": true, "cryptojacking": true, "other": true,
 }
 
-func getEnv(key, defaultValue string) string {
-	if v := os.Getenv(key); v != "" {
-		return v
-	}
-	return defaultValue
+var validSeverities = map[string]bool{"low": true, "medium": true, "high": true, "critical": true}
+
+// manualEntry entrada manual ya normalizada y validada
+type manualEntry struct {
+	Value        string // phone_national, email o dominio
+	CountryCode  string // Solo teléfonos
+	Domain       string // Solo emails
+	ThreatType   string
+	Severity     string
+	Description  string
+	Impersonates string
 }
 
-func getQueryInt(r *http.Request, key string, defaultValue int) int {
-	val := r.URL.Query().Get(key)
-	if val == "" {
-		return defaultValue
+// normalizeThreatFields aplica valores por defecto y valida contra los enums de la BD
+func normalizeThreatFields(threatType, severity, defaultType string) (string, string, error) {
+	threatType = strings.ToLower(strings.TrimSpace(threatType))
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	if threatType == "" {
+		threatType = defaultType
 	}
-	i, err := strconv.Atoi(val)
-	if err != nil {
-		return defaultValue
+	if severity == "" {
+		severity = "medium"
 	}
-	return i
+	if !validThreatTypes[threatType] {
+		return "", "", fmt.Errorf("invalid threat_type: %s", threatType)
+	}
+	if !validSeverities[severity] {
+		return "", "", fmt.Errorf("invalid severity: %s", severity)
+	}
+	return threatType, severity, nil
 }
 
-// ============================================================================
-// SYNC FUNCTIONS - Importación directa de fuentes de amenazas
-// ============================================================================
-
-const (
-	urlhausDownloadURL      = "https://urlhaus.abuse.ch/downloads/text/"
-	openPhishURL            = "https://openphish.com/feed.txt"
-	stopForumSpamEmailsURL  = "https://www.stopforumspam.com/downloads/listed_email_365_all.gz"
-	listaHuPhonesURL        = "https://listahu.org/descargar/csv"
-)
-
-// syncURLhaus descarga e importa datos de URLhaus
-func (s *Server) syncURLhaus(ctx context.Context) {
-	source := "urlhaus"
-	s.updateSyncStatus(source, true, "Downloading URLhaus feed...")
-
-	startTime := time.Now()
-	var records, errors int64
-
-	defer func() {
-		duration := time.Since(startTime)
-		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v", duration.Round(time.Second)))
-	}()
-
-	req, err := http.NewRequestWithContext(ctx, "GET", urlhausDownloadURL, nil)
+// normalizeManualPhone deja el número como phone_national (solo dígitos, sin código de país)
+func normalizeManualPhone(phone, countryCode, threatType, severity string) (*manualEntry, error) {
+	national, countryCode, err := nationalPhone(phone, countryCode)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to create request: "+err.Error())
-		return
+		return nil, err
 	}
-	req.Header.Set("User-Agent", "Fy-Admin/1.0")
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	threatType, severity, err = normalizeThreatFields(threatType, severity, "scam")
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to download: "+err.Error())
-		return
+		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		s.updateSyncStatusComplete(source, 0, 1, fmt.Sprintf("Download failed with status: %d", resp.StatusCode))
-		return
-	}
-
-	s.updateSyncStatus(source, true, "Parsing and importing...")
 
-	scanner := bufio.NewScanner(resp.Body)
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	lineNum := 0
-	now := time.Now()
+	return &manualEntry{Value: national, CountryCode: countryCode, ThreatType: threatType, Severity: severity}, nil
+}
 
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
+// nationalPhone número como phone_national y su código de país (34 por defecto)
+func nationalPhone(phone, countryCode string) (string, string, error) {
+	countryCode = strings.TrimPrefix(strings.TrimSpace(countryCode), "+")
+	if countryCode == "" {
+		countryCode = "34"
+	}
 
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
+	hasPrefix := strings.HasPrefix(strings.TrimSpace(phone), "+") || strings.HasPrefix(strings.TrimSpace(phone), "00")
+	var digits strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
 		}
+	}
+	national := strings.TrimPrefix(digits.String(), "00")
 
-		lineNum++
-
-		parsedURL, err := url.Parse(line)
-		if err != nil {
-			errors++
-			continue
-		}
+	// Quitar el código de país si viene en formato internacional
+	if hasPrefix || len(national) > 9 {
+		national = strings.TrimPrefix(national, countryCode)
+	}
 
-		domain := strings.ToLower(parsedURL.Hostname())
-		if domain == "" || len(domain) < 3 || !strings.Contains(domain, ".") {
-			errors++
-			continue
-		}
+	if len(national) < 6 || len(national) > 15 {
+		return "", "", fmt.Errorf("invalid phone number: %s", phone)
+	}
+	return national, countryCode, nil
+}
 
-		// Remover puerto
-		if idx := strings.Index(domain, ":"); idx != -1 {
-			domain = domain[:idx]
-		}
+// normalizeManualEmail limpia el email y extrae su dominio
+func normalizeManualEmail(email, threatType, severity string) (*manualEntry, error) {
+	email = strings.ToLower(cleanEmail(email))
 
-		// Saltar IPs
-		if net.ParseIP(domain) != nil {
-			continue
-		}
+	idx := strings.LastIndex(email, "@")
+	if idx <= 0 || strings.Count(email, "@") != 1 || !strings.Contains(email[idx+1:], ".") {
+		return nil, fmt.Errorf("invalid email: %s", email)
+	}
 
-		tld := extractTLD(domain)
-		sourceID := fmt.Sprintf("urlhaus-%d", lineNum)
+	threatType, severity, err := normalizeThreatFields(threatType, severity, "phishing")
+	if err != nil {
+		return nil, err
+	}
 
-		_, err = s.db.ExecContext(ctx, `
-			INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, source_id, tld, first_seen, last_seen, flags)
-			VALUES (sha256_bytea($1), $1, 'malware'::threat_type_enum, 'high'::severity_enum, 85, 'urlhaus'::source_enum, $2, $3, $4, $5, 1)
-			ON CONFLICT (domain_hash) DO UPDATE SET
-				last_seen = EXCLUDED.last_seen,
-				hit_count = threat_domains.hit_count + 1,
-				confidence = GREATEST(threat_domains.confidence, EXCLUDED.confidence)
-		`, domain, sourceID, tld, now, now)
+	return &manualEntry{Value: email, Domain: email[idx+1:], ThreatType: threatType, Severity: severity}, nil
+}
 
+// normalizeManualDomain acepta un dominio o una URL y retorna el host en minúsculas
+func normalizeManualDomain(domain, threatType, severity string) (*manualEntry, error) {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if strings.Contains(domain, "://") {
+		parsedURL, err := url.Parse(domain)
 		if err != nil {
-			errors++
-			continue
+			return nil, fmt.Errorf("invalid domain: %s", domain)
 		}
-		records++
+		domain = parsedURL.Hostname()
+	}
+	domain = strings.TrimSuffix(strings.SplitN(domain, "/", 2)[0], ".")
+	if idx := strings.Index(domain, ":"); idx != -1 {
+		domain = domain[:idx]
+	}
 
-		// Insertar path si existe
-		path := parsedURL.Path
-		if path != "" && path != "/" {
-			fullPath := domain + path
-			s.db.ExecContext(ctx, `
-				INSERT INTO threat_paths (path_hash, domain_hash, path, threat_type, severity, confidence, source, first_seen, last_seen, flags)
-				VALUES (sha256_bytea($1), sha256_bytea($2), $3, 'malware'::threat_type_enum, 'high'::severity_enum, 85, 'urlhaus'::source_enum, $4, $5, 1)
-				ON CONFLICT (path_hash) DO UPDATE SET last_seen = EXCLUDED.last_seen
-			`, fullPath, domain, path, now, now)
-		}
+	if len(domain) < 3 || !strings.Contains(domain, ".") || net.ParseIP(domain) != nil {
+		return nil, fmt.Errorf("invalid domain: %s", domain)
+	}
 
-		if lineNum%1000 == 0 {
-			s.updateSyncStatus(source, true, fmt.Sprintf("Imported %d domains...", records))
-		}
+	threatType, severity, err := normalizeThreatFields(threatType, severity, "phishing")
+	if err != nil {
+		return nil, err
 	}
 
-	// Actualizar sync_status en BD
-	s.db.ExecContext(ctx, `
-		INSERT INTO sync_status (source, last_sync, last_count)
-		VALUES ('urlhaus'::source_enum, NOW(), $1)
-		ON CONFLICT (source) DO UPDATE SET
-			last_sync = NOW(),
-			last_count = $1
-	`, records)
+	return &manualEntry{Value: domain, ThreatType: threatType, Severity: severity}, nil
 }
 
-// syncOpenPhish descarga e importa datos de OpenPhish
-func (s *Server) syncOpenPhish(ctx context.Context) {
-	source := "openphish"
-	s.updateSyncStatus(source, true, "Downloading OpenPhish feed...")
+// dbExecutor permite insertar tanto con *sql.DB como dentro de una *sql.Tx
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
 
-	startTime := time.Now()
-	var records, errors int64
+func insertManualPhone(ctx context.Context, db dbExecutor, e *manualEntry) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO threat_phones (phone_national, country_code, threat_type, severity, confidence, source, description, first_seen, last_seen, flags)
+		VALUES ($1, $2, $3::threat_type_enum, $4::severity_enum, 80, 'manual'::source_enum, $5, $6, $7, `+flagActive.sql()+`)
+		ON CONFLICT (phone_national) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			report_count = threat_phones.report_count + 1
+	`, e.Value, e.CountryCode, e.ThreatType, e.Severity, e.Description, now, now)
+	return err
+}
 
-	defer func() {
-		duration := time.Since(startTime)
-		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v", duration.Round(time.Second)))
-	}()
+// insertManualEmail guarda la dirección literal; email_normalized y email_normalized_hash los
+// calcula el trigger trg_threat_emails_normalize con las reglas de email_normalization_rules
+func insertManualEmail(ctx context.Context, db dbExecutor, e *manualEntry) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO threat_emails (email_hash, email, domain_hash, threat_type, severity, confidence, source, impersonates, first_seen, last_seen, flags)
+		VALUES (sha256_bytea($1), $1, sha256_bytea($2), $3::threat_type_enum, $4::severity_enum, 80, 'manual'::source_enum, $5, $6, $7, `+flagActive.sql()+`)
+		ON CONFLICT (email_hash) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			report_count = threat_emails.report_count + 1
+	`, e.Value, e.Domain, e.ThreatType, e.Severity, e.Impersonates, now, now)
+	return err
+}
 
-	req, err := http.NewRequestWithContext(ctx, "GET", openPhishURL, nil)
-	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to create request: "+err.Error())
-		return
-	}
-	req.Header.Set("User-Agent", "Fy-Admin/1.0")
+func insertManualDomain(ctx context.Context, db dbExecutor, e *manualEntry) error {
+	now := time.Now()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, tld, first_seen, last_seen, flags)
+		VALUES (sha256_bytea($1), $1, $2::threat_type_enum, $3::severity_enum, 80, 'manual'::source_enum, $4, $5, $6, `+flagActive.sql()+`)
+		ON CONFLICT (domain_hash) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			report_count = threat_domains.report_count + 1
+	`, e.Value, e.ThreatType, e.Severity, extractTLD(e.Value), now, now)
+	return err
+}
 
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+func (s *Server) checkService(url string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+	resp, err := s.client.Do(req)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to download: "+err.Error())
-		return
+		return "offline"
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		s.updateSyncStatusComplete(source, 0, 1, fmt.Sprintf("Download failed with status: %d", resp.StatusCode))
-		return
+	if resp.StatusCode == http.StatusOK {
+		return "online"
 	}
+	return "error"
+}
 
-	s.updateSyncStatus(source, true, "Parsing and importing...")
-
-	scanner := bufio.NewScanner(resp.Body)
-	lineNum := 0
-	now := time.Now()
-
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		lineNum++
-
-		parsedURL, err := url.Parse(line)
-		if err != nil {
-			errors++
-			continue
-		}
+func getEnv(key, defaultValue string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return defaultValue
+}
 
-		domain := strings.ToLower(parsedURL.Hostname())
-		if domain == "" || len(domain) < 3 || !strings.Contains(domain, ".") {
-			errors++
-			continue
+func getEnvInt(key string, defaultValue int) int {
+	if v := os.Getenv(key); v != "" {
+		if i, err := strconv.Atoi(v); err == nil && i > 0 {
+			return i
 		}
+	}
+	return defaultValue
+}
 
-		// Saltar IPs
-		if net.ParseIP(domain) != nil {
-			continue
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
 		}
+	}
+	return defaultValue
+}
 
-		tld := extractTLD(domain)
-		sourceID := fmt.Sprintf("openphish-%d", lineNum)
+// readCtx acota una consulta de lectura a queryTimeout
+func (s *Server) readCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.queryTimeout)
+}
 
-		_, err = s.db.ExecContext(ctx, `
-			INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, source_id, tld, first_seen, last_seen, flags)
-			VALUES (sha256_bytea($1), $1, 'phishing'::threat_type_enum, 'high'::severity_enum, 90, 'phishtank'::source_enum, $2, $3, $4, $5, 1)
-			ON CONFLICT (domain_hash) DO UPDATE SET
-				last_seen = EXCLUDED.last_seen,
-				hit_count = threat_domains.hit_count + 1,
-				confidence = GREATEST(threat_domains.confidence, EXCLUDED.confidence)
-		`, domain, sourceID, tld, now, now)
+// writeCtx acota una escritura a writeTimeout
+func (s *Server) writeCtx(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, s.writeTimeout)
+}
 
-		if err != nil {
-			errors++
-			continue
-		}
-		records++
+// exec ejecuta una escritura con su propio timeout dentro del contexto de la sincronización
+func (s *Server) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := s.writeCtx(ctx)
+	defer cancel()
+	return s.db.ExecContext(ctx, query, args...)
+}
 
-		// Insertar path si existe
-		path := parsedURL.Path
-		if path != "" && path != "/" {
-			fullPath := domain + path
-			s.db.ExecContext(ctx, `
-				INSERT INTO threat_paths (path_hash, domain_hash, path, threat_type, severity, confidence, source, first_seen, last_seen, flags)
-				VALUES (sha256_bytea($1), sha256_bytea($2), $3, 'phishing'::threat_type_enum, 'high'::severity_enum, 90, 'phishtank'::source_enum, $4, $5, 1)
-				ON CONFLICT (path_hash) DO UPDATE SET last_seen = EXCLUDED.last_seen
-			`, fullPath, domain, path, now, now)
-		}
+func getQueryInt(r *http.Request, key string, defaultValue int) int {
+	val := r.URL.Query().Get(key)
+	if val == "" {
+		return defaultValue
 	}
-
-	// Actualizar sync_status en BD (usa 'phishtank' como en el enum)
-	s.db.ExecContext(ctx, `
-		INSERT INTO sync_status (source, last_sync, last_count)
-		VALUES ('phishtank'::source_enum, NOW(), $1)
-		ON CONFLICT (source) DO UPDATE SET
-			last_sync = NOW(),
-			last_count = $1
-	`, records)
+	i, err := strconv.Atoi(val)
+	if err != nil {
+		return defaultValue
+	}
+	return i
 }
 
+// ============================================================================
+// SYNC FUNCTIONS - Importación directa de fuentes de amenazas
+// ============================================================================
+
+const (
+	urlhausDownloadURL      = "https://urlhaus.abuse.ch/downloads/csv/"
+	openPhishURL            = "https://openphish.com/feed.txt"
+	stopForumSpamEmailsURL  = "https://www.stopforumspam.com/downloads/listed_email_365_all.gz"
+	stopForumSpamPhonesURL  = "https://www.stopforumspam.com/downloads/listed_phone_365_all.gz"
+	listaHuPhonesURL        = "https://listahu.org/descargar/csv"
+)
+
 // cleanEmail limpia un email de caracteres no deseados
 func cleanEmail(email string) string {
 	// Quitar comillas simples y dobles
@@ -1204,7 +1469,7 @@ func cleanEmail(email string) string {
 }
 
 // syncStopForumSpam descarga e importa emails de spam
-func (s *Server) syncStopForumSpam(ctx context.Context) {
+func (s *Server) syncStopForumSpam(ctx context.Context) (err error) {
 	source := "emails"
 	s.updateSyncStatus(source, true, "Downloading StopForumSpam emails...")
 
@@ -1212,147 +1477,59 @@ func (s *Server) syncStopForumSpam(ctx context.Context) {
 	var records, errors int64
 
 	defer func() {
+		if err != nil {
+			s.updateSyncStatusComplete(source, records, errors+1, err.Error())
+			return
+		}
 		duration := time.Since(startTime)
+		logSyncCompleted(source, records, errors, duration)
 		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v", duration.Round(time.Second)))
 	}()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", stopForumSpamEmailsURL, nil)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to create request: "+err.Error())
-		return
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Fy-Admin/1.0")
 
 	client := &http.Client{Timeout: 180 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to download: "+err.Error())
-		return
+		return fmt.Errorf("failed to download: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		s.updateSyncStatusComplete(source, 0, 1, fmt.Sprintf("Download failed with status: %d", resp.StatusCode))
-		return
+		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
 	}
 
 	// Descomprimir gzip
 	gzReader, err := gzip.NewReader(resp.Body)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to decompress: "+err.Error())
-		return
+		return fmt.Errorf("failed to decompress: %w", err)
 	}
 	defer gzReader.Close()
 
 	s.updateSyncStatus(source, true, "Parsing and importing emails...")
 
-	scanner := bufio.NewScanner(gzReader)
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-
-	lineNum := 0
-	now := time.Now()
-
-	for scanner.Scan() {
-		select {
-		case <-ctx.Done():
-			return
-		default:
-		}
-
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" {
-			continue
-		}
-
-		// Formato: email,count,lastseen
-		parts := strings.Split(line, ",")
-		if len(parts) < 1 {
-			continue
-		}
-
-		email := strings.ToLower(strings.TrimSpace(parts[0]))
-
-		// Limpiar el email de caracteres no deseados
-		email = cleanEmail(email)
-
-		if email == "" || !strings.Contains(email, "@") {
-			continue
-		}
-
-		// Validar formato básico de email
-		if len(email) < 5 || len(email) > 254 {
-			continue
-		}
-
-		// Verificar que no contenga datos corruptos
-		if strings.Contains(email, "%") || strings.Contains(email, "spinfile") ||
-			strings.Contains(email, " ") || strings.Contains(email, "\t") {
-			continue
-		}
-
-		lineNum++
-
-		// Extraer dominio del email
-		atIndex := strings.LastIndex(email, "@")
-		if atIndex < 1 {
-			errors++
-			continue
-		}
-		domain := email[atIndex+1:]
-
-		// Validar que el dominio tenga al menos un punto
-		if !strings.Contains(domain, ".") {
-			errors++
-			continue
-		}
-
-		// Calcular confianza basado en el count si está disponible
-		confidence := int16(70)
-		if len(parts) >= 2 && parts[1] != "" {
-			var count int
-			fmt.Sscanf(parts[1], "%d", &count)
-			if count > 100 {
-				confidence = 95
-			} else if count > 50 {
-				confidence = 90
-			} else if count > 10 {
-				confidence = 80
-			}
-		}
-
-		_, err := s.db.ExecContext(ctx, `
-			INSERT INTO threat_emails (email_hash, email, domain_hash, threat_type, severity, confidence, source, first_seen, last_seen, flags)
-			VALUES (sha256_bytea($1), $1, sha256_bytea($2), 'spam'::threat_type_enum, 'medium'::severity_enum, $3, 'osint'::source_enum, $4, $5, 1)
-			ON CONFLICT (email_hash) DO UPDATE SET
-				last_seen = EXCLUDED.last_seen,
-				report_count = threat_emails.report_count + 1,
-				confidence = GREATEST(threat_emails.confidence, EXCLUDED.confidence)
-		`, email, domain, confidence, now, now)
-
-		if err != nil {
-			errors++
-			continue
-		}
-		records++
-
-		if lineNum%10000 == 0 {
-			s.updateSyncStatus(source, true, fmt.Sprintf("Imported %d emails...", records))
-		}
+	records, errors, err = s.importSpamEmails(ctx, source, gzReader, time.Now())
+	if err != nil {
+		return err
 	}
 
 	// Actualizar sync_status en BD (usa 'osint' para StopForumSpam)
-	s.db.ExecContext(ctx, `
+	s.exec(ctx, `
 		INSERT INTO sync_status (source, last_sync, last_count)
 		VALUES ('osint'::source_enum, NOW(), $1)
 		ON CONFLICT (source) DO UPDATE SET
 			last_sync = NOW(),
 			last_count = $1
 	`, records)
+	return nil
 }
 
 // syncPhones descarga e importa números de teléfono de estafa desde Lista Hũ
-func (s *Server) syncPhones(ctx context.Context) {
+func (s *Server) syncPhones(ctx context.Context) (err error) {
 	source := "phones"
 	s.updateSyncStatus(source, true, "Downloading Lista Hũ phone database...")
 
@@ -1360,28 +1537,30 @@ func (s *Server) syncPhones(ctx context.Context) {
 	var records, errors int64
 
 	defer func() {
+		if err != nil {
+			s.updateSyncStatusComplete(source, records, errors+1, err.Error())
+			return
+		}
 		duration := time.Since(startTime)
+		logSyncCompleted(source, records, errors, duration)
 		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v", duration.Round(time.Second)))
 	}()
 
 	req, err := http.NewRequestWithContext(ctx, "GET", listaHuPhonesURL, nil)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to create request: "+err.Error())
-		return
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("User-Agent", "Fy-Admin/1.0")
 
 	client := &http.Client{Timeout: 120 * time.Second}
 	resp, err := client.Do(req)
 	if err != nil {
-		s.updateSyncStatusComplete(source, 0, 1, "Failed to download: "+err.Error())
-		return
+		return fmt.Errorf("failed to download: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		s.updateSyncStatusComplete(source, 0, 1, fmt.Sprintf("Download failed with status: %d", resp.StatusCode))
-		return
+		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
 	}
 
 	s.updateSyncStatus(source, true, "Parsing and importing phones...")
@@ -1401,7 +1580,7 @@ func (s *Server) syncPhones(ctx context.Context) {
 	for scanner.Scan() {
 		select {
 		case <-ctx.Done():
-			return
+			return ctx.Err()
 		default:
 		}
 
@@ -1411,6 +1590,9 @@ func (s *Server) syncPhones(ctx context.Context) {
 		}
 
 		lineNum++
+		if lineNum%syncProgressLogInterval == 0 {
+			logSyncProgress(source, lineNum, records, errors)
+		}
 
 		// Formato CSV: "#","Numero","Tipo","Comentarios","Captura","Fecha_Denuncia"
 		// Parsear CSV manualmente (campos entre comillas)
@@ -1430,32 +1612,7 @@ func (s *Server) syncPhones(ctx context.Context) {
 		}
 
 		// Determinar código de país y número nacional
-		countryCode := "XX"
-		phoneNational := phone
-
-		// Detectar prefijos conocidos
-		if strings.HasPrefix(phone, "34") && len(phone) >= 11 {
-			countryCode = "ES"
-			phoneNational = phone[2:] // Remover 34
-		} else if strings.HasPrefix(phone, "595") && len(phone) >= 12 {
-			countryCode = "PY" // Paraguay
-			phoneNational = phone[3:]
-		} else if strings.HasPrefix(phone, "54") && len(phone) >= 11 {
-			countryCode = "AR" // Argentina
-			phoneNational = phone[2:]
-		} else if strings.HasPrefix(phone, "52") && len(phone) >= 11 {
-			countryCode = "MX" // México
-			phoneNational = phone[2:]
-		} else if strings.HasPrefix(phone, "57") && len(phone) >= 11 {
-			countryCode = "CO" // Colombia
-			phoneNational = phone[2:]
-		} else if strings.HasPrefix(phone, "56") && len(phone) >= 11 {
-			countryCode = "CL" // Chile
-			phoneNational = phone[2:]
-		} else if strings.HasPrefix(phone, "51") && len(phone) >= 11 {
-			countryCode = "PE" // Perú
-			phoneNational = phone[2:]
-		}
+		countryCode, phoneNational := splitPhoneCountry(phone)
 
 		// Mapear tipo de amenaza
 		threatType := "scam"
@@ -1481,9 +1638,9 @@ func (s *Server) syncPhones(ctx context.Context) {
 			description = strings.TrimSpace(parts[3])
 		}
 
-		_, err := s.db.ExecContext(ctx, `
+		_, err := s.exec(ctx, `
 			INSERT INTO threat_phones (phone_national, country_code, threat_type, severity, confidence, source, description, first_seen, last_seen, flags)
-			VALUES ($1, $2, $3::threat_type_enum, $4::severity_enum, 75, 'osint'::source_enum, $5, $6, $7, 1)
+			VALUES ($1, $2, $3::threat_type_enum, $4::severity_enum, 75, 'osint'::source_enum, $5, $6, $7, `+flagActive.sql()+`)
 			ON CONFLICT (phone_national) DO UPDATE SET
 				last_seen = EXCLUDED.last_seen,
 				report_count = threat_phones.report_count + 1,
@@ -1500,9 +1657,179 @@ func (s *Server) syncPhones(ctx context.Context) {
 			s.updateSyncStatus(source, true, fmt.Sprintf("Imported %d phones...", records))
 		}
 	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read feed: %w", err)
+	}
+
+	// Fila propia en sync_status: 'osint' ya la usa la lista de emails (migración 027)
+	s.exec(ctx, `
+		INSERT INTO sync_status (source, last_sync, last_count)
+		VALUES ('listahu_phones'::source_enum, NOW(), $1)
+		ON CONFLICT (source) DO UPDATE SET
+			last_sync = NOW(),
+			last_count = $1
+	`, records)
+	return nil
+}
 
-	// No hay sync_status específico para phones, pero podemos usar 'manual' o no actualizar
-	fmt.Printf("[Phones] Import completed: %d records, %d errors\n", records, errors)
+// splitPhoneCountry separa un número internacional sin '+' en código de país y número
+// nacional para los prefijos conocidos. Si no reconoce el prefijo devuelve "XX" y el número entero.
+func splitPhoneCountry(phone string) (countryCode, phoneNational string) {
+	switch {
+	case strings.HasPrefix(phone, "34") && len(phone) >= 11:
+		return "ES", phone[2:]
+	case strings.HasPrefix(phone, "595") && len(phone) >= 12:
+		return "PY", phone[3:] // Paraguay
+	case strings.HasPrefix(phone, "54") && len(phone) >= 11:
+		return "AR", phone[2:] // Argentina
+	case strings.HasPrefix(phone, "52") && len(phone) >= 11:
+		return "MX", phone[2:] // México
+	case strings.HasPrefix(phone, "57") && len(phone) >= 11:
+		return "CO", phone[2:] // Colombia
+	case strings.HasPrefix(phone, "56") && len(phone) >= 11:
+		return "CL", phone[2:] // Chile
+	case strings.HasPrefix(phone, "51") && len(phone) >= 11:
+		return "PE", phone[2:] // Perú
+	}
+	return "XX", phone
+}
+
+// syncStopForumSpamPhones descarga e importa los teléfonos de spam de StopForumSpam
+// (mismo formato que los emails: phone,count,lastseen, en formato internacional)
+func (s *Server) syncStopForumSpamPhones(ctx context.Context) (err error) {
+	source := "phones_sfs"
+	s.updateSyncStatus(source, true, "Downloading StopForumSpam phones...")
+
+	startTime := time.Now()
+	var records, errors int64
+
+	defer func() {
+		if err != nil {
+			s.updateSyncStatusComplete(source, records, errors+1, err.Error())
+			return
+		}
+		duration := time.Since(startTime)
+		logSyncCompleted(source, records, errors, duration)
+		s.updateSyncStatusComplete(source, records, errors, fmt.Sprintf("Completed in %v", duration.Round(time.Second)))
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", stopForumSpamPhonesURL, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Fy-Admin/1.0")
+
+	client := &http.Client{Timeout: 180 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	}
+
+	gzReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to decompress: %w", err)
+	}
+	defer gzReader.Close()
+
+	s.updateSyncStatus(source, true, "Parsing and importing phones...")
+
+	csvReader := csv.NewReader(skipUTF8BOM(gzReader))
+	csvReader.FieldsPerRecord = -1
+	csvReader.LazyQuotes = true
+	csvReader.ReuseRecord = true
+
+	lineNum := 0
+	now := time.Now()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		parts, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				errors++
+				continue
+			}
+			return fmt.Errorf("failed to read feed: %w", err)
+		}
+		if len(parts) == 0 {
+			continue
+		}
+
+		// +34 600 00 00 00, +1-202-555-0123... → solo dígitos (E.164 admite hasta 15)
+		var digits strings.Builder
+		for _, r := range parts[0] {
+			if r >= '0' && r <= '9' {
+				digits.WriteRune(r)
+			}
+		}
+		phone := strings.TrimLeft(digits.String(), "0")
+		if len(phone) < 8 || len(phone) > 15 {
+			errors++
+			continue
+		}
+
+		lineNum++
+		if lineNum%syncProgressLogInterval == 0 {
+			logSyncProgress(source, lineNum, records, errors)
+		}
+
+		countryCode, phoneNational := splitPhoneCountry(phone)
+
+		// Misma escala de confianza por número de reportes que los emails
+		confidence := int16(70)
+		if len(parts) >= 2 && parts[1] != "" {
+			var count int
+			fmt.Sscanf(strings.TrimSpace(parts[1]), "%d", &count)
+			if count > 100 {
+				confidence = 95
+			} else if count > 50 {
+				confidence = 90
+			} else if count > 10 {
+				confidence = 80
+			}
+		}
+
+		_, err = s.exec(ctx, `
+			INSERT INTO threat_phones (phone_national, country_code, threat_type, severity, confidence, source, description, first_seen, last_seen, flags)
+			VALUES ($1, $2, 'spam'::threat_type_enum, 'low'::severity_enum, $3, 'osint'::source_enum, 'StopForumSpam', $4, $4, `+flagActive.sql()+`)
+			ON CONFLICT (phone_national) DO UPDATE SET
+				last_seen = EXCLUDED.last_seen,
+				report_count = threat_phones.report_count + 1,
+				confidence = GREATEST(threat_phones.confidence, EXCLUDED.confidence)
+		`, phoneNational, countryCode, confidence, now)
+		if err != nil {
+			errors++
+			continue
+		}
+		records++
+
+		if lineNum%1000 == 0 {
+			s.updateSyncStatus(source, true, fmt.Sprintf("Imported %d phones...", records))
+		}
+	}
+
+	// Fila propia en sync_status: 'osint' ya la usa la lista de emails (migración 021)
+	s.exec(ctx, `
+		INSERT INTO sync_status (source, last_sync, last_count)
+		VALUES ('sfs_phones'::source_enum, NOW(), $1)
+		ON CONFLICT (source) DO UPDATE SET
+			last_sync = NOW(),
+			last_count = $1
+	`, records)
+	return nil
 }
 
 // parseCSVLine parsea una línea CSV con campos entre comillas
@@ -1526,6 +1853,35 @@ func parseCSVLine(line string) []string {
 	return result
 }
 
+// syncProgressLogInterval cada cuántas líneas del feed se registra el progreso
+const syncProgressLogInterval = 10000
+
+func logSyncProgress(source string, lines int, records, errors int64) {
+	log.Info().
+		Str("source", source).
+		Int("lines", lines).
+		Int64("records", records).
+		Int64("errors", errors).
+		Msg("[Sync] Progress")
+}
+
+func logFeedDeduplicated(source string, lines, unique int) {
+	log.Info().
+		Str("source", source).
+		Int("lines", lines).
+		Int("unique_domains", unique).
+		Msg("[Sync] Feed deduplicated")
+}
+
+func logSyncCompleted(source string, records, errors int64, duration time.Duration) {
+	log.Info().
+		Str("source", source).
+		Int64("records", records).
+		Int64("errors", errors).
+		Dur("duration", duration).
+		Msg("[Sync] Completed")
+}
+
 // updateSyncStatus actualiza el estado de sincronización
 func (s *Server) updateSyncStatus(source string, inProgress bool, message string) {
 	s.syncMutex.Lock()
@@ -1540,6 +1896,18 @@ func (s *Server) updateSyncStatus(source string, inProgress bool, message string
 	}
 }
 
+// updateSyncCounters actualiza contadores de una sincronización en curso
+func (s *Server) updateSyncCounters(source string, records, errors int64, message string) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	if status, ok := s.syncStatus[source]; ok {
+		status.Records = records
+		status.Errors = status.priorErrors + errors
+		status.Message = message
+	}
+}
+
 // updateSyncStatusComplete marca una sincronización como completada
 func (s *Server) updateSyncStatusComplete(source string, records, errors int64, message string) {
 	s.syncMutex.Lock()
@@ -1548,7 +1916,7 @@ func (s *Server) updateSyncStatusComplete(source string, records, errors int64,
 	if status, ok := s.syncStatus[source]; ok {
 		status.InProgress = false
 		status.Records = records
-		status.Errors = errors
+		status.Errors = status.priorErrors + errors
 		status.Message = message
 	}
 }
@@ -1557,11 +1925,14 @@ func (s *Server) updateSyncStatusComplete(source string, records, errors int64,
 func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	limit := getQueryInt(r, "limit", 50)
 	offset := getQueryInt(r, "offset", 0)
 	search := r.URL.Query().Get("search")
@@ -1572,7 +1943,7 @@ func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
 		       ru.total_reports, ru.unique_reporters, ru.status::text,
 		       ru.first_reported_at, ru.last_reported_at, ru.promoted_to_threats
 		FROM reported_urls ru
-		WHERE (ru.flags & 1) = 1
+		WHERE ` + flagActive.isSet("ru.flags") + `
 	`
 	args := []interface{}{}
 	argCount := 0
@@ -1591,43 +1962,21 @@ func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
 	query += " ORDER BY ru.last_reported_at DESC"
 	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
 
-	rows, err := s.db.Query(query, args...)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 		return
 	}
 	defer rows.Close()
 
-	reports := []map[string]interface{}{}
-	for rows.Next() {
-		var urlStr, domain, status string
-		var threatType sql.NullString
-		var score, totalReports, uniqueReporters int
-		var firstReported, lastReported time.Time
-		var promoted bool
-
-		if rows.Scan(&urlStr, &domain, &threatType, &score, &totalReports, &uniqueReporters,
-			&status, &firstReported, &lastReported, &promoted) == nil {
-			item := map[string]interface{}{
-				"url":              urlStr,
-				"domain":           domain,
-				"aggregated_score": score,
-				"total_reports":    totalReports,
-				"unique_reporters": uniqueReporters,
-				"status":           status,
-				"first_reported":   firstReported.Format(time.RFC3339),
-				"last_reported":    lastReported.Format(time.RFC3339),
-				"promoted":         promoted,
-			}
-			if threatType.Valid {
-				item["threat_type"] = threatType.String
-			}
-			reports = append(reports, item)
-		}
+	reports, scanErrors, err := scanRows(rows, "reported_urls", scanReportRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
 	}
 
 	// Get total count with same filters
-	countQuery := `SELECT COUNT(*) FROM reported_urls WHERE (flags & 1) = 1`
+	countQuery := `SELECT COUNT(*) FROM reported_urls WHERE ` + flagActive.isSet("flags")
 	countArgs := []interface{}{}
 	argCount = 0
 	if search != "" {
@@ -1642,30 +1991,28 @@ func (s *Server) handleListReports(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var total int64
-	s.db.QueryRow(countQuery, countArgs...).Scan(&total)
+	s.db.QueryRowContext(ctx, countQuery, countArgs...).Scan(&total)
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"data":   reports,
-		"total":  total,
-		"limit":  limit,
-		"offset": offset,
-	})
+	json.NewEncoder(w).Encode(listResponse(w, r, reports, scanErrors, total, limit, offset))
 }
 
 // handleReportsStats devuelve estadísticas de los reportes
 func (s *Server) handleReportsStats(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 
-	if s.db == nil {
+	if !s.dbReady() {
 		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
 		return
 	}
 
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
 	stats := make(map[string]interface{})
 
 	// Estadísticas generales de reported_urls
 	var total, pending, confirmed, rejected, highScore, promoted int64
-	s.db.QueryRow(`
+	s.db.QueryRowContext(ctx, `
 		SELECT
 			COUNT(*),
 			COUNT(*) FILTER (WHERE status = 'pending'),
@@ -1673,7 +2020,7 @@ func (s *Server) handleReportsStats(w http.ResponseWriter, r *http.Request) {
 			COUNT(*) FILTER (WHERE status = 'rejected'),
 			COUNT(*) FILTER (WHERE aggregated_score >= 70),
 			COUNT(*) FILTER (WHERE promoted_to_threats = true)
-		FROM reported_urls WHERE (flags & 1) = 1
+		FROM reported_urls WHERE `+flagActive.isSet("flags")+`
 	`).Scan(&total, &pending, &confirmed, &rejected, &highScore, &promoted)
 
 	stats["total_reported_urls"] = total
@@ -1684,16 +2031,16 @@ func (s *Server) handleReportsStats(w http.ResponseWriter, r *http.Request) {
 	stats["promoted"] = promoted
 
 	// Total de reportes individuales
-	var totalIndividual int64
-	s.db.QueryRow(`SELECT COUNT(*) FROM user_url_reports`).Scan(&totalIndividual)
+	totalIndividual, estimated := s.tableCount(ctx, "user_url_reports", wantExactCount(r))
 	stats["total_individual_reports"] = totalIndividual
+	stats["total_individual_reports_estimated"] = estimated
 
 	// Reportes por tipo de amenaza
 	byThreat := []map[string]interface{}{}
-	rows, err := s.db.Query(`
+	rows, err := s.db.QueryContext(ctx, `
 		SELECT primary_threat_type::text, COUNT(*) as count
 		FROM reported_urls
-		WHERE (flags & 1) = 1 AND primary_threat_type IS NOT NULL
+		WHERE `+flagActive.isSet("flags")+` AND primary_threat_type IS NOT NULL
 		GROUP BY primary_threat_type
 		ORDER BY count DESC
 	`)
@@ -1714,7 +2061,7 @@ func (s *Server) handleReportsStats(w http.ResponseWriter, r *http.Request) {
 
 	// Top reportadores (usuarios con más reportes)
 	topReporters := []map[string]interface{}{}
-	rows2, err := s.db.Query(`
+	rows2, err := s.db.QueryContext(ctx, `
 		SELECT user_id, trust_score, total_reports, confirmed_reports
 		FROM user_trust_scores
 		WHERE total_reports > 0
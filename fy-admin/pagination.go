@@ -0,0 +1,95 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ListPage respuesta de los listados paginados. ScanErrors cuenta las filas que no se
+// pudieron leer (se omiten de Data); con ScanErrors > 0 la página está incompleta.
+type ListPage[T any] struct {
+	Data           []T   `json:"data"`
+	Total          int64 `json:"total"`
+	Limit          int   `json:"limit"`
+	Offset         int   `json:"offset"`
+	HasMore        bool  `json:"has_more"`
+	NextOffset     *int  `json:"next_offset"`
+	PrevOffset     *int  `json:"prev_offset"`
+	ScanErrors     int   `json:"scan_errors"`
+	TotalEstimated *bool `json:"total_estimated,omitempty"`
+}
+
+// listResponse página con la paginación ya calculada (next_offset/prev_offset a null en los
+// extremos) y la cabecera Link estilo RFC 5988 con rel first/prev/next/last. Debe llamarse
+// antes de escribir el body.
+func listResponse[T any](w http.ResponseWriter, r *http.Request, data []T, scanErrors int, total int64, limit, offset int) ListPage[T] {
+	if offset < 0 {
+		offset = 0
+	}
+	if data == nil {
+		data = []T{}
+	}
+
+	page := ListPage[T]{Data: data, Total: total, Limit: limit, Offset: offset, ScanErrors: scanErrors}
+	page.HasMore = limit > 0 && int64(offset+limit) < total
+	if page.HasMore {
+		next := offset + limit
+		page.NextOffset = &next
+	}
+	if offset > 0 && limit > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		page.PrevOffset = &prev
+	}
+
+	if limit > 0 {
+		var links []string
+		links = append(links, pageLink(r, limit, 0, "first"))
+		if page.PrevOffset != nil {
+			links = append(links, pageLink(r, limit, *page.PrevOffset, "prev"))
+		}
+		if page.NextOffset != nil {
+			links = append(links, pageLink(r, limit, *page.NextOffset, "next"))
+		}
+		if total > 0 {
+			links = append(links, pageLink(r, limit, int((total-1)/int64(limit))*limit, "last"))
+		}
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return page
+}
+
+// scanRows lee todas las filas con scan. Una fila que no se puede leer no aborta el
+// listado: se registra y se cuenta en scanErrors. err es el de rows.Err(): si no es nil
+// la iteración se cortó a mitad y el resultado está truncado.
+func scanRows[T any](rows *sql.Rows, table string, scan func(*sql.Rows) (T, error)) (items []T, scanErrors int, err error) {
+	items = []T{}
+	for rows.Next() {
+		item, err := scan(rows)
+		if err != nil {
+			scanErrors++
+			log.Warn().Err(err).Str("table", table).Msg("[List] Failed to scan row")
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, scanErrors, rows.Err()
+}
+
+// pageLink enlace relativo a la misma ruta conservando los filtros de la query
+func pageLink(r *http.Request, limit, offset int, rel string) string {
+	query := r.URL.Query()
+	query.Set("limit", strconv.Itoa(limit))
+	query.Set("offset", strconv.Itoa(offset))
+	u := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return fmt.Sprintf(`<%s>; rel="%s"`, u.String(), rel)
+}
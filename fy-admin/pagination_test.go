@@ -0,0 +1,115 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestListResponse(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	tests := []struct {
+		name     string
+		total    int64
+		limit    int
+		offset   int
+		hasMore  bool
+		next     *int
+		prev     *int
+		wantLink []string
+	}{
+		{
+			name: "first page", total: 1200, limit: 500, offset: 0,
+			hasMore: true, next: intPtr(500),
+			wantLink: []string{"offset=0&q=bank>; rel=\"first\"", "offset=500&q=bank>; rel=\"next\"", "offset=1000&q=bank>; rel=\"last\""},
+		},
+		{
+			name: "middle page", total: 1200, limit: 500, offset: 500,
+			hasMore: true, next: intPtr(1000), prev: intPtr(0),
+			wantLink: []string{"rel=\"first\"", "offset=0&q=bank>; rel=\"prev\"", "offset=1000&q=bank>; rel=\"next\"", "rel=\"last\""},
+		},
+		{
+			name: "last page", total: 1200, limit: 500, offset: 1000,
+			prev:     intPtr(500),
+			wantLink: []string{"rel=\"first\"", "offset=500&q=bank>; rel=\"prev\"", "offset=1000&q=bank>; rel=\"last\""},
+		},
+		{
+			name: "unaligned offset", total: 1200, limit: 500, offset: 200,
+			hasMore: true, next: intPtr(700), prev: intPtr(0),
+		},
+		{
+			name: "exact multiple", total: 1000, limit: 500, offset: 500,
+			prev:     intPtr(0),
+			wantLink: []string{"offset=500&q=bank>; rel=\"last\""},
+		},
+		{
+			name: "empty", total: 0, limit: 500, offset: 0,
+			wantLink: []string{"rel=\"first\""},
+		},
+		{name: "negative offset", total: 10, limit: 5, offset: -3, hasMore: true, next: intPtr(5)},
+		{name: "no limit", total: 10, limit: 0, offset: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/data/domains?q=bank&limit=500&offset=7", nil)
+			rec := httptest.NewRecorder()
+			page := listResponse(rec, r, []string{"a"}, 0, tt.total, tt.limit, tt.offset)
+
+			if page.HasMore != tt.hasMore {
+				t.Errorf("HasMore = %v, want %v", page.HasMore, tt.hasMore)
+			}
+			if !equalIntPtr(page.NextOffset, tt.next) || !equalIntPtr(page.PrevOffset, tt.prev) {
+				t.Errorf("next/prev = %v/%v, want %v/%v", fmtIntPtr(page.NextOffset), fmtIntPtr(page.PrevOffset), fmtIntPtr(tt.next), fmtIntPtr(tt.prev))
+			}
+			if page.Offset < 0 {
+				t.Errorf("Offset = %d", page.Offset)
+			}
+
+			link := rec.Header().Get("Link")
+			if tt.limit == 0 && link != "" {
+				t.Errorf("Link without limit = %q", link)
+			}
+			for _, want := range tt.wantLink {
+				if !strings.Contains(link, want) {
+					t.Errorf("Link = %q, missing %q", link, want)
+				}
+			}
+			if strings.Contains(link, "rel=\"next\"") != (tt.next != nil) || strings.Contains(link, "rel=\"prev\"") != (tt.prev != nil) {
+				t.Errorf("Link = %q does not match next/prev offsets", link)
+			}
+		})
+	}
+}
+
+func TestListResponseJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/data/domains", nil)
+	page := listResponse[string](httptest.NewRecorder(), r, nil, 2, 3, 50, 0)
+
+	data, err := json.Marshal(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sin datos: array vacío y offsets a null en los extremos
+	for _, want := range []string{`"data":[]`, `"next_offset":null`, `"prev_offset":null`, `"has_more":false`, `"scan_errors":2`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("JSON %s missing %s", data, want)
+		}
+	}
+	if strings.Contains(string(data), "total_estimated") {
+		t.Errorf("JSON %s includes total_estimated without an estimate", data)
+	}
+}
+
+func equalIntPtr(a, b *int) bool {
+	return (a == nil) == (b == nil) && (a == nil || *a == *b)
+}
+
+func fmtIntPtr(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
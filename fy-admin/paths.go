@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Listado y búsqueda de threat_paths. La búsqueda por path (ILIKE) usa el índice de
+// trigramas idx_paths_path_trgm (migración 024).
+
+// domainListPathLimit paths por dominio que se añaden al listado de dominios con include_paths
+const domainListPathLimit = 10
+
+// pathHashRegex path_hash en hexadecimal (sha256)
+var pathHashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+var errPathNotFound = errors.New("Path not found")
+
+// pathColumns columnas de scanPathRow (p = threat_paths, d = threat_domains)
+const pathColumns = `encode(p.path_hash, 'hex'), d.domain, p.path, p.threat_type::text, p.severity::text,
+		       p.confidence, p.payload_type, p.target_brand, p.source::text, p.first_seen, p.last_seen,
+		       COALESCE(p.flags, 0)`
+
+// handleListPaths paths activos con filtros y paginación
+// GET /api/data/paths?search=&domain=&threat_type=&limit=&offset=
+func (s *Server) handleListPaths(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	limit := getQueryInt(r, "limit", 50)
+	offset := getQueryInt(r, "offset", 0)
+	search := r.URL.Query().Get("search")
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+	threatType := r.URL.Query().Get("threat_type")
+
+	where := " WHERE " + flagActive.isSet("p.flags")
+	args := []interface{}{}
+	argCount := 0
+
+	if search != "" {
+		argCount++
+		where += fmt.Sprintf(" AND p.path ILIKE $%d", argCount)
+		args = append(args, "%"+search+"%")
+	}
+	if domain != "" {
+		argCount++
+		where += fmt.Sprintf(" AND p.domain_hash = sha256_bytea($%d)", argCount)
+		args = append(args, domain)
+	}
+	if threatType != "" {
+		argCount++
+		where += fmt.Sprintf(" AND p.threat_type::text = $%d", argCount)
+		args = append(args, threatType)
+	}
+
+	query := `
+		SELECT ` + pathColumns + `
+		FROM threat_paths p
+		JOIN threat_domains d ON d.domain_hash = p.domain_hash
+	` + where
+	query += " ORDER BY p.last_seen DESC"
+	query += fmt.Sprintf(" LIMIT %d OFFSET %d", limit, offset)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	paths, scanErrors, err := scanRows(rows, "threat_paths", scanPathRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	// El total respeta los filtros: con búsqueda el listado completo no sirve para paginar
+	var total int64
+	s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM threat_paths p`+where, args...).Scan(&total)
+
+	json.NewEncoder(w).Encode(listResponse(w, r, paths, scanErrors, total, limit, offset))
+}
+
+// listedDomainPaths paths activos de cada dominio (los domainListPathLimit más recientes). Con
+// search solo los que coinciden con la búsqueda.
+func (s *Server) listedDomainPaths(ctx context.Context, domains []string, search string) (map[string][]PathRow, error) {
+	result := map[string][]PathRow{}
+	if len(domains) == 0 {
+		return result, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT `+pathColumns+`
+		FROM (
+			SELECT p.*, ROW_NUMBER() OVER (PARTITION BY p.domain_hash ORDER BY p.last_seen DESC) AS rn
+			FROM threat_paths p
+			WHERE p.domain_hash IN (SELECT sha256_bytea(unnest($1::text[])))
+			  AND `+flagActive.isSet("p.flags")+`
+			  AND ($2 = '' OR p.path ILIKE '%' || $2 || '%')
+		) p
+		JOIN threat_domains d ON d.domain_hash = p.domain_hash
+		WHERE p.rn <= $3
+		ORDER BY d.domain, p.last_seen DESC
+	`, pq.Array(domains), search, domainListPathLimit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	paths, _, err := scanRows(rows, "threat_paths", scanPathRow)
+	for _, path := range paths {
+		result[path.Domain] = append(result[path.Domain], path)
+	}
+	return result, err
+}
+
+// handleDeletePath desactiva un path (borrado lógico: se limpia el bit active, la fila se
+// conserva para el audit log y para reactivarla con /api/admin/flags)
+// DELETE /api/data/paths/{path_hash} (con TOTP)
+func (s *Server) handleDeletePath(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	pathHash := strings.ToLower(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/data/paths/"), "/"))
+	if !pathHashRegex.MatchString(pathHash) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid path_hash"})
+		return
+	}
+
+	err := s.auditWrite(r, "delete_path", "threat_paths", pathHash, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+
+		result, err := s.db.ExecContext(ctx, `
+			UPDATE threat_paths SET flags = COALESCE(flags, 0) & ~$2::smallint
+			WHERE path_hash = decode($1, 'hex') AND `+flagActive.isSet("flags")+`
+		`, pathHash, int16(flagActive))
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errPathNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errPathNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().Str("path_hash", pathHash).Msg("[Paths] Path deactivated")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "path_hash": pathHash})
+}
+
+// pathSearchFilter condición de threat_domains: algún path activo coincide con la búsqueda $arg
+func pathSearchFilter(arg int) string {
+	return fmt.Sprintf(`EXISTS (
+			SELECT 1 FROM threat_paths p
+			WHERE p.domain_hash = threat_domains.domain_hash
+			  AND %s AND p.path ILIKE $%d
+		)`, flagActive.isSet("p.flags"), arg)
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// performanceTimeout EXPLAIN ANALYZE ejecuta las consultas de verdad
+	performanceTimeout = 30 * time.Second
+
+	// partitionMinRows filas de threat_domains a partir de las que compensa particionar
+	partitionMinRows = 1000000
+	// unusedIndexMinBytes índices sin uso por debajo de este tamaño no se reportan
+	unusedIndexMinBytes = 1 << 20
+	// seqScanMinRows tablas pequeñas se recorren enteras sin problema
+	seqScanMinRows = 100000
+	// seqScanMaxIndexRatio % de accesos por índice por debajo del que se avisa
+	seqScanMaxIndexRatio = 50
+	// bloatMinDeadTuples y bloatMinRatio umbrales de filas muertas para recomendar VACUUM
+	bloatMinDeadTuples = 10000
+	bloatMinRatio      = 20
+
+	slowQueriesLimit = 10
+)
+
+// perfQuery consulta habitual de fy-analysis/fy-admin que se analiza con EXPLAIN ANALYZE.
+// Los valores son fijos: interesa el plan, no el resultado.
+type perfQuery struct {
+	name  string
+	query string
+}
+
+var perfQueries = []perfQuery{
+	{"domain_lookup", `
+		SELECT threat_type, severity, confidence FROM threat_domains
+		WHERE domain_hash = sha256_bytea('example.com') AND ` + flagActive.isSet("flags") + ``},
+	{"domain_by_name", `
+		SELECT domain_hash FROM threat_domains WHERE domain = 'example.com'`},
+	{"list_active_domains", `
+		SELECT domain, threat_type, severity, last_seen FROM threat_domains
+		WHERE ` + flagActive.isSet("flags") + ` ORDER BY last_seen DESC LIMIT 50`},
+	{"domains_by_tld", `
+		SELECT COUNT(*) FROM threat_domains WHERE tld = 'com' AND ` + flagActive.isSet("flags") + ``},
+	{"domains_by_type", `
+		SELECT threat_type, COUNT(*) FROM threat_domains WHERE ` + flagActive.isSet("flags") + ` GROUP BY threat_type`},
+}
+
+// PerfExplain resultado de EXPLAIN ANALYZE de una consulta habitual
+type PerfExplain struct {
+	Name        string   `json:"name"`
+	Query       string   `json:"query"`
+	PlanningMs  float64  `json:"planning_ms"`
+	ExecutionMs float64  `json:"execution_ms"`
+	RootNode    string   `json:"root_node"`
+	SeqScans    []string `json:"seq_scans,omitempty"` // Tablas recorridas enteras
+	Error       string   `json:"error,omitempty"`
+}
+
+// PerfIndex uso de un índice (pg_stat_user_indexes)
+type PerfIndex struct {
+	Table     string `json:"table"`
+	Index     string `json:"index"`
+	Scans     int64  `json:"scans"`
+	SizeBytes int64  `json:"size_bytes"`
+	Unique    bool   `json:"unique"`
+}
+
+// PerfTable accesos y filas muertas de una tabla (pg_stat_user_tables)
+type PerfTable struct {
+	Table          string     `json:"table"`
+	LiveTuples     int64      `json:"live_tuples"`
+	DeadTuples     int64      `json:"dead_tuples"`
+	DeadRatio      float64    `json:"dead_ratio"` // % de filas muertas
+	SeqScans       int64      `json:"seq_scans"`
+	IndexScans     int64      `json:"index_scans"`
+	IndexRatio     float64    `json:"index_ratio"` // % de accesos por índice
+	LastAutovacuum *time.Time `json:"last_autovacuum,omitempty"`
+}
+
+// PerfPartitioning particionado de threat_domains
+type PerfPartitioning struct {
+	Partitioned  bool    `json:"partitioned"`
+	PartitionKey string  `json:"partition_key,omitempty"`
+	Rows         int64   `json:"rows"`
+	DistinctTLDs float64 `json:"distinct_tlds,omitempty"`
+	TopTLDShare  float64 `json:"top_tld_share,omitempty"` // % de filas del TLD más frecuente
+}
+
+// PerfSlowQuery consulta de pg_stat_statements
+type PerfSlowQuery struct {
+	Query   string  `json:"query"`
+	Calls   int64   `json:"calls"`
+	MeanMs  float64 `json:"mean_ms"`
+	TotalMs float64 `json:"total_ms"`
+	Rows    int64   `json:"rows"`
+}
+
+// handleDatabasePerformance diagnóstico de rendimiento de la BD con recomendaciones.
+// Solo lectura: no aplica ningún cambio de esquema.
+// GET /api/database/performance
+func (s *Server) handleDatabasePerformance(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Method not allowed"})
+		return
+	}
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), performanceTimeout)
+	defer cancel()
+
+	partitioning, err := s.perfPartitioning(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[Performance] Partitioning check failed")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	explains := s.perfExplains(ctx)
+	indexes, err := s.perfIndexes(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[Performance] Index usage query failed")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	tables, err := s.perfTables(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("[Performance] Table stats query failed")
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	slowQueries, slowErr := s.perfSlowQueries(ctx)
+
+	slow := map[string]interface{}{"available": slowErr == nil, "queries": slowQueries}
+	if slowErr != nil {
+		slow["error"] = slowErr.Error()
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":         true,
+		"partitioning":    partitioning,
+		"explain":         explains,
+		"indexes":         indexes,
+		"tables":          tables,
+		"slow_queries":    slow,
+		"recommendations": perfRecommendations(partitioning, explains, indexes, tables),
+		"generated_at":    time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// perfPartitioning si threat_domains está particionada y la distribución de TLDs según pg_stats
+func (s *Server) perfPartitioning(ctx context.Context) (*PerfPartitioning, error) {
+	p := &PerfPartitioning{}
+	var partKey sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT c.relkind = 'p', pg_get_partkeydef(c.oid), GREATEST(c.reltuples, 0)::bigint
+		FROM pg_class c WHERE c.oid = to_regclass('threat_domains')
+	`).Scan(&p.Partitioned, &partKey, &p.Rows)
+	if err != nil {
+		return nil, fmt.Errorf("threat_domains: %w", err)
+	}
+	p.PartitionKey = partKey.String
+
+	// n_distinct negativo = fracción de las filas; most_common_freqs[1] = TLD más frecuente
+	var nDistinct, topFreq sql.NullFloat64
+	err = s.db.QueryRowContext(ctx, `
+		SELECT n_distinct, most_common_freqs[1]
+		FROM pg_stats WHERE schemaname = 'public' AND tablename = 'threat_domains' AND attname = 'tld'
+	`).Scan(&nDistinct, &topFreq)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if nDistinct.Valid {
+		p.DistinctTLDs = nDistinct.Float64
+		if p.DistinctTLDs < 0 {
+			p.DistinctTLDs = math.Round(-p.DistinctTLDs * float64(p.Rows))
+		}
+	}
+	if topFreq.Valid {
+		p.TopTLDShare = math.Round(topFreq.Float64*1000) / 10
+	}
+	return p, nil
+}
+
+// perfExplains EXPLAIN ANALYZE de perfQueries en una transacción de solo lectura
+func (s *Server) perfExplains(ctx context.Context) []PerfExplain {
+	explains := make([]PerfExplain, 0, len(perfQueries))
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		for _, q := range perfQueries {
+			explains = append(explains, PerfExplain{Name: q.name, Query: q.query, Error: err.Error()})
+		}
+		return explains
+	}
+	defer tx.Rollback()
+
+	for _, q := range perfQueries {
+		explain := PerfExplain{Name: q.name, Query: q.query}
+
+		// Un error aborta la transacción: cada consulta va en su savepoint
+		tx.ExecContext(ctx, `SAVEPOINT perf_explain`)
+		var raw []byte
+		if err := tx.QueryRowContext(ctx, `EXPLAIN (ANALYZE, FORMAT JSON) `+q.query).Scan(&raw); err != nil {
+			explain.Error = err.Error()
+			tx.ExecContext(ctx, `ROLLBACK TO SAVEPOINT perf_explain`)
+		} else if err := parseExplain(raw, &explain); err != nil {
+			explain.Error = err.Error()
+		}
+		explains = append(explains, explain)
+	}
+	return explains
+}
+
+// explainNode nodo del plan de EXPLAIN (FORMAT JSON)
+type explainNode struct {
+	NodeType     string        `json:"Node Type"`
+	RelationName string        `json:"Relation Name"`
+	Plans        []explainNode `json:"Plans"`
+}
+
+func parseExplain(raw []byte, explain *PerfExplain) error {
+	var plans []struct {
+		Plan          explainNode `json:"Plan"`
+		PlanningTime  float64     `json:"Planning Time"`
+		ExecutionTime float64     `json:"Execution Time"`
+	}
+	if err := json.Unmarshal(raw, &plans); err != nil {
+		return err
+	}
+	if len(plans) == 0 {
+		return fmt.Errorf("empty plan")
+	}
+
+	explain.PlanningMs = plans[0].PlanningTime
+	explain.ExecutionMs = plans[0].ExecutionTime
+	explain.RootNode = plans[0].Plan.NodeType
+
+	var walk func(node explainNode)
+	walk = func(node explainNode) {
+		if node.NodeType == "Seq Scan" {
+			explain.SeqScans = append(explain.SeqScans, node.RelationName)
+		}
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	walk(plans[0].Plan)
+	return nil
+}
+
+// perfIndexes uso de los índices de las tablas del esquema public
+func (s *Server) perfIndexes(ctx context.Context) ([]PerfIndex, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT st.relname, st.indexrelname, st.idx_scan, pg_relation_size(st.indexrelid),
+		       i.indisunique OR i.indisprimary
+		FROM pg_stat_user_indexes st
+		JOIN pg_index i ON i.indexrelid = st.indexrelid
+		WHERE st.schemaname = 'public'
+		ORDER BY st.relname, st.idx_scan
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexes := []PerfIndex{}
+	for rows.Next() {
+		var idx PerfIndex
+		if err := rows.Scan(&idx.Table, &idx.Index, &idx.Scans, &idx.SizeBytes, &idx.Unique); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// perfTables accesos y filas muertas de las tablas del esquema public
+func (s *Server) perfTables(ctx context.Context) ([]PerfTable, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT relname, n_live_tup, n_dead_tup, seq_scan, COALESCE(idx_scan, 0), last_autovacuum
+		FROM pg_stat_user_tables
+		WHERE schemaname = 'public'
+		ORDER BY n_live_tup DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []PerfTable{}
+	for rows.Next() {
+		var t PerfTable
+		var lastAutovacuum sql.NullTime
+		if err := rows.Scan(&t.Table, &t.LiveTuples, &t.DeadTuples, &t.SeqScans, &t.IndexScans, &lastAutovacuum); err != nil {
+			return nil, err
+		}
+		if total := t.LiveTuples + t.DeadTuples; total > 0 {
+			t.DeadRatio = math.Round(float64(t.DeadTuples)*1000/float64(total)) / 10
+		}
+		if scans := t.SeqScans + t.IndexScans; scans > 0 {
+			t.IndexRatio = math.Round(float64(t.IndexScans)*1000/float64(scans)) / 10
+		}
+		if lastAutovacuum.Valid {
+			t.LastAutovacuum = &lastAutovacuum.Time
+		}
+		tables = append(tables, t)
+	}
+	return tables, rows.Err()
+}
+
+// perfSlowQueries las slowQueriesLimit consultas con mayor tiempo medio de pg_stat_statements.
+// Error si la extensión no está instalada o no está en shared_preload_libraries.
+func (s *Server) perfSlowQueries(ctx context.Context) ([]PerfSlowQuery, error) {
+	var installed bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM pg_extension WHERE extname = 'pg_stat_statements')
+	`).Scan(&installed); err != nil {
+		return nil, err
+	}
+	if !installed {
+		return nil, fmt.Errorf("pg_stat_statements extension not installed")
+	}
+
+	// PostgreSQL 13 renombró mean_time/total_time a mean_exec_time/total_exec_time
+	query := `SELECT query, calls, mean_exec_time, total_exec_time, rows
+		FROM pg_stat_statements ORDER BY mean_exec_time DESC LIMIT $1`
+	rows, err := s.db.QueryContext(ctx, query, slowQueriesLimit)
+	if err != nil {
+		rows, err = s.db.QueryContext(ctx, `SELECT query, calls, mean_time, total_time, rows
+			FROM pg_stat_statements ORDER BY mean_time DESC LIMIT $1`, slowQueriesLimit)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer rows.Close()
+
+	queries := []PerfSlowQuery{}
+	for rows.Next() {
+		var q PerfSlowQuery
+		if err := rows.Scan(&q.Query, &q.Calls, &q.MeanMs, &q.TotalMs, &q.Rows); err != nil {
+			return nil, err
+		}
+		q.MeanMs = math.Round(q.MeanMs*100) / 100
+		q.TotalMs = math.Round(q.TotalMs*100) / 100
+		queries = append(queries, q)
+	}
+	return queries, rows.Err()
+}
+
+// perfRecommendations recomendaciones accionables a partir del diagnóstico. Son solo texto:
+// cualquier cambio de esquema se aplica a mano con una migración.
+func perfRecommendations(p *PerfPartitioning, explains []PerfExplain, indexes []PerfIndex, tables []PerfTable) []string {
+	recommendations := []string{}
+
+	// Con partition pruning una consulta por TLD solo lee su partición: en el peor caso
+	// (el TLD más frecuente) se ahorra el resto de la tabla
+	if !p.Partitioned && p.Rows >= partitionMinRows && p.DistinctTLDs > 1 {
+		speedup := 100 - p.TopTLDShare
+		recommendations = append(recommendations, fmt.Sprintf(
+			"Recommend partitioning threat_domains by tld (estimated %.0f%% query speedup for TLD-filtered queries)", speedup))
+	}
+
+	for _, e := range explains {
+		for _, table := range e.SeqScans {
+			if table == "threat_domains" && p.Rows >= seqScanMinRows {
+				recommendations = append(recommendations, fmt.Sprintf(
+					"Query %s does a sequential scan on threat_domains (%.1f ms) - consider an index", e.Name, e.ExecutionMs))
+			}
+		}
+	}
+
+	for _, idx := range indexes {
+		if idx.Scans == 0 && !idx.Unique && idx.SizeBytes >= unusedIndexMinBytes {
+			recommendations = append(recommendations, fmt.Sprintf(
+				"Index %s on %s unused (%d MB) - consider dropping", idx.Index, idx.Table, idx.SizeBytes>>20))
+		}
+	}
+
+	for _, t := range tables {
+		if t.LiveTuples >= seqScanMinRows && t.SeqScans+t.IndexScans > 0 && t.IndexRatio < seqScanMaxIndexRatio {
+			recommendations = append(recommendations, fmt.Sprintf(
+				"Table %s is mostly read with sequential scans (%.1f%% index usage) - review its indexes", t.Table, t.IndexRatio))
+		}
+		if t.DeadTuples >= bloatMinDeadTuples && t.DeadRatio >= bloatMinRatio {
+			recommendations = append(recommendations, fmt.Sprintf(
+				"Table %s has %.1f%% dead tuples - run VACUUM ANALYZE or tune autovacuum", t.Table, t.DeadRatio))
+		}
+	}
+
+	return recommendations
+}
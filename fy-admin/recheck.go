@@ -0,0 +1,593 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/pkg/signature"
+)
+
+// Re-evaluación de dominios (migración 023): se vuelve a analizar el dominio con fy-analysis
+// y se compara el veredicto de las fuentes independientes con la clasificación guardada.
+// Los dominios que varias fuentes dan por limpios se marcan como false_positive_candidate
+// para que los revise un analista; nunca se desactivan automáticamente.
+
+const (
+	recheckAgree              = "agree"
+	recheckDowngradeCandidate = "downgrade_candidate"
+	recheckUpgrade            = "upgrade"
+
+	// recheckMinCleanSources fuentes independientes que deben responder sin detectarlo
+	// para considerarlo posible falso positivo
+	recheckMinCleanSources = 2
+	// recheckMaxBatch dominios por lote (manual o diario)
+	recheckMaxBatch = 500
+	// recheckManualBudget tiempo de un lote lanzado desde el panel: el servidor corta la
+	// respuesta a los 15s (WriteTimeout); lo que no dé tiempo queda para la siguiente llamada
+	recheckManualBudget = 12 * time.Second
+	// recheckAnalyzeTimeout timeout de cada análisis
+	recheckAnalyzeTimeout = 10 * time.Second
+	// recheckCheckerTimeoutMs timeout_ms de los checkers: el lote puede esperar a las APIs externas,
+	// con margen para responder antes de recheckAnalyzeTimeout
+	recheckCheckerTimeoutMs = 8000
+	// recheckMaxConsecutiveErrors errores seguidos tras los que se aborta el lote (fy-analysis caído)
+	recheckMaxConsecutiveErrors = 5
+	// recheckCaller llamante ante fy-analysis (presupuestos de APIs externas por llamante)
+	recheckCaller = "fy-admin-recheck"
+)
+
+// recheckIndependentSources fuentes de fy-analysis que cuentan como opinión independiente.
+// LocalDB es la propia threat_domains, y heurísticas, reportes de usuarios y similitud visual
+// no confirman que el dominio siga activo.
+var recheckIndependentSources = map[string]bool{
+	"urlhaus":   true,
+	"phishtank": true,
+	"webrisk":   true,
+	"urlscan":   true,
+}
+
+// RecheckOld clasificación guardada en threat_domains
+type RecheckOld struct {
+	ThreatType string `json:"threat_type"`
+	Severity   string `json:"severity"`
+	Confidence int    `json:"confidence"`
+}
+
+// RecheckNew veredicto de fy-analysis
+type RecheckNew struct {
+	RiskScore    int      `json:"risk_score"`
+	RiskLevel    string   `json:"risk_level"`
+	ThreatType   string   `json:"threat_type,omitempty"`
+	SourcesFound []string `json:"sources_found"`
+	SourcesClean []string `json:"sources_clean"`
+}
+
+// RecheckResult resultado de re-evaluar un dominio
+type RecheckResult struct {
+	Domain  string      `json:"domain"`
+	Old     RecheckOld  `json:"old"`
+	New     *RecheckNew `json:"new,omitempty"`
+	Outcome string      `json:"outcome,omitempty"`
+	Flagged bool        `json:"flagged"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// RecheckLogRow entrada de recheck_log
+type RecheckLogRow struct {
+	Domain       string    `json:"domain"`
+	Outcome      string    `json:"outcome"`
+	OldThreat    string    `json:"old_threat_type"`
+	OldSeverity  string    `json:"old_severity"`
+	NewRiskScore int       `json:"new_risk_score"`
+	NewRiskLevel string    `json:"new_risk_level"`
+	NewThreat    *string   `json:"new_threat_type,omitempty"`
+	SourcesFound []string  `json:"sources_found"`
+	SourcesClean []string  `json:"sources_clean"`
+	Flagged      bool      `json:"flagged"`
+	TriggeredBy  string    `json:"triggered_by"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+// recheckTarget dominio a re-evaluar con su clasificación y flags actuales
+type recheckTarget struct {
+	domain string
+	old    RecheckOld
+	flags  int
+}
+
+// analysisVerdict campos usados de la respuesta de POST /api/v1/analyze
+type analysisVerdict struct {
+	RiskScore int    `json:"risk_score"`
+	RiskLevel string `json:"risk_level"`
+	Threats   []struct {
+		Source string `json:"source"`
+		Type   string `json:"type"`
+	} `json:"threats"`
+	Sources []struct {
+		Name  string `json:"name"`
+		Found bool   `json:"found"`
+		Error string `json:"error"`
+	} `json:"sources"`
+}
+
+// severityRanks orden de severity_enum
+var severityRanks = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// freshVerdict resume la respuesta de fy-analysis. Solo cuentan las fuentes independientes
+// que respondieron sin error: una API caída no es una opinión.
+func freshVerdict(v *analysisVerdict) RecheckNew {
+	fresh := RecheckNew{
+		RiskScore:    v.RiskScore,
+		RiskLevel:    v.RiskLevel,
+		SourcesFound: []string{},
+		SourcesClean: []string{},
+	}
+	for _, source := range v.Sources {
+		if !recheckIndependentSources[source.Name] || source.Error != "" {
+			continue
+		}
+		if source.Found {
+			fresh.SourcesFound = append(fresh.SourcesFound, source.Name)
+		} else {
+			fresh.SourcesClean = append(fresh.SourcesClean, source.Name)
+		}
+	}
+	sort.Strings(fresh.SourcesFound)
+	sort.Strings(fresh.SourcesClean)
+
+	for _, threat := range v.Threats {
+		if recheckIndependentSources[threat.Source] {
+			fresh.ThreatType = threat.Type
+			break
+		}
+	}
+	return fresh
+}
+
+// classifyRecheck compara el veredicto nuevo con la clasificación guardada:
+//   - downgrade_candidate: ninguna fuente independiente lo detecta y al menos
+//     recheckMinCleanSources responden limpio
+//   - upgrade: alguna fuente independiente lo detecta y la severidad guardada es low/medium
+//   - agree: en el resto de casos (incluido no tener suficientes fuentes para opinar)
+func classifyRecheck(old RecheckOld, fresh RecheckNew) string {
+	if len(fresh.SourcesFound) == 0 && len(fresh.SourcesClean) >= recheckMinCleanSources {
+		return recheckDowngradeCandidate
+	}
+	if len(fresh.SourcesFound) > 0 && severityRanks[old.Severity] < severityRanks["high"] {
+		return recheckUpgrade
+	}
+	return recheckAgree
+}
+
+// analyzeDomain pide a fy-analysis un análisis del dominio
+func (s *Server) analyzeDomain(ctx context.Context, domain string) (*analysisVerdict, error) {
+	ctx, cancel := context.WithTimeout(ctx, recheckAnalyzeTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(map[string]interface{}{
+		"input":      domain,
+		"type":       "url",
+		"timeout_ms": recheckCheckerTimeoutMs,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		s.config.AnalysisURL+"/api/v1/analyze?engine_info=false", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Trackfy-Caller", recheckCaller)
+	setCorrelationHeader(req)
+	if err := signature.SignRequest(req, s.config.SigningSecret); err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("fy-analysis returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	var verdict analysisVerdict
+	if err := json.NewDecoder(resp.Body).Decode(&verdict); err != nil {
+		return nil, fmt.Errorf("invalid fy-analysis response: %w", err)
+	}
+	return &verdict, nil
+}
+
+// recheckTargetByDomain clasificación actual de un dominio (sql.ErrNoRows si no existe)
+func (s *Server) recheckTargetByDomain(ctx context.Context, domain string) (recheckTarget, error) {
+	target := recheckTarget{domain: domain}
+	err := s.db.QueryRowContext(ctx, `
+		SELECT threat_type::text, severity::text, confidence, COALESCE(flags, 0)
+		FROM threat_domains
+		WHERE domain_hash = sha256_bytea($1)
+	`, domain).Scan(&target.old.ThreatType, &target.old.Severity, &target.old.Confidence, &target.flags)
+	return target, err
+}
+
+// recheckTargets los n dominios activos revisados hace más tiempo (primero los nunca
+// revisados). Se saltan los ya marcados como posible falso positivo y los verificados.
+func (s *Server) recheckTargets(ctx context.Context, n int) ([]recheckTarget, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT domain, threat_type::text, severity::text, confidence, COALESCE(flags, 0)
+		FROM threat_domains
+		WHERE `+activeThreat("flags")+`
+		  AND NOT `+flagFalsePositiveCandidate.isSet("flags")+`
+		  AND NOT `+flagAnalystVerified.isSet("flags")+`
+		ORDER BY last_rechecked_at NULLS FIRST, first_seen
+		LIMIT $1
+	`, n)
+	if err != nil {
+		return nil, err
+	}
+	targets, _, err := scanRows(rows, "threat_domains", func(rows *sql.Rows) (recheckTarget, error) {
+		var t recheckTarget
+		err := rows.Scan(&t.domain, &t.old.ThreatType, &t.old.Severity, &t.old.Confidence, &t.flags)
+		return t, err
+	})
+	rows.Close()
+	return targets, err
+}
+
+// recheckDomain re-evalúa un dominio y guarda el resultado en recheck_log. trigger es
+// "manual" o "scheduled"; auditIP identifica al autor del flag en el audit log.
+func (s *Server) recheckDomain(ctx context.Context, target recheckTarget, trigger, auditIP string) RecheckResult {
+	result := RecheckResult{Domain: target.domain, Old: target.old}
+
+	verdict, err := s.analyzeDomain(ctx, target.domain)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	fresh := freshVerdict(verdict)
+	result.New = &fresh
+	result.Outcome = classifyRecheck(target.old, fresh)
+
+	writeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.writeTimeout)
+	defer cancel()
+
+	if result.Outcome == recheckDowngradeCandidate && !flagAnalystVerified.has(target.flags) {
+		oldValue := s.audit.Snapshot(writeCtx, "threat_domains", target.domain)
+		_, err := s.db.ExecContext(writeCtx, `
+			UPDATE threat_domains SET flags = COALESCE(flags, 0) | $2::smallint
+			WHERE domain_hash = sha256_bytea($1)
+		`, target.domain, int16(flagFalsePositiveCandidate))
+		if err != nil {
+			result.Error = "flag: " + err.Error()
+		} else {
+			result.Flagged = true
+			entry := &AuditEntry{
+				Action:   "recheck_flag",
+				Table:    "threat_domains",
+				RecordID: target.domain,
+				OldValue: oldValue,
+				NewValue: s.audit.Snapshot(writeCtx, "threat_domains", target.domain),
+				AdminIP:  auditIP,
+			}
+			if err := s.audit.Log(writeCtx, entry); err != nil {
+				log.Error().Err(err).Str("domain", target.domain).Msg("[Audit] Failed to log entry")
+			}
+		}
+	}
+
+	var newThreat sql.NullString
+	if fresh.ThreatType != "" {
+		newThreat = sql.NullString{String: fresh.ThreatType, Valid: true}
+	}
+	_, err = s.db.ExecContext(writeCtx, `
+		WITH touched AS (
+			UPDATE threat_domains SET last_rechecked_at = NOW()
+			WHERE domain_hash = sha256_bytea($1)
+			RETURNING domain_hash
+		)
+		INSERT INTO recheck_log (domain_hash, domain, outcome, old_threat_type, old_severity, old_confidence,
+		                         new_risk_score, new_risk_level, new_threat_type, sources_found, sources_clean,
+		                         flagged, triggered_by)
+		SELECT domain_hash, $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12 FROM touched
+	`, target.domain, result.Outcome, target.old.ThreatType, target.old.Severity, target.old.Confidence,
+		fresh.RiskScore, fresh.RiskLevel, newThreat, pq.Array(fresh.SourcesFound), pq.Array(fresh.SourcesClean),
+		result.Flagged, trigger)
+	if err != nil {
+		log.Warn().Err(err).Str("domain", target.domain).Msg("[Recheck] Failed to record result")
+		if result.Error == "" {
+			result.Error = "record: " + err.Error()
+		}
+	}
+	return result
+}
+
+// recheckBatch re-evalúa en serie los n dominios revisados hace más tiempo. Se detiene al
+// cancelarse ctx o tras recheckMaxConsecutiveErrors análisis fallidos seguidos.
+// truncated indica que quedaron dominios sin revisar.
+func (s *Server) recheckBatch(ctx context.Context, n int, trigger, auditIP string) (results []RecheckResult, truncated bool, err error) {
+	readCtx, cancel := s.readCtx(ctx)
+	targets, err := s.recheckTargets(readCtx, n)
+	cancel()
+	if err != nil {
+		return nil, false, err
+	}
+
+	results = make([]RecheckResult, 0, len(targets))
+	consecutiveErrors := 0
+	for _, target := range targets {
+		if ctx.Err() != nil || consecutiveErrors >= recheckMaxConsecutiveErrors {
+			return results, true, nil
+		}
+		result := s.recheckDomain(ctx, target, trigger, auditIP)
+		if result.New == nil {
+			// Un análisis cortado por el presupuesto del lote no es un fallo del dominio
+			if ctx.Err() != nil {
+				return results, true, nil
+			}
+			consecutiveErrors++
+		} else {
+			consecutiveErrors = 0
+		}
+		results = append(results, result)
+	}
+	return results, false, nil
+}
+
+// recheckSummary resultados por outcome ("error" si no se pudo analizar)
+func recheckSummary(results []RecheckResult) map[string]int {
+	summary := map[string]int{recheckAgree: 0, recheckDowngradeCandidate: 0, recheckUpgrade: 0, "flagged": 0, "error": 0}
+	for _, result := range results {
+		if result.Outcome == "" {
+			summary["error"]++
+			continue
+		}
+		summary[result.Outcome]++
+		if result.Flagged {
+			summary["flagged"]++
+		}
+	}
+	return summary
+}
+
+// recheckSchedule programación actual del lote diario
+func (s *Server) recheckSchedule() map[string]interface{} {
+	return map[string]interface{}{
+		"daily_batch": s.recheckDailyBatch.Load(),
+		"hour_utc":    s.config.RecheckHour,
+	}
+}
+
+// handleRecheck re-evalúa un dominio o un lote, o programa el lote diario
+// POST /api/actions/recheck {"domain": "..."} | {"batch": N} | {"daily_batch": N}
+func (s *Server) handleRecheck(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	var input struct {
+		Domain     string `json:"domain"`
+		Batch      int    `json:"batch"`
+		DailyBatch *int   `json:"daily_batch"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(input.Domain))
+
+	if input.DailyBatch != nil {
+		if *input.DailyBatch < 0 || *input.DailyBatch > recheckMaxBatch {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": fmt.Sprintf("daily_batch must be between 0 and %d", recheckMaxBatch)})
+			return
+		}
+		previous := s.recheckDailyBatch.Swap(int32(*input.DailyBatch))
+		s.auditLog(r, &AuditEntry{
+			Action:   "recheck_schedule",
+			Table:    "recheck_log",
+			OldValue: auditJSON(map[string]int32{"daily_batch": previous}),
+			NewValue: auditJSON(map[string]int{"daily_batch": *input.DailyBatch}),
+		})
+		log.Info().Int("daily_batch", *input.DailyBatch).Msg("[Recheck] Daily batch scheduled")
+
+		if domain == "" && input.Batch == 0 {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "schedule": s.recheckSchedule()})
+			return
+		}
+	}
+
+	if domain == "" && input.Batch <= 0 {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "domain or batch is required"})
+		return
+	}
+	if input.Batch > recheckMaxBatch {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": fmt.Sprintf("batch must be at most %d", recheckMaxBatch)})
+		return
+	}
+
+	if !s.recheckMu.TryLock() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "A recheck is already running"})
+		return
+	}
+	defer s.recheckMu.Unlock()
+
+	var results []RecheckResult
+	truncated := false
+	if domain != "" {
+		readCtx, cancel := s.readCtx(r.Context())
+		target, err := s.recheckTargetByDomain(readCtx, domain)
+		cancel()
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Domain not found"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		results = []RecheckResult{s.recheckDomain(r.Context(), target, "manual", adminIP(r))}
+	} else {
+		ctx, cancel := context.WithTimeout(r.Context(), recheckManualBudget)
+		var err error
+		results, truncated, err = s.recheckBatch(ctx, input.Batch, "manual", adminIP(r))
+		cancel()
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	summary := recheckSummary(results)
+	log.Info().
+		Int("checked", len(results)).
+		Int("downgrade_candidates", summary[recheckDowngradeCandidate]).
+		Int("upgrades", summary[recheckUpgrade]).
+		Int("errors", summary["error"]).
+		Bool("truncated", truncated).
+		Msg("[Recheck] Manual recheck finished")
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":   true,
+		"results":   results,
+		"summary":   summary,
+		"truncated": truncated,
+		"schedule":  s.recheckSchedule(),
+	})
+}
+
+// handleRecheckLog programación, resultados de los últimos 30 días y últimas re-evaluaciones
+// GET /api/admin/recheck[?domain=...&limit=50]
+func (s *Server) handleRecheckLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	limit := getQueryInt(r, "limit", 50)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	domain := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("domain")))
+
+	outcomes := s.groupCounts(ctx, "outcome", `
+		SELECT outcome, COUNT(*) FROM recheck_log
+		WHERE checked_at >= NOW() - INTERVAL '30 days'
+		GROUP BY outcome
+	`)
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT domain, outcome, old_threat_type, old_severity, new_risk_score, new_risk_level,
+		       new_threat_type, sources_found, sources_clean, flagged, triggered_by, checked_at
+		FROM recheck_log
+		WHERE ($1 = '' OR domain_hash = sha256_bytea($1))
+		ORDER BY checked_at DESC, id DESC
+		LIMIT $2
+	`, domain, limit)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	recent, scanErrors, err := scanRows(rows, "recheck_log", func(rows *sql.Rows) (RecheckLogRow, error) {
+		var row RecheckLogRow
+		var newThreat sql.NullString
+		err := rows.Scan(&row.Domain, &row.Outcome, &row.OldThreat, &row.OldSeverity, &row.NewRiskScore,
+			&row.NewRiskLevel, &newThreat, pq.Array(&row.SourcesFound), pq.Array(&row.SourcesClean),
+			&row.Flagged, &row.TriggeredBy, &row.CheckedAt)
+		if newThreat.Valid {
+			row.NewThreat = &newThreat.String
+		}
+		return row, err
+	})
+	rows.Close()
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":      true,
+		"schedule":     s.recheckSchedule(),
+		"outcomes_30d": outcomes,
+		"recent":       recent,
+		"scan_errors":  scanErrors,
+	})
+}
+
+// recheckLoop lanza cada día a RecheckHour (UTC) el lote programado con daily_batch
+func (f *feedScheduler) recheckLoop(ctx context.Context) {
+	defer f.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(nextRecheckRun(time.Now().UTC(), f.server.config.RecheckHour))):
+		}
+
+		batch := int(f.server.recheckDailyBatch.Load())
+		if batch <= 0 {
+			continue
+		}
+		// Un lote manual sigue en curso: se espera al día siguiente
+		if !f.server.recheckMu.TryLock() {
+			log.Warn().Msg("[Recheck] Manual recheck in progress, skipping daily batch")
+			continue
+		}
+		start := time.Now()
+		results, truncated, err := f.server.recheckBatch(ctx, batch, "scheduled", "scheduler")
+		f.server.recheckMu.Unlock()
+		if err != nil {
+			log.Error().Err(err).Msg("[Recheck] Daily batch failed")
+			continue
+		}
+
+		summary := recheckSummary(results)
+		log.Info().
+			Int("checked", len(results)).
+			Int("downgrade_candidates", summary[recheckDowngradeCandidate]).
+			Int("upgrades", summary[recheckUpgrade]).
+			Int("errors", summary["error"]).
+			Bool("truncated", truncated).
+			Dur("duration", time.Since(start)).
+			Msg("[Recheck] Daily batch finished")
+	}
+}
+
+// nextRecheckRun siguiente ejecución del lote diario a la hora hour (UTC) después de now
+func nextRecheckRun(now time.Time, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
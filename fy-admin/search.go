@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	searchMinQueryLen  = 3
+	searchDefaultLimit = 20
+	searchMaxLimit     = 100
+	searchTimeout      = 3 * time.Second
+)
+
+// searchCategory consulta de una tabla. $1 = patrón ILIKE, $2 = texto para similarity(),
+// $3 = patrón solo dígitos (solo si usesDigits).
+// Cada fila retorna el resultado como JSON y el total de coincidencias (COUNT(*) OVER()).
+type searchCategory struct {
+	name       string
+	query      string
+	usesDigits bool
+}
+
+var searchCategories = []searchCategory{
+	{"domains", `
+		SELECT json_build_object('domain', domain, 'threat_type', threat_type, 'severity', severity,
+		       'confidence', confidence, 'source', source, 'last_seen', last_seen, 'active', ` + flagActive.isSet("flags") + `)::text,
+		       COUNT(*) OVER()
+		FROM threat_domains
+		WHERE domain ILIKE $1
+		ORDER BY similarity(domain, $2) DESC, last_seen DESC`, false},
+	{"paths", `
+		SELECT json_build_object('url', td.domain || COALESCE(tp.path, ''), 'threat_type', tp.threat_type,
+		       'severity', tp.severity, 'payload_type', tp.payload_type, 'target_brand', tp.target_brand,
+		       'source', tp.source, 'last_seen', tp.last_seen)::text,
+		       COUNT(*) OVER()
+		FROM threat_paths tp
+		JOIN threat_domains td ON td.domain_hash = tp.domain_hash
+		WHERE (td.domain || COALESCE(tp.path, '')) ILIKE $1
+		ORDER BY similarity(td.domain || COALESCE(tp.path, ''), $2) DESC, tp.last_seen DESC`, false},
+	{"emails", `
+		SELECT json_build_object('email', email, 'threat_type', threat_type, 'severity', severity,
+		       'impersonates', impersonates, 'source', source, 'report_count', report_count, 'last_seen', last_seen)::text,
+		       COUNT(*) OVER()
+		FROM threat_emails
+		WHERE email ILIKE $1 OR impersonates ILIKE $1
+		ORDER BY similarity(email, $2) DESC, last_seen DESC`, false},
+	{"phones", `
+		SELECT json_build_object('phone', phone_national, 'country_code', country_code, 'threat_type', threat_type,
+		       'severity', severity, 'description', description, 'source', source, 'report_count', report_count,
+		       'last_seen', last_seen)::text,
+		       COUNT(*) OVER()
+		FROM threat_phones
+		WHERE phone_national LIKE $3 OR description ILIKE $1
+		ORDER BY similarity(phone_national, $2) DESC, last_seen DESC`, true},
+	{"whitelist", `
+		SELECT json_build_object('domain', domain, 'category', category, 'brand', brand,
+		       'country', country, 'official_name', official_name)::text,
+		       COUNT(*) OVER()
+		FROM whitelist_domains
+		WHERE domain ILIKE $1 OR brand ILIKE $1 OR official_name ILIKE $1
+		ORDER BY similarity(domain, $2) DESC`, false},
+	{"reports", `
+		SELECT json_build_object('url', url, 'domain', domain, 'threat_type', primary_threat_type,
+		       'aggregated_score', aggregated_score, 'total_reports', total_reports, 'status', status,
+		       'last_reported', last_reported_at)::text,
+		       COUNT(*) OVER()
+		FROM reported_urls
+		WHERE url ILIKE $1
+		ORDER BY similarity(url, $2) DESC, last_reported_at DESC`, false},
+}
+
+// SearchCategoryResult resultados de una categoría de la búsqueda global
+type SearchCategoryResult struct {
+	Total     int64             `json:"total"`
+	Results   []json.RawMessage `json:"results"`
+	ElapsedMs int64             `json:"elapsed_ms"`
+	Error     string            `json:"error,omitempty"`
+}
+
+// handleSearch busca un fragmento en todas las tablas de amenazas
+// GET /api/search?q=&limit=
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if len([]rune(q)) < searchMinQueryLen {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   fmt.Sprintf("Query must be at least %d characters", searchMinQueryLen),
+		})
+		return
+	}
+
+	limit := getQueryInt(r, "limit", searchDefaultLimit)
+	if limit <= 0 || limit > searchMaxLimit {
+		limit = searchMaxLimit
+	}
+
+	// Un teléfono se busca solo por sus dígitos ("612 34 56" -> "%6123456%")
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, q)
+	pattern := "%" + escapeLike(strings.ToLower(q)) + "%"
+	digitsPattern := pattern
+	if len(digits) >= searchMinQueryLen {
+		digitsPattern = "%" + digits + "%"
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), searchTimeout)
+	defer cancel()
+
+	startTime := time.Now()
+	results := make(map[string]*SearchCategoryResult, len(searchCategories))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, category := range searchCategories {
+		wg.Add(1)
+		go func(category searchCategory) {
+			defer wg.Done()
+			result := s.searchCategory(ctx, category, limit, pattern, strings.ToLower(q), digitsPattern)
+			mu.Lock()
+			results[category.name] = result
+			mu.Unlock()
+		}(category)
+	}
+	wg.Wait()
+
+	var total int64
+	for _, result := range results {
+		total += result.Total
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"query":      q,
+		"limit":      limit,
+		"total":      total,
+		"elapsed_ms": time.Since(startTime).Milliseconds(),
+		"categories": results,
+	})
+}
+
+// searchCategory ejecuta la consulta de una categoría; un timeout solo afecta a esa categoría
+func (s *Server) searchCategory(ctx context.Context, category searchCategory, limit int, pattern, text, digitsPattern string) *SearchCategoryResult {
+	startTime := time.Now()
+	result := &SearchCategoryResult{Results: []json.RawMessage{}}
+	defer func() {
+		result.ElapsedMs = time.Since(startTime).Milliseconds()
+	}()
+
+	args := []interface{}{pattern, text}
+	if category.usesDigits {
+		args = append(args, digitsPattern)
+	}
+
+	rows, err := s.db.QueryContext(ctx, category.query+fmt.Sprintf(" LIMIT %d", limit), args...)
+	if err != nil {
+		result.Error = searchError(ctx, err)
+		return result
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item []byte
+		if err := rows.Scan(&item, &result.Total); err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.Results = append(result.Results, item)
+	}
+	if err := rows.Err(); err != nil {
+		result.Error = searchError(ctx, err)
+	}
+	return result
+}
+
+func searchError(ctx context.Context, err error) string {
+	if ctx.Err() == context.DeadlineExceeded {
+		return "timeout"
+	}
+	return err.Error()
+}
+
+// escapeLike escapa los comodines de LIKE en la entrada del usuario
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
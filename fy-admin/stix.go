@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Exportación de indicadores en STIX 2.1 para SIEMs de partners (misma API key que /v1/ti):
+//
+//	GET /api/export/stix?since=2024-01-01T00:00:00Z&limit=1000&next=...
+//
+// La respuesta es un envelope de TAXII 2.1 ({"more", "next", "objects"}) como el del
+// endpoint objects/ de una colección. Los indicadores se ordenan por last_seen; next es un
+// cursor opaco, así que una re-exportación desde el último next solo trae lo nuevo o
+// modificado. Los IDs son UUIDv5 del hash del registro: el mismo indicador conserva su ID.
+const (
+	stixDefaultLimit = 1000
+	stixMaxLimit     = 5000
+
+	stixContentType = "application/taxii+json;version=2.1"
+
+	// stixTimeFormat created/modified/valid_from (STIX exige precisión de milisegundos)
+	stixTimeFormat = "2006-01-02T15:04:05.000Z"
+	// stixCursorTime last_seen del cursor (TIMESTAMP sin zona, precisión de microsegundos)
+	stixCursorTime = "2006-01-02 15:04:05.999999"
+)
+
+// stixNamespace namespace de los UUIDv5 de los indicadores de Trackfy (no cambiar: los
+// SIEMs deduplican por ID)
+var stixNamespace = mustParseUUID("3b0f6a52-9c1e-4d7b-8a45-6e2f1c9d0b73")
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// stixIndicatorsQuery todas las tablas de amenazas con la misma forma. key es la clave
+// primaria en texto (hash en hex, o el número en teléfonos) y desempata el orden del cursor.
+var stixIndicatorsQuery = `
+	SELECT kind, key, value, threat_type, confidence, severity, source, first_seen, last_seen
+	FROM (
+		SELECT 'domain' AS kind, encode(domain_hash, 'hex') AS key, domain AS value,
+			threat_type::text AS threat_type, confidence, severity::text AS severity, source::text AS source,
+			first_seen, last_seen
+		FROM threat_domains
+		WHERE ` + activeThreat("flags") + `
+		UNION ALL
+		SELECT 'path', encode(tp.path_hash, 'hex'), td.domain || '/' || ltrim(COALESCE(tp.path, ''), '/'),
+			tp.threat_type::text, tp.confidence, tp.severity::text, tp.source::text,
+			tp.first_seen, tp.last_seen
+		FROM threat_paths tp
+		JOIN threat_domains td ON td.domain_hash = tp.domain_hash
+		WHERE ` + activeThreat("tp.flags") + `
+		UNION ALL
+		SELECT 'email', encode(email_hash, 'hex'), email,
+			threat_type::text, confidence, severity::text, source::text,
+			first_seen, last_seen
+		FROM threat_emails
+		WHERE ` + activeThreat("flags") + `
+		UNION ALL
+		SELECT 'phone', '+' || country_code || phone_national, '+' || country_code || phone_national,
+			threat_type::text, confidence, severity::text, source::text,
+			first_seen, last_seen
+		FROM threat_phones
+		WHERE ` + activeThreat("flags") + `
+	) indicators
+	WHERE %s
+	ORDER BY last_seen, kind, key
+	LIMIT %d
+`
+
+// stixRow fila de stixIndicatorsQuery
+type stixRow struct {
+	kind, key, value    string
+	threatType          string
+	confidence          int
+	severity, source    string
+	firstSeen, lastSeen time.Time
+}
+
+// stixCursor posición tras la última fila devuelta (last_seen, kind, key)
+type stixCursor struct {
+	lastSeen  time.Time
+	kind, key string
+}
+
+// STIXIndicator objeto indicator de STIX 2.1
+type STIXIndicator struct {
+	Type               string            `json:"type"`
+	SpecVersion        string            `json:"spec_version"`
+	ID                 string            `json:"id"`
+	Created            string            `json:"created"`
+	Modified           string            `json:"modified"`
+	Name               string            `json:"name"`
+	IndicatorTypes     []string          `json:"indicator_types"`
+	Pattern            string            `json:"pattern"`
+	PatternType        string            `json:"pattern_type"`
+	PatternVersion     string            `json:"pattern_version"`
+	ValidFrom          string            `json:"valid_from"`
+	Confidence         int               `json:"confidence"`
+	Labels             []string          `json:"labels"`
+	ExternalReferences []STIXExternalRef `json:"external_references,omitempty"`
+	Severity           string            `json:"x_trackfy_severity,omitempty"`
+}
+
+// STIXExternalRef fuente original del indicador
+type STIXExternalRef struct {
+	SourceName string `json:"source_name"`
+}
+
+// TAXIIEnvelope respuesta de objects/ en TAXII 2.1
+type TAXIIEnvelope struct {
+	More    bool            `json:"more"`
+	Next    string          `json:"next,omitempty"`
+	Objects []STIXIndicator `json:"objects"`
+}
+
+// handleExportSTIX GET /api/export/stix
+func (s *Server) handleExportSTIX(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		respondTI(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.dbReady() {
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	key, err := s.apiKeys.authenticate(ctx, r)
+	if err != nil {
+		ctxLogger(r.Context()).Error().Err(err).Msg("[STIX] API key lookup failed")
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="trackfy-ti"`)
+		respondTI(w, http.StatusUnauthorized, "Missing or invalid API key")
+		return
+	}
+
+	status, found := s.serveSTIX(ctx, w, r, key)
+
+	writeCtx, cancelWrite := s.writeCtx(r.Context())
+	defer cancelWrite()
+	s.apiKeys.recordUsage(writeCtx, key, "stix", status, found)
+}
+
+// serveSTIX responde una página de indicadores a una clave ya autenticada y retorna el
+// status y si la página tenía objetos (para api_key_usage)
+func (s *Server) serveSTIX(ctx context.Context, w http.ResponseWriter, r *http.Request, key *apiKey) (int, bool) {
+	allowed, remaining, retryAfter := s.apiKeys.allow(key, time.Now())
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.rateLimit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondTI(w, http.StatusTooManyRequests, "Rate limit exceeded")
+		return http.StatusTooManyRequests, false
+	}
+
+	limit := getQueryInt(r, "limit", stixDefaultLimit)
+	if limit < 1 || limit > stixMaxLimit {
+		limit = stixDefaultLimit
+	}
+
+	// since (o added_after, el nombre de TAXII) solo se usa sin cursor: el cursor ya es posterior
+	var cursor *stixCursor
+	var since time.Time
+	if next := r.URL.Query().Get("next"); next != "" {
+		c, err := decodeSTIXCursor(next)
+		if err != nil {
+			respondTI(w, http.StatusBadRequest, "Invalid next cursor")
+			return http.StatusBadRequest, false
+		}
+		cursor = c
+	} else if raw := firstQuery(r, "since", "added_after"); raw != "" {
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			respondTI(w, http.StatusBadRequest, "Invalid since: expected RFC 3339 timestamp")
+			return http.StatusBadRequest, false
+		}
+		since = t.UTC()
+	}
+
+	rows, err := s.querySTIXRows(ctx, since, cursor, limit+1)
+	if err != nil {
+		ctxLogger(ctx).Error().Err(err).Msg("[STIX] Export query failed")
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return http.StatusServiceUnavailable, false
+	}
+
+	envelope := TAXIIEnvelope{Objects: make([]STIXIndicator, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		envelope.More = true
+		envelope.Next = encodeSTIXCursor(stixCursor{lastSeen: last.lastSeen, kind: last.kind, key: last.key})
+	}
+	for _, row := range rows {
+		envelope.Objects = append(envelope.Objects, row.indicator())
+	}
+
+	if len(rows) > 0 {
+		w.Header().Set("X-TAXII-Date-Added-First", rows[0].lastSeen.UTC().Format(stixTimeFormat))
+		w.Header().Set("X-TAXII-Date-Added-Last", rows[len(rows)-1].lastSeen.UTC().Format(stixTimeFormat))
+	}
+	w.Header().Set("Content-Type", stixContentType)
+	json.NewEncoder(w).Encode(envelope)
+	return http.StatusOK, len(rows) > 0
+}
+
+// querySTIXRows hasta limit filas posteriores a cursor (o con last_seen > since sin cursor)
+func (s *Server) querySTIXRows(ctx context.Context, since time.Time, cursor *stixCursor, limit int) ([]stixRow, error) {
+	where := "last_seen > $1::timestamp"
+	args := []interface{}{since.Format(stixCursorTime)}
+	if cursor != nil {
+		where = "(last_seen, kind, key) > ($1::timestamp, $2, $3)"
+		args = []interface{}{cursor.lastSeen.Format(stixCursorTime), cursor.kind, cursor.key}
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(stixIndicatorsQuery, where, limit), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []stixRow{}
+	for rows.Next() {
+		var row stixRow
+		if err := rows.Scan(&row.kind, &row.key, &row.value, &row.threatType, &row.confidence,
+			&row.severity, &row.source, &row.firstSeen, &row.lastSeen); err != nil {
+			return nil, err
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// indicator objeto STIX de la fila
+func (row stixRow) indicator() STIXIndicator {
+	created := row.firstSeen.UTC()
+	modified := row.lastSeen.UTC()
+	if modified.Before(created) {
+		modified = created
+	}
+
+	confidence := row.confidence
+	if confidence < 0 {
+		confidence = 0
+	} else if confidence > 100 {
+		confidence = 100
+	}
+
+	return STIXIndicator{
+		Type:               "indicator",
+		SpecVersion:        "2.1",
+		ID:                 "indicator--" + uuidV5(stixNamespace, row.kind+":"+row.idHash()),
+		Created:            created.Format(stixTimeFormat),
+		Modified:           modified.Format(stixTimeFormat),
+		Name:               fmt.Sprintf("%s %s: %s", row.threatType, row.kind, row.value),
+		IndicatorTypes:     []string{stixIndicatorType(row.threatType)},
+		Pattern:            stixPattern(row.kind, row.value),
+		PatternType:        "stix",
+		PatternVersion:     "2.1",
+		ValidFrom:          created.Format(stixTimeFormat),
+		Confidence:         confidence,
+		Labels:             []string{row.threatType},
+		ExternalReferences: []STIXExternalRef{{SourceName: row.source}},
+		Severity:           row.severity,
+	}
+}
+
+// idHash hash del registro para el UUIDv5: la clave primaria salvo en teléfonos, que no
+// tienen hash (SHA256 del número E.164)
+func (row stixRow) idHash() string {
+	if row.kind == "phone" {
+		sum := sha256.Sum256([]byte(row.key))
+		return hex.EncodeToString(sum[:])
+	}
+	return row.key
+}
+
+// stixPattern patrón STIX del indicador. Los paths no guardan el esquema: se aceptan http y https.
+// Los teléfonos no tienen objeto observable en STIX 2.1 y usan uno propio.
+func stixPattern(kind, value string) string {
+	switch kind {
+	case "domain":
+		return fmt.Sprintf("[domain-name:value = '%s']", stixQuote(value))
+	case "path":
+		return fmt.Sprintf("[url:value = '%s' OR url:value = '%s']",
+			stixQuote("http://"+value), stixQuote("https://"+value))
+	case "email":
+		return fmt.Sprintf("[email-addr:value = '%s']", stixQuote(value))
+	default:
+		return fmt.Sprintf("[x-trackfy-phone-number:value = '%s']", stixQuote(value))
+	}
+}
+
+// stixQuote escapa un literal de cadena de un patrón STIX
+func stixQuote(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(value)
+}
+
+// stixIndicatorType threat_type -> indicator-type-ov de STIX
+func stixIndicatorType(threatType string) string {
+	switch threatType {
+	case "spam":
+		return "anomalous-activity"
+	case "other":
+		return "unknown"
+	default:
+		return "malicious-activity"
+	}
+}
+
+func encodeSTIXCursor(c stixCursor) string {
+	raw := c.lastSeen.Format(stixCursorTime) + "|" + c.kind + "|" + c.key
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeSTIXCursor(value string) (*stixCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	parts := strings.SplitN(string(raw), "|", 3)
+	if len(parts) != 3 {
+		return nil, errInvalidCursor
+	}
+	lastSeen, err := time.Parse(stixCursorTime, parts[0])
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	switch parts[1] {
+	case "domain", "path", "email", "phone":
+	default:
+		return nil, errInvalidCursor
+	}
+	return &stixCursor{lastSeen: lastSeen, kind: parts[1], key: parts[2]}, nil
+}
+
+// firstQuery primer parámetro de la query con valor
+func firstQuery(r *http.Request, keys ...string) string {
+	for _, key := range keys {
+		if value := r.URL.Query().Get(key); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// uuidV5 UUID versión 5 (SHA-1) de name en namespace (RFC 4122)
+func uuidV5(namespace [16]byte, name string) string {
+	h := sha1.New()
+	h.Write(namespace[:])
+	h.Write([]byte(name))
+	b := h.Sum(nil)[:16]
+	b[6] = (b[6] & 0x0f) | 0x50
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+func mustParseUUID(value string) [16]byte {
+	var id [16]byte
+	b, err := hex.DecodeString(strings.ReplaceAll(value, "-", ""))
+	if err != nil || len(b) != len(id) {
+		panic("invalid UUID: " + value)
+	}
+	copy(id[:], b)
+	return id
+}
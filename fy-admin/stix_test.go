@@ -0,0 +1,374 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Reglas del esquema de STIX 2.1 (indicator.json y common) que comprueban los tests
+var (
+	stixIdentifierRegex = regexp.MustCompile(`^indicator--[0-9a-f]{8}-[0-9a-f]{4}-[1-5][0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	stixTimestampRegex  = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`)
+	stixPropertyRegex   = regexp.MustCompile(`^[a-z0-9_]{3,250}$`) // Nombres de propiedad (id es la única excepción)
+	// Comparación de igualdad sobre value con literales entre comillas simples (\' y \\ escapados)
+	stixPatternRegex = regexp.MustCompile(`^\[([a-z0-9-]+):value = '((?:[^'\\]|\\['\\])*)'(?: OR ([a-z0-9-]+):value = '((?:[^'\\]|\\['\\])*)')?\]$`)
+
+	stixIndicatorTypeOV = map[string]bool{
+		"anomalous-activity": true, "anonymization": true, "benign": true, "compromised": true,
+		"malicious-activity": true, "attribution": true, "unknown": true,
+	}
+	stixRequiredProperties = []string{"type", "spec_version", "id", "created", "modified", "pattern", "pattern_type", "valid_from"}
+)
+
+// validateSTIXIndicator comprueba obj (JSON decodificado) contra el esquema de indicator de STIX 2.1
+func validateSTIXIndicator(t *testing.T, obj map[string]interface{}) {
+	t.Helper()
+	for _, property := range stixRequiredProperties {
+		if _, ok := obj[property]; !ok {
+			t.Errorf("%v: missing required property %q", obj["id"], property)
+		}
+	}
+	for property := range obj {
+		if property != "id" && !stixPropertyRegex.MatchString(property) {
+			t.Errorf("%v: invalid property name %q", obj["id"], property)
+		}
+	}
+
+	str := func(key string) string { s, _ := obj[key].(string); return s }
+	if str("type") != "indicator" || str("spec_version") != "2.1" {
+		t.Errorf("type/spec_version = %q/%q", str("type"), str("spec_version"))
+	}
+	if !stixIdentifierRegex.MatchString(str("id")) {
+		t.Errorf("id %q is not indicator--<RFC 4122 UUID>", str("id"))
+	}
+	for _, key := range []string{"created", "modified", "valid_from"} {
+		if !stixTimestampRegex.MatchString(str(key)) {
+			t.Errorf("%s %q is not a millisecond-precision UTC timestamp", key, str(key))
+		}
+	}
+	if str("modified") < str("created") {
+		t.Errorf("modified %s before created %s", str("modified"), str("created"))
+	}
+	if str("pattern_type") != "stix" || str("pattern_version") != "2.1" {
+		t.Errorf("pattern_type/pattern_version = %q/%q", str("pattern_type"), str("pattern_version"))
+	}
+	if !stixPatternRegex.MatchString(str("pattern")) {
+		t.Errorf("pattern %q does not parse", str("pattern"))
+	}
+
+	confidence, ok := obj["confidence"].(float64)
+	if !ok || confidence < 0 || confidence > 100 || confidence != float64(int(confidence)) {
+		t.Errorf("confidence %v is not an integer in 0-100", obj["confidence"])
+	}
+	types, _ := obj["indicator_types"].([]interface{})
+	if len(types) == 0 {
+		t.Error("indicator_types is empty")
+	}
+	for _, v := range types {
+		if s, _ := v.(string); !stixIndicatorTypeOV[s] {
+			t.Errorf("indicator_types %v not in indicator-type-ov", v)
+		}
+	}
+	if labels, _ := obj["labels"].([]interface{}); len(labels) == 0 {
+		t.Error("labels is empty")
+	}
+	refs, _ := obj["external_references"].([]interface{})
+	for _, ref := range refs {
+		if m, _ := ref.(map[string]interface{}); m["source_name"] == "" || m["source_name"] == nil {
+			t.Errorf("external reference without source_name: %v", ref)
+		}
+	}
+}
+
+func sampleSTIXRows() []stixRow {
+	first := time.Date(2024, 3, 1, 8, 30, 0, 123456000, time.UTC)
+	last := first.Add(36 * time.Hour)
+	return []stixRow{
+		{kind: "domain", key: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08", value: "bbva-clientes.xyz",
+			threatType: "phishing", confidence: 95, severity: "high", source: "urlhaus", firstSeen: first, lastSeen: last},
+		{kind: "path", key: "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752", value: "correos-pagos.top/track/o'brien",
+			threatType: "malware", confidence: 140, severity: "critical", source: "phishtank", firstSeen: first, lastSeen: last},
+		{kind: "email", key: "fd61a03af4f77d870fc21e05e7e80678095c92d808cfb3b5c279ee04c74aca13", value: `soporte\banco@estafa.es`,
+			threatType: "spam", confidence: -5, severity: "low", source: "stopforumspam", firstSeen: first, lastSeen: first.Add(-time.Hour)},
+		{kind: "phone", key: "+34806123456", value: "+34806123456",
+			threatType: "other", confidence: 60, severity: "medium", source: "manual", firstSeen: first, lastSeen: last},
+	}
+}
+
+// Ida y vuelta: cada tipo de indicador se serializa, valida contra el esquema y se decodifica igual
+func TestSTIXIndicatorRoundTrip(t *testing.T) {
+	for _, row := range sampleSTIXRows() {
+		t.Run(row.kind, func(t *testing.T) {
+			indicator := row.indicator()
+			data, err := json.Marshal(indicator)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var obj map[string]interface{}
+			if err := json.Unmarshal(data, &obj); err != nil {
+				t.Fatal(err)
+			}
+			validateSTIXIndicator(t, obj)
+
+			var decoded STIXIndicator
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, indicator) {
+				t.Errorf("round trip changed the indicator:\n got %+v\nwant %+v", decoded, indicator)
+			}
+		})
+	}
+}
+
+func TestSTIXIndicatorMapping(t *testing.T) {
+	rows := sampleSTIXRows()
+	tests := []struct {
+		pattern        string
+		indicatorType  string
+		confidence     int
+		validFrom      string
+		modified       string
+		externalSource string
+	}{
+		{`[domain-name:value = 'bbva-clientes.xyz']`, "malicious-activity", 95, "2024-03-01T08:30:00.123Z", "2024-03-02T20:30:00.123Z", "urlhaus"},
+		{`[url:value = 'http://correos-pagos.top/track/o\'brien' OR url:value = 'https://correos-pagos.top/track/o\'brien']`, "malicious-activity", 100, "2024-03-01T08:30:00.123Z", "2024-03-02T20:30:00.123Z", "phishtank"},
+		{`[email-addr:value = 'soporte\\banco@estafa.es']`, "anomalous-activity", 0, "2024-03-01T08:30:00.123Z", "2024-03-01T08:30:00.123Z", "stopforumspam"},
+		{`[x-trackfy-phone-number:value = '+34806123456']`, "unknown", 60, "2024-03-01T08:30:00.123Z", "2024-03-02T20:30:00.123Z", "manual"},
+	}
+	for i, tt := range tests {
+		got := rows[i].indicator()
+		if got.Pattern != tt.pattern {
+			t.Errorf("%s pattern = %s, want %s", rows[i].kind, got.Pattern, tt.pattern)
+		}
+		if got.IndicatorTypes[0] != tt.indicatorType || got.Confidence != tt.confidence {
+			t.Errorf("%s indicator_types/confidence = %v/%d, want %s/%d", rows[i].kind, got.IndicatorTypes, got.Confidence, tt.indicatorType, tt.confidence)
+		}
+		if got.ValidFrom != tt.validFrom || got.Created != tt.validFrom || got.Modified != tt.modified {
+			t.Errorf("%s valid_from/created/modified = %s/%s/%s", rows[i].kind, got.ValidFrom, got.Created, got.Modified)
+		}
+		if got.Labels[0] != rows[i].threatType || got.ExternalReferences[0].SourceName != tt.externalSource {
+			t.Errorf("%s labels/source = %v/%v", rows[i].kind, got.Labels, got.ExternalReferences)
+		}
+	}
+}
+
+func TestSTIXDeterministicIDs(t *testing.T) {
+	rows := sampleSTIXRows()
+	seen := map[string]string{}
+	for _, row := range rows {
+		id := row.indicator().ID
+
+		// Re-exportar el mismo registro, aunque haya cambiado, conserva el ID
+		changed := row
+		changed.confidence, changed.lastSeen = 10, row.lastSeen.Add(72*time.Hour)
+		if again := changed.indicator().ID; again != id {
+			t.Errorf("%s: re-export ID %s, want %s", row.kind, again, id)
+		}
+		if other, dup := seen[id]; dup {
+			t.Errorf("%s and %s share ID %s", row.kind, other, id)
+		}
+		seen[id] = row.kind
+	}
+
+	// Vector conocido de UUIDv5 (RFC 4122, namespace DNS)
+	dns := mustParseUUID("6ba7b810-9dad-11d1-80b4-00c04fd430c8")
+	if got := uuidV5(dns, "www.example.com"); got != "2ed6657d-e927-568b-95e1-2665a8aea6a2" {
+		t.Errorf("uuidV5 = %s", got)
+	}
+}
+
+func TestSTIXCursor(t *testing.T) {
+	c := stixCursor{lastSeen: time.Date(2024, 3, 2, 20, 30, 0, 123456000, time.UTC), kind: "path", key: "abc|def"}
+	got, err := decodeSTIXCursor(encodeSTIXCursor(c))
+	if err != nil || !got.lastSeen.Equal(c.lastSeen) || got.kind != c.kind || got.key != c.key {
+		t.Errorf("cursor round trip = %+v, %v", got, err)
+	}
+	for _, bad := range []string{"%%%", "bm9waXBlcw", encodeSTIXCursor(stixCursor{kind: "user", key: "1"})} {
+		if _, err := decodeSTIXCursor(bad); err == nil {
+			t.Errorf("decodeSTIXCursor(%q) accepted", bad)
+		}
+	}
+}
+
+// stixExportDB responde a stixIndicatorsQuery con rows, aplicando since/cursor y LIMIT
+type stixExportDB struct {
+	rows []stixRow
+}
+
+var stixLimitRegex = regexp.MustCompile(`LIMIT (\d+)`)
+
+func (c *stixExportDB) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *stixExportDB) Driver() driver.Driver                        { return nil }
+func (c *stixExportDB) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *stixExportDB) Close() error              { return nil }
+func (c *stixExportDB) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *stixExportDB) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	limit, _ := strconv.Atoi(stixLimitRegex.FindStringSubmatch(query)[1])
+	after, err := time.Parse(stixCursorTime, args[0].Value.(string))
+	if err != nil {
+		return nil, err
+	}
+	cursorKind, cursorKey := "", ""
+	if len(args) == 3 {
+		cursorKind, cursorKey = args[1].Value.(string), args[2].Value.(string)
+	}
+
+	// ORDER BY last_seen, kind, key; sin cursor solo last_seen > since
+	sorted := append([]stixRow(nil), c.rows...)
+	sort.Slice(sorted, func(i, j int) bool { return stixRowLess(sorted[i], sorted[j].lastSeen, sorted[j].kind, sorted[j].key) })
+	result := &stixRows{}
+	for _, row := range sorted {
+		if len(args) == 3 && !stixRowLess(stixRow{lastSeen: after, kind: cursorKind, key: cursorKey}, row.lastSeen, row.kind, row.key) ||
+			len(args) == 1 && !row.lastSeen.After(after) {
+			continue
+		}
+		if len(result.values) == limit {
+			break
+		}
+		result.values = append(result.values, []driver.Value{row.kind, row.key, row.value, row.threatType,
+			int64(row.confidence), row.severity, row.source, row.firstSeen, row.lastSeen})
+	}
+	return result, nil
+}
+
+func stixRowLess(a stixRow, lastSeen time.Time, kind, key string) bool {
+	if !a.lastSeen.Equal(lastSeen) {
+		return a.lastSeen.Before(lastSeen)
+	}
+	if a.kind != kind {
+		return a.kind < kind
+	}
+	return a.key < key
+}
+
+type stixRows struct{ values [][]driver.Value }
+
+func (r *stixRows) Columns() []string {
+	return []string{"kind", "key", "value", "threat_type", "confidence", "severity", "source", "first_seen", "last_seen"}
+}
+func (r *stixRows) Close() error { return nil }
+func (r *stixRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// Paginación TAXII: recorrer todas las páginas siguiendo next trae cada indicador una vez,
+// en objetos válidos, y re-exportar desde el último next solo trae lo nuevo
+func TestServeSTIXPagination(t *testing.T) {
+	base := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	fake := &stixExportDB{}
+	for i, row := range sampleSTIXRows() {
+		for j := 0; j < 3; j++ {
+			row.key = row.key[:len(row.key)-1] + strconv.Itoa(j)
+			// Dos filas por instante: el desempate por (kind, key) no debe perder ninguna
+			row.lastSeen = base.Add(time.Duration(i*3+j)/2*time.Hour + 123456*time.Microsecond)
+			row.firstSeen = base
+			fake.rows = append(fake.rows, row)
+		}
+	}
+
+	db := sql.OpenDB(fake)
+	defer db.Close()
+	s := &Server{db: db, apiKeys: newAPIKeyStore(db)}
+	key := &apiKey{id: "partner", rateLimit: 1000}
+
+	export := func(query string) TAXIIEnvelope {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		status, _ := s.serveSTIX(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/api/export/stix?"+query, nil), key)
+		if status != http.StatusOK || rec.Header().Get("Content-Type") != stixContentType {
+			t.Fatalf("%s: status %d, content type %q, body %s", query, status, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+
+		var raw struct {
+			Objects []map[string]interface{} `json:"objects"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &raw); err != nil {
+			t.Fatal(err)
+		}
+		for _, obj := range raw.Objects {
+			validateSTIXIndicator(t, obj)
+		}
+		var envelope TAXIIEnvelope
+		if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil {
+			t.Fatal(err)
+		}
+		return envelope
+	}
+
+	seen := map[string]bool{}
+	var pages int
+	query, last := "limit=5", ""
+	for {
+		envelope := export(query)
+		pages++
+		for _, obj := range envelope.Objects {
+			if seen[obj.ID] {
+				t.Errorf("indicator %s exported twice", obj.ID)
+			}
+			seen[obj.ID] = true
+		}
+		if !envelope.More {
+			if envelope.Next != "" {
+				t.Errorf("last page has next %q", envelope.Next)
+			}
+			break
+		}
+		if pages > 10 {
+			t.Fatal("pagination does not end")
+		}
+		last = envelope.Next
+		query = "limit=5&next=" + last
+	}
+	if len(seen) != len(fake.rows) || pages != 3 {
+		t.Errorf("exported %d indicators in %d pages, want %d in 3", len(seen), pages, len(fake.rows))
+	}
+
+	// since filtra por last_seen: solo las dos filas de la última hora
+	if envelope := export("since=" + base.Add(5*time.Hour).Format(time.RFC3339)); len(envelope.Objects) != 2 {
+		t.Errorf("since export = %d objects, want 2", len(envelope.Objects))
+	}
+
+	// Un indicador modificado después del último cursor vuelve a salir con el mismo ID
+	updated := fake.rows[0]
+	updated.lastSeen = base.Add(48 * time.Hour)
+	fake.rows[0] = updated
+	envelope := export("limit=5&next=" + last)
+	var ids []string
+	for _, obj := range envelope.Objects {
+		ids = append(ids, obj.ID)
+	}
+	if !strings.Contains(strings.Join(ids, ","), updated.indicator().ID) {
+		t.Errorf("re-export after update = %v, want it to include %s", ids, updated.indicator().ID)
+	}
+
+	// Parámetros inválidos
+	for _, query := range []string{"next=%25%25", "since=yesterday"} {
+		rec := httptest.NewRecorder()
+		if status, _ := s.serveSTIX(context.Background(), rec, httptest.NewRequest(http.MethodGet, "/api/export/stix?"+query, nil), key); status != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, status)
+		}
+	}
+}
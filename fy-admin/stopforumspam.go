@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// spamEmailBatchSize emails por INSERT multi-fila en syncStopForumSpam (3 parámetros por fila)
+const spamEmailBatchSize = 500
+
+// utf8BOM marca de orden de bytes que algunos volcados llevan al inicio del CSV
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// spamEmail email de StopForumSpam pendiente de escribir
+type spamEmail struct {
+	email      string
+	domain     string
+	confidence int16
+}
+
+// errInvalidSpamEmail el registro trae un email pero sin dominio válido
+var errInvalidSpamEmail = errors.New("invalid email domain")
+
+// importSpamEmails lee el volcado de emails de StopForumSpam (email,count,lastseen) y lo
+// escribe en lotes de spamEmailBatchSize. Devuelve los emails escritos y los errores: registros
+// mal formados, emails sin dominio válido e inserts fallidos.
+func (s *Server) importSpamEmails(ctx context.Context, source string, feed io.Reader, now time.Time) (records, failed int64, err error) {
+	// encoding/csv respeta los campos entre comillas con comas dentro. Sin LazyQuotes: con ella
+	// una comilla suelta se traga el resto del volcado como un único campo; así ese registro da
+	// csv.ParseError y se sigue en la línea siguiente.
+	csvReader := csv.NewReader(skipUTF8BOM(feed))
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+
+	lineNum := 0
+	batch := newSpamEmailBatch()
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return records, failed, err
+		}
+
+		parts, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// Un registro mal formado no invalida el resto del volcado
+			var parseErr *csv.ParseError
+			if errors.As(err, &parseErr) {
+				failed++
+				continue
+			}
+			return records, failed, fmt.Errorf("failed to read feed: %w", err)
+		}
+
+		row, ok, err := parseSpamEmail(parts)
+		if !ok {
+			continue
+		}
+
+		lineNum++
+		if lineNum%syncProgressLogInterval == 0 {
+			logSyncProgress(source, lineNum, records, failed)
+		}
+		if err != nil {
+			failed++
+			continue
+		}
+
+		if batch.add(row) {
+			written, batchFailed := s.flushSpamEmails(ctx, batch, now)
+			records += written
+			failed += batchFailed
+		}
+
+		if lineNum%10000 == 0 {
+			s.updateSyncStatus(source, true, fmt.Sprintf("Imported %d emails...", records))
+		}
+	}
+
+	written, batchFailed := s.flushSpamEmails(ctx, batch, now)
+	return records + written, failed + batchFailed, nil
+}
+
+// parseSpamEmail email normalizado de un registro del volcado. ok=false si el registro se
+// descarta sin contarlo (vacío, sin @ o con basura); err si es un email sin dominio válido.
+func parseSpamEmail(parts []string) (row spamEmail, ok bool, err error) {
+	if len(parts) == 0 {
+		return spamEmail{}, false, nil
+	}
+
+	email := cleanEmail(strings.ToLower(strings.TrimSpace(parts[0])))
+	if email == "" || !strings.Contains(email, "@") || len(email) < 5 || len(email) > 254 {
+		return spamEmail{}, false, nil
+	}
+
+	// Datos corruptos del volcado
+	if strings.Contains(email, "%") || strings.Contains(email, "spinfile") ||
+		strings.Contains(email, " ") || strings.Contains(email, "\t") {
+		return spamEmail{}, false, nil
+	}
+
+	atIndex := strings.LastIndex(email, "@")
+	if atIndex < 1 {
+		return spamEmail{}, true, errInvalidSpamEmail
+	}
+	domain := email[atIndex+1:]
+	if !strings.Contains(domain, ".") {
+		return spamEmail{}, true, errInvalidSpamEmail
+	}
+
+	// Confianza según el número de reportes, si viene
+	confidence := int16(70)
+	if len(parts) >= 2 && parts[1] != "" {
+		var count int
+		fmt.Sscanf(strings.TrimSpace(parts[1]), "%d", &count)
+		if count > 100 {
+			confidence = 95
+		} else if count > 50 {
+			confidence = 90
+		} else if count > 10 {
+			confidence = 80
+		}
+	}
+
+	return spamEmail{email: email, domain: domain, confidence: confidence}, true, nil
+}
+
+// spamEmailBatch acumula emails hasta spamEmailBatchSize. Un mismo INSERT ... ON CONFLICT
+// DO UPDATE no puede tocar dos veces la misma fila, así que los repetidos dentro del lote
+// se fusionan (mayor confianza).
+type spamEmailBatch struct {
+	rows  []spamEmail
+	index map[string]int
+}
+
+func newSpamEmailBatch() *spamEmailBatch {
+	return &spamEmailBatch{
+		rows:  make([]spamEmail, 0, spamEmailBatchSize),
+		index: make(map[string]int, spamEmailBatchSize),
+	}
+}
+
+// add añade un email; devuelve true cuando el lote está lleno
+func (b *spamEmailBatch) add(row spamEmail) bool {
+	if i, ok := b.index[row.email]; ok {
+		if row.confidence > b.rows[i].confidence {
+			b.rows[i].confidence = row.confidence
+		}
+		return false
+	}
+	b.index[row.email] = len(b.rows)
+	b.rows = append(b.rows, row)
+	return len(b.rows) >= spamEmailBatchSize
+}
+
+func (b *spamEmailBatch) reset() {
+	b.rows = b.rows[:0]
+	clear(b.index)
+}
+
+// flushSpamEmails escribe el lote con un solo INSERT multi-fila; si falla se reintenta
+// fila a fila para no perder el resto del lote. Devuelve filas escritas y fallidas.
+func (s *Server) flushSpamEmails(ctx context.Context, batch *spamEmailBatch, now time.Time) (written, failed int64) {
+	if len(batch.rows) == 0 {
+		return 0, 0
+	}
+	defer batch.reset()
+
+	if err := s.insertSpamEmails(ctx, batch.rows, now); err == nil {
+		return int64(len(batch.rows)), 0
+	}
+
+	for i := range batch.rows {
+		if err := s.insertSpamEmails(ctx, batch.rows[i:i+1], now); err != nil {
+			failed++
+			continue
+		}
+		written++
+	}
+	return written, failed
+}
+
+// insertSpamEmails INSERT ... VALUES (...),(...) ON CONFLICT para rows ($1 es now).
+// La forma canónica (email_normalized_hash) la calcula el trigger de threat_emails.
+func (s *Server) insertSpamEmails(ctx context.Context, rows []spamEmail, now time.Time) error {
+	var query strings.Builder
+	query.WriteString(`
+		INSERT INTO threat_emails (email_hash, email, domain_hash, threat_type, severity, confidence, source, first_seen, last_seen, flags)
+		VALUES `)
+
+	args := make([]interface{}, 0, 1+3*len(rows))
+	args = append(args, now)
+	for i, row := range rows {
+		if i > 0 {
+			query.WriteString(",")
+		}
+		n := len(args)
+		fmt.Fprintf(&query, "(sha256_bytea($%d), $%d, sha256_bytea($%d), 'spam'::threat_type_enum, 'medium'::severity_enum, $%d, 'osint'::source_enum, $1, $1, %s)",
+			n+1, n+1, n+2, n+3, flagActive.sql())
+		args = append(args, row.email, row.domain, row.confidence)
+	}
+
+	query.WriteString(`
+		ON CONFLICT (email_hash) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			report_count = threat_emails.report_count + 1,
+			confidence = GREATEST(threat_emails.confidence, EXCLUDED.confidence)
+	`)
+
+	_, err := s.exec(ctx, query.String(), args...)
+	return err
+}
+
+// skipUTF8BOM descarta el BOM inicial: sin esto el primer email llega con U+FEFF delante
+func skipUTF8BOM(r io.Reader) io.Reader {
+	br := bufio.NewReader(r)
+	if prefix, err := br.Peek(len(utf8BOM)); err == nil && bytes.Equal(prefix, utf8BOM) {
+		br.Discard(len(utf8BOM))
+	}
+	return br
+}
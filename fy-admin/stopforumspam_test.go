@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// spamEmailsDB guarda los INSERT de threat_emails (args: now y luego email, domain, confidence
+// por fila)
+type spamEmailsDB struct {
+	statements int
+	rows       []spamEmail
+}
+
+func (c *spamEmailsDB) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *spamEmailsDB) Driver() driver.Driver                        { return nil }
+func (c *spamEmailsDB) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+func (c *spamEmailsDB) Close() error              { return nil }
+func (c *spamEmailsDB) Begin() (driver.Tx, error) { return nil, errors.New("not supported") }
+
+func (c *spamEmailsDB) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if !strings.Contains(query, "INSERT INTO threat_emails") {
+		return nil, fmt.Errorf("unexpected query: %s", query)
+	}
+	c.statements++
+	for i := 1; i+2 < len(args); i += 3 {
+		c.rows = append(c.rows, spamEmail{
+			email:      args[i].Value.(string),
+			domain:     args[i+1].Value.(string),
+			confidence: int16(args[i+2].Value.(int64)),
+		})
+	}
+	return driver.RowsAffected((len(args) - 1) / 3), nil
+}
+
+func newSpamEmailsServer(t testing.TB, fake *spamEmailsDB) *Server {
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+	return &Server{db: db, writeTimeout: time.Minute, syncStatus: map[string]*SyncProgress{}}
+}
+
+func TestImportSpamEmails(t *testing.T) {
+	tests := []struct {
+		name        string
+		feed        string
+		wantRecords int64
+		wantErrors  int64
+		wantRows    []spamEmail
+	}{
+		{
+			name:        "UTF-8 BOM",
+			feed:        "\xEF\xBB\xBFspam@example.com,3,2024-01-01\nbot@mailer.net,60,2024-01-02\n",
+			wantRecords: 2,
+			wantRows: []spamEmail{
+				{email: "spam@example.com", domain: "example.com", confidence: 70},
+				{email: "bot@mailer.net", domain: "mailer.net", confidence: 90},
+			},
+		},
+		{
+			name:        "quoted fields with commas",
+			feed:        "\"Spam@Example.com\",\"120\",\"2024-01-01, 10:00\"\n\"a,b@example.com\",\"12\",\"2024-01-01\"\n",
+			wantRecords: 2,
+			wantRows: []spamEmail{
+				{email: "spam@example.com", domain: "example.com", confidence: 95},
+				{email: "a,b@example.com", domain: "example.com", confidence: 80},
+			},
+		},
+		{
+			name:        "malformed records are errors",
+			feed:        "bad\"quote@example.com,5\n\"unclosed\"x@example.com,5\nroot@localhost,5\n@x.io,5\nok@example.com,5\n",
+			wantRecords: 1,
+			wantErrors:  4,
+			wantRows:    []spamEmail{{email: "ok@example.com", domain: "example.com", confidence: 70}},
+		},
+		{
+			name:        "skipped lines",
+			feed:        "\n%20x@example.com,5\nnot-an-email,5\na@b,5\n",
+			wantRecords: 0,
+			wantErrors:  0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &spamEmailsDB{}
+			s := newSpamEmailsServer(t, fake)
+
+			records, failed, err := s.importSpamEmails(context.Background(), "emails", strings.NewReader(tt.feed), time.Now())
+			if err != nil {
+				t.Fatalf("importSpamEmails: %v", err)
+			}
+			if records != tt.wantRecords || failed != tt.wantErrors {
+				t.Errorf("records %d, errors %d; want %d, %d", records, failed, tt.wantRecords, tt.wantErrors)
+			}
+			if len(fake.rows) != len(tt.wantRows) {
+				t.Fatalf("inserted %+v, want %+v", fake.rows, tt.wantRows)
+			}
+			for i, want := range tt.wantRows {
+				if fake.rows[i] != want {
+					t.Errorf("row %d = %+v, want %+v", i, fake.rows[i], want)
+				}
+			}
+		})
+	}
+}
+
+// Los repetidos dentro de un lote se fusionan (ON CONFLICT DO UPDATE no puede tocar la misma
+// fila dos veces en un INSERT) y se quedan con la mayor confianza
+func TestImportSpamEmailsMergesDuplicatesInBatch(t *testing.T) {
+	var feed strings.Builder
+	for i := 0; i < spamEmailBatchSize-10; i++ {
+		fmt.Fprintf(&feed, "user%d@example.com,1,2024-01-01\n", i)
+	}
+	for i := 0; i < 10; i++ {
+		fmt.Fprintf(&feed, "USER%d@example.com,200,2024-01-02\n", i)
+	}
+
+	fake := &spamEmailsDB{}
+	s := newSpamEmailsServer(t, fake)
+	records, failed, err := s.importSpamEmails(context.Background(), "emails", strings.NewReader(feed.String()), time.Now())
+	if err != nil {
+		t.Fatalf("importSpamEmails: %v", err)
+	}
+
+	// 500 registros, 490 emails distintos: un solo INSERT al final
+	if records != spamEmailBatchSize-10 || failed != 0 || fake.statements != 1 {
+		t.Fatalf("records %d, errors %d, statements %d; want %d, 0, 1", records, failed, fake.statements, spamEmailBatchSize-10)
+	}
+	for i, row := range fake.rows {
+		want := int16(70)
+		if i < 10 {
+			want = 95
+		}
+		if row.email != fmt.Sprintf("user%d@example.com", i) || row.confidence != want {
+			t.Errorf("row %d = %+v, want confidence %d", i, row, want)
+		}
+	}
+}
+
+func spamEmailsFeed(n int) string {
+	var feed strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&feed, "spammer%d@example%d.com,%d,2024-01-01\n", i, i%100, i%150)
+	}
+	return feed.String()
+}
+
+// importSpamEmailsPerRow la importación anterior: un INSERT por email
+func (s *Server) importSpamEmailsPerRow(ctx context.Context, feed io.Reader, now time.Time) (records int64) {
+	csvReader := csv.NewReader(skipUTF8BOM(feed))
+	csvReader.FieldsPerRecord = -1
+	for {
+		parts, err := csvReader.Read()
+		if err != nil {
+			return records
+		}
+		if row, ok, err := parseSpamEmail(parts); ok && err == nil {
+			if s.insertSpamEmails(ctx, []spamEmail{row}, now) == nil {
+				records++
+			}
+		}
+	}
+}
+
+// BenchmarkImportSpamEmails lotes de spamEmailBatchSize frente a un INSERT por email. La BD
+// falsa no tiene red: mide construir y ejecutar las sentencias; stmts/op son los viajes a
+// PostgreSQL que costaría cada importación.
+func BenchmarkImportSpamEmails(b *testing.B) {
+	const emails = 5000
+	feed := spamEmailsFeed(emails)
+
+	for _, mode := range []string{"batched", "per-row"} {
+		b.Run(mode, func(b *testing.B) {
+			fake := &spamEmailsDB{}
+			s := newSpamEmailsServer(b, fake)
+			ctx := context.Background()
+			now := time.Now()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				fake.rows = fake.rows[:0]
+				var records int64
+				if mode == "batched" {
+					records, _, _ = s.importSpamEmails(ctx, "emails", strings.NewReader(feed), now)
+				} else {
+					records = s.importSpamEmailsPerRow(ctx, strings.NewReader(feed), now)
+				}
+				if records != emails {
+					b.Fatalf("records %d, want %d", records, emails)
+				}
+			}
+			b.ReportMetric(float64(fake.statements)/float64(b.N), "stmts/op")
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// subscriptionStatsDays días de la serie de altas y cancelaciones
+const subscriptionStatsDays = 60
+
+// entitledSubscriptionSQL usuarios con acceso premium (models.Subscription.Entitled de api-gateway)
+const entitledSubscriptionSQL = `(subscription_status IN ('trialing', 'active', 'past_due') OR (subscription_status IS NULL AND COALESCE(is_premium, false)))`
+
+// SubscriptionCounts usuarios por estado de la suscripción
+type SubscriptionCounts struct {
+	Premium    int64 `json:"premium"` // Con acceso premium (trialing + active + past_due + legacy)
+	Active     int64 `json:"active"`
+	Trialing   int64 `json:"trialing"`
+	PastDue    int64 `json:"past_due"`
+	Cancelling int64 `json:"cancelling"` // Canceladas con acceso hasta fin de periodo
+	Cancelled  int64 `json:"cancelled"`
+	Legacy     int64 `json:"legacy"` // is_premium sin datos de Stripe
+}
+
+// SubscriptionDay altas y cancelaciones de un día
+type SubscriptionDay struct {
+	Date          string `json:"date"` // YYYY-MM-DD
+	New           int64  `json:"new"`
+	Cancellations int64  `json:"cancellations"`
+}
+
+// CurrencyRevenue ingresos de una moneda en céntimos. Las monedas no se suman entre sí.
+type CurrencyRevenue struct {
+	Currency   string `json:"currency"`
+	TotalCents int64  `json:"total_cents"`
+	MonthCents int64  `json:"month_cents"` // Mes en curso
+}
+
+// SubscriptionStats estadísticas de negocio del panel
+type SubscriptionStats struct {
+	Subscriptions          SubscriptionCounts `json:"subscriptions"`
+	Daily                  []SubscriptionDay  `json:"daily"`
+	CancellationsThisMonth int64              `json:"cancellations_this_month"`
+	Revenue                []CurrencyRevenue  `json:"revenue"`
+	PaymentFailures7d      int64              `json:"payment_failures_7d"`
+	GeneratedAt            time.Time          `json:"generated_at"`
+}
+
+// handleSubscriptionStats suscripciones, ingresos y pagos fallidos (BD de api-gateway).
+// Requiere un token de ADMIN_TOKENS con el permiso financial.
+// GET /api/stats/subscriptions
+func (s *Server) handleSubscriptionStats(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.gatewayDB == nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Gateway database not configured (GATEWAY_DATABASE_URL)"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	stats, err := s.subscriptionStats(ctx)
+	if err != nil {
+		ctxLogger(r.Context()).Error().Err(err).Msg("[Stats] Failed to get subscription stats")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Failed to get subscription stats"})
+		return
+	}
+
+	json.NewEncoder(w).Encode(stats)
+}
+
+// subscriptionStats consulta la BD de api-gateway. A diferencia de las estadísticas de amenazas
+// un error no deja el campo a cero: un dato financiero incompleto se confundiría con uno real.
+func (s *Server) subscriptionStats(ctx context.Context) (*SubscriptionStats, error) {
+	stats := &SubscriptionStats{
+		Daily:       []SubscriptionDay{},
+		Revenue:     []CurrencyRevenue{},
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	c := &stats.Subscriptions
+	err := s.gatewayDB.QueryRowContext(ctx, `
+		SELECT
+			COUNT(*) FILTER (WHERE `+entitledSubscriptionSQL+`),
+			COUNT(*) FILTER (WHERE subscription_status = 'active' AND NOT subscription_cancel_at_period_end),
+			COUNT(*) FILTER (WHERE subscription_status = 'trialing'),
+			COUNT(*) FILTER (WHERE subscription_status = 'past_due'),
+			COUNT(*) FILTER (WHERE subscription_status IN ('trialing', 'active', 'past_due') AND subscription_cancel_at_period_end),
+			COUNT(*) FILTER (WHERE subscription_status = 'canceled'),
+			COUNT(*) FILTER (WHERE subscription_status IS NULL AND COALESCE(is_premium, false)),
+			COUNT(*) FILTER (WHERE subscription_canceled_at >= date_trunc('month', NOW()))
+		FROM users
+		WHERE deleted_at IS NULL
+	`).Scan(&c.Premium, &c.Active, &c.Trialing, &c.PastDue, &c.Cancelling, &c.Cancelled, &c.Legacy, &stats.CancellationsThisMonth)
+	if err != nil {
+		return nil, err
+	}
+
+	// Serie diaria con todos los días (los días sin movimientos a 0)
+	rows, err := s.gatewayDB.QueryContext(ctx, `
+		WITH days AS (
+			SELECT generate_series(date_trunc('day', NOW()) - ($1 - 1) * INTERVAL '1 day', date_trunc('day', NOW()), INTERVAL '1 day') AS day
+		), started AS (
+			SELECT date_trunc('day', subscription_started_at) AS day, COUNT(*) AS n
+			FROM users
+			WHERE subscription_started_at >= date_trunc('day', NOW()) - ($1 - 1) * INTERVAL '1 day'
+			GROUP BY 1
+		), cancelled AS (
+			SELECT date_trunc('day', subscription_canceled_at) AS day, COUNT(*) AS n
+			FROM users
+			WHERE subscription_canceled_at >= date_trunc('day', NOW()) - ($1 - 1) * INTERVAL '1 day'
+			GROUP BY 1
+		)
+		SELECT to_char(d.day, 'YYYY-MM-DD'), COALESCE(s.n, 0), COALESCE(c.n, 0)
+		FROM days d
+		LEFT JOIN started s ON s.day = d.day
+		LEFT JOIN cancelled c ON c.day = d.day
+		ORDER BY d.day
+	`, subscriptionStatsDays)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day SubscriptionDay
+		if err := rows.Scan(&day.Date, &day.New, &day.Cancellations); err != nil {
+			return nil, err
+		}
+		stats.Daily = append(stats.Daily, day)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ingresos por moneda: los importes son céntimos de su moneda y no se convierten
+	revenueRows, err := s.gatewayDB.QueryContext(ctx, `
+		SELECT currency, SUM(amount_cents),
+		       COALESCE(SUM(amount_cents) FILTER (WHERE created_at >= date_trunc('month', NOW())), 0)
+		FROM payments
+		WHERE status = 'succeeded'
+		GROUP BY currency
+		ORDER BY currency
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer revenueRows.Close()
+	for revenueRows.Next() {
+		var rev CurrencyRevenue
+		if err := revenueRows.Scan(&rev.Currency, &rev.TotalCents, &rev.MonthCents); err != nil {
+			return nil, err
+		}
+		stats.Revenue = append(stats.Revenue, rev)
+	}
+	if err := revenueRows.Err(); err != nil {
+		return nil, err
+	}
+
+	err = s.gatewayDB.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM payments
+		WHERE status = 'failed' AND created_at >= NOW() - INTERVAL '7 days'
+	`).Scan(&stats.PaymentFailures7d)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	syncRetryInitialDelay = 30 * time.Second
+	syncRetryMultiplier   = 2
+	syncRetryMaxDelay     = 10 * time.Minute
+	syncRetryMaxAttempts  = 5
+	// syncAttemptTimeout límite de cada intento (el total incluye las esperas entre intentos)
+	syncAttemptTimeout = 10 * time.Minute
+)
+
+// syncSourceEnums valor de source_enum en sync_status de las fuentes del panel que no son
+// feeds (migraciones 021 y 027 para los teléfonos)
+var syncSourceEnums = map[string]string{
+	"emails":     "osint",
+	"phones":     "listahu_phones",
+	"phones_sfs": "sfs_phones",
+}
+
+// syncSourceEnum valor de source_enum de una fuente: el de su feed o el de syncSourceEnums
+func (s *Server) syncSourceEnum(source string) (string, bool) {
+	if feed, ok := s.feed(source); ok {
+		return feed.source.Threat().sourceEnum, true
+	}
+	sourceEnum, ok := syncSourceEnums[source]
+	return sourceEnum, ok
+}
+
+// RetryableSync ejecuta una sincronización reintentándola con backoff exponencial
+// (30s, 60s, 120s... máx 10min) hasta syncRetryMaxAttempts intentos.
+// Tras el último fallo guarda el error en sync_status.last_error.
+func (s *Server) RetryableSync(ctx context.Context, source string, syncFn func(ctx context.Context) error) error {
+	s.resetSyncRetries(source, correlationID(ctx))
+	logger := ctxLogger(ctx)
+	logger.Info().Str("source", source).Msg("[Sync] Started")
+
+	delay := syncRetryInitialDelay
+	var err error
+
+	for attempt := 1; attempt <= syncRetryMaxAttempts; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, syncAttemptTimeout)
+		err = syncFn(attemptCtx)
+		cancel()
+
+		if err == nil {
+			s.clearSyncError(ctx, source)
+			return nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		logger.Warn().Err(err).
+			Str("source", source).
+			Int("attempt", attempt).
+			Int("max_attempts", syncRetryMaxAttempts).
+			Msg("[Sync] Attempt failed")
+		if attempt == syncRetryMaxAttempts {
+			break
+		}
+
+		s.markSyncRetry(source, attempt, fmt.Sprintf("Attempt %d/%d failed: %v. Retrying in %v...", attempt, syncRetryMaxAttempts, err, delay))
+
+		select {
+		case <-ctx.Done():
+			s.updateSyncStatus(source, false, "Sync cancelled: "+ctx.Err().Error())
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+
+		delay *= syncRetryMultiplier
+		if delay > syncRetryMaxDelay {
+			delay = syncRetryMaxDelay
+		}
+	}
+
+	logger.Error().Err(err).Str("source", source).Int("attempts", syncRetryMaxAttempts).Msg("[Sync] Failed after retries")
+	s.saveSyncError(source, err)
+	return err
+}
+
+// resetSyncRetries reinicia los contadores de reintentos al empezar una sincronización y
+// guarda el correlation ID de quien la lanzó ("" si es programada)
+func (s *Server) resetSyncRetries(source, correlationID string) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	if status, ok := s.syncStatus[source]; ok {
+		status.RetryCount = 0
+		status.priorErrors = 0
+		status.CorrelationID = correlationID
+	}
+}
+
+// markSyncRetry registra un intento fallido: acumula sus errores y mantiene la fuente en progreso
+func (s *Server) markSyncRetry(source string, attempt int, message string) {
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	if status, ok := s.syncStatus[source]; ok {
+		status.RetryCount = attempt
+		status.priorErrors = status.Errors
+		status.InProgress = true
+		status.Message = message
+	}
+}
+
+// saveSyncError guarda el error final en sync_status (las fuentes sin fila se omiten)
+func (s *Server) saveSyncError(source string, syncErr error) {
+	sourceEnum, ok := s.syncSourceEnum(source)
+	if !ok || s.db == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO sync_status (source, last_sync, last_error)
+		VALUES ($1::source_enum, NOW(), $2)
+		ON CONFLICT (source) DO UPDATE SET
+			last_sync = NOW(),
+			last_error = $2
+	`, sourceEnum, syncErr.Error())
+	if err != nil {
+		log.Error().Err(err).Str("source", source).Msg("[Sync] Failed to save last_error")
+	}
+}
+
+// clearSyncError limpia el error de una fuente tras una sincronización correcta
+func (s *Server) clearSyncError(ctx context.Context, source string) {
+	sourceEnum, ok := s.syncSourceEnum(source)
+	if !ok || s.db == nil {
+		return
+	}
+	s.exec(ctx, `UPDATE sync_status SET last_error = NULL WHERE source = $1::source_enum`, sourceEnum)
+}
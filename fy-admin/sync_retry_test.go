@@ -0,0 +1,20 @@
+package main
+
+import "testing"
+
+func TestSyncSourceEnums(t *testing.T) {
+	// Toda sincronización que se puede forzar tiene fila en sync_status para su error final
+	s := &Server{feeds: newFeedSources(&Config{PhishStatsURL: phishStatsDefaultURL, SpamhausDBLURL: "/data/dbl.txt"})}
+	for source := range s.syncRunners() {
+		if _, ok := s.syncSourceEnum(source); !ok {
+			t.Errorf("sync %q has no sync_status source_enum", source)
+		}
+	}
+
+	want := map[string]string{"emails": "osint", "phones": "listahu_phones", "phones_sfs": "sfs_phones"}
+	for source, sourceEnum := range want {
+		if got, _ := s.syncSourceEnum(source); got != sourceEnum {
+			t.Errorf("syncSourceEnum(%q) = %q, want %q", source, got, sourceEnum)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+)
+
+// Tags de amenaza (tags/domain_tags). fy-analysis los devuelve en Tags de la amenaza de
+// LocalDB; aquí se crean, se asignan a dominios desde el panel y los importa el feed de URLhaus.
+
+// maxTagNameLength longitud de tags.name (VARCHAR(30))
+const maxTagNameLength = 30
+
+// errTagNotFound el tag no existe (hay que crearlo antes de asignarlo)
+var errTagNotFound = errors.New("tag not found")
+
+// TagRow tag con el número de dominios que lo tienen
+type TagRow struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Domains int    `json:"domains"`
+}
+
+// normalizeTagName nombre canónico de un tag: minúsculas y espacios como guiones. Solo
+// admite letras, dígitos, '-', '_' y '.'; false si queda vacío, es más largo que
+// maxTagNameLength o tiene otros caracteres.
+func normalizeTagName(name string) (string, bool) {
+	name = strings.Join(strings.Fields(strings.ToLower(name)), "-")
+	if name == "" || len(name) > maxTagNameLength {
+		return "", false
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' && c != '_' && c != '.' {
+			return "", false
+		}
+	}
+	return name, true
+}
+
+// parseTagList tags de una lista separada por comas (columna tags de URLhaus), normalizados
+// y sin duplicados en el orden de la lista. Los que no son válidos se descartan.
+func parseTagList(list string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, raw := range strings.Split(list, ",") {
+		name, ok := normalizeTagName(raw)
+		if !ok || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+// tagIDs ids de los tags por nombre. Con create se insertan los que falten; sin create los
+// que no existen no aparecen en el resultado. Se consulta antes de insertar porque
+// tags.id es SMALLSERIAL y un INSERT ... ON CONFLICT consume secuencia aunque no inserte:
+// el feed de URLhaus repite los mismos tags cada pocos minutos.
+func (s *Server) tagIDs(ctx context.Context, names []string, create bool) (map[string]int16, error) {
+	ids := make(map[string]int16, len(names))
+	if len(names) == 0 {
+		return ids, nil
+	}
+
+	load := func() error {
+		rows, err := s.db.QueryContext(ctx, `SELECT id, name FROM tags WHERE name = ANY($1)`, pq.Array(names))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id int16
+			var name string
+			if err := rows.Scan(&id, &name); err != nil {
+				return err
+			}
+			ids[name] = id
+		}
+		return rows.Err()
+	}
+	if err := load(); err != nil {
+		return nil, err
+	}
+	if !create || len(ids) == len(names) {
+		return ids, nil
+	}
+
+	var missing []string
+	for _, name := range names {
+		if _, ok := ids[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	// ON CONFLICT por si otra sincronización o el panel lo ha creado entretanto
+	if _, err := s.exec(ctx, `
+		INSERT INTO tags (name) SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING
+	`, pq.Array(missing)); err != nil {
+		return nil, err
+	}
+	if err := load(); err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// attachDomainTags asigna tags a un dominio de threat_domains. Retorna cuántos eran nuevos:
+// los que ya tenía no se duplican.
+func (s *Server) attachDomainTags(ctx context.Context, domain string, tagIDs []int16) (int64, error) {
+	if len(tagIDs) == 0 {
+		return 0, nil
+	}
+	ids := make([]int64, len(tagIDs))
+	for i, id := range tagIDs {
+		ids[i] = int64(id)
+	}
+	res, err := s.exec(ctx, `
+		INSERT INTO domain_tags (domain_hash, tag_id)
+		SELECT td.domain_hash, t.id
+		FROM threat_domains td
+		CROSS JOIN unnest($2::smallint[]) AS t(id)
+		WHERE td.domain_hash = sha256_bytea($1)
+		ON CONFLICT (domain_hash, tag_id) DO NOTHING
+	`, domain, pq.Array(ids))
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// detachDomainTag quita un tag de un dominio. false si no lo tenía.
+func (s *Server) detachDomainTag(ctx context.Context, domain string, tagID int16) (bool, error) {
+	res, err := s.exec(ctx, `
+		DELETE FROM domain_tags WHERE domain_hash = sha256_bytea($1) AND tag_id = $2
+	`, domain, tagID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// handleTags lista los tags (GET) o crea uno (POST {"name": "..."})
+// GET|POST /api/admin/tags
+func (s *Server) handleTags(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.listTags(w, r)
+		return
+	}
+
+	var input struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+	name, ok := normalizeTagName(input.Name)
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false,
+			"error": fmt.Sprintf("name must be 1-%d letters, digits, '-', '_' or '.'", maxTagNameLength)})
+		return
+	}
+
+	ctx, cancel := s.writeCtx(r.Context())
+	defer cancel()
+
+	existing, err := s.tagIDs(ctx, []string{name}, false)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if id, ok := existing[name]; ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "created": false, "tag": TagRow{ID: int(id), Name: name}})
+		return
+	}
+
+	ids, err := s.tagIDs(ctx, []string{name}, true)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	newValue, _ := json.Marshal(map[string]interface{}{"id": ids[name], "name": name})
+	s.auditLog(r, &AuditEntry{Action: "create_tag", Table: "tags", RecordID: name, NewValue: newValue})
+
+	log.Info().Str("tag", name).Msg("[Tags] Tag created")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "created": true, "tag": TagRow{ID: int(ids[name]), Name: name}})
+}
+
+// listTags tags con su número de dominios, los más usados primero
+func (s *Server) listTags(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT t.id, t.name, COUNT(dt.domain_hash)
+		FROM tags t
+		LEFT JOIN domain_tags dt ON dt.tag_id = t.id
+		GROUP BY t.id, t.name
+		ORDER BY COUNT(dt.domain_hash) DESC, t.name
+	`)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	tags, scanErrors, err := scanRows(rows, "tags", func(rows *sql.Rows) (TagRow, error) {
+		var row TagRow
+		err := rows.Scan(&row.ID, &row.Name, &row.Domains)
+		return row, err
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"data": tags, "total": len(tags), "scan_errors": scanErrors})
+}
+
+// handleTagDomains dominios con un tag, paginados (GET ?tag=&limit=&offset=), o asigna/quita
+// un tag a un dominio (POST {"domain": "...", "tag": "...", "attach": true|false})
+// GET|POST /api/admin/tags/domains
+func (s *Server) handleTagDomains(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		s.listTagDomains(w, r)
+		return
+	}
+
+	var input struct {
+		Domain string `json:"domain"`
+		Tag    string `json:"tag"`
+		Attach *bool  `json:"attach"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(input.Domain))
+	if domain == "" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "domain is required"})
+		return
+	}
+	tag, ok := normalizeTagName(input.Tag)
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "invalid tag"})
+		return
+	}
+	if input.Attach == nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "attach is required"})
+		return
+	}
+
+	ctx, cancel := s.writeCtx(r.Context())
+	defer cancel()
+
+	changed, err := s.setDomainTag(ctx, domain, tag, *input.Attach)
+	if err != nil {
+		message := err.Error()
+		if errors.Is(err, sql.ErrNoRows) {
+			message = "Domain not found"
+		} else if errors.Is(err, errTagNotFound) {
+			message = "Tag not found, create it first in /api/admin/tags"
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+		return
+	}
+
+	action := "attach_tag"
+	if !*input.Attach {
+		action = "detach_tag"
+	}
+	if changed {
+		newValue, _ := json.Marshal(map[string]string{"tag": tag})
+		s.auditLog(r, &AuditEntry{Action: action, Table: "domain_tags", RecordID: domain, NewValue: newValue})
+		log.Info().
+			Str("domain", domain).
+			Str("tag", tag).
+			Str("action", action).
+			Msg("[Tags] Domain tags updated")
+	}
+
+	tags, err := s.domainTags(ctx, domain)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "changed": changed, "tags": tags})
+}
+
+// setDomainTag asigna o quita un tag existente a un dominio de threat_domains. changed es
+// false si el dominio ya lo tenía (o no lo tenía, al quitarlo). sql.ErrNoRows si el dominio
+// no existe, errTagNotFound si el tag no existe.
+func (s *Server) setDomainTag(ctx context.Context, domain, tag string, attach bool) (changed bool, err error) {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT true FROM threat_domains WHERE domain_hash = sha256_bytea($1)
+	`, domain).Scan(&exists); err != nil {
+		return false, err
+	}
+
+	ids, err := s.tagIDs(ctx, []string{tag}, false)
+	if err != nil {
+		return false, err
+	}
+	id, ok := ids[tag]
+	if !ok {
+		return false, errTagNotFound
+	}
+
+	if !attach {
+		return s.detachDomainTag(ctx, domain, id)
+	}
+	n, err := s.attachDomainTags(ctx, domain, []int16{id})
+	return n > 0, err
+}
+
+// listTagDomains dominios de threat_domains con el tag ?tag=, los vistos más recientemente primero
+func (s *Server) listTagDomains(w http.ResponseWriter, r *http.Request) {
+	tag, ok := normalizeTagName(r.URL.Query().Get("tag"))
+	if !ok {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "tag is required"})
+		return
+	}
+	limit := getQueryInt(r, "limit", 50)
+	offset := getQueryInt(r, "offset", 0)
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	var total int64
+	if err := s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*)
+		FROM domain_tags dt
+		JOIN tags t ON t.id = dt.tag_id
+		WHERE t.name = $1
+	`, tag).Scan(&total); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT td.domain, td.threat_type::text, td.severity::text, td.confidence, td.source::text,
+		       td.first_seen, td.last_seen, td.hit_count, COALESCE(td.flags, 0)
+		FROM domain_tags dt
+		JOIN tags t ON t.id = dt.tag_id
+		JOIN threat_domains td ON td.domain_hash = dt.domain_hash
+		WHERE t.name = $1
+		ORDER BY td.last_seen DESC, td.domain
+		LIMIT $2 OFFSET $3
+	`, tag, limit, offset)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	domains, scanErrors, err := scanRows(rows, "threat_domains", scanDomainRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(listResponse(w, r, domains, scanErrors, total, limit, offset))
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeTagName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"Mozi", "mozi", true},
+		{"  Cobalt  Strike ", "cobalt-strike", true},
+		{"32-bit", "32-bit", true},
+		{"elf_arm.v7", "elf_arm.v7", true},
+		{"", "", false},
+		{"   ", "", false},
+		{"banco/bbva", "", false},
+		{"café", "", false},
+		{strings.Repeat("a", maxTagNameLength), strings.Repeat("a", maxTagNameLength), true},
+		{strings.Repeat("a", maxTagNameLength+1), "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeTagName(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeTagName(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseTagList(t *testing.T) {
+	got := parseTagList("elf,Mozi, ELF ,,32-bit,bad/tag,mozi")
+	if want := []string{"elf", "mozi", "32-bit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseTagList = %q, want %q", got, want)
+	}
+	if got := parseTagList(""); got != nil {
+		t.Errorf("parseTagList(\"\") = %q, want nil", got)
+	}
+}
+
+func TestURLhausFeedTags(t *testing.T) {
+	feed := urlhausFeed{}
+	record, err := feed.Parse(`"3012345","2024-03-01 10:00:00","http://Evil.example/bins/x.arm7","online","2024-03-01 10:00:00","malware_download","elf,Mozi, ELF ","https://urlhaus.abuse.ch/url/3012345/","reporter"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record.Domain != "evil.example" || record.SourceID != "urlhaus-3012345" || !reflect.DeepEqual(record.Tags, []string{"elf", "mozi"}) {
+		t.Errorf("record = %+v", record)
+	}
+
+	// Fila sin columna de tags (o con la columna vacía): sin tags
+	for _, line := range []string{
+		`"3012346","2024-03-01 10:00:00","http://other.example/a"`,
+		`"3012347","2024-03-01 10:00:00","http://other.example/b","online","","malware_download","","",""`,
+	} {
+		record, err := feed.Parse(line)
+		if err != nil || len(record.Tags) != 0 {
+			t.Errorf("Parse(%s) = %+v, %v; want no tags", line, record, err)
+		}
+	}
+}
+
+func TestFeedDomainsTags(t *testing.T) {
+	domains := feedDomains{}
+	domains.add("evil.example", "urlhaus-2", "/a", []string{"elf", "mozi"})
+	domains.add("evil.example", "urlhaus-1", "/b", []string{"mozi", "32-bit"})
+	domains.add("other.example", "urlhaus-3", "/", nil)
+	domains.add("third.example", "urlhaus-4", "/c", []string{"elf"})
+
+	// Un dominio reúne los tags de todas sus URLs
+	var tags []string
+	for tag := range domains["evil.example"].tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	if want := []string{"32-bit", "elf", "mozi"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("evil.example tags = %q, want %q", tags, want)
+	}
+	if len(domains["other.example"].tags) != 0 {
+		t.Errorf("other.example tags = %v", domains["other.example"].tags)
+	}
+
+	// Un solo lookup/insert por tag distinto del feed
+	names := domains.tagNames()
+	sort.Strings(names)
+	if want := []string{"32-bit", "elf", "mozi"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("tagNames = %q, want %q", names, want)
+	}
+}
+
+// tagsDB tags/domain_tags/threat_domains en memoria con la semántica de las consultas de
+// tags.go. sequence imita la SMALLSERIAL de tags.id: avanza por cada fila que intenta
+// insertar el INSERT ... ON CONFLICT, aunque no la inserte.
+type tagsDB struct {
+	tags       map[string]int64
+	sequence   int64
+	domains    map[string]bool
+	domainTags map[string]map[int64]bool
+	audits     []string
+}
+
+func newTagsDB(domains ...string) *tagsDB {
+	db := &tagsDB{tags: map[string]int64{}, domains: map[string]bool{}, domainTags: map[string]map[int64]bool{}}
+	for _, d := range domains {
+		db.domains[d] = true
+	}
+	return db
+}
+
+func (c *tagsDB) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *tagsDB) Driver() driver.Driver                        { return nil }
+func (c *tagsDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (c *tagsDB) Close() error                                 { return nil }
+func (c *tagsDB) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+
+// pgArray elementos de un array de PostgreSQL en texto ({"a","b"} o {1,2}), como lo envía pq.Array
+func pgArray(v driver.Value) []string {
+	s := strings.Trim(v.(string), "{}")
+	if s == "" {
+		return nil
+	}
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		items = append(items, strings.Trim(item, `"`))
+	}
+	return items
+}
+
+func (c *tagsDB) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	var affected int64
+	switch {
+	case strings.Contains(query, "INSERT INTO tags"):
+		for _, name := range pgArray(args[0].Value) {
+			c.sequence++
+			if _, ok := c.tags[name]; !ok {
+				c.tags[name] = c.sequence
+				affected++
+			}
+		}
+	case strings.Contains(query, "INSERT INTO domain_tags"):
+		domain := args[0].Value.(string)
+		if !c.domains[domain] {
+			break
+		}
+		if c.domainTags[domain] == nil {
+			c.domainTags[domain] = map[int64]bool{}
+		}
+		for _, raw := range pgArray(args[1].Value) {
+			id, _ := strconv.ParseInt(raw, 10, 64)
+			if !c.domainTags[domain][id] {
+				c.domainTags[domain][id] = true
+				affected++
+			}
+		}
+	case strings.Contains(query, "DELETE FROM domain_tags"):
+		domain, id := args[0].Value.(string), args[1].Value.(int64)
+		if c.domainTags[domain][id] {
+			delete(c.domainTags[domain], id)
+			affected++
+		}
+	default:
+		return nil, errors.New("unexpected exec: " + query)
+	}
+	return driver.RowsAffected(affected), nil
+}
+
+func (c *tagsDB) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	rows := &tagsRows{}
+	switch {
+	case strings.Contains(query, "FROM tags WHERE name = ANY"):
+		rows.columns = []string{"id", "name"}
+		for _, name := range pgArray(args[0].Value) {
+			if id, ok := c.tags[name]; ok {
+				rows.values = append(rows.values, []driver.Value{id, name})
+			}
+		}
+	case strings.Contains(query, "SELECT true FROM threat_domains"):
+		rows.columns = []string{"bool"}
+		if c.domains[args[0].Value.(string)] {
+			rows.values = [][]driver.Value{{true}}
+		}
+	case strings.Contains(query, "SELECT t.name"):
+		rows.columns = []string{"name"}
+		var names []string
+		for name, id := range c.tags {
+			if c.domainTags[args[0].Value.(string)][id] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			rows.values = append(rows.values, []driver.Value{name})
+		}
+	case strings.Contains(query, "INSERT INTO audit_log"):
+		c.audits = append(c.audits, args[0].Value.(string)+" "+args[2].Value.(string))
+		rows.columns = []string{"id", "created_at"}
+		rows.values = [][]driver.Value{{int64(len(c.audits)), time.Now()}}
+	default:
+		return nil, errors.New("unexpected query: " + query)
+	}
+	return rows, nil
+}
+
+type tagsRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *tagsRows) Columns() []string { return r.columns }
+func (r *tagsRows) Close() error      { return nil }
+func (r *tagsRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func newTagsServer(t *testing.T, fake *tagsDB) *Server {
+	t.Helper()
+	db := sql.OpenDB(fake)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	health := newDBHealth(db)
+	health.healthy.Store(true)
+	return &Server{db: db, dbHealth: health, audit: NewAuditLogger(db), queryTimeout: time.Second, writeTimeout: time.Second}
+}
+
+// postTagJSON POST de body al handler y su respuesta decodificada
+func postTagJSON(t *testing.T, handler http.HandlerFunc, path, body string) map[string]interface{} {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	var resp map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: %v (%s)", path, body, err, rec.Body.String())
+	}
+	return resp
+}
+
+func TestCreateTag(t *testing.T) {
+	fake := newTagsDB()
+	s := newTagsServer(t, fake)
+
+	resp := postTagJSON(t, s.handleTags, "/api/admin/tags", `{"name": " Cobalt Strike "}`)
+	if resp["success"] != true || resp["created"] != true || resp["tag"].(map[string]interface{})["name"] != "cobalt-strike" {
+		t.Fatalf("create = %v", resp)
+	}
+
+	// Crear un tag existente no lo duplica, no gasta secuencia ni se audita
+	resp = postTagJSON(t, s.handleTags, "/api/admin/tags", `{"name": "cobalt-strike"}`)
+	if resp["success"] != true || resp["created"] != false {
+		t.Errorf("create existing = %v", resp)
+	}
+	if len(fake.tags) != 1 || fake.sequence != 1 {
+		t.Errorf("tags = %v, sequence = %d; want one tag and sequence 1", fake.tags, fake.sequence)
+	}
+	if want := []string{"create_tag cobalt-strike"}; !reflect.DeepEqual(fake.audits, want) {
+		t.Errorf("audits = %q, want %q", fake.audits, want)
+	}
+
+	if resp := postTagJSON(t, s.handleTags, "/api/admin/tags", `{"name": "bad/tag"}`); resp["success"] != false {
+		t.Errorf("invalid name = %v", resp)
+	}
+}
+
+func TestAttachDetachDomainTag(t *testing.T) {
+	fake := newTagsDB("evil.example")
+	fake.tags["mozi"], fake.tags["elf"], fake.sequence = 1, 2, 2
+	s := newTagsServer(t, fake)
+
+	tagDomain := func(body string) map[string]interface{} {
+		return postTagJSON(t, s.handleTagDomains, "/api/admin/tags/domains", body)
+	}
+	wantTags := func(resp map[string]interface{}, changed bool, tags ...string) {
+		t.Helper()
+		got := []string{}
+		list, _ := resp["tags"].([]interface{})
+		for _, tag := range list {
+			got = append(got, tag.(string))
+		}
+		if resp["success"] != true || resp["changed"] != changed || !reflect.DeepEqual(got, append([]string{}, tags...)) {
+			t.Errorf("response = %v, want changed %v and tags %q", resp, changed, tags)
+		}
+	}
+
+	wantTags(tagDomain(`{"domain": " Evil.Example ", "tag": "Mozi", "attach": true}`), true, "mozi")
+	// Asignar otra vez el mismo tag no duplica la fila
+	wantTags(tagDomain(`{"domain": "evil.example", "tag": "mozi", "attach": true}`), false, "mozi")
+	wantTags(tagDomain(`{"domain": "evil.example", "tag": "elf", "attach": true}`), true, "elf", "mozi")
+	if len(fake.domainTags["evil.example"]) != 2 {
+		t.Errorf("domain_tags = %v, want 2 rows", fake.domainTags["evil.example"])
+	}
+
+	wantTags(tagDomain(`{"domain": "evil.example", "tag": "mozi", "attach": false}`), true, "elf")
+	// Quitar un tag que ya no tiene no es un error, pero no cambia nada
+	wantTags(tagDomain(`{"domain": "evil.example", "tag": "mozi", "attach": false}`), false, "elf")
+
+	// Solo los cambios reales van al audit log
+	want := []string{"attach_tag evil.example", "attach_tag evil.example", "detach_tag evil.example"}
+	if !reflect.DeepEqual(fake.audits, want) {
+		t.Errorf("audits = %q, want %q", fake.audits, want)
+	}
+
+	errorsByBody := map[string]string{
+		`{"domain": "unknown.example", "tag": "mozi", "attach": true}`: "Domain not found",
+		`{"domain": "evil.example", "tag": "nuevo", "attach": true}`:   "Tag not found, create it first in /api/admin/tags",
+		`{"domain": "evil.example", "tag": "mozi"}`:                    "attach is required",
+		`{"domain": " ", "tag": "mozi", "attach": true}`:               "domain is required",
+		`{"domain": "evil.example", "tag": "a b/c", "attach": true}`:   "invalid tag",
+	}
+	for body, message := range errorsByBody {
+		if resp := tagDomain(body); resp["success"] != false || resp["error"] != message {
+			t.Errorf("%s: response = %v, want error %q", body, resp, message)
+		}
+	}
+	if len(fake.tags) != 2 || len(fake.audits) != 3 {
+		t.Errorf("failed requests changed state: tags %v, audits %q", fake.tags, fake.audits)
+	}
+}
+
+// Las sincronizaciones del feed repiten los mismos tags: solo se crean los nuevos y las
+// asignaciones existentes no se duplican
+func TestFeedTagResync(t *testing.T) {
+	fake := newTagsDB("evil.example", "other.example")
+	s := newTagsServer(t, fake)
+	ctx := context.Background()
+
+	sync := func(names []string, domainTags map[string][]string) map[string]int64 {
+		t.Helper()
+		ids, err := s.tagIDs(ctx, names, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attached := map[string]int64{}
+		for domain, tags := range domainTags {
+			var tagIDs []int16
+			for _, tag := range tags {
+				tagIDs = append(tagIDs, ids[tag])
+			}
+			n, err := s.attachDomainTags(ctx, domain, tagIDs)
+			if err != nil {
+				t.Fatal(err)
+			}
+			attached[domain] = n
+		}
+		return attached
+	}
+
+	first := sync([]string{"elf", "mozi"}, map[string][]string{"evil.example": {"elf", "mozi"}, "other.example": {"elf"}})
+	if first["evil.example"] != 2 || first["other.example"] != 1 || fake.sequence != 2 {
+		t.Fatalf("first sync attached %v, sequence %d", first, fake.sequence)
+	}
+
+	second := sync([]string{"elf", "mozi", "32-bit"}, map[string][]string{"evil.example": {"elf", "mozi", "32-bit"}, "other.example": {"elf"}})
+	if second["evil.example"] != 1 || second["other.example"] != 0 {
+		t.Errorf("second sync attached %v, want only the new tag", second)
+	}
+	// Solo el tag nuevo consume secuencia
+	if fake.sequence != 3 || fake.tags["32-bit"] != 3 {
+		t.Errorf("sequence = %d, tags = %v", fake.sequence, fake.tags)
+	}
+
+	// Sin create los tags que no existen no aparecen (y no se crean)
+	ids, err := s.tagIDs(ctx, []string{"elf", "nuevo"}, false)
+	if err != nil || len(ids) != 1 || ids["elf"] != int16(fake.tags["elf"]) {
+		t.Errorf("tagIDs without create = %v, %v", ids, err)
+	}
+	if n, err := s.attachDomainTags(ctx, "evil.example", nil); n != 0 || err != nil {
+		t.Errorf("attach without tags = %d, %v", n, err)
+	}
+}
@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API de inteligencia de amenazas para partners (solo lectura, autenticada con API key):
+//
+//	GET /v1/ti/domains/{domain}
+//	GET /v1/ti/emails/{sha256 hex del email en minúsculas}
+//	GET /v1/ti/phones/{número}
+//
+// Se sirve en PARTNER_PORT, no en el puerto del panel (ver main.go).
+// Las búsquedas van siempre por clave primaria o hash indexado. Los registros inactivos o
+// marcados como false_positive_candidate se responden como desconocidos (404).
+const (
+	tiFoundMaxAge    = 60  // Segundos de cache de una respuesta con datos
+	tiNotFoundMaxAge = 300 // Segundos de cache de un 404
+)
+
+var (
+	errInvalidIndicator = errors.New("invalid indicator")
+
+	tiDomainRegex    = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+	tiEmailHashRegex = regexp.MustCompile(`^[0-9a-f]{64}$`)
+)
+
+// tiEndpoint tipo de indicador de /v1/ti: normaliza el valor de la ruta y lo busca
+type tiEndpoint struct {
+	name      string // domains, emails, phones (api_key_usage.endpoint)
+	normalize func(value string) (string, error)
+	query     string // Un parámetro: el valor normalizado
+}
+
+// tiActive registro activo y no marcado como falso positivo
+var tiActive = activeThreat("flags")
+
+var tiEndpoints = map[string]tiEndpoint{
+	"domains": {
+		name:      "domains",
+		normalize: normalizeTIDomain,
+		query: `
+			SELECT threat_type::text, severity::text, confidence, source::text, first_seen, last_seen
+			FROM threat_domains
+			WHERE domain_hash = sha256_bytea($1) AND ` + tiActive,
+	},
+	"emails": {
+		name:      "emails",
+		normalize: normalizeTIEmailHash,
+		// El hash puede ser del email tal cual o de su forma canónica (sin sub-dirección)
+		query: `
+			SELECT threat_type::text, severity::text, confidence, source::text, first_seen, last_seen
+			FROM threat_emails
+			WHERE (email_hash = decode($1, 'hex') OR email_normalized_hash = decode($1, 'hex')) AND ` + tiActive,
+	},
+	"phones": {
+		name:      "phones",
+		normalize: normalizeTIPhone,
+		query: `
+			SELECT threat_type::text, severity::text, confidence, source::text, first_seen, last_seen
+			FROM threat_phones
+			WHERE phone_national = $1 AND ` + tiActive,
+	},
+}
+
+func normalizeTIDomain(value string) (string, error) {
+	domain := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(value)), ".")
+	if len(domain) > 253 || !tiDomainRegex.MatchString(domain) {
+		return "", errInvalidIndicator
+	}
+	return domain, nil
+}
+
+func normalizeTIEmailHash(value string) (string, error) {
+	hash := strings.ToLower(strings.TrimSpace(value))
+	if !tiEmailHashRegex.MatchString(hash) {
+		return "", errInvalidIndicator
+	}
+	return hash, nil
+}
+
+func normalizeTIPhone(value string) (string, error) {
+	national, _, err := nationalPhone(value, "")
+	if err != nil {
+		return "", errInvalidIndicator
+	}
+	return national, nil
+}
+
+// handleThreatIntel GET /v1/ti/{domains|emails|phones}/{indicador}
+func (s *Server) handleThreatIntel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet {
+		respondTI(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	if !s.dbReady() {
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+
+	kind, value, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/ti/"), "/")
+	endpoint, ok := tiEndpoints[kind]
+	if !ok || value == "" {
+		respondTI(w, http.StatusNotFound, "Not found")
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	key, err := s.apiKeys.authenticate(ctx, r)
+	if err != nil {
+		ctxLogger(r.Context()).Error().Err(err).Msg("[ThreatAPI] API key lookup failed")
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	if key == nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="trackfy-ti"`)
+		respondTI(w, http.StatusUnauthorized, "Missing or invalid API key")
+		return
+	}
+
+	status, found := s.serveThreatIntel(ctx, w, key, endpoint, value)
+
+	writeCtx, cancelWrite := s.writeCtx(r.Context())
+	defer cancelWrite()
+	s.apiKeys.recordUsage(writeCtx, key, endpoint.name, status, found)
+}
+
+// serveThreatIntel responde la consulta de una clave ya autenticada y retorna el status
+// y si el indicador se encontró (para api_key_usage)
+func (s *Server) serveThreatIntel(ctx context.Context, w http.ResponseWriter, key *apiKey, endpoint tiEndpoint, value string) (int, bool) {
+	allowed, remaining, retryAfter := s.apiKeys.allow(key, time.Now())
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(key.rateLimit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		respondTI(w, http.StatusTooManyRequests, "Rate limit exceeded")
+		return http.StatusTooManyRequests, false
+	}
+
+	indicator, err := endpoint.normalize(value)
+	if err != nil {
+		respondTI(w, http.StatusBadRequest, fmt.Sprintf("Invalid %s indicator", strings.TrimSuffix(endpoint.name, "s")))
+		return http.StatusBadRequest, false
+	}
+
+	result, err := s.lookupThreatIntel(ctx, endpoint, indicator)
+	if err != nil {
+		ctxLogger(ctx).Error().Err(err).Str("endpoint", endpoint.name).Msg("[ThreatAPI] Lookup failed")
+		respondTI(w, http.StatusServiceUnavailable, "Service unavailable")
+		return http.StatusServiceUnavailable, false
+	}
+	if result == nil {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", tiNotFoundMaxAge))
+		respondTI(w, http.StatusNotFound, "Indicator not found")
+		return http.StatusNotFound, false
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", tiFoundMaxAge))
+	json.NewEncoder(w).Encode(result)
+	return http.StatusOK, true
+}
+
+// lookupThreatIntel clasificación del indicador (nil si no se conoce). Si hay varias filas
+// (emails con la misma forma canónica) manda la de mayor confianza.
+func (s *Server) lookupThreatIntel(ctx context.Context, endpoint tiEndpoint, indicator string) (map[string]interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, endpoint.query, indicator)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result map[string]interface{}
+	var bestConfidence int
+	var firstSeen, lastSeen time.Time
+	sources := []string{}
+	seen := map[string]bool{}
+
+	for rows.Next() {
+		var threatType, severity, source string
+		var confidence int
+		var first, last time.Time
+		if err := rows.Scan(&threatType, &severity, &confidence, &source, &first, &last); err != nil {
+			return nil, err
+		}
+
+		if result == nil || confidence > bestConfidence {
+			bestConfidence = confidence
+			result = map[string]interface{}{
+				"indicator":   indicator,
+				"type":        strings.TrimSuffix(endpoint.name, "s"),
+				"threat_type": threatType,
+				"severity":    severity,
+				"confidence":  confidence,
+			}
+		}
+		if firstSeen.IsZero() || first.Before(firstSeen) {
+			firstSeen = first
+		}
+		if last.After(lastSeen) {
+			lastSeen = last
+		}
+		if !seen[source] {
+			seen[source] = true
+			sources = append(sources, source)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if result == nil {
+		return nil, nil
+	}
+
+	result["sources"] = sources
+	result["first_seen"] = firstSeen.UTC().Format(time.RFC3339)
+	result["last_seen"] = lastSeen.UTC().Format(time.RFC3339)
+	return result, nil
+}
+
+func respondTI(w http.ResponseWriter, status int, message string) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	timeseriesDefaultDays = 30
+	timeseriesMaxDays     = 365
+	timeseriesCacheTTL    = 3 * time.Minute
+	timeseriesTimeout     = 10 * time.Second
+)
+
+// timeseriesTables tablas de amenazas (las altas se cuentan por first_seen)
+var timeseriesTables = []string{"threat_domains", "threat_paths", "threat_emails", "threat_phones"}
+
+// SeriesPoint punto de una serie temporal
+type SeriesPoint struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// TableSeries serie total de una tabla y su desglose por fuente
+type TableSeries struct {
+	Total    []SeriesPoint            `json:"total"`
+	BySource map[string][]SeriesPoint `json:"by_source,omitempty"`
+}
+
+// timeseriesCache cache en memoria de las series (el dashboard las consulta en cada refresco)
+type timeseriesCache struct {
+	mu      sync.Mutex
+	entries map[string]timeseriesCacheEntry
+}
+
+type timeseriesCacheEntry struct {
+	data      map[string]interface{}
+	expiresAt time.Time
+}
+
+func newTimeseriesCache() *timeseriesCache {
+	return &timeseriesCache{entries: make(map[string]timeseriesCacheEntry)}
+}
+
+func (c *timeseriesCache) get(key string) (map[string]interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.data, true
+}
+
+func (c *timeseriesCache) set(key string, data map[string]interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = timeseriesCacheEntry{data: data, expiresAt: time.Now().Add(timeseriesCacheTTL)}
+}
+
+// handleTimeseries altas diarias/semanales por tabla y fuente, con días sin datos a cero
+// GET /api/stats/timeseries?days=30&granularity=day|week&source=
+func (s *Server) handleTimeseries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	days := getQueryInt(r, "days", timeseriesDefaultDays)
+	if days <= 0 || days > timeseriesMaxDays {
+		days = timeseriesDefaultDays
+	}
+	granularity := r.URL.Query().Get("granularity")
+	if granularity == "" {
+		granularity = "day"
+	}
+	if granularity != "day" && granularity != "week" {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "granularity must be day or week"})
+		return
+	}
+	source := r.URL.Query().Get("source")
+
+	cacheKey := fmt.Sprintf("%s|%d|%s", granularity, days, source)
+	if data, ok := s.timeseries.get(cacheKey); ok {
+		json.NewEncoder(w).Encode(withCached(data, true))
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), timeseriesTimeout)
+	defer cancel()
+
+	since := time.Now().AddDate(0, 0, -days+1)
+	series := make(map[string]interface{}, len(timeseriesTables)+1)
+
+	for _, table := range timeseriesTables {
+		tableSeries, err := s.tableTimeseries(ctx, table, since, granularity, source)
+		if err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+			return
+		}
+		series[table] = tableSeries
+	}
+
+	// Los reportes de usuarios no tienen fuente: el filtro source no les aplica
+	reports, err := s.querySeries(ctx, `
+		SELECT date_trunc($2::text, created_at) AS bucket, '' AS source, COUNT(*)
+		FROM user_url_reports
+		WHERE created_at >= date_trunc($2::text, $1::timestamp)
+		GROUP BY 1`, since, granularity)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	series["user_reports"] = &TableSeries{Total: reports[""]}
+
+	data := map[string]interface{}{
+		"success":     true,
+		"granularity": granularity,
+		"days":        days,
+		"source":      source,
+		"from":        since.Format("2006-01-02"),
+		"to":          time.Now().Format("2006-01-02"),
+		"series":      series,
+	}
+	s.timeseries.set(cacheKey, data)
+
+	json.NewEncoder(w).Encode(withCached(data, false))
+}
+
+// tableTimeseries serie total y por fuente de una tabla de amenazas (por first_seen)
+func (s *Server) tableTimeseries(ctx context.Context, table string, since time.Time, granularity, source string) (*TableSeries, error) {
+	query := fmt.Sprintf(`
+		SELECT date_trunc($2::text, first_seen) AS bucket, source::text, COUNT(*)
+		FROM %s
+		WHERE first_seen >= date_trunc($2::text, $1::timestamp)`, table)
+	args := []interface{}{since, granularity}
+	if source != "" {
+		query += " AND source::text = $3"
+		args = append(args, source)
+	}
+	query += " GROUP BY 1, 2"
+
+	bySource, err := s.querySeries(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", table, err)
+	}
+
+	// Total = suma de las fuentes por bucket (todas las series comparten los mismos buckets)
+	total, err := s.emptySeries(ctx, since, granularity)
+	if err != nil {
+		return nil, err
+	}
+	delete(bySource, "")
+	for _, points := range bySource {
+		for i, point := range points {
+			if i < len(total) {
+				total[i].Count += point.Count
+			}
+		}
+	}
+
+	return &TableSeries{Total: total, BySource: bySource}, nil
+}
+
+// querySeries ejecuta una consulta (bucket, source, count) y rellena con ceros los buckets vacíos
+// cruzándola con generate_series. $1 = desde, $2 = granularidad.
+func (s *Server) querySeries(ctx context.Context, countQuery string, args ...interface{}) (map[string][]SeriesPoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		WITH buckets AS (
+			SELECT generate_series(
+				date_trunc($2::text, $1::timestamp),
+				date_trunc($2::text, NOW()::timestamp),
+				('1 ' || $2::text)::interval
+			) AS bucket
+		),
+		counts AS (`+countQuery+`),
+		sources AS (SELECT DISTINCT source FROM counts)
+		SELECT b.bucket, s.source, COALESCE(c.count, 0)
+		FROM buckets b
+		CROSS JOIN sources s
+		LEFT JOIN counts c ON c.bucket = b.bucket AND c.source = s.source
+		ORDER BY s.source, b.bucket
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	series := make(map[string][]SeriesPoint)
+	for rows.Next() {
+		var bucket time.Time
+		var source string
+		var count int64
+		if err := rows.Scan(&bucket, &source, &count); err != nil {
+			return nil, err
+		}
+		series[source] = append(series[source], SeriesPoint{Date: bucket.Format("2006-01-02"), Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Sin filas en la ventana: serie completa a cero
+	if len(series) == 0 {
+		empty, err := s.emptySeries(ctx, args[0].(time.Time), args[1].(string))
+		if err != nil {
+			return nil, err
+		}
+		series[""] = empty
+	}
+	return series, nil
+}
+
+// emptySeries buckets de la ventana con contador a cero
+func (s *Server) emptySeries(ctx context.Context, since time.Time, granularity string) ([]SeriesPoint, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT generate_series(
+			date_trunc($2::text, $1::timestamp),
+			date_trunc($2::text, NOW()::timestamp),
+			('1 ' || $2::text)::interval
+		)
+	`, since, granularity)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []SeriesPoint{}
+	for rows.Next() {
+		var bucket time.Time
+		if err := rows.Scan(&bucket); err != nil {
+			return nil, err
+		}
+		points = append(points, SeriesPoint{Date: bucket.Format("2006-01-02")})
+	}
+	return points, rows.Err()
+}
+
+// withCached copia la respuesta indicando si viene de la cache
+func withCached(data map[string]interface{}, cached bool) map[string]interface{} {
+	response := make(map[string]interface{}, len(data)+1)
+	for k, v := range data {
+		response[k] = v
+	}
+	response["cached"] = cached
+	return response
+}
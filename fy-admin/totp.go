@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// TOTP (RFC 6238) compatible con Google Authenticator, Authy, 1Password...: HMAC-SHA1, 6 dígitos, 30s
+const (
+	totpPeriod     = 30
+	totpDigits     = 6
+	totpSkew       = 1  // Pasos de 30s aceptados antes y después (desfase de reloj del móvil)
+	totpSecretSize = 20 // Bytes del secreto generado (160 bits, lo recomendado para SHA1)
+	totpIssuer     = "Trackfy Admin"
+	totpAccount    = "admin"
+
+	totpMaxFailures = 5                // Códigos erróneos seguidos desde una IP antes de bloquearla
+	totpLockout     = 15 * time.Minute // Duración del bloqueo por IP
+	totpMaxTracked  = 1024             // IPs con fallos en memoria antes de purgar las caducadas
+
+	headerTOTPCode = "X-TOTP-Code"
+)
+
+var (
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+	errTOTPNotConfigured = errors.New("TOTP not configured")
+)
+
+// totpStore secreto TOTP del panel: ADMIN_TOTP_SECRET o, si no está configurado, el generado
+// en /api/admin/totp/setup y confirmado (tabla admin_totp)
+type totpStore struct {
+	db *sql.DB
+
+	envSecret []byte
+	envErr    error
+
+	mu        sync.RWMutex
+	confirmed []byte // Cache del secreto confirmado en BD (no cambia una vez confirmado)
+
+	guardMu     sync.Mutex
+	lastCounter uint64                  // Último paso aceptado: cada código se acepta una sola vez
+	failures    map[string]totpFailures // Clave: IP del admin
+}
+
+// totpFailures códigos erróneos seguidos de una IP y hasta cuándo está bloqueada
+type totpFailures struct {
+	count       int
+	lockedUntil time.Time
+}
+
+func newTOTPStore(db *sql.DB, envSecret string) *totpStore {
+	store := &totpStore{db: db, failures: make(map[string]totpFailures)}
+	if envSecret != "" {
+		store.envSecret, store.envErr = decodeTOTPSecret(envSecret)
+		if store.envErr != nil {
+			log.Error().Err(store.envErr).Msg("[TOTP] ADMIN_TOTP_SECRET is not valid base32, high-risk actions are disabled")
+		}
+	}
+	return store
+}
+
+// fromEnv indica si el secreto viene de ADMIN_TOTP_SECRET (el setup no aplica)
+func (t *totpStore) fromEnv() bool {
+	return t.envSecret != nil || t.envErr != nil
+}
+
+// secret secreto vigente; errTOTPNotConfigured si aún no hay ninguno confirmado
+func (t *totpStore) secret(ctx context.Context) ([]byte, error) {
+	if t.fromEnv() {
+		return t.envSecret, t.envErr
+	}
+
+	t.mu.RLock()
+	cached := t.confirmed
+	t.mu.RUnlock()
+	if cached != nil {
+		return cached, nil
+	}
+
+	if t.db == nil {
+		return nil, errTOTPNotConfigured
+	}
+	var encoded string
+	err := t.db.QueryRowContext(ctx, `SELECT secret FROM admin_totp WHERE confirmed`).Scan(&encoded)
+	if err == sql.ErrNoRows {
+		return nil, errTOTPNotConfigured
+	}
+	if err != nil {
+		return nil, err
+	}
+	secret, err := decodeTOTPSecret(encoded)
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	t.confirmed = secret
+	t.mu.Unlock()
+	return secret, nil
+}
+
+// locked indica si ip está bloqueada por demasiados códigos erróneos y cuánto le falta
+func (t *totpStore) locked(ip string, now time.Time) (bool, time.Duration) {
+	t.guardMu.Lock()
+	defer t.guardMu.Unlock()
+	entry := t.failures[ip]
+	if now.Before(entry.lockedUntil) {
+		return true, entry.lockedUntil.Sub(now)
+	}
+	return false, 0
+}
+
+// fail registra un código erróneo de ip; al llegar a totpMaxFailures la bloquea totpLockout.
+// Retorna true si este fallo ha provocado el bloqueo.
+func (t *totpStore) fail(ip string, now time.Time) bool {
+	t.guardMu.Lock()
+	defer t.guardMu.Unlock()
+
+	if len(t.failures) >= totpMaxTracked {
+		for key, entry := range t.failures {
+			if now.After(entry.lockedUntil) {
+				delete(t.failures, key)
+			}
+		}
+	}
+
+	entry := t.failures[ip]
+	if !entry.lockedUntil.IsZero() && now.After(entry.lockedUntil) {
+		entry = totpFailures{}
+	}
+	entry.count++
+	lockedNow := entry.count >= totpMaxFailures
+	if lockedNow {
+		entry.count = 0
+		entry.lockedUntil = now.Add(totpLockout)
+	}
+	t.failures[ip] = entry
+	return lockedNow
+}
+
+// accept consume el paso counter de un código válido de ip. false si ese paso, u otro
+// posterior, ya se aceptó: un código interceptado no se puede reutilizar dentro de su ventana.
+func (t *totpStore) accept(ip string, counter uint64) bool {
+	t.guardMu.Lock()
+	defer t.guardMu.Unlock()
+	if counter <= t.lastCounter {
+		return false
+	}
+	t.lastCounter = counter
+	delete(t.failures, ip)
+	return true
+}
+
+// checkCode valida code para la petición de ip aplicando el bloqueo por IP y el anti-replay.
+// status 0 si el código es válido; si no, el código HTTP y el mensaje a responder.
+func (t *totpStore) checkCode(secret []byte, code, ip string, now time.Time) (status int, message string, retryAfter time.Duration) {
+	if isLocked, wait := t.locked(ip, now); isLocked {
+		return http.StatusTooManyRequests, "Too many invalid TOTP codes, try again later", wait
+	}
+
+	counter, ok := validateTOTP(secret, code, now)
+	if ok && t.accept(ip, counter) {
+		return 0, "", 0
+	}
+
+	message = "Invalid TOTP code"
+	if ok {
+		message = "TOTP code already used, wait for the next one"
+	}
+	if t.fail(ip, now) {
+		log.Warn().Str("remote_addr", ip).Dur("lockout", totpLockout).Msg("[TOTP] Too many invalid codes, IP locked out")
+	}
+	return http.StatusForbidden, message, 0
+}
+
+// totpMiddleware exige en X-TOTP-Code un código TOTP válido para las acciones de alto riesgo.
+// Sin secreto configurado las acciones quedan bloqueadas hasta completar /api/admin/totp/setup.
+func (s *Server) totpMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		secret, err := s.totp.secret(r.Context())
+		if err != nil {
+			message := "TOTP not configured, complete /api/admin/totp/setup first"
+			if !errors.Is(err, errTOTPNotConfigured) {
+				log.Error().Err(err).Msg("[TOTP] Failed to load secret")
+				message = "TOTP secret unavailable"
+			}
+			respondTOTPRequired(w, message)
+			return
+		}
+
+		code := strings.TrimSpace(r.Header.Get(headerTOTPCode))
+		if code == "" {
+			respondTOTPRequired(w, "Missing "+headerTOTPCode+" header")
+			return
+		}
+		ip := adminIP(r)
+		if status, message, retryAfter := s.totp.checkCode(secret, code, ip, time.Now()); status != 0 {
+			log.Warn().
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Str("remote_addr", ip).
+				Msg("[TOTP] Rejected code: " + message)
+			respondTOTPError(w, status, message, retryAfter)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+func respondTOTPRequired(w http.ResponseWriter, message string) {
+	respondTOTPError(w, http.StatusForbidden, message, 0)
+}
+
+func respondTOTPError(w http.ResponseWriter, status int, message string, retryAfter time.Duration) {
+	errorCode := "totp_required"
+	if status == http.StatusTooManyRequests {
+		errorCode = "totp_locked"
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   errorCode,
+		"message": message,
+	})
+}
+
+// handleTOTPSetup genera el secreto TOTP y devuelve la URI otpauth:// para el QR. Cada llamada
+// antes de confirmar genera un secreto nuevo (el anterior no se vuelve a mostrar); una vez
+// confirmado, o con ADMIN_TOTP_SECRET configurado, responde 409.
+// GET /api/admin/totp/setup
+func (s *Server) handleTOTPSetup(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	if s.totp.fromEnv() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "TOTP secret is set via ADMIN_TOTP_SECRET"})
+		return
+	}
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	raw := make([]byte, totpSecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Failed to generate secret"})
+		return
+	}
+	secret := totpEncoding.EncodeToString(raw)
+
+	var stored bool
+	err := s.auditWrite(r, "totp_setup", "admin_totp", "1", func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		result, err := s.db.ExecContext(ctx, `
+			INSERT INTO admin_totp (id, secret) VALUES (1, $1)
+			ON CONFLICT (id) DO UPDATE SET secret = EXCLUDED.secret, created_at = NOW()
+			WHERE NOT admin_totp.confirmed
+		`, secret)
+		if err != nil {
+			return err
+		}
+		affected, _ := result.RowsAffected()
+		stored = affected > 0
+		return nil
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if !stored {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "TOTP already configured"})
+		return
+	}
+
+	log.Info().Str("remote_addr", adminIP(r)).Msg("[TOTP] New secret generated, pending confirmation")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":     true,
+		"secret":      secret,
+		"otpauth_uri": totpURI(secret),
+		"confirm_url": "/api/admin/totp/confirm",
+	})
+}
+
+// handleTOTPConfirm activa el secreto pendiente con un código generado por la app
+// POST /api/admin/totp/confirm   {"code": "123456"}
+func (s *Server) handleTOTPConfirm(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if s.totp.fromEnv() {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "TOTP secret is set via ADMIN_TOTP_SECRET"})
+		return
+	}
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	var input struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	var encoded string
+	var confirmed bool
+	err := s.db.QueryRowContext(ctx, `SELECT secret, confirmed FROM admin_totp WHERE id = 1`).Scan(&encoded, &confirmed)
+	cancel()
+	if err == sql.ErrNoRows {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "No pending TOTP setup"})
+		return
+	}
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if confirmed {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "TOTP already configured"})
+		return
+	}
+
+	secret, err := decodeTOTPSecret(encoded)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	if status, message, retryAfter := s.totp.checkCode(secret, strings.TrimSpace(input.Code), adminIP(r), time.Now()); status != 0 {
+		if retryAfter > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": message})
+		return
+	}
+
+	err = s.auditWrite(r, "totp_confirm", "admin_totp", "1", func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+		// secret en el WHERE: si otro setup lo ha sustituido entretanto, no se confirma
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE admin_totp SET confirmed = TRUE, confirmed_at = NOW()
+			WHERE id = 1 AND secret = $1 AND NOT confirmed
+		`, encoded)
+		return err
+	})
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().Str("remote_addr", adminIP(r)).Msg("[TOTP] Secret confirmed, high-risk actions now require " + headerTOTPCode)
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// HOTP/TOTP propios en lugar de github.com/pquerna/otp: totp.ValidateCustom solo dice si el
+// código vale, no qué paso de la ventana coincidió, y el anti-replay (lastCounter) lo necesita.
+// Recalcular la ventana con totp.GenerateCodeCustom para averiguarlo sería reimplementar
+// esto mismo encima de la librería. Son RFC 4226/6238 con los parámetros fijos de las apps
+// (SHA1, 6 dígitos, 30s) y TestTOTPCodeRFC6238 los comprueba con los vectores del RFC. Si se
+// cambia a la librería, solo se sustituyen validateTOTP, totpCode y totpURI: el bloqueo por
+// IP y el anti-replay de totpStore se quedan aquí.
+
+// validateTOTP compara code con los códigos de la ventana [now-skew, now+skew] y retorna
+// el paso (contador) que coincide, para el anti-replay
+func validateTOTP(secret []byte, code string, now time.Time) (uint64, bool) {
+	if len(code) != totpDigits || len(secret) == 0 {
+		return 0, false
+	}
+	counter := uint64(now.Unix() / totpPeriod)
+	var matched uint64
+	valid := false
+	for i := -totpSkew; i <= totpSkew; i++ {
+		expected := totpCode(secret, counter+uint64(i))
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			matched = counter + uint64(i)
+			valid = true
+		}
+	}
+	return matched, valid
+}
+
+// totpCode HOTP (RFC 4226) del contador
+func totpCode(secret []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// decodeTOTPSecret base32 con o sin padding, sin distinguir mayúsculas ni espacios
+func decodeTOTPSecret(encoded string) ([]byte, error) {
+	encoded = strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(encoded), " ", ""))
+	secret, err := totpEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, err
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	return secret, nil
+}
+
+// totpURI URI otpauth:// que codifica el QR de las apps autenticadoras
+func totpURI(secret string) string {
+	params := url.Values{}
+	params.Set("secret", secret)
+	params.Set("issuer", totpIssuer)
+	params.Set("algorithm", "SHA1")
+	params.Set("digits", fmt.Sprint(totpDigits))
+	params.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(totpIssuer+":"+totpAccount) + "?" + params.Encode()
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Vectores de RFC 6238 (SHA1) truncados a 6 dígitos
+func TestTOTPCodeRFC6238(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		if got := totpCode(secret, uint64(tt.unix/totpPeriod)); got != tt.want {
+			t.Errorf("totpCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPReplay(t *testing.T) {
+	store := newTOTPStore(nil, "")
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1111111109, 0)
+	code := totpCode(secret, uint64(now.Unix()/totpPeriod))
+
+	if status, message, _ := store.checkCode(secret, code, "10.0.0.1", now); status != 0 {
+		t.Fatalf("first use rejected: %d %s", status, message)
+	}
+	if status, _, _ := store.checkCode(secret, code, "10.0.0.2", now.Add(5*time.Second)); status != http.StatusForbidden {
+		t.Fatalf("replayed code status = %d, want 403", status)
+	}
+	// El código del paso anterior sigue en la ventana de desfase pero ya es viejo
+	previous := totpCode(secret, uint64(now.Unix()/totpPeriod)-1)
+	if status, _, _ := store.checkCode(secret, previous, "10.0.0.1", now); status != http.StatusForbidden {
+		t.Fatalf("older code status = %d, want 403", status)
+	}
+
+	next := now.Add(totpPeriod * time.Second)
+	if status, message, _ := store.checkCode(secret, totpCode(secret, uint64(next.Unix()/totpPeriod)), "10.0.0.1", next); status != 0 {
+		t.Fatalf("next code rejected: %d %s", status, message)
+	}
+}
+
+func TestTOTPLockout(t *testing.T) {
+	store := newTOTPStore(nil, "")
+	secret := []byte("12345678901234567890")
+	now := time.Unix(1234567890, 0)
+	valid := totpCode(secret, uint64(now.Unix()/totpPeriod))
+
+	for i := 0; i < totpMaxFailures; i++ {
+		if status, _, _ := store.checkCode(secret, "000000", "10.0.0.1", now); status != http.StatusForbidden {
+			t.Fatalf("attempt %d status = %d, want 403", i+1, status)
+		}
+	}
+
+	status, _, retryAfter := store.checkCode(secret, valid, "10.0.0.1", now.Add(time.Second))
+	if status != http.StatusTooManyRequests || retryAfter <= 0 {
+		t.Fatalf("locked IP: status = %d retryAfter = %v, want 429", status, retryAfter)
+	}
+
+	// Otra IP no queda afectada por el bloqueo
+	if status, message, _ := store.checkCode(secret, valid, "10.0.0.2", now.Add(time.Second)); status != 0 {
+		t.Fatalf("other IP rejected: %d %s", status, message)
+	}
+
+	after := now.Add(totpLockout + time.Minute)
+	if status, message, _ := store.checkCode(secret, totpCode(secret, uint64(after.Unix()/totpPeriod)), "10.0.0.1", after); status != 0 {
+		t.Fatalf("code after lockout rejected: %d %s", status, message)
+	}
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// Resultados de la red de confianza de fy-analysis (TrustNetworkAnalyzer, migración 022):
+// cuentas vinculadas en user_relationships y ajustes de trust_score propagados por ellas.
+
+// trustNetworkTopUsers cuentas con más vínculos que se muestran en el resumen
+const trustNetworkTopUsers = 20
+
+// TrustRelationshipRow vínculo de una cuenta con otra
+type TrustRelationshipRow struct {
+	LinkedUserID     string    `json:"linked_user_id"`
+	RelationshipType string    `json:"relationship_type"`
+	Confidence       float64   `json:"confidence"`
+	EvidenceCount    int       `json:"evidence_count"`
+	FirstSeenAt      time.Time `json:"first_seen_at"`
+	LastSeenAt       time.Time `json:"last_seen_at"`
+	LinkedTrustScore *int      `json:"linked_trust_score,omitempty"`
+	LinkedBanned     bool      `json:"linked_banned"`
+}
+
+// TrustAdjustmentRow ajuste de trust_score aplicado por la red de confianza
+type TrustAdjustmentRow struct {
+	UserID       string    `json:"user_id"`
+	LinkedUserID string    `json:"linked_user_id"`
+	Reason       string    `json:"reason"`
+	Adjustment   int       `json:"adjustment"`
+	TrustBefore  int       `json:"trust_before"`
+	TrustAfter   int       `json:"trust_after"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// TrustLinkedUserRow cuenta con más vínculos (posible granja de cuentas)
+type TrustLinkedUserRow struct {
+	UserID     string `json:"user_id"`
+	Links      int    `json:"links"`
+	TrustScore *int   `json:"trust_score,omitempty"`
+	Banned     bool   `json:"banned"`
+}
+
+func scanTrustAdjustmentRow(rows *sql.Rows) (TrustAdjustmentRow, error) {
+	var row TrustAdjustmentRow
+	err := rows.Scan(&row.UserID, &row.LinkedUserID, &row.Reason, &row.Adjustment,
+		&row.TrustBefore, &row.TrustAfter, &row.CreatedAt)
+	return row, err
+}
+
+// handleTrustNetwork resumen de la red de confianza o, con ?user_id=, los vínculos y ajustes
+// de una cuenta
+// GET /api/admin/trust-network[?user_id=...]
+func (s *Server) handleTrustNetwork(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	var resp interface{}
+	var err error
+	if userID := r.URL.Query().Get("user_id"); userID != "" {
+		resp, err = s.trustNetworkUser(ctx, userID)
+	} else {
+		resp, err = s.trustNetworkSummary(ctx)
+	}
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// trustNetworkSummary tamaño del grafo, ajustes de los últimos 30 días, cuentas con más
+// vínculos y últimos ajustes
+func (s *Server) trustNetworkSummary(ctx context.Context) (map[string]interface{}, error) {
+	relationships := s.groupCounts(ctx, "type", `
+		SELECT relationship_type, COUNT(*) FROM user_relationships GROUP BY relationship_type
+	`)
+	adjustments := s.groupCounts(ctx, "reason", `
+		SELECT reason, COUNT(*) FROM trust_network_adjustments
+		WHERE created_at >= NOW() - INTERVAL '30 days'
+		GROUP BY reason
+	`)
+
+	rows, err := s.db.QueryContext(ctx, `
+		WITH links AS (
+			SELECT user_id_a AS user_id FROM user_relationships
+			UNION ALL
+			SELECT user_id_b FROM user_relationships
+		)
+		SELECT l.user_id, COUNT(*) AS links, t.trust_score,
+		       COALESCE(`+flagBanned.isSet("t.flags")+` AND t.trust_score < 10, false)
+		FROM links l
+		LEFT JOIN user_trust_scores t ON t.user_id = l.user_id
+		GROUP BY l.user_id, t.trust_score, t.flags
+		ORDER BY links DESC, l.user_id
+		LIMIT $1
+	`, trustNetworkTopUsers)
+	if err != nil {
+		return nil, err
+	}
+	topUsers, topErrors, err := scanRows(rows, "user_relationships", func(rows *sql.Rows) (TrustLinkedUserRow, error) {
+		var row TrustLinkedUserRow
+		var trust sql.NullInt32
+		err := rows.Scan(&row.UserID, &row.Links, &trust, &row.Banned)
+		if trust.Valid {
+			score := int(trust.Int32)
+			row.TrustScore = &score
+		}
+		return row, err
+	})
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT user_id, linked_user_id, reason, adjustment, trust_before, trust_after, created_at
+		FROM trust_network_adjustments
+		ORDER BY created_at DESC, id DESC
+		LIMIT 50
+	`)
+	if err != nil {
+		return nil, err
+	}
+	recent, recentErrors, err := scanRows(rows, "trust_network_adjustments", scanTrustAdjustmentRow)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"relationships":      relationships,
+		"adjustments_30d":    adjustments,
+		"most_linked_users":  topUsers,
+		"recent_adjustments": recent,
+		"scan_errors":        topErrors + recentErrors,
+	}, nil
+}
+
+// trustNetworkUser vínculos de una cuenta (con el trust actual de la otra) y sus ajustes
+func (s *Server) trustNetworkUser(ctx context.Context, userID string) (map[string]interface{}, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT CASE WHEN r.user_id_a = $1 THEN r.user_id_b ELSE r.user_id_a END AS linked,
+		       r.relationship_type, r.confidence, r.evidence_count, r.first_seen_at, r.last_seen_at,
+		       t.trust_score, COALESCE(`+flagBanned.isSet("t.flags")+` AND t.trust_score < 10, false)
+		FROM user_relationships r
+		LEFT JOIN user_trust_scores t ON t.user_id = CASE WHEN r.user_id_a = $1 THEN r.user_id_b ELSE r.user_id_a END
+		WHERE r.user_id_a = $1 OR r.user_id_b = $1
+		ORDER BY r.confidence DESC, r.last_seen_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	relationships, relErrors, err := scanRows(rows, "user_relationships", func(rows *sql.Rows) (TrustRelationshipRow, error) {
+		var row TrustRelationshipRow
+		var trust sql.NullInt32
+		err := rows.Scan(&row.LinkedUserID, &row.RelationshipType, &row.Confidence, &row.EvidenceCount,
+			&row.FirstSeenAt, &row.LastSeenAt, &trust, &row.LinkedBanned)
+		if trust.Valid {
+			score := int(trust.Int32)
+			row.LinkedTrustScore = &score
+		}
+		return row, err
+	})
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err = s.db.QueryContext(ctx, `
+		SELECT user_id, linked_user_id, reason, adjustment, trust_before, trust_after, created_at
+		FROM trust_network_adjustments
+		WHERE user_id = $1 OR linked_user_id = $1
+		ORDER BY created_at DESC, id DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	adjustments, adjErrors, err := scanRows(rows, "trust_network_adjustments", scanTrustAdjustmentRow)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var trustScore sql.NullInt32
+	if err := s.db.QueryRowContext(ctx, `SELECT trust_score FROM user_trust_scores WHERE user_id = $1`, userID).Scan(&trustScore); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	resp := map[string]interface{}{
+		"user_id":       userID,
+		"relationships": relationships,
+		"adjustments":   adjustments,
+		"scan_errors":   relErrors + adjErrors,
+	}
+	if trustScore.Valid {
+		resp["trust_score"] = trustScore.Int32
+	}
+	return resp, nil
+}
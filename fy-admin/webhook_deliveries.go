@@ -0,0 +1,106 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// WebhookDeliveryRow entrega de webhook_deliveries
+type WebhookDeliveryRow struct {
+	ID             int64      `json:"id"`
+	Webhook        string     `json:"webhook"`
+	URL            string     `json:"url"`
+	Event          string     `json:"event"`
+	RiskScore      int        `json:"risk_score"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	ResponseStatus *int32     `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+func scanWebhookDeliveryRow(rows *sql.Rows) (WebhookDeliveryRow, error) {
+	var row WebhookDeliveryRow
+	var responseStatus sql.NullInt32
+	var deliveredAt sql.NullTime
+	if err := rows.Scan(&row.ID, &row.Webhook, &row.URL, &row.Event, &row.RiskScore, &row.Status, &row.Attempts,
+		&responseStatus, &row.LastError, &row.CreatedAt, &deliveredAt); err != nil {
+		return row, err
+	}
+	if responseStatus.Valid {
+		row.ResponseStatus = &responseStatus.Int32
+	}
+	if deliveredAt.Valid {
+		row.DeliveredAt = &deliveredAt.Time
+	}
+	return row, nil
+}
+
+// handleListWebhookDeliveries entregas de los webhooks de amenazas de fy-analysis, las más
+// recientes primero. Filtros: status (pending, delivered, failed) y webhook (nombre).
+// GET /api/admin/webhooks/deliveries
+func (s *Server) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", "pending", "delivered", "failed":
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "status must be pending, delivered or failed"})
+		return
+	}
+	webhook := r.URL.Query().Get("webhook")
+
+	limit := getQueryInt(r, "limit", 50)
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+	offset := getQueryInt(r, "offset", 0)
+	if offset < 0 {
+		offset = 0
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT id, webhook, url, event, risk_score, status, attempts, response_status,
+		       COALESCE(last_error, ''), created_at, delivered_at
+		FROM webhook_deliveries
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR webhook = $2)
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4
+	`, status, webhook, limit, offset)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	deliveries, scanErrors, err := scanRows(rows, "webhook_deliveries", scanWebhookDeliveryRow)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	var total int64
+	s.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM webhook_deliveries
+		WHERE ($1 = '' OR status = $1) AND ($2 = '' OR webhook = $2)
+	`, status, webhook).Scan(&total)
+
+	json.NewEncoder(w).Encode(listResponse(w, r, deliveries, scanErrors, total, limit, offset))
+}
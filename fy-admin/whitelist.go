@@ -0,0 +1,783 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	whitelistSource     = "whitelist"
+	whitelistDNSTimeout = 3 * time.Second
+	whitelistDNSWorkers = 10
+)
+
+// whitelistCategoryRegex categorías en minúsculas que caben en whitelist_domains.category
+var whitelistCategoryRegex = regexp.MustCompile(`^[a-z_]{2,20}$`)
+
+var (
+	errWhitelistNotFound = errors.New("Domain not in whitelist")
+	errWhitelistExists   = errors.New("Domain already in whitelist (use PUT to update it)")
+)
+
+// BrandEntry marca del fichero de whitelist (mismo formato en importación y exportación)
+type BrandEntry struct {
+	Brand        string   `json:"brand"`
+	Category     string   `json:"category"`
+	Country      string   `json:"country"`
+	OfficialName string   `json:"official_name,omitempty"`
+	Domains      []string `json:"domains"`
+}
+
+// WhitelistDomainResult resultado de un dominio del fichero
+type WhitelistDomainResult struct {
+	Brand  string `json:"brand"`
+	Domain string `json:"domain"`
+	// inserted, updated, unchanged, valid (dry_run), invalid, conflict, failed
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// WhitelistImportReport resumen de la importación de marcas
+type WhitelistImportReport struct {
+	Success   bool                    `json:"success"`
+	DryRun    bool                    `json:"dry_run"`
+	Brands    int                     `json:"brands"`
+	Total     int                     `json:"total"`
+	Inserted  int                     `json:"inserted"`
+	Updated   int                     `json:"updated"`
+	Unchanged int                     `json:"unchanged"`
+	Valid     int                     `json:"valid"`
+	Invalid   int                     `json:"invalid"`
+	Conflicts int                     `json:"conflicts"`
+	Failed    int                     `json:"failed"`
+	Duration  string                  `json:"duration"`
+	Domains   []WhitelistDomainResult `json:"domains"`
+}
+
+// whitelistDomain dominio validado pendiente de escribir
+type whitelistDomain struct {
+	brand  *BrandEntry
+	domain string
+}
+
+// handleWhitelist lista (GET) o añade a mano (POST, con TOTP) dominios de whitelist_domains
+// GET  /api/data/whitelist?search=&limit=&offset=
+// POST /api/data/whitelist {"domain", "brand", "category", "country", "official_name"}
+//
+// fy-analysis consulta whitelist_domains en cada análisis (LocalDB no cachea veredictos):
+// los cambios de estos endpoints se aplican desde el siguiente análisis.
+func (s *Server) handleWhitelist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		s.handleListWhitelist(w, r)
+	case http.MethodPost:
+		s.totpMiddleware(s.handleAddWhitelist)(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAddWhitelist añade un dominio a whitelist_domains. Si el dominio está activo en
+// threat_domains se añade igualmente y la respuesta incluye un warning.
+func (s *Server) handleAddWhitelist(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	var input struct {
+		Domain       string `json:"domain"`
+		Brand        string `json:"brand"`
+		Category     string `json:"category"`
+		Country      string `json:"country"`
+		OfficialName string `json:"official_name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+		return
+	}
+
+	domain, err := validateWhitelistFQDN(input.Domain)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	entry := &BrandEntry{
+		Brand:        input.Brand,
+		Category:     input.Category,
+		Country:      input.Country,
+		OfficialName: input.OfficialName,
+		Domains:      []string{domain},
+	}
+	if strings.TrimSpace(entry.Category) == "" {
+		entry.Category = defaultRequestCategory
+	}
+	if strings.TrimSpace(entry.Country) == "" {
+		entry.Country = defaultRequestCountry
+	}
+	if err := normalizeBrandEntry(entry); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	ctx, cancel := s.writeCtx(r.Context())
+	defer cancel()
+
+	// El conflicto con threat_domains no bloquea: el admin decide, pero se le avisa
+	var threatType string
+	err = s.db.QueryRowContext(ctx, `
+		SELECT threat_type::text FROM threat_domains
+		WHERE domain_hash = sha256_bytea($1) AND `+flagActive.isSet("flags")+`
+	`, domain).Scan(&threatType)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	err = s.auditWrite(r, "add_whitelist", "whitelist_domains", domain, func() error {
+		result, err := s.db.ExecContext(ctx, `
+			INSERT INTO whitelist_domains (domain_hash, domain, category, brand, country, official_name)
+			VALUES (sha256_bytea($1), $1, $2, $3, $4, NULLIF($5, ''))
+			ON CONFLICT (domain_hash) DO NOTHING
+		`, domain, entry.Category, entry.Brand, entry.Country, entry.OfficialName)
+		if err != nil {
+			return err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return errWhitelistExists
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, errWhitelistExists) {
+			w.WriteHeader(http.StatusConflict)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	resp := map[string]interface{}{"success": true, "domain": domain, "brand": entry.Brand}
+	if threatType != "" {
+		resp["warning"] = "domain is listed in threat_domains as " + threatType
+	}
+
+	log.Info().
+		Str("domain", domain).
+		Str("brand", entry.Brand).
+		Bool("in_threat_db", threatType != "").
+		Msg("[Whitelist] Domain added")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// handleWhitelistDomain actualiza o elimina un dominio de whitelist_domains (con TOTP)
+// PUT    /api/data/whitelist/{domain} {"brand", "category", "country", "official_name"} (los que cambian)
+// DELETE /api/data/whitelist/{domain}
+func (s *Server) handleWhitelistDomain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	domain, err := validateWhitelistFQDN(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/data/whitelist/"), "/"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	if r.Method == http.MethodDelete {
+		err = s.auditWrite(r, "delete_whitelist", "whitelist_domains", domain, func() error {
+			ctx, cancel := s.writeCtx(r.Context())
+			defer cancel()
+
+			result, err := s.db.ExecContext(ctx, `DELETE FROM whitelist_domains WHERE domain_hash = sha256_bytea($1)`, domain)
+			if err != nil {
+				return err
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return errWhitelistNotFound
+			}
+			return nil
+		})
+	} else {
+		var input struct {
+			Brand        *string `json:"brand"`
+			Category     *string `json:"category"`
+			Country      *string `json:"country"`
+			OfficialName *string `json:"official_name"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+			return
+		}
+		err = s.auditWrite(r, "update_whitelist", "whitelist_domains", domain, func() error {
+			return s.updateWhitelistDomain(r.Context(), domain, input.Brand, input.Category, input.Country, input.OfficialName)
+		})
+	}
+	if err != nil {
+		if errors.Is(err, errWhitelistNotFound) {
+			w.WriteHeader(http.StatusNotFound)
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().Str("domain", domain).Str("method", r.Method).Msg("[Whitelist] Domain modified")
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "domain": domain})
+}
+
+// updateWhitelistDomain aplica los campos recibidos (nil = sin cambios) sobre los actuales
+// y los valida como una entrada de marca
+func (s *Server) updateWhitelistDomain(ctx context.Context, domain string, brand, category, country, officialName *string) error {
+	ctx, cancel := s.writeCtx(ctx)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	entry := &BrandEntry{Domains: []string{domain}}
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(brand, ''), COALESCE(category, ''), COALESCE(country, ''), COALESCE(official_name, '')
+		FROM whitelist_domains
+		WHERE domain_hash = sha256_bytea($1)
+		FOR UPDATE
+	`, domain).Scan(&entry.Brand, &entry.Category, &entry.Country, &entry.OfficialName)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errWhitelistNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if brand != nil {
+		entry.Brand = *brand
+	}
+	if category != nil {
+		entry.Category = *category
+	}
+	if country != nil {
+		entry.Country = *country
+	}
+	if officialName != nil {
+		entry.OfficialName = *officialName
+	}
+	if err := normalizeBrandEntry(entry); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE whitelist_domains
+		SET brand = $2, category = $3, country = $4, official_name = NULLIF($5, '')
+		WHERE domain_hash = sha256_bytea($1)
+	`, domain, entry.Brand, entry.Category, entry.Country, entry.OfficialName); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// validateWhitelistFQDN normaliza el dominio y comprueba que es un FQDN válido
+// (etiquetas alfanuméricas con guiones internos, al menos dos, máximo 253 caracteres)
+func validateWhitelistFQDN(raw string) (string, error) {
+	domain, err := normalizeWhitelistDomain(raw)
+	if err != nil {
+		return "", err
+	}
+	if len(domain) > 253 || !tiDomainRegex.MatchString(domain) {
+		return "", fmt.Errorf("invalid domain: %s is not a valid FQDN", domain)
+	}
+	for _, label := range strings.Split(domain, ".") {
+		if len(label) > 63 {
+			return "", fmt.Errorf("invalid domain: label %q exceeds 63 characters", label)
+		}
+	}
+	return domain, nil
+}
+
+// handleImportWhitelist importa un fichero de marcas (JSON o CSV, multipart campo "file")
+// POST /api/import/whitelist?format=json|csv&dry_run=true&skip_dns=true
+//
+// Cada dominio se valida (formato, resolución DNS y ausencia en threat_domains) y se hace
+// upsert en whitelist_domains; reimportar el mismo fichero no modifica nada.
+func (s *Server) handleImportWhitelist(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	skipDNS, _ := strconv.ParseBool(r.URL.Query().Get("skip_dns"))
+
+	s.syncMutex.RLock()
+	inProgress := s.syncStatus[whitelistSource].InProgress
+	s.syncMutex.RUnlock()
+	if inProgress {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Whitelist import already in progress"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, importMaxUploadSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Brands file is required (field 'file')"})
+		return
+	}
+	defer file.Close()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+	}
+
+	var brands []BrandEntry
+	switch format {
+	case "json":
+		err = json.NewDecoder(file).Decode(&brands)
+	case "csv":
+		brands, err = parseBrandsCSV(file)
+	default:
+		err = fmt.Errorf("format must be json or csv")
+	}
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Now().Add(10 * time.Minute))
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Minute)
+	defer cancel()
+
+	report := s.importWhitelist(ctx, brands, dryRun, skipDNS)
+	log.Info().
+		Str("file", header.Filename).
+		Bool("dry_run", dryRun).
+		Int("total", report.Total).
+		Int("inserted", report.Inserted).
+		Int("updated", report.Updated).
+		Int("conflicts", report.Conflicts).
+		Int("invalid", report.Invalid).
+		Msg("[Whitelist] Import completed")
+
+	if !dryRun && report.Inserted+report.Updated > 0 {
+		s.auditLog(r, &AuditEntry{
+			Action:   "import_whitelist",
+			Table:    "whitelist_domains",
+			RecordID: header.Filename,
+			NewValue: auditJSON(map[string]interface{}{
+				"total":     report.Total,
+				"inserted":  report.Inserted,
+				"updated":   report.Updated,
+				"conflicts": report.Conflicts,
+				"invalid":   report.Invalid,
+			}),
+		})
+	}
+
+	json.NewEncoder(w).Encode(report)
+}
+
+// importWhitelist valida los dominios de todas las marcas y escribe los válidos
+func (s *Server) importWhitelist(ctx context.Context, brands []BrandEntry, dryRun, skipDNS bool) *WhitelistImportReport {
+	startTime := time.Now()
+	report := &WhitelistImportReport{DryRun: dryRun, Brands: len(brands), Domains: []WhitelistDomainResult{}}
+
+	s.updateSyncStatus(whitelistSource, true, "Validating whitelist brands...")
+	defer func() {
+		report.Duration = time.Since(startTime).Round(time.Millisecond).String()
+		s.updateSyncStatusComplete(whitelistSource, int64(report.Inserted+report.Updated),
+			int64(report.Invalid+report.Conflicts+report.Failed),
+			fmt.Sprintf("Whitelist import completed in %s (dry_run=%t)", report.Duration, dryRun))
+	}()
+
+	// 1. Validación de formato; un dominio listado en dos marcas es un conflicto del fichero
+	owner := make(map[string]string)
+	var candidates []whitelistDomain
+	for i := range brands {
+		brand := &brands[i]
+		if err := normalizeBrandEntry(brand); err != nil {
+			for _, d := range brand.Domains {
+				report.addDomain(WhitelistDomainResult{Brand: brand.Brand, Domain: d, Status: "invalid", Error: err.Error()})
+			}
+			if len(brand.Domains) == 0 {
+				report.addDomain(WhitelistDomainResult{Brand: brand.Brand, Status: "invalid", Error: err.Error()})
+			}
+			continue
+		}
+
+		for _, raw := range brand.Domains {
+			domain, err := normalizeWhitelistDomain(raw)
+			if err != nil {
+				report.addDomain(WhitelistDomainResult{Brand: brand.Brand, Domain: raw, Status: "invalid", Error: err.Error()})
+				continue
+			}
+			if other, dup := owner[domain]; dup {
+				if other != brand.Brand {
+					report.addDomain(WhitelistDomainResult{Brand: brand.Brand, Domain: domain, Status: "conflict",
+						Error: "domain also listed under brand " + other})
+				}
+				continue
+			}
+			owner[domain] = brand.Brand
+			candidates = append(candidates, whitelistDomain{brand: brand, domain: domain})
+		}
+	}
+
+	// 2. Resolución DNS en paralelo (un dominio oficial que no resuelve suele ser una errata)
+	var dnsErrors map[string]error
+	if !skipDNS {
+		s.updateSyncCounters(whitelistSource, 0, 0, fmt.Sprintf("Resolving %d domains...", len(candidates)))
+		dnsErrors = resolveWhitelistDomains(ctx, candidates)
+	}
+
+	// 3. Presencia en threat_domains y escritura
+	for _, c := range candidates {
+		result := WhitelistDomainResult{Brand: c.brand.Brand, Domain: c.domain}
+
+		if err := dnsErrors[c.domain]; err != nil {
+			result.Status, result.Error = "invalid", "domain does not resolve: "+err.Error()
+			report.addDomain(result)
+			continue
+		}
+
+		var threatType string
+		err := s.db.QueryRowContext(ctx, `
+			SELECT threat_type::text FROM threat_domains
+			WHERE domain_hash = sha256_bytea($1) AND `+flagActive.isSet("flags")+`
+		`, c.domain).Scan(&threatType)
+		if err == nil {
+			result.Status, result.Error = "conflict", "domain is listed in threat_domains as "+threatType
+			report.addDomain(result)
+			continue
+		}
+		if err != sql.ErrNoRows {
+			result.Status, result.Error = "failed", err.Error()
+			report.addDomain(result)
+			continue
+		}
+
+		if dryRun {
+			result.Status = "valid"
+			report.addDomain(result)
+			continue
+		}
+
+		result.Status, err = upsertWhitelistDomain(ctx, s.db, c.brand, c.domain)
+		if err != nil {
+			result.Status, result.Error = "failed", err.Error()
+		}
+		report.addDomain(result)
+	}
+
+	report.Success = report.Failed == 0
+	return report
+}
+
+// upsertWhitelistDomain inserta o actualiza el dominio; sin cambios no escribe nada (idempotente)
+func upsertWhitelistDomain(ctx context.Context, db *sql.DB, brand *BrandEntry, domain string) (string, error) {
+	var inserted bool
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO whitelist_domains (domain_hash, domain, category, brand, country, official_name)
+		VALUES (sha256_bytea($1), $1, $2, $3, $4, NULLIF($5, ''))
+		ON CONFLICT (domain_hash) DO UPDATE SET
+			category = EXCLUDED.category,
+			brand = EXCLUDED.brand,
+			country = EXCLUDED.country,
+			official_name = EXCLUDED.official_name
+		WHERE (whitelist_domains.category, whitelist_domains.brand, whitelist_domains.country, whitelist_domains.official_name)
+			IS DISTINCT FROM (EXCLUDED.category, EXCLUDED.brand, EXCLUDED.country, EXCLUDED.official_name)
+		RETURNING (xmax = 0)
+	`, domain, brand.Category, brand.Brand, brand.Country, brand.OfficialName).Scan(&inserted)
+	if err == sql.ErrNoRows {
+		return "unchanged", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if inserted {
+		return "inserted", nil
+	}
+	return "updated", nil
+}
+
+// resolveWhitelistDomains resuelve los dominios con un pool de workers; retorna los errores por dominio
+func resolveWhitelistDomains(ctx context.Context, candidates []whitelistDomain) map[string]error {
+	errs := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	jobs := make(chan string)
+	for i := 0; i < whitelistDNSWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for domain := range jobs {
+				if err := resolveDomain(ctx, domain); err != nil {
+					mu.Lock()
+					errs[domain] = err
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, c := range candidates {
+		jobs <- c.domain
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
+
+// resolveDomain acepta el dominio si tiene A/AAAA o, en su defecto, MX (dominios solo de correo)
+func resolveDomain(ctx context.Context, domain string) error {
+	ctx, cancel := context.WithTimeout(ctx, whitelistDNSTimeout)
+	defer cancel()
+
+	if _, err := net.DefaultResolver.LookupHost(ctx, domain); err == nil {
+		return nil
+	}
+	mx, err := net.DefaultResolver.LookupMX(ctx, domain)
+	if err != nil {
+		return err
+	}
+	if len(mx) == 0 {
+		return fmt.Errorf("no A, AAAA or MX records")
+	}
+	return nil
+}
+
+// normalizeBrandEntry valida y normaliza los campos de la marca
+func normalizeBrandEntry(brand *BrandEntry) error {
+	brand.Brand = strings.TrimSpace(brand.Brand)
+	brand.Category = strings.ToLower(strings.TrimSpace(brand.Category))
+	brand.Country = strings.ToUpper(strings.TrimSpace(brand.Country))
+	brand.OfficialName = strings.TrimSpace(brand.OfficialName)
+
+	switch {
+	case brand.Brand == "" || len(brand.Brand) > 50:
+		return fmt.Errorf("brand is required (max 50 characters)")
+	case !whitelistCategoryRegex.MatchString(brand.Category):
+		return fmt.Errorf("invalid category: %q", brand.Category)
+	case len(brand.Country) != 2:
+		return fmt.Errorf("country must be an ISO 3166 alpha-2 code")
+	case len(brand.OfficialName) > 100:
+		return fmt.Errorf("official_name exceeds 100 characters")
+	case len(brand.Domains) == 0:
+		return fmt.Errorf("brand has no domains")
+	}
+	return nil
+}
+
+// normalizeWhitelistDomain reutiliza la normalización de dominios manuales y quita el www.
+func normalizeWhitelistDomain(raw string) (string, error) {
+	entry, err := normalizeManualDomain(raw, "", "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(entry.Value, "www."), nil
+}
+
+// parseBrandsCSV lee el formato CSV: brand,category,country,official_name,domains
+// (domains separados por ';'). Varias filas de la misma marca se combinan.
+func parseBrandsCSV(file io.Reader) ([]BrandEntry, error) {
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	index := map[string]int{"brand": 0, "category": 1, "country": 2, "official_name": 3, "domains": 4}
+
+	var brands []BrandEntry
+	byBrand := make(map[string]int)
+	first := true
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if first {
+			first = false
+			if hasHeader(record, "brand") {
+				index = make(map[string]int)
+				for i, name := range record {
+					index[strings.ToLower(strings.TrimSpace(name))] = i
+				}
+				continue
+			}
+		}
+
+		field := func(name string) string {
+			if i, ok := index[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		var domains []string
+		for _, d := range strings.Split(field("domains"), ";") {
+			if d = strings.TrimSpace(d); d != "" {
+				domains = append(domains, d)
+			}
+		}
+
+		key := strings.ToLower(field("brand")) + "|" + strings.ToUpper(field("country"))
+		if i, ok := byBrand[key]; ok {
+			brands[i].Domains = append(brands[i].Domains, domains...)
+			continue
+		}
+		byBrand[key] = len(brands)
+		brands = append(brands, BrandEntry{
+			Brand:        field("brand"),
+			Category:     field("category"),
+			Country:      field("country"),
+			OfficialName: field("official_name"),
+			Domains:      domains,
+		})
+	}
+
+	return brands, nil
+}
+
+// handleExportWhitelist vuelca whitelist_domains en el formato de importación
+// GET /api/export/whitelist?format=json|csv
+func (s *Server) handleExportWhitelist(w http.ResponseWriter, r *http.Request) {
+	if !s.dbReady() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"error": "Database not connected"})
+		return
+	}
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	brands, err := s.exportWhitelist(ctx)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+
+	filename := "whitelist-" + time.Now().Format("20060102")
+	if r.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+
+		writer := csv.NewWriter(w)
+		writer.Write([]string{"brand", "category", "country", "official_name", "domains"})
+		for _, b := range brands {
+			writer.Write([]string{b.Brand, b.Category, b.Country, b.OfficialName, strings.Join(b.Domains, ";")})
+		}
+		writer.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+filename+`.json"`)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(brands)
+}
+
+// exportWhitelist agrupa los dominios por marca, categoría, país y nombre oficial
+func (s *Server) exportWhitelist(ctx context.Context) ([]BrandEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT domain, COALESCE(category, ''), COALESCE(brand, ''), COALESCE(country, ''), COALESCE(official_name, '')
+		FROM whitelist_domains
+		ORDER BY brand, domain
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	brands := []BrandEntry{}
+	byKey := make(map[string]int)
+	for rows.Next() {
+		var domain string
+		var entry BrandEntry
+		if err := rows.Scan(&domain, &entry.Category, &entry.Brand, &entry.Country, &entry.OfficialName); err != nil {
+			return nil, err
+		}
+
+		key := entry.Brand + "|" + entry.Category + "|" + entry.Country + "|" + entry.OfficialName
+		if i, ok := byKey[key]; ok {
+			brands[i].Domains = append(brands[i].Domains, domain)
+			continue
+		}
+		byKey[key] = len(brands)
+		entry.Domains = []string{domain}
+		brands = append(brands, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(brands, func(i, j int) bool { return brands[i].Brand < brands[j].Brand })
+	return brands, nil
+}
+
+// addDomain registra el resultado de un dominio y actualiza los contadores
+func (rep *WhitelistImportReport) addDomain(result WhitelistDomainResult) {
+	rep.Total++
+	switch result.Status {
+	case "inserted":
+		rep.Inserted++
+	case "updated":
+		rep.Updated++
+	case "unchanged":
+		rep.Unchanged++
+	case "valid":
+		rep.Valid++
+	case "invalid":
+		rep.Invalid++
+	case "conflict":
+		rep.Conflicts++
+	case "failed":
+		rep.Failed++
+	}
+	rep.Domains = append(rep.Domains, result)
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Valores por defecto de whitelist_domains al aprobar una solicitud sin categoría ni país
+	defaultRequestCategory = "other"
+	defaultRequestCountry  = "ES"
+
+	maxReviewNoteLen = 300
+)
+
+// uuidRegex ids UUID de whitelist_requests y api_keys
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// errRequestNotPending la solicitud no existe o ya se revisó
+var errRequestNotPending = errors.New("Request not found or already reviewed")
+
+// handleListWhitelistRequests lista las solicitudes de whitelist de usuarios con el estado del
+// dominio en la DB local (si está en threat_domains o ya en whitelist_domains)
+// GET /api/admin/whitelist/requests?status=pending|approved|rejected|all&limit=&offset=
+func (s *Server) handleListWhitelistRequests(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "":
+		status = "pending"
+	case "pending", "approved", "rejected", "all":
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "status must be pending, approved, rejected or all"})
+		return
+	}
+	limit := getQueryInt(r, "limit", 50)
+	offset := getQueryInt(r, "offset", 0)
+
+	ctx, cancel := s.readCtx(r.Context())
+	defer cancel()
+
+	// Pendientes: las más antiguas primero (cola de revisión); revisadas: las más recientes
+	order := "wr.created_at ASC"
+	if status != "pending" {
+		order = "COALESCE(wr.reviewed_at, wr.created_at) DESC"
+	}
+
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT wr.id::text, wr.user_id::text, wr.domain, COALESCE(wr.brand, ''), wr.reason,
+		       COALESCE(wr.evidence_url, ''), wr.status, COALESCE(wr.reviewed_by, ''), wr.reviewed_at,
+		       COALESCE(wr.review_note, ''), wr.created_at,
+		       td.domain IS NOT NULL, COALESCE(td.threat_type::text, ''), COALESCE(td.severity::text, ''),
+		       COALESCE(td.source::text, ''), COALESCE(td.flags, 0),
+		       wd.domain IS NOT NULL, COALESCE(wd.brand, '')
+		FROM whitelist_requests wr
+		LEFT JOIN threat_domains td ON td.domain_hash = sha256_bytea(wr.domain)
+		LEFT JOIN whitelist_domains wd ON wd.domain_hash = sha256_bytea(wr.domain)
+		WHERE $1 = 'all' OR wr.status = $1
+		ORDER BY %s
+		LIMIT %d OFFSET %d
+	`, order, limit, offset), status)
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+	defer rows.Close()
+
+	requests := []map[string]interface{}{}
+	for rows.Next() {
+		var id, userID, domain, brand, reason, evidenceURL, reqStatus, reviewedBy, reviewNote string
+		var reviewedAt sql.NullTime
+		var createdAt time.Time
+		var inThreats, whitelisted bool
+		var threatType, severity, source, whitelistBrand string
+		var threatFlags int
+
+		if err := rows.Scan(&id, &userID, &domain, &brand, &reason, &evidenceURL, &reqStatus, &reviewedBy,
+			&reviewedAt, &reviewNote, &createdAt, &inThreats, &threatType, &severity, &source, &threatFlags,
+			&whitelisted, &whitelistBrand); err != nil {
+			continue
+		}
+
+		item := map[string]interface{}{
+			"id":           id,
+			"user_id":      userID,
+			"domain":       domain,
+			"brand":        brand,
+			"reason":       reason,
+			"evidence_url": evidenceURL,
+			"status":       reqStatus,
+			"created_at":   createdAt.Format(time.RFC3339),
+			"whitelisted":  whitelisted,
+			"in_threat_db": inThreats,
+		}
+		if reviewedAt.Valid {
+			item["reviewed_by"] = reviewedBy
+			item["reviewed_at"] = reviewedAt.Time.Format(time.RFC3339)
+			item["review_note"] = reviewNote
+		}
+		if inThreats {
+			item["threat"] = map[string]interface{}{
+				"threat_type": threatType,
+				"severity":    severity,
+				"source":      source,
+				"active":      flagActive.has(threatFlags),
+				"flags":       decodeFlags("threat_domains", threatFlags),
+			}
+		}
+		if whitelisted {
+			item["whitelist_brand"] = whitelistBrand
+		}
+		requests = append(requests, item)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":  true,
+		"status":   status,
+		"requests": requests,
+		"count":    len(requests),
+	})
+}
+
+// handleReviewWhitelistRequest aprueba o rechaza una solicitud de whitelist y avisa al usuario
+// POST /api/admin/whitelist/requests/{id}/approve {"brand", "category", "country", "official_name", "note"} (opcionales)
+// POST /api/admin/whitelist/requests/{id}/reject  {"note": "..."}
+//
+// Al aprobar, el dominio pasa a whitelist_domains y, si está en threat_domains, se marca como
+// false_positive_candidate para que fy-analysis deje de usarlo.
+func (s *Server) handleReviewWhitelistRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if !s.dbReady() {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Database not connected"})
+		return
+	}
+
+	id, action, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/admin/whitelist/requests/"), "/")
+	if !ok || !uuidRegex.MatchString(id) || (action != "approve" && action != "reject") {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Not found"})
+		return
+	}
+
+	var input struct {
+		Brand        string `json:"brand"`
+		Category     string `json:"category"`
+		Country      string `json:"country"`
+		OfficialName string `json:"official_name"`
+		Note         string `json:"note"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": "Invalid JSON"})
+			return
+		}
+	}
+	note := strings.TrimSpace(input.Note)
+	if len([]rune(note)) > maxReviewNoteLen {
+		note = string([]rune(note)[:maxReviewNoteLen])
+	}
+	reviewer := "admin:" + adminIP(r)
+
+	var domain string
+	var err error
+	if action == "approve" {
+		domain, err = s.approveWhitelistRequest(r, id, reviewer, note, input.Brand, input.Category, input.Country, input.OfficialName)
+	} else {
+		domain, err = s.rejectWhitelistRequest(r, id, reviewer, note)
+	}
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{"success": false, "error": err.Error()})
+		return
+	}
+
+	log.Info().
+		Str("request_id", id).
+		Str("domain", domain).
+		Str("action", action).
+		Msg("[WhitelistRequests] Whitelist request reviewed")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"id":      id,
+		"domain":  domain,
+		"status":  map[string]string{"approve": "approved", "reject": "rejected"}[action],
+	})
+}
+
+// approveWhitelistRequest añade el dominio a whitelist_domains, cierra la solicitud y notifica
+// al usuario en una sola transacción
+func (s *Server) approveWhitelistRequest(r *http.Request, id, reviewer, note, brand, category, country, officialName string) (string, error) {
+	var domain string
+	err := s.auditWrite(r, "approve_whitelist_request", "whitelist_requests", id, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var userID, requestedBrand string
+		err = tx.QueryRowContext(ctx, `
+			SELECT user_id::text, domain, COALESCE(brand, '')
+			FROM whitelist_requests
+			WHERE id = $1::uuid AND status = 'pending'
+			FOR UPDATE
+		`, id).Scan(&userID, &domain, &requestedBrand)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errRequestNotPending
+		}
+		if err != nil {
+			return err
+		}
+
+		// Marca: la del admin o la propuesta por el usuario; el resto con valores por defecto
+		entry := &BrandEntry{
+			Brand:        brand,
+			Category:     category,
+			Country:      country,
+			OfficialName: officialName,
+			Domains:      []string{domain},
+		}
+		if strings.TrimSpace(entry.Brand) == "" {
+			entry.Brand = requestedBrand
+		}
+		if strings.TrimSpace(entry.Category) == "" {
+			entry.Category = defaultRequestCategory
+		}
+		if strings.TrimSpace(entry.Country) == "" {
+			entry.Country = defaultRequestCountry
+		}
+		if err := normalizeBrandEntry(entry); err != nil {
+			return err
+		}
+
+		// Si el dominio ya estaba en la whitelist se conservan sus datos
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO whitelist_domains (domain_hash, domain, category, brand, country, official_name)
+			VALUES (sha256_bytea($1), $1, $2, $3, $4, NULLIF($5, ''))
+			ON CONFLICT (domain_hash) DO NOTHING
+		`, domain, entry.Category, entry.Brand, entry.Country, entry.OfficialName); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE threat_domains SET flags = COALESCE(flags, 0) | $2::smallint
+			WHERE domain_hash = sha256_bytea($1)
+		`, domain, int16(flagFalsePositiveCandidate)); err != nil {
+			return err
+		}
+
+		if err := closeWhitelistRequest(ctx, tx, id, "approved", reviewer, note); err != nil {
+			return err
+		}
+
+		body := fmt.Sprintf("Hemos añadido %s a la lista de dominios legítimos. Gracias por tu ayuda", domain)
+		if err := notifyWhitelistDecision(ctx, tx, userID, "whitelist_approved", "Solicitud de whitelist aprobada", body, id, domain, note); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	return domain, err
+}
+
+// rejectWhitelistRequest cierra la solicitud como rechazada y notifica al usuario
+func (s *Server) rejectWhitelistRequest(r *http.Request, id, reviewer, note string) (string, error) {
+	var domain string
+	err := s.auditWrite(r, "reject_whitelist_request", "whitelist_requests", id, func() error {
+		ctx, cancel := s.writeCtx(r.Context())
+		defer cancel()
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		var userID string
+		err = tx.QueryRowContext(ctx, `
+			SELECT user_id::text, domain
+			FROM whitelist_requests
+			WHERE id = $1::uuid AND status = 'pending'
+			FOR UPDATE
+		`, id).Scan(&userID, &domain)
+		if errors.Is(err, sql.ErrNoRows) {
+			return errRequestNotPending
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := closeWhitelistRequest(ctx, tx, id, "rejected", reviewer, note); err != nil {
+			return err
+		}
+
+		body := fmt.Sprintf("Hemos revisado tu solicitud y %s no se añadirá a la lista de dominios legítimos", domain)
+		if note != "" {
+			body += ": " + note
+		}
+		if err := notifyWhitelistDecision(ctx, tx, userID, "whitelist_rejected", "Solicitud de whitelist rechazada", body, id, domain, note); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	})
+	return domain, err
+}
+
+func closeWhitelistRequest(ctx context.Context, tx *sql.Tx, id, status, reviewer, note string) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE whitelist_requests
+		SET status = $2, reviewed_by = $3, reviewed_at = NOW(), review_note = NULLIF($4, '')
+		WHERE id = $1::uuid
+	`, id, status, reviewer, note)
+	return err
+}
+
+// notifyWhitelistDecision notificación in-app (api-gateway la sirve en GET /api/v1/me/notifications)
+func notifyWhitelistDecision(ctx context.Context, tx *sql.Tx, userID, notificationType, title, body, id, domain, note string) error {
+	if len([]rune(body)) > 500 {
+		body = string([]rune(body)[:500])
+	}
+	data, err := json.Marshal(map[string]string{"request_id": id, "domain": domain, "note": note})
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_notifications (user_id, type, title, body, data)
+		VALUES ($1, $2, $3, $4, $5)
+	`, userID, notificationType, title, body, data)
+	return err
+}
@@ -8,12 +8,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/trackfy/fy-analysis/internal/api"
+	"github.com/trackfy/fy-analysis/internal/api/handlers"
+	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/config"
 	"github.com/trackfy/fy-analysis/internal/urlengine"
+	"github.com/trackfy/pkg/clientip"
+	"github.com/trackfy/pkg/dbtls"
+	"github.com/trackfy/pkg/dbutil"
+	"github.com/trackfy/pkg/signature"
+	"github.com/trackfy/pkg/tracing"
 )
 
 func main() {
@@ -28,12 +36,45 @@ func main() {
 		Str("environment", cfg.Environment).
 		Msg("Starting Fy-Analysis Service")
 
+	// DATABASE_URL es opcional (sin ella los checkers de PostgreSQL se deshabilitan), pero si
+	// está definida tiene que ser válida
+	if cfg.DatabaseURL != "" {
+		if err := dbutil.ValidateDatabaseURL(cfg.DatabaseURL); err != nil {
+			log.Fatal().Err(err).Msg("Invalid database configuration")
+		}
+	}
+
+	trustedProxies, err := clientip.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid TRUSTED_PROXIES")
+	}
+
+	// Inicializar tracing (OpenTelemetry)
+	shutdownTracing, err := tracing.Init(context.Background(), "fy-analysis")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
 	// Inicializar URL Engine
 	urlEngine := initURLEngine(cfg)
+	configWatcher := initConfigWatcher(cfg, urlEngine)
+
+	// Redis compartido: nonces de la firma y cache de /expand (nil = en memoria)
+	redisClient := initRedis(cfg)
 
 	// Crear router con URL Engine
 	routerConfig := &api.RouterConfig{
-		URLEngine: urlEngine,
+		URLEngine:       urlEngine,
+		RateLimit:       cfg.RateLimit,
+		TrustedProxies:  trustedProxies,
+		SigningSecret:   cfg.InternalSigningSecret,
+		NonceStore:      initNonceStore(cfg, redisClient),
+		AdminToken:      cfg.InternalAdminToken,
+		ExpandRateLimit: cfg.ExpandRateLimit,
+		ExpandCacheTTL:  cfg.ExpandCacheTTL,
+	}
+	if redisClient != nil {
+		routerConfig.ExpandCache = handlers.NewRedisExpandCache(redisClient)
 	}
 	router := api.NewRouterWithConfig(routerConfig)
 
@@ -66,6 +107,9 @@ func main() {
 	defer cancel()
 
 	// Detener URL Engine
+	if configWatcher != nil {
+		configWatcher.Stop()
+	}
 	if urlEngine != nil {
 		urlEngine.Stop()
 	}
@@ -74,6 +118,10 @@ func main() {
 		log.Fatal().Err(err).Msg("Server forced to shutdown")
 	}
 
+	if err := shutdownTracing(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
+
 	log.Info().Msg("Server stopped gracefully")
 }
 
@@ -98,21 +146,166 @@ func setupLogger(cfg *config.Config) {
 	}
 }
 
+// initRedis conecta con REDIS_URL. nil si no está configurado o no responde: los nonces
+// de la firma y la cache de /expand quedan entonces en memoria.
+func initRedis(cfg *config.Config) *redis.Client {
+	if cfg.RedisURL == "" {
+		return nil
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisURL,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Warn().Err(err).Str("addr", cfg.RedisURL).Msg("Redis not available, using in-memory signature nonces and expand cache")
+		client.Close()
+		return nil
+	}
+
+	log.Info().Str("addr", cfg.RedisURL).Msg("Redis connected")
+	return client
+}
+
+// initNonceStore usa Redis para los nonces de la firma si está disponible
+// (compartidos entre réplicas); si no, quedan en memoria
+func initNonceStore(cfg *config.Config, client *redis.Client) signature.NonceStore {
+	if cfg.InternalSigningSecret == "" || client == nil {
+		return signature.NewMemoryNonceStore()
+	}
+
+	log.Info().Msg("Signature nonces stored in Redis")
+	return signature.NewRedisNonceStore(client)
+}
+
+// initConfigWatcher recarga pesos, timeout, checkers activos, consenso, modo de prioridad y
+// circuitos del engine cuando cambia ENGINE_CONFIG_FILE. Lo que el fichero no define vuelve al
+// valor de arranque.
+func initConfigWatcher(cfg *config.Config, engine *urlengine.Engine) *config.ConfigWatcher {
+	if cfg.EngineConfigFile == "" || engine == nil {
+		return nil
+	}
+
+	watcher := config.NewConfigWatcher(cfg.EngineConfigFile, cfg.ConfigReloadInterval, func(reloaded *config.ReloadableConfig) error {
+		newConfig := &urlengine.EngineConfig{
+			CheckTimeout:        engineCheckTimeout,
+			DisabledCheckers:    cfg.DisabledCheckers,
+			CheckerWeights:      reloaded.CheckerWeights,
+			ConsensusThreshold:  cfg.ConsensusThreshold,
+			CheckerPriorityMode: cfg.CheckerPriorityMode,
+			BreakerThreshold:    cfg.CheckerBreakerThreshold,
+			BreakerCooldown:     cfg.CheckerBreakerCooldown,
+		}
+		if reloaded.CheckTimeout > 0 {
+			newConfig.CheckTimeout = reloaded.CheckTimeout
+		}
+		if reloaded.DisabledCheckers != nil {
+			newConfig.DisabledCheckers = reloaded.DisabledCheckers
+		}
+		if reloaded.ConsensusThreshold > 0 {
+			newConfig.ConsensusThreshold = reloaded.ConsensusThreshold
+		}
+		if reloaded.CheckerPriorityMode != "" {
+			newConfig.CheckerPriorityMode = reloaded.CheckerPriorityMode
+		}
+		if reloaded.BreakerThreshold != nil {
+			newConfig.BreakerThreshold = *reloaded.BreakerThreshold
+		}
+		if reloaded.BreakerCooldown > 0 {
+			newConfig.BreakerCooldown = reloaded.BreakerCooldown
+		}
+		return engine.ReloadConfig(newConfig)
+	})
+	watcher.Start(context.Background())
+	return watcher
+}
+
+// engineCheckTimeout timeout de los checkers si ENGINE_CONFIG_FILE no lo define
+const engineCheckTimeout = 3 * time.Second
+
 // initURLEngine inicializa el motor de verificación de URLs
 func initURLEngine(cfg *config.Config) *urlengine.Engine {
 	log.Info().Msg("Initializing URL Engine...")
 
 	engineConfig := &urlengine.EngineConfig{
-		CheckTimeout:      3 * time.Second,
-		URLhausDBPath:     cfg.URLhausDBPath,
-		PhishTankDBPath:   cfg.PhishTankDBPath,
-		GoogleWebRiskKey:  cfg.GoogleWebRiskKey,
-		URLScanKey:        cfg.URLScanKey,
-		PhishTankKey:      cfg.PhishTankKey,
-		EnableDBSync:      cfg.EnableDBSync,
-		DatabaseURL:       cfg.DatabaseURL,
-		EnableLocalDB:     cfg.EnableLocalDB,
-		EnableUserReports: cfg.EnableUserReports,
+		CheckTimeout:     engineCheckTimeout,
+		URLhausDBPath:    cfg.URLhausDBPath,
+		PhishTankDBPath:  cfg.PhishTankDBPath,
+		GoogleWebRiskKey: cfg.GoogleWebRiskKey,
+		URLScanKey:       cfg.URLScanKey,
+		PhishTankKey:     cfg.PhishTankKey,
+		HIBPKey:          cfg.HIBPKey,
+		VirusTotalKey:    cfg.VirusTotalKey,
+		MalwareBazaarKey: cfg.MalwareBazaarKey,
+		SpamdAddress:     cfg.SpamdAddress,
+		EnableDBSync:     cfg.EnableDBSync,
+		EnableFileSync:   cfg.EnableFileSync,
+		EnableFeedDBSync: cfg.EnableFeedDBSync,
+		DatabaseURL:      cfg.DatabaseURL,
+		EnableLocalDB:    cfg.EnableLocalDB,
+		LocalDBMaxConns:  cfg.LocalDBMaxConns,
+		DBQueryTimeout:   cfg.DBQueryTimeout,
+		DBTLS: dbtls.Config{
+			CertFile: cfg.DBSSLCert,
+			KeyFile:  cfg.DBSSLKey,
+			CAFile:   cfg.DBSSLCA,
+		},
+		DisabledCheckers:      cfg.DisabledCheckers,
+		ConsensusThreshold:    cfg.ConsensusThreshold,
+		CheckerPriorityMode:   cfg.CheckerPriorityMode,
+		BreakerThreshold:      cfg.CheckerBreakerThreshold,
+		BreakerCooldown:       cfg.CheckerBreakerCooldown,
+		ConfidenceDecayLambda: cfg.ConfidenceDecayLambda,
+		EnableUserReports:     cfg.EnableUserReports,
+		EnableThreatPromotion: cfg.EnableThreatPromotion,
+		EnableDomainAge:       cfg.EnableDomainAge,
+		EnableVisual:          cfg.EnableVisualChecker,
+		ChromeURL:             cfg.ChromeURL,
+		EnableEmailDNS:        cfg.EnableEmailDNS,
+		EmailRulesFile:        cfg.EmailRulesFile,
+		WebhooksFile:          cfg.WebhooksFile,
+		GatewayURL:            cfg.GatewayURL,
+		SigningSecret:         cfg.InternalSigningSecret,
+		URLTrackingParams:     cfg.URLTrackingParams,
+		DisposableURL:         cfg.DisposableDomainsURL,
+		DisposableInterval:    time.Duration(cfg.DisposableRefreshHours) * time.Hour,
+		TLDRiskFile:           cfg.TLDRiskFile,
+		TLDRiskInterval:       time.Duration(cfg.TLDRiskRefreshHours) * time.Hour,
+		PhoneLookupTimeout:    time.Duration(cfg.PhoneLookupTimeoutMs) * time.Millisecond,
+		PhoneLookupTTL:        time.Duration(cfg.PhoneLookupCacheTTLSec) * time.Second,
+		MinAnalysisTimeout:    time.Duration(cfg.AnalysisTimeoutMinMs) * time.Millisecond,
+		MaxAnalysisTimeout:    time.Duration(cfg.AnalysisTimeoutMaxMs) * time.Millisecond,
+		EnableURLScanSubmit:   cfg.URLScanSubmit,
+		EnableCarrierLookup:   cfg.EnableCarrierLookup,
+		CarrierLookupURL:      cfg.CarrierLookupURL,
+		CarrierLookupKey:      cfg.CarrierLookupKey,
+		ReportLimits: checkers.ReportLimitsConfig{
+			MaxPerUser:        cfg.ReportMaxPerUser,
+			MaxPerIP:          cfg.ReportMaxPerIP,
+			Window:            cfg.ReportWindow,
+			DuplicateWindow:   cfg.ReportDuplicateWindow,
+			MaxDescriptionLen: cfg.ReportMaxDescriptionLen,
+		},
+		AnalysisLimits: checkers.AnalysisLimitsConfig{
+			CheapPerMinute:     cfg.AnalysisCheapPerMinute,
+			ExpensivePerMinute: cfg.AnalysisExpensivePerMinute,
+			MaxConcurrent:      cfg.AnalysisMaxConcurrent,
+			MaxQueued:          cfg.AnalysisMaxQueued,
+			QueueTimeout:       cfg.AnalysisQueueTimeout,
+		},
+		HTTPClient: &checkers.HTTPClientConfig{
+			MaxIdleConns:        cfg.CheckerMaxIdleConns,
+			MaxIdleConnsPerHost: cfg.CheckerMaxIdleConnsPerHost,
+			IdleConnTimeout:     time.Duration(cfg.CheckerIdleConnTimeoutSec) * time.Second,
+			ClientCertFile:      cfg.CheckerClientCertFile,
+			ClientKeyFile:       cfg.CheckerClientKeyFile,
+			CAFile:              cfg.CheckerCAFile,
+		},
 	}
 
 	engine := urlengine.NewEngine(engineConfig)
@@ -123,8 +316,16 @@ func initURLEngine(cfg *config.Config) *urlengine.Engine {
 
 	log.Info().
 		Bool("db_sync_enabled", cfg.EnableDBSync).
+		Bool("file_sync_enabled", cfg.EnableFileSync).
+		Bool("feed_db_sync_enabled", cfg.EnableFeedDBSync && cfg.DatabaseURL != "").
 		Bool("google_webrisk", cfg.GoogleWebRiskKey != "").
 		Bool("urlscan", cfg.URLScanKey != "").
+		Bool("urlscan_submit", cfg.URLScanKey != "" && cfg.URLScanSubmit).
+		Bool("hibp", cfg.HIBPKey != "").
+		Bool("malware_hash", cfg.VirusTotalKey != "" || cfg.MalwareBazaarKey != "").
+		Bool("spamassassin", cfg.SpamdAddress != "").
+		Bool("carrier_lookup", cfg.EnableCarrierLookup).
+		Bool("webhooks", cfg.WebhooksFile != "").
 		Msg("URL Engine initialized and started")
 
 	return engine
@@ -6,12 +6,13 @@ import (
 	"strings"
 	"time"
 
+	"github.com/trackfy/fy-analysis/internal/disposable"
 	"github.com/trackfy/fy-analysis/internal/models"
 )
 
 // Analyzer maneja el análisis de emails
 type Analyzer struct {
-	disposableDomains map[string]bool
+	disposableDomains *disposable.List
 	freemailDomains   map[string]bool
 	blacklistedDomains map[string]bool
 }
@@ -19,7 +20,7 @@ type Analyzer struct {
 // NewAnalyzer crea una nueva instancia del analizador de emails
 func NewAnalyzer() *Analyzer {
 	return &Analyzer{
-		disposableDomains:  loadDisposableDomains(),
+		disposableDomains:  disposable.Default,
 		freemailDomains:    loadFreemailDomains(),
 		blacklistedDomains: loadBlacklistedDomains(),
 	}
@@ -120,7 +121,7 @@ func isValidFormat(email string) bool {
 }
 
 func (a *Analyzer) isDisposable(domain string) bool {
-	return a.disposableDomains[domain]
+	return a.disposableDomains.Contains(domain)
 }
 
 func (a *Analyzer) isBlacklisted(domain string) bool {
@@ -196,26 +197,6 @@ func (a *Analyzer) heuristicAnalysis(email string, context string) float64 {
 	return score
 }
 
-func loadDisposableDomains() map[string]bool {
-	return map[string]bool{
-		"tempmail.com":      true,
-		"throwaway.email":   true,
-		"guerrillamail.com": true,
-		"10minutemail.com":  true,
-		"mailinator.com":    true,
-		"yopmail.com":       true,
-		"trashmail.com":     true,
-		"fakeinbox.com":     true,
-		"tempail.com":       true,
-		"getnada.com":       true,
-		"temp-mail.org":     true,
-		"disposablemail.com": true,
-		"maildrop.cc":       true,
-		"dispostable.com":   true,
-		"sharklasers.com":   true,
-	}
-}
-
 func loadFreemailDomains() map[string]bool {
 	return map[string]bool{
 		"gmail.com":     true,
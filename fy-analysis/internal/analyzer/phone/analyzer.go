@@ -1,18 +1,22 @@
 package phone
 
 import (
+	"context"
 	"regexp"
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/models"
 )
 
 // Analyzer maneja el análisis de números de teléfono
 type Analyzer struct {
-	premiumPrefixes    map[string][]string
-	scamNumbers        map[string]bool
-	countryPatterns    map[string]*regexp.Regexp
+	premiumPrefixes map[string][]string
+	scamNumbers     map[string]bool
+	countryPatterns map[string]*regexp.Regexp
+	carrier         *CarrierLookupService // nil = sin lookup de operador
 }
 
 // NewAnalyzer crea una nueva instancia del analizador de teléfonos
@@ -24,8 +28,13 @@ func NewAnalyzer() *Analyzer {
 	}
 }
 
+// SetCarrierLookup habilita el lookup de operador (ENABLE_CARRIER_LOOKUP)
+func (a *Analyzer) SetCarrierLookup(service *CarrierLookupService) {
+	a.carrier = service
+}
+
 // Analyze realiza el análisis completo de un número de teléfono
-func (a *Analyzer) Analyze(phone string, countryCode string, context string) models.PhoneAnalysisResponse {
+func (a *Analyzer) Analyze(phone string, countryCode string, text string) models.PhoneAnalysisResponse {
 	// Limpiar número
 	cleanPhone := cleanPhoneNumber(phone)
 
@@ -70,25 +79,32 @@ func (a *Analyzer) Analyze(phone string, countryCode string, context string) mod
 	if phoneInfo.IsPremiumRate {
 		response.Analysis.ThreatLevel = models.ThreatLevelMedium
 		response.Analysis.ThreatTypes = append(response.Analysis.ThreatTypes, models.ThreatTypeFraud)
-		response.Analysis.Reasons = append(response.Analysis.Reasons, "Número de tarificación especial (premium)")
+		reason := "Número de tarificación especial (premium)"
+		if phoneInfo.PremiumClass != "" {
+			reason = "Número de tarificación especial (premium): " + phoneInfo.PremiumClass
+		}
+		response.Analysis.Reasons = append(response.Analysis.Reasons, reason)
 		response.Analysis.Confidence = 0.9
 		response.Recommendations = append(response.Recommendations, "Llamar a este número puede generar cargos elevados")
 	}
 
+	// Operador del número (si está habilitado)
+	a.enrichCarrier(context.Background(), cleanPhone, phoneInfo)
+
 	// Análisis heurístico
-	heuristicResult := a.heuristicAnalysis(cleanPhone, phoneInfo, context)
+	heuristicResult := a.heuristicAnalysis(cleanPhone, phoneInfo, text)
 
-	if heuristicResult.score > 0.6 {
+	if heuristicResult.Score > 0.6 {
 		response.Analysis.ThreatLevel = models.ThreatLevelHigh
 		response.Analysis.ThreatTypes = append(response.Analysis.ThreatTypes, models.ThreatTypeScam)
-		response.Analysis.Reasons = append(response.Analysis.Reasons, heuristicResult.reasons...)
-		response.Analysis.Confidence = heuristicResult.score
+		response.Analysis.Reasons = append(response.Analysis.Reasons, heuristicResult.Reasons...)
+		response.Analysis.Confidence = heuristicResult.Score
 		response.Recommendations = append(response.Recommendations, "Verificar la identidad del llamante por otros medios")
-	} else if heuristicResult.score > 0.3 {
+	} else if heuristicResult.Score > 0.3 {
 		if response.Analysis.ThreatLevel == models.ThreatLevelSafe {
 			response.Analysis.ThreatLevel = models.ThreatLevelLow
 		}
-		response.Analysis.Reasons = append(response.Analysis.Reasons, heuristicResult.reasons...)
+		response.Analysis.Reasons = append(response.Analysis.Reasons, heuristicResult.Reasons...)
 	}
 
 	// Si no hay amenazas
@@ -100,6 +116,47 @@ func (a *Analyzer) Analyze(phone string, countryCode string, context string) mod
 	return response
 }
 
+// Heuristics retorna la información del número y el resultado de las heurísticas, sin el
+// veredicto de Analyze (lo usa el Engine a través de correlation.PhoneHeuristicAdapter)
+func (a *Analyzer) Heuristics(ctx context.Context, phone string, countryCode string, text string) (*models.PhoneInfo, HeuristicResult) {
+	cleanPhone := cleanPhoneNumber(phone)
+	info := a.extractPhoneInfo(cleanPhone, countryCode)
+	if info.IsValid {
+		a.enrichCarrier(ctx, cleanPhone, info)
+	}
+	return info, a.heuristicAnalysis(cleanPhone, info, text)
+}
+
+// enrichCarrier añade el operador a info. Un fallo del lookup solo deja info sin operador.
+func (a *Analyzer) enrichCarrier(ctx context.Context, phone string, info *models.PhoneInfo) {
+	if a.carrier == nil {
+		return
+	}
+
+	// Sin prefijo internacional el servicio necesita el del país detectado
+	if !strings.HasPrefix(phone, "+") {
+		country := checkers.PremiumCountryByISO(info.CountryCode)
+		if country == nil {
+			return
+		}
+		phone = country.DialCode + phone
+	}
+
+	carrier, err := a.carrier.Lookup(ctx, phone)
+	if err != nil {
+		log.Debug().Err(err).Msg("[PhoneAnalyzer] Carrier lookup failed")
+		return
+	}
+	if carrier.Name == "" && carrier.LineType == "" {
+		return
+	}
+
+	info.Carrier = carrier
+	if info.Type == "unknown" && carrier.LineType != "" {
+		info.Type = carrier.LineType
+	}
+}
+
 func cleanPhoneNumber(phone string) string {
 	// Remover todo excepto dígitos y el símbolo +
 	reg := regexp.MustCompile(`[^\d+]`)
@@ -108,9 +165,8 @@ func cleanPhoneNumber(phone string) string {
 
 func (a *Analyzer) extractPhoneInfo(phone string, countryCode string) *models.PhoneInfo {
 	info := &models.PhoneInfo{
-		CountryCode:   countryCode,
-		IsValid:       a.isValidPhone(phone, countryCode),
-		IsPremiumRate: a.isPremiumRate(phone, countryCode),
+		CountryCode: countryCode,
+		IsValid:     a.isValidPhone(phone, countryCode),
 	}
 
 	// Detectar país si no se especificó
@@ -120,8 +176,19 @@ func (a *Analyzer) extractPhoneInfo(phone string, countryCode string) *models.Ph
 		info.Country = getCountryName(countryCode)
 	}
 
+	// Premium se evalúa con el país ya detectado para usar su plan de numeración
+	if match := a.premiumMatch(phone, info.CountryCode); match != nil {
+		info.IsPremiumRate = true
+		info.PremiumClass = match.Describe()
+	} else {
+		info.IsPremiumRate = a.isPremiumRate(phone, info.CountryCode)
+	}
+
 	// Detectar tipo de número
 	info.Type = a.detectPhoneType(phone, info.CountryCode)
+	if info.IsPremiumRate {
+		info.Type = "premium"
+	}
 
 	return info
 }
@@ -143,14 +210,35 @@ func (a *Analyzer) isValidPhone(phone string, countryCode string) bool {
 	return len(cleanPhone) >= 8
 }
 
+// premiumMatch busca el número en la tabla de tarificación especial del país (ISO)
+func (a *Analyzer) premiumMatch(phone string, countryCode string) *checkers.PremiumMatch {
+	country := checkers.PremiumCountryByISO(countryCode)
+	if country == nil {
+		return nil
+	}
+
+	digits := strings.TrimPrefix(phone, "+")
+	dialCode := strings.TrimPrefix(country.DialCode, "+")
+	if strings.HasPrefix(digits, dialCode) {
+		if match := country.Match(digits[len(dialCode):]); match != nil {
+			return match
+		}
+	}
+
+	// Sin prefijo internacional el número ya es nacional
+	if !strings.HasPrefix(phone, "+") {
+		return country.Match(digits)
+	}
+	return nil
+}
+
+// isPremiumRate prefijos genéricos para países sin plan en la tabla
 func (a *Analyzer) isPremiumRate(phone string, countryCode string) bool {
-	prefixes, exists := a.premiumPrefixes[countryCode]
-	if !exists {
-		// Usar prefijos genéricos
-		prefixes = a.premiumPrefixes["default"]
+	if checkers.PremiumCountryByISO(countryCode) != nil {
+		return false
 	}
 
-	for _, prefix := range prefixes {
+	for _, prefix := range a.premiumPrefixes["default"] {
 		if strings.HasPrefix(phone, prefix) || strings.HasPrefix(strings.TrimPrefix(phone, "+"), prefix) {
 			return true
 		}
@@ -235,15 +323,18 @@ func (a *Analyzer) detectPhoneType(phone string, countryCode string) string {
 	return "unknown"
 }
 
-type heuristicResult struct {
-	score   float64
-	reasons []string
+// HeuristicResult resultado de las heurísticas de un número
+type HeuristicResult struct {
+	Score   float64  // 0.0 - 1.0
+	Reasons []string // Razones en español
+	Flags   []string // Flags técnicos
 }
 
-func (a *Analyzer) heuristicAnalysis(phone string, info *models.PhoneInfo, context string) heuristicResult {
-	result := heuristicResult{
-		score:   0.0,
-		reasons: []string{},
+func (a *Analyzer) heuristicAnalysis(phone string, info *models.PhoneInfo, context string) HeuristicResult {
+	result := HeuristicResult{
+		Score:   0.0,
+		Reasons: []string{},
+		Flags:   []string{},
 	}
 
 	// 1. País de alto riesgo para estafas
@@ -251,14 +342,16 @@ func (a *Analyzer) heuristicAnalysis(phone string, info *models.PhoneInfo, conte
 		"UNKNOWN": true,
 	}
 	if highRiskCountries[info.CountryCode] {
-		result.score += 0.2
-		result.reasons = append(result.reasons, "País de origen no identificado")
+		result.Score += 0.2
+		result.Reasons = append(result.Reasons, "País de origen no identificado")
+		result.Flags = append(result.Flags, "unknown_country")
 	}
 
 	// 2. Número VOIP (difícil de rastrear)
 	if info.Type == "voip" {
-		result.score += 0.2
-		result.reasons = append(result.reasons, "Número VOIP (difícil de rastrear)")
+		result.Score += 0.2
+		result.Reasons = append(result.Reasons, "Número VOIP (difícil de rastrear)")
+		result.Flags = append(result.Flags, "voip_number")
 	}
 
 	// 3. Analizar contexto
@@ -273,8 +366,9 @@ func (a *Analyzer) heuristicAnalysis(phone string, info *models.PhoneInfo, conte
 		}
 		for _, pattern := range scamPatterns {
 			if strings.Contains(contextLower, pattern) {
-				result.score += 0.15
-				result.reasons = append(result.reasons, "Contexto contiene palabras asociadas a estafas")
+				result.Score += 0.15
+				result.Reasons = append(result.Reasons, "Contexto contiene palabras asociadas a estafas")
+				result.Flags = append(result.Flags, "scam_context")
 				break
 			}
 		}
@@ -282,18 +376,72 @@ func (a *Analyzer) heuristicAnalysis(phone string, info *models.PhoneInfo, conte
 
 	// 4. Número con muchos ceros o patrones repetitivos
 	if hasRepetitivePattern(phone) {
-		result.score += 0.1
-		result.reasons = append(result.reasons, "Patrón numérico inusual")
+		result.Score += 0.1
+		result.Reasons = append(result.Reasons, "Patrón numérico inusual")
+		result.Flags = append(result.Flags, "repetitive_pattern")
+	}
+
+	// 5. Operador del número (solo con lookup de operador)
+	if carrier := info.Carrier; carrier != nil {
+		contextLower := strings.ToLower(context)
+
+		if carrier.LineType == "voip" && containsAny(contextLower, bankKeywords) {
+			result.Score += 0.3
+			result.Reasons = append(result.Reasons, "Número VOIP que se presenta como un banco")
+			result.Flags = append(result.Flags, "voip_bank_impersonation")
+		}
+
+		if isPrepaid(carrier) && containsAny(contextLower, institutionKeywords) {
+			result.Score += 0.15
+			result.Reasons = append(result.Reasons, "Número de prepago que se presenta como una institución")
+			result.Flags = append(result.Flags, "prepaid_institution_impersonation")
+		}
+
+		if carrier.Country != "" && info.CountryCode != "" && info.CountryCode != "UNKNOWN" && carrier.Country != info.CountryCode {
+			result.Score += 0.25
+			result.Reasons = append(result.Reasons, "El operador del número es de un país distinto al de origen")
+			result.Flags = append(result.Flags, "carrier_country_mismatch")
+		}
 	}
 
 	// Limitar score máximo
-	if result.score > 1.0 {
-		result.score = 1.0
+	if result.Score > 1.0 {
+		result.Score = 1.0
 	}
 
 	return result
 }
 
+// bankKeywords palabras con las que un llamante se presenta como un banco
+var bankKeywords = []string{
+	"banco", "bank", "bbva", "santander", "caixabank", "la caixa", "sabadell",
+	"bankinter", "unicaja", "kutxabank", "abanca", "openbank", "ing direct",
+}
+
+// institutionKeywords palabras con las que un llamante se presenta como una institución pública
+var institutionKeywords = []string{
+	"hacienda", "agencia tributaria", "seguridad social", "sepe", "dgt", "policía",
+	"policia", "guardia civil", "ministerio", "ayuntamiento", "juzgado", "correos",
+}
+
+func containsAny(text string, keywords []string) bool {
+	for _, keyword := range keywords {
+		if strings.Contains(text, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// isPrepaid el servicio de lookup marca la línea o el operador como prepago
+func isPrepaid(carrier *models.CarrierInfo) bool {
+	if strings.Contains(carrier.LineType, "prepaid") {
+		return true
+	}
+	name := strings.ToLower(carrier.Name)
+	return strings.Contains(name, "prepaid") || strings.Contains(name, "prepago")
+}
+
 func hasRepetitivePattern(phone string) bool {
 	digits := strings.TrimPrefix(phone, "+")
 	if len(digits) < 6 {
@@ -333,12 +481,9 @@ func getCountryName(code string) string {
 	return "Desconocido"
 }
 
+// loadPremiumPrefixes prefijos genéricos; los planes por país están en checkers.premiumCountries
 func loadPremiumPrefixes() map[string][]string {
 	return map[string][]string{
-		"ES":      {"803", "806", "807", "905"},
-		"MX":      {"900"},
-		"US":      {"900", "976"},
-		"GB":      {"09", "070"},
 		"default": {"900", "901", "902"},
 	}
 }
@@ -0,0 +1,184 @@
+package phone
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/models"
+)
+
+// NumverifyURL endpoint de validación de Numverify (apilayer)
+const NumverifyURL = "http://apilayer.net/api/validate"
+
+// CarrierLookupService obtiene operador, tipo de línea y país de un número vía Numverify o un
+// servicio HLR local con la misma respuesta (valid, carrier, line_type, country_code)
+type CarrierLookupService struct {
+	db         *sql.DB // Cache en phone_carrier_cache (opcional)
+	httpClient *http.Client
+	apiURL     string
+	accessKey  string // access_key de Numverify; vacío para un HLR local sin autenticación
+	timeout    time.Duration
+	cacheTTL   time.Duration
+}
+
+// carrierResponse subconjunto de la respuesta de Numverify que nos interesa
+type carrierResponse struct {
+	Valid       bool   `json:"valid"`
+	CountryCode string `json:"country_code"`
+	Carrier     string `json:"carrier"`
+	LineType    string `json:"line_type"`
+	Success     *bool  `json:"success"` // Solo presente en errores
+	Error       *struct {
+		Code int    `json:"code"`
+		Info string `json:"info"`
+	} `json:"error"`
+}
+
+// NewCarrierLookupService crea el servicio; apiURL vacío = Numverify, db puede ser nil (sin cache)
+func NewCarrierLookupService(db *sql.DB, apiURL, accessKey string, timeout time.Duration) *CarrierLookupService {
+	if apiURL == "" {
+		apiURL = NumverifyURL
+	}
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &CarrierLookupService{
+		db:         db,
+		httpClient: &http.Client{Timeout: timeout},
+		apiURL:     apiURL,
+		accessKey:  accessKey,
+		timeout:    timeout,
+		cacheTTL:   30 * 24 * time.Hour, // La portabilidad cambia el operador, pero rara vez
+	}
+}
+
+// Lookup retorna el operador del número (formato internacional, con o sin +), usando la cache
+// si está disponible. Los números que el servicio no reconoce se retornan sin datos.
+func (s *CarrierLookupService) Lookup(ctx context.Context, phone string) (*models.CarrierInfo, error) {
+	number := strings.TrimPrefix(cleanPhoneNumber(phone), "+")
+	if number == "" {
+		return nil, fmt.Errorf("invalid phone number")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.timeout)
+	defer cancel()
+
+	if cached, ok := s.getCached(ctx, number); ok {
+		return cached, nil
+	}
+
+	info, err := s.query(ctx, number)
+	if err != nil {
+		return nil, err
+	}
+
+	s.storeCached(ctx, number, info)
+	return info, nil
+}
+
+// query consulta el servicio de lookup
+func (s *CarrierLookupService) query(ctx context.Context, number string) (*models.CarrierInfo, error) {
+	params := url.Values{}
+	params.Set("number", number)
+	if s.accessKey != "" {
+		params.Set("access_key", s.accessKey)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.apiURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		// El error de net/http incluye la URL, que lleva la access_key
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("carrier lookup request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("carrier lookup returned status %d", resp.StatusCode)
+	}
+
+	var body carrierResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode carrier response: %w", err)
+	}
+	// Numverify responde 200 con success=false si la clave o la cuota fallan
+	if body.Success != nil && !*body.Success {
+		if body.Error != nil {
+			return nil, fmt.Errorf("carrier lookup error %d: %s", body.Error.Code, body.Error.Info)
+		}
+		return nil, fmt.Errorf("carrier lookup failed")
+	}
+
+	info := &models.CarrierInfo{}
+	if body.Valid {
+		info.Name = strings.TrimSpace(body.Carrier)
+		info.LineType = strings.ToLower(strings.TrimSpace(body.LineType))
+		info.Country = strings.ToUpper(strings.TrimSpace(body.CountryCode))
+	}
+
+	log.Debug().
+		Str("carrier", info.Name).
+		Str("line_type", info.LineType).
+		Str("country", info.Country).
+		Msg("[CarrierLookup] Lookup completed")
+
+	return info, nil
+}
+
+// getCached busca el número en phone_carrier_cache
+func (s *CarrierLookupService) getCached(ctx context.Context, number string) (*models.CarrierInfo, bool) {
+	if s.db == nil {
+		return nil, false
+	}
+
+	var carrier, lineType, country sql.NullString
+	err := s.db.QueryRowContext(ctx, `
+		SELECT carrier, line_type, country
+		FROM phone_carrier_cache
+		WHERE phone_national = $1 AND cached_at > NOW() - $2::interval
+	`, number, fmt.Sprintf("%d seconds", int(s.cacheTTL.Seconds()))).Scan(&carrier, &lineType, &country)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Debug().Err(err).Msg("[CarrierLookup] Cache lookup failed")
+		}
+		return nil, false
+	}
+
+	return &models.CarrierInfo{Name: carrier.String, LineType: lineType.String, Country: country.String}, true
+}
+
+// storeCached guarda el resultado en phone_carrier_cache
+func (s *CarrierLookupService) storeCached(ctx context.Context, number string, info *models.CarrierInfo) {
+	if s.db == nil {
+		return
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO phone_carrier_cache (phone_national, carrier, line_type, country, cached_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NOW())
+		ON CONFLICT (phone_national) DO UPDATE SET
+			carrier = EXCLUDED.carrier,
+			line_type = EXCLUDED.line_type,
+			country = EXCLUDED.country,
+			cached_at = NOW()
+	`, number, info.Name, info.LineType, info.Country)
+	if err != nil {
+		log.Debug().Err(err).Msg("[CarrierLookup] Failed to store cache")
+	}
+}
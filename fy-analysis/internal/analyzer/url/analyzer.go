@@ -1,19 +1,20 @@
 package url
 
 import (
+	"net"
 	"net/url"
-	"regexp"
 	"strings"
 	"time"
 
 	"github.com/trackfy/fy-analysis/internal/models"
+	"github.com/trackfy/fy-analysis/internal/tldrisk"
 )
 
 // Analyzer maneja el análisis de URLs
 type Analyzer struct {
 	maliciousDomains  map[string]bool
 	shortenerDomains  map[string]bool
-	suspiciousTLDs    map[string]bool
+	suspiciousTLDs    *tldrisk.Table
 	phishingKeywords  []string
 }
 
@@ -22,7 +23,7 @@ func NewAnalyzer() *Analyzer {
 	return &Analyzer{
 		maliciousDomains: loadMaliciousDomains(),
 		shortenerDomains: loadShortenerDomains(),
-		suspiciousTLDs:   loadSuspiciousTLDs(),
+		suspiciousTLDs:   tldrisk.Default,
 		phishingKeywords: loadPhishingKeywords(),
 	}
 }
@@ -182,14 +183,13 @@ func (a *Analyzer) heuristicAnalysis(parsedURL *url.URL, urlInfo *models.URLInfo
 
 	// 1. Verificar TLD sospechoso
 	tld := extractTLD(domain)
-	if a.suspiciousTLDs[tld] {
+	if a.suspiciousTLDs.Points(tld) > 0 {
 		result.score += 0.2
 		result.reasons = append(result.reasons, "TLD frecuentemente usado en sitios maliciosos")
 	}
 
-	// 2. Verificar IP en lugar de dominio
-	ipRegex := regexp.MustCompile(`^\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}$`)
-	if ipRegex.MatchString(domain) {
+	// 2. Verificar IP (IPv4 o IPv6) en lugar de dominio
+	if net.ParseIP(domain) != nil {
 		result.score += 0.4
 		result.reasons = append(result.reasons, "URL usa dirección IP en lugar de dominio")
 	}
@@ -308,22 +308,6 @@ func loadShortenerDomains() map[string]bool {
 	}
 }
 
-func loadSuspiciousTLDs() map[string]bool {
-	return map[string]bool{
-		"tk":      true,
-		"ml":      true,
-		"ga":      true,
-		"cf":      true,
-		"gq":      true,
-		"xyz":     true,
-		"top":     true,
-		"work":    true,
-		"click":   true,
-		"link":    true,
-		"download": true,
-	}
-}
-
 func loadPhishingKeywords() []string {
 	return []string{
 		"login", "signin", "account", "verify", "secure", "update",
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/trackfy/fy-analysis/internal/urlengine"
+)
+
+// AdminHandler endpoints internos de operación (re-descarga de feeds y estado)
+type AdminHandler struct {
+	engine *urlengine.Engine
+}
+
+// NewAdminHandler crea el handler de administración
+func NewAdminHandler(engine *urlengine.Engine) *AdminHandler {
+	return &AdminHandler{engine: engine}
+}
+
+// Sync maneja POST /admin/sync?db=urlhaus|phishtank|all - re-descarga asíncrona
+func (h *AdminHandler) Sync(w http.ResponseWriter, r *http.Request) {
+	dbName := r.URL.Query().Get("db")
+	if dbName == "" {
+		dbName = "all"
+	}
+
+	err := h.engine.StartDBSync(dbName)
+	switch {
+	case errors.Is(err, urlengine.ErrDBSyncDisabled):
+		respondWithError(w, http.StatusServiceUnavailable, "SYNC_DISABLED", "La sincronización de DBs está deshabilitada")
+		return
+	case errors.Is(err, urlengine.ErrSyncInProgress):
+		respondWithError(w, http.StatusConflict, "SYNC_IN_PROGRESS", "Ya hay una sincronización en curso para "+dbName)
+		return
+	case err != nil:
+		respondWithError(w, http.StatusBadRequest, "INVALID_DB", "DB inválida. Usar: urlhaus, phishtank, all")
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{
+		"status": "sync_started",
+		"db":     dbName,
+	})
+}
+
+// Status maneja GET /admin/status - entradas, last_update y sync en curso por DB
+func (h *AdminHandler) Status(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, h.engine.GetStatus())
+}
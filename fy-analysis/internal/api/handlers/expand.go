@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+
+	"github.com/trackfy/fy-analysis/internal/urlengine"
+)
+
+const (
+	// expandTimeout máximo para seguir la cadena de redirects
+	expandTimeout = 5 * time.Second
+	// expandFailureTTL las expansiones fallidas se cachean menos (el fallo puede ser puntual)
+	expandFailureTTL = 5 * time.Minute
+	// maxExpandURLLength longitud máxima de la URL a expandir
+	maxExpandURLLength = 2048
+	// maxExpandBodySize límite del body (endpoint público)
+	maxExpandBodySize = 8 << 10
+	// maxMemoryExpandEntries entradas de la cache en memoria
+	maxMemoryExpandEntries = 10000
+	expandKeyPrefix        = "expand:"
+)
+
+var urlExpansions = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "url_expansions_total",
+	Help: "Peticiones a /api/v1/expand por resultado (expanded, failed, cached)",
+}, []string{"result"})
+
+// ExpandCache cache de expansiones por URL (los acortadores casi nunca cambian su destino)
+type ExpandCache interface {
+	Get(ctx context.Context, rawURL string) (*urlengine.ExpandResult, bool)
+	Set(ctx context.Context, rawURL string, result *urlengine.ExpandResult, ttl time.Duration)
+}
+
+// expandCacheKey la URL tal cual (sin recortar mayúsculas: en los acortadores el path distingue)
+func expandCacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return expandKeyPrefix + hex.EncodeToString(sum[:])
+}
+
+// RedisExpandCache cache compartida entre réplicas
+type RedisExpandCache struct {
+	client *redis.Client
+}
+
+// NewRedisExpandCache crea la cache de expansiones sobre Redis
+func NewRedisExpandCache(client *redis.Client) *RedisExpandCache {
+	return &RedisExpandCache{client: client}
+}
+
+// Get lee la expansión cacheada; los errores de Redis cuentan como fallo de cache
+func (c *RedisExpandCache) Get(ctx context.Context, rawURL string) (*urlengine.ExpandResult, bool) {
+	data, err := c.client.Get(ctx, expandCacheKey(rawURL)).Bytes()
+	if err != nil {
+		if err != redis.Nil {
+			log.Warn().Err(err).Msg("[Expand] Redis cache read failed")
+		}
+		return nil, false
+	}
+	var result urlengine.ExpandResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	return &result, true
+}
+
+// Set guarda la expansión con su TTL
+func (c *RedisExpandCache) Set(ctx context.Context, rawURL string, result *urlengine.ExpandResult, ttl time.Duration) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := c.client.Set(ctx, expandCacheKey(rawURL), data, ttl).Err(); err != nil {
+		log.Warn().Err(err).Msg("[Expand] Redis cache write failed")
+	}
+}
+
+// MemoryExpandCache cache en memoria (una sola réplica o sin Redis)
+type MemoryExpandCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryExpandEntry
+}
+
+type memoryExpandEntry struct {
+	result    urlengine.ExpandResult
+	expiresAt time.Time
+}
+
+// NewMemoryExpandCache crea la cache de expansiones en memoria
+func NewMemoryExpandCache() *MemoryExpandCache {
+	return &MemoryExpandCache{entries: make(map[string]memoryExpandEntry)}
+}
+
+// Get lee la expansión cacheada si no ha caducado
+func (c *MemoryExpandCache) Get(ctx context.Context, rawURL string) (*urlengine.ExpandResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[expandCacheKey(rawURL)]
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	result := entry.result
+	return &result, true
+}
+
+// Set guarda la expansión; si la cache está llena purga las caducadas y, si no basta, no guarda
+func (c *MemoryExpandCache) Set(ctx context.Context, rawURL string, result *urlengine.ExpandResult, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.entries) >= maxMemoryExpandEntries {
+		for key, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, key)
+			}
+		}
+		if len(c.entries) >= maxMemoryExpandEntries {
+			return
+		}
+	}
+	c.entries[expandCacheKey(rawURL)] = memoryExpandEntry{result: *result, expiresAt: now.Add(ttl)}
+}
+
+// ExpandHandler maneja POST /api/v1/expand: solo expansión de URLs, sin checkers ni DB
+type ExpandHandler struct {
+	engine *urlengine.Engine
+	cache  ExpandCache
+	ttl    time.Duration
+}
+
+// NewExpandHandler crea el handler de expansión. ttl es el de las expansiones correctas.
+func NewExpandHandler(engine *urlengine.Engine, cache ExpandCache, ttl time.Duration) *ExpandHandler {
+	if cache == nil {
+		cache = NewMemoryExpandCache()
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &ExpandHandler{engine: engine, cache: cache, ttl: ttl}
+}
+
+// ExpandRequest petición de expansión
+type ExpandRequest struct {
+	URL string `json:"url"`
+}
+
+// Expand maneja POST /api/v1/expand
+func (h *ExpandHandler) Expand(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+
+	var req ExpandRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExpandBodySize)).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_JSON", "Error al parsear el JSON")
+		return
+	}
+
+	rawURL := strings.TrimSpace(req.URL)
+	if rawURL == "" {
+		respondWithError(w, http.StatusBadRequest, "MISSING_URL", "El campo 'url' es requerido")
+		return
+	}
+	if len(rawURL) > maxExpandURLLength {
+		respondWithError(w, http.StatusBadRequest, "URL_TOO_LONG", "La URL es demasiado larga")
+		return
+	}
+
+	if cached, ok := h.cache.Get(r.Context(), rawURL); ok {
+		urlExpansions.WithLabelValues("cached").Inc()
+		cached.Cached = true
+		cached.ExpansionTimeMs = time.Since(start).Milliseconds()
+		respondWithJSON(w, http.StatusOK, cached)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), expandTimeout)
+	defer cancel()
+
+	result, err := h.engine.Expand(ctx, rawURL)
+	if errors.Is(err, urlengine.ErrExpandInvalidURL) {
+		respondWithError(w, http.StatusBadRequest, "INVALID_URL", "La URL no es válida")
+		return
+	}
+	if r.Context().Err() != nil {
+		// El cliente se fue: la cadena está incompleta y no se cachea
+		return
+	}
+
+	ttl := h.ttl
+	if result.Expanded == nil {
+		ttl = expandFailureTTL
+		urlExpansions.WithLabelValues("failed").Inc()
+	} else {
+		urlExpansions.WithLabelValues("expanded").Inc()
+	}
+	h.cache.Set(r.Context(), rawURL, result, ttl)
+
+	respondWithJSON(w, http.StatusOK, result)
+}
@@ -3,6 +3,10 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
 
 	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/urlengine"
@@ -36,17 +40,20 @@ type FyEngineResponse struct {
 
 // URLRequest petición de análisis de URL
 type URLRequest struct {
-	URL string `json:"url"`
+	URL            string   `json:"url"`
+	AllowlistItems []string `json:"allowlist_items,omitempty"` // Lista de confianza del usuario
 }
 
 // EmailRequest petición de análisis de email
 type EmailRequest struct {
-	Email string `json:"email"`
+	Email          string   `json:"email"`
+	AllowlistItems []string `json:"allowlist_items,omitempty"` // Lista de confianza del usuario
 }
 
 // PhoneRequest petición de análisis de teléfono
 type PhoneRequest struct {
-	Phone string `json:"phone"`
+	Phone          string   `json:"phone"`
+	AllowlistItems []string `json:"allowlist_items,omitempty"` // Lista de confianza del usuario
 }
 
 // convertToFyEngineResponse convierte el resultado del engine al formato de fy-engine
@@ -105,10 +112,17 @@ func (h *FyEngineHandler) AnalyzeURL(w http.ResponseWriter, r *http.Request) {
 
 	// Ejecutar análisis
 	engineReq := &urlengine.AnalysisRequest{
-		Input: req.URL,
-		Type:  checkers.InputTypeURL,
+		Input:          req.URL,
+		Type:           checkers.InputTypeURL,
+		AllowlistItems: req.AllowlistItems,
 	}
 
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
+	}
+	defer release()
+
 	result := h.engine.Analyze(r.Context(), engineReq)
 	response := convertToFyEngineResponse(result, "url", req.URL)
 
@@ -131,9 +145,16 @@ func (h *FyEngineHandler) AnalyzeEmail(w http.ResponseWriter, r *http.Request) {
 
 	// Ejecutar análisis
 	engineReq := &urlengine.AnalysisRequest{
-		Input: req.Email,
-		Type:  checkers.InputTypeEmail,
+		Input:          req.Email,
+		Type:           checkers.InputTypeEmail,
+		AllowlistItems: req.AllowlistItems,
+	}
+
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
 	}
+	defer release()
 
 	result := h.engine.Analyze(r.Context(), engineReq)
 	response := convertToFyEngineResponse(result, "email", req.Email)
@@ -157,9 +178,16 @@ func (h *FyEngineHandler) AnalyzePhone(w http.ResponseWriter, r *http.Request) {
 
 	// Ejecutar análisis
 	engineReq := &urlengine.AnalysisRequest{
-		Input: req.Phone,
-		Type:  checkers.InputTypePhone,
+		Input:          req.Phone,
+		Type:           checkers.InputTypePhone,
+		AllowlistItems: req.AllowlistItems,
+	}
+
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
 	}
+	defer release()
 
 	result := h.engine.Analyze(r.Context(), engineReq)
 	response := convertToFyEngineResponse(result, "phone", req.Phone)
@@ -167,6 +195,29 @@ func (h *FyEngineHandler) AnalyzePhone(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, response)
 }
 
+// LookupPhone maneja GET /analyze/phone/{number}
+// Respuesta compacta para identificación de llamadas (integración de call screening)
+func (h *FyEngineHandler) LookupPhone(w http.ResponseWriter, r *http.Request) {
+	number := chi.URLParam(r, "number")
+	if decoded, err := url.PathUnescape(number); err == nil {
+		number = decoded
+	}
+
+	if strings.TrimSpace(number) == "" {
+		respondWithError(w, http.StatusBadRequest, "MISSING_PHONE", "El número es requerido")
+		return
+	}
+
+	// Caller-ID: solo DB local, heurísticas y cache
+	release, ok := admitAnalysis(w, r, h.engine, urlengine.CostCheap)
+	if !ok {
+		return
+	}
+	defer release()
+
+	respondWithJSON(w, http.StatusOK, h.engine.LookupPhone(r.Context(), number))
+}
+
 // respondError responde con un error en formato fy-engine
 func (h *FyEngineHandler) respondError(w http.ResponseWriter, inputType, content, reason string) {
 	response := &FyEngineResponse{
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/trackfy/fy-analysis/internal/models"
+	"github.com/trackfy/fy-analysis/internal/urlengine"
 )
 
 const Version = "1.0.0"
@@ -18,3 +19,25 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	}
 	respondWithJSON(w, http.StatusOK, response)
 }
+
+// EngineHealthResponse health check con el estado de los checkers del engine
+type EngineHealthResponse struct {
+	models.HealthResponse
+	Checkers map[string]urlengine.CheckerHealth `json:"checkers"`
+}
+
+// EngineHealthCheck health check que incluye el estado real de los checkers.
+// Responde 200 también en "degraded": el servicio sigue funcionando con menos fuentes.
+func EngineHealthCheck(engine *urlengine.Engine) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health := engine.Health(r.Context())
+		respondWithJSON(w, http.StatusOK, EngineHealthResponse{
+			HealthResponse: models.HealthResponse{
+				Status:    health.Status,
+				Version:   Version,
+				Timestamp: time.Now().UTC(),
+			},
+			Checkers: health.Checkers,
+		})
+	}
+}
@@ -2,9 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
+	"github.com/trackfy/fy-analysis/internal/api/middleware"
 	"github.com/trackfy/fy-analysis/internal/models"
+	"github.com/trackfy/fy-analysis/internal/urlengine"
 )
 
 // respondWithJSON envía una respuesta JSON
@@ -22,3 +27,35 @@ func respondWithError(w http.ResponseWriter, statusCode int, code string, messag
 	}
 	respondWithJSON(w, statusCode, response)
 }
+
+// omitEngineInfo ?engine_info=false: el cliente no quiere engine_info en la respuesta
+// (clientes con poco ancho de banda)
+func omitEngineInfo(r *http.Request) bool {
+	include, err := strconv.ParseBool(r.URL.Query().Get("engine_info"))
+	return err == nil && !include
+}
+
+// admitAnalysis reserva el análisis en el engine (cuota del llamante y concurrencia).
+// Si se rechaza responde 429 con Retry-After y retorna ok = false; si no, el handler
+// debe llamar a release al terminar.
+func admitAnalysis(w http.ResponseWriter, r *http.Request, engine *urlengine.Engine, cost urlengine.AnalysisCost) (release func(), ok bool) {
+	release, err := engine.Admit(r.Context(), middleware.CallerID(r), cost)
+	if err == nil {
+		return release, true
+	}
+
+	var quotaErr *urlengine.QuotaError
+	if !errors.As(err, &quotaErr) {
+		// El cliente se fue mientras esperaba en cola
+		respondWithError(w, http.StatusServiceUnavailable, "REQUEST_CANCELLED", "La petición se canceló antes de empezar el análisis")
+		return nil, false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+	if quotaErr.Reason == "queue" {
+		respondWithError(w, http.StatusTooManyRequests, "TOO_BUSY", "Demasiados análisis en curso. Inténtalo de nuevo en unos segundos")
+	} else {
+		respondWithError(w, http.StatusTooManyRequests, "RATE_LIMITED", "Límite de análisis superado. Inténtalo de nuevo más tarde")
+	}
+	return nil, false
+}
@@ -4,7 +4,11 @@ import (
 	"encoding/json"
 	"net"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/urlengine"
 )
 
@@ -112,3 +116,102 @@ func (h *ReportsHandler) GetReportsStats(w http.ResponseWriter, r *http.Request)
 
 	respondWithJSON(w, http.StatusOK, stats)
 }
+
+// GetUserReportsSummary maneja GET /api/v1/reports/users/{userID}?year=2024&month=1
+func (h *ReportsHandler) GetUserReportsSummary(w http.ResponseWriter, r *http.Request) {
+	userID := chi.URLParam(r, "userID")
+
+	year, errYear := strconv.Atoi(r.URL.Query().Get("year"))
+	month, errMonth := strconv.Atoi(r.URL.Query().Get("month"))
+	if errYear != nil || errMonth != nil || month < 1 || month > 12 {
+		respondWithError(w, http.StatusBadRequest, "INVALID_PERIOD", "Los parámetros 'year' y 'month' son requeridos")
+		return
+	}
+
+	from := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	summary, err := h.engine.GetUserReportsSummary(r.Context(), userID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, summary)
+}
+
+// AnonymizeUserReports maneja DELETE /api/v1/reports/users/{userID} (borrado de cuenta en el gateway)
+func (h *ReportsHandler) AnonymizeUserReports(w http.ResponseWriter, r *http.Request) {
+	anonymized, err := h.engine.AnonymizeUserReports(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"anonymized": anonymized})
+}
+
+// GetUserNotifications maneja GET /api/v1/reports/users/{userID}/notifications?limit=20
+func (h *ReportsHandler) GetUserNotifications(w http.ResponseWriter, r *http.Request) {
+	limit := 20
+	if l, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && l > 0 && l <= 100 {
+		limit = l
+	}
+
+	notifications, err := h.engine.GetUserNotifications(r.Context(), chi.URLParam(r, "userID"), limit)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, notifications)
+}
+
+// MarkUserNotificationsRead maneja POST /api/v1/reports/users/{userID}/notifications/read
+func (h *ReportsHandler) MarkUserNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	marked, err := h.engine.MarkUserNotificationsRead(r.Context(), chi.URLParam(r, "userID"))
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, map[string]int64{"marked": marked})
+}
+
+// WhitelistRequestBody petición de un usuario para añadir un dominio a la whitelist
+type WhitelistRequestBody struct {
+	UserID      string `json:"user_id"`
+	Domain      string `json:"domain"`
+	Brand       string `json:"brand"`
+	Reason      string `json:"reason"`
+	EvidenceURL string `json:"evidence_url"`
+}
+
+// SubmitWhitelistRequest maneja POST /api/v1/whitelist/requests (desde api-gateway)
+func (h *ReportsHandler) SubmitWhitelistRequest(w http.ResponseWriter, r *http.Request) {
+	var req WhitelistRequestBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_JSON", "Error al parsear el JSON")
+		return
+	}
+	if req.UserID == "" {
+		respondWithError(w, http.StatusBadRequest, "MISSING_USER_ID", "El campo 'user_id' es requerido")
+		return
+	}
+
+	result, err := h.engine.SubmitWhitelistRequest(r.Context(), &checkers.WhitelistRequest{
+		UserID:      req.UserID,
+		Domain:      req.Domain,
+		Brand:       req.Brand,
+		Reason:      req.Reason,
+		EvidenceURL: req.EvidenceURL,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+
+	if !result.Success || result.RequestID == "" {
+		respondWithJSON(w, http.StatusOK, result) // 200 pero con success=false, o ya existente
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, result)
+}
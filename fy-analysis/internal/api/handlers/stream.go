@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/urlengine"
+)
+
+// CheckerEvent evento SSE emitido al terminar cada checker
+type CheckerEvent struct {
+	Checker    string  `json:"checker"`
+	Found      bool    `json:"found"`
+	LatencyMs  int64   `json:"latency_ms"`
+	ThreatType string  `json:"threat_type,omitempty"`
+	Confidence float64 `json:"confidence,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// FinalEvent último evento SSE con el resultado agregado (mismos campos que /analyze)
+type FinalEvent struct {
+	Type string `json:"type"`
+	*urlengine.AnalysisResponse
+}
+
+// AnalyzeStream maneja POST /api/v1/analyze/stream - mismo body que /analyze,
+// responde por SSE un evento por checker, el resultado final y [DONE]
+func (h *URLEngineHandler) AnalyzeStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithError(w, http.StatusInternalServerError, "STREAMING_UNSUPPORTED", "El servidor no soporta streaming")
+		return
+	}
+
+	engineReq, ok := decodeAnalyzeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// Antes de empezar el stream: el 429 tiene que ir en la cabecera de la respuesta
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Nginx: no bufferizar el stream
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	// onResult se ejecuta en esta goroutine: las escrituras no necesitan lock
+	result := h.engine.AnalyzeStream(r.Context(), engineReq, func(res *checkers.CheckResult) {
+		event := CheckerEvent{
+			Checker:   res.Source,
+			Found:     res.Found,
+			LatencyMs: res.Latency.Milliseconds(),
+		}
+		if res.Found {
+			event.ThreatType = res.ThreatType
+			event.Confidence = res.Confidence
+		}
+		if res.Error != nil {
+			event.Error = res.Error.Error()
+		}
+		writeSSE(w, flusher, event)
+	})
+
+	if omitEngineInfo(r) {
+		result.EngineInfo = nil
+	}
+	writeSSE(w, flusher, FinalEvent{Type: "final", AnalysisResponse: result})
+	fmt.Fprint(w, "data: [DONE]\n\n")
+	flusher.Flush()
+}
+
+// writeSSE envía un evento data: con el payload en JSON
+func writeSSE(w http.ResponseWriter, flusher http.Flusher, payload interface{}) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+	flusher.Flush()
+}
+
+// acceptsEventStream indica si el cliente pide SSE (Accept: text/event-stream)
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
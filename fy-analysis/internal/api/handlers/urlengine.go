@@ -3,6 +3,7 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/urlengine"
@@ -34,8 +35,14 @@ func (h *URLEngineHandler) CheckURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(&urlengine.AnalysisRequest{Input: req.URL, Type: checkers.InputTypeURL}))
+	if !ok {
+		return
+	}
+	defer release()
+
 	// Ejecutar verificación
-	result := h.engine.Check(r.Context(), req.URL)
+	result := h.engine.Check(r.Context(), req.URL, req.Debug)
 
 	respondWithJSON(w, http.StatusOK, result)
 }
@@ -43,46 +50,195 @@ func (h *URLEngineHandler) CheckURL(w http.ResponseWriter, r *http.Request) {
 // AnalyzeRequest estructura de la petición de análisis unificado
 type AnalyzeRequest struct {
 	Input   string `json:"input"`
-	Type    string `json:"type"` // url, email, phone
+	Type    string `json:"type"` // url, email, phone, hash (opcional: se detecta si falta)
 	Context *struct {
 		ClaimedSender string `json:"claimed_sender,omitempty"`
 		MessageType   string `json:"message_type,omitempty"`
 		OriginalText  string `json:"original_text,omitempty"`
 	} `json:"context,omitempty"`
+	Debug bool `json:"debug,omitempty"` // Incluir RawData de cada fuente en la respuesta
+	// TimeoutMs timeout de los checkers en ms (opcional, el engine lo limita a su mínimo/máximo)
+	TimeoutMs int `json:"timeout_ms,omitempty"`
 }
 
-// Analyze maneja POST /api/v1/analyze - Endpoint unificado
+// Analyze maneja POST /api/v1/analyze - Endpoint unificado.
+// Con Accept: text/event-stream responde como AnalyzeStream.
 func (h *URLEngineHandler) Analyze(w http.ResponseWriter, r *http.Request) {
-	var req AnalyzeRequest
+	if acceptsEventStream(r) {
+		h.AnalyzeStream(w, r)
+		return
+	}
+
+	engineReq, ok := decodeAnalyzeRequest(w, r)
+	if !ok {
+		return
+	}
+
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
+	}
+	defer release()
+
+	// Ejecutar análisis
+	result := h.engine.Analyze(r.Context(), engineReq)
+	if omitEngineInfo(r) {
+		result.EngineInfo = nil
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// AnalyzePhoneRequest estructura de la petición de análisis completo de un teléfono
+type AnalyzePhoneRequest struct {
+	Phone   string `json:"phone"`
+	Context *struct {
+		ClaimedSender string `json:"claimed_sender,omitempty"`
+		MessageType   string `json:"message_type,omitempty"`
+		OriginalText  string `json:"original_text,omitempty"`
+	} `json:"context,omitempty"`
+	AllowlistItems []string `json:"allowlist_items,omitempty"`
+	Debug          bool     `json:"debug,omitempty"`
+}
+
+// AnalyzePhone maneja POST /api/v1/analyze/phone/full - análisis del Engine para
+// teléfonos, con la información de línea (país, tipo, premium) en phone_info
+func (h *URLEngineHandler) AnalyzePhone(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzePhoneRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_JSON", "Error al parsear el JSON")
+		return
+	}
+
+	if strings.TrimSpace(req.Phone) == "" {
+		respondWithError(w, http.StatusBadRequest, "MISSING_PHONE", "El campo 'phone' es requerido")
+		return
+	}
+
+	engineReq := &urlengine.AnalysisRequest{
+		Input:          req.Phone,
+		Type:           checkers.InputTypePhone,
+		AllowlistItems: req.AllowlistItems,
+		Debug:          req.Debug,
+	}
+	if req.Context != nil {
+		engineReq.Context = &checkers.AnalysisContext{
+			ClaimedSender: req.Context.ClaimedSender,
+			MessageType:   req.Context.MessageType,
+			OriginalText:  req.Context.OriginalText,
+		}
+	}
+
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.AnalysisCost(engineReq))
+	if !ok {
+		return
+	}
+	defer release()
+
+	result := h.engine.Analyze(r.Context(), engineReq)
+	if omitEngineInfo(r) {
+		result.EngineInfo = nil
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// maxMessageLength longitud máxima del texto de /analyze/message
+const maxMessageLength = 10000
+
+// AnalyzeMessageRequest estructura de la petición de análisis de un mensaje completo
+type AnalyzeMessageRequest struct {
+	Text    string `json:"text"`
+	Context *struct {
+		ClaimedSender string `json:"claimed_sender,omitempty"`
+		MessageType   string `json:"message_type,omitempty"`
+	} `json:"context,omitempty"`
+}
+
+// AnalyzeMessage maneja POST /api/v1/analyze/message - extrae y analiza
+// las URLs, emails y teléfonos de un mensaje pegado por el usuario
+func (h *URLEngineHandler) AnalyzeMessage(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzeMessageRequest
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		respondWithError(w, http.StatusBadRequest, "INVALID_JSON", "Error al parsear el JSON")
 		return
 	}
 
+	if strings.TrimSpace(req.Text) == "" {
+		respondWithError(w, http.StatusBadRequest, "MISSING_TEXT", "El campo 'text' es requerido")
+		return
+	}
+	if len(req.Text) > maxMessageLength {
+		respondWithError(w, http.StatusBadRequest, "TEXT_TOO_LONG", "El mensaje es demasiado largo")
+		return
+	}
+
+	release, ok := admitAnalysis(w, r, h.engine, h.engine.MessageCost(req.Text))
+	if !ok {
+		return
+	}
+	defer release()
+
+	engineReq := &urlengine.MessageAnalysisRequest{Text: req.Text}
+	if req.Context != nil {
+		engineReq.Context = &checkers.AnalysisContext{
+			ClaimedSender: req.Context.ClaimedSender,
+			MessageType:   req.Context.MessageType,
+		}
+	}
+
+	result := h.engine.AnalyzeMessage(r.Context(), engineReq)
+	if omitEngineInfo(r) {
+		result.EngineInfo = nil
+	}
+
+	respondWithJSON(w, http.StatusOK, result)
+}
+
+// decodeAnalyzeRequest parsea y valida el body de /analyze (responde el error si no es válido)
+func decodeAnalyzeRequest(w http.ResponseWriter, r *http.Request) (*urlengine.AnalysisRequest, bool) {
+	var req AnalyzeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		respondWithError(w, http.StatusBadRequest, "INVALID_JSON", "Error al parsear el JSON")
+		return nil, false
+	}
+
 	if req.Input == "" {
 		respondWithError(w, http.StatusBadRequest, "MISSING_INPUT", "El campo 'input' es requerido")
-		return
+		return nil, false
 	}
 
-	// Validar tipo
+	// Validar tipo (vacío: el engine lo detecta a partir del input)
 	var inputType checkers.InputType
 	switch req.Type {
-	case "url", "":
+	case "":
+	case "url":
 		inputType = checkers.InputTypeURL
 	case "email":
 		inputType = checkers.InputTypeEmail
 	case "phone":
 		inputType = checkers.InputTypePhone
+	case "hash":
+		inputType = checkers.InputTypeHash
 	default:
-		respondWithError(w, http.StatusBadRequest, "INVALID_TYPE", "Tipo inválido. Usar: url, email, phone")
-		return
+		respondWithError(w, http.StatusBadRequest, "INVALID_TYPE", "Tipo inválido. Usar: url, email, phone, hash")
+		return nil, false
+	}
+
+	if req.TimeoutMs < 0 {
+		respondWithError(w, http.StatusBadRequest, "INVALID_TIMEOUT", "El campo 'timeout_ms' no puede ser negativo")
+		return nil, false
 	}
 
 	// Construir request del engine
 	engineReq := &urlengine.AnalysisRequest{
-		Input: req.Input,
-		Type:  inputType,
+		Input:     req.Input,
+		Type:      inputType,
+		Debug:     req.Debug,
+		TimeoutMs: req.TimeoutMs,
 	}
 
 	// Añadir contexto si existe
@@ -94,10 +250,7 @@ func (h *URLEngineHandler) Analyze(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Ejecutar análisis
-	result := h.engine.Analyze(r.Context(), engineReq)
-
-	respondWithJSON(w, http.StatusOK, result)
+	return engineReq, true
 }
 
 // GetStatus maneja GET /api/v1/urlengine/status
@@ -106,6 +259,26 @@ func (h *URLEngineHandler) GetStatus(w http.ResponseWriter, r *http.Request) {
 	respondWithJSON(w, http.StatusOK, status)
 }
 
+// GetDBStatus maneja GET /api/v1/status/db
+func (h *URLEngineHandler) GetDBStatus(w http.ResponseWriter, r *http.Request) {
+	stats := h.engine.LocalDBPoolStats()
+	if stats == nil {
+		respondWithJSON(w, http.StatusOK, map[string]interface{}{"enabled": false})
+		return
+	}
+	respondWithJSON(w, http.StatusOK, map[string]interface{}{"enabled": true, "pool": stats})
+}
+
+// GetStats maneja GET /api/v1/stats (vista threat_metrics; "stale" si no se refresca hace más de 10 min)
+func (h *URLEngineHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	metrics, err := h.engine.GetThreatMetrics(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE", err.Error())
+		return
+	}
+	respondWithJSON(w, http.StatusOK, metrics)
+}
+
 // SyncDB maneja POST /api/v1/urlengine/sync
 func (h *URLEngineHandler) SyncDB(w http.ResponseWriter, r *http.Request) {
 	dbName := r.URL.Query().Get("db")
@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// HeaderCaller cabecera con la que api-gateway y fy-engine se identifican ante fy-analysis.
+// Las cuotas de análisis se llevan por llamante; sin cabecera, por IP.
+const HeaderCaller = "X-Trackfy-Caller"
+
+// maxCallerLength longitud máxima del identificador de llamante
+const maxCallerLength = 64
+
+// CallerID identificador del llamante: "caller:<X-Trackfy-Caller>" o "ip:<IP remota>"
+// (RemoteAddr ya viene resuelto por clientip.RealIP)
+func CallerID(r *http.Request) string {
+	if caller := strings.TrimSpace(r.Header.Get(HeaderCaller)); validCaller(caller) {
+		return "caller:" + strings.ToLower(caller)
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// validCaller acepta nombres de servicio cortos (letras, dígitos, '-', '_' y '.')
+func validCaller(caller string) bool {
+	if caller == "" || len(caller) > maxCallerLength {
+		return false
+	}
+	for _, c := range caller {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
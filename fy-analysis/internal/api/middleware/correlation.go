@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// HeaderCorrelationID identificador de la petición de usuario que api-gateway propaga a
+// todos los servicios
+const HeaderCorrelationID = "X-Correlation-ID"
+
+type correlationKey struct{}
+
+// CorrelationID toma el X-Correlation-ID de la petición (o genera uno si falta, p. ej. en
+// llamadas directas) y añade un logger con correlation_id al contexto (ver Log). Se devuelve
+// en la respuesta.
+func CorrelationID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(HeaderCorrelationID)
+		if !validCorrelationID(id) {
+			id = newCorrelationID()
+		}
+
+		logger := log.With().Str("correlation_id", id).Logger()
+		ctx := logger.WithContext(context.WithValue(r.Context(), correlationKey{}, id))
+
+		w.Header().Set(HeaderCorrelationID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetCorrelationID correlation ID del contexto ("" fuera de una petición)
+func GetCorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// Log logger de la petición (con correlation_id), o el global fuera de una petición
+func Log(ctx context.Context) *zerolog.Logger {
+	if GetCorrelationID(ctx) == "" {
+		return &log.Logger
+	}
+	return zerolog.Ctx(ctx)
+}
+
+// validCorrelationID mismas reglas que el identificador de llamante (UUIDs y tokens cortos)
+func validCorrelationID(id string) bool {
+	return validCaller(id)
+}
+
+// newCorrelationID UUID v4 aleatorio
+func newCorrelationID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/trackfy/fy-analysis/internal/models"
+)
+
+// HeaderInternalToken cabecera con el token de los endpoints /admin
+const HeaderInternalToken = "X-Internal-Token"
+
+// InternalToken exige el token interno en X-Internal-Token (401 si falta o no coincide)
+func InternalToken(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := r.Header.Get(HeaderInternalToken)
+			if provided == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				log.Warn().
+					Str("path", r.URL.Path).
+					Str("remote_addr", r.RemoteAddr).
+					Msg("[Admin] Invalid internal token")
+
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.ErrorResponse{
+					Error: "Token interno inválido",
+					Code:  "UNAUTHORIZED",
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package api
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/trackfy/fy-analysis/internal/api/handlers"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/models"
+	"github.com/trackfy/fy-analysis/internal/urlengine"
+	"github.com/trackfy/pkg/openapi"
+)
+
+// openapi.json se regenera con go generate ./internal/api (o make openapi) tras cambiar
+// rutas o tipos; CI falla si el fichero commiteado no coincide con OpenAPIDocument.
+//
+//go:generate go run ../../cmd/openapi-gen -o openapi.json
+
+//go:embed openapi.json
+var openAPISpec []byte
+
+// Cuerpos que los handlers construyen como map: solo existen para documentarlos
+
+// SyncStartedResponse respuesta de las sincronizaciones de DBs
+type SyncStartedResponse struct {
+	Status string `json:"status"` // sync_started
+	DB     string `json:"db"`
+}
+
+// DBStatusResponse estado del pool de la DB local
+type DBStatusResponse struct {
+	Enabled bool                `json:"enabled"`
+	Pool    *checkers.PoolStats `json:"pool,omitempty"`
+}
+
+// AnonymizedResponse reportes anonimizados
+type AnonymizedResponse struct {
+	Anonymized int64 `json:"anonymized"`
+}
+
+// MarkedResponse notificaciones marcadas como leídas
+type MarkedResponse struct {
+	Marked int64 `json:"marked"`
+}
+
+const (
+	tagSystem   = "system"
+	tagAnalyze  = "analyze"
+	tagLegacy   = "legacy"
+	tagFyEngine = "fy-engine"
+	tagReports  = "reports"
+	tagStatus   = "status"
+	tagAdmin    = "admin"
+)
+
+// OpenAPIDocument documento OpenAPI de las rutas de NewRouterWithConfig con URL engine y
+// AdminToken. cmd/openapi-gen comprueba que no falte ninguna.
+func OpenAPIDocument() *openapi.Document {
+	doc := openapi.New("Trackfy fy-analysis", handlers.Version,
+		"API interna de análisis de URLs, emails, teléfonos y mensajes. La usan api-gateway, fy-engine y fy-admin.")
+
+	doc.AddSecurityScheme("signature", &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: "X-Trackfy-Signature",
+		Description: "HMAC-SHA256 con INTERNAL_SIGNING_SECRET; requiere también X-Trackfy-Timestamp y X-Trackfy-Nonce. Sin secreto configurado no se valida.",
+	}, true)
+	doc.AddSecurityScheme("internalToken", &openapi.SecurityScheme{
+		Type: "apiKey", In: "header", Name: "X-Internal-Token",
+		Description: "ADMIN_TOKEN (solo /admin)",
+	}, false)
+
+	doc.AddTag(tagSystem, "Salud, métricas y documentación")
+	doc.AddTag(tagAnalyze, "Análisis con el URL engine")
+	doc.AddTag(tagLegacy, "Analizadores básicos y endpoints legacy")
+	doc.AddTag(tagFyEngine, "Formato compatible con fy-engine")
+	doc.AddTag(tagReports, "Reportes de usuarios, notificaciones y propuestas de whitelist")
+	doc.AddTag(tagStatus, "Estado de las fuentes y de la DB local")
+	doc.AddTag(tagAdmin, "Operación interna desde fy-admin")
+
+	e := models.ErrorResponse{}
+	// Respuestas comunes de los análisis: body inválido, cuota y cola (admitAnalysis)
+	analysisErrors := []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable}
+
+	// Sistema
+	doc.Op(http.MethodGet, "/health", tagSystem, "Estado del servicio y de los checkers").Public().
+		JSON(http.StatusOK, handlers.EngineHealthResponse{})
+	doc.Op(http.MethodGet, "/metrics", tagSystem, "Métricas Prometheus").Public().
+		Content(http.StatusOK, "text/plain", "Métricas en formato de exposición de Prometheus")
+	doc.Op(http.MethodGet, "/api/openapi.json", tagSystem, "Este documento OpenAPI").Public().
+		Content(http.StatusOK, "application/json", "Documento OpenAPI 3.0")
+	doc.Op(http.MethodGet, "/docs", tagSystem, "Swagger UI").Public().
+		Content(http.StatusOK, "text/html", "Página de Swagger UI")
+
+	// Analizadores básicos
+	doc.Op(http.MethodPost, "/api/v1/analyze/email", tagLegacy, "Análisis básico de email").
+		Body(models.EmailAnalysisRequest{}).
+		JSON(http.StatusOK, models.EmailAnalysisResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/analyze/url", tagLegacy, "Análisis básico de URL").
+		Body(models.URLAnalysisRequest{}).
+		JSON(http.StatusOK, models.URLAnalysisResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/analyze/phone", tagLegacy, "Análisis básico de teléfono").
+		Body(models.PhoneAnalysisRequest{}).
+		JSON(http.StatusOK, models.PhoneAnalysisResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/analyze/batch", tagLegacy, "Análisis básico en lote (máximo 100 elementos)").
+		Body(models.BatchAnalysisRequest{}).
+		JSON(http.StatusOK, models.BatchAnalysisResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized)
+
+	// URL engine
+	doc.Op(http.MethodPost, "/api/v1/analyze", tagAnalyze, "Análisis unificado de URL, email, teléfono o hash de fichero").
+		Describe("Con Accept: text/event-stream responde como /api/v1/analyze/stream.").
+		Query("engine_info", "boolean", "false omite engine_info de la respuesta").
+		Body(handlers.AnalyzeRequest{}).
+		JSON(http.StatusOK, urlengine.AnalysisResponse{}).
+		Content(http.StatusOK, "text/event-stream", "Stream de eventos (Accept: text/event-stream)").
+		Errors(e, analysisErrors...)
+	doc.Op(http.MethodPost, "/api/v1/analyze/stream", tagAnalyze, "Análisis unificado con resultados por SSE").
+		Describe("Eventos data: con JSON: un CheckerEvent por checker, FinalEvent (type=final) con el resultado agregado y data: [DONE] al terminar.").
+		Body(handlers.AnalyzeRequest{}).
+		Content(http.StatusOK, "text/event-stream", "Stream de eventos por checker").
+		Errors(e, append(analysisErrors, http.StatusInternalServerError)...)
+	// Los eventos del stream no aparecen en ninguna respuesta JSON: se registran a mano
+	doc.SchemaOf(handlers.CheckerEvent{})
+	doc.SchemaOf(handlers.FinalEvent{})
+	doc.Op(http.MethodPost, "/api/v1/analyze/message", tagAnalyze, "Análisis de un mensaje completo").
+		Describe("Extrae y analiza las URLs, emails y teléfonos del texto (máximo 10000 caracteres).").
+		Query("engine_info", "boolean", "false omite engine_info de la respuesta").
+		Body(handlers.AnalyzeMessageRequest{}).
+		JSON(http.StatusOK, urlengine.MessageAnalysisResponse{}).
+		Errors(e, analysisErrors...)
+	doc.Op(http.MethodPost, "/api/v1/analyze/phone/full", tagAnalyze, "Análisis de teléfono con información de línea").
+		Query("engine_info", "boolean", "false omite engine_info de la respuesta").
+		Body(handlers.AnalyzePhoneRequest{}).
+		JSON(http.StatusOK, urlengine.AnalysisResponse{}).
+		Errors(e, analysisErrors...)
+
+	doc.Op(http.MethodPost, "/api/v1/expand", tagAnalyze, "Expandir una URL acortada").Public().
+		Describe("Sigue los redirects sin checkers ni consultas a la DB. Público (sin firma), 30 peticiones/min por IP; "+
+			"resultados cacheados 1 hora. Si la cadena termina en 4xx/5xx o falla la conexión, expanded es null y error = expansion_failed.").
+		Body(handlers.ExpandRequest{}).
+		JSON(http.StatusOK, urlengine.ExpandResult{}).
+		Errors(e, http.StatusBadRequest, http.StatusTooManyRequests)
+
+	// Estado
+	doc.Op(http.MethodGet, "/api/v1/status/db", tagStatus, "Pool de conexiones de la DB local").
+		JSON(http.StatusOK, DBStatusResponse{}).
+		Errors(e, http.StatusUnauthorized)
+	doc.Op(http.MethodGet, "/api/v1/stats", tagStatus, "Estadísticas de las tablas de amenazas").
+		JSON(http.StatusOK, checkers.ThreatMetrics{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/urlengine/check", tagLegacy, "Verificación de URL (legacy)").
+		Body(urlengine.URLCheckRequest{}).
+		JSON(http.StatusOK, urlengine.URLCheckResponse{}).
+		Errors(e, analysisErrors...)
+	doc.Op(http.MethodGet, "/api/v1/urlengine/status", tagStatus, "Estado de las DBs y checkers").
+		JSON(http.StatusOK, map[string]interface{}{}).
+		Errors(e, http.StatusUnauthorized)
+	doc.Op(http.MethodPost, "/api/v1/urlengine/sync", tagLegacy, "Sincronizar DBs (legacy)").
+		Query("db", "string", "urlhaus, phishtank o all (por defecto)").
+		JSON(http.StatusOK, SyncStartedResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusInternalServerError)
+
+	// Reportes de usuarios
+	doc.Op(http.MethodPost, "/api/v1/reports", tagReports, "Reportar una URL").
+		Describe("201 si el reporte se registra; 200 con success=false si se descarta.").
+		Body(handlers.ReportURLRequest{}).
+		JSON(http.StatusCreated, urlengine.ReportURLResponse{}).
+		JSON(http.StatusOK, urlengine.ReportURLResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized)
+	doc.Op(http.MethodGet, "/api/v1/reports/stats", tagReports, "Estadísticas de reportes").
+		JSON(http.StatusOK, map[string]interface{}{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/reports/users/{userID}", tagReports, "Reportes de un usuario en un mes").
+		Query("year", "integer", "Año (requerido)").
+		Query("month", "integer", "Mes 1-12 (requerido)").
+		JSON(http.StatusOK, checkers.UserReportsSummary{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodDelete, "/api/v1/reports/users/{userID}", tagReports, "Anonimizar los reportes de un usuario").
+		JSON(http.StatusOK, AnonymizedResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/api/v1/reports/users/{userID}/notifications", tagReports, "Notificaciones de un usuario").
+		Query("limit", "integer", "Máximo de notificaciones (1-100, por defecto 20)").
+		JSON(http.StatusOK, []checkers.UserNotification{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/reports/users/{userID}/notifications/read", tagReports, "Marcar notificaciones como leídas").
+		JSON(http.StatusOK, MarkedResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/api/v1/whitelist/requests", tagReports, "Proponer un dominio legítimo").
+		Describe("201 si se crea la propuesta; 200 si se rechaza o ya existía.").
+		Body(handlers.WhitelistRequestBody{}).
+		JSON(http.StatusCreated, checkers.WhitelistRequestResult{}).
+		JSON(http.StatusOK, checkers.WhitelistRequestResult{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusServiceUnavailable)
+
+	// Administración
+	doc.Op(http.MethodPost, "/admin/sync", tagAdmin, "Re-descargar feeds en segundo plano").
+		Security("signature", "internalToken").
+		Query("db", "string", "urlhaus, phishtank o all (por defecto)").
+		JSON(http.StatusAccepted, SyncStartedResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusConflict, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/admin/status", tagAdmin, "Entradas, last_update y sync en curso por DB").
+		Security("signature", "internalToken").
+		JSON(http.StatusOK, map[string]interface{}{}).
+		Errors(e, http.StatusUnauthorized)
+
+	// fy-engine
+	doc.Op(http.MethodPost, "/analyze/url", tagFyEngine, "Analizar URL (formato fy-engine)").
+		Describe("Un body inválido responde 200 con verdict=unknown y el motivo en reasons.").
+		Body(handlers.URLRequest{}).
+		JSON(http.StatusOK, handlers.FyEngineResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/analyze/email", tagFyEngine, "Analizar email (formato fy-engine)").
+		Describe("Un body inválido responde 200 con verdict=unknown y el motivo en reasons.").
+		Body(handlers.EmailRequest{}).
+		JSON(http.StatusOK, handlers.FyEngineResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	doc.Op(http.MethodPost, "/analyze/phone", tagFyEngine, "Analizar teléfono (formato fy-engine)").
+		Describe("Un body inválido responde 200 con verdict=unknown y el motivo en reasons.").
+		Body(handlers.PhoneRequest{}).
+		JSON(http.StatusOK, handlers.FyEngineResponse{}).
+		Errors(e, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+	doc.Op(http.MethodGet, "/analyze/phone/{number}", tagFyEngine, "Identificación de llamadas").
+		Describe("Solo DB local, heurísticas y cache: respuesta rápida para call screening.").
+		JSON(http.StatusOK, urlengine.PhoneLookupResponse{}).
+		Errors(e, http.StatusBadRequest, http.StatusUnauthorized, http.StatusTooManyRequests, http.StatusServiceUnavailable)
+
+	return doc
+}
@@ -1,21 +1,66 @@
 package api
 
 import (
+	"net"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-chi/httprate"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/trackfy/fy-analysis/internal/api/handlers"
 	customMiddleware "github.com/trackfy/fy-analysis/internal/api/middleware"
 	"github.com/trackfy/fy-analysis/internal/urlengine"
+	"github.com/trackfy/pkg/clientip"
+	"github.com/trackfy/pkg/httpgzip"
+	"github.com/trackfy/pkg/openapi"
+	"github.com/trackfy/pkg/signature"
 )
 
+const (
+	// maxSignedBodySize límite del body que se lee para calcular la firma (lotes de /analyze)
+	maxSignedBodySize = 10 << 20
+)
+
+// signatureExemptPaths rutas sin firma (healthchecks de Docker, scraping de Prometheus,
+// la documentación de la API y la expansión pública de URLs)
+var signatureExemptPaths = map[string]bool{
+	"/health":           true,
+	"/metrics":          true,
+	"/api/openapi.json": true,
+	"/docs":             true,
+	"/api/v1/expand":    true,
+}
+
+// compressedResponses respuestas enviadas con gzip por httpgzip
+var compressedResponses = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "trackfy_compressed_responses_total",
+	Help: "Respuestas HTTP enviadas comprimidas con gzip",
+})
+
 // RouterConfig configuración para el router
 type RouterConfig struct {
 	URLEngine *urlengine.Engine
+	RateLimit int // Requests por minuto por IP (0 = 100)
+
+	// Proxies inversos cuyo X-Forwarded-For/X-Real-IP se respeta (nil = ninguno: IP de la conexión)
+	TrustedProxies []*net.IPNet
+
+	// Firma HMAC de peticiones internas (vacío = sin validar)
+	SigningSecret string
+	NonceStore    signature.NonceStore // nil = nonces en memoria
+
+	// Token de los endpoints /admin (vacío = /admin deshabilitado)
+	AdminToken string
+
+	// POST /api/v1/expand: límite por IP (0 = 30/min) y cache (nil = en memoria, TTL 0 = 1h)
+	ExpandRateLimit int
+	ExpandCache     handlers.ExpandCache
+	ExpandCacheTTL  time.Duration
 }
 
 // NewRouter crea y configura el router de la API (versión legacy)
@@ -27,28 +72,62 @@ func NewRouter() *chi.Mux {
 func NewRouterWithConfig(config *RouterConfig) *chi.Mux {
 	r := chi.NewRouter()
 
-	// Middleware global
+	var trustedProxies []*net.IPNet
+	if config != nil {
+		trustedProxies = config.TrustedProxies
+	}
+
+	// Middleware global. Los rate limits por IP usan RemoteAddr: clientip.RealIP solo lo
+	// sustituye por X-Forwarded-For si lo pone un proxy de confianza (si no, rotar la cabecera
+	// en cada petición salta los límites)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	r.Use(customMiddleware.CorrelationID)
+	r.Use(customMiddleware.Tracing)
+	r.Use(clientip.RealIP(trustedProxies))
 	r.Use(customMiddleware.Logger)
 	r.Use(middleware.Recoverer)
-	r.Use(middleware.Compress(5))
+	r.Use(httpgzip.New(httpgzip.Options{OnCompress: compressedResponses.Inc}))
 
 	// CORS
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"*"}, // En producción, especificar dominios
 		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID"},
-		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-Request-ID", "X-Correlation-ID", "traceparent", "tracestate"},
+		ExposedHeaders:   []string{"X-Request-ID", "X-Correlation-ID"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
-	// Rate limiting: 100 requests por minuto por IP
-	r.Use(httprate.LimitByIP(100, time.Minute))
+	// Firma de peticiones internas (api-gateway, fy-engine, fy-admin)
+	if config != nil {
+		r.Use(signature.Middleware(signature.Options{
+			Secret:      config.SigningSecret,
+			Nonces:      config.NonceStore,
+			ExemptPaths: signatureExemptPaths,
+			MaxBodySize: maxSignedBodySize,
+		}))
+	}
 
-	// Health check
-	r.Get("/health", handlers.HealthCheck)
+	// Rate limiting: 100 requests por minuto por IP (configurable con RATE_LIMIT)
+	rateLimit := 100
+	if config != nil && config.RateLimit > 0 {
+		rateLimit = config.RateLimit
+	}
+	r.Use(httprate.LimitByIP(rateLimit, time.Minute))
+
+	// Health check (con el estado de los checkers si hay engine)
+	if config != nil && config.URLEngine != nil {
+		r.Get("/health", handlers.EngineHealthCheck(config.URLEngine))
+	} else {
+		r.Get("/health", handlers.HealthCheck)
+	}
+
+	// Métricas Prometheus
+	r.Handle("/metrics", promhttp.Handler())
+
+	// Documento OpenAPI y Swagger UI
+	r.Get("/api/openapi.json", openapi.SpecHandler(openAPISpec))
+	r.Get("/docs", openapi.SwaggerUIHandler("Trackfy fy-analysis", "/api/openapi.json"))
 
 	// API v1
 	r.Route("/api/v1", func(r chi.Router) {
@@ -72,6 +151,23 @@ func NewRouterWithConfig(config *RouterConfig) *chi.Mux {
 
 			// Endpoint unificado de análisis (recomendado)
 			r.Post("/analyze", urlEngineHandler.Analyze)
+			r.Post("/analyze/stream", urlEngineHandler.AnalyzeStream)    // SSE: resultados por checker
+			r.Post("/analyze/message", urlEngineHandler.AnalyzeMessage)  // Mensaje completo: extrae y analiza sus indicadores
+			r.Post("/analyze/phone/full", urlEngineHandler.AnalyzePhone) // Teléfono con información de línea (phone_info)
+
+			// Expansión de URLs (acortadores) sin análisis: pública, sin firma y con límite propio
+			expandRateLimit := config.ExpandRateLimit
+			if expandRateLimit <= 0 {
+				expandRateLimit = 30
+			}
+			expandHandler := handlers.NewExpandHandler(config.URLEngine, config.ExpandCache, config.ExpandCacheTTL)
+			r.With(httprate.LimitByIP(expandRateLimit, time.Minute)).Post("/expand", expandHandler.Expand)
+
+			// Estado del pool de conexiones de la DB local
+			r.Get("/status/db", urlEngineHandler.GetDBStatus)
+
+			// Estadísticas de las tablas de amenazas (vista materializada threat_metrics)
+			r.Get("/stats", urlEngineHandler.GetStats)
 
 			// Endpoints legacy para compatibilidad
 			r.Route("/urlengine", func(r chi.Router) {
@@ -82,12 +178,29 @@ func NewRouterWithConfig(config *RouterConfig) *chi.Mux {
 
 			// Endpoints de reportes de usuarios
 			r.Route("/reports", func(r chi.Router) {
-				r.Post("/", reportsHandler.ReportURL)           // POST /api/v1/reports
-				r.Get("/stats", reportsHandler.GetReportsStats) // GET /api/v1/reports/stats
+				r.Post("/", reportsHandler.ReportURL)                                                  // POST /api/v1/reports
+				r.Get("/stats", reportsHandler.GetReportsStats)                                        // GET /api/v1/reports/stats
+				r.Get("/users/{userID}", reportsHandler.GetUserReportsSummary)                         // GET /api/v1/reports/users/{id}?year=&month=
+				r.Delete("/users/{userID}", reportsHandler.AnonymizeUserReports)                       // DELETE /api/v1/reports/users/{id}
+				r.Get("/users/{userID}/notifications", reportsHandler.GetUserNotifications)            // GET /api/v1/reports/users/{id}/notifications?limit=
+				r.Post("/users/{userID}/notifications/read", reportsHandler.MarkUserNotificationsRead) // POST /api/v1/reports/users/{id}/notifications/read
 			})
+
+			// Propuestas de whitelist de usuarios (se revisan en fy-admin)
+			r.Post("/whitelist/requests", reportsHandler.SubmitWhitelistRequest)
 		}
 	})
 
+	// Administración interna (fy-admin): re-descarga de feeds y estado de las DBs
+	if config != nil && config.URLEngine != nil && config.AdminToken != "" {
+		adminHandler := handlers.NewAdminHandler(config.URLEngine)
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(customMiddleware.InternalToken(config.AdminToken))
+			r.Post("/sync", adminHandler.Sync)    // POST /admin/sync?db=urlhaus|phishtank|all
+			r.Get("/status", adminHandler.Status) // GET /admin/status
+		})
+	}
+
 	// Endpoints para Fy-Engine (formato compatible con Python service)
 	// Estos endpoints están en la raíz para compatibilidad con fy-engine
 	if config != nil && config.URLEngine != nil {
@@ -96,6 +209,7 @@ func NewRouterWithConfig(config *RouterConfig) *chi.Mux {
 			r.Post("/url", fyHandler.AnalyzeURL)
 			r.Post("/email", fyHandler.AnalyzeEmail)
 			r.Post("/phone", fyHandler.AnalyzePhone)
+			r.Get("/phone/{number}", fyHandler.LookupPhone) // Caller-ID rápido
 		})
 	}
 
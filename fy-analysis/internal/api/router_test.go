@@ -0,0 +1,49 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/trackfy/fy-analysis/internal/urlengine"
+	"github.com/trackfy/pkg/clientip"
+)
+
+func TestExpandRateLimitIgnoresForwardedHeaders(t *testing.T) {
+	trusted, _ := clientip.ParseTrustedProxies("172.18.0.0/16")
+	router := NewRouterWithConfig(&RouterConfig{
+		URLEngine:       &urlengine.Engine{},
+		ExpandRateLimit: 3,
+		TrustedProxies:  trusted,
+	})
+
+	expand := func(remoteAddr string, k int) int {
+		// Body inválido: el handler responde 400 sin expandir nada
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/expand", strings.NewReader("{"))
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-For", fmt.Sprintf("198.51.100.%d", k))
+		r.Header.Set("X-Real-IP", fmt.Sprintf("198.51.100.%d", k))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, r)
+		return rec.Code
+	}
+
+	// Un cliente directo que rota X-Forwarded-For sigue siendo la misma IP
+	for k := 1; k <= 3; k++ {
+		if code := expand("203.0.113.7:40000", k); code != http.StatusBadRequest {
+			t.Fatalf("request %d: status %d, want 400", k, code)
+		}
+	}
+	if code := expand("203.0.113.7:40001", 4); code != http.StatusTooManyRequests {
+		t.Errorf("rotating X-Forwarded-For: status %d, want 429", code)
+	}
+
+	// Detrás de un proxy de confianza cada cliente tiene su propio límite
+	for k := 10; k < 13; k++ {
+		if code := expand("172.18.0.5:50000", k); code != http.StatusBadRequest {
+			t.Errorf("client %d behind proxy: status %d, want 400", k, code)
+		}
+	}
+}
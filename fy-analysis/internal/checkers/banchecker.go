@@ -0,0 +1,173 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+)
+
+const (
+	// Baneo automático: más de autoBanMaxRejected reportes rechazados en autoBanWindow
+	autoBanMaxRejected = 20
+	autoBanWindow      = 7 * 24 * time.Hour
+	autoBanInterval    = time.Hour
+	autoBanTimeout     = 2 * time.Minute
+
+	// BannedReportMessage respuesta de ReportURL para usuarios baneados
+	BannedReportMessage = "Tu cuenta está suspendida por abuso del sistema de reportes"
+)
+
+var reportBansTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "report_bans_total",
+	Help: "Baneos del sistema de reportes (auto) y reportes bloqueados por baneo (blocked)",
+}, []string{"event"})
+
+// BanChecker comprueba y aplica los baneos del sistema de reportes (user_trust_scores).
+// Un usuario está baneado si tiene el bit 0 de flags y trust_score < 10.
+type BanChecker struct {
+	db *sql.DB
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewBanChecker crea el checker sobre la conexión de reportes
+func NewBanChecker(db *sql.DB) *BanChecker {
+	return &BanChecker{
+		db:     db,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// IsBanned indica si el usuario tiene los reportes suspendidos
+func (b *BanChecker) IsBanned(ctx context.Context, userID string) (bool, error) {
+	var banned bool
+	err := b.db.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_trust_scores
+			WHERE user_id = $1 AND `+flags.Banned.IsSet("flags")+` AND trust_score < 10
+		)
+	`, userID).Scan(&banned)
+	return banned, err
+}
+
+// Start revisa cada hora los usuarios con demasiados reportes rechazados
+func (b *BanChecker) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(autoBanInterval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, autoBanTimeout)
+			if _, err := b.AutoBan(runCtx); err != nil {
+				log.Warn().Err(err).Msg("[Bans] Auto-ban run failed")
+			}
+			cancel()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-b.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop detiene la revisión periódica
+func (b *BanChecker) Stop() {
+	b.stopOnce.Do(func() { close(b.stopCh) })
+}
+
+// AutoBan banea a los usuarios con más de autoBanMaxRejected reportes rechazados en los
+// últimos 7 días, les avisa con una notificación in-app y lo registra en audit_log.
+// Retorna el número de usuarios baneados.
+func (b *BanChecker) AutoBan(ctx context.Context) (int, error) {
+	reason := fmt.Sprintf("Más de %d reportes rechazados en 7 días", autoBanMaxRejected)
+
+	rows, err := b.db.QueryContext(ctx, `
+		WITH offenders AS (
+			SELECT uur.user_id, COUNT(*) AS rejected
+			FROM user_url_reports uur
+			JOIN reported_urls ru ON ru.url_hash = uur.url_hash
+			WHERE (uur.status = 'rejected' OR ru.status = 'rejected')
+			  AND COALESCE(ru.reviewed_at, uur.created_at) >= NOW() - $1::INTERVAL
+			GROUP BY uur.user_id
+			HAVING COUNT(*) > $2
+		)
+		UPDATE user_trust_scores t SET
+			flags = t.flags | `+flags.Banned.SQL()+`,
+			trust_before_ban = t.trust_score,
+			trust_score = 0,
+			ban_reason = $3,
+			banned_at = NOW(),
+			banned_by = 'auto',
+			updated_at = NOW()
+		FROM offenders o
+		WHERE t.user_id = o.user_id AND NOT (`+flags.Banned.IsSet("t.flags")+` AND t.trust_score < 10)
+		RETURNING t.user_id, o.rejected, t.trust_before_ban
+	`, fmt.Sprintf("%d seconds", int(autoBanWindow.Seconds())), autoBanMaxRejected, reason)
+	if err != nil {
+		return 0, err
+	}
+
+	type bannedUser struct {
+		userID      string
+		rejected    int
+		trustBefore int
+	}
+	var banned []bannedUser
+	for rows.Next() {
+		var u bannedUser
+		if err := rows.Scan(&u.userID, &u.rejected, &u.trustBefore); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		banned = append(banned, u)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, u := range banned {
+		reportBansTotal.WithLabelValues("auto").Inc()
+		log.Warn().
+			Str("user_id", u.userID).
+			Int("rejected_reports", u.rejected).
+			Msg("[Bans] User automatically banned from reporting")
+
+		if _, err := b.db.ExecContext(ctx, `
+			INSERT INTO user_notifications (user_id, type, title, body)
+			VALUES ($1, 'reports_suspended', $2, $3)
+		`, u.userID, "Reportes suspendidos",
+			"Hemos suspendido tu capacidad de reportar porque muchos de tus reportes recientes han sido rechazados"); err != nil {
+			log.Warn().Err(err).Msg("[Bans] Failed to notify banned user")
+		}
+
+		newValue, _ := json.Marshal(map[string]interface{}{
+			"banned":           true,
+			"reason":           reason,
+			"banned_by":        "auto",
+			"rejected_reports": u.rejected,
+		})
+		oldValue, _ := json.Marshal(map[string]interface{}{"banned": false, "trust_score": u.trustBefore})
+		if _, err := b.db.ExecContext(ctx, `
+			INSERT INTO audit_log (action, table_name, record_id, old_value, new_value, admin_ip)
+			VALUES ('auto_ban_user', 'user_trust_scores', $1, $2, $3, 'fy-analysis')
+		`, u.userID, oldValue, newValue); err != nil {
+			log.Warn().Err(err).Msg("[Bans] Failed to write audit log")
+		}
+	}
+
+	return len(banned), nil
+}
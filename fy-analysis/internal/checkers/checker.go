@@ -12,6 +12,7 @@ const (
 	InputTypeURL   InputType = "url"
 	InputTypeEmail InputType = "email"
 	InputTypePhone InputType = "phone"
+	InputTypeHash  InputType = "hash" // Hash de un fichero (MD5/SHA1/SHA256), p. ej. de un adjunto
 )
 
 // Indicators contiene los indicadores extraídos para verificación
@@ -30,18 +31,29 @@ type Indicators struct {
 	TLD        string // Top Level Domain (.es, .com, etc)
 	Scheme     string // http, https
 	Path       string // Path de la URL
-	URLHash    string // SHA256 del URL (alias de Hash para URLs)
+	URLHash    string // SHA256 de la URL canónica (alias de Hash para URLs)
+	// Path canónico (sin . ni .., sin barras duplicadas, percent-encoding normalizado):
+	// el que se busca en threat_paths
+	CanonicalPath string
+	// Cadena de redirects desde la URL original (incluida) hasta la final; vacía si no redirige
+	RedirectChain []string
 
 	// Email específico
 	EmailUser   string // Parte antes del @
 	EmailDomain string // Dominio del email
+	// Forma canónica para búsqueda: sin sub-dirección ni puntos según el proveedor (user@gmail.com)
+	EmailNormalized string
 
 	// Phone específico
 	PhoneNumber  string // Número normalizado E.164
 	CountryCode  string // Código de país (+34)
 	NationalNum  string // Número sin código de país
 	IsPremium    bool   // Si es número premium (806, 807, etc)
+	Premium      *PremiumMatch // Detalle del prefijo premium según el país (nil si no es premium)
 	CarrierHint  string // Pista del operador si disponible
+
+	// Hash específico (Normalized es el hash en hexadecimal en minúsculas)
+	FileHashType string // md5, sha1, sha256
 }
 
 // AnalysisContext información adicional que da el usuario
@@ -51,6 +63,22 @@ type AnalysisContext struct {
 	OriginalText  string `json:"original_text,omitempty"`  // Texto completo del mensaje
 }
 
+type analysisContextKey struct{}
+
+// WithAnalysisContext adjunta el contexto del usuario al ctx que reciben los checkers
+func WithAnalysisContext(ctx context.Context, ac *AnalysisContext) context.Context {
+	if ac == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, analysisContextKey{}, ac)
+}
+
+// AnalysisContextFrom retorna el contexto del usuario (nil si no se indicó)
+func AnalysisContextFrom(ctx context.Context) *AnalysisContext {
+	ac, _ := ctx.Value(analysisContextKey{}).(*AnalysisContext)
+	return ac
+}
+
 // CheckResult representa el resultado de un checker individual
 type CheckResult struct {
 	Source     string                 // Nombre del checker (urlhaus, webrisk, etc)
@@ -79,6 +107,28 @@ type ThreatChecker interface {
 
 	// SupportedTypes retorna los tipos de entrada que soporta este checker
 	SupportedTypes() []InputType
+
+	// Health verifica que el checker puede funcionar (DB accesible, datos cargados, API configurada)
+	Health(ctx context.Context) error
+
+	// Close libera los recursos del checker (conexiones, clientes HTTP)
+	Close() error
+}
+
+// RedirectChainChecker lo implementan los checkers que, además de la URL analizada, pueden
+// evaluar los saltos intermedios de su cadena de redirects (redirectores comprometidos o
+// dominios legítimos usados como trampolín). El Orchestrator lo usa en lugar de Check
+// cuando la URL redirige.
+type RedirectChainChecker interface {
+	CheckRedirectChain(ctx context.Context, indicators *Indicators, chain []string) (*CheckResult, error)
+}
+
+// ExternalAPIChecker lo implementan los checkers que llaman a una API externa (con cuota o de
+// pago). NeedsExternalCall indica si analizar value llamaría a la API (false si el checker ya
+// tiene la respuesta cacheada); el Engine lo usa para clasificar el coste de un análisis
+// antes de ejecutarlo.
+type ExternalAPIChecker interface {
+	NeedsExternalCall(inputType InputType, value string) bool
 }
 
 // SupportsType verifica si un checker soporta un tipo de entrada
@@ -0,0 +1,146 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	dbPingTimeout     = 5 * time.Second
+	dbRetryMinBackoff = 5 * time.Second
+	dbRetryMaxBackoff = 5 * time.Minute
+	// dbRetryOnDemandInterval mínimo entre reintentos lanzados desde IsEnabled/Check
+	dbRetryOnDemandInterval = 30 * time.Second
+)
+
+// dbConnState disponibilidad de la PostgreSQL de un checker. Si la DB no responde al
+// arrancar (o cae más tarde), el checker queda deshabilitado y se reintenta el ping
+// con backoff en background y bajo demanda, hasta que vuelve y se rehabilita.
+type dbConnState struct {
+	component string // Prefijo de los logs (LocalDB, UserReports)
+	db        *sql.DB
+	onConnect func() // Se llama en cada paso de deshabilitado a habilitado (puede ser nil)
+
+	enabled   atomic.Bool
+	retrying  atomic.Bool
+	pinging   atomic.Bool
+	nextRetry atomic.Int64 // UnixNano antes del que no se reintenta bajo demanda
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func newDBConnState(component string, db *sql.DB, onConnect func()) *dbConnState {
+	return &dbConnState{
+		component: component,
+		db:        db,
+		onConnect: onConnect,
+		stop:      make(chan struct{}),
+	}
+}
+
+// Enabled indica si la última comprobación de la DB fue correcta
+func (s *dbConnState) Enabled() bool {
+	return s.enabled.Load()
+}
+
+// Retrying indica si la DB no responde y se está reintentando en background
+func (s *dbConnState) Retrying() bool {
+	return s.retrying.Load()
+}
+
+// connect hace ping a la DB y habilita el checker si responde
+func (s *dbConnState) connect(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, dbPingTimeout)
+	defer cancel()
+
+	if err := s.db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	if s.enabled.CompareAndSwap(false, true) {
+		if s.retrying.Swap(false) {
+			log.Info().Msgf("[%s] Database is reachable again, checker enabled", s.component)
+		}
+		if s.onConnect != nil {
+			s.onConnect()
+		}
+	}
+	return nil
+}
+
+// markDown deshabilita el checker tras un fallo de la DB y empieza a reintentar
+func (s *dbConnState) markDown(err error) {
+	if !s.enabled.CompareAndSwap(true, false) {
+		return
+	}
+	log.Warn().Err(err).Msgf("[%s] Database unreachable, checker disabled until it recovers", s.component)
+	s.startRetry()
+}
+
+// startRetry lanza el bucle de reintentos con backoff exponencial (uno a la vez)
+func (s *dbConnState) startRetry() {
+	if !s.retrying.CompareAndSwap(false, true) {
+		return
+	}
+	s.nextRetry.Store(time.Now().Add(dbRetryOnDemandInterval).UnixNano())
+
+	go func() {
+		backoff := dbRetryMinBackoff
+		for attempt := 1; ; attempt++ {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-s.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			if s.Enabled() {
+				return
+			}
+			err := s.connect(context.Background())
+			if err == nil {
+				return
+			}
+
+			backoff *= 2
+			if backoff > dbRetryMaxBackoff {
+				backoff = dbRetryMaxBackoff
+			}
+			log.Debug().
+				Err(err).
+				Int("attempt", attempt).
+				Dur("next_retry", backoff).
+				Msgf("[%s] Database still unreachable", s.component)
+		}
+	}()
+}
+
+// retryIfDue lanza un ping en background si la DB está caída y ha pasado
+// dbRetryOnDemandInterval desde el último reintento bajo demanda (no bloquea)
+func (s *dbConnState) retryIfDue() {
+	if s.Enabled() || !s.Retrying() {
+		return
+	}
+	now := time.Now()
+	if now.UnixNano() < s.nextRetry.Load() || !s.pinging.CompareAndSwap(false, true) {
+		return
+	}
+	s.nextRetry.Store(now.Add(dbRetryOnDemandInterval).UnixNano())
+
+	go func() {
+		defer s.pinging.Store(false)
+		_ = s.connect(context.Background())
+	}()
+}
+
+// Close detiene los reintentos
+func (s *dbConnState) Close() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
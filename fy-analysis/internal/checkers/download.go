@@ -0,0 +1,145 @@
+package checkers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// minRowRatio proporción mínima de filas respecto a la carga anterior para aceptar una descarga
+const minRowRatio = 0.5
+
+// Downloader obtiene el contenido de un feed para DownloadDB. En producción es un
+// HTTPDownloader; los tests lo sustituyen para simular descargas vacías o truncadas.
+type Downloader interface {
+	Download(ctx context.Context) (io.ReadCloser, error)
+}
+
+// HTTPDownloader descarga un feed por GET con las cabeceras indicadas
+type HTTPDownloader struct {
+	Client *http.Client
+	URL    string
+	Header http.Header
+}
+
+// Download retorna el body de la respuesta (el llamante lo cierra); error si no es 200
+func (d *HTTPDownloader) Download(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for name, values := range d.Header {
+		req.Header[name] = values
+	}
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return resp.Body, nil
+}
+
+// DownloadOutcome resultado del último intento de descarga de un feed
+type DownloadOutcome struct {
+	At           time.Time
+	Success      bool
+	Error        string
+	Bytes        int64
+	Rows         int
+	PreviousRows int
+}
+
+// Delta diferencia de filas respecto a la carga anterior
+func (o DownloadOutcome) Delta() int {
+	return o.Rows - o.PreviousRows
+}
+
+// ToMap convierte el resultado para GetStats
+func (o DownloadOutcome) ToMap() map[string]interface{} {
+	if o.At.IsZero() {
+		return nil
+	}
+	return map[string]interface{}{
+		"at":            o.At,
+		"success":       o.Success,
+		"error":         o.Error,
+		"bytes":         o.Bytes,
+		"rows":          o.Rows,
+		"previous_rows": o.PreviousRows,
+		"row_delta":     o.Delta(),
+	}
+}
+
+// replaceDBFile descarga a un archivo temporal junto a dbPath, lo valida con parse
+// y solo si es válido lo renombra atómicamente sobre el archivo anterior.
+// parse debe construir el índice nuevo sin tocar el actual y retornar el número de filas.
+func replaceDBFile(body io.Reader, dbPath string, previousRows int, parse func(io.Reader) (int, error)) DownloadOutcome {
+	outcome := DownloadOutcome{At: time.Now(), PreviousRows: previousRows}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dbPath), filepath.Base(dbPath)+".tmp-*")
+	if err != nil {
+		outcome.Error = fmt.Sprintf("failed to create temp file: %v", err)
+		return outcome
+	}
+	tmpPath := tmp.Name()
+	defer func() {
+		tmp.Close()
+		if !outcome.Success {
+			os.Remove(tmpPath)
+		}
+	}()
+
+	outcome.Bytes, err = io.Copy(tmp, body)
+	if err != nil {
+		outcome.Error = fmt.Sprintf("failed to write temp file: %v", err)
+		return outcome
+	}
+	if outcome.Bytes == 0 {
+		outcome.Error = "downloaded file is empty"
+		return outcome
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		outcome.Error = fmt.Sprintf("failed to rewind temp file: %v", err)
+		return outcome
+	}
+
+	outcome.Rows, err = parse(tmp)
+	if err != nil {
+		outcome.Error = fmt.Sprintf("failed to parse downloaded DB: %v", err)
+		return outcome
+	}
+	if outcome.Rows == 0 {
+		outcome.Error = "downloaded file contains no rows"
+		return outcome
+	}
+
+	// Una caída brusca de filas suele indicar una descarga truncada
+	if previousRows > 0 && float64(outcome.Rows) < float64(previousRows)*minRowRatio {
+		outcome.Error = fmt.Sprintf("row count dropped from %d to %d, download rejected", previousRows, outcome.Rows)
+		return outcome
+	}
+
+	if err := tmp.Sync(); err != nil {
+		outcome.Error = fmt.Sprintf("failed to sync temp file: %v", err)
+		return outcome
+	}
+	if err := os.Rename(tmpPath, dbPath); err != nil {
+		outcome.Error = fmt.Sprintf("failed to replace DB file: %v", err)
+		return outcome
+	}
+
+	outcome.Success = true
+	return outcome
+}
@@ -0,0 +1,210 @@
+package checkers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// stubDownloader sirve un contenido fijo; err simula un corte a mitad de descarga
+type stubDownloader struct {
+	body string
+	err  error
+}
+
+func (d *stubDownloader) Download(ctx context.Context) (io.ReadCloser, error) {
+	var r io.Reader = strings.NewReader(d.body)
+	if d.err != nil {
+		r = io.MultiReader(r, &failingReader{err: d.err})
+	}
+	return io.NopCloser(r), nil
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+// countLines parse de prueba: una fila por línea no vacía
+func countLines(r io.Reader) (int, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	return len(strings.Fields(string(data))), nil
+}
+
+// writeDBFile crea el archivo "anterior" que una descarga fallida no debe tocar
+func writeDBFile(t *testing.T, content string) string {
+	t.Helper()
+	dbPath := filepath.Join(t.TempDir(), "feed.csv")
+	if err := os.WriteFile(dbPath, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dbPath
+}
+
+// assertOnlyDBFile comprueba que el archivo sigue intacto y que no quedan temporales
+func assertOnlyDBFile(t *testing.T, dbPath, want string) {
+	t.Helper()
+	got, err := os.ReadFile(dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("DB file = %q, want %q", got, want)
+	}
+	entries, _ := os.ReadDir(filepath.Dir(dbPath))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d files, want only the DB file", len(entries))
+	}
+}
+
+func TestReplaceDBFileRejectsEmptyDownload(t *testing.T) {
+	dbPath := writeDBFile(t, "a\nb\nc\n")
+
+	outcome := replaceDBFile(strings.NewReader(""), dbPath, 3, countLines)
+
+	if outcome.Success || outcome.Error != "downloaded file is empty" {
+		t.Fatalf("outcome = %+v, want empty download rejected", outcome)
+	}
+	assertOnlyDBFile(t, dbPath, "a\nb\nc\n")
+}
+
+func TestReplaceDBFileRejectsFileWithoutRows(t *testing.T) {
+	dbPath := writeDBFile(t, "a\nb\nc\n")
+
+	outcome := replaceDBFile(strings.NewReader("\n\n"), dbPath, 3, countLines)
+
+	if outcome.Success || outcome.Error != "downloaded file contains no rows" {
+		t.Fatalf("outcome = %+v, want no rows rejected", outcome)
+	}
+	assertOnlyDBFile(t, dbPath, "a\nb\nc\n")
+}
+
+func TestReplaceDBFileRejectsTruncatedDownload(t *testing.T) {
+	old := strings.Repeat("row\n", 10)
+
+	t.Run("row count drop", func(t *testing.T) {
+		dbPath := writeDBFile(t, old)
+
+		outcome := replaceDBFile(strings.NewReader("row\nrow\nrow\nrow\n"), dbPath, 10, countLines)
+
+		if outcome.Success || outcome.Rows != 4 || outcome.Delta() != -6 {
+			t.Fatalf("outcome = %+v, want 4 rows rejected with delta -6", outcome)
+		}
+		assertOnlyDBFile(t, dbPath, old)
+	})
+
+	t.Run("connection cut", func(t *testing.T) {
+		dbPath := writeDBFile(t, old)
+		body, _ := (&stubDownloader{body: "row\nro", err: io.ErrUnexpectedEOF}).Download(context.Background())
+
+		outcome := replaceDBFile(body, dbPath, 10, countLines)
+
+		if outcome.Success || !strings.Contains(outcome.Error, "unexpected EOF") {
+			t.Fatalf("outcome = %+v, want write error", outcome)
+		}
+		assertOnlyDBFile(t, dbPath, old)
+	})
+}
+
+func TestReplaceDBFileAcceptsValidDownload(t *testing.T) {
+	dbPath := writeDBFile(t, "a\nb\n")
+
+	// Bajar a la mitad exacta aún se acepta
+	outcome := replaceDBFile(strings.NewReader("x\n"), dbPath, 2, countLines)
+
+	if !outcome.Success || outcome.Rows != 1 || outcome.Bytes != 2 {
+		t.Fatalf("outcome = %+v, want success with 1 row", outcome)
+	}
+	assertOnlyDBFile(t, dbPath, "x\n")
+}
+
+// urlhausCSV CSV de URLhaus con n filas
+func urlhausCSV(n int) string {
+	var b strings.Builder
+	b.WriteString("# URLhaus database dump\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "%d,2026-10-01 10:00:00,http://bad%d.example.com/x,online,,malware_download,exe,https://urlhaus.abuse.ch/url/%d/,reporter\n", i, i, i)
+	}
+	return b.String()
+}
+
+func TestURLhausDownloadDBKeepsIndexOnBadDownload(t *testing.T) {
+	dbPath := writeDBFile(t, urlhausCSV(10))
+	checker := NewURLhausChecker(dbPath, nil)
+	if got := checker.rowCount(); got != 10 {
+		t.Fatalf("loaded %d rows, want 10", got)
+	}
+
+	downloads := map[string]*stubDownloader{
+		"empty":     {body: ""},
+		"truncated": {body: urlhausCSV(3)},
+		"cut":       {body: urlhausCSV(10)[:200], err: io.ErrUnexpectedEOF},
+	}
+	for name, downloader := range downloads {
+		t.Run(name, func(t *testing.T) {
+			checker.downloader = downloader
+
+			if err := checker.DownloadDB(context.Background()); err == nil {
+				t.Fatal("DownloadDB succeeded, want rejection")
+			}
+			if got := checker.rowCount(); got != 10 {
+				t.Errorf("in-memory index has %d rows, want the previous 10", got)
+			}
+			last := checker.GetStats()["last_download"].(map[string]interface{})
+			if last["success"] != false || last["previous_rows"] != 10 {
+				t.Errorf("last_download = %v", last)
+			}
+		})
+	}
+	assertOnlyDBFile(t, dbPath, urlhausCSV(10))
+
+	checker.downloader = &stubDownloader{body: urlhausCSV(12)}
+	if err := checker.DownloadDB(context.Background()); err != nil {
+		t.Fatalf("DownloadDB: %v", err)
+	}
+	if got := checker.rowCount(); got != 12 {
+		t.Errorf("in-memory index has %d rows, want 12", got)
+	}
+	if last := checker.GetStats()["last_download"].(map[string]interface{}); last["row_delta"] != 2 {
+		t.Errorf("row_delta = %v, want 2", last["row_delta"])
+	}
+}
+
+func TestHTTPDownloader(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, r.Header.Get("User-Agent"))
+	}))
+	defer server.Close()
+
+	downloader := &HTTPDownloader{
+		Client: server.Client(),
+		URL:    server.URL + "/feed",
+		Header: http.Header{"User-Agent": {"phishtank/fy-analysis"}},
+	}
+	body, err := downloader.Download(context.Background())
+	if err != nil {
+		t.Fatalf("Download: %v", err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "phishtank/fy-analysis" {
+		t.Errorf("body = %q, want the User-Agent echoed", data)
+	}
+
+	downloader.URL = server.URL + "/down"
+	if _, err := downloader.Download(context.Background()); err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Errorf("err = %v, want status 503", err)
+	}
+}
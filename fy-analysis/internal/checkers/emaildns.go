@@ -0,0 +1,317 @@
+package checkers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	emailDNSTimeout    = 1500 * time.Millisecond
+	emailDNSCacheTTL   = 1 * time.Hour
+	emailDNSCacheLimit = 10000
+
+	// emailDNSCanary dominio con MX conocido: si no resuelve, el DNS no es fiable
+	// (resolvers filtrados responden NXDOMAIN a todo) y el check se omite
+	emailDNSCanary         = "gmail.com"
+	emailDNSCanaryInterval = 5 * time.Minute
+)
+
+// EmailDNSResult registros DNS de correo de un dominio
+type EmailDNSResult struct {
+	DomainExists bool
+	MX           []string
+	SPF          string
+	DMARC        string
+	DMARCPolicy  string // none, quarantine, reject (vacío si no publica DMARC)
+}
+
+type emailDNSCacheEntry struct {
+	result    *EmailDNSResult
+	expiresAt time.Time
+}
+
+// EmailDNSChecker valida MX, SPF y DMARC del dominio de un email
+type EmailDNSChecker struct {
+	resolver *net.Resolver
+	timeout  time.Duration
+	enabled  bool
+	weight   float64
+
+	mu           sync.Mutex
+	cache        map[string]emailDNSCacheEntry
+	dnsHealthy   bool
+	dnsCheckedAt time.Time
+}
+
+// NewEmailDNSChecker crea el checker DNS de emails
+func NewEmailDNSChecker() *EmailDNSChecker {
+	return &EmailDNSChecker{
+		resolver: net.DefaultResolver,
+		timeout:  emailDNSTimeout,
+		enabled:  true,
+		weight:   0.20,
+		cache:    make(map[string]emailDNSCacheEntry),
+	}
+}
+
+func init() {
+	Registry.Register("email_dns", newEmailDNSFromConfig)
+}
+
+// newEmailDNSFromConfig factory del registro (ENABLE_EMAIL_DNS)
+func newEmailDNSFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if !cfg.EnableEmailDNS {
+		return nil, nil
+	}
+	return NewEmailDNSChecker(), nil
+}
+
+// Name retorna el nombre del checker
+func (c *EmailDNSChecker) Name() string {
+	return "email_dns"
+}
+
+// Weight retorna el peso del checker
+func (c *EmailDNSChecker) Weight() float64 {
+	return c.weight
+}
+
+// IsEnabled indica si el checker está habilitado
+func (c *EmailDNSChecker) IsEnabled() bool {
+	return c.enabled
+}
+
+// SupportedTypes retorna los tipos soportados (solo emails)
+func (c *EmailDNSChecker) SupportedTypes() []InputType {
+	return []InputType{InputTypeEmail}
+}
+
+// Health comprueba que el resolver DNS responde (cacheado)
+func (c *EmailDNSChecker) Health(ctx context.Context) error {
+	if !c.dnsReachable(ctx) {
+		return errors.New("DNS resolver not reliable")
+	}
+	return nil
+}
+
+// Close no libera nada (usa el resolver del sistema)
+func (c *EmailDNSChecker) Close() error {
+	return nil
+}
+
+// Check consulta MX/SPF/DMARC del dominio. Si el DNS no responde el check se omite sin error.
+func (c *EmailDNSChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
+	startTime := time.Now()
+	result := &CheckResult{
+		Source:  c.Name(),
+		Found:   false,
+		RawData: make(map[string]interface{}),
+	}
+
+	domain := strings.ToLower(strings.TrimSuffix(indicators.EmailDomain, "."))
+	if indicators.InputType != InputTypeEmail || domain == "" {
+		return result, nil
+	}
+
+	records, err := c.lookup(ctx, domain)
+	result.Latency = time.Since(startTime)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", domain).Msg("[EmailDNS] DNS unreachable, skipping")
+		result.RawData["skipped"] = "dns_unreachable"
+		return result, nil
+	}
+
+	result.RawData["mx"] = records.MX
+	result.RawData["spf"] = records.SPF
+	result.RawData["dmarc"] = records.DMARC
+	result.RawData["dmarc_policy"] = records.DMARCPolicy
+
+	var reasons []string
+
+	// 1. Sin MX: el dominio no puede recibir correo (dirección probablemente inventada)
+	switch {
+	case !records.DomainExists:
+		result.Found = true
+		result.ThreatType = ThreatTypeSocialEng
+		result.Confidence = 0.70
+		result.Tags = append(result.Tags, "email_domain_nxdomain")
+		reasons = append(reasons, "El dominio del email no existe")
+	case len(records.MX) == 0:
+		result.Found = true
+		result.ThreatType = ThreatTypeSocialEng
+		result.Confidence = 0.45
+		result.Tags = append(result.Tags, "email_no_mx")
+		reasons = append(reasons, "El dominio del email no tiene servidores de correo (MX)")
+	}
+
+	// 2. El mensaje dice venir de la marca y el dominio es el suyo con DMARC p=reject:
+	// un correo legítimo no llegaría sin autenticar, así que el remitente mostrado está falsificado
+	if ac := AnalysisContextFrom(ctx); ac != nil && records.DMARCPolicy == "reject" && claimsDomain(ac.ClaimedSender, domain) {
+		result.Found = true
+		result.ThreatType = ThreatTypePhishing
+		if result.Confidence < 0.60 {
+			result.Confidence = 0.60
+		}
+		result.Tags = append(result.Tags, "dmarc_reject_spoofing")
+		reasons = append(reasons, "El dominio de "+ac.ClaimedSender+" rechaza correos no autenticados (DMARC p=reject): si lo recibiste con este remitente, probablemente esté suplantado")
+	}
+
+	if len(reasons) > 0 {
+		result.RawData["reasons"] = reasons
+	}
+
+	return result, nil
+}
+
+// lookup retorna los registros del dominio (cacheados). Solo falla si el DNS no es alcanzable.
+func (c *EmailDNSChecker) lookup(ctx context.Context, domain string) (*EmailDNSResult, error) {
+	c.mu.Lock()
+	entry, ok := c.cache[domain]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expiresAt) {
+		return entry.result, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	records := &EmailDNSResult{DomainExists: true}
+	var mxErr, spfErr, dmarcErr error
+	var wg sync.WaitGroup
+	wg.Add(3)
+
+	go func() {
+		defer wg.Done()
+		var mxs []*net.MX
+		mxs, mxErr = c.resolver.LookupMX(ctx, domain)
+		for _, mx := range mxs {
+			// Null MX (RFC 7505): el dominio declara que no acepta correo
+			if host := strings.TrimSuffix(mx.Host, "."); host != "" {
+				records.MX = append(records.MX, host)
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		var txts []string
+		txts, spfErr = c.resolver.LookupTXT(ctx, domain)
+		records.SPF = findRecord(txts, "v=spf1")
+	}()
+	go func() {
+		defer wg.Done()
+		var txts []string
+		txts, dmarcErr = c.resolver.LookupTXT(ctx, "_dmarc."+domain)
+		records.DMARC = findRecord(txts, "v=DMARC1")
+		records.DMARCPolicy = dmarcPolicy(records.DMARC)
+	}()
+	wg.Wait()
+
+	notFound := false
+	for _, err := range []error{mxErr, spfErr, dmarcErr} {
+		if err != nil && !isDNSNotFound(err) {
+			return nil, err
+		}
+		notFound = notFound || err != nil
+	}
+	if notFound && !c.dnsReachable(ctx) {
+		return nil, errors.New("DNS resolver not reliable")
+	}
+
+	// Sin MX: comprobar si el dominio existe (el correo puede ir al registro A, RFC 5321)
+	if len(records.MX) == 0 {
+		if _, err := c.resolver.LookupHost(ctx, domain); err != nil {
+			if !isDNSNotFound(err) {
+				return nil, err
+			}
+			records.DomainExists = false
+		}
+	}
+
+	c.mu.Lock()
+	if len(c.cache) >= emailDNSCacheLimit {
+		c.cache = make(map[string]emailDNSCacheEntry)
+	}
+	c.cache[domain] = emailDNSCacheEntry{result: records, expiresAt: time.Now().Add(emailDNSCacheTTL)}
+	c.mu.Unlock()
+
+	return records, nil
+}
+
+// dnsReachable comprueba (cacheado) que el resolver responde al dominio canario
+func (c *EmailDNSChecker) dnsReachable(ctx context.Context) bool {
+	c.mu.Lock()
+	if time.Since(c.dnsCheckedAt) < emailDNSCanaryInterval {
+		healthy := c.dnsHealthy
+		c.mu.Unlock()
+		return healthy
+	}
+	c.mu.Unlock()
+
+	mxs, err := c.resolver.LookupMX(ctx, emailDNSCanary)
+	healthy := err == nil && len(mxs) > 0
+
+	c.mu.Lock()
+	c.dnsHealthy = healthy
+	c.dnsCheckedAt = time.Now()
+	c.mu.Unlock()
+
+	if !healthy {
+		log.Warn().Err(err).Msg("[EmailDNS] Canary lookup failed, DNS results ignored")
+	}
+	return healthy
+}
+
+// findRecord primer TXT que empieza por el prefijo indicado
+func findRecord(txts []string, prefix string) string {
+	for _, txt := range txts {
+		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(txt)), strings.ToLower(prefix)) {
+			return strings.TrimSpace(txt)
+		}
+	}
+	return ""
+}
+
+// dmarcPolicy extrae el valor de p= de un registro DMARC
+func dmarcPolicy(record string) string {
+	for _, tag := range strings.Split(record, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		if ok && strings.EqualFold(strings.TrimSpace(key), "p") {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+// claimsDomain indica si el remitente declarado corresponde al dominio ("BBVA" -> bbva.es)
+func claimsDomain(claimedSender, domain string) bool {
+	claimed := strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(claimedSender))
+	if len(claimed) < 3 {
+		return false
+	}
+
+	if strings.Contains(strings.ToLower(claimedSender), domain) {
+		return true
+	}
+	labels := strings.Split(domain, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	return strings.Contains(labels[len(labels)-2], claimed)
+}
+
+// isDNSNotFound distingue "no existe el registro" de "DNS no alcanzable"
+func isDNSNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,306 @@
+package checkers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	hibpBaseURL    = "https://haveibeenpwned.com/api/v3"
+	hibpUserAgent  = "fy-analysis"
+	hibpTimeout    = 5 * time.Second
+	hibpCacheTTL   = 24 * time.Hour
+	hibpCacheLimit = 10000
+
+	// Confianza según el número de brechas: 1 = hibpMinConfidence, hibpMaxBreaches o más = hibpMaxConfidence
+	hibpMinConfidence = 0.40
+	hibpMaxConfidence = 0.70
+	hibpMaxBreaches   = 5
+)
+
+// HIBPBreach brecha de GET /breachedaccount/{account} (truncateResponse=false)
+type HIBPBreach struct {
+	Name        string   `json:"Name"`
+	Title       string   `json:"Title"`
+	Domain      string   `json:"Domain"`
+	BreachDate  string   `json:"BreachDate"`
+	Description string   `json:"Description"`
+	DataClasses []string `json:"DataClasses"`
+	IsVerified  bool     `json:"IsVerified"`
+	IsSpamList  bool     `json:"IsSpamList"`
+	IsMalware   bool     `json:"IsMalware"`
+}
+
+type hibpCacheEntry struct {
+	breaches  []HIBPBreach // nil = el email no aparece en ninguna brecha
+	expiresAt time.Time
+}
+
+// HaveIBeenPwnedChecker comprueba si un email aparece en brechas de datos (HIBP API v3).
+// Los emails filtrados se usan para campañas de phishing y spam.
+// Documentación: https://haveibeenpwned.com/API/v3
+type HaveIBeenPwnedChecker struct {
+	enabled    bool
+	weight     float64
+	apiKey     string
+	httpClient *http.Client
+	baseURL    string
+
+	mu    sync.Mutex
+	cache map[string]hibpCacheEntry // Clave: SHA256 del email (no se guardan emails en claro)
+}
+
+// NewHaveIBeenPwnedChecker crea el checker de HIBP (clients nil = factoría por defecto)
+func NewHaveIBeenPwnedChecker(apiKey string, clients *CheckerHTTPClientFactory) *HaveIBeenPwnedChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
+	checker := &HaveIBeenPwnedChecker{
+		enabled:    apiKey != "",
+		weight:     0.10,
+		apiKey:     apiKey,
+		httpClient: clients.Client(hibpTimeout),
+		baseURL:    hibpBaseURL,
+		cache:      make(map[string]hibpCacheEntry),
+	}
+
+	if checker.enabled {
+		log.Info().Msg("[HIBP] Checker enabled with API key")
+	} else {
+		log.Warn().Msg("[HIBP] Checker disabled - no API key provided")
+	}
+
+	return checker
+}
+
+func init() {
+	Registry.Register("hibp", newHIBPFromConfig)
+}
+
+// newHIBPFromConfig factory del registro (requiere HIBP_KEY)
+func newHIBPFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if cfg.HIBPKey == "" {
+		return nil, nil
+	}
+	return NewHaveIBeenPwnedChecker(cfg.HIBPKey, cfg.checkerHTTPClients()), nil
+}
+
+// Name retorna el nombre del checker
+func (c *HaveIBeenPwnedChecker) Name() string {
+	return "hibp"
+}
+
+// Weight retorna el peso del checker
+func (c *HaveIBeenPwnedChecker) Weight() float64 {
+	return c.weight
+}
+
+// IsEnabled indica si el checker está habilitado
+func (c *HaveIBeenPwnedChecker) IsEnabled() bool {
+	return c.enabled
+}
+
+// SupportedTypes retorna los tipos soportados (solo emails)
+func (c *HaveIBeenPwnedChecker) SupportedTypes() []InputType {
+	return []InputType{InputTypeEmail}
+}
+
+// NeedsExternalCall implementa ExternalAPIChecker: false si el email está en la cache
+func (c *HaveIBeenPwnedChecker) NeedsExternalCall(inputType InputType, value string) bool {
+	if inputType != InputTypeEmail {
+		return false
+	}
+	_, ok := c.cached(hibpCacheKey(strings.ToLower(strings.TrimSpace(value))))
+	return !ok
+}
+
+// Health falla si no hay API key (no se consume cuota para comprobar la API)
+func (c *HaveIBeenPwnedChecker) Health(ctx context.Context) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("HIBP API key not configured")
+	}
+	return nil
+}
+
+// Close cierra las conexiones HTTP inactivas
+func (c *HaveIBeenPwnedChecker) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Check busca el email en las brechas de HIBP. Un 404 (sin brechas) es Found: false.
+func (c *HaveIBeenPwnedChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
+	startTime := time.Now()
+	result := &CheckResult{
+		Source:  c.Name(),
+		Found:   false,
+		RawData: make(map[string]interface{}),
+	}
+
+	if !c.enabled {
+		return result, fmt.Errorf("HIBP API key not configured")
+	}
+
+	email := strings.ToLower(strings.TrimSpace(indicators.Normalized))
+	if indicators.InputType != InputTypeEmail || email == "" {
+		return result, nil
+	}
+
+	breaches, cached, err := c.breachedAccount(ctx, email)
+	result.Latency = time.Since(startTime)
+	if err != nil {
+		log.Warn().Err(err).Msg("[HIBP] Breach lookup failed")
+		return result, err
+	}
+	result.RawData["cached"] = cached
+
+	if len(breaches) == 0 {
+		log.Debug().Msg("[HIBP] Email not found in any breach")
+		return result, nil
+	}
+
+	names := make([]string, 0, len(breaches))
+	for _, b := range breaches {
+		names = append(names, b.Name)
+	}
+
+	result.Found = true
+	result.ThreatType = hibpThreatType(breaches)
+	result.Confidence = hibpConfidence(len(breaches))
+	result.Tags = names
+	result.RawData["breach_count"] = len(breaches)
+	result.RawData["breaches"] = names
+	result.RawData["reasons"] = []string{
+		fmt.Sprintf("El email aparece en %d filtraciones de datos (%s)", len(breaches), strings.Join(names, ", ")),
+	}
+
+	log.Info().
+		Int("breaches", len(breaches)).
+		Str("threat_type", result.ThreatType).
+		Msg("[HIBP] Email found in data breaches")
+
+	return result, nil
+}
+
+// breachedAccount brechas del email (cacheadas hibpCacheTTL, también las respuestas sin brechas)
+func (c *HaveIBeenPwnedChecker) breachedAccount(ctx context.Context, email string) ([]HIBPBreach, bool, error) {
+	key := hibpCacheKey(email)
+	if breaches, ok := c.cached(key); ok {
+		return breaches, true, nil
+	}
+
+	requestURL := c.baseURL + "/breachedaccount/" + url.PathEscape(email) + "?truncateResponse=false"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	// HIBP rechaza peticiones sin User-Agent; Add-Padding iguala el tamaño de las respuestas
+	req.Header.Set("hibp-api-key", c.apiKey)
+	req.Header.Set("User-Agent", hibpUserAgent)
+	req.Header.Set("Add-Padding", "true")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var breaches []HIBPBreach
+	switch resp.StatusCode {
+	case http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read response: %w", err)
+		}
+		if err := json.Unmarshal(body, &breaches); err != nil {
+			return nil, false, fmt.Errorf("failed to parse response: %w", err)
+		}
+	case http.StatusNotFound:
+		// Sin brechas
+	case http.StatusTooManyRequests:
+		return nil, false, fmt.Errorf("rate limited (retry-after %s)", resp.Header.Get("Retry-After"))
+	default:
+		return nil, false, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	c.mu.Lock()
+	// Al llenarse se descartan las entradas caducadas; si no basta, se vacía
+	if len(c.cache) >= hibpCacheLimit {
+		now := time.Now()
+		for k, e := range c.cache {
+			if now.After(e.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= hibpCacheLimit {
+			c.cache = make(map[string]hibpCacheEntry)
+		}
+	}
+	c.cache[key] = hibpCacheEntry{breaches: breaches, expiresAt: time.Now().Add(hibpCacheTTL)}
+	c.mu.Unlock()
+
+	return breaches, false, nil
+}
+
+// hibpCacheKey clave de la cache: SHA256 del email
+func hibpCacheKey(email string) string {
+	sum := sha256.Sum256([]byte(email))
+	return hex.EncodeToString(sum[:])
+}
+
+// cached brechas cacheadas y vigentes de la clave
+func (c *HaveIBeenPwnedChecker) cached(key string) ([]HIBPBreach, bool) {
+	c.mu.Lock()
+	entry, ok := c.cache[key]
+	c.mu.Unlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.breaches, true
+}
+
+// hibpThreatType tipo de amenaza de las brechas: malware y phishing por las categorías de
+// la brecha (IsMalware, clases de datos y nombre), spam para listas de spam
+func hibpThreatType(breaches []HIBPBreach) string {
+	threatType := ThreatTypeUnknown
+	for _, b := range breaches {
+		switch {
+		case b.IsMalware || hibpMentions(b, "malware"):
+			return ThreatTypeMalware
+		case hibpMentions(b, "phishing"):
+			threatType = ThreatTypePhishing
+		case b.IsSpamList && threatType == ThreatTypeUnknown:
+			threatType = ThreatTypeSpam
+		}
+	}
+	return threatType
+}
+
+func hibpMentions(b HIBPBreach, category string) bool {
+	for _, class := range b.DataClasses {
+		if strings.Contains(strings.ToLower(class), category) {
+			return true
+		}
+	}
+	return strings.Contains(strings.ToLower(b.Name+" "+b.Title), category)
+}
+
+// hibpConfidence confianza lineal entre hibpMinConfidence (1 brecha) y hibpMaxConfidence (hibpMaxBreaches+)
+func hibpConfidence(breaches int) float64 {
+	if breaches >= hibpMaxBreaches {
+		return hibpMaxConfidence
+	}
+	step := (hibpMaxConfidence - hibpMinConfidence) / float64(hibpMaxBreaches-1)
+	return hibpMinConfidence + float64(breaches-1)*step
+}
@@ -0,0 +1,138 @@
+package checkers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// HTTPClientConfig configuración de las conexiones salientes de los checkers
+type HTTPClientConfig struct {
+	MaxIdleConns        int           // Conexiones ociosas en total (0 = 100)
+	MaxIdleConnsPerHost int           // Conexiones ociosas por host (0 = 10)
+	IdleConnTimeout     time.Duration // Cierre de conexiones ociosas (0 = 90s)
+
+	// Certificado cliente para proxies corporativos con mTLS (vacío = sin certificado)
+	ClientCertFile string
+	ClientKeyFile  string
+	CAFile         string // CA adicional del proxy (vacío = CAs del sistema)
+}
+
+// CheckerHTTPClientFactory crea los *http.Client de los checkers externos. Todos comparten
+// un Transport (pool de conexiones) que sale por el proxy de HTTP_PROXY/HTTPS_PROXY/NO_PROXY.
+type CheckerHTTPClientFactory struct {
+	transport *http.Transport
+}
+
+// NewCheckerHTTPClientFactory crea la factoría; config nil usa los valores por defecto
+func NewCheckerHTTPClientFactory(config *HTTPClientConfig) (*CheckerHTTPClientFactory, error) {
+	if config == nil {
+		config = &HTTPClientConfig{}
+	}
+	if config.MaxIdleConns <= 0 {
+		config.MaxIdleConns = 100
+	}
+	if config.MaxIdleConnsPerHost <= 0 {
+		config.MaxIdleConnsPerHost = 10
+	}
+	if config.IdleConnTimeout <= 0 {
+		config.IdleConnTimeout = 90 * time.Second
+	}
+
+	tlsConfig, err := loadClientTLS(config)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyConfig := httpproxy.FromEnvironment()
+	proxyFunc := proxyConfig.ProxyFunc()
+
+	transport := &http.Transport{
+		Proxy: func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		},
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          config.MaxIdleConns,
+		MaxIdleConnsPerHost:   config.MaxIdleConnsPerHost,
+		IdleConnTimeout:       config.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}
+
+	log.Info().
+		Bool("http_proxy", proxyConfig.HTTPProxy != "").
+		Bool("https_proxy", proxyConfig.HTTPSProxy != "").
+		Str("no_proxy", proxyConfig.NoProxy).
+		Bool("client_cert", tlsConfig != nil && len(tlsConfig.Certificates) > 0).
+		Int("max_idle_conns", config.MaxIdleConns).
+		Int("max_idle_conns_per_host", config.MaxIdleConnsPerHost).
+		Msg("[HTTPClient] Checker HTTP client factory initialized")
+
+	return &CheckerHTTPClientFactory{transport: transport}, nil
+}
+
+// DefaultCheckerHTTPClientFactory factoría sin certificado cliente (constructores sin factoría)
+func DefaultCheckerHTTPClientFactory() *CheckerHTTPClientFactory {
+	factory, _ := NewCheckerHTTPClientFactory(nil) // sin ficheros de certificado no puede fallar
+	return factory
+}
+
+// Client retorna un cliente con el timeout dado sobre el Transport compartido
+func (f *CheckerHTTPClientFactory) Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: f.transport,
+	}
+}
+
+// Close cierra las conexiones ociosas del Transport compartido
+func (f *CheckerHTTPClientFactory) Close() {
+	f.transport.CloseIdleConnections()
+}
+
+// loadClientTLS carga el certificado cliente y la CA del proxy (nil si no hay ninguno)
+func loadClientTLS(config *HTTPClientConfig) (*tls.Config, error) {
+	if config.ClientCertFile == "" && config.CAFile == "" {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if config.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.ClientCertFile, config.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if config.CAFile != "" {
+		pem, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", config.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
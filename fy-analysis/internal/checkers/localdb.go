@@ -2,45 +2,145 @@ package checkers
 
 import (
 	"context"
+	"crypto/tls"
 	"database/sql"
 	"fmt"
+	"math"
+	"net/url"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
 	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+	"github.com/trackfy/pkg/dbtls"
+	"github.com/trackfy/pkg/dbutil"
+	"github.com/trackfy/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+const (
+	localDBHealthInterval   = 30 * time.Second
+	localDBQueryTimeout     = 2 * time.Second
+	localDBDegradedTimeout  = 500 * time.Millisecond
+	localDBDegradedDuration = 2 * localDBHealthInterval
+	localDBSlowQuery        = 500 * time.Millisecond
+
+	// threat_metrics: el REFRESH recorre todas las tablas de amenazas
+	localDBStatsRefreshTimeout = 2 * time.Minute
+	localDBStatsStaleAfter     = 10 * time.Minute
+
+	// source_quality la recalcula fy-dbsync cada 30 minutos
+	localDBQualityInterval = 30 * time.Minute
+
+	// Decaimiento de la confianza por antigüedad de last_seen: exp(-λ * días)
+	defaultConfidenceDecayLambda = 0.01 // Vida media ≈ 70 días
+	confidenceDecayGraceDays     = 7    // Vistos en la última semana: sin decaimiento
+	confidenceDecayMaxDays       = 365  // Más antiguos: la confianza baja al menos un 90%
+	confidenceDecayMaxFactor     = 0.1
+	// staleConfidenceThreshold por debajo se marca el registro como stale_candidate
+	staleConfidenceThreshold = 0.1
+)
+
+// activeNotFalsePositive registros activos que ningún analista ha marcado como posible falso positivo
+var activeNotFalsePositive = flags.Active.IsSet("flags") + " AND " + flags.FalsePositiveCandidate.IsClear("flags")
+
+var localDBLatency = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "localdb_connection_latency_ms",
+	Help: "Latencia de SELECT 1 contra la DB local, medida cada 30s",
+})
+
+var localDBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "localdb_query_duration_seconds",
+	Help:    "Duración de las consultas del checker LocalDB por tabla",
+	Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5},
+}, []string{"table"})
+
+var localDBSlowQueries = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "localdb_slow_queries_total",
+	Help: "Consultas del checker LocalDB que superaron 500ms, por tabla",
+}, []string{"table"})
+
 // LocalDBChecker verifica amenazas contra la base de datos local PostgreSQL
 type LocalDBChecker struct {
-	db      *sql.DB
-	enabled bool
-	weight  float64
+	db     *sql.DB
+	conn   *dbConnState // nil si no hay DB configurada
+	weight float64
+
+	// Salud del pool de conexiones
+	maxConns      int
+	queryTimeout  time.Duration
+	degradedUntil atomic.Int64 // UnixNano hasta el que se usa localDBDegradedTimeout
+	lastLatencyMs atomic.Int64
+	lastWaitCount int64 // Solo lo usa la goroutine de monitorización
+	stopMonitor   chan struct{}
+	stopOnce      sync.Once
+	monitorOnce   sync.Once
+
+	// Conexión mTLS (ValidateDBTLSConnection)
+	dsn string
+	tls dbtls.Config
+
+	// quality_score por fuente (source_quality); nil hasta la primera carga
+	sourceQuality atomic.Pointer[map[string]float64]
+
+	// decayLambda λ de ConfidenceDecay (0 = sin decaimiento)
+	decayLambda float64
 }
 
 // LocalDBConfig configuración para el checker de DB local
 type LocalDBConfig struct {
-	DatabaseURL string
-	MaxConns    int
-	Weight      float64
+	DatabaseURL  string
+	MaxConns     int
+	Weight       float64
+	QueryTimeout time.Duration // Timeout por consulta (0 = 2s; DB_QUERY_TIMEOUT)
+	DecayLambda  float64       // λ del decaimiento por last_seen (0 = 0.01; < 0 = sin decaimiento; CONFIDENCE_DECAY_LAMBDA)
+	TLS          dbtls.Config  // mTLS con PostgreSQL (vacío = sslmode de la URL)
 }
 
-// NewLocalDBChecker crea un nuevo checker de base de datos local
+// PoolStats estado del pool de conexiones de la DB local
+type PoolStats struct {
+	MaxOpenConns    int    `json:"max_open_conns"`
+	OpenConnections int    `json:"open_connections"`
+	InUse           int    `json:"in_use"`
+	Idle            int    `json:"idle"`
+	WaitCount       int64  `json:"wait_count"`
+	WaitDurationMs  int64  `json:"wait_duration_ms"`
+	LatencyMs       int64  `json:"latency_ms"`
+	Saturated       bool   `json:"saturated"`
+	CheckTimeout    string `json:"check_timeout"`
+}
+
+// NewLocalDBChecker crea un nuevo checker de base de datos local.
+// Si la DB no responde al arrancar, el checker queda deshabilitado y se reintenta
+// en background: se habilita solo cuando PostgreSQL vuelve.
 func NewLocalDBChecker(config *LocalDBConfig) *LocalDBChecker {
 	if config == nil || config.DatabaseURL == "" {
 		log.Warn().Msg("[LocalDB] No database URL provided, checker disabled")
 		return &LocalDBChecker{
-			enabled: false,
-			weight:  0.5,
+			weight: 0.5,
+		}
+	}
+
+	if err := dbutil.ValidateDatabaseURL(config.DatabaseURL); err != nil {
+		log.Error().Err(err).Msg("[LocalDB] Invalid database URL, checker disabled")
+		return &LocalDBChecker{
+			weight: config.Weight,
 		}
 	}
 
-	db, err := sql.Open("postgres", config.DatabaseURL)
+	dsn := config.TLS.ApplyToDSN(config.DatabaseURL)
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		log.Error().Err(err).Msg("[LocalDB] Failed to open database connection")
 		return &LocalDBChecker{
-			enabled: false,
-			weight:  config.Weight,
+			weight: config.Weight,
 		}
 	}
 
@@ -53,39 +153,293 @@ func NewLocalDBChecker(config *LocalDBConfig) *LocalDBChecker {
 	db.SetMaxIdleConns(maxConns / 2)
 	db.SetConnMaxLifetime(5 * time.Minute)
 
-	// Verificar conexión
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-
-	if err := db.PingContext(ctx); err != nil {
-		log.Error().Err(err).Msg("[LocalDB] Failed to ping database")
-		return &LocalDBChecker{
-			enabled: false,
-			weight:  config.Weight,
-		}
-	}
-
 	weight := config.Weight
 	if weight <= 0 {
 		weight = 0.5
 	}
 
+	queryTimeout := config.QueryTimeout
+	if queryTimeout <= 0 {
+		queryTimeout = localDBQueryTimeout
+	}
+
+	decayLambda := config.DecayLambda
+	if decayLambda == 0 {
+		decayLambda = defaultConfidenceDecayLambda
+	} else if decayLambda < 0 {
+		decayLambda = 0
+	}
+
+	checker := &LocalDBChecker{
+		db:           db,
+		weight:       weight,
+		maxConns:     maxConns,
+		queryTimeout: queryTimeout,
+		stopMonitor:  make(chan struct{}),
+		dsn:          dsn,
+		tls:          config.TLS,
+		decayLambda:  decayLambda,
+	}
+	checker.conn = newDBConnState("LocalDB", db, func() {
+		checker.monitorOnce.Do(func() { go checker.monitorPool() })
+	})
+
+	// Verificar conexión
+	if err := checker.conn.connect(context.Background()); err != nil {
+		log.Error().Err(err).Msg("[LocalDB] Failed to ping database, checker disabled until it becomes reachable")
+		checker.conn.startRetry()
+		return checker
+	}
+
+	if config.TLS.Enabled() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := checker.ValidateDBTLSConnection(ctx); err != nil {
+			log.Error().Err(err).Msg("[LocalDB] TLS validation failed")
+		}
+		cancel()
+	}
+
 	log.Info().
 		Str("url", maskDatabaseURL(config.DatabaseURL)).
+		Bool("mtls", config.TLS.Enabled()).
 		Int("max_conns", maxConns).
 		Float64("weight", weight).
+		Dur("query_timeout", queryTimeout).
+		Float64("confidence_decay_lambda", decayLambda).
 		Msg("[LocalDB] Checker initialized successfully")
 
-	return &LocalDBChecker{
-		db:      db,
-		enabled: true,
-		weight:  weight,
+	return checker
+}
+
+// ValidateDBTLSConnection hace un handshake TLS con el servidor, verifica que el SAN del
+// certificado coincide con el host de la DB y registra la versión y el cipher suite
+func (c *LocalDBChecker) ValidateDBTLSConnection(ctx context.Context) error {
+	if !c.tls.Enabled() {
+		return fmt.Errorf("database TLS not configured")
+	}
+
+	state, err := c.tls.DialTLS(ctx, c.dsn)
+	if err != nil {
+		return fmt.Errorf("database TLS validation failed: %w", err)
+	}
+
+	log.Info().
+		Str("tls_version", tls.VersionName(state.Version)).
+		Str("cipher_suite", tls.CipherSuiteName(state.CipherSuite)).
+		Str("server_cn", state.PeerCertificates[0].Subject.CommonName).
+		Msg("[LocalDB] TLS connection verified")
+	return nil
+}
+
+// monitorPool mide la latencia del pool cada 30s y detecta saturación.
+// Si en un intervalo hubo más esperas que 2×MaxOpenConns se reduce el timeout
+// de las consultas para no encadenar retrasos en el resto de checkers.
+// También recarga cada 30 minutos la calidad de las fuentes.
+func (c *LocalDBChecker) monitorPool() {
+	ticker := time.NewTicker(localDBHealthInterval)
+	defer ticker.Stop()
+	qualityTicker := time.NewTicker(localDBQualityInterval)
+	defer qualityTicker.Stop()
+
+	c.pingPool()
+	c.loadSourceQuality()
+	for {
+		select {
+		case <-ticker.C:
+			c.pingPool()
+		case <-qualityTicker.C:
+			c.loadSourceQuality()
+		case <-c.stopMonitor:
+			return
+		}
+	}
+}
+
+// loadSourceQuality carga source_quality en memoria. Si falla se mantiene la carga anterior.
+func (c *LocalDBChecker) loadSourceQuality() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := c.db.QueryContext(ctx, `SELECT source, quality_score FROM source_quality`)
+	if err != nil {
+		log.Warn().Err(err).Msg("[LocalDB] Failed to load source quality")
+		return
+	}
+	defer rows.Close()
+
+	quality := make(map[string]float64)
+	for rows.Next() {
+		var source string
+		var score float64
+		if err := rows.Scan(&source, &score); err != nil {
+			log.Warn().Err(err).Msg("[LocalDB] Failed to load source quality")
+			return
+		}
+		quality[source] = score
+	}
+	if err := rows.Err(); err != nil {
+		log.Warn().Err(err).Msg("[LocalDB] Failed to load source quality")
+		return
+	}
+
+	c.sourceQuality.Store(&quality)
+	log.Debug().Int("sources", len(quality)).Msg("[LocalDB] Source quality loaded")
+}
+
+// qualityFor quality_score de una fuente (1.0 si no tiene: fuentes manuales o sin calcular)
+func (c *LocalDBChecker) qualityFor(source string) float64 {
+	quality := c.sourceQuality.Load()
+	if quality == nil {
+		return 1.0
+	}
+	if score, ok := (*quality)[source]; ok && score >= 0 && score <= 1 {
+		return score
+	}
+	return 1.0
+}
+
+// applySourceQuality escala la confianza del resultado por la calidad de la fuente del registro
+func (c *LocalDBChecker) applySourceQuality(result *CheckResult, source string) {
+	quality := c.qualityFor(source)
+	result.RawData["feed_source"] = source
+	if quality < 1.0 {
+		result.Confidence *= quality
+		result.RawData["source_quality"] = quality
+	}
+}
+
+// ConfidenceDecay factor por el que se multiplica la confianza de un registro visto por última
+// vez hace age: exp(-lambda * días). Los vistos en los últimos confidenceDecayGraceDays no
+// decaen y los de más de confidenceDecayMaxDays pierden al menos un 90% con cualquier lambda.
+func ConfidenceDecay(lambda float64, age time.Duration) float64 {
+	days := age.Hours() / 24
+	if lambda <= 0 || days <= confidenceDecayGraceDays {
+		return 1.0
+	}
+	factor := math.Exp(-lambda * days)
+	if days > confidenceDecayMaxDays && factor > confidenceDecayMaxFactor {
+		factor = confidenceDecayMaxFactor
+	}
+	return factor
+}
+
+// applyConfidenceDecay ajusta la confianza del resultado por la antigüedad de last_seen.
+// Retorna true si la confianza decaída queda por debajo de staleConfidenceThreshold.
+func (c *LocalDBChecker) applyConfidenceDecay(result *CheckResult, lastSeen time.Time) bool {
+	original := result.Confidence
+	result.Confidence = original * ConfidenceDecay(c.decayLambda, time.Since(lastSeen))
+	result.RawData["original_confidence"] = original
+	result.RawData["confidence_decayed"] = result.Confidence
+	result.RawData["last_seen"] = lastSeen.UTC().Format(time.RFC3339)
+	return result.Confidence < staleConfidenceThreshold
+}
+
+// markStale marca el registro como stale_candidate para el reaper de registros obsoletos
+// (where lo selecciona con $1 = key). El veredicto no cambia: sigue en lista negra hasta
+// que el reaper o un analista lo desactiven.
+func (c *LocalDBChecker) markStale(ctx context.Context, result *CheckResult, table, where string, key interface{}) {
+	result.RawData["stale_candidate"] = true
+
+	query := fmt.Sprintf(`UPDATE %s SET flags = flags | %s WHERE %s AND %s`,
+		table, flags.StaleCandidate.SQL(), where, flags.StaleCandidate.IsClear("flags"))
+	done := c.timeQuery(table, query)
+	res, err := c.db.ExecContext(ctx, query, key)
+	done()
+	if err != nil {
+		log.Debug().Err(err).Str("table", table).Msg("[LocalDB] Failed to mark stale candidate")
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		log.Info().
+			Str("table", table).
+			Float64("confidence", result.Confidence).
+			Interface("last_seen", result.RawData["last_seen"]).
+			Msg("[LocalDB] Entry marked as stale candidate")
+	}
+}
+
+func (c *LocalDBChecker) pingPool() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	var one int
+	if err := c.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+		log.Warn().Err(err).Msg("[LocalDB] Pool health check failed")
+		c.conn.markDown(err)
+	}
+	latency := time.Since(start).Milliseconds()
+	c.lastLatencyMs.Store(latency)
+	localDBLatency.Set(float64(latency))
+
+	stats := c.db.Stats()
+	waits := stats.WaitCount - c.lastWaitCount
+	c.lastWaitCount = stats.WaitCount
+
+	if waits > int64(c.maxConns*2) {
+		c.degradedUntil.Store(time.Now().Add(localDBDegradedDuration).UnixNano())
+		log.Warn().
+			Int64("waits", waits).
+			Int("max_conns", c.maxConns).
+			Int("in_use", stats.InUse).
+			Dur("wait_duration", stats.WaitDuration).
+			Dur("check_timeout", localDBDegradedTimeout).
+			Msg("[LocalDB] Connection pool saturated, reducing check timeout")
+	}
+}
+
+// checkTimeout timeout vigente: reducido mientras el pool está saturado
+func (c *LocalDBChecker) checkTimeout() time.Duration {
+	if time.Now().UnixNano() < c.degradedUntil.Load() {
+		return localDBDegradedTimeout
+	}
+	return c.queryTimeout
+}
+
+// timeQuery mide una consulta hasta que se llama a la función retornada (tras el Scan o
+// rows.Close). Las consultas lentas se registran sin parámetros: el texto solo contiene
+// placeholders, nunca datos del usuario.
+func (c *LocalDBChecker) timeQuery(table, query string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		localDBQueryDuration.WithLabelValues(table).Observe(elapsed.Seconds())
+		if elapsed < localDBSlowQuery {
+			return
+		}
+		localDBSlowQueries.WithLabelValues(table).Inc()
+		log.Warn().
+			Str("table", table).
+			Str("query", strings.Join(strings.Fields(query), " ")).
+			Dur("duration", elapsed).
+			Msg("[LocalDB] Slow query")
+	}
+}
+
+// ConnectionPoolStats retorna el estado del pool (nil si el checker está deshabilitado)
+func (c *LocalDBChecker) ConnectionPoolStats() *PoolStats {
+	if !c.IsEnabled() {
+		return nil
+	}
+
+	stats := c.db.Stats()
+	timeout := c.checkTimeout()
+	return &PoolStats{
+		MaxOpenConns:    stats.MaxOpenConnections,
+		OpenConnections: stats.OpenConnections,
+		InUse:           stats.InUse,
+		Idle:            stats.Idle,
+		WaitCount:       stats.WaitCount,
+		WaitDurationMs:  stats.WaitDuration.Milliseconds(),
+		LatencyMs:       c.lastLatencyMs.Load(),
+		Saturated:       timeout != c.queryTimeout,
+		CheckTimeout:    timeout.String(),
 	}
 }
 
 // Check verifica un indicador contra la base de datos local
 func (c *LocalDBChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
-	if !c.enabled || c.db == nil {
+	if !c.IsEnabled() {
 		return &CheckResult{
 			Source: c.Name(),
 			Found:  false,
@@ -95,20 +449,43 @@ func (c *LocalDBChecker) Check(ctx context.Context, indicators *Indicators) (*Ch
 
 	startTime := time.Now()
 
+	ctx, cancel := context.WithTimeout(ctx, c.checkTimeout())
+	defer cancel()
+
+	ctx, span := tracing.Tracer().Start(ctx, "LocalDBChecker.Check",
+		trace.WithAttributes(
+			attribute.String("checker.name", c.Name()),
+			attribute.String("analysis.input_type", string(indicators.InputType)),
+			attribute.String("db.system", "postgresql"),
+		),
+	)
+	defer span.End()
+
+	var result *CheckResult
+	var err error
 	switch indicators.InputType {
 	case InputTypeURL:
-		return c.checkURL(ctx, indicators, startTime)
+		result, err = c.checkURL(ctx, indicators, startTime)
 	case InputTypeEmail:
-		return c.checkEmail(ctx, indicators, startTime)
+		result, err = c.checkEmail(ctx, indicators, startTime)
 	case InputTypePhone:
-		return c.checkPhone(ctx, indicators, startTime)
+		result, err = c.checkPhone(ctx, indicators, startTime)
 	default:
-		return &CheckResult{
+		result = &CheckResult{
 			Source:  c.Name(),
 			Found:   false,
 			Latency: time.Since(startTime),
-		}, nil
+		}
+	}
+
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else if result != nil {
+		span.SetAttributes(attribute.Bool("checker.found", result.Found))
 	}
+
+	return result, err
 }
 
 // checkURL verifica una URL en la base de datos (Schema v2.0 optimizado)
@@ -128,12 +505,15 @@ func (c *LocalDBChecker) checkURL(ctx context.Context, indicators *Indicators, s
 	if domain != "" {
 		var brand sql.NullString
 		var category sql.NullString
-		err := c.db.QueryRowContext(ctx, `
+		query := `
 			SELECT brand, category
 			FROM whitelist_domains
 			WHERE domain = $1
 			LIMIT 1
-		`, domain).Scan(&brand, &category)
+		`
+		done := c.timeQuery("whitelist_domains", query)
+		err := c.db.QueryRowContext(ctx, query, domain).Scan(&brand, &category)
+		done()
 
 		if err == nil {
 			// Dominio está en whitelist - marcar como seguro
@@ -165,24 +545,33 @@ func (c *LocalDBChecker) checkURL(ctx context.Context, indicators *Indicators, s
 	// 1. Buscar dominio usando la función optimizada find_threat_domain
 	if domain != "" {
 		var domainHash []byte
-		var domainStr, threatType, severity string
+		var domainStr, threatType, severity, source string
 		var confidence int16
 		var impersonates sql.NullString
+		var lastSeen time.Time
 
 		log.Debug().
 			Str("domain", domain).
 			Msg("[LocalDB] Searching domain with find_threat_domain")
 
-		err := c.db.QueryRowContext(ctx, `
-			SELECT domain_hash, domain, threat_type, severity, confidence, impersonates
-			FROM find_threat_domain($1)
+		query := `
+			SELECT f.domain_hash, f.domain, f.threat_type, f.severity, f.confidence, f.impersonates, f.source, td.last_seen
+			FROM find_threat_domain($1) f
+			JOIN threat_domains td ON td.domain_hash = f.domain_hash
 			LIMIT 1
-		`, domain).Scan(&domainHash, &domainStr, &threatType, &severity, &confidence, &impersonates)
+		`
+		done := c.timeQuery("threat_domains", query)
+		err := c.db.QueryRowContext(ctx, query, domain).Scan(&domainHash, &domainStr, &threatType, &severity, &confidence, &impersonates, &source, &lastSeen)
+		done()
 
 		if err == nil {
 			result.Found = true
 			result.ThreatType = threatType
 			result.Confidence = float64(confidence) / 100.0 // Convertir 0-100 a 0.0-1.0
+			c.applySourceQuality(result, source)
+			if c.applyConfidenceDecay(result, lastSeen) {
+				c.markStale(ctx, result, "threat_domains", "domain_hash = $1", domainHash)
+			}
 			if impersonates.Valid {
 				reasons = append(reasons, fmt.Sprintf("Dominio malicioso que suplanta a %s", impersonates.String))
 				result.RawData["impersonates"] = impersonates.String
@@ -198,27 +587,43 @@ func (c *LocalDBChecker) checkURL(ctx context.Context, indicators *Indicators, s
 		}
 	}
 
-	// 2. Si no encontramos el dominio, buscar path específico
-	if !result.Found && indicators.Path != "" && domain != "" {
-		var threatType, severity string
+	// 2. Si no encontramos el dominio, buscar path específico. Se busca el path canónico y,
+	// mientras fy-admin y fy-dbsync guarden los paths sin canonicalizar, también el original.
+	canonicalPath := indicators.CanonicalPath
+	if canonicalPath == "" {
+		canonicalPath = indicators.Path
+	}
+	if !result.Found && canonicalPath != "" && domain != "" {
+		var domainHash, pathHash []byte
+		var threatType, severity, source string
 		var confidence int16
+		var lastSeen time.Time
 
-		err := c.db.QueryRowContext(ctx, `
-			SELECT tp.threat_type, tp.severity, tp.confidence
+		query := `
+			SELECT tp.domain_hash, tp.path_hash, tp.threat_type, tp.severity, tp.confidence, tp.source, tp.last_seen
 			FROM threat_paths tp
 			JOIN threat_domains td ON tp.domain_hash = td.domain_hash
 			WHERE td.domain = $1
-			  AND tp.path = $2
-			  AND (tp.flags & 1) = 1
+			  AND tp.path IN ($2, $3)
+			  AND ` + flags.Active.IsSet("tp.flags") + `
+			  AND ` + flags.FalsePositiveCandidate.IsClear("tp.flags") + `
 			LIMIT 1
-		`, domain, indicators.Path).Scan(&threatType, &severity, &confidence)
+		`
+		done := c.timeQuery("threat_paths", query)
+		err := c.db.QueryRowContext(ctx, query, domain, canonicalPath, indicators.Path).Scan(&domainHash, &pathHash, &threatType, &severity, &confidence, &source, &lastSeen)
+		done()
 
 		if err == nil {
 			result.Found = true
 			result.ThreatType = threatType
 			result.Confidence = float64(confidence) / 100.0
+			c.applySourceQuality(result, source)
+			if c.applyConfidenceDecay(result, lastSeen) {
+				c.markStale(ctx, result, "threat_paths", "path_hash = $1", pathHash)
+			}
 			result.RawData["severity"] = severity
 			reasons = append(reasons, fmt.Sprintf("Path malicioso encontrado (%s)", threatType))
+			result.Tags = c.getDomainTags(ctx, domainHash)
 		}
 	}
 
@@ -241,6 +646,13 @@ func (c *LocalDBChecker) checkURL(ctx context.Context, indicators *Indicators, s
 		}
 	}
 
+	// 4. Historial de reputación del dominio (se incluye aunque no haya amenaza actual)
+	if domain != "" {
+		if score, ok := c.updateReputation(ctx, domain, result.Found); ok {
+			result.RawData["historical_reputation"] = score
+		}
+	}
+
 	result.RawData["reasons"] = reasons
 	result.Latency = time.Since(startTime)
 
@@ -254,14 +666,125 @@ func (c *LocalDBChecker) checkURL(ctx context.Context, indicators *Indicators, s
 	return result, nil
 }
 
-// getDomainTags obtiene los tags de un dominio
+// updateReputation acumula el resultado de la comprobación en domain_reputation_scores y
+// retorna el score actualizado: porcentaje (en decenas) de comprobaciones con amenaza
+func (c *LocalDBChecker) updateReputation(ctx context.Context, domain string, found bool) (int, bool) {
+	query := `
+		INSERT INTO domain_reputation_scores AS r
+			(domain_hash, score, last_positive, positive_count, negative_count, updated_at)
+		VALUES (
+			sha256_bytea($1),
+			CASE WHEN $2 THEN 100 ELSE 0 END,
+			CASE WHEN $2 THEN NOW() END,
+			CASE WHEN $2 THEN 1 ELSE 0 END,
+			CASE WHEN $2 THEN 0 ELSE 1 END,
+			NOW()
+		)
+		ON CONFLICT (domain_hash) DO UPDATE SET
+			positive_count = r.positive_count + EXCLUDED.positive_count,
+			negative_count = r.negative_count + EXCLUDED.negative_count,
+			score = ((r.positive_count + EXCLUDED.positive_count) * 10
+				/ (r.positive_count + r.negative_count + 1)) * 10,
+			last_positive = COALESCE(EXCLUDED.last_positive, r.last_positive),
+			updated_at = NOW()
+		RETURNING score
+	`
+	var score int
+	done := c.timeQuery("domain_reputation_scores", query)
+	err := c.db.QueryRowContext(ctx, query, domain, found).Scan(&score)
+	done()
+
+	if err != nil {
+		log.Debug().Err(err).Msg("[LocalDB] Error updating domain reputation")
+		return 0, false
+	}
+	return score, true
+}
+
+// CheckRedirectChain comprueba la URL como Check y, si no está en lista negra, los dominios de
+// los saltos de la cadena de redirects. Un dominio en whitelist no hace segura la URL si
+// redirige a un dominio malicioso (redirectores legítimos abusados).
+func (c *LocalDBChecker) CheckRedirectChain(ctx context.Context, indicators *Indicators, chain []string) (*CheckResult, error) {
+	result, err := c.Check(ctx, indicators)
+	if err != nil || result == nil || result.Found || result.Error != nil {
+		return result, err
+	}
+	if result.RawData == nil {
+		result.RawData = make(map[string]interface{})
+	}
+	result.RawData["redirect_chain"] = chain
+
+	ctx, cancel := context.WithTimeout(ctx, c.checkTimeout())
+	defer cancel()
+
+	checked := map[string]bool{strings.ToLower(indicators.Domain): true}
+	for _, hop := range chain {
+		parsed, err := url.Parse(hop)
+		if err != nil {
+			continue
+		}
+		domain := strings.ToLower(parsed.Hostname())
+		if domain == "" || checked[domain] {
+			continue
+		}
+		checked[domain] = true
+
+		var domainHash []byte
+		var threatType string
+		var confidence int16
+		var lastSeen time.Time
+		query := `
+			SELECT f.domain_hash, f.threat_type, f.confidence, td.last_seen
+			FROM find_threat_domain($1) f
+			JOIN threat_domains td ON td.domain_hash = f.domain_hash
+			LIMIT 1
+		`
+		done := c.timeQuery("threat_domains", query)
+		err = c.db.QueryRowContext(ctx, query, domain).Scan(&domainHash, &threatType, &confidence, &lastSeen)
+		done()
+		if err != nil {
+			if err != sql.ErrNoRows {
+				log.Debug().Err(err).Str("domain", domain).Msg("[LocalDB] Error checking redirect hop")
+			}
+			continue
+		}
+
+		result.Found = true
+		result.ThreatType = threatType
+		result.Confidence = float64(confidence) / 100.0
+		delete(result.RawData, "is_safe")
+		delete(result.RawData, "whitelisted")
+		result.RawData["redirect_hop"] = domain
+		if c.applyConfidenceDecay(result, lastSeen) {
+			c.markStale(ctx, result, "threat_domains", "domain_hash = $1", domainHash)
+		}
+		result.Tags = c.getDomainTags(ctx, domainHash)
+		result.RawData["reasons"] = []string{fmt.Sprintf("La URL redirige a un dominio en lista negra: %s (%s)", domain, threatType)}
+
+		log.Info().
+			Str("url", indicators.FullURL).
+			Str("hop", domain).
+			Str("threat_type", threatType).
+			Msg("[LocalDB] Malicious domain found in redirect chain")
+		break
+	}
+
+	return result, nil
+}
+
+// getDomainTags obtiene los tags de un dominio (domain_tags, asignados desde fy-admin o por
+// el feed de URLhaus). Se leen en cada comprobación: un tag nuevo aparece en el siguiente
+// veredicto.
 func (c *LocalDBChecker) getDomainTags(ctx context.Context, domainHash []byte) []string {
-	rows, err := c.db.QueryContext(ctx, `
+	query := `
 		SELECT t.name
 		FROM domain_tags dt
 		JOIN tags t ON dt.tag_id = t.id
 		WHERE dt.domain_hash = $1
-	`, domainHash)
+	`
+	defer c.timeQuery("domain_tags", query)()
+
+	rows, err := c.db.QueryContext(ctx, query, domainHash)
 	if err != nil {
 		return nil
 	}
@@ -289,24 +812,55 @@ func (c *LocalDBChecker) checkEmail(ctx context.Context, indicators *Indicators,
 
 	var reasons []string
 	email := strings.ToLower(indicators.Normalized)
+	normalized := indicators.EmailNormalized
+	if normalized == "" {
+		normalized = email
+	}
 
-	// 1. Buscar email exacto por hash BYTEA
+	// 1. Buscar el email por hash BYTEA: primero en forma canónica (user+tag@gmail.com ==
+	// user@gmail.com, cubre todas las variantes guardadas) y después tal cual, para las filas
+	// que aún no tienen email_normalized_hash
+	var emailHash []byte
 	var threatType, severity string
 	var confidence int16
 	var impersonates sql.NullString
-	var flags int16
+	var flagBits int16
+	var lastSeen time.Time
 
-	err := c.db.QueryRowContext(ctx, `
-		SELECT threat_type, severity, confidence, impersonates, flags
+	query := `
+		SELECT email_hash, threat_type, severity, confidence, impersonates, flags, last_seen
 		FROM threat_emails
-		WHERE email_hash = sha256_bytea($1) AND (flags & 1) = 1
+		WHERE email_normalized_hash = sha256_bytea($2) AND ` + activeNotFalsePositive + `
+		UNION ALL
+		SELECT email_hash, threat_type, severity, confidence, impersonates, flags, last_seen
+		FROM threat_emails
+		WHERE email_hash IN (sha256_bytea($1), sha256_bytea($2)) AND ` + activeNotFalsePositive + `
 		LIMIT 1
-	`, email).Scan(&threatType, &severity, &confidence, &impersonates, &flags)
+	`
+	done := c.timeQuery("threat_emails", query)
+	err := c.db.QueryRowContext(ctx, query, email, normalized).Scan(&emailHash, &threatType, &severity, &confidence, &impersonates, &flagBits, &lastSeen)
+	done()
+
+	if err != nil && err != sql.ErrNoRows && ctx.Err() == nil {
+		// Sin la migración 007 no existe email_normalized_hash: solo hashes exactos
+		query = `
+			SELECT email_hash, threat_type, severity, confidence, impersonates, flags, last_seen
+			FROM threat_emails
+			WHERE email_hash IN (sha256_bytea($1), sha256_bytea($2)) AND ` + activeNotFalsePositive + `
+			LIMIT 1
+		`
+		done := c.timeQuery("threat_emails", query)
+		err = c.db.QueryRowContext(ctx, query, email, normalized).Scan(&emailHash, &threatType, &severity, &confidence, &impersonates, &flagBits, &lastSeen)
+		done()
+	}
 
 	if err == nil {
 		result.Found = true
 		result.ThreatType = threatType
 		result.Confidence = float64(confidence) / 100.0
+		if c.applyConfidenceDecay(result, lastSeen) {
+			c.markStale(ctx, result, "threat_emails", "email_hash = $1", emailHash)
+		}
 		result.RawData["severity"] = severity
 		if impersonates.Valid {
 			reasons = append(reasons, fmt.Sprintf("Email fraudulento que suplanta a %s", impersonates.String))
@@ -321,19 +875,28 @@ func (c *LocalDBChecker) checkEmail(ctx context.Context, indicators *Indicators,
 	// 2. Verificar si el dominio del email está en threat_domains
 	if !result.Found && indicators.EmailDomain != "" {
 		emailDomain := strings.ToLower(indicators.EmailDomain)
+		var domHash []byte
 		var domThreatType, domSeverity string
 		var domConfidence int16
+		var domLastSeen time.Time
 
-		err := c.db.QueryRowContext(ctx, `
-			SELECT threat_type, severity, confidence
-			FROM find_threat_domain($1)
+		query := `
+			SELECT f.domain_hash, f.threat_type, f.severity, f.confidence, td.last_seen
+			FROM find_threat_domain($1) f
+			JOIN threat_domains td ON td.domain_hash = f.domain_hash
 			LIMIT 1
-		`, emailDomain).Scan(&domThreatType, &domSeverity, &domConfidence)
+		`
+		done := c.timeQuery("threat_domains", query)
+		err := c.db.QueryRowContext(ctx, query, emailDomain).Scan(&domHash, &domThreatType, &domSeverity, &domConfidence, &domLastSeen)
+		done()
 
 		if err == nil {
 			result.Found = true
 			result.ThreatType = domThreatType
 			result.Confidence = float64(domConfidence) / 100.0 * 0.9 // Ligeramente menor confianza
+			if c.applyConfidenceDecay(result, domLastSeen) {
+				c.markStale(ctx, result, "threat_domains", "domain_hash = $1", domHash)
+			}
 			result.RawData["severity"] = domSeverity
 			reasons = append(reasons, "Dominio del email en lista negra")
 		}
@@ -393,24 +956,31 @@ func (c *LocalDBChecker) checkPhone(ctx context.Context, indicators *Indicators,
 	var threatType, severity string
 	var confidence int16
 	var description sql.NullString
-	var flags int16
+	var flagBits int16
+	var reportCount sql.NullInt32
+	var lastSeen time.Time
 
-	err := c.db.QueryRowContext(ctx, `
-		SELECT threat_type, severity, confidence, description, flags
+	query := `
+		SELECT threat_type, severity, confidence, description, flags, report_count, last_seen
 		FROM threat_phones
-		WHERE phone_national = $1 AND (flags & 1) = 1
+		WHERE phone_national = $1 AND ` + activeNotFalsePositive + `
 		LIMIT 1
-	`, phoneNational).Scan(&threatType, &severity, &confidence, &description, &flags)
+	`
+	done := c.timeQuery("threat_phones", query)
+	err := c.db.QueryRowContext(ctx, query, phoneNational).Scan(&threatType, &severity, &confidence, &description, &flagBits, &reportCount, &lastSeen)
+	done()
 
 	if err == nil {
 		result.Found = true
 		result.ThreatType = threatType
 		result.Confidence = float64(confidence) / 100.0
+		if c.applyConfidenceDecay(result, lastSeen) {
+			c.markStale(ctx, result, "threat_phones", "phone_national = $1", phoneNational)
+		}
 		result.RawData["severity"] = severity
+		result.RawData["report_count"] = int(reportCount.Int32)
 
-		// Check bit 1 (is_premium)
-		isPremium := (flags & 2) == 2
-		if isPremium {
+		if flags.Premium.Has(flagBits) {
 			reasons = append(reasons, "Número premium fraudulento reportado")
 			result.RawData["is_premium"] = true
 		}
@@ -452,6 +1022,23 @@ func (c *LocalDBChecker) checkPhone(ctx context.Context, indicators *Indicators,
 	return result, nil
 }
 
+func init() {
+	Registry.Register("localdb", newLocalDBFromConfig)
+}
+
+// newLocalDBFromConfig factory del registro. Con PostgreSQL caído se registra igualmente:
+// el orchestrator lo usa en cuanto vuelva.
+func newLocalDBFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	checker := cfg.sharedLocalDB()
+	if checker == nil {
+		return nil, nil
+	}
+	if !checker.IsEnabled() && !checker.Reconnecting() {
+		return nil, fmt.Errorf("LocalDB checker failed to initialize")
+	}
+	return checker, nil
+}
+
 // Name retorna el nombre del checker
 func (c *LocalDBChecker) Name() string {
 	return "localdb"
@@ -462,9 +1049,19 @@ func (c *LocalDBChecker) Weight() float64 {
 	return c.weight
 }
 
-// IsEnabled indica si el checker está habilitado
+// IsEnabled indica si el checker está habilitado. Con la DB caída lanza (como mucho
+// una vez por intervalo) un reintento de conexión en background.
 func (c *LocalDBChecker) IsEnabled() bool {
-	return c.enabled
+	if c.conn == nil {
+		return false
+	}
+	c.conn.retryIfDue()
+	return c.conn.Enabled()
+}
+
+// Reconnecting indica si la DB está configurada pero no responde y se está reintentando
+func (c *LocalDBChecker) Reconnecting() bool {
+	return c.conn != nil && c.conn.Retrying()
 }
 
 // SupportedTypes retorna los tipos de input soportados
@@ -472,8 +1069,22 @@ func (c *LocalDBChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL, InputTypeEmail, InputTypePhone}
 }
 
-// Close cierra la conexión a la base de datos
+// Health comprueba que la base de datos responde
+func (c *LocalDBChecker) Health(ctx context.Context) error {
+	if c.db == nil {
+		return fmt.Errorf("database not configured")
+	}
+	return c.db.PingContext(ctx)
+}
+
+// Close detiene la monitorización del pool y cierra la conexión a la base de datos
 func (c *LocalDBChecker) Close() error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.stopMonitor != nil {
+		c.stopOnce.Do(func() { close(c.stopMonitor) })
+	}
 	if c.db != nil {
 		return c.db.Close()
 	}
@@ -485,17 +1096,143 @@ func (c *LocalDBChecker) GetDB() *sql.DB {
 	return c.db
 }
 
-// GetStats retorna estadísticas de la base de datos (Schema v2.0)
+// ThreatMetricsTable totales de una tabla de amenazas
+type ThreatMetricsTable struct {
+	Total     int64 `json:"total"`
+	Active    int64 `json:"active"`
+	Phishing  int64 `json:"phishing"`
+	Malware   int64 `json:"malware"`
+	Scam      int64 `json:"scam"`
+	SizeBytes int64 `json:"size_bytes"`
+}
+
+// ThreatMetricsCount recuento por fuente o tipo de amenaza
+type ThreatMetricsCount struct {
+	Key    string `json:"key"`
+	Total  int64  `json:"total"`
+	Active int64  `json:"active"`
+}
+
+// ThreatMetrics contenido de la vista materializada threat_metrics
+type ThreatMetrics struct {
+	Tables         map[string]ThreatMetricsTable `json:"tables"`
+	BySource       []ThreatMetricsCount          `json:"by_source"`
+	EmailsBySource []ThreatMetricsCount          `json:"emails_by_source"`
+	ByThreatType   []ThreatMetricsCount          `json:"by_threat_type"`
+	RefreshedAt    time.Time                     `json:"refreshed_at"`
+	Stale          bool                          `json:"stale,omitempty"`
+}
+
+// RefreshStatsView recalcula threat_metrics sin bloquear las lecturas (requiere la migración 006)
+func (c *LocalDBChecker) RefreshStatsView(ctx context.Context) error {
+	if !c.IsEnabled() {
+		return fmt.Errorf("checker disabled")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, localDBStatsRefreshTimeout)
+	defer cancel()
+
+	query := `REFRESH MATERIALIZED VIEW CONCURRENTLY threat_metrics`
+	done := c.timeQuery("threat_metrics", query)
+	_, err := c.db.ExecContext(ctx, query)
+	done()
+	return err
+}
+
+// GetThreatMetrics lee threat_metrics; Stale indica que el último refresco supera los 10 minutos
+func (c *LocalDBChecker) GetThreatMetrics(ctx context.Context) (*ThreatMetrics, error) {
+	if !c.IsEnabled() {
+		return nil, fmt.Errorf("checker disabled")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	defer cancel()
+
+	query := `
+		SELECT scope, key, total, active, phishing, malware, scam, size_bytes, refreshed_at
+		FROM threat_metrics
+		ORDER BY scope, total DESC
+	`
+	defer c.timeQuery("threat_metrics", query)()
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := &ThreatMetrics{
+		Tables:         make(map[string]ThreatMetricsTable),
+		BySource:       []ThreatMetricsCount{},
+		EmailsBySource: []ThreatMetricsCount{},
+		ByThreatType:   []ThreatMetricsCount{},
+	}
+	for rows.Next() {
+		var scope, key string
+		var table ThreatMetricsTable
+		var refreshedAt time.Time
+		if err := rows.Scan(&scope, &key, &table.Total, &table.Active, &table.Phishing,
+			&table.Malware, &table.Scam, &table.SizeBytes, &refreshedAt); err != nil {
+			return nil, err
+		}
+		if refreshedAt.After(metrics.RefreshedAt) {
+			metrics.RefreshedAt = refreshedAt
+		}
+
+		count := ThreatMetricsCount{Key: key, Total: table.Total, Active: table.Active}
+		switch scope {
+		case "table":
+			metrics.Tables[key] = table
+		case "domain_source":
+			metrics.BySource = append(metrics.BySource, count)
+		case "email_source":
+			metrics.EmailsBySource = append(metrics.EmailsBySource, count)
+		case "domain_threat_type":
+			metrics.ByThreatType = append(metrics.ByThreatType, count)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	metrics.Stale = time.Since(metrics.RefreshedAt) > localDBStatsStaleAfter
+	return metrics, nil
+}
+
+// GetStats retorna estadísticas de la base de datos (Schema v2.0).
+// Lee threat_metrics; sin la vista materializada recurre a threat_stats y, en último caso, a COUNT(*)
 func (c *LocalDBChecker) GetStats(ctx context.Context) (map[string]interface{}, error) {
-	if !c.enabled || c.db == nil {
+	if !c.IsEnabled() {
 		return nil, fmt.Errorf("checker disabled")
 	}
 
 	stats := make(map[string]interface{})
 
-	// Usar la vista threat_stats optimizada
-	rows, err := c.db.QueryContext(ctx, `SELECT type, total, active, phishing, malware, scam, size FROM threat_stats`)
+	if metrics, err := c.GetThreatMetrics(ctx); err == nil {
+		for name, table := range metrics.Tables {
+			stats[name] = map[string]interface{}{
+				"total":      table.Total,
+				"active":     table.Active,
+				"phishing":   table.Phishing,
+				"malware":    table.Malware,
+				"scam":       table.Scam,
+				"size_bytes": table.SizeBytes,
+			}
+		}
+		stats["refreshed_at"] = metrics.RefreshedAt
+		stats["stale"] = metrics.Stale
+		return stats, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.queryTimeout)
+	defer cancel()
+
+	// Vista threat_stats (no materializada)
+	query := `SELECT type, total, active, phishing, malware, scam, size FROM threat_stats`
+	done := c.timeQuery("threat_stats", query)
+	rows, err := c.db.QueryContext(ctx, query)
 	if err == nil {
+		defer done()
 		defer rows.Close()
 		for rows.Next() {
 			var tableType, size string
@@ -518,17 +1255,19 @@ func (c *LocalDBChecker) GetStats(ctx context.Context) (map[string]interface{},
 			query string
 		}
 		queries := []tableQuery{
-			{"threat_domains", "SELECT COUNT(*) FROM threat_domains WHERE (flags & 1) = 1"},
-			{"threat_paths", "SELECT COUNT(*) FROM threat_paths WHERE (flags & 1) = 1"},
-			{"threat_emails", "SELECT COUNT(*) FROM threat_emails WHERE (flags & 1) = 1"},
-			{"threat_phones", "SELECT COUNT(*) FROM threat_phones WHERE (flags & 1) = 1"},
+			{"threat_domains", "SELECT COUNT(*) FROM threat_domains WHERE " + flags.Active.IsSet("flags")},
+			{"threat_paths", "SELECT COUNT(*) FROM threat_paths WHERE " + flags.Active.IsSet("flags")},
+			{"threat_emails", "SELECT COUNT(*) FROM threat_emails WHERE " + flags.Active.IsSet("flags")},
+			{"threat_phones", "SELECT COUNT(*) FROM threat_phones WHERE " + flags.Active.IsSet("flags")},
 			{"whitelist_domains", "SELECT COUNT(*) FROM whitelist_domains"},
 		}
 		for _, q := range queries {
 			var count int64
+			done := c.timeQuery(q.name, q.query)
 			if err := c.db.QueryRowContext(ctx, q.query).Scan(&count); err == nil {
 				stats[q.name] = count
 			}
+			done()
 		}
 	}
 
@@ -551,11 +1290,14 @@ func (c *LocalDBChecker) detectBrandImpersonation(ctx context.Context, domain st
 	domain = strings.ToLower(domain)
 
 	// Obtener todas las marcas de la whitelist
-	rows, err := c.db.QueryContext(ctx, `
+	query := `
 		SELECT LOWER(brand), domain
 		FROM whitelist_domains
 		WHERE brand IS NOT NULL AND brand != ''
-	`)
+	`
+	defer c.timeQuery("whitelist_domains", query)()
+
+	rows, err := c.db.QueryContext(ctx, query)
 	if err != nil {
 		log.Debug().Err(err).Msg("[LocalDB] Error fetching whitelist brands")
 		return "", ""
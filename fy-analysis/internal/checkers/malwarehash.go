@@ -0,0 +1,417 @@
+package checkers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	virusTotalBaseURL    = "https://www.virustotal.com/api/v3"
+	malwareBazaarBaseURL = "https://mb-api.abuse.ch/api/v1/"
+	malwareHashTimeout   = 5 * time.Second
+	malwareHashCacheTTL  = 7 * 24 * time.Hour // Un veredicto sobre un fichero apenas cambia
+	malwareHashCacheSize = 10000
+
+	// Confianza según los motores de VirusTotal que lo detectan: 1 = malwareHashMinConfidence,
+	// malwareHashMaxDetections o más = malwareHashMaxConfidence
+	malwareHashMinConfidence = 0.50
+	malwareHashMaxConfidence = 0.95
+	malwareHashMaxDetections = 10
+	// malwareBazaarConfidence MalwareBazaar solo publica muestras de malware confirmadas
+	malwareBazaarConfidence = 0.90
+)
+
+// errHashNotFound la fuente no conoce el hash (404 de VirusTotal, hash_not_found de MalwareBazaar)
+var errHashNotFound = errors.New("hash not found")
+
+// MalwareHashInfo veredicto de un hash de fichero
+type MalwareHashInfo struct {
+	Source         string // virustotal, malwarebazaar (vacío si ninguna fuente lo conoce)
+	Known          bool   // La fuente conoce el fichero
+	MaliciousCount int    // Motores de VirusTotal que lo detectan (0 en MalwareBazaar)
+	ThreatName     string // Etiqueta sugerida (VirusTotal) o firma (MalwareBazaar)
+	FileType       string
+}
+
+// Malicious indica si el fichero es malware según la fuente
+func (i *MalwareHashInfo) Malicious() bool {
+	return i.Known && (i.MaliciousCount > 0 || i.Source == "malwarebazaar")
+}
+
+type malwareHashCacheEntry struct {
+	info      *MalwareHashInfo
+	expiresAt time.Time
+}
+
+// virusTotalFileResponse campos usados de GET /files/{id}
+type virusTotalFileResponse struct {
+	Data struct {
+		Attributes struct {
+			LastAnalysisStats struct {
+				Malicious  int `json:"malicious"`
+				Suspicious int `json:"suspicious"`
+				Undetected int `json:"undetected"`
+				Harmless   int `json:"harmless"`
+			} `json:"last_analysis_stats"`
+			TypeDescription             string `json:"type_description"`
+			PopularThreatClassification struct {
+				SuggestedThreatLabel string `json:"suggested_threat_label"`
+			} `json:"popular_threat_classification"`
+		} `json:"attributes"`
+	} `json:"data"`
+}
+
+// malwareBazaarResponse respuesta de query=get_info
+type malwareBazaarResponse struct {
+	QueryStatus string `json:"query_status"`
+	Data        []struct {
+		SHA256Hash string `json:"sha256_hash"`
+		FileType   string `json:"file_type"`
+		Signature  string `json:"signature"`
+	} `json:"data"`
+}
+
+// MalwareHashChecker comprueba hashes (MD5/SHA1/SHA256) de adjuntos sospechosos. Consulta
+// VirusTotal y, si no está configurado, falla o no conoce el fichero, MalwareBazaar (que
+// exige Auth-Key: sin MALWAREBAZAAR_KEY no se consulta).
+// Documentación: https://docs.virustotal.com/reference/file-info y https://bazaar.abuse.ch/api/
+type MalwareHashChecker struct {
+	enabled          bool
+	weight           float64
+	virusTotalKey    string
+	malwareBazaarKey string
+	httpClient       *http.Client
+	virusTotalURL    string
+	malwareBazaarURL string
+
+	mu    sync.Mutex
+	cache map[string]malwareHashCacheEntry // Clave: hash en minúsculas
+}
+
+// NewMalwareHashChecker crea el checker de hashes (clients nil = factoría por defecto). Sin
+// ninguna de las dos claves queda deshabilitado.
+func NewMalwareHashChecker(virusTotalKey, malwareBazaarKey string, clients *CheckerHTTPClientFactory) *MalwareHashChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
+	checker := &MalwareHashChecker{
+		enabled:          virusTotalKey != "" || malwareBazaarKey != "",
+		weight:           0.30,
+		virusTotalKey:    virusTotalKey,
+		malwareBazaarKey: malwareBazaarKey,
+		httpClient:       clients.Client(malwareHashTimeout),
+		virusTotalURL:    virusTotalBaseURL,
+		malwareBazaarURL: malwareBazaarBaseURL,
+		cache:            make(map[string]malwareHashCacheEntry),
+	}
+
+	if checker.enabled {
+		log.Info().
+			Bool("virustotal", virusTotalKey != "").
+			Bool("malwarebazaar", malwareBazaarKey != "").
+			Msg("[MalwareHash] Checker enabled")
+	} else {
+		log.Warn().Msg("[MalwareHash] Checker disabled - no VirusTotal or MalwareBazaar key provided")
+	}
+
+	return checker
+}
+
+func init() {
+	Registry.Register("malware_hash", newMalwareHashFromConfig)
+}
+
+// newMalwareHashFromConfig factory del registro (requiere VIRUSTOTAL_KEY o MALWAREBAZAAR_KEY)
+func newMalwareHashFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if cfg.VirusTotalKey == "" && cfg.MalwareBazaarKey == "" {
+		return nil, nil
+	}
+	return NewMalwareHashChecker(cfg.VirusTotalKey, cfg.MalwareBazaarKey, cfg.checkerHTTPClients()), nil
+}
+
+// Name retorna el nombre del checker
+func (c *MalwareHashChecker) Name() string {
+	return "malware_hash"
+}
+
+// Weight retorna el peso del checker
+func (c *MalwareHashChecker) Weight() float64 {
+	return c.weight
+}
+
+// IsEnabled indica si el checker está habilitado
+func (c *MalwareHashChecker) IsEnabled() bool {
+	return c.enabled
+}
+
+// SupportedTypes retorna los tipos soportados (solo hashes de ficheros)
+func (c *MalwareHashChecker) SupportedTypes() []InputType {
+	return []InputType{InputTypeHash}
+}
+
+// NeedsExternalCall implementa ExternalAPIChecker: false si el hash está en la cache
+func (c *MalwareHashChecker) NeedsExternalCall(inputType InputType, value string) bool {
+	if inputType != InputTypeHash {
+		return false
+	}
+	_, ok := c.cached(strings.ToLower(strings.TrimSpace(value)))
+	return !ok
+}
+
+// Health falla si no hay ninguna clave (no se consume cuota para comprobar las APIs)
+func (c *MalwareHashChecker) Health(ctx context.Context) error {
+	if !c.enabled {
+		return fmt.Errorf("VirusTotal and MalwareBazaar keys not configured")
+	}
+	return nil
+}
+
+// Close cierra las conexiones HTTP inactivas
+func (c *MalwareHashChecker) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// Check busca el hash en VirusTotal/MalwareBazaar. Un hash desconocido es Found: false.
+func (c *MalwareHashChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
+	startTime := time.Now()
+	result := &CheckResult{
+		Source:  c.Name(),
+		Found:   false,
+		RawData: make(map[string]interface{}),
+	}
+
+	if !c.enabled {
+		return result, fmt.Errorf("VirusTotal and MalwareBazaar keys not configured")
+	}
+
+	hash := strings.ToLower(strings.TrimSpace(indicators.Normalized))
+	if indicators.InputType != InputTypeHash || hash == "" {
+		return result, nil
+	}
+
+	info, cached, err := c.lookup(ctx, hash)
+	result.Latency = time.Since(startTime)
+	if err != nil {
+		log.Warn().Err(err).Msg("[MalwareHash] Hash lookup failed")
+		return result, err
+	}
+
+	result.RawData["hash_type"] = indicators.FileHashType
+	result.RawData["malicious_count"] = info.MaliciousCount
+	result.RawData["threat_name"] = info.ThreatName
+	result.RawData["file_type"] = info.FileType
+	result.RawData["lookup_source"] = info.Source
+	result.RawData["cached"] = cached
+
+	if !info.Malicious() {
+		log.Debug().Bool("known", info.Known).Str("lookup_source", info.Source).Msg("[MalwareHash] Hash not flagged as malware")
+		return result, nil
+	}
+
+	result.Found = true
+	result.ThreatType = ThreatTypeMalware
+	result.Confidence = malwareHashConfidence(info)
+	if info.ThreatName != "" {
+		result.Tags = []string{info.ThreatName}
+	}
+	reason := "El fichero es malware conocido"
+	if info.Source == "virustotal" {
+		reason = fmt.Sprintf("El fichero es malware: lo detectan %d antivirus en VirusTotal", info.MaliciousCount)
+	}
+	if info.ThreatName != "" {
+		reason += " (" + info.ThreatName + ")"
+	}
+	result.RawData["reasons"] = []string{reason}
+
+	log.Info().
+		Str("lookup_source", info.Source).
+		Int("malicious_count", info.MaliciousCount).
+		Str("threat_name", info.ThreatName).
+		Msg("[MalwareHash] Malicious file hash found")
+
+	return result, nil
+}
+
+// lookup veredicto del hash (cacheado malwareHashCacheTTL, también los hashes desconocidos)
+func (c *MalwareHashChecker) lookup(ctx context.Context, hash string) (*MalwareHashInfo, bool, error) {
+	if info, ok := c.cached(hash); ok {
+		return info, true, nil
+	}
+
+	// Se pasa a la siguiente fuente si la anterior no conoce el hash o falla; un hash que
+	// ninguna conoce también se cachea, un fallo no
+	var info *MalwareHashInfo
+	var errs []error
+	if c.virusTotalKey != "" {
+		vtInfo, err := c.virusTotalFile(ctx, hash)
+		switch {
+		case err == nil:
+			info = vtInfo
+		case !errors.Is(err, errHashNotFound):
+			log.Debug().Err(err).Msg("[MalwareHash] VirusTotal lookup failed")
+			errs = append(errs, fmt.Errorf("virustotal: %w", err))
+		}
+	}
+	if info == nil && c.malwareBazaarKey != "" {
+		mbInfo, err := c.malwareBazaarInfo(ctx, hash)
+		switch {
+		case err == nil:
+			info = mbInfo
+		case !errors.Is(err, errHashNotFound):
+			errs = append(errs, fmt.Errorf("malwarebazaar: %w", err))
+		}
+	}
+	if info == nil {
+		if len(errs) > 0 {
+			return nil, false, errors.Join(errs...)
+		}
+		info = &MalwareHashInfo{}
+	}
+
+	c.mu.Lock()
+	// Al llenarse se descartan las entradas caducadas; si no basta, se vacía
+	if len(c.cache) >= malwareHashCacheSize {
+		now := time.Now()
+		for k, e := range c.cache {
+			if now.After(e.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= malwareHashCacheSize {
+			c.cache = make(map[string]malwareHashCacheEntry)
+		}
+	}
+	c.cache[hash] = malwareHashCacheEntry{info: info, expiresAt: time.Now().Add(malwareHashCacheTTL)}
+	c.mu.Unlock()
+
+	return info, false, nil
+}
+
+// virusTotalFile GET /files/{id}. errHashNotFound si VirusTotal no tiene el fichero.
+func (c *MalwareHashChecker) virusTotalFile(ctx context.Context, hash string) (*MalwareHashInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.virusTotalURL+"/files/"+url.PathEscape(hash), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("x-apikey", c.virusTotalKey)
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, errHashNotFound
+	case http.StatusTooManyRequests:
+		return nil, fmt.Errorf("rate limited")
+	default:
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var vt virusTotalFileResponse
+	if err := json.Unmarshal(body, &vt); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	attrs := vt.Data.Attributes
+	return &MalwareHashInfo{
+		Source:         "virustotal",
+		Known:          true,
+		MaliciousCount: attrs.LastAnalysisStats.Malicious,
+		ThreatName:     attrs.PopularThreatClassification.SuggestedThreatLabel,
+		FileType:       attrs.TypeDescription,
+	}, nil
+}
+
+// malwareBazaarInfo POST query=get_info. errHashNotFound si MalwareBazaar no tiene la muestra.
+func (c *MalwareHashChecker) malwareBazaarInfo(ctx context.Context, hash string) (*MalwareHashInfo, error) {
+	form := url.Values{"query": {"get_info"}, "hash": {hash}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.malwareBazaarURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Auth-Key", c.malwareBazaarKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	var mb malwareBazaarResponse
+	if err := json.Unmarshal(body, &mb); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	switch mb.QueryStatus {
+	case "ok":
+	case "hash_not_found", "no_results":
+		return nil, errHashNotFound
+	default:
+		return nil, fmt.Errorf("query_status %q", mb.QueryStatus)
+	}
+	if len(mb.Data) == 0 {
+		return nil, errHashNotFound
+	}
+
+	sample := mb.Data[0]
+	return &MalwareHashInfo{
+		Source:     "malwarebazaar",
+		Known:      true,
+		ThreatName: sample.Signature,
+		FileType:   sample.FileType,
+	}, nil
+}
+
+// cached veredicto cacheado y vigente del hash
+func (c *MalwareHashChecker) cached(hash string) (*MalwareHashInfo, bool) {
+	c.mu.Lock()
+	entry, ok := c.cache[hash]
+	c.mu.Unlock()
+	if !ok || time.Now().After(entry.expiresAt) {
+		return nil, false
+	}
+	return entry.info, true
+}
+
+// malwareHashConfidence confianza de un hash malicioso: fija para MalwareBazaar y, en
+// VirusTotal, lineal entre malwareHashMinConfidence (1 motor) y malwareHashMaxConfidence
+// (malwareHashMaxDetections+)
+func malwareHashConfidence(info *MalwareHashInfo) float64 {
+	if info.Source == "malwarebazaar" {
+		return malwareBazaarConfidence
+	}
+	if info.MaliciousCount >= malwareHashMaxDetections {
+		return malwareHashMaxConfidence
+	}
+	step := (malwareHashMaxConfidence - malwareHashMinConfidence) / float64(malwareHashMaxDetections-1)
+	return malwareHashMinConfidence + float64(info.MaliciousCount-1)*step
+}
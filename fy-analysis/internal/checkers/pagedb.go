@@ -0,0 +1,174 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/corona10/goimagehash"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// pageHashMaxDistance distancia de Hamming máxima (bits) para considerar dos páginas visualmente iguales
+	pageHashMaxDistance = 10
+	// pageDBMaxPerSync límite de páginas nuevas indexadas por sync (capturar es caro)
+	pageDBMaxPerSync     = 200
+	pageDBCaptureTimeout = 20 * time.Second
+	pageDBMaxImageSize   = 10 << 20
+)
+
+// PageMatch página de phishing conocida visualmente similar
+type PageMatch struct {
+	SourceURL string
+	Distance  int
+}
+
+// PhishingPageDB hashes perceptuales (pHash) de páginas de phishing conocidas
+type PhishingPageDB struct {
+	db            *sql.DB
+	client        *http.Client
+	screenshotter Screenshotter
+	maxPerSync    int
+}
+
+// NewPhishingPageDB crea el índice visual sobre phishing_page_hashes.
+// screenshotter puede ser nil: solo se indexan entradas con screenshot publicado.
+func NewPhishingPageDB(db *sql.DB, screenshotter Screenshotter) *PhishingPageDB {
+	return &PhishingPageDB{
+		db:            db,
+		client:        &http.Client{Timeout: pageDBCaptureTimeout},
+		screenshotter: screenshotter,
+		maxPerSync:    pageDBMaxPerSync,
+	}
+}
+
+// PerceptualHash calcula el pHash de 64 bits de una imagen
+func PerceptualHash(img image.Image) (uint64, error) {
+	hash, err := goimagehash.PerceptionHash(img)
+	if err != nil {
+		return 0, err
+	}
+	return hash.GetHash(), nil
+}
+
+// IndexPhishTank calcula y guarda el pHash de las entradas de PhishTank aún no indexadas.
+// Usa el screenshot de PhishTank si existe; si no, captura la página (si hay screenshotter).
+func (p *PhishingPageDB) IndexPhishTank(ctx context.Context, entries []*PhishTankEntry) (int, error) {
+	indexed, err := p.indexedURLs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	added, failed := 0, 0
+	for _, entry := range entries {
+		if added >= p.maxPerSync || ctx.Err() != nil {
+			break
+		}
+		if indexed[entry.URL] || (entry.ScreenshotURL == "" && p.screenshotter == nil) {
+			continue
+		}
+
+		img, err := p.loadImage(ctx, entry)
+		if err != nil {
+			failed++
+			log.Debug().Err(err).Str("url", entry.URL).Msg("[PageDB] Failed to load page image")
+			continue
+		}
+
+		hash, err := PerceptualHash(img)
+		if err != nil {
+			failed++
+			continue
+		}
+
+		_, err = p.db.ExecContext(ctx, `
+			INSERT INTO phishing_page_hashes (url_hash, phash, source_url, source)
+			VALUES (sha256_bytea($1), $2, $1, 'phishtank')
+			ON CONFLICT (url_hash) DO NOTHING
+		`, entry.URL, int64(hash))
+		if err != nil {
+			return added, err
+		}
+		added++
+	}
+
+	log.Info().
+		Int("added", added).
+		Int("failed", failed).
+		Int("already_indexed", len(indexed)).
+		Msg("[PageDB] PhishTank page hashes indexed")
+
+	return added, nil
+}
+
+// indexedURLs URLs que ya tienen hash (para no volver a capturarlas)
+func (p *PhishingPageDB) indexedURLs(ctx context.Context) (map[string]bool, error) {
+	rows, err := p.db.QueryContext(ctx, `SELECT source_url FROM phishing_page_hashes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	indexed := make(map[string]bool)
+	for rows.Next() {
+		var u string
+		if err := rows.Scan(&u); err != nil {
+			return nil, err
+		}
+		indexed[u] = true
+	}
+	return indexed, rows.Err()
+}
+
+// loadImage descarga el screenshot de PhishTank o captura la página
+func (p *PhishingPageDB) loadImage(ctx context.Context, entry *PhishTankEntry) (image.Image, error) {
+	ctx, cancel := context.WithTimeout(ctx, pageDBCaptureTimeout)
+	defer cancel()
+
+	if entry.ScreenshotURL == "" {
+		return p.screenshotter.Capture(ctx, entry.URL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, entry.ScreenshotURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("screenshot download returned status %d", resp.StatusCode)
+	}
+
+	img, _, err := image.Decode(io.LimitReader(resp.Body, pageDBMaxImageSize))
+	return img, err
+}
+
+// FindSimilar busca la página conocida más parecida con distancia de Hamming <= pageHashMaxDistance
+func (p *PhishingPageDB) FindSimilar(ctx context.Context, hash uint64) (*PageMatch, error) {
+	match := &PageMatch{}
+	err := p.db.QueryRowContext(ctx, `
+		SELECT source_url, bit_count((phash # $1)::bit(64)) AS distance
+		FROM phishing_page_hashes
+		WHERE bit_count((phash # $1)::bit(64)) <= $2
+		ORDER BY distance
+		LIMIT 1
+	`, int64(hash), pageHashMaxDistance).Scan(&match.SourceURL, &match.Distance)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return match, nil
+}
@@ -26,6 +26,9 @@ type PhishTankChecker struct {
 	lastUpdate  time.Time
 	downloadURL string
 	apiKey      string // Opcional, para mayor rate limit
+	downloader  Downloader
+	// Resultado del último intento de descarga
+	lastDownload DownloadOutcome
 }
 
 // PhishTankEntry representa una entrada en la DB de PhishTank
@@ -38,6 +41,7 @@ type PhishTankEntry struct {
 	VerifiedTime      string   `json:"verification_time"`
 	Online            string   `json:"online"`
 	Target            string   `json:"target"`
+	ScreenshotURL     string   `json:"screenshot_url,omitempty"`
 	Details           []Detail `json:"details,omitempty"`
 }
 
@@ -49,8 +53,11 @@ type Detail struct {
 	Country     string `json:"country"`
 }
 
-// NewPhishTankChecker crea un nuevo checker de PhishTank
-func NewPhishTankChecker(dbPath string, apiKey string) *PhishTankChecker {
+// NewPhishTankChecker crea un nuevo checker de PhishTank (clients nil = factoría por defecto)
+func NewPhishTankChecker(dbPath string, apiKey string, clients *CheckerHTTPClientFactory) *PhishTankChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
 	checker := &PhishTankChecker{
 		enabled:     true,
 		weight:      0.20,
@@ -66,6 +73,13 @@ func NewPhishTankChecker(dbPath string, apiKey string) *PhishTankChecker {
 		checker.downloadURL = fmt.Sprintf("http://data.phishtank.com/data/%s/online-valid.json", apiKey)
 	}
 
+	// PhishTank requiere User-Agent y puede ser lento
+	checker.downloader = &HTTPDownloader{
+		Client: clients.Client(120 * time.Second),
+		URL:    checker.downloadURL,
+		Header: http.Header{"User-Agent": {"phishtank/fy-analysis"}},
+	}
+
 	// Intentar cargar DB existente
 	if err := checker.LoadDB(); err != nil {
 		log.Warn().Err(err).Msg("[PhishTank] Failed to load existing DB, will download")
@@ -74,6 +88,15 @@ func NewPhishTankChecker(dbPath string, apiKey string) *PhishTankChecker {
 	return checker
 }
 
+func init() {
+	Registry.Register("phishtank", newPhishTankFromConfig)
+}
+
+// newPhishTankFromConfig factory del registro: PhishTank siempre está disponible (DB local)
+func newPhishTankFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	return NewPhishTankChecker(cfg.PhishTankDBPath, cfg.PhishTankKey, cfg.checkerHTTPClients()), nil
+}
+
 // Name retorna el nombre del checker
 func (c *PhishTankChecker) Name() string {
 	return "phishtank"
@@ -94,6 +117,21 @@ func (c *PhishTankChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL}
 }
 
+// Health falla si la base local no tiene entradas cargadas
+func (c *PhishTankChecker) Health(ctx context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.urlDB) == 0 {
+		return fmt.Errorf("PhishTank database is empty")
+	}
+	return nil
+}
+
+// Close no libera nada (la base está en memoria)
+func (c *PhishTankChecker) Close() error {
+	return nil
+}
+
 // Check verifica los indicadores contra PhishTank
 func (c *PhishTankChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
 	c.mu.RLock()
@@ -203,75 +241,127 @@ func (c *PhishTankChecker) LoadDB() error {
 	return c.parseJSON(file)
 }
 
-// DownloadDB descarga la base de datos actualizada
+// DownloadDB descarga la base de datos actualizada.
+// Si la descarga no es válida se conserva el archivo y el índice anteriores.
 func (c *PhishTankChecker) DownloadDB(ctx context.Context) error {
-	log.Info().Str("url", c.downloadURL).Msg("[PhishTank] Downloading database...")
-
-	req, err := http.NewRequestWithContext(ctx, "GET", c.downloadURL, nil)
+	body, err := c.fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		c.recordDownload(DownloadOutcome{At: time.Now(), Error: err.Error(), PreviousRows: c.rowCount()})
+		return err
+	}
+	defer body.Close()
+
+	var urlDB, domainDB map[string]*PhishTankEntry
+	outcome := replaceDBFile(body, c.dbPath, c.rowCount(), func(r io.Reader) (int, error) {
+		var err error
+		urlDB, domainDB, err = c.readJSON(r)
+		return len(urlDB), err
+	})
+
+	if !outcome.Success {
+		c.recordDownload(outcome)
+		log.Warn().
+			Str("error", outcome.Error).
+			Int("rows", outcome.Rows).
+			Int("previous_rows", outcome.PreviousRows).
+			Msg("[PhishTank] Download rejected, keeping previous database")
+		return fmt.Errorf("phishtank download rejected: %s", outcome.Error)
 	}
 
-	// PhishTank requiere User-Agent
-	req.Header.Set("User-Agent", "phishtank/fy-analysis")
+	log.Info().Int64("bytes", outcome.Bytes).Msg("[PhishTank] Database downloaded")
 
-	client := &http.Client{Timeout: 120 * time.Second} // PhishTank puede ser lento
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
+	c.mu.Lock()
+	c.urlDB = urlDB
+	c.domainDB = domainDB
+	c.lastUpdate = time.Now()
+	c.lastDownload = outcome
+	c.mu.Unlock()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("download failed with status %d: %s", resp.StatusCode, string(body))
-	}
+	log.Info().
+		Int("urls", len(urlDB)).
+		Int("domains", len(domainDB)).
+		Int("row_delta", outcome.Delta()).
+		Msg("[PhishTank] Database loaded")
+
+	return nil
+}
+
+// rowCount retorna el número de URLs cargadas actualmente
+func (c *PhishTankChecker) rowCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.urlDB)
+}
+
+// recordDownload guarda el resultado del último intento de descarga
+func (c *PhishTankChecker) recordDownload(outcome DownloadOutcome) {
+	c.mu.Lock()
+	c.lastDownload = outcome
+	c.mu.Unlock()
+}
 
-	// Guardar a archivo
-	file, err := os.Create(c.dbPath)
+// FetchEntries descarga y parsea el feed sin tocar el archivo local ni el índice en memoria
+func (c *PhishTankChecker) FetchEntries(ctx context.Context) ([]*PhishTankEntry, error) {
+	body, err := c.fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create DB file: %w", err)
+		return nil, err
 	}
-	defer file.Close()
+	defer body.Close()
 
-	written, err := io.Copy(file, resp.Body)
+	urlDB, _, err := c.readJSON(body)
 	if err != nil {
-		return fmt.Errorf("failed to write DB file: %w", err)
+		return nil, fmt.Errorf("failed to parse downloaded DB: %w", err)
 	}
 
-	log.Info().Int64("bytes", written).Msg("[PhishTank] Database downloaded")
-
-	// Recargar DB
-	file.Seek(0, 0)
+	entries := make([]*PhishTankEntry, 0, len(urlDB))
+	for _, entry := range urlDB {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Entries retorna una copia de las entradas cargadas actualmente en memoria
+func (c *PhishTankChecker) Entries() []*PhishTankEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if err := c.parseJSON(file); err != nil {
-		return fmt.Errorf("failed to parse downloaded DB: %w", err)
+	entries := make([]*PhishTankEntry, 0, len(c.urlDB))
+	for _, entry := range c.urlDB {
+		entries = append(entries, entry)
 	}
+	return entries
+}
 
-	c.lastUpdate = time.Now()
-	log.Info().
-		Int("urls", len(c.urlDB)).
-		Int("domains", len(c.domainDB)).
-		Msg("[PhishTank] Database loaded")
+// fetch realiza la descarga del JSON y retorna el body de la respuesta
+func (c *PhishTankChecker) fetch(ctx context.Context) (io.ReadCloser, error) {
+	log.Info().Str("url", c.downloadURL).Msg("[PhishTank] Downloading database...")
 
-	return nil
+	return c.downloader.Download(ctx)
 }
 
-// parseJSON parsea el archivo JSON de PhishTank
+// parseJSON parsea el archivo JSON de PhishTank y reemplaza el índice en memoria
 func (c *PhishTankChecker) parseJSON(reader io.Reader) error {
-	// Limpiar DBs existentes
-	c.urlDB = make(map[string]*PhishTankEntry)
-	c.domainDB = make(map[string]*PhishTankEntry)
+	urlDB, domainDB, err := c.readJSON(reader)
+	if err != nil {
+		return err
+	}
+
+	c.urlDB = urlDB
+	c.domainDB = domainDB
+	return nil
+}
+
+// readJSON parsea el JSON de PhishTank en índices por URL y por dominio
+func (c *PhishTankChecker) readJSON(reader io.Reader) (map[string]*PhishTankEntry, map[string]*PhishTankEntry, error) {
+	urlDB := make(map[string]*PhishTankEntry)
+	domainDB := make(map[string]*PhishTankEntry)
 
 	decoder := json.NewDecoder(reader)
 
 	// El JSON es un array de entries
 	var entries []PhishTankEntry
 	if err := decoder.Decode(&entries); err != nil {
-		return fmt.Errorf("failed to decode JSON: %w", err)
+		return nil, nil, fmt.Errorf("failed to decode JSON: %w", err)
 	}
 
 	for i := range entries {
@@ -281,17 +371,17 @@ func (c *PhishTankChecker) parseJSON(reader io.Reader) error {
 		normalizedURL := strings.ToLower(entry.URL)
 
 		// Indexar por URL
-		c.urlDB[normalizedURL] = entry
+		urlDB[normalizedURL] = entry
 
 		// Extraer e indexar por dominio
 		domain := c.extractDomain(entry.URL)
 		if domain != "" {
-			c.domainDB[domain] = entry
+			domainDB[domain] = entry
 		}
 	}
 
 	log.Debug().Int("entries", len(entries)).Msg("[PhishTank] JSON parsed")
-	return nil
+	return urlDB, domainDB, nil
 }
 
 // extractDomain extrae el dominio de una URL
@@ -330,8 +420,9 @@ func (c *PhishTankChecker) GetStats() map[string]interface{} {
 	defer c.mu.RUnlock()
 
 	return map[string]interface{}{
-		"urls":        len(c.urlDB),
-		"domains":     len(c.domainDB),
-		"last_update": c.lastUpdate,
+		"urls":          len(c.urlDB),
+		"domains":       len(c.domainDB),
+		"last_update":   c.lastUpdate,
+		"last_download": c.lastDownload.ToMap(),
 	}
 }
@@ -0,0 +1,125 @@
+package checkers
+
+import "strings"
+
+// PremiumPrefix prefijo de tarificación especial dentro del plan de numeración nacional
+type PremiumPrefix struct {
+	Prefix    string // Prefijo del número nacional (sin código de país ni 0 troncal)
+	CostClass string // Descripción del tipo de coste ("tarificación adicional", "coste compartido"...)
+	MinLen    int    // Longitud mínima del número nacional para considerarlo válido
+	MaxLen    int    // Longitud máxima del número nacional
+}
+
+// PremiumCountry plan de tarificación especial de un país
+type PremiumCountry struct {
+	DialCode string // Código de país E.164 (+34)
+	ISO      string // Código ISO 3166-1 alpha-2 (ES)
+	Name     string // Nombre del país en español
+	Prefixes []PremiumPrefix
+}
+
+// PremiumMatch resultado de buscar un número en la tabla de tarificación especial
+type PremiumMatch struct {
+	Country   *PremiumCountry
+	Prefix    string
+	CostClass string
+}
+
+// Describe retorna una explicación legible ("número de tarificación adicional en México")
+func (m *PremiumMatch) Describe() string {
+	return "número de " + m.CostClass + " en " + m.Country.Name
+}
+
+// premiumCountries tabla de prefijos de tarificación especial por código de país.
+// Las longitudes evitan falsos positivos con números truncados o mal separados del código de país.
+var premiumCountries = map[string]*PremiumCountry{
+	"+34": {DialCode: "+34", ISO: "ES", Name: "España", Prefixes: []PremiumPrefix{
+		{Prefix: "803", CostClass: "tarificación adicional (servicios para adultos)", MinLen: 9, MaxLen: 9},
+		{Prefix: "806", CostClass: "tarificación adicional (ocio y entretenimiento)", MinLen: 9, MaxLen: 9},
+		{Prefix: "807", CostClass: "tarificación adicional (servicios profesionales)", MinLen: 9, MaxLen: 9},
+		{Prefix: "905", CostClass: "tarificación adicional (televoto)", MinLen: 9, MaxLen: 9},
+		{Prefix: "907", CostClass: "tarificación adicional (servicios de datos)", MinLen: 9, MaxLen: 9},
+	}},
+	"+52": {DialCode: "+52", ISO: "MX", Name: "México", Prefixes: []PremiumPrefix{
+		{Prefix: "900", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 10},
+	}},
+	"+1": {DialCode: "+1", ISO: "US", Name: "Estados Unidos", Prefixes: []PremiumPrefix{
+		{Prefix: "900", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 10},
+		{Prefix: "976", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 10},
+	}},
+	"+44": {DialCode: "+44", ISO: "GB", Name: "Reino Unido", Prefixes: []PremiumPrefix{
+		{Prefix: "9", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 10},
+		{Prefix: "70", CostClass: "numeración personal de coste elevado", MinLen: 10, MaxLen: 10},
+		{Prefix: "87", CostClass: "tarificación especial", MinLen: 10, MaxLen: 10},
+	}},
+	"+54": {DialCode: "+54", ISO: "AR", Name: "Argentina", Prefixes: []PremiumPrefix{
+		{Prefix: "600", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 10},
+		{Prefix: "609", CostClass: "tarificación adicional (juegos y concursos)", MinLen: 10, MaxLen: 10},
+	}},
+	"+57": {DialCode: "+57", ISO: "CO", Name: "Colombia", Prefixes: []PremiumPrefix{
+		{Prefix: "1900", CostClass: "tarificación adicional", MinLen: 10, MaxLen: 11},
+	}},
+	"+56": {DialCode: "+56", ISO: "CL", Name: "Chile", Prefixes: []PremiumPrefix{
+		{Prefix: "700", CostClass: "tarificación adicional", MinLen: 9, MaxLen: 10},
+	}},
+	"+51": {DialCode: "+51", ISO: "PE", Name: "Perú", Prefixes: []PremiumPrefix{
+		{Prefix: "808", CostClass: "tarificación adicional", MinLen: 8, MaxLen: 9},
+	}},
+}
+
+// PremiumCountryByDialCode retorna el plan de un código de país (+34), o nil si no está en la tabla
+func PremiumCountryByDialCode(dialCode string) *PremiumCountry {
+	return premiumCountries[dialCode]
+}
+
+// PremiumCountryByISO retorna el plan de un país por código ISO (ES), o nil si no está en la tabla
+func PremiumCountryByISO(iso string) *PremiumCountry {
+	iso = strings.ToUpper(iso)
+	for _, country := range premiumCountries {
+		if country.ISO == iso {
+			return country
+		}
+	}
+	return nil
+}
+
+// SplitDialCode separa un número E.164 en código de país y número nacional.
+// Prueba primero los códigos de la tabla (del más largo al más corto).
+func SplitDialCode(e164 string) (string, string, bool) {
+	if !strings.HasPrefix(e164, "+") {
+		return "", e164, false
+	}
+	for i := 4; i >= 2; i-- {
+		if len(e164) <= i {
+			continue
+		}
+		if _, ok := premiumCountries[e164[:i]]; ok {
+			return e164[:i], e164[i:], true
+		}
+	}
+	return "", e164, false
+}
+
+// Match busca el número nacional en los prefijos del país.
+// Ignora un 0 troncal (ej: +44 (0)909...) y descarta números fuera de la longitud esperada.
+func (c *PremiumCountry) Match(nationalNum string) *PremiumMatch {
+	if c == nil {
+		return nil
+	}
+	nationalNum = strings.TrimPrefix(nationalNum, "0")
+
+	for _, p := range c.Prefixes {
+		if len(nationalNum) < p.MinLen || len(nationalNum) > p.MaxLen {
+			continue
+		}
+		if strings.HasPrefix(nationalNum, p.Prefix) {
+			return &PremiumMatch{Country: c, Prefix: p.Prefix, CostClass: p.CostClass}
+		}
+	}
+	return nil
+}
+
+// MatchPremium busca un número nacional en la tabla del código de país indicado (+34)
+func MatchPremium(dialCode, nationalNum string) *PremiumMatch {
+	return PremiumCountryByDialCode(dialCode).Match(nationalNum)
+}
@@ -0,0 +1,100 @@
+package checkers
+
+import "testing"
+
+func TestSplitDialCode(t *testing.T) {
+	tests := []struct {
+		e164, dialCode, national string
+		ok                       bool
+	}{
+		{"+34806123456", "+34", "806123456", true},
+		{"+529001234567", "+52", "9001234567", true},
+		{"+19005550199", "+1", "9005550199", true},
+		{"+449098765432", "+44", "9098765432", true},
+		{"+5719001234567", "+57", "19001234567", true},
+		{"806123456", "", "806123456", false},       // Sin prefijo internacional
+		{"+33612345678", "", "+33612345678", false}, // País fuera de la tabla
+		{"+34", "", "+34", false},
+	}
+
+	for _, tt := range tests {
+		dialCode, national, ok := SplitDialCode(tt.e164)
+		if dialCode != tt.dialCode || national != tt.national || ok != tt.ok {
+			t.Errorf("SplitDialCode(%q) = %q, %q, %v; want %q, %q, %v",
+				tt.e164, dialCode, national, ok, tt.dialCode, tt.national, tt.ok)
+		}
+	}
+}
+
+func TestMatchPremium(t *testing.T) {
+	tests := []struct {
+		name, dialCode, national string
+		wantPrefix               string // "" = no es premium
+	}{
+		{"ES 806", "+34", "806123456", "806"},
+		{"ES 905 televoto", "+34", "905123456", "905"},
+		{"ES mobile", "+34", "612345678", ""},
+		{"ES mobile with 807 inside", "+34", "612807123", ""},
+		{"ES 807 too short", "+34", "80712345", ""},
+		{"ES 807 too long", "+34", "8071234567", ""},
+		{"MX 900", "+52", "9001234567", "900"},
+		{"MX mobile", "+52", "15512345678", ""},
+		{"US 900", "+1", "9005550199", "900"},
+		{"US 976", "+1", "9765550199", "976"},
+		{"US toll free", "+1", "8005550199", ""},
+		{"GB 09 with trunk zero", "+44", "09098765432", "9"},
+		{"GB 70 personal", "+44", "7012345678", "70"},
+		{"GB mobile", "+44", "7912345678", ""},
+		{"GB 9 too short", "+44", "909876543", ""},
+		{"AR 609", "+54", "6091234567", "609"},
+		{"CO 1900 eleven digits", "+57", "19001234567", "1900"},
+		{"CL 700", "+56", "700123456", "700"},
+		{"PE 808 eight digits", "+51", "80812345", "808"},
+		{"PE 808 too short", "+51", "8081234", ""},
+		{"country not in table", "+33", "899123456", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			match := MatchPremium(tt.dialCode, tt.national)
+			switch {
+			case tt.wantPrefix == "" && match != nil:
+				t.Errorf("matched prefix %s, want no match", match.Prefix)
+			case tt.wantPrefix != "" && match == nil:
+				t.Errorf("no match, want prefix %s", tt.wantPrefix)
+			case match != nil && match.Prefix != tt.wantPrefix:
+				t.Errorf("prefix = %s, want %s", match.Prefix, tt.wantPrefix)
+			}
+		})
+	}
+}
+
+func TestPremiumMatchDescribe(t *testing.T) {
+	match := MatchPremium("+52", "9001234567")
+	if match == nil {
+		t.Fatal("no match for a Mexican 900 number")
+	}
+	if got := match.Describe(); got != "número de tarificación adicional en México" {
+		t.Errorf("Describe() = %q", got)
+	}
+}
+
+func TestPremiumCountryLookup(t *testing.T) {
+	if c := PremiumCountryByISO("gb"); c == nil || c.DialCode != "+44" {
+		t.Errorf("PremiumCountryByISO(gb) = %+v, want +44", c)
+	}
+	if c := PremiumCountryByISO("FR"); c != nil {
+		t.Errorf("PremiumCountryByISO(FR) = %+v, want nil", c)
+	}
+	// Un país fuera de la tabla no casa con nada (Match admite receptor nil)
+	if match := PremiumCountryByDialCode("+33").Match("899123456"); match != nil {
+		t.Errorf("match on an unknown country: %+v", match)
+	}
+
+	// Todos los países pedidos tienen plan
+	for _, iso := range []string{"ES", "MX", "US", "GB", "AR", "CO", "CL", "PE"} {
+		if PremiumCountryByISO(iso) == nil {
+			t.Errorf("no premium plan for %s", iso)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package checkers
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress la conexión iba a una dirección local o privada (SSRF)
+var ErrPrivateAddress = errors.New("connection to private address refused")
+
+// PublicOnlyControl hook Control de net.Dialer que rechaza conectar a direcciones locales o
+// privadas. Se ejecuta con la IP ya resuelta, justo antes del connect: a diferencia de
+// comprobar el host antes de la petición (IsPrivateHost), un DNS que cambia de respuesta
+// entre la comprobación y el dial (DNS rebinding) no lo salta.
+func PublicOnlyControl(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, address)
+	}
+	if ip := net.ParseIP(host); ip == nil || IsPrivateIP(ip) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+	return nil
+}
+
+// NewPublicOnlyTransport transport HTTP que solo conecta a direcciones públicas (cada
+// redirect y cada IP que pruebe el dial pasan por PublicOnlyControl). Sin proxy: con proxy
+// el dial iría al proxy y no al destino.
+func NewPublicOnlyTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   PublicOnlyControl,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return transport
+}
@@ -0,0 +1,32 @@
+package checkers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPublicOnlyControl(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:80", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:443", false},
+		{"10.0.0.7:6379", false},
+		{"169.254.169.254:80", false},
+		{"[::ffff:192.168.1.1]:80", false},
+		{"0.0.0.0:9090", false},
+		{"not-an-address", false},
+	}
+	for _, tt := range tests {
+		err := PublicOnlyControl("tcp", tt.address, nil)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("PublicOnlyControl(%q) = %v, want allowed %v", tt.address, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrPrivateAddress) {
+			t.Errorf("PublicOnlyControl(%q) error %v is not ErrPrivateAddress", tt.address, err)
+		}
+	}
+}
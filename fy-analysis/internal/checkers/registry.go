@@ -0,0 +1,193 @@
+package checkers
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/pkg/dbtls"
+)
+
+// EngineConfig configuración del engine. Vive en checkers para que las factories del
+// registro la reciban sin depender de urlengine (urlengine.EngineConfig es un alias).
+type EngineConfig struct {
+	CheckTimeout          time.Duration
+	URLhausDBPath         string
+	PhishTankDBPath       string
+	GoogleWebRiskKey      string
+	URLScanKey            string
+	EnableURLScanSubmit   bool // Enviar a urlscan.io las URLs sin scans previos (requiere LocalDB)
+	PhishTankKey          string
+	HIBPKey               string // Have I Been Pwned API v3 (brechas de emails)
+	VirusTotalKey         string // VirusTotal API v3 (hashes de ficheros)
+	MalwareBazaarKey      string // Auth-Key de MalwareBazaar (hashes de ficheros, si VirusTotal no lo conoce)
+	SpamdAddress          string // spamd de SpamAssassin: host:port o socket Unix (vacío = deshabilitado)
+	EnableDBSync          bool
+	EnableFileSync        bool // Refrescar archivos locales de URLhaus/PhishTank
+	EnableFeedDBSync      bool // Escribir los feeds en PostgreSQL (requiere DatabaseURL)
+	DatabaseURL           string
+	EnableLocalDB         bool
+	LocalDBMaxConns       int           // Tamaño máximo del pool de LocalDB (0 = 10)
+	DBQueryTimeout        time.Duration // Timeout de las consultas de LocalDB (0 = 2s)
+	ConfidenceDecayLambda float64       // λ del decaimiento de confianza de LocalDB (0 = 0.01; < 0 = sin decaimiento)
+	DBTLS                 dbtls.Config  // Certificados mTLS de PostgreSQL (DB_SSL_CERT/KEY/CA)
+	EnableUserReports     bool          // Habilitar checker de reportes de usuarios
+	EnableThreatPromotion bool          // Promover a threat_domains las URLs con muchos reportes (requiere LocalDB)
+	EnableDomainAge       bool          // Consultar antigüedad del dominio vía RDAP
+	EnableVisual          bool          // Checker de similitud visual (pHash de capturas)
+	ChromeURL             string        // Chrome remoto para capturas (vacío = Chrome local)
+	EnableEmailDNS        bool          // Validar MX/SPF/DMARC del dominio de los emails
+	EmailRulesFile        string        // YAML con las reglas de normalización de emails (vacío = Gmail y Yahoo)
+	WebhooksFile          string        // YAML con los webhooks de amenazas detectadas (vacío = sin webhooks)
+	GatewayURL            string        // api-gateway: alertas push de las URLs promovidas (vacío = sin alertas)
+	SigningSecret         string        // HMAC de las peticiones internas a api-gateway
+	URLTrackingParams     []string      // Parámetros de query que la URL canónica descarta (vacío = urlcanon.DefaultTrackingParams)
+	DisposableURL         string        // Lista remota de dominios desechables (vacío = solo la incluida)
+	DisposableInterval    time.Duration // Intervalo de refresco de la lista de desechables
+	TLDRiskFile           string        // Tabla de TLDs de riesgo, "tld puntos" por línea (vacío = la incluida)
+	TLDRiskInterval       time.Duration // Intervalo de recálculo de los puntos con LocalDB (0 = deshabilitado)
+	PhoneLookupTimeout    time.Duration // Presupuesto de GET /analyze/phone/{number}
+	PhoneLookupTTL        time.Duration // TTL de la cache de lookups de teléfono
+	MinAnalysisTimeout    time.Duration // Mínimo del timeout_ms de una petición de análisis
+	MaxAnalysisTimeout    time.Duration // Máximo del timeout_ms de una petición de análisis
+	EnableCarrierLookup   bool          // Operador de los teléfonos vía Numverify/HLR (heurísticas y phone_info.carrier)
+	CarrierLookupURL      string        // Servicio de lookup de operador (vacío = Numverify)
+	CarrierLookupKey      string        // access_key de Numverify
+	DisabledCheckers      []string      // Checkers que el registro no construye (DISABLED_CHECKERS)
+	// Pesos por checker en el score, sobre los por defecto (recargables con ENGINE_CONFIG_FILE)
+	CheckerWeights map[string]float64
+	// Fuentes que deben confirmar una amenaza para llegar a danger (CONSENSUS_THRESHOLD; <= 1 = una)
+	ConsensusThreshold int
+	// Espera de los checkers: all (todos) o priority (una confirmación segura corta el análisis)
+	CheckerPriorityMode string
+	// Fallos seguidos que abren el circuito de un checker (0 = sin circuito) y duración del corte
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// Límites de ReportURL por usuario e IP, repetidos y longitud de la descripción
+	ReportLimits ReportLimitsConfig
+	// Cuotas por llamante y concurrencia de los análisis (las aplica urlengine)
+	AnalysisLimits AnalysisLimitsConfig
+	// Conexiones salientes de los checkers externos (proxy, pool, mTLS); nil = por defecto
+	HTTPClient *HTTPClientConfig
+
+	// Recursos compartidos entre factories durante Build (se crean la primera vez que se piden)
+	httpClients *CheckerHTTPClientFactory
+	localDB     *LocalDBChecker
+	localDBOnce sync.Once
+}
+
+// AnalysisLimitsConfig protección de fy-analysis frente a un llamante que lo satura
+// (valores <= 0 = por defecto). Los presupuestos son por llamante (X-Trackfy-Caller o IP)
+// y por minuto; la concurrencia es global a la instancia.
+type AnalysisLimitsConfig struct {
+	CheapPerMinute     int           // Análisis sin APIs externas (DB local, caches) (default: 600)
+	ExpensivePerMinute int           // Análisis que llaman a alguna API externa (default: 120)
+	MaxConcurrent      int           // Análisis ejecutándose a la vez (default: 32)
+	MaxQueued          int           // Análisis esperando hueco; más allá se rechazan (default: 64)
+	QueueTimeout       time.Duration // Espera máxima de un análisis en cola (default: 2s)
+}
+
+// CheckerDisabled indica si el checker está en DisabledCheckers
+func (c *EngineConfig) CheckerDisabled(name string) bool {
+	for _, disabled := range c.DisabledCheckers {
+		if strings.EqualFold(strings.TrimSpace(disabled), name) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkerHTTPClients clientes HTTP compartidos por los checkers externos
+func (c *EngineConfig) checkerHTTPClients() *CheckerHTTPClientFactory {
+	if c.httpClients == nil {
+		clients, err := NewCheckerHTTPClientFactory(c.HTTPClient)
+		if err != nil {
+			log.Error().Err(err).Msg("[Registry] Invalid checker HTTP client config, using defaults without client certificate")
+			clients = DefaultCheckerHTTPClientFactory()
+		}
+		c.httpClients = clients
+	}
+	return c.httpClients
+}
+
+// sharedLocalDB checker LocalDB cuyo pool reutilizan reportes e índice visual
+// (nil si LocalDB no está configurado o está en DisabledCheckers)
+func (c *EngineConfig) sharedLocalDB() *LocalDBChecker {
+	c.localDBOnce.Do(func() {
+		if !c.EnableLocalDB || c.DatabaseURL == "" || c.CheckerDisabled("localdb") {
+			return
+		}
+		c.localDB = NewLocalDBChecker(&LocalDBConfig{
+			DatabaseURL:  c.DatabaseURL,
+			MaxConns:     c.LocalDBMaxConns,
+			Weight:       0.50, // Peso alto para DB local
+			QueryTimeout: c.DBQueryTimeout,
+			DecayLambda:  c.ConfidenceDecayLambda,
+			TLS:          c.DBTLS,
+		})
+	})
+	return c.localDB
+}
+
+// CheckerFactory construye un checker a partir de la configuración.
+// Retorna (nil, nil) si el checker no está configurado (p. ej. sin API key).
+type CheckerFactory func(cfg *EngineConfig) (ThreatChecker, error)
+
+// CheckerRegistry factories de checkers por nombre, en orden de registro
+type CheckerRegistry struct {
+	mu        sync.Mutex
+	names     []string
+	factories map[string]CheckerFactory
+}
+
+// NewCheckerRegistry crea un registro vacío
+func NewCheckerRegistry() *CheckerRegistry {
+	return &CheckerRegistry{factories: make(map[string]CheckerFactory)}
+}
+
+// Registry registro por defecto: cada checker se registra en su init()
+var Registry = NewCheckerRegistry()
+
+// Register añade una factory; registrar dos veces el mismo nombre es un error de programación
+func (r *CheckerRegistry) Register(name string, factory CheckerFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.factories[name]; exists {
+		panic(fmt.Sprintf("checkers: checker %q registered twice", name))
+	}
+	r.names = append(r.names, name)
+	r.factories[name] = factory
+}
+
+// Build construye los checkers registrados, saltando los deshabilitados, los no configurados
+// y los que fallan al inicializarse
+func (r *CheckerRegistry) Build(cfg *EngineConfig) []ThreatChecker {
+	r.mu.Lock()
+	names := append([]string(nil), r.names...)
+	r.mu.Unlock()
+
+	var built []ThreatChecker
+	for _, name := range names {
+		if cfg.CheckerDisabled(name) {
+			log.Info().Str("checker", name).Msg("[Registry] Checker disabled by DISABLED_CHECKERS")
+			continue
+		}
+
+		checker, err := r.factories[name](cfg)
+		if err != nil {
+			log.Warn().Err(err).Str("checker", name).Msg("[Registry] Checker failed to initialize")
+			continue
+		}
+		if checker == nil {
+			log.Debug().Str("checker", name).Msg("[Registry] Checker not configured")
+			continue
+		}
+
+		built = append(built, checker)
+		log.Info().Str("checker", name).Msg("[Registry] Checker initialized")
+	}
+	return built
+}
@@ -0,0 +1,213 @@
+package checkers
+
+import (
+	"crypto/sha256"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// Límites por defecto de ReportURL (ReportLimitsConfig con valores <= 0)
+	defaultReportsPerUser       = 20
+	defaultReportsPerIP         = 60
+	defaultReportWindow         = time.Hour
+	defaultReportDuplicateTTL   = 24 * time.Hour
+	defaultReportDescriptionLen = 1000
+
+	// reportLimiterMaxKeys tope de claves en memoria de cada limitador (se purgan al llenarse)
+	reportLimiterMaxKeys = 100000
+
+	// TooManyReportsMessage respuesta de ReportURL al superar el límite de reportes por usuario o IP
+	TooManyReportsMessage = "Has enviado demasiados reportes. Inténtalo de nuevo más tarde"
+	// AlreadyReportedMessage prefijo de la respuesta de ReportURL a un reporte repetido del mismo usuario
+	AlreadyReportedMessage = "Ya has reportado esta URL recientemente"
+)
+
+var reportThrottledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "report_throttled_total",
+	Help: "Reportes rechazados antes de llegar a la DB por límite de usuario (user), de IP (ip) o por repetidos (duplicate)",
+}, []string{"reason"})
+
+// ReportLimitsConfig protección de ReportURL a nivel de aplicación (valores <= 0 = por defecto)
+type ReportLimitsConfig struct {
+	MaxPerUser        int           // Reportes por usuario en Window (default: 20)
+	MaxPerIP          int           // Reportes por IP en Window (default: 60)
+	Window            time.Duration // Ventana deslizante de los límites (default: 1h)
+	DuplicateWindow   time.Duration // Tiempo que se recuerda el resultado de un reporte (default: 24h)
+	MaxDescriptionLen int           // Caracteres máximos de la descripción (default: 1000)
+}
+
+func (c ReportLimitsConfig) withDefaults() ReportLimitsConfig {
+	if c.MaxPerUser <= 0 {
+		c.MaxPerUser = defaultReportsPerUser
+	}
+	if c.MaxPerIP <= 0 {
+		c.MaxPerIP = defaultReportsPerIP
+	}
+	if c.Window <= 0 {
+		c.Window = defaultReportWindow
+	}
+	if c.DuplicateWindow <= 0 {
+		c.DuplicateWindow = defaultReportDuplicateTTL
+	}
+	if c.MaxDescriptionLen <= 0 {
+		c.MaxDescriptionLen = defaultReportDescriptionLen
+	}
+	return c
+}
+
+// slidingWindowLimiter límite de eventos por clave en una ventana deslizante (en memoria,
+// por instancia: con varias réplicas el límite efectivo se multiplica)
+type slidingWindowLimiter struct {
+	mu     sync.Mutex
+	events map[string][]time.Time
+	limit  int
+	window time.Duration
+}
+
+func newSlidingWindowLimiter(limit int, window time.Duration) *slidingWindowLimiter {
+	return &slidingWindowLimiter{
+		events: make(map[string][]time.Time),
+		limit:  limit,
+		window: window,
+	}
+}
+
+// allow registra un evento de key si no supera el límite
+func (l *slidingWindowLimiter) allow(key string, now time.Time) bool {
+	if key == "" {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	recent := pruneEvents(l.events[key], now.Add(-l.window))
+	if len(recent) >= l.limit {
+		l.events[key] = recent
+		return false
+	}
+
+	if _, ok := l.events[key]; !ok && len(l.events) >= reportLimiterMaxKeys {
+		l.purge(now)
+	}
+	l.events[key] = append(recent, now)
+	return true
+}
+
+// purge descarta las claves sin eventos en la ventana; si no basta, se vacía
+func (l *slidingWindowLimiter) purge(now time.Time) {
+	cutoff := now.Add(-l.window)
+	for key, events := range l.events {
+		if len(events) == 0 || !events[len(events)-1].After(cutoff) {
+			delete(l.events, key)
+		}
+	}
+	if len(l.events) >= reportLimiterMaxKeys {
+		l.events = make(map[string][]time.Time)
+	}
+}
+
+// pruneEvents eventos posteriores a cutoff (events está ordenado)
+func pruneEvents(events []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(events) && !events[i].After(cutoff) {
+		i++
+	}
+	return events[i:]
+}
+
+// reportOutcome resultado de un reporte ya procesado
+type reportOutcome struct {
+	message   string
+	score     int
+	expiresAt time.Time
+}
+
+// reportDedup resultado reciente de cada par usuario + URL, para responder a los reportes
+// repetidos sin volver a la DB
+type reportDedup struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]reportOutcome
+	ttl     time.Duration
+}
+
+func newReportDedup(ttl time.Duration) *reportDedup {
+	return &reportDedup{
+		entries: make(map[[sha256.Size]byte]reportOutcome),
+		ttl:     ttl,
+	}
+}
+
+func reportDedupKey(userID, url string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(userID + "\x00" + url))
+}
+
+func (d *reportDedup) get(userID, url string, now time.Time) (reportOutcome, bool) {
+	key := reportDedupKey(userID, url)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	outcome, ok := d.entries[key]
+	if !ok {
+		return reportOutcome{}, false
+	}
+	if now.After(outcome.expiresAt) {
+		delete(d.entries, key)
+		return reportOutcome{}, false
+	}
+	return outcome, true
+}
+
+func (d *reportDedup) set(userID, url, message string, score int, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// Al llenarse se descartan las entradas caducadas; si no basta, se vacía
+	if len(d.entries) >= reportLimiterMaxKeys {
+		for k, e := range d.entries {
+			if now.After(e.expiresAt) {
+				delete(d.entries, k)
+			}
+		}
+		if len(d.entries) >= reportLimiterMaxKeys {
+			d.entries = make(map[[sha256.Size]byte]reportOutcome)
+		}
+	}
+
+	d.entries[reportDedupKey(userID, url)] = reportOutcome{
+		message:   message,
+		score:     score,
+		expiresAt: now.Add(d.ttl),
+	}
+}
+
+// sanitizeDescription quita caracteres de control (salvo saltos de línea y tabuladores),
+// quita los espacios de los extremos y recorta a maxLen caracteres
+func sanitizeDescription(description string, maxLen int) string {
+	if !utf8.ValidString(description) {
+		description = strings.ToValidUTF8(description, "")
+	}
+	description = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\t' {
+			return r
+		}
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) {
+			return -1
+		}
+		return r
+	}, description)
+	description = strings.TrimSpace(description)
+
+	if utf8.RuneCountInString(description) > maxLen {
+		description = strings.TrimSpace(string([]rune(description)[:maxLen]))
+	}
+	return description
+}
@@ -0,0 +1,79 @@
+package checkers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/png"
+
+	"github.com/chromedp/chromedp"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	screenshotWidth  = 1280
+	screenshotHeight = 800
+)
+
+// Screenshotter captura la parte visible de una página web
+type Screenshotter interface {
+	Capture(ctx context.Context, pageURL string) (image.Image, error)
+}
+
+// ChromeScreenshotter captura páginas con Chrome headless vía chromedp.
+// Con remoteURL (ej: ws://chrome:9222) usa un Chrome externo; si está vacío lanza uno local.
+type ChromeScreenshotter struct {
+	allocCtx context.Context
+	cancel   context.CancelFunc
+}
+
+// NewChromeScreenshotter crea el allocator de Chrome (las pestañas se abren por captura)
+func NewChromeScreenshotter(remoteURL string) *ChromeScreenshotter {
+	var allocCtx context.Context
+	var cancel context.CancelFunc
+
+	if remoteURL != "" {
+		allocCtx, cancel = chromedp.NewRemoteAllocator(context.Background(), remoteURL)
+		log.Info().Str("url", remoteURL).Msg("[Screenshot] Using remote Chrome")
+	} else {
+		opts := append(chromedp.DefaultExecAllocatorOptions[:],
+			chromedp.WindowSize(screenshotWidth, screenshotHeight),
+			chromedp.Flag("disable-gpu", true),
+		)
+		allocCtx, cancel = chromedp.NewExecAllocator(context.Background(), opts...)
+		log.Info().Msg("[Screenshot] Using local headless Chrome")
+	}
+
+	return &ChromeScreenshotter{allocCtx: allocCtx, cancel: cancel}
+}
+
+// Capture abre la URL en una pestaña nueva y retorna la captura del viewport
+func (s *ChromeScreenshotter) Capture(ctx context.Context, pageURL string) (image.Image, error) {
+	tabCtx, cancel := chromedp.NewContext(s.allocCtx)
+	defer cancel()
+
+	// La pestaña cuelga del allocator: se cierra también si vence el contexto del llamador
+	stop := context.AfterFunc(ctx, cancel)
+	defer stop()
+
+	var buf []byte
+	err := chromedp.Run(tabCtx,
+		chromedp.EmulateViewport(screenshotWidth, screenshotHeight),
+		chromedp.Navigate(pageURL),
+		chromedp.CaptureScreenshot(&buf),
+	)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("screenshot failed: %w", err)
+	}
+
+	return png.Decode(bytes.NewReader(buf))
+}
+
+// Close cierra el allocator (y el Chrome local si lo hay)
+func (s *ChromeScreenshotter) Close() {
+	s.cancel()
+}
@@ -0,0 +1,34 @@
+package checkers
+
+import "strings"
+
+// shortenerDomains servicios de acortamiento de URLs (los usan el Normalizer para expandir
+// y las heurísticas de cadenas de redirects)
+var shortenerDomains = map[string]bool{
+	"bit.ly":      true,
+	"tinyurl.com": true,
+	"t.co":        true,
+	"goo.gl":      true,
+	"ow.ly":       true,
+	"is.gd":       true,
+	"buff.ly":     true,
+	"adf.ly":      true,
+	"bl.ink":      true,
+	"lnkd.in":     true,
+	"rebrand.ly":  true,
+	"short.io":    true,
+	"cutt.ly":     true,
+	"shorturl.at": true,
+	"rb.gy":       true,
+	"v.gd":        true,
+	"clck.ru":     true,
+	"shorte.st":   true,
+	"bc.vc":       true,
+	"j.mp":        true,
+}
+
+// IsShortener indica si el host es un servicio de acortamiento (con o sin www.)
+func IsShortener(host string) bool {
+	host = strings.ToLower(host)
+	return shortenerDomains[host] || shortenerDomains[strings.TrimPrefix(host, "www.")]
+}
@@ -0,0 +1,319 @@
+package checkers
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	spamdTimeout     = 3 * time.Second
+	spamdDefaultPort = "783"
+	// spamdMaxResponse las respuestas a CHECK son unas pocas cabeceras
+	spamdMaxResponse = 64 << 10
+
+	// spamAssassinThreshold score a partir del cual el email se considera spam (el
+	// required_score por defecto de SpamAssassin). Se aplica el nuestro y no el de spamd
+	// para que el veredicto no dependa de la configuración del servidor.
+	spamAssassinThreshold = 5.0
+	// spamAssassinMaxScore score con el que la confianza llega a 1.0
+	spamAssassinMaxScore = 20.0
+)
+
+// spamdResultRe cabecera de resultado de CHECK: "Spam: True ; 15.3 / 5.0"
+var spamdResultRe = regexp.MustCompile(`^Spam:\s*(\w+)\s*;\s*(-?[0-9.]+)\s*/\s*(-?[0-9.]+)`)
+
+// rfc822HeaderRe primera línea de un email con cabeceras ("From: ...", "Received: ...")
+var rfc822HeaderRe = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9-]*:[ \t]`)
+
+// SpamAssassinResult veredicto de spamd para un mensaje
+type SpamAssassinResult struct {
+	IsSpam    bool    // Veredicto de spamd (con su propio umbral)
+	Score     float64 // Puntuación del mensaje
+	Threshold float64 // required_score configurado en spamd
+}
+
+// SpamAssassinChecker puntúa emails con un spamd (protocolo SPAMC/1.5 sobre TCP o socket Unix).
+// Con solo la dirección se envía un mensaje mínimo con ella en From; si el usuario pegó el
+// email completo (contexto original_text) se envía ese texto.
+type SpamAssassinChecker struct {
+	enabled bool
+	weight  float64
+	network string // tcp, unix
+	address string
+	timeout time.Duration
+	dialer  net.Dialer
+}
+
+// NewSpamAssassinChecker crea el checker. address: host:port, tcp://host:port, unix:/ruta o
+// una ruta absoluta al socket (vacío = deshabilitado).
+func NewSpamAssassinChecker(address string) *SpamAssassinChecker {
+	network, addr := parseSpamdAddress(address)
+	checker := &SpamAssassinChecker{
+		enabled: addr != "",
+		weight:  0.10,
+		network: network,
+		address: addr,
+		timeout: spamdTimeout,
+	}
+
+	if checker.enabled {
+		log.Info().Str("network", network).Str("address", addr).Msg("[SpamAssassin] Checker enabled")
+	} else {
+		log.Warn().Msg("[SpamAssassin] Checker disabled - no SPAMD_ADDRESS provided")
+	}
+
+	return checker
+}
+
+func init() {
+	Registry.Register("spamassassin", newSpamAssassinFromConfig)
+}
+
+// newSpamAssassinFromConfig factory del registro (requiere SPAMD_ADDRESS)
+func newSpamAssassinFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if strings.TrimSpace(cfg.SpamdAddress) == "" {
+		return nil, nil
+	}
+	return NewSpamAssassinChecker(cfg.SpamdAddress), nil
+}
+
+// parseSpamdAddress red y dirección de SPAMD_ADDRESS (host sin puerto = puerto 783)
+func parseSpamdAddress(address string) (network, addr string) {
+	address = strings.TrimSpace(address)
+	switch {
+	case address == "":
+		return "", ""
+	case strings.HasPrefix(address, "unix://"):
+		return "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "unix:"):
+		return "unix", strings.TrimPrefix(address, "unix:")
+	case strings.HasPrefix(address, "/"):
+		return "unix", address
+	}
+
+	address = strings.TrimPrefix(address, "tcp://")
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, spamdDefaultPort)
+	}
+	return "tcp", address
+}
+
+// Name retorna el nombre del checker
+func (c *SpamAssassinChecker) Name() string {
+	return "spamassassin"
+}
+
+// Weight retorna el peso del checker
+func (c *SpamAssassinChecker) Weight() float64 {
+	return c.weight
+}
+
+// IsEnabled indica si el checker está habilitado
+func (c *SpamAssassinChecker) IsEnabled() bool {
+	return c.enabled
+}
+
+// SupportedTypes retorna los tipos soportados (solo emails)
+func (c *SpamAssassinChecker) SupportedTypes() []InputType {
+	return []InputType{InputTypeEmail}
+}
+
+// Health comprueba que spamd responde a PING
+func (c *SpamAssassinChecker) Health(ctx context.Context) error {
+	if !c.enabled {
+		return errors.New("SPAMD_ADDRESS not configured")
+	}
+
+	resp, err := c.roundTrip(ctx, "PING SPAMC/1.5\r\n\r\n")
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(resp, "PONG") {
+		return fmt.Errorf("unexpected PING response: %q", firstLine(resp))
+	}
+	return nil
+}
+
+// Close no libera nada (una conexión por petición)
+func (c *SpamAssassinChecker) Close() error {
+	return nil
+}
+
+// Check envía el email a spamd. Un score por encima de spamAssassinThreshold es spam con
+// confianza score/spamAssassinMaxScore.
+func (c *SpamAssassinChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
+	startTime := time.Now()
+	result := &CheckResult{
+		Source:  c.Name(),
+		Found:   false,
+		RawData: make(map[string]interface{}),
+	}
+
+	if !c.enabled {
+		return result, errors.New("SPAMD_ADDRESS not configured")
+	}
+
+	email := strings.TrimSpace(indicators.Normalized)
+	if indicators.InputType != InputTypeEmail || email == "" {
+		return result, nil
+	}
+
+	message, full := spamAssassinMessage(email, AnalysisContextFrom(ctx), startTime)
+	verdict, err := c.CheckMessage(ctx, message)
+	result.Latency = time.Since(startTime)
+	if err != nil {
+		log.Warn().Err(err).Msg("[SpamAssassin] spamd check failed")
+		return result, err
+	}
+
+	result.RawData["score"] = verdict.Score
+	result.RawData["spamd_threshold"] = verdict.Threshold
+	result.RawData["spamd_is_spam"] = verdict.IsSpam
+	result.RawData["full_message"] = full
+
+	if verdict.Score <= spamAssassinThreshold {
+		return result, nil
+	}
+
+	result.Found = true
+	result.ThreatType = ThreatTypeSpam
+	result.Confidence = math.Min(verdict.Score/spamAssassinMaxScore, 1.0)
+	result.Tags = append(result.Tags, "spamassassin_spam")
+	result.RawData["reasons"] = []string{
+		fmt.Sprintf("SpamAssassin puntúa el email como spam (%.1f sobre un umbral de %.1f)", verdict.Score, spamAssassinThreshold),
+	}
+
+	log.Info().
+		Float64("score", verdict.Score).
+		Bool("full_message", full).
+		Msg("[SpamAssassin] Email scored as spam")
+
+	return result, nil
+}
+
+// CheckMessage envía message (cabeceras + cuerpo) con CHECK y devuelve el veredicto de spamd
+func (c *SpamAssassinChecker) CheckMessage(ctx context.Context, message string) (*SpamAssassinResult, error) {
+	request := fmt.Sprintf("CHECK SPAMC/1.5\r\nContent-length: %d\r\n\r\n%s", len(message), message)
+	resp, err := c.roundTrip(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	return parseSpamdResponse(resp)
+}
+
+// roundTrip abre una conexión, envía la petición y lee la respuesta hasta que spamd cierra
+func (c *SpamAssassinChecker) roundTrip(ctx context.Context, request string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	conn, err := c.dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("failed to connect to spamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := io.WriteString(conn, request); err != nil {
+		return "", fmt.Errorf("failed to send request: %w", err)
+	}
+
+	resp, err := io.ReadAll(io.LimitReader(conn, spamdMaxResponse))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+	return string(resp), nil
+}
+
+// parseSpamdResponse interpreta "SPAMD/1.1 0 EX_OK" + "Spam: True ; 15.3 / 5.0"
+func parseSpamdResponse(resp string) (*SpamAssassinResult, error) {
+	scanner := bufio.NewScanner(strings.NewReader(resp))
+	if !scanner.Scan() {
+		return nil, errors.New("empty spamd response")
+	}
+
+	status := strings.Fields(scanner.Text())
+	if len(status) < 2 || !strings.HasPrefix(status[0], "SPAMD/") {
+		return nil, fmt.Errorf("invalid spamd response: %q", scanner.Text())
+	}
+	if status[1] != "0" {
+		return nil, fmt.Errorf("spamd error: %s", strings.Join(status[1:], " "))
+	}
+
+	for scanner.Scan() {
+		match := spamdResultRe.FindStringSubmatch(strings.TrimSpace(scanner.Text()))
+		if match == nil {
+			continue
+		}
+		score, err := strconv.ParseFloat(match[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spamd score %q", match[2])
+		}
+		threshold, err := strconv.ParseFloat(match[3], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spamd threshold %q", match[3])
+		}
+		isSpam := strings.EqualFold(match[1], "true") || strings.EqualFold(match[1], "yes")
+		return &SpamAssassinResult{IsSpam: isSpam, Score: score, Threshold: threshold}, nil
+	}
+
+	return nil, errors.New("spamd response without Spam header")
+}
+
+// spamAssassinMessage mensaje a puntuar. Si el texto original del usuario ya es un email con
+// cabeceras se envía tal cual (full = true); si no, cabeceras mínimas con la dirección en From
+// y el texto (o nada) como cuerpo. Date, Message-ID y Subject van siempre: sin ellos reglas
+// como MISSING_DATE suman puntos por cómo se construyó el mensaje, no por el remitente.
+func spamAssassinMessage(email string, ac *AnalysisContext, now time.Time) (message string, full bool) {
+	body := ""
+	if ac != nil {
+		body = strings.TrimSpace(ac.OriginalText)
+	}
+	if rfc822HeaderRe.MatchString(body) && (strings.Contains(body, "\n\n") || strings.Contains(body, "\r\n\r\n")) {
+		return body + "\r\n", true
+	}
+
+	// La dirección viene del usuario: sin saltos de línea no puede añadir cabeceras
+	from := strings.NewReplacer("\r", "", "\n", "").Replace(email)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: <%s>\r\n", from)
+	b.WriteString("To: undisclosed-recipients:;\r\n")
+	b.WriteString("Subject: \r\n")
+	fmt.Fprintf(&b, "Date: %s\r\n", now.UTC().Format(time.RFC1123Z))
+	fmt.Fprintf(&b, "Message-ID: <%s@fy-analysis>\r\n", spamAssassinMessageID())
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(body)
+	b.WriteString("\r\n")
+	return b.String(), false
+}
+
+// spamAssassinMessageID parte local aleatoria del Message-ID del mensaje mínimo
+func spamAssassinMessageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// firstLine primera línea de una respuesta (para los errores)
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return strings.TrimSpace(line)
+}
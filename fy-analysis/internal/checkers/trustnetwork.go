@@ -0,0 +1,368 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+)
+
+const (
+	// Ejecución diaria a las 03:00 UTC (poco tráfico de reportes)
+	trustNetworkRunHour = 3
+	trustNetworkTimeout = 10 * time.Minute
+	// trustNetworkLookback reportes que se revisan para crear relaciones
+	trustNetworkLookback = 90 * 24 * time.Hour
+
+	// co_report: la misma URL reportada por dos usuarios con menos de coReportWindow de
+	// diferencia. Las URLs con más de coReportMaxReporters reportadores son campañas masivas
+	// que recibe mucha gente a la vez y no indican colusión.
+	coReportWindow       = time.Hour
+	coReportMaxReporters = 20
+	// shared_subnet: reportes desde la misma /24 (IPv4) o /48 (IPv6). Las subredes con más de
+	// sharedSubnetMaxUsers usuarios (CGNAT de operadoras, Wi-Fi públicas) no vinculan.
+	sharedSubnetMaxUsers = 5
+
+	// Solo las relaciones con confianza >= trustPropagationMinConfidence propagan: un único
+	// co-reporte (0.45) no basta, dos sí (0.60), igual que compartir IP exacta (0.60)
+	trustPropagationMinConfidence = 0.5
+
+	// guilt_by_association: -3 por cada cuenta vinculada con confianza baja por rechazos
+	guiltByAssociationPenalty = -3
+	lowTrustScore             = 20
+	lowTrustMinRejected       = 3
+
+	// bootstrap: +5 (una vez) a una cuenta nueva vinculada a un reportador muy confiable
+	trustBootstrapBonus   = 5
+	highTrustScore        = 80
+	highTrustMinConfirmed = 5
+	newReporterAge        = 7 * 24 * time.Hour
+
+	reasonGuiltByAssociation = "guilt_by_association"
+	reasonTrustBootstrap     = "bootstrap"
+)
+
+var (
+	trustNetworkAdjustments = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "trust_network_adjustments_total",
+		Help: "Ajustes de trust_score propagados por la red de confianza (guilt_by_association, bootstrap)",
+	}, []string{"reason"})
+
+	trustNetworkRelationships = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "trust_network_relationships",
+		Help: "Relaciones entre cuentas en user_relationships por tipo (co_report, shared_subnet)",
+	}, []string{"type"})
+)
+
+// TrustNetworkAnalyzer mantiene el grafo de cuentas vinculadas (user_relationships) y propaga
+// por él la confianza de los reportadores. Cada ejecución propaga un salto; como los ajustes
+// cambian trust_score, las noches siguientes alcanzan a los vecinos de los vecinos.
+type TrustNetworkAnalyzer struct {
+	db *sql.DB
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// TrustNetworkRun resultado de una ejecución
+type TrustNetworkRun struct {
+	CoReportLinks int64 `json:"co_report_links"`
+	SubnetLinks   int64 `json:"subnet_links"`
+	Penalties     int   `json:"penalties"`
+	Bootstraps    int   `json:"bootstraps"`
+}
+
+// NewTrustNetworkAnalyzer crea el analizador sobre la conexión de reportes
+func NewTrustNetworkAnalyzer(db *sql.DB) *TrustNetworkAnalyzer {
+	return &TrustNetworkAnalyzer{
+		db:     db,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Start ejecuta el análisis cada noche a las trustNetworkRunHour UTC
+func (a *TrustNetworkAnalyzer) Start(ctx context.Context) {
+	go func() {
+		for {
+			timer := time.NewTimer(time.Until(nextTrustNetworkRun(time.Now())))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-a.stopCh:
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+
+			runCtx, cancel := context.WithTimeout(ctx, trustNetworkTimeout)
+			if _, err := a.Run(runCtx); err != nil {
+				log.Warn().Err(err).Msg("[TrustNetwork] Run failed")
+			}
+			cancel()
+		}
+	}()
+}
+
+// Stop detiene la ejecución nocturna
+func (a *TrustNetworkAnalyzer) Stop() {
+	a.stopOnce.Do(func() { close(a.stopCh) })
+}
+
+// nextTrustNetworkRun próxima ejecución a las trustNetworkRunHour UTC posterior a now
+func nextTrustNetworkRun(now time.Time) time.Time {
+	now = now.UTC()
+	next := time.Date(now.Year(), now.Month(), now.Day(), trustNetworkRunHour, 0, 0, 0, time.UTC)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Run actualiza las relaciones con los reportes recientes y propaga la confianza:
+// primero las penalizaciones y después el bootstrap (que excluye a los penalizados)
+func (a *TrustNetworkAnalyzer) Run(ctx context.Context) (*TrustNetworkRun, error) {
+	start := time.Now()
+	run := &TrustNetworkRun{}
+
+	var err error
+	if run.CoReportLinks, err = a.linkCoReports(ctx); err != nil {
+		return nil, fmt.Errorf("co_report links: %w", err)
+	}
+	if run.SubnetLinks, err = a.linkSharedSubnets(ctx); err != nil {
+		return nil, fmt.Errorf("shared_subnet links: %w", err)
+	}
+	if run.Penalties, err = a.propagate(ctx, reasonGuiltByAssociation, guiltByAssociationPenalty, `
+		SELECT e.target, e.source
+		FROM trust_edges e
+		JOIN user_trust_scores src ON src.user_id = e.source
+		JOIN user_trust_scores dst ON dst.user_id = e.target
+		WHERE src.trust_score < $2 AND src.rejected_reports >= $3
+		  AND NOT (`+flags.Banned.IsSet("dst.flags")+` AND dst.trust_score < 10)
+		  AND NOT EXISTS (
+			SELECT 1 FROM trust_network_adjustments adj
+			WHERE adj.user_id = e.target AND adj.linked_user_id = e.source AND adj.reason = 'guilt_by_association'
+		  )
+		ORDER BY e.target, e.source
+	`, lowTrustScore, lowTrustMinRejected); err != nil {
+		return nil, fmt.Errorf("guilt_by_association: %w", err)
+	}
+	if run.Bootstraps, err = a.propagate(ctx, reasonTrustBootstrap, trustBootstrapBonus, `
+		SELECT e.target, MIN(e.source)
+		FROM trust_edges e
+		JOIN user_trust_scores src ON src.user_id = e.source
+		JOIN user_trust_scores dst ON dst.user_id = e.target
+		WHERE src.trust_score >= $2 AND src.confirmed_reports >= $3
+		  AND `+flags.Banned.IsClear("src.flags")+`
+		  AND dst.created_at >= NOW() - $4::INTERVAL AND dst.rejected_reports = 0
+		  AND `+flags.Banned.IsClear("dst.flags")+`
+		  AND NOT EXISTS (SELECT 1 FROM trust_network_adjustments adj WHERE adj.user_id = e.target)
+		GROUP BY e.target
+		ORDER BY e.target
+	`, highTrustScore, highTrustMinConfirmed, fmt.Sprintf("%d seconds", int(newReporterAge.Seconds()))); err != nil {
+		return nil, fmt.Errorf("bootstrap: %w", err)
+	}
+
+	a.refreshRelationshipGauge(ctx)
+
+	log.Info().
+		Int64("co_report_links", run.CoReportLinks).
+		Int64("subnet_links", run.SubnetLinks).
+		Int("penalties", run.Penalties).
+		Int("bootstraps", run.Bootstraps).
+		Dur("duration", time.Since(start)).
+		Msg("[TrustNetwork] Run completed")
+	return run, nil
+}
+
+// linkCoReports vincula a los usuarios que reportaron la misma URL con menos de
+// coReportWindow de diferencia. La confianza sube con cada URL compartida (0.45, 0.60...).
+func (a *TrustNetworkAnalyzer) linkCoReports(ctx context.Context) (int64, error) {
+	result, err := a.db.ExecContext(ctx, `
+		WITH pairs AS (
+			SELECT r1.user_id AS user_id_a, r2.user_id AS user_id_b,
+			       COUNT(DISTINCT r1.url_hash) AS shared,
+			       MIN(LEAST(r1.created_at, r2.created_at)) AS first_seen,
+			       MAX(GREATEST(r1.created_at, r2.created_at)) AS last_seen
+			FROM user_url_reports r1
+			JOIN reported_urls ru ON ru.url_hash = r1.url_hash AND ru.unique_reporters <= $3
+			JOIN user_url_reports r2 ON r2.url_hash = r1.url_hash AND r1.user_id < r2.user_id
+			 AND r2.created_at BETWEEN r1.created_at - $2::INTERVAL AND r1.created_at + $2::INTERVAL
+			WHERE r1.created_at >= NOW() - $1::INTERVAL
+			GROUP BY r1.user_id, r2.user_id
+		)
+		INSERT INTO user_relationships (user_id_a, user_id_b, relationship_type, confidence, evidence_count, first_seen_at, last_seen_at)
+		SELECT user_id_a, user_id_b, 'co_report', LEAST(0.30 + 0.15 * shared, 0.95), shared, first_seen, last_seen
+		FROM pairs
+		ON CONFLICT (user_id_a, user_id_b, relationship_type) DO UPDATE SET
+			confidence = GREATEST(user_relationships.confidence, EXCLUDED.confidence),
+			evidence_count = GREATEST(user_relationships.evidence_count, EXCLUDED.evidence_count),
+			last_seen_at = GREATEST(user_relationships.last_seen_at, EXCLUDED.last_seen_at),
+			updated_at = NOW()
+	`, fmt.Sprintf("%d seconds", int(trustNetworkLookback.Seconds())),
+		fmt.Sprintf("%d seconds", int(coReportWindow.Seconds())), coReportMaxReporters)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// linkSharedSubnets vincula a los usuarios que han reportado desde la misma subred
+// (indicio de dispositivo o red compartidos): 0.60 con la misma IP, 0.40 solo la subred
+func (a *TrustNetworkAnalyzer) linkSharedSubnets(ctx context.Context) (int64, error) {
+	result, err := a.db.ExecContext(ctx, `
+		WITH user_ips AS (
+			SELECT DISTINCT user_id, host(user_ip) AS ip,
+			       network(set_masklen(user_ip, CASE family(user_ip) WHEN 4 THEN 24 ELSE 48 END)) AS subnet,
+			       MIN(created_at) OVER (PARTITION BY user_id, user_ip) AS first_seen,
+			       MAX(created_at) OVER (PARTITION BY user_id, user_ip) AS last_seen
+			FROM user_url_reports
+			WHERE user_ip IS NOT NULL AND created_at >= NOW() - $1::INTERVAL
+		),
+		small_subnets AS (
+			SELECT subnet FROM user_ips
+			GROUP BY subnet
+			HAVING COUNT(DISTINCT user_id) BETWEEN 2 AND $2
+		),
+		pairs AS (
+			SELECT u1.user_id AS user_id_a, u2.user_id AS user_id_b,
+			       MAX(CASE WHEN u1.ip = u2.ip THEN 0.60 ELSE 0.40 END) AS confidence,
+			       COUNT(DISTINCT u1.subnet) AS subnets,
+			       MIN(GREATEST(u1.first_seen, u2.first_seen)) AS first_seen,
+			       MAX(GREATEST(u1.last_seen, u2.last_seen)) AS last_seen
+			FROM user_ips u1
+			JOIN small_subnets s ON s.subnet = u1.subnet
+			JOIN user_ips u2 ON u2.subnet = u1.subnet AND u1.user_id < u2.user_id
+			GROUP BY u1.user_id, u2.user_id
+		)
+		INSERT INTO user_relationships (user_id_a, user_id_b, relationship_type, confidence, evidence_count, first_seen_at, last_seen_at)
+		SELECT user_id_a, user_id_b, 'shared_subnet', confidence, subnets, first_seen, last_seen
+		FROM pairs
+		ON CONFLICT (user_id_a, user_id_b, relationship_type) DO UPDATE SET
+			confidence = GREATEST(user_relationships.confidence, EXCLUDED.confidence),
+			evidence_count = GREATEST(user_relationships.evidence_count, EXCLUDED.evidence_count),
+			last_seen_at = GREATEST(user_relationships.last_seen_at, EXCLUDED.last_seen_at),
+			updated_at = NOW()
+	`, fmt.Sprintf("%d seconds", int(trustNetworkLookback.Seconds())), sharedSubnetMaxUsers)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// propagate aplica delta a cada par (target, source) que devuelve candidates. La consulta
+// ve la CTE trust_edges (aristas en ambos sentidos con confianza >= $1). Retorna los ajustes
+// aplicados.
+func (a *TrustNetworkAnalyzer) propagate(ctx context.Context, reason string, delta int, candidates string, args ...interface{}) (int, error) {
+	query := `
+		WITH trust_edges AS (
+			SELECT user_id_a AS target, user_id_b AS source FROM user_relationships WHERE confidence >= $1
+			UNION
+			SELECT user_id_b, user_id_a FROM user_relationships WHERE confidence >= $1
+		)
+	` + candidates
+	rows, err := a.db.QueryContext(ctx, query, append([]interface{}{trustPropagationMinConfidence}, args...)...)
+	if err != nil {
+		return 0, err
+	}
+
+	type pair struct{ target, source string }
+	var pairs []pair
+	for rows.Next() {
+		var p pair
+		if err := rows.Scan(&p.target, &p.source); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		pairs = append(pairs, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	applied := 0
+	for _, p := range pairs {
+		ok, err := a.applyAdjustment(ctx, p.target, p.source, reason, delta)
+		if err != nil {
+			return applied, err
+		}
+		if ok {
+			applied++
+			trustNetworkAdjustments.WithLabelValues(reason).Inc()
+			log.Info().
+				Str("user_id", p.target).
+				Str("linked_user_id", p.source).
+				Str("reason", reason).
+				Int("adjustment", delta).
+				Msg("[TrustNetwork] Trust score adjusted")
+		}
+	}
+	return applied, nil
+}
+
+// applyAdjustment registra el ajuste y actualiza trust_score (0-100) en una transacción.
+// false si ya se había aplicado (la restricción única de trust_network_adjustments).
+func (a *TrustNetworkAnalyzer) applyAdjustment(ctx context.Context, userID, linkedUserID, reason string, delta int) (bool, error) {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var before int
+	if err := tx.QueryRowContext(ctx, `
+		SELECT trust_score FROM user_trust_scores WHERE user_id = $1 FOR UPDATE
+	`, userID).Scan(&before); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+	after := min(max(before+delta, 0), 100)
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO trust_network_adjustments (user_id, linked_user_id, reason, adjustment, trust_before, trust_after)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT DO NOTHING
+	`, userID, linkedUserID, reason, delta, before, after)
+	if err != nil {
+		return false, err
+	}
+	if inserted, _ := result.RowsAffected(); inserted == 0 {
+		return false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_trust_scores SET trust_score = $2, updated_at = NOW() WHERE user_id = $1
+	`, userID, after); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// refreshRelationshipGauge actualiza trust_network_relationships con el tamaño del grafo
+func (a *TrustNetworkAnalyzer) refreshRelationshipGauge(ctx context.Context) {
+	rows, err := a.db.QueryContext(ctx, `
+		SELECT relationship_type, COUNT(*) FROM user_relationships GROUP BY relationship_type
+	`)
+	if err != nil {
+		log.Debug().Err(err).Msg("[TrustNetwork] Failed to count relationships")
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var relType string
+		var count int64
+		if rows.Scan(&relType, &count) == nil {
+			trustNetworkRelationships.WithLabelValues(relType).Set(float64(count))
+		}
+	}
+}
@@ -1,12 +1,10 @@
 package checkers
 
 import (
-	"bufio"
 	"context"
 	"encoding/csv"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"strings"
 	"sync"
@@ -25,6 +23,9 @@ type URLhausChecker struct {
 	mu          sync.RWMutex
 	lastUpdate  time.Time
 	downloadURL string
+	downloader  Downloader
+	// Resultado del último intento de descarga
+	lastDownload DownloadOutcome
 }
 
 // URLhausEntry representa una entrada en la DB de URLhaus
@@ -39,8 +40,11 @@ type URLhausEntry struct {
 	Reporter    string
 }
 
-// NewURLhausChecker crea un nuevo checker de URLhaus
-func NewURLhausChecker(dbPath string) *URLhausChecker {
+// NewURLhausChecker crea un nuevo checker de URLhaus (clients nil = factoría por defecto)
+func NewURLhausChecker(dbPath string, clients *CheckerHTTPClientFactory) *URLhausChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
 	checker := &URLhausChecker{
 		enabled:     true,
 		weight:      0.40,
@@ -49,6 +53,7 @@ func NewURLhausChecker(dbPath string) *URLhausChecker {
 		domainDB:    make(map[string]*URLhausEntry),
 		downloadURL: "https://urlhaus.abuse.ch/downloads/csv/",
 	}
+	checker.downloader = &HTTPDownloader{Client: clients.Client(60 * time.Second), URL: checker.downloadURL}
 
 	// Intentar cargar DB existente
 	if err := checker.LoadDB(); err != nil {
@@ -58,6 +63,15 @@ func NewURLhausChecker(dbPath string) *URLhausChecker {
 	return checker
 }
 
+func init() {
+	Registry.Register("urlhaus", newURLhausFromConfig)
+}
+
+// newURLhausFromConfig factory del registro: URLhaus siempre está disponible (DB local)
+func newURLhausFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	return NewURLhausChecker(cfg.URLhausDBPath, cfg.checkerHTTPClients()), nil
+}
+
 // Name retorna el nombre del checker
 func (c *URLhausChecker) Name() string {
 	return "urlhaus"
@@ -78,6 +92,21 @@ func (c *URLhausChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL}
 }
 
+// Health falla si la base local no tiene entradas cargadas
+func (c *URLhausChecker) Health(ctx context.Context) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if len(c.urlDB) == 0 {
+		return fmt.Errorf("URLhaus database is empty")
+	}
+	return nil
+}
+
+// Close no libera nada (la base está en memoria)
+func (c *URLhausChecker) Close() error {
+	return nil
+}
+
 // Check verifica los indicadores contra URLhaus
 func (c *URLhausChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
 	c.mu.RLock()
@@ -177,78 +206,116 @@ func (c *URLhausChecker) LoadDB() error {
 	return c.parseCSV(file)
 }
 
-// DownloadDB descarga la base de datos actualizada
+// DownloadDB descarga la base de datos actualizada.
+// Si la descarga no es válida se conserva el archivo y el índice anteriores.
 func (c *URLhausChecker) DownloadDB(ctx context.Context) error {
-	log.Info().Str("url", c.downloadURL).Msg("[URLhaus] Downloading database...")
-
-	req, err := http.NewRequestWithContext(ctx, "GET", c.downloadURL, nil)
+	body, err := c.fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		c.recordDownload(DownloadOutcome{At: time.Now(), Error: err.Error(), PreviousRows: c.rowCount()})
+		return err
 	}
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
+	defer body.Close()
+
+	var urlDB, domainDB map[string]*URLhausEntry
+	outcome := replaceDBFile(body, c.dbPath, c.rowCount(), func(r io.Reader) (int, error) {
+		urlDB, domainDB = c.readCSV(r)
+		return len(urlDB), nil
+	})
+
+	if !outcome.Success {
+		c.recordDownload(outcome)
+		log.Warn().
+			Str("error", outcome.Error).
+			Int("rows", outcome.Rows).
+			Int("previous_rows", outcome.PreviousRows).
+			Msg("[URLhaus] Download rejected, keeping previous database")
+		return fmt.Errorf("urlhaus download rejected: %s", outcome.Error)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
-	}
+	log.Info().Int64("bytes", outcome.Bytes).Msg("[URLhaus] Database downloaded")
 
-	// Guardar a archivo
-	file, err := os.Create(c.dbPath)
-	if err != nil {
-		return fmt.Errorf("failed to create DB file: %w", err)
-	}
-	defer file.Close()
+	c.mu.Lock()
+	c.urlDB = urlDB
+	c.domainDB = domainDB
+	c.lastUpdate = time.Now()
+	c.lastDownload = outcome
+	c.mu.Unlock()
 
-	written, err := io.Copy(file, resp.Body)
+	log.Info().
+		Int("urls", len(urlDB)).
+		Int("domains", len(domainDB)).
+		Int("row_delta", outcome.Delta()).
+		Msg("[URLhaus] Database loaded")
+
+	return nil
+}
+
+// rowCount retorna el número de URLs cargadas actualmente
+func (c *URLhausChecker) rowCount() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.urlDB)
+}
+
+// recordDownload guarda el resultado del último intento de descarga
+func (c *URLhausChecker) recordDownload(outcome DownloadOutcome) {
+	c.mu.Lock()
+	c.lastDownload = outcome
+	c.mu.Unlock()
+}
+
+// FetchEntries descarga y parsea el feed sin tocar el archivo local ni el índice en memoria
+func (c *URLhausChecker) FetchEntries(ctx context.Context) ([]*URLhausEntry, error) {
+	body, err := c.fetch(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to write DB file: %w", err)
+		return nil, err
 	}
+	defer body.Close()
 
-	log.Info().Int64("bytes", written).Msg("[URLhaus] Database downloaded")
+	urlDB, _ := c.readCSV(body)
 
-	// Recargar DB
-	file.Seek(0, 0)
+	entries := make([]*URLhausEntry, 0, len(urlDB))
+	for _, entry := range urlDB {
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
 
-	c.mu.Lock()
-	defer c.mu.Unlock()
+// Entries retorna una copia de las entradas cargadas actualmente en memoria
+func (c *URLhausChecker) Entries() []*URLhausEntry {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
 
-	if err := c.parseCSV(file); err != nil {
-		return fmt.Errorf("failed to parse downloaded DB: %w", err)
+	entries := make([]*URLhausEntry, 0, len(c.urlDB))
+	for _, entry := range c.urlDB {
+		entries = append(entries, entry)
 	}
+	return entries
+}
 
-	c.lastUpdate = time.Now()
-	log.Info().
-		Int("urls", len(c.urlDB)).
-		Int("domains", len(c.domainDB)).
-		Msg("[URLhaus] Database loaded")
+// fetch realiza la descarga del CSV y retorna el body de la respuesta
+func (c *URLhausChecker) fetch(ctx context.Context) (io.ReadCloser, error) {
+	log.Info().Str("url", c.downloadURL).Msg("[URLhaus] Downloading database...")
 
-	return nil
+	return c.downloader.Download(ctx)
 }
 
-// parseCSV parsea el archivo CSV de URLhaus
+// parseCSV parsea el archivo CSV de URLhaus y reemplaza el índice en memoria
 func (c *URLhausChecker) parseCSV(reader io.Reader) error {
-	// Limpiar DBs existentes
-	c.urlDB = make(map[string]*URLhausEntry)
-	c.domainDB = make(map[string]*URLhausEntry)
+	c.urlDB, c.domainDB = c.readCSV(reader)
+	return nil
+}
 
-	scanner := bufio.NewScanner(reader)
+// readCSV parsea el CSV de URLhaus en índices por URL y por dominio
+func (c *URLhausChecker) readCSV(reader io.Reader) (map[string]*URLhausEntry, map[string]*URLhausEntry) {
+	urlDB := make(map[string]*URLhausEntry)
+	domainDB := make(map[string]*URLhausEntry)
 
-	// Saltear comentarios iniciales (líneas que empiezan con #)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if !strings.HasPrefix(line, "#") {
-			// Primera línea de datos
-			break
-		}
-	}
-
-	// Crear reader CSV desde la posición actual
+	// Los comentarios (líneas que empiezan con #) los descarta el propio reader CSV.
+	// Antes se saltaban con un bufio.Scanner, que consumía un bloque del reader
+	// y hacía que se perdieran las primeras filas de datos.
 	csvReader := csv.NewReader(reader)
+	csvReader.Comment = '#'
 	csvReader.FieldsPerRecord = -1 // Número variable de campos
 	csvReader.LazyQuotes = true
 
@@ -281,19 +348,19 @@ func (c *URLhausChecker) parseCSV(reader io.Reader) error {
 		}
 
 		// Indexar por URL
-		c.urlDB[entry.URL] = entry
+		urlDB[entry.URL] = entry
 
 		// Extraer e indexar por dominio
 		domain := c.extractDomain(entry.URL)
 		if domain != "" {
-			c.domainDB[domain] = entry
+			domainDB[domain] = entry
 		}
 
 		count++
 	}
 
 	log.Debug().Int("entries", count).Msg("[URLhaus] CSV parsed")
-	return nil
+	return urlDB, domainDB
 }
 
 // parseTags parsea los tags del formato URLhaus
@@ -369,8 +436,9 @@ func (c *URLhausChecker) GetStats() map[string]interface{} {
 	defer c.mu.RUnlock()
 
 	return map[string]interface{}{
-		"urls":        len(c.urlDB),
-		"domains":     len(c.domainDB),
-		"last_update": c.lastUpdate,
+		"urls":          len(c.urlDB),
+		"domains":       len(c.domainDB),
+		"last_update":   c.lastUpdate,
+		"last_download": c.lastDownload.ToMap(),
 	}
 }
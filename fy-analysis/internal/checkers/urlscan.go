@@ -1,12 +1,15 @@
 package checkers
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -20,6 +23,17 @@ type URLScanChecker struct {
 	apiKey     string
 	httpClient *http.Client
 	baseURL    string
+
+	// Envío de URLs no vistas (EnableSubmissions): nil = solo se buscan scans previos
+	db     *sql.DB
+	poller *ResultPoller
+}
+
+// URLScanSubmitResponse respuesta de POST /scan/
+type URLScanSubmitResponse struct {
+	UUID    string `json:"uuid"`
+	Message string `json:"message"`
+	Result  string `json:"result"`
 }
 
 // URLScanSearchResponse respuesta de búsqueda
@@ -75,16 +89,17 @@ type URLScanVerdict struct {
 	Categories []string `json:"categories,omitempty"`
 }
 
-// NewURLScanChecker crea un nuevo checker de URLScan.io
-func NewURLScanChecker(apiKey string) *URLScanChecker {
+// NewURLScanChecker crea un nuevo checker de URLScan.io (clients nil = factoría por defecto)
+func NewURLScanChecker(apiKey string, clients *CheckerHTTPClientFactory) *URLScanChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
 	checker := &URLScanChecker{
-		enabled: apiKey != "",
-		weight:  0.10,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 10 * time.Second,
-		},
-		baseURL: "https://urlscan.io/api/v1",
+		enabled:    apiKey != "",
+		weight:     0.10,
+		apiKey:     apiKey,
+		httpClient: clients.Client(10 * time.Second),
+		baseURL:    "https://urlscan.io/api/v1",
 	}
 
 	if checker.enabled {
@@ -96,6 +111,40 @@ func NewURLScanChecker(apiKey string) *URLScanChecker {
 	return checker
 }
 
+func init() {
+	Registry.Register("urlscan", newURLScanFromConfig)
+}
+
+// newURLScanFromConfig factory del registro (requiere URLSCAN_KEY; el envío de URLs
+// nuevas requiere además ENABLE_URLSCAN_SUBMIT y LocalDB)
+func newURLScanFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if cfg.URLScanKey == "" {
+		return nil, nil
+	}
+	checker := NewURLScanChecker(cfg.URLScanKey, cfg.checkerHTTPClients())
+	if cfg.EnableURLScanSubmit {
+		if localDB := cfg.sharedLocalDB(); localDB != nil && localDB.GetDB() != nil {
+			checker.EnableSubmissions(localDB.GetDB())
+		} else {
+			log.Warn().Msg("[URLScan] Submissions require LocalDB, only searching previous scans")
+		}
+	}
+	return checker, nil
+}
+
+// EnableSubmissions envía a urlscan.io las URLs sin scans previos y guarda los envíos en
+// pending_scans, que ResultPoller resuelve en background
+func (c *URLScanChecker) EnableSubmissions(db *sql.DB) {
+	c.db = db
+	c.poller = NewResultPoller(c, db)
+	log.Info().Msg("[URLScan] Submission of unseen URLs enabled")
+}
+
+// Poller resolución de los envíos pendientes (nil si los envíos no están habilitados)
+func (c *URLScanChecker) Poller() *ResultPoller {
+	return c.poller
+}
+
 // Name retorna el nombre del checker
 func (c *URLScanChecker) Name() string {
 	return "urlscan"
@@ -116,6 +165,25 @@ func (c *URLScanChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL}
 }
 
+// NeedsExternalCall implementa ExternalAPIChecker: urlscan.io no se cachea: toda URL consulta la API
+func (c *URLScanChecker) NeedsExternalCall(inputType InputType, value string) bool {
+	return inputType == InputTypeURL
+}
+
+// Health falla si no hay API key (no se consume cuota para comprobar la API)
+func (c *URLScanChecker) Health(ctx context.Context) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("URLScan API key not configured")
+	}
+	return nil
+}
+
+// Close cierra las conexiones HTTP inactivas
+func (c *URLScanChecker) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
 // Check verifica los indicadores contra URLScan.io
 // Usa la API de búsqueda para encontrar scans previos de la URL/dominio
 func (c *URLScanChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
@@ -134,6 +202,30 @@ func (c *URLScanChecker) Check(ctx context.Context, indicators *Indicators) (*Ch
 		Str("domain", indicators.Domain).
 		Msg("[URLScan] Checking URL")
 
+	// Envío previo de la misma URL: veredicto cacheado o scan aún en curso
+	var pending *pendingScan
+	if c.db != nil {
+		var err error
+		pending, err = c.pendingScan(ctx, indicators.FullURL)
+		if err != nil {
+			log.Debug().Err(err).Msg("[URLScan] Pending scan lookup failed")
+		}
+		if pending != nil && !pending.resolved {
+			result.RawData["scan_pending"] = true
+			result.RawData["scan_uuid"] = pending.uuid
+			return result, nil
+		}
+		if pending != nil && pending.malicious {
+			result.Found = true
+			result.ThreatType = pending.threatType
+			result.Confidence = pending.confidence
+			result.RawData["scan_uuid"] = pending.uuid
+			result.RawData["scan_url"] = "https://urlscan.io/result/" + pending.uuid
+			result.RawData["scan_cached"] = true
+			return result, nil
+		}
+	}
+
 	// Buscar por dominio (más probable encontrar resultados)
 	searchResult, err := c.searchDomain(ctx, indicators.Domain)
 	if err != nil {
@@ -145,6 +237,17 @@ func (c *URLScanChecker) Check(ctx context.Context, indicators *Indicators) (*Ch
 		log.Debug().
 			Str("domain", indicators.Domain).
 			Msg("[URLScan] No previous scans found for domain")
+
+		// URL nunca vista: se envía a escanear y el veredicto llega por ResultPoller
+		if c.db != nil && pending == nil {
+			uuid, err := c.SubmitScan(ctx, indicators.FullURL)
+			if err != nil {
+				log.Warn().Err(err).Msg("[URLScan] Submit failed")
+				return result, nil
+			}
+			result.RawData["scan_pending"] = true
+			result.RawData["scan_uuid"] = uuid
+		}
 		return result, nil
 	}
 
@@ -306,15 +409,69 @@ func (c *URLScanChecker) extractTags(scan URLScanResult) []string {
 	return tags
 }
 
-// SubmitScan envía una URL para escaneo (opcional, para URLs no encontradas)
+// SubmitScan envía una URL para escaneo (visibilidad unlisted) y retorna el UUID del scan.
+// Con los envíos habilitados lo guarda en pending_scans para que ResultPoller lo resuelva.
 func (c *URLScanChecker) SubmitScan(ctx context.Context, targetURL string) (string, error) {
 	if !c.enabled {
 		return "", fmt.Errorf("URLScan API key not configured")
 	}
 
-	// Esta función es para uso futuro - submite una URL para escaneo
-	// POST /api/v1/scan/
-	// Por ahora solo buscamos scans existentes
+	payload, err := json.Marshal(map[string]string{
+		"url":        targetURL,
+		"visibility": "unlisted",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/scan/", bytes.NewReader(payload))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("API-Key", c.apiKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	var submitResp URLScanSubmitResponse
+	if err := json.Unmarshal(body, &submitResp); err != nil {
+		return "", fmt.Errorf("failed to parse response: %w", err)
+	}
+	if submitResp.UUID == "" {
+		return "", fmt.Errorf("API returned no scan UUID")
+	}
+
+	if c.db != nil {
+		domain := ""
+		if parsed, err := url.Parse(targetURL); err == nil {
+			domain = strings.ToLower(parsed.Hostname())
+		}
+		if _, err := c.db.ExecContext(ctx, `
+			INSERT INTO pending_scans (uuid, url, url_hash, domain, submitted_at, resolved)
+			VALUES ($1, $2, sha256_bytea($2), $3, NOW(), false)
+			ON CONFLICT (uuid) DO NOTHING
+		`, submitResp.UUID, targetURL, domain); err != nil {
+			log.Warn().Err(err).Str("uuid", submitResp.UUID).Msg("[URLScan] Failed to store pending scan")
+		}
+	}
+	urlscanSubmissionsTotal.WithLabelValues("submitted").Inc()
+
+	log.Info().
+		Str("uuid", submitResp.UUID).
+		Msg("[URLScan] URL submitted for scanning")
 
-	return "", fmt.Errorf("submit scan not implemented")
+	return submitResp.UUID, nil
 }
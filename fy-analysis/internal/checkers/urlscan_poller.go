@@ -0,0 +1,272 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	urlscanPollInterval = 2 * time.Minute
+	urlscanPollTimeout  = time.Minute
+	urlscanPollBatch    = 50
+	// Un scan sin resultado tras urlscanPendingMaxAge se da por perdido (urlscan.io falló o lo borró)
+	urlscanPendingMaxAge = 24 * time.Hour
+)
+
+var urlscanSubmissionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "urlscan_submissions_total",
+	Help: "URLs enviadas a urlscan.io (submitted) y cómo se resolvieron (malicious, clean, expired)",
+}, []string{"event"})
+
+// pendingScan envío a urlscan.io guardado en pending_scans
+type pendingScan struct {
+	uuid       string
+	url        string
+	domain     string
+	resolved   bool
+	malicious  bool
+	threatType string
+	confidence float64
+}
+
+// pendingScan último envío de la URL (nil si nunca se envió)
+func (c *URLScanChecker) pendingScan(ctx context.Context, rawURL string) (*pendingScan, error) {
+	var p pendingScan
+	var threatType sql.NullString
+	var confidence sql.NullFloat64
+	err := c.db.QueryRowContext(ctx, `
+		SELECT uuid, resolved, COALESCE(malicious, false), threat_type, confidence
+		FROM pending_scans
+		WHERE url_hash = sha256_bytea($1)
+		ORDER BY submitted_at DESC
+		LIMIT 1
+	`, rawURL).Scan(&p.uuid, &p.resolved, &p.malicious, &threatType, &confidence)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.threatType = threatType.String
+	p.confidence = confidence.Float64
+	return &p, nil
+}
+
+// fetchResult resultado de un scan; ready=false mientras urlscan.io no lo ha terminado (404)
+func (c *URLScanChecker) fetchResult(ctx context.Context, uuid string) (*URLScanResult, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", c.baseURL+"/result/"+uuid+"/", nil)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("API-Key", c.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, false, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, fmt.Errorf("API returned status %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var result URLScanResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return &result, true, nil
+}
+
+// ResultPoller resuelve los envíos de pending_scans: consulta su resultado cada 2 minutos y
+// añade a threat_domains los dominios con veredicto malicioso
+type ResultPoller struct {
+	checker *URLScanChecker
+	db      *sql.DB
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewResultPoller crea el poller de los envíos del checker
+func NewResultPoller(checker *URLScanChecker, db *sql.DB) *ResultPoller {
+	return &ResultPoller{
+		checker: checker,
+		db:      db,
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Start revisa los envíos pendientes cada urlscanPollInterval
+func (p *ResultPoller) Start(ctx context.Context) {
+	log.Info().Dur("interval", urlscanPollInterval).Msg("[URLScan] Starting result poller")
+
+	go func() {
+		ticker := time.NewTicker(urlscanPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+			}
+
+			runCtx, cancel := context.WithTimeout(ctx, urlscanPollTimeout)
+			if err := p.Run(runCtx); err != nil {
+				log.Warn().Err(err).Msg("[URLScan] Result poll failed")
+			}
+			cancel()
+		}
+	}()
+}
+
+// Stop detiene la revisión periódica
+func (p *ResultPoller) Stop() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
+
+// Run da por perdidos los envíos demasiado antiguos y resuelve los que ya tienen resultado
+func (p *ResultPoller) Run(ctx context.Context) error {
+	expired, err := p.db.ExecContext(ctx, `
+		UPDATE pending_scans SET resolved = true, resolved_at = NOW()
+		WHERE resolved = false AND submitted_at < NOW() - $1::interval
+	`, fmt.Sprintf("%d seconds", int(urlscanPendingMaxAge.Seconds())))
+	if err != nil {
+		return fmt.Errorf("failed to expire pending scans: %w", err)
+	}
+	if n, _ := expired.RowsAffected(); n > 0 {
+		urlscanSubmissionsTotal.WithLabelValues("expired").Add(float64(n))
+		log.Info().Int64("scans", n).Msg("[URLScan] Pending scans expired without result")
+	}
+
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT uuid, url, COALESCE(domain, '')
+		FROM pending_scans
+		WHERE resolved = false
+		ORDER BY submitted_at
+		LIMIT $1
+	`, urlscanPollBatch)
+	if err != nil {
+		return err
+	}
+	var pending []pendingScan
+	for rows.Next() {
+		var s pendingScan
+		if err := rows.Scan(&s.uuid, &s.url, &s.domain); err != nil {
+			rows.Close()
+			return err
+		}
+		pending = append(pending, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range pending {
+		if err := p.resolve(ctx, &pending[i]); err != nil {
+			log.Warn().Err(err).Str("uuid", pending[i].uuid).Msg("[URLScan] Failed to resolve pending scan")
+		}
+	}
+	return nil
+}
+
+// resolve consulta el resultado de un envío y, si ya está, guarda el veredicto
+func (p *ResultPoller) resolve(ctx context.Context, scan *pendingScan) error {
+	result, ready, err := p.checker.fetchResult(ctx, scan.uuid)
+	if err != nil || !ready {
+		return err
+	}
+
+	scan.malicious = result.Verdicts.Overall.Malicious
+	if scan.malicious {
+		scan.threatType = p.checker.determineThreatType(*result)
+		scan.confidence = p.checker.calculateConfidence(*result)
+	}
+
+	if _, err := p.db.ExecContext(ctx, `
+		UPDATE pending_scans SET
+			resolved = true,
+			malicious = $2,
+			threat_type = NULLIF($3, ''),
+			confidence = $4,
+			resolved_at = NOW()
+		WHERE uuid = $1
+	`, scan.uuid, scan.malicious, scan.threatType, scan.confidence); err != nil {
+		return err
+	}
+
+	if !scan.malicious {
+		urlscanSubmissionsTotal.WithLabelValues("clean").Inc()
+		log.Debug().Str("uuid", scan.uuid).Msg("[URLScan] Pending scan resolved as clean")
+		return nil
+	}
+	urlscanSubmissionsTotal.WithLabelValues("malicious").Inc()
+
+	added, err := p.addThreatDomain(ctx, scan)
+	if err != nil {
+		return fmt.Errorf("failed to add threat domain: %w", err)
+	}
+
+	log.Info().
+		Str("uuid", scan.uuid).
+		Str("domain", scan.domain).
+		Str("threat_type", scan.threatType).
+		Float64("confidence", scan.confidence).
+		Bool("added_to_threats", added).
+		Msg("[URLScan] Pending scan resolved as MALICIOUS")
+	return nil
+}
+
+// addThreatDomain añade el dominio del scan a threat_domains (source urlscan).
+// Retorna false si el dominio está en la whitelist o la URL no tiene dominio.
+func (p *ResultPoller) addThreatDomain(ctx context.Context, scan *pendingScan) (bool, error) {
+	if scan.domain == "" {
+		return false, nil
+	}
+
+	// Una URL maliciosa alojada en un dominio legítimo no bloquea el dominio entero
+	var whitelisted bool
+	if err := p.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM whitelist_domains WHERE domain_hash = sha256_bytea($1))
+	`, scan.domain).Scan(&whitelisted); err != nil {
+		return false, err
+	}
+	if whitelisted {
+		return false, nil
+	}
+
+	threatType := scan.threatType
+	if threatType != ThreatTypePhishing && threatType != ThreatTypeMalware && threatType != ThreatTypeSpam {
+		threatType = "other"
+	}
+	severity := "medium"
+	if scan.confidence >= 0.85 {
+		severity = "high"
+	}
+
+	_, err := p.db.ExecContext(ctx, `
+		SELECT upsert_threat_domain($1, $2::threat_type_enum, $3::severity_enum, $4::SMALLINT, 'urlscan'::source_enum)
+	`, scan.domain, threatType, severity, int(scan.confidence*100))
+	return err == nil, err
+}
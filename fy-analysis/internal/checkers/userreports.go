@@ -3,42 +3,73 @@ package checkers
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+	"github.com/trackfy/pkg/dbutil"
 )
 
 // UserReportsChecker verifica URLs contra reportes de usuarios
 // Usa el sistema de confianza agregada anti-spam
 type UserReportsChecker struct {
-	db      *sql.DB
-	enabled bool
-	weight  float64
+	db     *sql.DB
+	conn   *dbConnState // nil si no hay DB
+	ownsDB bool         // La conexión se abrió aquí (sin LocalDB) y la cierra Close
+	weight float64
 
 	// Configuración de umbrales
 	minScoreForWarning int // Score mínimo para considerar como warning (default: 40)
 	minScoreForDanger  int // Score mínimo para considerar como danger (default: 70)
 	minReportersForUse int // Mínimo de reportadores únicos para usar (default: 2)
+
+	bans         *BanChecker           // nil si no hay DB
+	trustNetwork *TrustNetworkAnalyzer // nil si no hay DB
+
+	// Protección de ReportURL antes de llegar a la DB
+	limits       ReportLimitsConfig
+	userLimiter  *slidingWindowLimiter
+	ipLimiter    *slidingWindowLimiter
+	recentReport *reportDedup
 }
 
 // UserReportsConfig configuración para el checker de reportes
 type UserReportsConfig struct {
-	DatabaseURL        string
+	DatabaseURL        string // Solo se usa si no se recibe la conexión de LocalDB
 	Weight             float64
 	MinScoreForWarning int
 	MinScoreForDanger  int
 	MinReportersForUse int
+	Limits             ReportLimitsConfig // Límites por usuario/IP, repetidos y descripción
 }
 
-// NewUserReportsChecker crea un nuevo checker de reportes de usuarios
+// NewUserReportsChecker crea un nuevo checker de reportes de usuarios. Reusa la conexión
+// de LocalDB; si es nil (LocalDB deshabilitado) abre una propia con config.DatabaseURL.
+// Si la DB no responde al arrancar, se reintenta en background como en LocalDB.
 func NewUserReportsChecker(db *sql.DB, config *UserReportsConfig) *UserReportsChecker {
+	ownsDB := false
+	if db == nil && config.DatabaseURL != "" {
+		err := dbutil.ValidateDatabaseURL(config.DatabaseURL)
+		if err == nil {
+			db, err = sql.Open("postgres", config.DatabaseURL)
+		}
+		if err != nil {
+			log.Error().Err(err).Msg("[UserReports] Failed to open database connection")
+			db = nil
+		} else {
+			db.SetMaxOpenConns(5)
+			db.SetMaxIdleConns(2)
+			db.SetConnMaxLifetime(5 * time.Minute)
+			ownsDB = true
+		}
+	}
 	if db == nil {
 		log.Warn().Msg("[UserReports] No database provided, checker disabled")
 		return &UserReportsChecker{
-			enabled: false,
-			weight:  0.10,
+			weight: 0.10,
 		}
 	}
 
@@ -62,26 +93,47 @@ func NewUserReportsChecker(db *sql.DB, config *UserReportsConfig) *UserReportsCh
 		minReporters = 2 // Requiere al menos 2 reportadores por defecto
 	}
 
-	log.Info().
-		Float64("weight", weight).
-		Int("min_warning_score", minWarning).
-		Int("min_danger_score", minDanger).
-		Int("min_reporters", minReporters).
-		Msg("[UserReports] Checker initialized")
+	limits := config.Limits.withDefaults()
 
-	return &UserReportsChecker{
+	checker := &UserReportsChecker{
 		db:                 db,
-		enabled:            true,
+		conn:               newDBConnState("UserReports", db, nil),
+		ownsDB:             ownsDB,
 		weight:             weight,
 		minScoreForWarning: minWarning,
 		minScoreForDanger:  minDanger,
 		minReportersForUse: minReporters,
+		bans:               NewBanChecker(db),
+		trustNetwork:       NewTrustNetworkAnalyzer(db),
+		limits:             limits,
+		userLimiter:        newSlidingWindowLimiter(limits.MaxPerUser, limits.Window),
+		ipLimiter:          newSlidingWindowLimiter(limits.MaxPerIP, limits.Window),
+		recentReport:       newReportDedup(limits.DuplicateWindow),
 	}
+
+	if err := checker.conn.connect(context.Background()); err != nil {
+		log.Error().Err(err).Msg("[UserReports] Failed to ping database, checker disabled until it becomes reachable")
+		checker.conn.startRetry()
+		return checker
+	}
+
+	log.Info().
+		Float64("weight", weight).
+		Int("min_warning_score", minWarning).
+		Int("min_danger_score", minDanger).
+		Int("min_reporters", minReporters).
+		Int("max_reports_per_user", limits.MaxPerUser).
+		Int("max_reports_per_ip", limits.MaxPerIP).
+		Dur("report_window", limits.Window).
+		Bool("own_connection", ownsDB).
+		Msg("[UserReports] Checker initialized")
+
+	return checker
 }
 
 // Check verifica una URL contra los reportes de usuarios
 func (c *UserReportsChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
-	if !c.enabled || c.db == nil {
+	if !c.IsEnabled() {
 		return &CheckResult{
 			Source: c.Name(),
 			Found:  false,
@@ -135,7 +187,7 @@ func (c *UserReportsChecker) checkURL(ctx context.Context, indicators *Indicator
 			last_reported_at
 		FROM reported_urls
 		WHERE url_hash = sha256_bytea($1)
-		  AND (flags & 1) = 1
+		  AND `+flags.Active.IsSet("flags")+`
 		LIMIT 1
 	`, url).Scan(&threatType, &aggregatedScore, &totalReports, &uniqueReporters, &status, &firstReported, &lastReported)
 
@@ -218,7 +270,7 @@ func (c *UserReportsChecker) checkURL(ctx context.Context, indicators *Indicator
 				COALESCE(AVG(aggregated_score), 0) as avg_score
 			FROM reported_urls
 			WHERE domain = $1
-			  AND (flags & 1) = 1
+			  AND `+flags.Active.IsSet("flags")+`
 			  AND unique_reporters >= $2
 		`, domain, c.minReportersForUse).Scan(&domainReports, &avgScore)
 
@@ -248,6 +300,35 @@ func (c *UserReportsChecker) checkURL(ctx context.Context, indicators *Indicator
 	return result, nil
 }
 
+func init() {
+	Registry.Register("user_reports", newUserReportsFromConfig)
+}
+
+// newUserReportsFromConfig factory del registro. Reutiliza el pool de LocalDB;
+// sin LocalDB el checker abre su propia conexión.
+func newUserReportsFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if !cfg.EnableUserReports || cfg.DatabaseURL == "" {
+		return nil, nil
+	}
+
+	var reportsDB *sql.DB
+	if localDB := cfg.sharedLocalDB(); localDB != nil {
+		reportsDB = localDB.GetDB()
+	}
+	checker := NewUserReportsChecker(reportsDB, &UserReportsConfig{
+		DatabaseURL:        cfg.DBTLS.ApplyToDSN(cfg.DatabaseURL),
+		Weight:             0.10, // Peso bajo por ser crowdsourced
+		MinScoreForWarning: 40,
+		MinScoreForDanger:  70,
+		MinReportersForUse: 2,
+		Limits:             cfg.ReportLimits,
+	})
+	if !checker.IsEnabled() && !checker.Reconnecting() {
+		return nil, fmt.Errorf("UserReports checker failed to initialize")
+	}
+	return checker, nil
+}
+
 // Name retorna el nombre del checker
 func (c *UserReportsChecker) Name() string {
 	return "user_reports"
@@ -258,9 +339,19 @@ func (c *UserReportsChecker) Weight() float64 {
 	return c.weight
 }
 
-// IsEnabled indica si el checker está habilitado
+// IsEnabled indica si el checker está habilitado. Con la DB caída lanza (como mucho
+// una vez por intervalo) un reintento de conexión en background.
 func (c *UserReportsChecker) IsEnabled() bool {
-	return c.enabled
+	if c.conn == nil {
+		return false
+	}
+	c.conn.retryIfDue()
+	return c.conn.Enabled()
+}
+
+// Reconnecting indica si la DB está configurada pero no responde y se está reintentando
+func (c *UserReportsChecker) Reconnecting() bool {
+	return c.conn != nil && c.conn.Retrying()
 }
 
 // SupportedTypes retorna los tipos de input soportados
@@ -268,9 +359,39 @@ func (c *UserReportsChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL}
 }
 
+// Health comprueba que la base de datos responde
+func (c *UserReportsChecker) Health(ctx context.Context) error {
+	if c.db == nil {
+		return fmt.Errorf("database not configured")
+	}
+	return c.db.PingContext(ctx)
+}
+
+// Close detiene los reintentos. Solo cierra la conexión si es propia: la de LocalDB
+// la cierra su checker.
+func (c *UserReportsChecker) Close() error {
+	if c.conn != nil {
+		c.conn.Close()
+	}
+	if c.ownsDB {
+		return c.db.Close()
+	}
+	return nil
+}
+
+// Bans checker de baneos del sistema de reportes (nil si no hay DB)
+func (c *UserReportsChecker) Bans() *BanChecker {
+	return c.bans
+}
+
+// TrustNetwork analizador de cuentas vinculadas (nil si no hay DB)
+func (c *UserReportsChecker) TrustNetwork() *TrustNetworkAnalyzer {
+	return c.trustNetwork
+}
+
 // GetStats retorna estadísticas de reportes de usuarios
 func (c *UserReportsChecker) GetStats(ctx context.Context) (map[string]interface{}, error) {
-	if !c.enabled || c.db == nil {
+	if !c.IsEnabled() {
 		return nil, fmt.Errorf("checker disabled")
 	}
 
@@ -294,7 +415,7 @@ func (c *UserReportsChecker) GetStats(ctx context.Context) (map[string]interface
 			COALESCE(AVG(unique_reporters), 0),
 			COALESCE(SUM(total_reports), 0)
 		FROM reported_urls
-		WHERE (flags & 1) = 1
+		WHERE `+flags.Active.IsSet("flags")+`
 	`).Scan(&totalURLs, &pendingReview, &confirmed, &rejected,
 		&highScore, &mediumScore, &lowScore, &avgReporters, &totalReports)
 
@@ -321,7 +442,7 @@ func (c *UserReportsChecker) GetStats(ctx context.Context) (map[string]interface
 	err = c.db.QueryRowContext(ctx, `
 		SELECT
 			COUNT(*),
-			COUNT(*) FILTER (WHERE (flags & 1) = 1),
+			COUNT(*) FILTER (WHERE `+flags.Banned.IsSet("flags")+`),
 			COALESCE(AVG(trust_score), 50)
 		FROM user_trust_scores
 	`).Scan(&totalUsers, &bannedUsers, &avgTrust)
@@ -337,14 +458,241 @@ func (c *UserReportsChecker) GetStats(ctx context.Context) (map[string]interface
 	return stats, nil
 }
 
-// ReportURL permite reportar una URL (llamado desde el API gateway)
+// UserReportsSummary reportes de un usuario en un periodo (informe mensual del API gateway)
+type UserReportsSummary struct {
+	Total        int64            `json:"total"`
+	ByThreatType map[string]int64 `json:"by_threat_type"`
+	ByDay        map[string]int64 `json:"by_day"` // YYYY-MM-DD -> reportes
+	TopDomains   []DomainCount    `json:"top_domains"`
+}
+
+// DomainCount dominio con su número de apariciones
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  int64  `json:"count"`
+}
+
+// GetUserReportsSummary agrega los reportes de un usuario en [from, to)
+func (c *UserReportsChecker) GetUserReportsSummary(ctx context.Context, userID string, from, to time.Time) (*UserReportsSummary, error) {
+	if !c.IsEnabled() {
+		return nil, fmt.Errorf("checker disabled")
+	}
+
+	summary := &UserReportsSummary{
+		ByThreatType: make(map[string]int64),
+		ByDay:        make(map[string]int64),
+		TopDomains:   []DomainCount{},
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT threat_type::text, to_char(created_at, 'YYYY-MM-DD'), COUNT(*)
+		FROM user_url_reports
+		WHERE user_id = $1 AND created_at >= $2 AND created_at < $3
+		GROUP BY 1, 2
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var threatType, day string
+		var count int64
+		if err := rows.Scan(&threatType, &day, &count); err != nil {
+			return nil, err
+		}
+		summary.Total += count
+		summary.ByThreatType[threatType] += count
+		summary.ByDay[day] += count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	domainRows, err := c.db.QueryContext(ctx, `
+		SELECT r.domain, COUNT(*)
+		FROM user_url_reports u
+		JOIN reported_urls r ON r.url_hash = u.url_hash
+		WHERE u.user_id = $1 AND u.created_at >= $2 AND u.created_at < $3
+		GROUP BY r.domain
+		ORDER BY COUNT(*) DESC, r.domain
+		LIMIT 5
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer domainRows.Close()
+
+	for domainRows.Next() {
+		var dc DomainCount
+		if err := domainRows.Scan(&dc.Domain, &dc.Count); err != nil {
+			return nil, err
+		}
+		summary.TopDomains = append(summary.TopDomains, dc)
+	}
+
+	return summary, domainRows.Err()
+}
+
+// UserNotification notificación in-app (p. ej. reporte confirmado y promovido a threat_domains)
+type UserNotification struct {
+	ID        int64                  `json:"id"`
+	Type      string                 `json:"type"`
+	Title     string                 `json:"title"`
+	Body      string                 `json:"body"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+	CreatedAt time.Time              `json:"created_at"`
+	ReadAt    *time.Time             `json:"read_at,omitempty"`
+}
+
+// GetUserNotifications últimas notificaciones del usuario, más recientes primero
+func (c *UserReportsChecker) GetUserNotifications(ctx context.Context, userID string, limit int) ([]UserNotification, error) {
+	if !c.IsEnabled() {
+		return nil, fmt.Errorf("checker disabled")
+	}
+
+	rows, err := c.db.QueryContext(ctx, `
+		SELECT id, type, title, body, data, created_at, read_at
+		FROM user_notifications
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2
+	`, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	notifications := []UserNotification{}
+	for rows.Next() {
+		var n UserNotification
+		var data []byte
+		var readAt sql.NullTime
+		if err := rows.Scan(&n.ID, &n.Type, &n.Title, &n.Body, &data, &n.CreatedAt, &readAt); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			_ = json.Unmarshal(data, &n.Data)
+		}
+		if readAt.Valid {
+			n.ReadAt = &readAt.Time
+		}
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// MarkNotificationsRead marca como leídas todas las notificaciones pendientes del usuario
+func (c *UserReportsChecker) MarkNotificationsRead(ctx context.Context, userID string) (int64, error) {
+	if !c.IsEnabled() {
+		return 0, fmt.Errorf("checker disabled")
+	}
+
+	result, err := c.db.ExecContext(ctx, `
+		UPDATE user_notifications SET read_at = NOW()
+		WHERE user_id = $1 AND read_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// AnonymizeUser desvincula los reportes de un usuario que ha borrado su cuenta: el user_id pasa
+// a ser su SHA-256 y se eliminan IP y user agent. Los reportes se conservan porque alimentan
+// la puntuación agregada de reported_urls. Retorna el número de reportes anonimizados.
+func (c *UserReportsChecker) AnonymizeUser(ctx context.Context, userID string) (int64, error) {
+	if !c.IsEnabled() {
+		return 0, fmt.Errorf("checker disabled")
+	}
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `
+		UPDATE user_url_reports
+		SET user_id = encode(sha256_bytea($1), 'hex'), user_ip = NULL, user_agent = NULL
+		WHERE user_id = $1
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	anonymized, _ := result.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_trust_scores SET user_id = encode(sha256_bytea($1), 'hex'), updated_at = NOW()
+		WHERE user_id = $1
+	`, userID); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	// Fuera de la transacción: user_notifications no existe sin la migración 008
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM user_notifications WHERE user_id = $1`, userID); err != nil {
+		log.Debug().Err(err).Msg("[UserReports] Could not delete user notifications")
+	}
+	// Igual con whitelist_requests (migración 012); user_id es UUID, no admite el hash
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM whitelist_requests WHERE user_id::text = $1`, userID); err != nil {
+		log.Debug().Err(err).Msg("[UserReports] Could not delete user whitelist requests")
+	}
+	// Y con la red de confianza (migración 022): los vínculos identifican a la persona
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM user_relationships WHERE user_id_a = $1 OR user_id_b = $1`, userID); err != nil {
+		log.Debug().Err(err).Msg("[UserReports] Could not delete user relationships")
+	}
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM trust_network_adjustments WHERE user_id = $1 OR linked_user_id = $1`, userID); err != nil {
+		log.Debug().Err(err).Msg("[UserReports] Could not delete trust network adjustments")
+	}
+
+	log.Info().Int64("reports", anonymized).Msg("[UserReports] User reports anonymized")
+	return anonymized, nil
+}
+
+// ReportURL permite reportar una URL (llamado desde el API gateway).
+// Antes de la DB aplica los límites de Limits: un reporte repetido del mismo usuario dentro de
+// DuplicateWindow devuelve el resultado anterior (AlreadyReportedMessage) y superar el límite
+// por usuario o por IP devuelve TooManyReportsMessage.
 func (c *UserReportsChecker) ReportURL(ctx context.Context, url, domain, userID string,
 	threatType, description, reportContext string, userIP, userAgent string) (bool, string, int, error) {
 
-	if !c.enabled || c.db == nil {
+	if !c.IsEnabled() {
 		return false, "Servicio no disponible", 0, fmt.Errorf("checker disabled")
 	}
 
+	// Baneo a nivel de aplicación (report_url también lo comprueba, pero solo por flag)
+	if banned, err := c.bans.IsBanned(ctx, userID); err != nil {
+		log.Warn().Err(err).Msg("[UserReports] Ban check failed, relying on report_url")
+	} else if banned {
+		reportBansTotal.WithLabelValues("blocked").Inc()
+		log.Info().Str("user_id", userID).Msg("[UserReports] Report rejected: user banned")
+		return false, BannedReportMessage, 0, nil
+	}
+
+	// Repetidos antes que los límites: no deben consumir cupo
+	now := time.Now()
+	if outcome, ok := c.recentReport.get(userID, url, now); ok {
+		reportThrottledTotal.WithLabelValues("duplicate").Inc()
+		log.Debug().Str("user_id", userID).Str("url", url).Msg("[UserReports] Duplicate report, returning cached outcome")
+		return false, AlreadyReportedMessage + ": " + outcome.message, outcome.score, nil
+	}
+	if !c.userLimiter.allow(userID, now) {
+		reportThrottledTotal.WithLabelValues("user").Inc()
+		log.Info().Str("user_id", userID).Msg("[UserReports] Report rejected: user rate limit")
+		return false, TooManyReportsMessage, 0, nil
+	}
+	if !c.ipLimiter.allow(userIP, now) {
+		reportThrottledTotal.WithLabelValues("ip").Inc()
+		log.Info().Str("user_id", userID).Str("user_ip", userIP).Msg("[UserReports] Report rejected: IP rate limit")
+		return false, TooManyReportsMessage, 0, nil
+	}
+
+	description = sanitizeDescription(description, c.limits.MaxDescriptionLen)
+
 	var success bool
 	var message string
 	var score int16
@@ -379,5 +727,9 @@ func (c *UserReportsChecker) ReportURL(ctx context.Context, url, domain, userID
 		Int("new_score", int(score)).
 		Msg("[UserReports] URL report processed")
 
+	if success {
+		c.recentReport.set(userID, url, message, int(score), now)
+	}
+
 	return success, message, int(score), nil
 }
@@ -0,0 +1,207 @@
+package checkers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// VisualSimilarityChecker detecta páginas visualmente iguales a phishing conocido (pHash)
+type VisualSimilarityChecker struct {
+	pageDB        *PhishingPageDB
+	screenshotter Screenshotter
+	client        *http.Client
+	enabled       bool
+	weight        float64
+}
+
+// NewVisualSimilarityChecker crea el checker visual (requiere índice y screenshotter)
+func NewVisualSimilarityChecker(pageDB *PhishingPageDB, screenshotter Screenshotter) *VisualSimilarityChecker {
+	return &VisualSimilarityChecker{
+		pageDB:        pageDB,
+		screenshotter: screenshotter,
+		client: &http.Client{
+			Timeout:   2 * time.Second,
+			Transport: NewPublicOnlyTransport(),
+		},
+		enabled: pageDB != nil && screenshotter != nil,
+		weight:  0.25,
+	}
+}
+
+func init() {
+	Registry.Register("visual", newVisualFromConfig)
+}
+
+// newVisualFromConfig factory del registro: deshabilitado por defecto, requiere Chrome y LocalDB
+func newVisualFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if !cfg.EnableVisual {
+		return nil, nil
+	}
+	localDB := cfg.sharedLocalDB()
+	if localDB == nil || !localDB.IsEnabled() {
+		return nil, fmt.Errorf("visual checker requires LocalDB")
+	}
+
+	screenshotter := NewChromeScreenshotter(cfg.ChromeURL)
+	pageDB := NewPhishingPageDB(localDB.GetDB(), screenshotter)
+	return NewVisualSimilarityChecker(pageDB, screenshotter), nil
+}
+
+// PageDB índice de páginas de phishing (el syncer lo alimenta con los feeds)
+func (c *VisualSimilarityChecker) PageDB() *PhishingPageDB {
+	return c.pageDB
+}
+
+// Name retorna el nombre del checker
+func (c *VisualSimilarityChecker) Name() string {
+	return "visual"
+}
+
+// Weight retorna el peso del checker
+func (c *VisualSimilarityChecker) Weight() float64 {
+	return c.weight
+}
+
+// IsEnabled indica si el checker está habilitado
+func (c *VisualSimilarityChecker) IsEnabled() bool {
+	return c.enabled
+}
+
+// SupportedTypes retorna los tipos soportados (solo URLs)
+func (c *VisualSimilarityChecker) SupportedTypes() []InputType {
+	return []InputType{InputTypeURL}
+}
+
+// NeedsExternalCall implementa ExternalAPIChecker: toda URL descarga la página y hace una captura
+func (c *VisualSimilarityChecker) NeedsExternalCall(inputType InputType, value string) bool {
+	return inputType == InputTypeURL
+}
+
+// Health comprueba que el índice de hashes es accesible
+func (c *VisualSimilarityChecker) Health(ctx context.Context) error {
+	if c.pageDB == nil {
+		return fmt.Errorf("page index not configured")
+	}
+	return c.pageDB.db.PingContext(ctx)
+}
+
+// Close cierra Chrome (el índice usa la conexión de LocalDB, que la cierra su checker)
+func (c *VisualSimilarityChecker) Close() error {
+	if closer, ok := c.screenshotter.(interface{ Close() }); ok {
+		closer.Close()
+	}
+	return nil
+}
+
+// Check captura la página si responde 200 y compara su pHash con el índice
+func (c *VisualSimilarityChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
+	result := &CheckResult{
+		Source:  c.Name(),
+		Found:   false,
+		RawData: make(map[string]interface{}),
+	}
+
+	if indicators.InputType != InputTypeURL || indicators.FullURL == "" {
+		return result, nil
+	}
+
+	// No abrir en Chrome direcciones internas (SSRF)
+	if IsPrivateHost(ctx, indicators.Domain) {
+		result.RawData["skipped"] = "private_address"
+		return result, nil
+	}
+
+	status, err := c.pageStatus(ctx, indicators.FullURL)
+	if err != nil {
+		return nil, err
+	}
+	result.RawData["status_code"] = status
+	if status != http.StatusOK {
+		return result, nil
+	}
+
+	img, err := c.screenshotter.Capture(ctx, indicators.FullURL)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := PerceptualHash(img)
+	if err != nil {
+		return nil, err
+	}
+	result.RawData["phash"] = fmt.Sprintf("%016x", hash)
+
+	match, err := c.pageDB.FindSimilar(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if match == nil {
+		return result, nil
+	}
+
+	log.Debug().
+		Str("url", indicators.FullURL).
+		Str("similar_to", match.SourceURL).
+		Int("distance", match.Distance).
+		Msg("[Visual] Page visually matches known phishing")
+
+	result.Found = true
+	result.ThreatType = ThreatTypePhishing
+	result.Confidence = 0.80
+	result.Tags = []string{"visual_match"}
+	result.RawData["similar_to"] = match.SourceURL
+	result.RawData["distance"] = match.Distance
+	result.RawData["reasons"] = []string{"La página es visualmente idéntica a una web de phishing conocida"}
+
+	return result, nil
+}
+
+// pageStatus código HTTP de la página (solo se capturan las que responden 200)
+func (c *VisualSimilarityChecker) pageStatus(ctx context.Context, pageURL string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	return resp.StatusCode, nil
+}
+
+// IsPrivateHost indica si el host (dominio o IP, IPv6 con o sin corchetes) es o resuelve a
+// una dirección local o privada
+func IsPrivateHost(ctx context.Context, host string) bool {
+	host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	if ip := net.ParseIP(host); ip != nil {
+		return IsPrivateIP(ip)
+	}
+
+	ips, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if IsPrivateIP(ip.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPrivateIP indica si ip es local o privada: loopback (127.0.0.0/8, ::1), privadas
+// (10/8, 172.16/12, 192.168/16 y las ULA fc00::/7), link-local (169.254/16, fe80::/10)
+// o sin especificar (0.0.0.0, ::)
+func IsPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsUnspecified()
+}
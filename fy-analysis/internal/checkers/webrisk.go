@@ -35,16 +35,17 @@ type WebRiskThreat struct {
 	ThreatEntryTypes []string `json:"threatEntryTypes,omitempty"`
 }
 
-// NewWebRiskChecker crea un nuevo checker de Google Web Risk
-func NewWebRiskChecker(apiKey string) *WebRiskChecker {
+// NewWebRiskChecker crea un nuevo checker de Google Web Risk (clients nil = factoría por defecto)
+func NewWebRiskChecker(apiKey string, clients *CheckerHTTPClientFactory) *WebRiskChecker {
+	if clients == nil {
+		clients = DefaultCheckerHTTPClientFactory()
+	}
 	checker := &WebRiskChecker{
-		enabled: apiKey != "",
-		weight:  0.30,
-		apiKey:  apiKey,
-		httpClient: &http.Client{
-			Timeout: 5 * time.Second,
-		},
-		baseURL: "https://webrisk.googleapis.com/v1/uris:search",
+		enabled:    apiKey != "",
+		weight:     0.30,
+		apiKey:     apiKey,
+		httpClient: clients.Client(5 * time.Second),
+		baseURL:    "https://webrisk.googleapis.com/v1/uris:search",
 	}
 
 	if checker.enabled {
@@ -56,6 +57,18 @@ func NewWebRiskChecker(apiKey string) *WebRiskChecker {
 	return checker
 }
 
+func init() {
+	Registry.Register("webrisk", newWebRiskFromConfig)
+}
+
+// newWebRiskFromConfig factory del registro (requiere GOOGLE_WEBRISK_KEY)
+func newWebRiskFromConfig(cfg *EngineConfig) (ThreatChecker, error) {
+	if cfg.GoogleWebRiskKey == "" {
+		return nil, nil
+	}
+	return NewWebRiskChecker(cfg.GoogleWebRiskKey, cfg.checkerHTTPClients()), nil
+}
+
 // Name retorna el nombre del checker
 func (c *WebRiskChecker) Name() string {
 	return "webrisk"
@@ -76,6 +89,25 @@ func (c *WebRiskChecker) SupportedTypes() []InputType {
 	return []InputType{InputTypeURL}
 }
 
+// NeedsExternalCall implementa ExternalAPIChecker: Web Risk no se cachea: toda URL consulta la API
+func (c *WebRiskChecker) NeedsExternalCall(inputType InputType, value string) bool {
+	return inputType == InputTypeURL
+}
+
+// Health falla si no hay API key (no se consume cuota para comprobar la API)
+func (c *WebRiskChecker) Health(ctx context.Context) error {
+	if c.apiKey == "" {
+		return fmt.Errorf("Web Risk API key not configured")
+	}
+	return nil
+}
+
+// Close cierra las conexiones HTTP inactivas
+func (c *WebRiskChecker) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
 // Check verifica los indicadores contra Google Web Risk
 func (c *WebRiskChecker) Check(ctx context.Context, indicators *Indicators) (*CheckResult, error) {
 	result := &CheckResult{
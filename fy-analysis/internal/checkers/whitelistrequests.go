@@ -0,0 +1,199 @@
+package checkers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// maxPendingWhitelistRequests solicitudes pendientes de revisión por usuario
+	maxPendingWhitelistRequests = 5
+
+	whitelistBrandMaxLen       = 50
+	whitelistReasonMaxLen      = 1000
+	whitelistEvidenceURLMaxLen = 500
+)
+
+var (
+	// whitelistDomainRegex dominio con al menos un punto y TLD alfabético (o IDN en punycode)
+	whitelistDomainRegex = regexp.MustCompile(`^([a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+([a-z]{2,63}|xn--[a-z0-9-]+)$`)
+	// whitelistUserIDRegex user_id de api-gateway (UUID)
+	whitelistUserIDRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+// WhitelistRequest dominio legítimo propuesto por un usuario
+type WhitelistRequest struct {
+	UserID      string
+	Domain      string
+	Brand       string
+	Reason      string
+	EvidenceURL string
+}
+
+// WhitelistRequestResult resultado de SubmitWhitelistRequest
+type WhitelistRequestResult struct {
+	Success   bool   `json:"success"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+	Domain    string `json:"domain,omitempty"`
+	Status    string `json:"status,omitempty"` // pending, whitelisted
+}
+
+// SubmitWhitelistRequest registra la propuesta de un usuario en whitelist_requests (pending) para
+// que la revise un admin en fy-admin. Una propuesta repetida del mismo usuario devuelve la
+// pendiente; un dominio ya en whitelist_domains no se registra. Los datos inválidos se
+// responden con Success false y el motivo en Message, como ReportURL.
+func (c *UserReportsChecker) SubmitWhitelistRequest(ctx context.Context, req *WhitelistRequest) (*WhitelistRequestResult, error) {
+	if !c.IsEnabled() {
+		return nil, fmt.Errorf("checker disabled")
+	}
+
+	if !whitelistUserIDRegex.MatchString(req.UserID) {
+		return &WhitelistRequestResult{Success: false, Message: "Usuario inválido"}, nil
+	}
+	domain, err := normalizeRequestedDomain(req.Domain)
+	if err != nil {
+		return &WhitelistRequestResult{Success: false, Message: err.Error()}, nil
+	}
+	brand := sanitizeDescription(req.Brand, whitelistBrandMaxLen)
+	reason := sanitizeDescription(req.Reason, whitelistReasonMaxLen)
+	if reason == "" {
+		return &WhitelistRequestResult{Success: false, Message: "El motivo es obligatorio"}, nil
+	}
+	evidenceURL, err := normalizeEvidenceURL(req.EvidenceURL)
+	if err != nil {
+		return &WhitelistRequestResult{Success: false, Message: err.Error()}, nil
+	}
+
+	if banned, err := c.bans.IsBanned(ctx, req.UserID); err != nil {
+		log.Warn().Err(err).Msg("[Whitelist] Ban check failed")
+	} else if banned {
+		log.Info().Str("user_id", req.UserID).Msg("[Whitelist] Request rejected: user banned")
+		return &WhitelistRequestResult{Success: false, Message: BannedReportMessage}, nil
+	}
+
+	var whitelisted bool
+	if err := c.db.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM whitelist_domains WHERE domain_hash = sha256_bytea($1))
+	`, domain).Scan(&whitelisted); err != nil {
+		return nil, err
+	}
+	if whitelisted {
+		return &WhitelistRequestResult{
+			Success: true,
+			Message: "Este dominio ya está en la lista de dominios legítimos",
+			Domain:  domain,
+			Status:  "whitelisted",
+		}, nil
+	}
+
+	var existingID string
+	var pending int
+	if err := c.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(MAX(id::text) FILTER (WHERE domain = $2), ''),
+			COUNT(*)
+		FROM whitelist_requests
+		WHERE user_id = $1::uuid AND status = 'pending'
+	`, req.UserID, domain).Scan(&existingID, &pending); err != nil {
+		return nil, err
+	}
+	if existingID != "" {
+		return &WhitelistRequestResult{
+			Success:   true,
+			Message:   "Ya tienes una solicitud pendiente para este dominio",
+			RequestID: existingID,
+			Domain:    domain,
+			Status:    "pending",
+		}, nil
+	}
+	if pending >= maxPendingWhitelistRequests {
+		return &WhitelistRequestResult{
+			Success: false,
+			Message: "Tienes demasiadas solicitudes pendientes de revisión. Espera a que se revisen",
+		}, nil
+	}
+
+	var id string
+	err = c.db.QueryRowContext(ctx, `
+		INSERT INTO whitelist_requests (user_id, domain, brand, reason, evidence_url)
+		VALUES ($1::uuid, $2, NULLIF($3, ''), $4, NULLIF($5, ''))
+		ON CONFLICT (user_id, domain) WHERE status = 'pending' DO NOTHING
+		RETURNING id
+	`, req.UserID, domain, brand, reason, evidenceURL).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Otra petición simultánea del mismo usuario la insertó primero
+		return &WhitelistRequestResult{
+			Success: true,
+			Message: "Ya tienes una solicitud pendiente para este dominio",
+			Domain:  domain,
+			Status:  "pending",
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("user_id", req.UserID).
+		Str("domain", domain).
+		Str("request_id", id).
+		Msg("[Whitelist] Whitelist request submitted")
+
+	return &WhitelistRequestResult{
+		Success:   true,
+		Message:   "Solicitud enviada. Te avisaremos cuando se revise",
+		RequestID: id,
+		Domain:    domain,
+		Status:    "pending",
+	}, nil
+}
+
+// normalizeRequestedDomain acepta un dominio o una URL y retorna el host en minúsculas sin www.
+func normalizeRequestedDomain(raw string) (string, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return "", errors.New("El dominio es obligatorio")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Hostname() == "" {
+		return "", errors.New("Dominio inválido")
+	}
+
+	host := strings.TrimSuffix(parsed.Hostname(), ".")
+	if net.ParseIP(host) != nil {
+		return "", errors.New("Las direcciones IP no se pueden añadir a la lista blanca")
+	}
+	host = strings.TrimPrefix(host, "www.")
+	if len(host) > 253 || !whitelistDomainRegex.MatchString(host) {
+		return "", errors.New("Dominio inválido")
+	}
+	return host, nil
+}
+
+// normalizeEvidenceURL URL de evidencia opcional (http/https)
+func normalizeEvidenceURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", nil
+	}
+	if len(raw) > whitelistEvidenceURLMaxLen {
+		return "", errors.New("La URL de evidencia es demasiado larga")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", errors.New("La URL de evidencia debe empezar por http:// o https://")
+	}
+	return parsed.String(), nil
+}
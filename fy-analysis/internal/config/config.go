@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Config contiene la configuración de la aplicación
@@ -11,19 +13,124 @@ type Config struct {
 	Environment string
 	LogLevel    string
 	RateLimit   int
+	// TrustedProxies IPs o CIDRs de los proxies inversos cuyo X-Forwarded-For se respeta
+	// (vacío = ninguno: los rate limits por IP usan la IP de la conexión)
+	TrustedProxies string
+
+	// POST /api/v1/expand (público, sin firma)
+	ExpandRateLimit int           // Peticiones por minuto por IP
+	ExpandCacheTTL  time.Duration // Cache de expansiones (Redis si REDIS_URL, si no en memoria)
 
 	// URL Engine Config
 	GoogleWebRiskKey string
 	URLScanKey       string
+	URLScanSubmit    bool // Enviar a urlscan.io las URLs sin scans previos (veredicto diferido)
 	PhishTankKey     string
+	HIBPKey          string
+	VirusTotalKey    string // Hashes de ficheros (checker malware_hash)
+	MalwareBazaarKey string // Auth-Key de MalwareBazaar (malware_hash, tras VirusTotal)
+	SpamdAddress     string // spamd de SpamAssassin (host:port, unix:/ruta); vacío = sin checker
 	URLhausDBPath    string
 	PhishTankDBPath  string
 	EnableDBSync     bool
+	EnableFileSync   bool     // Refrescar CSV/JSON locales para los checkers en memoria
+	EnableFeedDBSync bool     // Escribir los feeds en PostgreSQL (requiere DATABASE_URL)
+	DisabledCheckers []string // Checkers que no se construyen (DISABLED_CHECKERS=urlscan,visual)
+
+	// Configuración del engine recargable en caliente (YAML, ver ReloadableConfig); vacío = sin recarga
+	EngineConfigFile     string
+	ConfigReloadInterval time.Duration
+
+	// Valores de arranque de la parte recargable que no es de checkers concretos
+	ConsensusThreshold      int           // Fuentes que deben confirmar una amenaza para llegar a danger
+	CheckerPriorityMode     string        // all | priority
+	CheckerBreakerThreshold int           // Fallos seguidos que abren el circuito de un checker (0 = sin circuito)
+	CheckerBreakerCooldown  time.Duration // Tiempo sin llamar a un checker con el circuito abierto
 
 	// PostgreSQL Local DB
-	DatabaseURL       string
-	EnableLocalDB     bool
-	EnableUserReports bool
+	DatabaseURL           string
+	EnableLocalDB         bool
+	EnableUserReports     bool
+	EnableThreatPromotion bool          // Promoción horaria de URLs reportadas a threat_domains
+	LocalDBMaxConns       int           // Tamaño máximo del pool del checker LocalDB
+	DBQueryTimeout        time.Duration // Timeout de las consultas del checker LocalDB (0 = 2s)
+	ConfidenceDecayLambda float64       // λ del decaimiento de confianza por last_seen (0 = 0.01; < 0 = sin decaimiento)
+
+	// mTLS con PostgreSQL: rutas a los PEM (vacías = la URL decide sslmode)
+	DBSSLCert string // DB_SSL_CERT: certificado de cliente
+	DBSSLKey  string // DB_SSL_KEY: clave del cliente (permisos 0600)
+	DBSSLCA   string // DB_SSL_CA: CA del servidor
+
+	// Heurísticas
+	EnableDomainAge bool
+
+	// Checker de similitud visual (pHash)
+	EnableVisualChecker bool
+	ChromeURL           string // DevTools de un Chrome remoto (ws://chrome:9222)
+
+	// Validación DNS de emails (MX/SPF/DMARC)
+	EnableEmailDNS bool
+	// Reglas de normalización de emails (YAML); vacío = Gmail y Yahoo
+	EmailRulesFile string
+	// Webhooks de amenazas detectadas (YAML); vacío = sin webhooks
+	WebhooksFile string
+	// api-gateway, para avisar de las URLs reclasificadas como peligrosas; vacío = sin avisos
+	GatewayURL string
+	// Parámetros de tracking que la URL canónica descarta (utm_*,fbclid); vacío = los por defecto
+	URLTrackingParams []string
+
+	// Lista de dominios de email desechables
+	DisposableDomainsURL   string // Lista remota (un dominio por línea); vacío = solo la incluida
+	DisposableRefreshHours int
+
+	// Tabla de TLDs de riesgo
+	TLDRiskFile         string // "tld puntos" por línea; vacío = la incluida en el binario
+	TLDRiskRefreshHours int    // Recálculo con threat_domains/whitelist_domains; 0 = deshabilitado
+
+	// Lookup de teléfono (caller-ID)
+	PhoneLookupTimeoutMs   int // Presupuesto por petición en ms
+	PhoneLookupCacheTTLSec int // TTL de la cache en memoria
+
+	// Límites del timeout_ms de cada petición de análisis (timeout de los checkers)
+	AnalysisTimeoutMinMs int
+	AnalysisTimeoutMaxMs int // El WriteTimeout del servidor es 15s
+
+	// Lookup de operador de teléfonos (Numverify o HLR local)
+	EnableCarrierLookup bool
+	CarrierLookupURL    string // Vacío = Numverify
+	CarrierLookupKey    string // access_key de Numverify
+
+	// Límites de reportes de usuarios (en memoria, por instancia)
+	ReportMaxPerUser        int
+	ReportMaxPerIP          int
+	ReportWindow            time.Duration
+	ReportDuplicateWindow   time.Duration
+	ReportMaxDescriptionLen int
+
+	// Cuotas por llamante y concurrencia de los análisis (en memoria, por instancia)
+	AnalysisCheapPerMinute     int
+	AnalysisExpensivePerMinute int
+	AnalysisMaxConcurrent      int
+	AnalysisMaxQueued          int
+	AnalysisQueueTimeout       time.Duration
+
+	// Firma de peticiones internas (HMAC compartido con api-gateway, fy-engine y fy-admin)
+	InternalSigningSecret string
+	// Redis para los nonces de la firma y la cache de /expand (vacío = en memoria)
+	RedisURL      string
+	RedisPassword string
+	RedisDB       int
+
+	// Token de los endpoints /admin (X-Internal-Token); vacío = deshabilitados
+	InternalAdminToken string
+
+	// Conexiones salientes de los checkers (el proxy se lee de HTTP_PROXY/HTTPS_PROXY/NO_PROXY)
+	CheckerMaxIdleConns        int
+	CheckerMaxIdleConnsPerHost int
+	CheckerIdleConnTimeoutSec  int
+	CheckerClientCertFile      string // Certificado cliente para proxies con mTLS
+	CheckerClientKeyFile       string
+	CheckerCAFile              string // CA del proxy corporativo
 }
 
 // Load carga la configuración desde variables de entorno
@@ -34,20 +141,115 @@ func Load() *Config {
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
 		RateLimit:   getEnvAsInt("RATE_LIMIT", 100),
 
+		TrustedProxies: getEnv("TRUSTED_PROXIES", ""),
+
+		ExpandRateLimit: getEnvAsInt("EXPAND_RATE_LIMIT", 30),
+		ExpandCacheTTL:  getEnvAsDuration("EXPAND_CACHE_TTL", time.Hour),
+
 		// URL Engine - API Keys
 		GoogleWebRiskKey: getEnv("GOOGLE_WEBRISK_KEY", ""),
 		URLScanKey:       getEnv("URLSCAN_KEY", ""),
+		URLScanSubmit:    getEnvAsBool("ENABLE_URLSCAN_SUBMIT", false),
 		PhishTankKey:     getEnv("PHISHTANK_KEY", ""),
+		HIBPKey:          getEnv("HIBP_KEY", ""),
+		VirusTotalKey:    getEnv("VIRUSTOTAL_KEY", ""),
+		MalwareBazaarKey: getEnv("MALWAREBAZAAR_KEY", ""),
+		SpamdAddress:     getEnv("SPAMD_ADDRESS", ""),
 
 		// URL Engine - DB Paths
-		URLhausDBPath:   getEnv("URLHAUS_DB_PATH", "/data/urlhaus.csv"),
-		PhishTankDBPath: getEnv("PHISHTANK_DB_PATH", "/data/phishtank.json"),
-		EnableDBSync:    getEnvAsBool("ENABLE_DB_SYNC", true),
+		URLhausDBPath:    getEnv("URLHAUS_DB_PATH", "/data/urlhaus.csv"),
+		PhishTankDBPath:  getEnv("PHISHTANK_DB_PATH", "/data/phishtank.json"),
+		EnableDBSync:     getEnvAsBool("ENABLE_DB_SYNC", true),
+		EnableFileSync:   getEnvAsBool("ENABLE_FILE_SYNC", true),
+		EnableFeedDBSync: getEnvAsBool("ENABLE_FEED_DB_SYNC", true),
+		DisabledCheckers: getEnvAsList("DISABLED_CHECKERS"),
+
+		EngineConfigFile:     getEnv("ENGINE_CONFIG_FILE", ""),
+		ConfigReloadInterval: getEnvAsDuration("CONFIG_RELOAD_INTERVAL", time.Minute),
+
+		ConsensusThreshold:      getEnvAsInt("CONSENSUS_THRESHOLD", 1),
+		CheckerPriorityMode:     getEnv("CHECKER_PRIORITY_MODE", "all"),
+		CheckerBreakerThreshold: getEnvAsInt("CHECKER_BREAKER_THRESHOLD", 5),
+		CheckerBreakerCooldown:  getEnvAsDuration("CHECKER_BREAKER_COOLDOWN", 30*time.Second),
 
 		// PostgreSQL Local DB
-		DatabaseURL:       getEnv("DATABASE_URL", ""),
-		EnableLocalDB:     getEnvAsBool("ENABLE_LOCAL_DB", true),
-		EnableUserReports: getEnvAsBool("ENABLE_USER_REPORTS", true),
+		DatabaseURL:           getEnv("DATABASE_URL", ""),
+		EnableLocalDB:         getEnvAsBool("ENABLE_LOCAL_DB", true),
+		EnableUserReports:     getEnvAsBool("ENABLE_USER_REPORTS", true),
+		EnableThreatPromotion: getEnvAsBool("ENABLE_THREAT_PROMOTION", true),
+		LocalDBMaxConns:       getEnvAsInt("LOCALDB_MAX_CONNS", 10),
+		DBQueryTimeout:        getEnvAsDuration("DB_QUERY_TIMEOUT", 0),
+		ConfidenceDecayLambda: getEnvAsFloat("CONFIDENCE_DECAY_LAMBDA", 0),
+		DBSSLCert:             getEnv("DB_SSL_CERT", ""),
+		DBSSLKey:              getEnv("DB_SSL_KEY", ""),
+		DBSSLCA:               getEnv("DB_SSL_CA", ""),
+
+		// Heurísticas
+		EnableDomainAge: getEnvAsBool("ENABLE_DOMAIN_AGE", true),
+
+		// Checker de similitud visual (pHash)
+		EnableVisualChecker: getEnvAsBool("ENABLE_VISUAL_CHECKER", false),
+		ChromeURL:           getEnv("CHROME_URL", ""),
+
+		// Validación DNS de emails (MX/SPF/DMARC)
+		EnableEmailDNS: getEnvAsBool("ENABLE_EMAIL_DNS", true),
+		EmailRulesFile: getEnv("EMAIL_RULES_FILE", ""),
+		WebhooksFile:   getEnv("WEBHOOKS_FILE", ""),
+		GatewayURL:     getEnv("GATEWAY_URL", ""),
+
+		// Canonicalización de URLs
+		URLTrackingParams: getEnvAsList("URL_TRACKING_PARAMS"),
+
+		// Lista de dominios de email desechables
+		DisposableDomainsURL:   getEnv("DISPOSABLE_DOMAINS_URL", "https://raw.githubusercontent.com/disposable-email-domains/disposable-email-domains/main/disposable_email_blocklist.conf"),
+		DisposableRefreshHours: getEnvAsInt("DISPOSABLE_REFRESH_HOURS", 24),
+
+		// Tabla de TLDs de riesgo
+		TLDRiskFile:         getEnv("TLD_RISK_FILE", ""),
+		TLDRiskRefreshHours: getEnvAsInt("TLD_RISK_REFRESH_HOURS", 0),
+
+		// Lookup de teléfono (caller-ID)
+		PhoneLookupTimeoutMs:   getEnvAsInt("PHONE_LOOKUP_TIMEOUT_MS", 120),
+		PhoneLookupCacheTTLSec: getEnvAsInt("PHONE_LOOKUP_CACHE_TTL", 600),
+
+		// Límites del timeout_ms de cada petición de análisis
+		AnalysisTimeoutMinMs: getEnvAsInt("ANALYSIS_TIMEOUT_MIN_MS", 200),
+		AnalysisTimeoutMaxMs: getEnvAsInt("ANALYSIS_TIMEOUT_MAX_MS", 10000),
+
+		// Lookup de operador de teléfonos
+		EnableCarrierLookup: getEnvAsBool("ENABLE_CARRIER_LOOKUP", false),
+		CarrierLookupURL:    getEnv("CARRIER_LOOKUP_URL", ""),
+		CarrierLookupKey:    getEnv("NUMVERIFY_KEY", ""),
+
+		// Límites de reportes de usuarios
+		ReportMaxPerUser:        getEnvAsInt("REPORT_MAX_PER_USER", 20),
+		ReportMaxPerIP:          getEnvAsInt("REPORT_MAX_PER_IP", 60),
+		ReportWindow:            getEnvAsDuration("REPORT_WINDOW", time.Hour),
+		ReportDuplicateWindow:   getEnvAsDuration("REPORT_DUPLICATE_WINDOW", 24*time.Hour),
+		ReportMaxDescriptionLen: getEnvAsInt("REPORT_MAX_DESCRIPTION_LEN", 1000),
+
+		// Cuotas y concurrencia de los análisis
+		AnalysisCheapPerMinute:     getEnvAsInt("ANALYSIS_CHEAP_PER_MINUTE", 600),
+		AnalysisExpensivePerMinute: getEnvAsInt("ANALYSIS_EXPENSIVE_PER_MINUTE", 120),
+		AnalysisMaxConcurrent:      getEnvAsInt("ANALYSIS_MAX_CONCURRENT", 32),
+		AnalysisMaxQueued:          getEnvAsInt("ANALYSIS_MAX_QUEUED", 64),
+		AnalysisQueueTimeout:       getEnvAsDuration("ANALYSIS_QUEUE_TIMEOUT", 2*time.Second),
+
+		// Firma de peticiones internas
+		InternalSigningSecret: getEnv("INTERNAL_SIGNING_SECRET", ""),
+		RedisURL:              getEnv("REDIS_URL", ""),
+		RedisPassword:         getEnv("REDIS_PASSWORD", ""),
+		RedisDB:               getEnvAsInt("REDIS_DB", 0),
+
+		InternalAdminToken: getEnv("INTERNAL_ADMIN_TOKEN", ""),
+
+		// Conexiones salientes de los checkers
+		CheckerMaxIdleConns:        getEnvAsInt("CHECKER_MAX_IDLE_CONNS", 100),
+		CheckerMaxIdleConnsPerHost: getEnvAsInt("CHECKER_MAX_IDLE_CONNS_PER_HOST", 10),
+		CheckerIdleConnTimeoutSec:  getEnvAsInt("CHECKER_IDLE_CONN_TIMEOUT", 90),
+		CheckerClientCertFile:      getEnv("CHECKER_CLIENT_CERT_FILE", ""),
+		CheckerClientKeyFile:       getEnv("CHECKER_CLIENT_KEY_FILE", ""),
+		CheckerCAFile:              getEnv("CHECKER_CA_FILE", ""),
 	}
 }
 
@@ -75,3 +277,32 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value, exists := os.LookupEnv(key); exists {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+func getEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, exists := os.LookupEnv(key); exists {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsList lista separada por comas (sin elementos vacíos)
+func getEnvAsList(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,162 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// maxReloadCheckTimeout límite del timeout de checkers recargable (el WriteTimeout del servidor es 15s)
+const maxReloadCheckTimeout = 10 * time.Second
+
+// ReloadableConfig parámetros del engine que se pueden cambiar sin reiniciar (ENGINE_CONFIG_FILE).
+// Los campos ausentes del fichero mantienen el valor de arranque.
+//
+//	check_timeout: 3s
+//	disabled_checkers: [urlscan]
+//	checker_weights:
+//	  localdb: 0.35
+//	  user_reports: 0.05
+//	consensus_threshold: 2        # CONSENSUS_THRESHOLD
+//	checker_priority_mode: priority # CHECKER_PRIORITY_MODE: all | priority
+//	breaker_threshold: 5          # CHECKER_BREAKER_THRESHOLD (0 = sin circuito)
+//	breaker_cooldown: 30s         # CHECKER_BREAKER_COOLDOWN
+type ReloadableConfig struct {
+	CheckTimeout        time.Duration      `yaml:"check_timeout"`
+	DisabledCheckers    []string           `yaml:"disabled_checkers"`
+	CheckerWeights      map[string]float64 `yaml:"checker_weights"`
+	ConsensusThreshold  int                `yaml:"consensus_threshold"`
+	CheckerPriorityMode string             `yaml:"checker_priority_mode"`
+	BreakerThreshold    *int               `yaml:"breaker_threshold"` // Puntero: 0 desactiva el circuito
+	BreakerCooldown     time.Duration      `yaml:"breaker_cooldown"`
+}
+
+// maxConsensusThreshold límite de consensus_threshold (más fuentes de las que responden a la vez
+// dejaría todo en warning)
+const maxConsensusThreshold = 5
+
+// ParseReloadableConfig valida el YAML de configuración recargable
+func ParseReloadableConfig(data []byte) (*ReloadableConfig, error) {
+	var cfg ReloadableConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) { // Vacío = valores de arranque
+		return nil, fmt.Errorf("invalid engine config: %w", err)
+	}
+
+	if cfg.CheckTimeout < 0 || cfg.CheckTimeout > maxReloadCheckTimeout {
+		return nil, fmt.Errorf("check_timeout must be between 0 and %s", maxReloadCheckTimeout)
+	}
+	for name, weight := range cfg.CheckerWeights {
+		if weight < 0 || weight > 1 {
+			return nil, fmt.Errorf("checker weight for %q must be between 0 and 1", name)
+		}
+	}
+	if cfg.ConsensusThreshold < 0 || cfg.ConsensusThreshold > maxConsensusThreshold {
+		return nil, fmt.Errorf("consensus_threshold must be between 1 and %d", maxConsensusThreshold)
+	}
+	switch cfg.CheckerPriorityMode {
+	case "", "all", "priority":
+	default:
+		return nil, fmt.Errorf("checker_priority_mode must be all or priority, got %q", cfg.CheckerPriorityMode)
+	}
+	if cfg.BreakerThreshold != nil && *cfg.BreakerThreshold < 0 {
+		return nil, fmt.Errorf("breaker_threshold must not be negative")
+	}
+	if cfg.BreakerCooldown < 0 {
+		return nil, fmt.Errorf("breaker_cooldown must not be negative")
+	}
+	return &cfg, nil
+}
+
+// ConfigWatcher comprueba cada interval si el fichero de configuración ha cambiado (por su hash,
+// así un touch sin cambios no recarga) y entrega la nueva configuración a onChange.
+// Un fichero inválido se registra y se ignora: el engine sigue con la última configuración válida.
+type ConfigWatcher struct {
+	path     string
+	interval time.Duration
+	onChange func(*ReloadableConfig) error
+
+	lastHash [sha256.Size]byte
+	loaded   bool
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewConfigWatcher crea el watcher de path
+func NewConfigWatcher(path string, interval time.Duration, onChange func(*ReloadableConfig) error) *ConfigWatcher {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &ConfigWatcher{
+		path:     path,
+		interval: interval,
+		onChange: onChange,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start aplica el fichero inmediatamente y después lo revisa cada interval
+func (w *ConfigWatcher) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			w.check()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	log.Info().
+		Str("file", w.path).
+		Dur("interval", w.interval).
+		Msg("[Config] Watching engine config file")
+}
+
+// Stop detiene la revisión periódica
+func (w *ConfigWatcher) Stop() {
+	w.stopOnce.Do(func() { close(w.stopCh) })
+}
+
+func (w *ConfigWatcher) check() {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		log.Warn().Err(err).Str("file", w.path).Msg("[Config] Failed to read engine config file")
+		return
+	}
+
+	hash := sha256.Sum256(data)
+	if w.loaded && hash == w.lastHash {
+		return
+	}
+	// El hash se guarda aunque falle: un fichero inválido se avisa una vez, no en cada tick
+	w.lastHash = hash
+	w.loaded = true
+
+	cfg, err := ParseReloadableConfig(data)
+	if err != nil {
+		log.Error().Err(err).Str("file", w.path).Msg("[Config] Engine config file rejected, keeping current config")
+		return
+	}
+	if err := w.onChange(cfg); err != nil {
+		log.Error().Err(err).Str("file", w.path).Msg("[Config] Engine config reload failed")
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseReloadableConfig(t *testing.T) {
+	cfg, err := ParseReloadableConfig([]byte(`
+check_timeout: 2s
+consensus_threshold: 2
+checker_priority_mode: priority
+breaker_threshold: 0
+breaker_cooldown: 1m
+checker_weights:
+  localdb: 0.4
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ConsensusThreshold != 2 || cfg.CheckerPriorityMode != "priority" || cfg.BreakerCooldown != time.Minute {
+		t.Errorf("parsed %+v", cfg)
+	}
+	// breaker_threshold: 0 desactiva el circuito, distinto de no definirlo
+	if cfg.BreakerThreshold == nil || *cfg.BreakerThreshold != 0 {
+		t.Errorf("breaker_threshold = %v, want explicit 0", cfg.BreakerThreshold)
+	}
+
+	empty, err := ParseReloadableConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if empty.BreakerThreshold != nil || empty.ConsensusThreshold != 0 || empty.CheckerPriorityMode != "" {
+		t.Errorf("empty file should keep startup values, got %+v", empty)
+	}
+
+	invalid := map[string]string{
+		"timeout too high":   "check_timeout: 30s",
+		"weight above 1":     "checker_weights: {localdb: 1.5}",
+		"consensus negative": "consensus_threshold: -1",
+		"consensus too high": "consensus_threshold: 9",
+		"unknown mode":       "checker_priority_mode: fastest",
+		"breaker negative":   "breaker_threshold: -2",
+		"cooldown negative":  "breaker_cooldown: -5s",
+		"unknown field":      "consensus: 2",
+	}
+	for name, data := range invalid {
+		if _, err := ParseReloadableConfig([]byte(data)); err == nil {
+			t.Errorf("%s: %q accepted", name, data)
+		}
+	}
+}
+
+func TestConfigWatcherReloadsOnChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "engine.yaml")
+	write := func(data string) {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var applied []*ReloadableConfig
+	w := NewConfigWatcher(path, time.Minute, func(cfg *ReloadableConfig) error {
+		applied = append(applied, cfg)
+		return nil
+	})
+
+	write("consensus_threshold: 2\n")
+	w.check()
+	w.check() // Mismo contenido: no recarga
+	if len(applied) != 1 || applied[0].ConsensusThreshold != 2 {
+		t.Fatalf("applied = %d configs, want 1 with consensus 2", len(applied))
+	}
+
+	write("checker_priority_mode: nope\n")
+	w.check() // Inválido: se ignora y el engine sigue con la última válida
+	if len(applied) != 1 {
+		t.Fatalf("invalid file was applied")
+	}
+
+	write("checker_priority_mode: priority\nbreaker_threshold: 3\n")
+	w.check()
+	if len(applied) != 2 || applied[1].CheckerPriorityMode != "priority" || *applied[1].BreakerThreshold != 3 {
+		t.Fatalf("change not applied: %d configs", len(applied))
+	}
+}
@@ -0,0 +1,237 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// DomainAgeLookup obtiene la fecha de registro de dominios vía RDAP (reemplaza WHOIS)
+type DomainAgeLookup struct {
+	db         *sql.DB // Cache en domain_age_cache (opcional)
+	httpClient *http.Client
+	rdapURL    string
+	timeout    time.Duration
+	cacheTTL   time.Duration
+}
+
+// DomainAge información de registro de un dominio
+type DomainAge struct {
+	Domain       string
+	RegisteredAt *time.Time // nil = RDAP no devolvió fecha de registro
+	Registrar    string
+}
+
+// AgeDays retorna los días desde el registro, o -1 si se desconoce
+func (d *DomainAge) AgeDays() int {
+	if d == nil || d.RegisteredAt == nil {
+		return -1
+	}
+	return int(time.Since(*d.RegisteredAt).Hours() / 24)
+}
+
+// rdapResponse subconjunto de la respuesta RDAP que nos interesa
+type rdapResponse struct {
+	Events []struct {
+		EventAction string `json:"eventAction"`
+		EventDate   string `json:"eventDate"`
+	} `json:"events"`
+	Entities []struct {
+		Roles      []string        `json:"roles"`
+		VCardArray json.RawMessage `json:"vcardArray"`
+	} `json:"entities"`
+}
+
+// NewDomainAgeLookup crea un nuevo lookup RDAP; db puede ser nil (sin cache)
+func NewDomainAgeLookup(db *sql.DB, timeout time.Duration) *DomainAgeLookup {
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	return &DomainAgeLookup{
+		db:         db,
+		httpClient: &http.Client{Timeout: timeout},
+		rdapURL:    "https://rdap.org/domain/",
+		timeout:    timeout,
+		cacheTTL:   7 * 24 * time.Hour,
+	}
+}
+
+// Lookup retorna la antigüedad del dominio, usando la cache si está disponible
+func (l *DomainAgeLookup) Lookup(ctx context.Context, domain string) (*DomainAge, error) {
+	domain = registrableDomain(domain)
+	if domain == "" {
+		return nil, fmt.Errorf("invalid domain")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	if cached, ok := l.getCached(ctx, domain); ok {
+		return cached, nil
+	}
+
+	age, err := l.queryRDAP(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	l.storeCached(ctx, age)
+	return age, nil
+}
+
+// queryRDAP consulta rdap.org y extrae el evento "registration"
+func (l *DomainAgeLookup) queryRDAP(ctx context.Context, domain string) (*DomainAge, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", l.rdapURL+domain, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/rdap+json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("rdap request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	age := &DomainAge{Domain: domain}
+
+	// 404 = el registro no conoce el dominio; se cachea sin fecha
+	if resp.StatusCode == http.StatusNotFound {
+		return age, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("rdap returned status %d", resp.StatusCode)
+	}
+
+	var rdap rdapResponse
+	if err := json.NewDecoder(resp.Body).Decode(&rdap); err != nil {
+		return nil, fmt.Errorf("failed to decode rdap response: %w", err)
+	}
+
+	for _, event := range rdap.Events {
+		if event.EventAction != "registration" {
+			continue
+		}
+		if t, err := time.Parse(time.RFC3339, event.EventDate); err == nil {
+			age.RegisteredAt = &t
+		}
+		break
+	}
+
+	for _, entity := range rdap.Entities {
+		for _, role := range entity.Roles {
+			if role == "registrar" {
+				age.Registrar = vcardName(entity.VCardArray)
+			}
+		}
+	}
+
+	log.Debug().
+		Str("domain", domain).
+		Int("age_days", age.AgeDays()).
+		Str("registrar", age.Registrar).
+		Msg("[DomainAge] RDAP lookup completed")
+
+	return age, nil
+}
+
+// getCached busca el dominio en domain_age_cache
+func (l *DomainAgeLookup) getCached(ctx context.Context, domain string) (*DomainAge, bool) {
+	if l.db == nil {
+		return nil, false
+	}
+
+	var registeredAt sql.NullTime
+	var registrar sql.NullString
+	err := l.db.QueryRowContext(ctx, `
+		SELECT registered_at, registrar
+		FROM domain_age_cache
+		WHERE domain = $1 AND cached_at > NOW() - $2::interval
+	`, domain, fmt.Sprintf("%d seconds", int(l.cacheTTL.Seconds()))).Scan(&registeredAt, &registrar)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Debug().Err(err).Str("domain", domain).Msg("[DomainAge] Cache lookup failed")
+		}
+		return nil, false
+	}
+
+	age := &DomainAge{Domain: domain, Registrar: registrar.String}
+	if registeredAt.Valid {
+		age.RegisteredAt = &registeredAt.Time
+	}
+	return age, true
+}
+
+// storeCached guarda el resultado en domain_age_cache
+func (l *DomainAgeLookup) storeCached(ctx context.Context, age *DomainAge) {
+	if l.db == nil {
+		return
+	}
+
+	_, err := l.db.ExecContext(ctx, `
+		INSERT INTO domain_age_cache (domain, registered_at, registrar, cached_at)
+		VALUES ($1, $2, NULLIF($3, ''), NOW())
+		ON CONFLICT (domain) DO UPDATE SET
+			registered_at = EXCLUDED.registered_at,
+			registrar = EXCLUDED.registrar,
+			cached_at = NOW()
+	`, age.Domain, age.RegisteredAt, age.Registrar)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", age.Domain).Msg("[DomainAge] Failed to store cache")
+	}
+}
+
+// vcardName extrae el campo "fn" de un vcardArray jCard
+func vcardName(raw json.RawMessage) string {
+	var vcard []interface{}
+	if err := json.Unmarshal(raw, &vcard); err != nil || len(vcard) < 2 {
+		return ""
+	}
+	props, ok := vcard[1].([]interface{})
+	if !ok {
+		return ""
+	}
+	for _, p := range props {
+		prop, ok := p.([]interface{})
+		if !ok || len(prop) < 4 {
+			continue
+		}
+		if name, _ := prop[0].(string); name == "fn" {
+			value, _ := prop[3].(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// registrableDomain reduce un host a su dominio registrable (ej: login.banco.co.uk -> banco.co.uk)
+func registrableDomain(host string) string {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	if host == "" || net.ParseIP(host) != nil {
+		return ""
+	}
+
+	parts := strings.Split(host, ".")
+	if len(parts) < 2 {
+		return ""
+	}
+
+	// Sufijos de segundo nivel habituales (ej: com.es, co.uk)
+	secondLevel := map[string]bool{
+		"com": true, "co": true, "org": true, "net": true,
+		"gob": true, "gov": true, "edu": true, "nom": true,
+	}
+	n := 2
+	if len(parts) >= 3 && secondLevel[parts[len(parts)-2]] && len(parts[len(parts)-1]) == 2 {
+		n = 3
+	}
+
+	return strings.Join(parts[len(parts)-n:], ".")
+}
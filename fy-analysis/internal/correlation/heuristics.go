@@ -2,23 +2,39 @@ package correlation
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"net"
+	"net/url"
+	"sort"
 	"strings"
+	"sync/atomic"
 
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/disposable"
+	"github.com/trackfy/fy-analysis/internal/tldrisk"
 )
 
+// RulesetRevision revisión de las reglas heurísticas (fecha del cambio, .N si hay varios el
+// mismo día). Se sube al cambiar una regla, sus puntos o sus listas: es el mismo cambio que
+// obliga a regenerar el golden del corpus (go test ./internal/urlengine -run Corpus -update).
+// Va en el engine_info de cada análisis.
+const RulesetRevision = "2026-10-16"
+
 // HeuristicEngine motor de análisis heurístico
 type HeuristicEngine struct {
 	// Dominios oficiales de bancos españoles
 	spanishBanks map[string][]string
 	// Dominios oficiales de telcos españolas
 	spanishTelcos map[string][]string
-	// TLDs sospechosos
-	suspiciousTLDs map[string]int // TLD -> puntos de riesgo
-	// Prefijos premium españoles
-	premiumPrefixes []string
+	// Nombres habituales de buzones de servicio (support@, noreply@...)
+	serviceLocalParts []string
+	// Dominios de whitelist_domains para officialDomainTypo; nil hasta la primera carga
+	// (mientras tanto se usan los de spanishBanks y spanishTelcos)
+	officialDomains atomic.Pointer[[]string]
+	// Lookup RDAP de antigüedad de dominios (opcional)
+	domainAge *DomainAgeLookup
 }
 
 // NewHeuristicEngine crea un nuevo motor heurístico
@@ -53,48 +69,69 @@ func NewHeuristicEngine() *HeuristicEngine {
 			"simyo":     {"simyo.es"},
 			"finetwork": {"finetwork.com"},
 		},
-		suspiciousTLDs: map[string]int{
-			"xyz":     15,
-			"top":     15,
-			"tk":      20,
-			"ml":      20,
-			"ga":      20,
-			"cf":      20,
-			"gq":      20,
-			"buzz":    10,
-			"click":   15,
-			"link":    10,
-			"work":    10,
-			"rest":    15,
-			"cam":     15,
-			"icu":     15,
-			"monster": 15,
-			"uno":     10,
-		},
-		premiumPrefixes: []string{"803", "806", "807", "905", "907"},
+		serviceLocalParts: []string{"support", "security", "help", "info", "noreply"},
+	}
+}
+
+// SetDomainAgeLookup habilita la comprobación de antigüedad de dominios vía RDAP
+func (h *HeuristicEngine) SetDomainAgeLookup(lookup *DomainAgeLookup) {
+	h.domainAge = lookup
+}
+
+// LoadOfficialDomains carga los dominios de whitelist_domains con los que se comparan los
+// dominios de email en officialDomainTypo. Si falla se mantiene la carga anterior.
+func (h *HeuristicEngine) LoadOfficialDomains(ctx context.Context, db *sql.DB) error {
+	rows, err := db.QueryContext(ctx, `SELECT LOWER(domain) FROM whitelist_domains`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var domains []string
+	for rows.Next() {
+		var domain string
+		if err := rows.Scan(&domain); err != nil {
+			return err
+		}
+		domains = append(domains, domain)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(domains) == 0 {
+		return fmt.Errorf("whitelist_domains is empty")
 	}
+
+	sort.Strings(domains)
+	h.officialDomains.Store(&domains)
+	log.Debug().Int("domains", len(domains)).Msg("[Heuristics] Official domains loaded")
+	return nil
 }
 
 // HeuristicResult resultado del análisis heurístico
 type HeuristicResult struct {
-	Score       int      // Puntos de riesgo acumulados
-	Reasons     []string // Razones en español
-	Flags       []string // Flags técnicos
-	ContextHits []string // Coincidencias de contexto
+	Score         int      // Puntos de riesgo acumulados
+	Reasons       []string // Razones en español
+	Flags         []string // Flags técnicos
+	ContextHits   []string // Coincidencias de contexto
+	DomainAgeDays int      // Días desde el registro del dominio (-1 = desconocido)
+	PhoneType     string   // Tipo de línea (mobile, landline, premium, voip...) si es un teléfono
+	RedirectChain []string // Cadena de redirects de la URL (vacía si no redirige)
 }
 
 // Analyze ejecuta el análisis heurístico completo
 func (h *HeuristicEngine) Analyze(ctx context.Context, indicators *checkers.Indicators, analysisCtx *checkers.AnalysisContext) *HeuristicResult {
 	result := &HeuristicResult{
-		Score:       0,
-		Reasons:     []string{},
-		Flags:       []string{},
-		ContextHits: []string{},
+		Score:         0,
+		Reasons:       []string{},
+		Flags:         []string{},
+		ContextHits:   []string{},
+		DomainAgeDays: -1,
 	}
 
 	switch indicators.InputType {
 	case checkers.InputTypeURL:
-		h.analyzeURL(indicators, analysisCtx, result)
+		h.analyzeURL(ctx, indicators, analysisCtx, result)
 	case checkers.InputTypeEmail:
 		h.analyzeEmail(indicators, analysisCtx, result)
 	case checkers.InputTypePhone:
@@ -110,11 +147,11 @@ func (h *HeuristicEngine) Analyze(ctx context.Context, indicators *checkers.Indi
 }
 
 // analyzeURL analiza heurísticas específicas de URLs
-func (h *HeuristicEngine) analyzeURL(indicators *checkers.Indicators, ctx *checkers.AnalysisContext, result *HeuristicResult) {
+func (h *HeuristicEngine) analyzeURL(ctx context.Context, indicators *checkers.Indicators, analysisCtx *checkers.AnalysisContext, result *HeuristicResult) {
 	domain := strings.ToLower(indicators.Domain)
 
-	// 1. TLD sospechoso
-	if points, isSuspicious := h.suspiciousTLDs[indicators.TLD]; isSuspicious {
+	// 1. TLD sospechoso (tabla compartida con el analizador legacy, ver internal/tldrisk)
+	if points := tldrisk.Default.Points(indicators.TLD); points > 0 {
 		result.Score += points
 		result.Flags = append(result.Flags, "suspicious_tld")
 		result.Reasons = append(result.Reasons, fmt.Sprintf("El dominio usa un TLD sospechoso (.%s)", indicators.TLD))
@@ -141,8 +178,8 @@ func (h *HeuristicEngine) analyzeURL(indicators *checkers.Indicators, ctx *check
 	}
 
 	// 4. Context mismatch: dice ser X pero dominio no coincide
-	if ctx != nil && ctx.ClaimedSender != "" {
-		claimedLower := strings.ToLower(ctx.ClaimedSender)
+	if analysisCtx != nil && analysisCtx.ClaimedSender != "" {
+		claimedLower := strings.ToLower(analysisCtx.ClaimedSender)
 		matched := false
 
 		// Buscar en bancos
@@ -187,11 +224,15 @@ func (h *HeuristicEngine) analyzeURL(indicators *checkers.Indicators, ctx *check
 		}
 	}
 
-	// 5. URL con IP directa (sospechoso)
-	if indicators.IP != "" && indicators.Domain == indicators.IP {
+	// 5. URL con IP directa, IPv4 o IPv6 (sospechoso)
+	if ip := net.ParseIP(domain); ip != nil {
 		result.Score += 25
 		result.Flags = append(result.Flags, "direct_ip")
-		result.Reasons = append(result.Reasons, "La URL usa una dirección IP directa en lugar de un dominio")
+		if ip.To4() == nil {
+			result.Reasons = append(result.Reasons, "La URL usa una dirección IPv6 directa en lugar de un dominio")
+		} else {
+			result.Reasons = append(result.Reasons, "La URL usa una dirección IP directa en lugar de un dominio")
+		}
 	}
 
 	// 6. Subdominios excesivos (más de 3 niveles)
@@ -212,14 +253,119 @@ func (h *HeuristicEngine) analyzeURL(indicators *checkers.Indicators, ctx *check
 			break
 		}
 	}
+
+	// 8. Dominio recién registrado (RDAP)
+	if h.domainAge != nil && indicators.IP != indicators.Domain {
+		h.analyzeDomainAge(ctx, domain, result)
+	}
+
+	// 9. Cadena de redirects
+	if chain := h.analyzeRedirectChain(indicators.RedirectChain); chain != nil {
+		result.Score += chain.Score
+		result.Flags = append(result.Flags, chain.Flags...)
+		result.Reasons = append(result.Reasons, chain.Reasons...)
+		result.RedirectChain = chain.RedirectChain
+	}
+}
+
+// analyzeRedirectChain puntúa la ofuscación por saltos: más de 3 saltos, acortadores en la
+// cadena y cambios de TLD entre saltos. nil si la URL no redirige.
+// El cambio de país/ASN entre saltos no se puntúa: el servicio no tiene base de datos GeoIP/ASN.
+func (h *HeuristicEngine) analyzeRedirectChain(chain []string) *HeuristicResult {
+	if len(chain) < 2 {
+		return nil
+	}
+
+	result := &HeuristicResult{
+		Reasons:       []string{},
+		Flags:         []string{},
+		DomainAgeDays: -1,
+		RedirectChain: chain,
+	}
+
+	hosts := make([]string, 0, len(chain))
+	for _, hop := range chain {
+		if parsed, err := url.Parse(hop); err == nil && parsed.Hostname() != "" {
+			hosts = append(hosts, strings.ToLower(parsed.Hostname()))
+		}
+	}
+
+	if hops := len(chain) - 1; hops > 3 {
+		result.Score += 15
+		result.Flags = append(result.Flags, "long_redirect_chain")
+		result.Reasons = append(result.Reasons, fmt.Sprintf("La URL pasa por %d redirecciones antes de llegar a su destino", hops))
+	}
+
+	for _, host := range hosts {
+		if checkers.IsShortener(host) {
+			result.Score += 10
+			result.Flags = append(result.Flags, "redirect_via_shortener")
+			result.Reasons = append(result.Reasons, fmt.Sprintf("La cadena de redirecciones pasa por un acortador de URLs (%s)", host))
+			break
+		}
+	}
+
+	for i := 1; i < len(hosts); i++ {
+		from, to := hostTLD(hosts[i-1]), hostTLD(hosts[i])
+		if from != "" && to != "" && from != to {
+			result.Score += 20
+			result.Flags = append(result.Flags, "redirect_tld_change")
+			result.Reasons = append(result.Reasons, fmt.Sprintf("La URL redirige de un dominio .%s a otro .%s", from, to))
+			break
+		}
+	}
+
+	return result
+}
+
+// hostTLD último nivel del host ("" si es una IP o no tiene punto)
+func hostTLD(host string) string {
+	if net.ParseIP(host) != nil {
+		return ""
+	}
+	idx := strings.LastIndex(host, ".")
+	if idx < 0 {
+		return ""
+	}
+	return host[idx+1:]
+}
+
+// analyzeDomainAge puntúa dominios registrados recientemente
+func (h *HeuristicEngine) analyzeDomainAge(ctx context.Context, domain string, result *HeuristicResult) {
+	age, err := h.domainAge.Lookup(ctx, domain)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", domain).Msg("[Heuristics] Domain age lookup failed")
+		return
+	}
+
+	days := age.AgeDays()
+	if days < 0 {
+		return
+	}
+	result.DomainAgeDays = days
+
+	switch {
+	case days < 7:
+		result.Score += 40
+		result.Flags = append(result.Flags, "new_domain")
+		result.Reasons = append(result.Reasons, fmt.Sprintf("El dominio se registró hace %d días (los dominios recién creados son habituales en phishing)", days))
+	case days < 30:
+		result.Score += 20
+		result.Flags = append(result.Flags, "recent_domain")
+		result.Reasons = append(result.Reasons, fmt.Sprintf("El dominio es muy reciente (registrado hace %d días)", days))
+	case days < 90:
+		result.Score += 10
+		result.Flags = append(result.Flags, "young_domain")
+		result.Reasons = append(result.Reasons, fmt.Sprintf("El dominio tiene menos de 3 meses (registrado hace %d días)", days))
+	}
 }
 
 // analyzeEmail analiza heurísticas específicas de emails
 func (h *HeuristicEngine) analyzeEmail(indicators *checkers.Indicators, ctx *checkers.AnalysisContext, result *HeuristicResult) {
 	domain := strings.ToLower(indicators.EmailDomain)
 
-	// 1. TLD sospechoso
-	if points, isSuspicious := h.suspiciousTLDs[indicators.TLD]; isSuspicious {
+	// 1. TLD sospechoso (tabla compartida con el analizador legacy, ver internal/tldrisk)
+	if points := tldrisk.Default.Points(indicators.TLD); points > 0 {
 		result.Score += points
 		result.Flags = append(result.Flags, "suspicious_tld")
 		result.Reasons = append(result.Reasons, fmt.Sprintf("El email usa un dominio con TLD sospechoso (.%s)", indicators.TLD))
@@ -235,7 +381,24 @@ func (h *HeuristicEngine) analyzeEmail(indicators *checkers.Indicators, ctx *che
 		}
 	}
 
-	// 3. Context mismatch
+	// 3. Dominio a una letra de un dominio oficial (santanderr.com, vodafne.es), salvo que
+	// ya cuente como typosquatting de un banco: es la misma señal
+	if !contains(result.Flags, "email_typosquatting") {
+		if legit := h.officialDomainTypo(domain); legit != "" {
+			result.Score += 35
+			result.Flags = append(result.Flags, "email_domain_typo")
+			result.Reasons = append(result.Reasons, fmt.Sprintf("El dominio del email se diferencia en una letra del dominio oficial %s", legit))
+		}
+	}
+
+	// 4. Buzón de servicio mal escrito (supportt@, seurity@, suport@)
+	if service := h.localPartTypo(indicators.EmailUser); service != "" {
+		result.Score += 25
+		result.Flags = append(result.Flags, "email_local_typo")
+		result.Reasons = append(result.Reasons, fmt.Sprintf("La dirección imita un buzón de servicio (%s@) con una errata", service))
+	}
+
+	// 5. Context mismatch
 	if ctx != nil && ctx.ClaimedSender != "" {
 		claimedLower := strings.ToLower(ctx.ClaimedSender)
 		for brand, domains := range h.spanishBanks {
@@ -257,18 +420,11 @@ func (h *HeuristicEngine) analyzeEmail(indicators *checkers.Indicators, ctx *che
 		}
 	}
 
-	// 4. Dominios de email desechables comunes
-	disposableDomains := []string{
-		"tempmail.com", "guerrillamail.com", "10minutemail.com", "mailinator.com",
-		"throwaway.email", "temp-mail.org", "fakeinbox.com", "trashmail.com",
-	}
-	for _, disposable := range disposableDomains {
-		if domain == disposable || strings.HasSuffix(domain, "."+disposable) {
-			result.Score += 30
-			result.Flags = append(result.Flags, "disposable_email")
-			result.Reasons = append(result.Reasons, "Esta dirección de email parece ser temporal/desechable")
-			break
-		}
+	// 6. Dominios de email desechables (lista compartida, incluye subdominios)
+	if disposable.Default.Contains(domain) {
+		result.Score += 30
+		result.Flags = append(result.Flags, "disposable_email")
+		result.Reasons = append(result.Reasons, "Esta dirección de email parece ser temporal/desechable")
 	}
 }
 
@@ -276,17 +432,14 @@ func (h *HeuristicEngine) analyzeEmail(indicators *checkers.Indicators, ctx *che
 func (h *HeuristicEngine) analyzePhone(indicators *checkers.Indicators, ctx *checkers.AnalysisContext, result *HeuristicResult) {
 	// 1. Número premium
 	if indicators.IsPremium {
-		// Determinar qué prefijo es
-		prefix := ""
-		for _, p := range h.premiumPrefixes {
-			if strings.HasPrefix(indicators.NationalNum, p) {
-				prefix = p
-				break
-			}
+		reason := "Este es un número de tarificación adicional. Las llamadas tienen coste elevado."
+		if indicators.Premium != nil {
+			// Ej: "Este es un número de tarificación adicional en México (prefijo 900)"
+			reason = fmt.Sprintf("Este es un %s (prefijo %s). Las llamadas tienen coste elevado.", indicators.Premium.Describe(), indicators.Premium.Prefix)
 		}
 		result.Score += 50
 		result.Flags = append(result.Flags, "premium_number")
-		result.Reasons = append(result.Reasons, fmt.Sprintf("Este es un número de tarificación adicional (prefijo %s). Las llamadas tienen coste elevado.", prefix))
+		result.Reasons = append(result.Reasons, reason)
 	}
 
 	// 2. Context mismatch: dice ser banco/empresa pero número no parece oficial
@@ -351,6 +504,77 @@ func (h *HeuristicEngine) isTyposquatting(domain, brand string, legitDomains []s
 	return false
 }
 
+// officialDomainTypo retorna el dominio oficial (de whitelist_domains o, sin carga, banco o
+// telco) del que domain se diferencia en una sola inserción, borrado o sustitución ("" si
+// ninguno o si domain es oficial)
+func (h *HeuristicEngine) officialDomainTypo(domain string) string {
+	var official []string
+	if loaded := h.officialDomains.Load(); loaded != nil {
+		official = *loaded
+	} else {
+		for _, brands := range []map[string][]string{h.spanishBanks, h.spanishTelcos} {
+			for _, domains := range brands {
+				official = append(official, domains...)
+			}
+		}
+		sort.Strings(official)
+	}
+	if i := sort.SearchStrings(official, domain); i < len(official) && official[i] == domain {
+		return ""
+	}
+
+	for _, legit := range official {
+		// Dominios muy cortos (ing.es) tienen demasiados vecinos legítimos
+		if len(legit) < 8 {
+			continue
+		}
+		// Con longitudes que difieren en más de uno la distancia no puede ser 1
+		if diff := len(legit) - len(domain); diff > 1 || diff < -1 {
+			continue
+		}
+		if levenshteinDistance(domain, legit) == 1 {
+			return legit
+		}
+	}
+	return ""
+}
+
+// localPartTypo retorna el buzón de servicio que imita el local part con 1-2 erratas
+// ("" si no imita ninguno o coincide exactamente). Se compara la parte completa sin
+// separadores y cada segmento: "seurity-team" -> seurity.
+func (h *HeuristicEngine) localPartTypo(local string) string {
+	local = strings.ToLower(local)
+	if idx := strings.IndexByte(local, '+'); idx >= 0 {
+		local = local[:idx]
+	}
+
+	isSeparator := func(r rune) bool { return r == '.' || r == '-' || r == '_' }
+	candidates := append(strings.FieldsFunc(local, isSeparator), strings.Join(strings.FieldsFunc(local, isSeparator), ""))
+
+	match := ""
+	for _, service := range h.serviceLocalParts {
+		// En nombres cortos (info, help) 2 erratas dan falsos positivos (hello -> help)
+		maxDistance := 1
+		if len(service) >= 6 {
+			maxDistance = 2
+		}
+		for _, candidate := range candidates {
+			if len(candidate) < 3 {
+				continue
+			}
+			distance := levenshteinDistance(candidate, service)
+			if distance == 0 {
+				// Buzón legítimo (no-reply@, support@)
+				return ""
+			}
+			if distance <= maxDistance && match == "" {
+				match = service
+			}
+		}
+	}
+	return match
+}
+
 // levenshteinDistance calcula la distancia de Levenshtein entre dos strings
 func levenshteinDistance(s1, s2 string) int {
 	if len(s1) == 0 {
@@ -394,7 +618,7 @@ func (h *HeuristicEngine) ToCheckResult(result *HeuristicResult) *checkers.Check
 
 	// Determinar tipo de amenaza basado en flags
 	threatType := checkers.ThreatTypeUnknown
-	if contains(result.Flags, "typosquatting_bank") || contains(result.Flags, "email_typosquatting") {
+	if contains(result.Flags, "typosquatting_bank") || contains(result.Flags, "email_typosquatting") || contains(result.Flags, "email_domain_typo") {
 		threatType = checkers.ThreatTypePhishing
 	} else if contains(result.Flags, "premium_number") {
 		threatType = "scam"
@@ -411,17 +635,28 @@ func (h *HeuristicEngine) ToCheckResult(result *HeuristicResult) *checkers.Check
 		confidence = 0.3
 	}
 
+	rawData := map[string]interface{}{
+		"score":        result.Score,
+		"reasons":      result.Reasons,
+		"context_hits": result.ContextHits,
+	}
+	if result.DomainAgeDays >= 0 {
+		rawData["domain_age_days"] = result.DomainAgeDays
+	}
+	if result.PhoneType != "" {
+		rawData["phone_type"] = result.PhoneType
+	}
+	if len(result.RedirectChain) > 0 {
+		rawData["redirect_chain"] = result.RedirectChain
+	}
+
 	return &checkers.CheckResult{
 		Source:     "heuristics",
 		Found:      found,
 		ThreatType: threatType,
 		Confidence: confidence,
 		Tags:       result.Flags,
-		RawData: map[string]interface{}{
-			"score":        result.Score,
-			"reasons":      result.Reasons,
-			"context_hits": result.ContextHits,
-		},
+		RawData:    rawData,
 	}
 }
 
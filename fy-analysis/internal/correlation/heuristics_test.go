@@ -0,0 +1,132 @@
+package correlation
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+// whitelistDB BD en memoria con los dominios de whitelist_domains (err falla la consulta)
+type whitelistDB struct {
+	domains []string
+	err     error
+}
+
+func (c *whitelistDB) Connect(context.Context) (driver.Conn, error) { return &whitelistConn{c}, nil }
+func (c *whitelistDB) Driver() driver.Driver                        { return nil }
+
+type whitelistConn struct{ db *whitelistDB }
+
+func (c *whitelistConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (c *whitelistConn) Close() error                        { return nil }
+func (c *whitelistConn) Begin() (driver.Tx, error)           { return nil, errors.New("not supported") }
+
+func (c *whitelistConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if c.db.err != nil {
+		return nil, c.db.err
+	}
+	return &whitelistRows{domains: c.db.domains}, nil
+}
+
+type whitelistRows struct{ domains []string }
+
+func (r *whitelistRows) Columns() []string { return []string{"domain"} }
+func (r *whitelistRows) Close() error      { return nil }
+func (r *whitelistRows) Next(dest []driver.Value) error {
+	if len(r.domains) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.domains[0]
+	r.domains = r.domains[1:]
+	return nil
+}
+
+func analyzeEmailDomain(h *HeuristicEngine, domain string) *HeuristicResult {
+	return h.Analyze(context.Background(), &checkers.Indicators{
+		InputType:   checkers.InputTypeEmail,
+		EmailUser:   "clientes",
+		EmailDomain: domain,
+	}, nil)
+}
+
+func hasFlag(result *HeuristicResult, flag string) bool {
+	return contains(result.Flags, flag)
+}
+
+func TestEmailDomainTypoFallback(t *testing.T) {
+	// Sin whitelist cargada se compara con los dominios de bancos y telcos
+	h := NewHeuristicEngine()
+
+	result := analyzeEmailDomain(h, "vodafne.es")
+	if !hasFlag(result, "email_domain_typo") || result.Score != 35 {
+		t.Errorf("vodafne.es: flags %v, score %d; want email_domain_typo +35", result.Flags, result.Score)
+	}
+
+	result = analyzeEmailDomain(h, "vodafone.es")
+	if hasFlag(result, "email_domain_typo") {
+		t.Errorf("vodafone.es is official but got flags %v", result.Flags)
+	}
+}
+
+func TestEmailDomainTypoNoStacking(t *testing.T) {
+	// santanderr.com ya es typosquatting de banco: no suma además email_domain_typo
+	h := NewHeuristicEngine()
+
+	result := analyzeEmailDomain(h, "santanderr.com")
+	if !hasFlag(result, "email_typosquatting") {
+		t.Fatalf("santanderr.com: flags %v, want email_typosquatting", result.Flags)
+	}
+	if hasFlag(result, "email_domain_typo") || result.Score != 40 {
+		t.Errorf("santanderr.com: flags %v, score %d; want only email_typosquatting +40", result.Flags, result.Score)
+	}
+}
+
+func TestEmailDomainTypoWhitelist(t *testing.T) {
+	h := NewHeuristicEngine()
+	db := sql.OpenDB(&whitelistDB{domains: []string{"correos.es", "agenciatributaria.gob.es", "vodafone.es", "vodafone.com"}})
+	defer db.Close()
+
+	if err := h.LoadOfficialDomains(context.Background(), db); err != nil {
+		t.Fatalf("LoadOfficialDomains: %v", err)
+	}
+
+	tests := []struct {
+		domain string
+		typo   bool
+	}{
+		{"corre0s.es", true},                 // Sustitución
+		{"correoss.es", true},                // Inserción
+		{"agenciatributaria.gob.com", false}, // A más de una letra
+		{"vodafne.es", true},
+		{"correos.es", false}, // Oficial
+		{"gmail.com", false},
+	}
+	for _, tt := range tests {
+		result := analyzeEmailDomain(h, tt.domain)
+		if got := hasFlag(result, "email_domain_typo"); got != tt.typo {
+			t.Errorf("%s: email_domain_typo = %v, want %v (flags %v)", tt.domain, got, tt.typo, result.Flags)
+		}
+	}
+
+	// Un fallo al recargar mantiene la lista anterior
+	failing := sql.OpenDB(&whitelistDB{err: errors.New("connection refused")})
+	defer failing.Close()
+	if err := h.LoadOfficialDomains(context.Background(), failing); err == nil {
+		t.Fatal("LoadOfficialDomains with a failing DB returned nil")
+	}
+	if !hasFlag(analyzeEmailDomain(h, "corre0s.es"), "email_domain_typo") {
+		t.Error("whitelist lost after a failed reload")
+	}
+
+	// Una whitelist vacía tampoco reemplaza la anterior
+	empty := sql.OpenDB(&whitelistDB{})
+	defer empty.Close()
+	if err := h.LoadOfficialDomains(context.Background(), empty); err == nil {
+		t.Error("LoadOfficialDomains with an empty whitelist returned nil")
+	}
+}
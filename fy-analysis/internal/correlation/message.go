@@ -0,0 +1,111 @@
+package correlation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// MessageResult resultado de las heurísticas a nivel de mensaje completo
+type MessageResult struct {
+	Score        int      // Puntos de riesgo del texto (sin contar los indicadores)
+	Reasons      []string // Razones en español
+	Flags        []string // Flags técnicos
+	Keywords     []string // Palabras clave encontradas en el texto
+	ClaimedBrand string   // Marca que el mensaje dice representar ("" si ninguna)
+}
+
+// urgencyKeywords expresiones de urgencia/amenaza típicas de smishing (sin tildes)
+var urgencyKeywords = []string{
+	"urgente", "inmediatamente", "de inmediato", "ultimo aviso", "24 horas", "48 horas",
+	"hoy mismo", "bloqueada", "bloqueado", "suspendida", "suspendido", "caducada",
+	"cancelada", "retenido", "retenida", "pendiente de pago", "actividad sospechosa",
+	"acceso no autorizado", "verifique", "verifica tu", "confirme sus datos",
+}
+
+// paymentKeywords peticiones de pago o datos de tarjeta (sin tildes)
+var paymentKeywords = []string{
+	"pague", "pagar", "abone", "abonar", "tasas", "aduana", "gastos de envio",
+	"importe", "tarjeta", "cvv", "bizum", "transferencia", "reembolso", "€", "eur ",
+}
+
+// serviceBrands organismos y empresas de paquetería suplantados habitualmente
+var serviceBrands = []string{
+	"correos", "dhl", "seur", "mrw", "gls", "ups", "fedex", "amazon",
+	"hacienda", "agencia tributaria", "dgt", "seguridad social",
+}
+
+// AnalyzeMessage puntúa el texto de un mensaje completo: urgencia, petición de pago
+// y suplantación de marcas (bancos, telcos, paquetería y organismos)
+func (h *HeuristicEngine) AnalyzeMessage(text string) *MessageResult {
+	result := &MessageResult{
+		Reasons:  []string{},
+		Flags:    []string{},
+		Keywords: []string{},
+	}
+
+	normalized := " " + foldAccents(strings.ToLower(text)) + " "
+
+	// 1. Urgencia
+	if found := findKeywords(normalized, urgencyKeywords); len(found) > 0 {
+		result.Score += 15
+		result.Flags = append(result.Flags, "message_urgency")
+		result.Keywords = append(result.Keywords, found...)
+		result.Reasons = append(result.Reasons, "El mensaje mete prisa o amenaza con bloqueos (táctica típica de estafa)")
+	}
+
+	// 2. Petición de pago
+	if found := findKeywords(normalized, paymentKeywords); len(found) > 0 {
+		result.Score += 20
+		result.Flags = append(result.Flags, "message_payment_request")
+		result.Keywords = append(result.Keywords, found...)
+		result.Reasons = append(result.Reasons, "El mensaje pide un pago o datos de tarjeta")
+	}
+
+	// 3. Suplantación: el mensaje menciona una marca conocida (palabra completa: "ing" no casa con "ingrese")
+	words := strings.FieldsFunc(normalized, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+	wordSet := make(map[string]bool, len(words))
+	for _, word := range words {
+		wordSet[word] = true
+		if _, isBank := h.spanishBanks[word]; isBank && result.ClaimedBrand == "" {
+			result.ClaimedBrand = word
+		}
+		if _, isTelco := h.spanishTelcos[word]; isTelco && result.ClaimedBrand == "" {
+			result.ClaimedBrand = word
+		}
+	}
+	if result.ClaimedBrand == "" {
+		for _, brand := range serviceBrands {
+			if wordSet[brand] || (strings.Contains(brand, " ") && strings.Contains(normalized, " "+brand+" ")) {
+				result.ClaimedBrand = brand
+				break
+			}
+		}
+	}
+	if result.ClaimedBrand != "" {
+		result.Keywords = append(result.Keywords, result.ClaimedBrand)
+		if result.Score > 0 {
+			// Marca + urgencia/pago: patrón clásico de suplantación
+			result.Score += 20
+			result.Flags = append(result.Flags, "message_impersonation")
+			result.Reasons = append(result.Reasons, "El mensaje dice venir de "+result.ClaimedBrand+" y pide actuar o pagar: verifica siempre por los canales oficiales")
+		}
+	}
+
+	return result
+}
+
+// findKeywords retorna las palabras clave presentes en el texto normalizado
+func findKeywords(normalized string, keywords []string) []string {
+	var found []string
+	for _, kw := range keywords {
+		if strings.Contains(normalized, kw) {
+			found = append(found, strings.TrimSpace(kw))
+		}
+	}
+	return found
+}
+
+// foldAccents quita tildes y diéresis para comparar palabras clave
+func foldAccents(s string) string {
+	return strings.NewReplacer("á", "a", "é", "e", "í", "i", "ó", "o", "ú", "u", "ü", "u").Replace(s)
+}
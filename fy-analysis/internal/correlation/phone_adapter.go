@@ -0,0 +1,69 @@
+package correlation
+
+import (
+	"context"
+
+	"github.com/trackfy/fy-analysis/internal/analyzer/phone"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/models"
+)
+
+// PhoneHeuristicAdapter integra las heurísticas de phone.Analyzer (país, VoIP, contexto,
+// patrones) en el análisis del Engine, que solo aplicaba las de HeuristicEngine.analyzePhone
+type PhoneHeuristicAdapter struct {
+	analyzer *phone.Analyzer
+}
+
+// NewPhoneHeuristicAdapter crea el adaptador
+func NewPhoneHeuristicAdapter() *PhoneHeuristicAdapter {
+	return &PhoneHeuristicAdapter{
+		analyzer: phone.NewAnalyzer(),
+	}
+}
+
+// SetCarrierLookup habilita el lookup de operador en las heurísticas (ENABLE_CARRIER_LOOKUP)
+func (a *PhoneHeuristicAdapter) SetCarrierLookup(service *phone.CarrierLookupService) {
+	a.analyzer.SetCarrierLookup(service)
+}
+
+// Translate convierte el resultado de phone.Analyzer (score 0-1) a HeuristicResult (puntos 0-100)
+func (a *PhoneHeuristicAdapter) Translate(heuristic phone.HeuristicResult, info *models.PhoneInfo) *HeuristicResult {
+	result := &HeuristicResult{
+		Score:         int(heuristic.Score * 100),
+		Reasons:       append([]string{}, heuristic.Reasons...),
+		Flags:         append([]string{}, heuristic.Flags...),
+		ContextHits:   []string{},
+		DomainAgeDays: -1,
+	}
+	if info != nil {
+		result.PhoneType = info.Type
+	}
+	return result
+}
+
+// Apply ejecuta las heurísticas de phone.Analyzer sobre el número y suma su resultado a
+// result (sin repetir flags ni razones). Retorna la información del número.
+func (a *PhoneHeuristicAdapter) Apply(ctx context.Context, indicators *checkers.Indicators, analysisCtx *checkers.AnalysisContext, result *HeuristicResult) *models.PhoneInfo {
+	text := ""
+	if analysisCtx != nil {
+		text = analysisCtx.OriginalText
+	}
+
+	info, heuristic := a.analyzer.Heuristics(ctx, indicators.Normalized, "", text)
+	translated := a.Translate(heuristic, info)
+
+	result.Score += translated.Score
+	for _, flag := range translated.Flags {
+		if !contains(result.Flags, flag) {
+			result.Flags = append(result.Flags, flag)
+		}
+	}
+	for _, reason := range translated.Reasons {
+		if !contains(result.Reasons, reason) {
+			result.Reasons = append(result.Reasons, reason)
+		}
+	}
+	result.PhoneType = translated.PhoneType
+
+	return info
+}
@@ -0,0 +1,198 @@
+// Package disposable mantiene la lista de dominios de email desechables/temporales
+// compartida por el analizador de emails y las heurísticas del engine.
+package disposable
+
+import (
+	"bufio"
+	"context"
+	_ "embed"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// minRefreshRatio proporción mínima de dominios respecto a la lista actual para aceptar una descarga
+	minRefreshRatio = 0.5
+	maxDownloadSize = 10 << 20
+	downloadTimeout = 60 * time.Second
+)
+
+//go:embed domains.txt
+var bundledDomains string
+
+// Default lista compartida, cargada al arrancar con la lista incluida en el binario
+var Default = New()
+
+// Stats estado de la lista para GetStatus
+type Stats struct {
+	Count       int       `json:"count"`
+	Source      string    `json:"source"` // bundled o la URL de la última descarga aceptada
+	LastRefresh time.Time `json:"last_refresh,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+}
+
+// List conjunto de dominios desechables protegido por RWMutex
+type List struct {
+	mu          sync.RWMutex
+	domains     map[string]struct{}
+	source      string
+	lastRefresh time.Time
+	lastError   string
+
+	client   *http.Client
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// New crea una lista con los dominios incluidos en el binario
+func New() *List {
+	domains, _ := parse(strings.NewReader(bundledDomains))
+	return &List{
+		domains: domains,
+		source:  "bundled",
+		client:  &http.Client{Timeout: downloadTimeout},
+		stopCh:  make(chan struct{}),
+	}
+}
+
+// Contains indica si el dominio, o alguno de sus dominios padre, es desechable
+// (ej: "x.mailinator.com" -> mailinator.com)
+func (l *List) Contains(domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+	if domain == "" {
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for {
+		if _, ok := l.domains[domain]; ok {
+			return true
+		}
+		idx := strings.IndexByte(domain, '.')
+		if idx < 0 {
+			return false
+		}
+		domain = domain[idx+1:]
+	}
+}
+
+// Stats retorna el número de dominios cargados y la última actualización
+func (l *List) Stats() Stats {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return Stats{
+		Count:       len(l.domains),
+		Source:      l.source,
+		LastRefresh: l.lastRefresh,
+		LastError:   l.lastError,
+	}
+}
+
+// StartRefresh descarga la lista de url ahora y cada interval hasta Stop.
+// Con url vacía la lista se queda con los dominios incluidos en el binario.
+func (l *List) StartRefresh(ctx context.Context, url string, interval time.Duration) {
+	if url == "" || interval <= 0 {
+		return
+	}
+
+	log.Info().
+		Str("url", url).
+		Dur("interval", interval).
+		Msg("[Disposable] Starting disposable domains refresh")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := l.Refresh(ctx, url); err != nil {
+				log.Warn().Err(err).Msg("[Disposable] Refresh failed, keeping previous list")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop detiene el refresco periódico
+func (l *List) Stop() {
+	l.stopOnce.Do(func() { close(l.stopCh) })
+}
+
+// Refresh descarga la lista y la sustituye solo si la descarga es válida;
+// ante cualquier error se mantiene la lista anterior
+func (l *List) Refresh(ctx context.Context, url string) error {
+	domains, err := l.download(ctx, url)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if err == nil && float64(len(domains)) < float64(len(l.domains))*minRefreshRatio {
+		err = fmt.Errorf("downloaded list too small: %d domains (current %d)", len(domains), len(l.domains))
+	}
+	if err != nil {
+		l.lastError = err.Error()
+		return err
+	}
+
+	previous := len(l.domains)
+	l.domains = domains
+	l.source = url
+	l.lastRefresh = time.Now()
+	l.lastError = ""
+
+	log.Info().
+		Int("domains", len(domains)).
+		Int("previous", previous).
+		Msg("[Disposable] Disposable domains list refreshed")
+
+	return nil
+}
+
+// download descarga y parsea la lista remota
+func (l *List) download(ctx context.Context, url string) (map[string]struct{}, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("download returned status %d", resp.StatusCode)
+	}
+
+	return parse(io.LimitReader(resp.Body, maxDownloadSize))
+}
+
+// parse lee un dominio por línea, ignorando vacías y comentarios (#)
+func parse(r io.Reader) (map[string]struct{}, error) {
+	domains := make(map[string]struct{})
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line == "" || strings.HasPrefix(line, "#") || !strings.Contains(line, ".") || strings.ContainsAny(line, " \t/@") {
+			continue
+		}
+		domains[strings.TrimSuffix(line, ".")] = struct{}{}
+	}
+	return domains, scanner.Err()
+}
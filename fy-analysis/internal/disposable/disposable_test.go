@@ -0,0 +1,106 @@
+package disposable
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestContainsSubdomains(t *testing.T) {
+	list := New()
+
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"mailinator.com", true},
+		{"mail.mailinator.com", true},
+		{"a.b.mailinator.com", true},
+		{"MAIL.Mailinator.COM", true},
+		{"mail.mailinator.com.", true},
+		{" guerrillamail.com ", true},
+		{"inbox.guerrillamail.de", true},
+		{"notmailinator.com", false},
+		{"mailinator.com.evil.es", false},
+		{"mailinator", false},
+		{"gmail.com", false},
+		{"mail.google.com", false},
+		{"com", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := list.Contains(tt.domain); got != tt.want {
+			t.Errorf("Contains(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestParseSkipsInvalidLines(t *testing.T) {
+	domains, err := parse(strings.NewReader("# comment\n\nTempMail.ORG\nbad domain.com\nhttp://x.com/\nuser@x.com\nlocalhost\ntrailing.dot.\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(domains) != 2 {
+		t.Errorf("parsed %v, want tempmail.org and trailing.dot", domains)
+	}
+	for _, d := range []string{"tempmail.org", "trailing.dot"} {
+		if _, ok := domains[d]; !ok {
+			t.Errorf("%s missing from %v", d, domains)
+		}
+	}
+}
+
+func TestRefreshKeepsPreviousListOnBadDownload(t *testing.T) {
+	var body string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	list := New()
+	bundled := list.Stats().Count
+
+	// Lista remota válida: sustituye a la incluida y los subdominios se resuelven contra ella
+	var b strings.Builder
+	for i := 0; i < bundled; i++ {
+		fmt.Fprintf(&b, "throwaway%d.test\n", i)
+	}
+	b.WriteString("newthrowaway.io\n")
+	body = b.String()
+	if err := list.Refresh(context.Background(), server.URL); err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if !list.Contains("inbox.newthrowaway.io") || list.Contains("mail.mailinator.com") {
+		t.Error("refreshed list not applied")
+	}
+	stats := list.Stats()
+	if stats.Source != server.URL || stats.LastRefresh.IsZero() || stats.LastError != "" {
+		t.Errorf("stats after refresh = %+v", stats)
+	}
+	count := stats.Count
+
+	bad := map[string]func(){
+		"server error": func() { status, body = http.StatusInternalServerError, "" },
+		"empty":        func() { status, body = http.StatusOK, "" },
+		"truncated":    func() { status, body = http.StatusOK, "only.one\n" },
+	}
+	for name, setup := range bad {
+		setup()
+		if err := list.Refresh(context.Background(), server.URL); err == nil {
+			t.Errorf("%s: Refresh succeeded, want error", name)
+		}
+		stats := list.Stats()
+		if stats.Count != count || stats.LastError == "" {
+			t.Errorf("%s: stats = %+v, want the previous %d domains and an error", name, stats, count)
+		}
+		if !list.Contains("inbox.newthrowaway.io") {
+			t.Errorf("%s: previous list lost", name)
+		}
+	}
+}
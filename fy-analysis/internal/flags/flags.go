@@ -0,0 +1,92 @@
+// Package flags bits de la columna flags (SMALLINT) de las tablas de la DB local.
+//
+// fy-admin tiene una copia de estos bits en flags.go (los módulos se compilan por separado):
+// cualquier cambio se hace en los dos. El bit 0 es "activo" salvo en user_trust_scores,
+// donde es "baneado"; los bits 1-4 dependen de la tabla, los bits 5 y 6 son marcas de
+// analista comunes a todas las tablas de amenazas y el bit 7 lo pone LocalDB.
+package flags
+
+import "fmt"
+
+// Flag bit de la columna flags
+type Flag int16
+
+const (
+	// Active registro activo (threat_*, reported_urls)
+	Active Flag = 1 << 0
+	// Banned usuario baneado del sistema de reportes (user_trust_scores)
+	Banned Flag = 1 << 0
+
+	// threat_domains
+	Verified      Flag = 1 << 1 // Verificado por la fuente
+	Typosquatting Flag = 1 << 2
+	HasSSL        Flag = 1 << 3
+	Parked        Flag = 1 << 4
+
+	// threat_phones
+	Premium Flag = 1 << 1 // Número de tarificación especial
+
+	// Marcas de analista (todas las tablas de amenazas)
+	FalsePositiveCandidate Flag = 1 << 5 // Posible falso positivo: los checkers lo ignoran
+	AnalystVerified        Flag = 1 << 6 // Confirmado por un analista
+
+	// StaleCandidate la confianza decaída por antigüedad (LocalDB) es inferior a 0.1:
+	// candidato a desactivarse por el reaper de registros obsoletos
+	StaleCandidate Flag = 1 << 7
+)
+
+// named nombre legible de un bit en la salida JSON
+type named struct {
+	flag Flag
+	name string
+}
+
+var analystFlags = []named{
+	{FalsePositiveCandidate, "false_positive_candidate"},
+	{AnalystVerified, "analyst_verified"},
+	{StaleCandidate, "stale_candidate"},
+}
+
+// tableFlags bits con nombre de cada tabla
+var tableFlags = map[string][]named{
+	"threat_domains": append([]named{
+		{Active, "active"}, {Verified, "verified"}, {Typosquatting, "typosquatting"},
+		{HasSSL, "has_ssl"}, {Parked, "parked"},
+	}, analystFlags...),
+	"threat_paths":      append([]named{{Active, "active"}}, analystFlags...),
+	"threat_emails":     append([]named{{Active, "active"}}, analystFlags...),
+	"threat_phones":     append([]named{{Active, "active"}, {Premium, "premium"}}, analystFlags...),
+	"reported_urls":     {{Active, "active"}},
+	"user_trust_scores": {{Banned, "banned"}},
+}
+
+// Has indica si value tiene el bit f
+func (f Flag) Has(value int16) bool {
+	return value&int16(f) == int16(f)
+}
+
+// IsSet condición SQL "el bit está puesto" sobre column (p.ej. "td.flags")
+func (f Flag) IsSet(column string) string {
+	return fmt.Sprintf("(%s & %d) = %d", column, f, f)
+}
+
+// IsClear condición SQL "el bit no está puesto" sobre column
+func (f Flag) IsClear(column string) string {
+	return fmt.Sprintf("(%s & %d) = 0", column, f)
+}
+
+// SQL valor del bit como literal SQL (INSERT ... flags)
+func (f Flag) SQL() string {
+	return fmt.Sprintf("%d", f)
+}
+
+// Names nombres de los bits puestos en value según la tabla (bits sin nombre se omiten)
+func Names(table string, value int16) []string {
+	names := []string{}
+	for _, n := range tableFlags[table] {
+		if n.flag.Has(value) {
+			names = append(names, n.name)
+		}
+	}
+	return names
+}
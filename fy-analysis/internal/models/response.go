@@ -85,12 +85,20 @@ type PhoneAnalysisResponse struct {
 
 // PhoneInfo información sobre el número de teléfono
 type PhoneInfo struct {
-	CountryCode  string `json:"country_code"`
-	Country      string `json:"country"`
-	Carrier      string `json:"carrier,omitempty"`
-	Type         string `json:"type"` // mobile, landline, voip, premium, toll_free
-	IsValid      bool   `json:"is_valid"`
-	IsPremiumRate bool  `json:"is_premium_rate"`
+	CountryCode   string       `json:"country_code"`
+	Country       string       `json:"country"`
+	Carrier       *CarrierInfo `json:"carrier,omitempty"` // Solo con ENABLE_CARRIER_LOOKUP
+	Type          string       `json:"type"`              // mobile, landline, voip, premium, toll_free
+	IsValid       bool         `json:"is_valid"`
+	IsPremiumRate bool         `json:"is_premium_rate"`
+	PremiumClass  string       `json:"premium_class,omitempty"` // Ej: "número de tarificación adicional en México"
+}
+
+// CarrierInfo operador del número según Numverify o el HLR configurado
+type CarrierInfo struct {
+	Name     string `json:"name,omitempty"`
+	LineType string `json:"line_type,omitempty"` // mobile, landline, voip, prepaid... (lo que reporte el servicio)
+	Country  string `json:"country,omitempty"`   // ISO 3166-1 alfa-2 del operador
 }
 
 // BatchAnalysisResponse representa la respuesta de análisis en lote
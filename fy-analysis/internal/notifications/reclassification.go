@@ -0,0 +1,113 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/pkg/signature"
+)
+
+const (
+	// reclassificationPath webhook de api-gateway que avisa por push a quien analizó el input
+	reclassificationPath    = "/internal/webhooks/reclassification"
+	reclassificationTimeout = 10 * time.Second
+)
+
+// Motivos de una reclasificación (Reason)
+const (
+	ReasonCommunityReports = "community_reports"
+	ReasonFeed             = "feed"
+	ReasonManual           = "manual"
+)
+
+var reclassificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "reclassification_webhooks_total",
+	Help: "Reclasificaciones notificadas a api-gateway por resultado (sent, failed)",
+}, []string{"status"})
+
+// Reclassification un input pasó a considerarse peligroso (mismo JSON que espera api-gateway)
+type Reclassification struct {
+	InputType  string `json:"input_type"` // url, email, phone
+	InputHash  string `json:"input_hash"` // hex(SHA-256(lower(valor)))
+	Verdict    string `json:"verdict"`
+	ThreatType string `json:"threat_type,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// NewReclassification evento "dangerous" de un valor tal y como lo analizaron los usuarios
+func NewReclassification(inputType, value, threatType, reason string) Reclassification {
+	hash := sha256.Sum256([]byte(strings.ToLower(value)))
+	return Reclassification{
+		InputType:  inputType,
+		InputHash:  hex.EncodeToString(hash[:]),
+		Verdict:    "dangerous",
+		ThreatType: threatType,
+		Reason:     reason,
+	}
+}
+
+// ReclassificationNotifier envía las reclasificaciones al webhook interno de api-gateway,
+// firmadas con INTERNAL_SIGNING_SECRET como el resto de peticiones internas
+type ReclassificationNotifier struct {
+	gatewayURL    string
+	signingSecret string
+	client        *http.Client
+}
+
+// NewReclassificationNotifier crea el notificador (gatewayURL sin path: http://api-gateway:8080)
+func NewReclassificationNotifier(gatewayURL, signingSecret string) *ReclassificationNotifier {
+	return &ReclassificationNotifier{
+		gatewayURL:    strings.TrimRight(gatewayURL, "/"),
+		signingSecret: signingSecret,
+		client:        &http.Client{Timeout: reclassificationTimeout},
+	}
+}
+
+// NotifyReclassification envía el evento; api-gateway responde en cuanto lo acepta y envía
+// las alertas en segundo plano
+func (n *ReclassificationNotifier) NotifyReclassification(ctx context.Context, event Reclassification) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.gatewayURL+reclassificationPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := signature.SignRequest(req, n.signingSecret); err != nil {
+		return err
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		reclassificationsSent.WithLabelValues("failed").Inc()
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		reclassificationsSent.WithLabelValues("failed").Inc()
+		return fmt.Errorf("api-gateway returned %d", resp.StatusCode)
+	}
+
+	reclassificationsSent.WithLabelValues("sent").Inc()
+	log.Debug().
+		Str("input_type", event.InputType).
+		Str("reason", event.Reason).
+		Msg("[Reclassification] Event sent to api-gateway")
+	return nil
+}
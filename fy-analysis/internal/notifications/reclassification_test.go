@@ -0,0 +1,63 @@
+package notifications
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/trackfy/pkg/signature"
+)
+
+func TestNotifyReclassification(t *testing.T) {
+	const secret = "internal-secret"
+	var received Reclassification
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != reclassificationPath {
+			t.Errorf("path = %s, want %s", r.URL.Path, reclassificationPath)
+		}
+		body, _ := io.ReadAll(r.Body)
+		want := signature.Sign(secret, r.Method, r.URL.RequestURI(),
+			r.Header.Get(signature.HeaderTimestamp), r.Header.Get(signature.HeaderNonce), body)
+		if got := r.Header.Get(signature.HeaderSignature); got != want {
+			t.Errorf("signature = %q, want %q", got, want)
+		}
+		if err := json.Unmarshal(body, &received); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	notifier := NewReclassificationNotifier(server.URL+"/", secret)
+	event := NewReclassification("url", "HTTP://Bbva-Clientes.xyz/login", "phishing", ReasonCommunityReports)
+	if err := notifier.NotifyReclassification(context.Background(), event); err != nil {
+		t.Fatalf("NotifyReclassification: %v", err)
+	}
+
+	// api-gateway guarda SHA-256(lower(valor)) de cada análisis
+	hash := sha256.Sum256([]byte("http://bbva-clientes.xyz/login"))
+	if received.InputHash != hex.EncodeToString(hash[:]) {
+		t.Errorf("input_hash = %s, want the hash of the lowercased URL", received.InputHash)
+	}
+	if received.Verdict != "dangerous" || received.Reason != ReasonCommunityReports || received.ThreatType != "phishing" {
+		t.Errorf("event = %+v", received)
+	}
+}
+
+func TestNotifyReclassificationRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	notifier := NewReclassificationNotifier(server.URL, "wrong-secret")
+	if err := notifier.NotifyReclassification(context.Background(), NewReclassification("url", "http://x.top", "", ReasonFeed)); err == nil {
+		t.Fatal("NotifyReclassification succeeded on a 401")
+	}
+}
@@ -0,0 +1,353 @@
+package notifications
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// EventThreatDetected análisis con risk score >= MinRiskScore del webhook
+const EventThreatDetected = "threat_detected"
+
+const (
+	// SignatureHeader HMAC-SHA256 del body con el secreto del webhook: "sha256=<hex>"
+	SignatureHeader = "X-Trackfy-Signature"
+
+	webhookMaxAttempts    = 3
+	webhookInitialBackoff = 2 * time.Second // 2s, 4s
+	webhookTimeout        = 10 * time.Second
+	webhookQueueSize      = 1000
+	webhookWorkers        = 4
+	webhookMaxErrorLen    = 500
+)
+
+var webhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "webhook_deliveries_total",
+	Help: "Notificaciones de webhooks por resultado (delivered, failed, dropped con la cola llena)",
+}, []string{"webhook", "status"})
+
+// WebhookConfig webhook de webhooks.yaml
+type WebhookConfig struct {
+	Name         string   `yaml:"name"` // Por defecto el host de la URL (métricas y webhook_deliveries)
+	URL          string   `yaml:"url"`
+	Secret       string   `yaml:"secret"`
+	MinRiskScore int      `yaml:"min_risk_score"`
+	EventTypes   []string `yaml:"event_types"` // Vacío = todos los eventos
+}
+
+// wants indica si el webhook recibe el evento
+func (c *WebhookConfig) wants(event *ThreatEvent) bool {
+	if event.RiskScore < c.MinRiskScore {
+		return false
+	}
+	if len(c.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range c.EventTypes {
+		if t == event.Event {
+			return true
+		}
+	}
+	return false
+}
+
+type webhooksFile struct {
+	Webhooks []WebhookConfig `yaml:"webhooks"`
+}
+
+// LoadWebhookConfigs lee los webhooks de un YAML (clave webhooks)
+func LoadWebhookConfigs(path string) ([]WebhookConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhooks: %w", err)
+	}
+
+	var file webhooksFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid webhooks: %w", err)
+	}
+	for i := range file.Webhooks {
+		hook := &file.Webhooks[i]
+		u, err := url.Parse(hook.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("webhook %d: url must be an absolute http(s) URL", i)
+		}
+		if hook.Secret == "" {
+			return nil, fmt.Errorf("webhook %d: secret is required", i)
+		}
+		if hook.MinRiskScore < 0 || hook.MinRiskScore > 100 {
+			return nil, fmt.Errorf("webhook %d: min_risk_score must be between 0 and 100", i)
+		}
+		for _, t := range hook.EventTypes {
+			if t != EventThreatDetected {
+				return nil, fmt.Errorf("webhook %d: unknown event type %q", i, t)
+			}
+		}
+		if hook.Name == "" {
+			hook.Name = u.Host
+		}
+	}
+	return file.Webhooks, nil
+}
+
+// ThreatEvent body de la notificación
+type ThreatEvent struct {
+	Event       string    `json:"event"`
+	RiskScore   int       `json:"risk_score"`
+	RiskLevel   string    `json:"risk_level"`
+	Input       string    `json:"input"`
+	InputType   string    `json:"input_type"`
+	ThreatTypes []string  `json:"threat_types"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// delivery notificación en cola para un webhook
+type delivery struct {
+	webhook *WebhookConfig
+	event   string
+	score   int
+	body    []byte
+}
+
+// WebhookNotifier envía en background los eventos a los webhooks configurados. Cada envío
+// se reintenta hasta webhookMaxAttempts veces con backoff exponencial y, si hay BD, queda
+// registrado en webhook_deliveries (sin el body: el input puede contener datos personales).
+type WebhookNotifier struct {
+	webhooks []WebhookConfig
+	db       *sql.DB // Opcional
+	client   *http.Client
+	queue    chan delivery
+
+	wg       sync.WaitGroup
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewWebhookNotifier crea el notificador (db nil = sin registro de entregas)
+func NewWebhookNotifier(webhooks []WebhookConfig, db *sql.DB) *WebhookNotifier {
+	return &WebhookNotifier{
+		webhooks: webhooks,
+		db:       db,
+		client:   &http.Client{Timeout: webhookTimeout},
+		queue:    make(chan delivery, webhookQueueSize),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Start arranca los workers de envío
+func (n *WebhookNotifier) Start(ctx context.Context) {
+	log.Info().
+		Int("webhooks", len(n.webhooks)).
+		Bool("tracking", n.db != nil).
+		Msg("[Webhooks] Starting webhook notifier")
+
+	for i := 0; i < webhookWorkers; i++ {
+		n.wg.Add(1)
+		go func() {
+			defer n.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-n.stopCh:
+					return
+				case d := <-n.queue:
+					n.deliver(ctx, &d)
+				}
+			}
+		}()
+	}
+}
+
+// Stop detiene los workers. Los envíos en cola se descartan; el que está en reintento
+// queda registrado como failed.
+func (n *WebhookNotifier) Stop() {
+	n.stopOnce.Do(func() { close(n.stopCh) })
+	n.wg.Wait()
+}
+
+// Notify encola el evento para los webhooks que lo reciben. No bloquea: con la cola
+// llena el envío se descarta.
+func (n *WebhookNotifier) Notify(event ThreatEvent) {
+	var body []byte
+	for i := range n.webhooks {
+		hook := &n.webhooks[i]
+		if !hook.wants(&event) {
+			continue
+		}
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(event); err != nil {
+				log.Error().Err(err).Msg("[Webhooks] Failed to encode event")
+				return
+			}
+		}
+
+		select {
+		case n.queue <- delivery{webhook: hook, event: event.Event, score: event.RiskScore, body: body}:
+		default:
+			webhookDeliveriesTotal.WithLabelValues(hook.Name, "dropped").Inc()
+			log.Warn().Str("webhook", hook.Name).Msg("[Webhooks] Queue full, notification dropped")
+		}
+	}
+}
+
+// deliver envía la notificación con reintentos y registra el resultado
+func (n *WebhookNotifier) deliver(ctx context.Context, d *delivery) {
+	id := n.recordPending(ctx, d)
+
+	var statusCode int
+	var lastErr error
+	attempts := 0
+	backoff := webhookInitialBackoff
+	for attempts < webhookMaxAttempts {
+		if attempts > 0 {
+			if !n.wait(ctx, backoff) {
+				break // Parada: se registra el último error
+			}
+			backoff *= 2
+		}
+
+		attempts++
+		statusCode, lastErr = n.post(ctx, d, id)
+		if lastErr == nil {
+			webhookDeliveriesTotal.WithLabelValues(d.webhook.Name, "delivered").Inc()
+			n.recordResult(id, "delivered", attempts, statusCode, nil)
+			return
+		}
+		log.Debug().
+			Err(lastErr).
+			Str("webhook", d.webhook.Name).
+			Int("attempt", attempts).
+			Msg("[Webhooks] Delivery attempt failed")
+	}
+
+	webhookDeliveriesTotal.WithLabelValues(d.webhook.Name, "failed").Inc()
+	log.Warn().
+		Err(lastErr).
+		Str("webhook", d.webhook.Name).
+		Int("attempts", attempts).
+		Msg("[Webhooks] Delivery failed")
+	n.recordResult(id, "failed", attempts, statusCode, lastErr)
+}
+
+// wait espera el backoff; false si el notificador se detiene antes
+func (n *WebhookNotifier) wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	case <-n.stopCh:
+		return false
+	}
+}
+
+// post hace un intento de envío. Solo un 2xx cuenta como entregado.
+func (n *WebhookNotifier) post(ctx context.Context, d *delivery, id int64) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.webhook.URL, bytes.NewReader(d.body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Trackfy-Webhooks/1.0")
+	req.Header.Set("X-Trackfy-Event", d.event)
+	req.Header.Set(SignatureHeader, Sign(d.webhook.Secret, d.body))
+	if id > 0 {
+		req.Header.Set("X-Trackfy-Delivery", strconv.FormatInt(id, 10))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign firma del body para SignatureHeader. El receptor recalcula el HMAC con su copia
+// del secreto sobre el body recibido y compara en tiempo constante.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordPending registra la entrega como pending; 0 sin BD o si falla el INSERT
+func (n *WebhookNotifier) recordPending(ctx context.Context, d *delivery) int64 {
+	if n.db == nil {
+		return 0
+	}
+
+	var id int64
+	err := n.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_deliveries (webhook, url, event, risk_score)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`, d.webhook.Name, d.webhook.URL, d.event, d.score).Scan(&id)
+	if err != nil {
+		log.Warn().Err(err).Msg("[Webhooks] Failed to record delivery (migration 020 applied?)")
+		return 0
+	}
+	return id
+}
+
+// recordResult guarda el resultado final de la entrega
+func (n *WebhookNotifier) recordResult(id int64, status string, attempts, statusCode int, deliveryErr error) {
+	if n.db == nil || id == 0 {
+		return
+	}
+
+	var code sql.NullInt32
+	if statusCode > 0 {
+		code = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+	var errMsg sql.NullString
+	if deliveryErr != nil {
+		msg := deliveryErr.Error()
+		if len(msg) > webhookMaxErrorLen {
+			msg = msg[:webhookMaxErrorLen]
+		}
+		errMsg = sql.NullString{String: msg, Valid: true}
+	}
+
+	// Contexto propio: el resultado se guarda aunque el de los workers se haya cancelado
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := n.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = $3, response_status = $4, last_error = $5,
+		    delivered_at = CASE WHEN $2 = 'delivered' THEN NOW() END,
+		    updated_at = NOW()
+		WHERE id = $1
+	`, id, status, attempts, code, errMsg)
+	if err != nil {
+		log.Warn().Err(err).Int64("delivery_id", id).Msg("[Webhooks] Failed to update delivery")
+	}
+}
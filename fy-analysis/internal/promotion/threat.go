@@ -0,0 +1,361 @@
+package promotion
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+	"github.com/trackfy/fy-analysis/internal/notifications"
+)
+
+const (
+	// Umbrales de promoción (mismos que usa fy-admin para "high score")
+	minPromotionScore     = 70
+	minPromotionReporters = 3
+
+	promotionBatchSize = 200
+	promotionTimeout   = 5 * time.Minute
+	// reclassificationTimeout presupuesto del aviso a api-gateway de cada URL promovida
+	reclassificationTimeout = 10 * time.Second
+)
+
+var promotionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "threat_promotions_total",
+	Help: "URLs reportadas promovidas a threat_domains (promoted) o retiradas tras rechazo (demoted)",
+}, []string{"action"})
+
+// ReclassificationNotifier avisa de que un input pasó a ser peligroso (api-gateway envía
+// las alertas push a quien lo analizó)
+type ReclassificationNotifier interface {
+	NotifyReclassification(ctx context.Context, event notifications.Reclassification) error
+}
+
+// ThreatPromoter promueve a threat_domains las URLs con reportes suficientes
+// y retira las que un admin rechaza después de promovidas
+type ThreatPromoter struct {
+	db       *sql.DB
+	interval time.Duration
+	notifier ReclassificationNotifier // nil = sin alertas de reclasificación
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// PromotionResult resumen de una pasada
+type PromotionResult struct {
+	Promoted int `json:"promoted"`
+	Demoted  int `json:"demoted"`
+	Skipped  int `json:"skipped"`
+}
+
+// NewThreatPromoter crea el promotor (interval <= 0 = cada hora)
+func NewThreatPromoter(db *sql.DB, interval time.Duration) *ThreatPromoter {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &ThreatPromoter{
+		db:       db,
+		interval: interval,
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// SetReclassificationNotifier configura el aviso de las URLs promovidas
+func (p *ThreatPromoter) SetReclassificationNotifier(notifier ReclassificationNotifier) {
+	p.notifier = notifier
+}
+
+// Start ejecuta una pasada al arrancar y luego cada interval
+func (p *ThreatPromoter) Start(ctx context.Context) {
+	log.Info().Dur("interval", p.interval).Msg("[Promotion] Starting threat promoter")
+
+	go func() {
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+
+		for {
+			runCtx, cancel := context.WithTimeout(ctx, promotionTimeout)
+			if _, err := p.Run(runCtx); err != nil {
+				log.Warn().Err(err).Msg("[Promotion] Promotion run failed")
+			}
+			cancel()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-p.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop detiene las pasadas periódicas
+func (p *ThreatPromoter) Stop() {
+	p.stopOnce.Do(func() { close(p.stopCh) })
+}
+
+// Run retira las promociones rechazadas y promueve las nuevas candidatas
+func (p *ThreatPromoter) Run(ctx context.Context) (*PromotionResult, error) {
+	result := &PromotionResult{}
+
+	demoted, err := p.demoteRejected(ctx)
+	result.Demoted = demoted
+	if err != nil {
+		return result, fmt.Errorf("demotion failed: %w", err)
+	}
+
+	candidates, err := p.candidates(ctx)
+	if err != nil {
+		return result, err
+	}
+
+	for _, c := range candidates {
+		promoted, err := p.promote(ctx, &c)
+		if err != nil {
+			log.Warn().Err(err).Str("domain", c.domain).Msg("[Promotion] Failed to promote reported URL")
+			continue
+		}
+		if promoted {
+			result.Promoted++
+		} else {
+			result.Skipped++
+		}
+	}
+
+	if result.Promoted > 0 || result.Demoted > 0 {
+		log.Info().
+			Int("promoted", result.Promoted).
+			Int("demoted", result.Demoted).
+			Int("skipped", result.Skipped).
+			Msg("[Promotion] Reported URLs processed")
+	}
+	return result, nil
+}
+
+// candidate URL reportada que cumple los umbrales
+type candidate struct {
+	urlHash    []byte
+	url        string
+	domain     string // Columna domain de reported_urls (fallback si la URL no parsea)
+	threatType string
+	score      int
+}
+
+func (p *ThreatPromoter) candidates(ctx context.Context) ([]candidate, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT url_hash, url, domain, COALESCE(primary_threat_type::text, 'other'), aggregated_score
+		FROM reported_urls
+		WHERE aggregated_score >= $1
+		  AND unique_reporters >= $2
+		  AND status NOT IN ('rejected')
+		  AND promoted_to_threats = false
+		  AND `+flags.Active.IsSet("flags")+`
+		ORDER BY aggregated_score DESC
+		LIMIT $3
+	`, minPromotionScore, minPromotionReporters, promotionBatchSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.urlHash, &c.url, &c.domain, &c.threatType, &c.score); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// promote inserta el dominio en threat_domains, marca la URL como confirmada y avisa a los reportadores.
+// Retorna false si el dominio está en la whitelist (queda para revisión manual).
+func (p *ThreatPromoter) promote(ctx context.Context, c *candidate) (bool, error) {
+	domain := promotionDomain(c.url, c.domain)
+	if domain == "" {
+		return false, fmt.Errorf("no domain for reported URL")
+	}
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Una URL maliciosa alojada en un dominio legítimo no bloquea el dominio entero
+	var whitelisted bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM whitelist_domains WHERE domain_hash = sha256_bytea($1))
+	`, domain).Scan(&whitelisted); err != nil {
+		return false, err
+	}
+	if whitelisted {
+		log.Debug().Str("domain", domain).Msg("[Promotion] Whitelisted domain, leaving for manual review")
+		return false, nil
+	}
+
+	severity := "medium"
+	if c.score >= 90 {
+		severity = "high"
+	}
+	if _, err := tx.ExecContext(ctx, `
+		SELECT upsert_threat_domain($1, $2::threat_type_enum, $3::severity_enum, $4::SMALLINT, 'user_report'::source_enum)
+	`, domain, c.threatType, severity, c.score); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE reported_urls
+		SET promoted_to_threats = true, promoted_at = NOW(), status = 'confirmed'
+		WHERE url_hash = $1
+	`, c.urlHash); err != nil {
+		return false, err
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"url":         c.url,
+		"domain":      domain,
+		"threat_type": c.threatType,
+	})
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_notifications (user_id, type, title, body, data)
+		SELECT user_id, 'report_confirmed', $2, $3, $4
+		FROM user_url_reports
+		WHERE url_hash = $1
+	`, c.urlHash, "Reporte confirmado",
+		fmt.Sprintf("Gracias a tu reporte, %s ya está marcado como peligroso para todos", domain), data); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	promotionsTotal.WithLabelValues("promoted").Inc()
+	log.Info().
+		Str("domain", domain).
+		Str("threat_type", c.threatType).
+		Int("score", c.score).
+		Msg("[Promotion] Reported URL promoted to threat_domains")
+
+	p.notifyReclassification(ctx, c)
+	return true, nil
+}
+
+// notifyReclassification avisa de que la URL promovida ya es peligrosa. Un fallo no deshace
+// la promoción: la URL ya está en threat_domains y solo se pierde la alerta push.
+func (p *ThreatPromoter) notifyReclassification(ctx context.Context, c *candidate) {
+	if p.notifier == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reclassificationTimeout)
+	defer cancel()
+
+	event := notifications.NewReclassification("url", c.url, c.threatType, notifications.ReasonCommunityReports)
+	if err := p.notifier.NotifyReclassification(ctx, event); err != nil {
+		log.Warn().Err(err).Str("domain", c.domain).Msg("[Promotion] Failed to notify reclassification")
+	}
+}
+
+// demoteRejected retira de threat_domains las URLs promovidas que un admin ha rechazado.
+// Solo borra entradas con source user_report y si ninguna otra URL promovida comparte dominio.
+func (p *ThreatPromoter) demoteRejected(ctx context.Context) (int, error) {
+	rows, err := p.db.QueryContext(ctx, `
+		SELECT url_hash, url, domain
+		FROM reported_urls
+		WHERE status = 'rejected' AND promoted_to_threats = true
+		LIMIT $1
+	`, promotionBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	var rejected []candidate
+	for rows.Next() {
+		var c candidate
+		if err := rows.Scan(&c.urlHash, &c.url, &c.domain); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		rejected = append(rejected, c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	demoted := 0
+	for _, c := range rejected {
+		if err := p.demote(ctx, &c); err != nil {
+			log.Warn().Err(err).Str("domain", c.domain).Msg("[Promotion] Failed to demote rejected URL")
+			continue
+		}
+		demoted++
+	}
+	return demoted, nil
+}
+
+func (p *ThreatPromoter) demote(ctx context.Context, c *candidate) error {
+	domain := promotionDomain(c.url, c.domain)
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE reported_urls SET promoted_to_threats = false, promoted_at = NULL
+		WHERE url_hash = $1
+	`, c.urlHash); err != nil {
+		return err
+	}
+
+	if domain != "" {
+		if _, err := tx.ExecContext(ctx, `
+			DELETE FROM threat_domains
+			WHERE domain_hash = sha256_bytea($1)
+			  AND source = 'user_report'
+			  AND NOT EXISTS (
+				SELECT 1 FROM reported_urls
+				WHERE domain IN ($1, 'www.' || $1) AND promoted_to_threats = true
+			  )
+		`, domain); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	promotionsTotal.WithLabelValues("demoted").Inc()
+	log.Info().Str("domain", domain).Msg("[Promotion] Rejected URL removed from threat_domains")
+	return nil
+}
+
+// promotionDomain host de la URL en minúsculas (sin www.); si no parsea, el dominio guardado
+func promotionDomain(rawURL, fallback string) string {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	host := fallback
+	if u, err := url.Parse(rawURL); err == nil && u.Hostname() != "" {
+		host = u.Hostname()
+	}
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	return strings.TrimPrefix(host, "www.")
+}
@@ -2,6 +2,10 @@ package sync
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
@@ -9,22 +13,48 @@ import (
 )
 
 // DBSyncer maneja la sincronización periódica de las bases de datos locales
+// y, si hay PostgreSQL configurado, persiste los feeds en threat_domains/threat_paths
 type DBSyncer struct {
-	urlhausChecker   *checkers.URLhausChecker
-	phishtankChecker *checkers.PhishTankChecker
-	urlhausInterval  time.Duration
+	urlhausChecker    *checkers.URLhausChecker
+	phishtankChecker  *checkers.PhishTankChecker
+	urlhausInterval   time.Duration
 	phishtankInterval time.Duration
-	stopCh           chan struct{}
+	fileSync          bool
+	writer            *ThreatDBWriter
+	pageDB            *checkers.PhishingPageDB
+	writeStats        map[string]*WriteStats
+	running           map[string]time.Time // DB -> inicio de la sincronización en curso
+	mu                sync.RWMutex
+	stopCh            chan struct{}
+}
+
+// ErrSyncInProgress ya hay una sincronización en curso para esa DB
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+// DBSyncerConfig configuración del sincronizador
+type DBSyncerConfig struct {
+	EnableFileSync bool                     // Refrescar archivos locales e índices en memoria
+	Writer         *ThreatDBWriter          // nil = no escribir en PostgreSQL
+	PageDB         *checkers.PhishingPageDB // nil = no indexar páginas de PhishTank (pHash)
 }
 
 // NewDBSyncer crea un nuevo sincronizador de DBs
-func NewDBSyncer(urlhaus *checkers.URLhausChecker, phishtank *checkers.PhishTankChecker) *DBSyncer {
+func NewDBSyncer(urlhaus *checkers.URLhausChecker, phishtank *checkers.PhishTankChecker, config *DBSyncerConfig) *DBSyncer {
+	if config == nil {
+		config = &DBSyncerConfig{EnableFileSync: true}
+	}
+
 	return &DBSyncer{
-		urlhausChecker:   urlhaus,
-		phishtankChecker: phishtank,
-		urlhausInterval:  5 * time.Minute,  // URLhaus se actualiza cada 5 min
+		urlhausChecker:    urlhaus,
+		phishtankChecker:  phishtank,
+		urlhausInterval:   5 * time.Minute, // URLhaus se actualiza cada 5 min
 		phishtankInterval: 1 * time.Hour,   // PhishTank cada 1 hora
-		stopCh:           make(chan struct{}),
+		fileSync:          config.EnableFileSync,
+		writer:            config.Writer,
+		pageDB:            config.PageDB,
+		writeStats:        make(map[string]*WriteStats),
+		running:           make(map[string]time.Time),
+		stopCh:            make(chan struct{}),
 	}
 }
 
@@ -33,26 +63,28 @@ func (s *DBSyncer) Start(ctx context.Context) {
 	log.Info().
 		Dur("urlhaus_interval", s.urlhausInterval).
 		Dur("phishtank_interval", s.phishtankInterval).
+		Bool("file_sync", s.fileSync).
+		Bool("db_write", s.writer != nil).
 		Msg("[DBSyncer] Starting database synchronization")
 
 	// Sincronización inicial
 	go s.syncNow(ctx)
 
 	// Goroutine para URLhaus
-	go s.syncLoop(ctx, "urlhaus", s.urlhausInterval, func(ctx context.Context) error {
-		return s.urlhausChecker.DownloadDB(ctx)
-	})
+	go s.syncLoop(ctx, "urlhaus", s.urlhausInterval, s.syncURLhaus)
 
 	// Goroutine para PhishTank
-	go s.syncLoop(ctx, "phishtank", s.phishtankInterval, func(ctx context.Context) error {
-		return s.phishtankChecker.DownloadDB(ctx)
-	})
+	go s.syncLoop(ctx, "phishtank", s.phishtankInterval, s.syncPhishTank)
 }
 
 // Stop detiene la sincronización
 func (s *DBSyncer) Stop() {
 	log.Info().Msg("[DBSyncer] Stopping database synchronization")
 	close(s.stopCh)
+
+	if s.writer != nil {
+		s.writer.Close()
+	}
 }
 
 // syncNow sincroniza todas las DBs inmediatamente
@@ -61,7 +93,7 @@ func (s *DBSyncer) syncNow(ctx context.Context) {
 
 	// URLhaus
 	if s.urlhausChecker != nil {
-		if err := s.urlhausChecker.DownloadDB(ctx); err != nil {
+		if err := s.syncURLhaus(ctx); err != nil {
 			log.Error().Err(err).Msg("[DBSyncer] Failed to sync URLhaus")
 		} else {
 			stats := s.urlhausChecker.GetStats()
@@ -73,7 +105,7 @@ func (s *DBSyncer) syncNow(ctx context.Context) {
 
 	// PhishTank
 	if s.phishtankChecker != nil {
-		if err := s.phishtankChecker.DownloadDB(ctx); err != nil {
+		if err := s.syncPhishTank(ctx); err != nil {
 			log.Error().Err(err).Msg("[DBSyncer] Failed to sync PhishTank")
 		} else {
 			stats := s.phishtankChecker.GetStats()
@@ -91,6 +123,12 @@ func (s *DBSyncer) syncLoop(ctx context.Context, name string, interval time.Dura
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	// Escribir en PostgreSQL tarda bastante más que refrescar el archivo
+	timeout := 2 * time.Minute
+	if s.writer != nil {
+		timeout = 10 * time.Minute
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -102,7 +140,7 @@ func (s *DBSyncer) syncLoop(ctx context.Context, name string, interval time.Dura
 		case <-ticker.C:
 			log.Debug().Str("db", name).Msg("[DBSyncer] Running scheduled sync")
 
-			syncCtx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+			syncCtx, cancel := context.WithTimeout(ctx, timeout)
 			if err := syncFunc(syncCtx); err != nil {
 				log.Error().Err(err).Str("db", name).Msg("[DBSyncer] Scheduled sync failed")
 			} else {
@@ -113,35 +151,229 @@ func (s *DBSyncer) syncLoop(ctx context.Context, name string, interval time.Dura
 	}
 }
 
+// syncURLhaus refresca el archivo de URLhaus y/o escribe el feed en PostgreSQL
+func (s *DBSyncer) syncURLhaus(ctx context.Context) error {
+	if s.urlhausChecker == nil {
+		return nil
+	}
+
+	done, err := s.begin("urlhaus")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var entries []*checkers.URLhausEntry
+	switch {
+	case s.fileSync:
+		if err := s.urlhausChecker.DownloadDB(ctx); err != nil {
+			return err
+		}
+		if s.writer == nil {
+			return nil
+		}
+		entries = s.urlhausChecker.Entries()
+	case s.writer != nil:
+		var err error
+		if entries, err = s.urlhausChecker.FetchEntries(ctx); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	records := make([]ThreatRecord, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, ThreatRecord{
+			URL:        entry.URL,
+			ThreatType: mapURLhausThreat(entry.Threat),
+			Severity:   "high",
+			Confidence: 85,
+			SourceID:   "urlhaus-" + entry.ID,
+		})
+	}
+
+	return s.write(ctx, "urlhaus", records)
+}
+
+// syncPhishTank refresca el archivo de PhishTank y/o escribe el feed en PostgreSQL
+func (s *DBSyncer) syncPhishTank(ctx context.Context) error {
+	if s.phishtankChecker == nil {
+		return nil
+	}
+
+	done, err := s.begin("phishtank")
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	var entries []*checkers.PhishTankEntry
+	switch {
+	case s.fileSync:
+		if err := s.phishtankChecker.DownloadDB(ctx); err != nil {
+			return err
+		}
+		if s.writer == nil && s.pageDB == nil {
+			return nil
+		}
+		entries = s.phishtankChecker.Entries()
+	case s.writer != nil || s.pageDB != nil:
+		var err error
+		if entries, err = s.phishtankChecker.FetchEntries(ctx); err != nil {
+			return err
+		}
+	default:
+		return nil
+	}
+
+	// Hashes perceptuales para el checker visual
+	if s.pageDB != nil {
+		if _, err := s.pageDB.IndexPhishTank(ctx, entries); err != nil {
+			log.Error().Err(err).Msg("[DBSyncer] Failed to index PhishTank page hashes")
+		}
+	}
+	if s.writer == nil {
+		return nil
+	}
+
+	records := make([]ThreatRecord, 0, len(entries))
+	for _, entry := range entries {
+		// Los no verificados entran con menos confianza
+		confidence := int16(70)
+		if entry.Verified == "yes" {
+			confidence = 90
+		}
+		records = append(records, ThreatRecord{
+			URL:        entry.URL,
+			ThreatType: "phishing",
+			Severity:   "high",
+			Confidence: confidence,
+			SourceID:   fmt.Sprintf("phishtank-%d", entry.PhishID),
+		})
+	}
+
+	return s.write(ctx, "phishtank", records)
+}
+
+// begin marca la sincronización de una DB como en curso (el loop periódico y
+// ForceSync pueden coincidir); done la desmarca
+func (s *DBSyncer) begin(source string) (done func(), err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, running := s.running[source]; running {
+		return nil, ErrSyncInProgress
+	}
+	s.running[source] = time.Now()
+
+	return func() {
+		s.mu.Lock()
+		delete(s.running, source)
+		s.mu.Unlock()
+	}, nil
+}
+
+// SyncInProgress indica si hay una sincronización en curso para la DB ("all" = cualquiera)
+func (s *DBSyncer) SyncInProgress(dbName string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if dbName == "all" {
+		return len(s.running) > 0
+	}
+	_, running := s.running[dbName]
+	return running
+}
+
+// write persiste los registros y guarda el resultado para GetStatus
+func (s *DBSyncer) write(ctx context.Context, source string, records []ThreatRecord) error {
+	stats := s.writer.Upsert(ctx, source, records)
+
+	s.mu.Lock()
+	s.writeStats[source] = stats
+	s.mu.Unlock()
+
+	if stats.Error != "" {
+		return fmt.Errorf("database write for %s interrupted: %s", source, stats.Error)
+	}
+	return nil
+}
+
 // GetStatus retorna el estado de las DBs
 func (s *DBSyncer) GetStatus() map[string]interface{} {
 	status := make(map[string]interface{})
 
 	if s.urlhausChecker != nil {
-		status["urlhaus"] = s.urlhausChecker.GetStats()
+		status["urlhaus"] = s.sourceStatus("urlhaus", s.urlhausChecker.GetStats())
 	}
 
 	if s.phishtankChecker != nil {
-		status["phishtank"] = s.phishtankChecker.GetStats()
+		status["phishtank"] = s.sourceStatus("phishtank", s.phishtankChecker.GetStats())
 	}
 
 	return status
 }
 
+// sourceStatus combina el estado del archivo local con el de la última escritura en PostgreSQL
+func (s *DBSyncer) sourceStatus(source string, fileStats map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{
+		"file_sync": s.fileSync,
+		"db_write":  s.writer != nil,
+	}
+	for k, v := range fileStats {
+		result[k] = v
+	}
+
+	s.mu.RLock()
+	stats, ok := s.writeStats[source]
+	startedAt, running := s.running[source]
+	s.mu.RUnlock()
+
+	result["sync_in_progress"] = running
+	if running {
+		result["sync_started_at"] = startedAt
+	}
+
+	if ok {
+		result["database"] = map[string]interface{}{
+			"last_run":    stats.LastRun,
+			"inserted":    stats.Inserted,
+			"updated":     stats.Updated,
+			"skipped":     stats.Skipped,
+			"errors":      stats.Errors,
+			"duration_ms": stats.Duration.Milliseconds(),
+			"error":       stats.Error,
+		}
+	}
+
+	return result
+}
+
 // ForceSync fuerza una sincronización inmediata de una DB específica
 func (s *DBSyncer) ForceSync(ctx context.Context, dbName string) error {
 	switch dbName {
 	case "urlhaus":
-		if s.urlhausChecker != nil {
-			return s.urlhausChecker.DownloadDB(ctx)
-		}
+		return s.syncURLhaus(ctx)
 	case "phishtank":
-		if s.phishtankChecker != nil {
-			return s.phishtankChecker.DownloadDB(ctx)
-		}
+		return s.syncPhishTank(ctx)
 	case "all":
 		s.syncNow(ctx)
 		return nil
 	}
-	return nil
+	return fmt.Errorf("unknown database: %s", dbName)
+}
+
+// mapURLhausThreat mapea el tipo de amenaza de URLhaus a threat_type_enum
+func mapURLhausThreat(threat string) string {
+	switch strings.ToLower(strings.TrimSpace(threat)) {
+	case "phishing":
+		return "phishing"
+	case "cryptojacking":
+		return "cryptojacking"
+	case "ransomware":
+		return "ransomware"
+	default:
+		return "malware"
+	}
 }
@@ -0,0 +1,328 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+	"github.com/trackfy/fy-analysis/internal/urlcanon"
+	"github.com/trackfy/pkg/dbutil"
+)
+
+// ThreatRecord representa una URL de un feed lista para persistir en PostgreSQL
+type ThreatRecord struct {
+	URL        string
+	ThreatType string // threat_type_enum
+	Severity   string // severity_enum
+	Confidence int16  // 0-100
+	SourceID   string
+}
+
+// WriteStats resultado de una ejecución de escritura en PostgreSQL
+type WriteStats struct {
+	LastRun   time.Time     `json:"last_run"`
+	Inserted  int64         `json:"inserted"`
+	Updated   int64         `json:"updated"`   // Dominios existentes cuya confianza sube
+	Unchanged int64         `json:"unchanged"` // Dominios ya conocidos: solo se refresca last_seen
+	Skipped   int64         `json:"skipped"`
+	Errors    int64         `json:"errors"`
+	Duration  time.Duration `json:"-"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// ThreatDBWriter persiste los feeds descargados en threat_domains/threat_paths
+type ThreatDBWriter struct {
+	db *sql.DB
+}
+
+// NewThreatDBWriter crea un nuevo writer conectado a PostgreSQL
+func NewThreatDBWriter(databaseURL string) (*ThreatDBWriter, error) {
+	if databaseURL == "" {
+		return nil, fmt.Errorf("database URL is required")
+	}
+	if err := dbutil.ValidateDatabaseURL(databaseURL); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	db.SetMaxOpenConns(5)
+	db.SetMaxIdleConns(2)
+	db.SetConnMaxLifetime(5 * time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Info().Msg("[ThreatDBWriter] Connected to PostgreSQL")
+	return &ThreatDBWriter{db: db}, nil
+}
+
+// Close cierra la conexión a PostgreSQL
+func (w *ThreatDBWriter) Close() error {
+	return w.db.Close()
+}
+
+// upsertBatchSize filas por sentencia INSERT multi-VALUES (8 parámetros por fila, muy por
+// debajo del límite de 65535 de PostgreSQL)
+const upsertBatchSize = 500
+
+// threatDomainRow dominio deduplicado de un feed con la clasificación de mayor confianza
+type threatDomainRow struct {
+	domain string
+	record ThreatRecord
+}
+
+// threatPathRow path de un dominio del feed
+type threatPathRow struct {
+	domain string
+	path   string
+	record ThreatRecord
+}
+
+// Upsert inserta o actualiza los registros de una fuente por lotes y actualiza sync_status.
+// Volver a ver un dominio en el feed no es un hit: hit_count no se toca y solo cuenta como
+// actualizado si sube su confianza; si no, solo se refresca last_seen (Unchanged).
+func (w *ThreatDBWriter) Upsert(ctx context.Context, source string, records []ThreatRecord) *WriteStats {
+	start := time.Now()
+	stats := &WriteStats{LastRun: start}
+
+	domains, paths, skipped := groupThreatRecords(records)
+	stats.Skipped = skipped
+
+	for i := 0; i < len(domains); i += upsertBatchSize {
+		if ctx.Err() != nil {
+			stats.Error = ctx.Err().Error()
+			break
+		}
+		batch := domains[i:min(i+upsertBatchSize, len(domains))]
+		if err := w.upsertDomainBatch(ctx, source, batch, start, stats); err != nil {
+			stats.Errors += int64(len(batch))
+			log.Warn().Err(err).Str("source", source).Int("batch", len(batch)).Msg("[ThreatDBWriter] Domain batch failed")
+		}
+	}
+
+	for i := 0; i < len(paths) && stats.Error == ""; i += upsertBatchSize {
+		if ctx.Err() != nil {
+			stats.Error = ctx.Err().Error()
+			break
+		}
+		batch := paths[i:min(i+upsertBatchSize, len(paths))]
+		if err := w.upsertPathBatch(ctx, source, batch, start); err != nil {
+			stats.Errors += int64(len(batch))
+			log.Warn().Err(err).Str("source", source).Int("batch", len(batch)).Msg("[ThreatDBWriter] Path batch failed")
+		}
+	}
+
+	stats.Duration = time.Since(start)
+	w.updateSyncStatus(ctx, source, stats)
+
+	log.Info().
+		Str("source", source).
+		Int64("inserted", stats.Inserted).
+		Int64("updated", stats.Updated).
+		Int64("unchanged", stats.Unchanged).
+		Int64("skipped", stats.Skipped).
+		Int64("errors", stats.Errors).
+		Dur("duration", stats.Duration).
+		Msg("[ThreatDBWriter] Feed written to PostgreSQL")
+
+	return stats
+}
+
+// groupThreatRecords deduplica los registros del feed: un dominio por fila (con la mayor
+// confianza de sus URLs) y un path por dominio+path. skipped cuenta URLs inválidas y repetidas.
+func groupThreatRecords(records []ThreatRecord) ([]threatDomainRow, []threatPathRow, int64) {
+	var domains []threatDomainRow
+	var paths []threatPathRow
+	var skipped int64
+	domainIndex := make(map[string]int, len(records))
+	seen := make(map[string]bool, len(records))
+
+	for _, record := range records {
+		domain, path, ok := splitThreatURL(record.URL)
+		if !ok || seen[domain+path] {
+			skipped++
+			continue
+		}
+		seen[domain+path] = true
+
+		if i, ok := domainIndex[domain]; !ok {
+			domainIndex[domain] = len(domains)
+			domains = append(domains, threatDomainRow{domain: domain, record: record})
+		} else if record.Confidence > domains[i].record.Confidence {
+			domains[i].record = record
+		}
+
+		if path != "" && path != "/" {
+			paths = append(paths, threatPathRow{domain: domain, path: path, record: record})
+		}
+	}
+	return domains, paths, skipped
+}
+
+// upsertDomainBatch escribe un lote de dominios en una transacción. El INSERT solo devuelve
+// las filas nuevas o cuya confianza sube; al resto se le refresca last_seen sin contar hit.
+func (w *ThreatDBWriter) upsertDomainBatch(ctx context.Context, source string, batch []threatDomainRow, now time.Time, stats *WriteStats) error {
+	args := []interface{}{source, now}
+	values := make([]string, 0, len(batch))
+	for _, row := range batch {
+		n := len(args)
+		values = append(values, fmt.Sprintf(
+			"(sha256_bytea($%d), $%d, $%d::threat_type_enum, $%d::severity_enum, $%d::SMALLINT, $1::source_enum, $%d, $%d, $2::TIMESTAMP, $2::TIMESTAMP, %s)",
+			n+1, n+1, n+2, n+3, n+4, n+5, n+6, flags.Active.SQL()))
+		args = append(args, row.domain, row.record.ThreatType, row.record.Severity, row.record.Confidence, row.record.SourceID, extractTLD(row.domain))
+	}
+
+	tx, err := w.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, source_id, tld, first_seen, last_seen, flags)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (domain_hash) DO UPDATE SET
+			last_seen = EXCLUDED.last_seen,
+			confidence = EXCLUDED.confidence
+		WHERE threat_domains.confidence < EXCLUDED.confidence
+		RETURNING domain, (xmax = 0)
+	`, args...)
+	if err != nil {
+		return err
+	}
+
+	written := make(map[string]bool, len(batch))
+	var inserted, updated int64
+	for rows.Next() {
+		var domain string
+		var isNew bool
+		if err := rows.Scan(&domain, &isNew); err != nil {
+			rows.Close()
+			return err
+		}
+		written[domain] = true
+		if isNew {
+			inserted++
+		} else {
+			updated++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	unchanged := make([]string, 0, len(batch)-len(written))
+	for _, row := range batch {
+		if !written[row.domain] {
+			unchanged = append(unchanged, row.domain)
+		}
+	}
+	if len(unchanged) > 0 {
+		_, err = tx.ExecContext(ctx, `
+			UPDATE threat_domains SET last_seen = $1
+			WHERE domain_hash IN (SELECT sha256_bytea(d) FROM unnest($2::TEXT[]) AS d)
+		`, now, pq.Array(unchanged))
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	stats.Inserted += inserted
+	stats.Updated += updated
+	stats.Unchanged += int64(len(unchanged))
+	return nil
+}
+
+// upsertPathBatch escribe un lote de paths (sus dominios ya existen); los que ya estaban
+// solo refrescan last_seen
+func (w *ThreatDBWriter) upsertPathBatch(ctx context.Context, source string, batch []threatPathRow, now time.Time) error {
+	args := []interface{}{source, now}
+	values := make([]string, 0, len(batch))
+	for _, row := range batch {
+		n := len(args)
+		values = append(values, fmt.Sprintf(
+			"(sha256_bytea($%d), sha256_bytea($%d), $%d, $%d::threat_type_enum, $%d::severity_enum, $%d::SMALLINT, $1::source_enum, $2::TIMESTAMP, $2::TIMESTAMP, %s)",
+			n+1, n+2, n+3, n+4, n+5, n+6, flags.Active.SQL()))
+		args = append(args, row.domain+row.path, row.domain, row.path, row.record.ThreatType, row.record.Severity, row.record.Confidence)
+	}
+
+	_, err := w.db.ExecContext(ctx, `
+		INSERT INTO threat_paths (path_hash, domain_hash, path, threat_type, severity, confidence, source, first_seen, last_seen, flags)
+		VALUES `+strings.Join(values, ", ")+`
+		ON CONFLICT (path_hash) DO UPDATE SET last_seen = EXCLUDED.last_seen
+	`, args...)
+	return err
+}
+
+// updateSyncStatus registra el resultado de la ejecución en sync_status
+func (w *ThreatDBWriter) updateSyncStatus(ctx context.Context, source string, stats *WriteStats) {
+	var lastError sql.NullString
+	if stats.Error != "" {
+		lastError = sql.NullString{String: stats.Error, Valid: true}
+	}
+
+	_, err := w.db.ExecContext(ctx, `
+		INSERT INTO sync_status (source, last_sync, last_count, last_error)
+		VALUES ($1::source_enum, NOW(), $2, $3)
+		ON CONFLICT (source) DO UPDATE SET
+			last_sync = NOW(),
+			last_count = $2,
+			last_error = $3
+	`, source, stats.Inserted+stats.Updated+stats.Unchanged, lastError)
+	if err != nil {
+		log.Error().Err(err).Str("source", source).Msg("[ThreatDBWriter] Failed to update sync_status")
+	}
+}
+
+// splitThreatURL extrae dominio normalizado y path canónico de una URL del feed
+func splitThreatURL(rawURL string) (string, string, bool) {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return "", "", false
+	}
+
+	parsedURL, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", false
+	}
+
+	domain := strings.ToLower(parsedURL.Hostname())
+	if len(domain) < 3 || !strings.Contains(domain, ".") {
+		return "", "", false
+	}
+
+	// Saltar IPs (threat_domains solo almacena dominios)
+	if net.ParseIP(domain) != nil {
+		return "", "", false
+	}
+
+	return domain, urlcanon.DecodedPath(parsedURL), true
+}
+
+// extractTLD extrae el TLD de un dominio
+func extractTLD(domain string) string {
+	parts := strings.Split(domain, ".")
+	if len(parts) >= 2 {
+		return parts[len(parts)-1]
+	}
+	return ""
+}
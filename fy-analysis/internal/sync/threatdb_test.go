@@ -0,0 +1,34 @@
+package sync
+
+import "testing"
+
+func TestGroupThreatRecords(t *testing.T) {
+	records := []ThreatRecord{
+		{URL: "http://phish.example.com/login", Confidence: 70, ThreatType: "phishing"},
+		{URL: "http://phish.example.com/login", Confidence: 90}, // repetida
+		{URL: "http://PHISH.example.com/pay", Confidence: 95, ThreatType: "malware"},
+		{URL: "http://phish.example.com/", Confidence: 60}, // sin path útil
+		{URL: "http://other.example.org", Confidence: 50},
+		{URL: "http://192.168.1.10/x", Confidence: 80}, // IP
+		{URL: "", Confidence: 80},
+	}
+
+	domains, paths, skipped := groupThreatRecords(records)
+
+	if skipped != 3 {
+		t.Errorf("skipped = %d, want 3", skipped)
+	}
+	if len(domains) != 2 {
+		t.Fatalf("domains = %d, want 2", len(domains))
+	}
+	if domains[0].domain != "phish.example.com" || domains[1].domain != "other.example.org" {
+		t.Errorf("domains order = %s, %s", domains[0].domain, domains[1].domain)
+	}
+	// El dominio se escribe con la clasificación de su URL de mayor confianza
+	if got := domains[0].record; got.Confidence != 95 || got.ThreatType != "malware" {
+		t.Errorf("phish.example.com record = %+v, want confidence 95 malware", got)
+	}
+	if len(paths) != 2 || paths[0].path != "/login" || paths[1].path != "/pay" {
+		t.Errorf("paths = %+v, want /login and /pay", paths)
+	}
+}
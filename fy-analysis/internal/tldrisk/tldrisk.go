@@ -0,0 +1,314 @@
+// Package tldrisk mantiene la tabla de TLDs de riesgo (TLD -> puntos) compartida por las
+// heurísticas del engine y el analizador de URLs legacy.
+package tldrisk
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	_ "embed"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/flags"
+)
+
+const (
+	// MaxPoints puntos máximos de un TLD: ni el fichero ni el recálculo pueden superarlos
+	MaxPoints = 25
+
+	// minActiveDomains dominios activos mínimos para recalcular: con menos (BD vacía o a medio
+	// sincronizar) las proporciones no son fiables y se mantiene la tabla anterior
+	minActiveDomains = 1000
+	// minTLDDomains dominios activos mínimos de un TLD para recalcular sus puntos; los TLDs
+	// con menos conservan los del fichero
+	minTLDDomains = 25
+	// pointsPerDoubling puntos por cada vez que se duplica la sobrerrepresentación del TLD
+	pointsPerDoubling = 5
+	refreshTimeout    = 2 * time.Minute
+)
+
+//go:embed tlds.txt
+var bundledTable string
+
+// Default tabla compartida, cargada al arrancar con la incluida en el binario
+var Default = New()
+
+// Stats estado de la tabla para GetStatus
+type Stats struct {
+	Count       int            `json:"count"`
+	Source      string         `json:"source"` // bundled, el fichero cargado o postgres
+	LastRefresh time.Time      `json:"last_refresh,omitempty"`
+	LastError   string         `json:"last_error,omitempty"`
+	TLDs        map[string]int `json:"tlds"`
+}
+
+// Table puntos de riesgo por TLD protegidos por RWMutex
+type Table struct {
+	mu          sync.RWMutex
+	base        map[string]int // Tabla del fichero: punto de partida de cada recálculo
+	points      map[string]int
+	source      string
+	lastRefresh time.Time
+	lastError   string
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// New crea una tabla con los TLDs incluidos en el binario
+func New() *Table {
+	points, _ := parse(strings.NewReader(bundledTable))
+	return &Table{
+		base:   points,
+		points: points,
+		source: "bundled",
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Points puntos de riesgo del TLD (0 si no es de riesgo)
+func (t *Table) Points(tld string) int {
+	tld = normalizeTLD(tld)
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.points[tld]
+}
+
+// Stats retorna una copia de la tabla actual y su origen
+func (t *Table) Stats() Stats {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	tlds := make(map[string]int, len(t.points))
+	for tld, points := range t.points {
+		tlds[tld] = points
+	}
+	return Stats{
+		Count:       len(tlds),
+		Source:      t.source,
+		LastRefresh: t.lastRefresh,
+		LastError:   t.lastError,
+		TLDs:        tlds,
+	}
+}
+
+// LoadFile sustituye la tabla por la del fichero (mismo formato que tlds.txt).
+// Ante cualquier error se mantiene la tabla anterior.
+func (t *Table) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	points, err := parse(f)
+	if err != nil {
+		return err
+	}
+	if len(points) == 0 {
+		return fmt.Errorf("no TLDs in %s", path)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.base = points
+	t.points = points
+	t.source = path
+	return nil
+}
+
+// StartRefresh recalcula los puntos con db ahora y cada interval hasta Stop.
+// Sin db o con interval 0 la tabla se queda con la del fichero.
+func (t *Table) StartRefresh(ctx context.Context, db *sql.DB, interval time.Duration) {
+	if db == nil || interval <= 0 {
+		return
+	}
+
+	log.Info().
+		Dur("interval", interval).
+		Msg("[TLDRisk] Starting TLD risk refresh")
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := t.Refresh(ctx, db); err != nil {
+				log.Warn().Err(err).Msg("[TLDRisk] Refresh failed, keeping previous table")
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Stop detiene el refresco periódico
+func (t *Table) Stop() {
+	t.stopOnce.Do(func() { close(t.stopCh) })
+}
+
+// Refresh recalcula los puntos de los TLDs con suficientes dominios activos en threat_domains
+// a partir de la tabla del fichero; el resto conserva los del fichero.
+// Ante cualquier error se mantiene la tabla anterior.
+func (t *Table) Refresh(ctx context.Context, db *sql.DB) error {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	computed, err := compute(ctx, db)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err != nil {
+		t.lastError = err.Error()
+		return err
+	}
+
+	points := make(map[string]int, len(t.base)+len(computed))
+	for tld, p := range t.base {
+		points[tld] = p
+	}
+	for tld, p := range computed {
+		if p == 0 {
+			delete(points, tld)
+			continue
+		}
+		points[tld] = p
+	}
+
+	t.points = points
+	t.source = "postgres"
+	t.lastRefresh = time.Now()
+	t.lastError = ""
+
+	log.Info().
+		Int("tlds", len(points)).
+		Int("recomputed", len(computed)).
+		Msg("[TLDRisk] TLD risk table refreshed")
+
+	return nil
+}
+
+// compute puntos por TLD según su sobrerrepresentación en threat_domains (activos) respecto a
+// whitelist_domains. Solo incluye los TLDs con al menos minTLDDomains dominios activos.
+func compute(ctx context.Context, db *sql.DB) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, `
+		WITH threats AS (
+			SELECT lower(substring(domain from '\.([^.]+)$')) AS tld, COUNT(*) AS n
+			FROM threat_domains
+			WHERE `+flags.Active.IsSet("flags")+`
+			GROUP BY 1
+		), whitelist AS (
+			SELECT lower(substring(domain from '\.([^.]+)$')) AS tld, COUNT(*) AS n
+			FROM whitelist_domains
+			GROUP BY 1
+		)
+		SELECT COALESCE(t.tld, w.tld), COALESCE(t.n, 0), COALESCE(w.n, 0)
+		FROM threats t
+		FULL OUTER JOIN whitelist w ON w.tld = t.tld
+		WHERE COALESCE(t.tld, w.tld) IS NOT NULL
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type counts struct{ threats, whitelist int64 }
+	byTLD := map[string]counts{}
+	var totalThreats, totalWhitelist int64
+	for rows.Next() {
+		var tld string
+		var c counts
+		if err := rows.Scan(&tld, &c.threats, &c.whitelist); err != nil {
+			return nil, err
+		}
+		byTLD[tld] = c
+		totalThreats += c.threats
+		totalWhitelist += c.whitelist
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if totalThreats < minActiveDomains {
+		return nil, fmt.Errorf("not enough active domains to recompute: %d (min %d)", totalThreats, minActiveDomains)
+	}
+
+	computed := map[string]int{}
+	for tld, c := range byTLD {
+		if c.threats < minTLDDomains {
+			continue
+		}
+		// Suavizado de Laplace: un TLD sin dominios en la whitelist no divide por cero
+		threatShare := float64(c.threats) / float64(totalThreats)
+		whitelistShare := float64(c.whitelist+1) / float64(totalWhitelist+int64(len(byTLD)))
+		computed[tld] = score(threatShare / whitelistShare)
+	}
+	return computed, nil
+}
+
+// score puntos de una sobrerrepresentación (lift): pointsPerDoubling por cada duplicación,
+// entre 0 (igual o menos frecuente que en la whitelist) y MaxPoints
+func score(lift float64) int {
+	if lift <= 1 {
+		return 0
+	}
+	return clamp(int(math.Round(pointsPerDoubling * math.Log2(lift))))
+}
+
+// parse lee "tld puntos" por línea, ignorando vacías y comentarios (#)
+func parse(r io.Reader) (map[string]int, error) {
+	points := make(map[string]int)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid line %q: expected \"tld points\"", line)
+		}
+		p, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid points for %s: %w", fields[0], err)
+		}
+		if tld := normalizeTLD(fields[0]); tld != "" && p > 0 {
+			points[tld] = clamp(p)
+		}
+	}
+	return points, scanner.Err()
+}
+
+// normalizeTLD minúsculas y sin punto inicial (".ZIP" -> "zip")
+func normalizeTLD(tld string) string {
+	return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(tld)), ".")
+}
+
+// clamp limita los puntos a [0, MaxPoints]
+func clamp(points int) int {
+	if points < 0 {
+		return 0
+	}
+	if points > MaxPoints {
+		return MaxPoints
+	}
+	return points
+}
@@ -0,0 +1,219 @@
+// Package urlcanon forma canónica de las URLs: la que se usa para los hashes y las búsquedas
+// en la DB, de modo que /login/../paypal/verify, //paypal//verify, %7E o ?utm_source=sms
+// no fragmenten la cache ni las coincidencias de threat_paths. La URL original se conserva
+// aparte para mostrarla.
+package urlcanon
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultTrackingParams parámetros de tracking que se quitan de la query. Un * final
+// es un prefijo (utm_* quita utm_source, utm_medium...). La comparación ignora mayúsculas.
+var DefaultTrackingParams = []string{
+	"utm_*",
+	"fbclid", "gclid", "gbraid", "wbraid", "dclid", "msclkid", "yclid", "twclid", "ttclid",
+	"mc_cid", "mc_eid", "igshid", "_ga", "_gl",
+}
+
+// Canonicalizer canonicaliza URLs con una lista de parámetros de tracking
+type Canonicalizer struct {
+	exact    map[string]bool
+	prefixes []string
+}
+
+// Canonical forma canónica de una URL
+type Canonical struct {
+	// URL canónica (escapada): scheme://host/path?query, sin fragmento ni tracking
+	URL string
+	// Path canónico decodificado, comparable con threat_paths.path (que guarda url.URL.Path)
+	Path string
+}
+
+// New crea un Canonicalizer; sin parámetros de tracking usa DefaultTrackingParams
+func New(trackingParams []string) *Canonicalizer {
+	if len(trackingParams) == 0 {
+		trackingParams = DefaultTrackingParams
+	}
+
+	c := &Canonicalizer{exact: make(map[string]bool)}
+	for _, param := range trackingParams {
+		param = strings.ToLower(strings.TrimSpace(param))
+		switch {
+		case param == "":
+		case strings.HasSuffix(param, "*"):
+			c.prefixes = append(c.prefixes, strings.TrimSuffix(param, "*"))
+		default:
+			c.exact[param] = true
+		}
+	}
+	return c
+}
+
+// Canonicalize forma canónica de u (no lo modifica). El scheme y el host se asumen ya
+// normalizados (minúsculas, sin puerto por defecto).
+func (c *Canonicalizer) Canonicalize(u *url.URL) Canonical {
+	escapedPath := CanonicalEscapedPath(u.EscapedPath())
+
+	result := (&url.URL{Scheme: u.Scheme, User: u.User, Host: u.Host}).String() + escapedPath
+	if query := c.canonicalQuery(u.RawQuery); query != "" {
+		result += "?" + query
+	}
+
+	return Canonical{
+		URL:  result,
+		Path: decodePath(escapedPath),
+	}
+}
+
+// DecodedPath path canónico decodificado de u (el que se guarda en threat_paths.path)
+func DecodedPath(u *url.URL) string {
+	return decodePath(CanonicalEscapedPath(u.EscapedPath()))
+}
+
+// CanonicalEscapedPath normaliza un path escapado: percent-encoding en minúsculas,
+// caracteres no reservados decodificados, segmentos . y .. resueltos y barras duplicadas
+// colapsadas. Conserva la barra final.
+func CanonicalEscapedPath(escapedPath string) string {
+	return removeDotSegments(normalizePercentEncoding(escapedPath))
+}
+
+// canonicalQuery query sin parámetros de tracking ni vacíos, con el percent-encoding
+// normalizado y ordenada por nombre (y valor, para nombres repetidos)
+func (c *Canonicalizer) canonicalQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	type param struct{ key, pair string }
+	var params []param
+	for _, pair := range strings.Split(rawQuery, "&") {
+		if pair == "" {
+			continue
+		}
+		pair = normalizePercentEncoding(pair)
+		key, _, _ := strings.Cut(pair, "=")
+		if c.isTracking(key) {
+			continue
+		}
+		params = append(params, param{key: key, pair: pair})
+	}
+
+	sort.SliceStable(params, func(i, j int) bool {
+		if params[i].key != params[j].key {
+			return params[i].key < params[j].key
+		}
+		return params[i].pair < params[j].pair
+	})
+
+	pairs := make([]string, len(params))
+	for i, p := range params {
+		pairs[i] = p.pair
+	}
+	return strings.Join(pairs, "&")
+}
+
+// isTracking el parámetro (nombre escapado) está en la lista de tracking
+func (c *Canonicalizer) isTracking(key string) bool {
+	if decoded, err := url.QueryUnescape(key); err == nil {
+		key = decoded
+	}
+	key = strings.ToLower(key)
+
+	if c.exact[key] {
+		return true
+	}
+	for _, prefix := range c.prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizePercentEncoding decodifica los caracteres no reservados (RFC 3986: letras,
+// dígitos, - . _ ~) y pasa a minúsculas el hex del resto de secuencias %XX.
+// Un % que no va seguido de dos dígitos hex se deja tal cual.
+func normalizePercentEncoding(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(decoded) {
+			b.WriteByte(decoded)
+		} else {
+			b.WriteByte('%')
+			b.WriteString(strings.ToLower(s[i+1 : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+// removeDotSegments resuelve . y .. (sin subir de la raíz) y colapsa las barras duplicadas.
+// Un path que termina en /, /. o /.. conserva la barra final.
+func removeDotSegments(path string) string {
+	if path == "" {
+		return "/"
+	}
+
+	trailingSlash := strings.HasSuffix(path, "/") || strings.HasSuffix(path, "/.") || strings.HasSuffix(path, "/..")
+
+	var segments []string
+	for _, segment := range strings.Split(path, "/") {
+		switch segment {
+		case "", ".":
+		case "..":
+			if len(segments) > 0 {
+				segments = segments[:len(segments)-1]
+			}
+		default:
+			segments = append(segments, segment)
+		}
+	}
+
+	result := "/" + strings.Join(segments, "/")
+	if trailingSlash && len(segments) > 0 {
+		result += "/"
+	}
+	return result
+}
+
+// decodePath path escapado a su forma decodificada (igual que url.URL.Path)
+func decodePath(escapedPath string) string {
+	if decoded, err := url.PathUnescape(escapedPath); err == nil {
+		return decoded
+	}
+	return escapedPath
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -2,36 +2,50 @@ package urlengine
 
 import (
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/fy-analysis/internal/checkers"
 )
 
+// checkWeights pesos por defecto de Check (endpoint legacy)
+var checkWeights = map[string]float64{
+	"urlhaus":   0.40, // Base de datos de malware - alta confianza
+	"webrisk":   0.30, // Google Web Risk - buena cobertura
+	"phishtank": 0.20, // PhishTank - especializado en phishing
+	"urlscan":   0.10, // URLScan.io - fallback/complementario
+}
+
 // Aggregator agrega resultados de múltiples checkers y calcula el score final
 type Aggregator struct {
-	// Pesos por defecto si el checker no especifica
+	// Pesos por defecto si el checker no especifica (recargables con SetWeights)
+	weightsMu      sync.RWMutex
 	defaultWeights map[string]float64
 }
 
 // NewAggregator crea un nuevo aggregator
 func NewAggregator() *Aggregator {
-	return &Aggregator{
-		defaultWeights: map[string]float64{
-			"urlhaus":   0.40, // Base de datos de malware - alta confianza
-			"webrisk":   0.30, // Google Web Risk - buena cobertura
-			"phishtank": 0.20, // PhishTank - especializado en phishing
-			"urlscan":   0.10, // URLScan.io - fallback/complementario
-		},
-	}
+	return &Aggregator{defaultWeights: mergeWeights(checkWeights, nil)}
+}
+
+// SetWeights reconstruye los pesos a partir de los por defecto y overrides
+func (a *Aggregator) SetWeights(overrides map[string]float64) {
+	weights := mergeWeights(checkWeights, overrides)
+
+	a.weightsMu.Lock()
+	a.defaultWeights = weights
+	a.weightsMu.Unlock()
 }
 
-// Aggregate combina los resultados y genera la respuesta final
+// Aggregate combina los resultados y genera la respuesta final.
+// Con debug cada fuente incluye los datos crudos del checker.
 func (a *Aggregator) Aggregate(
 	normalized *NormalizeResult,
 	indicators *checkers.Indicators,
 	results []*checkers.CheckResult,
 	startTime time.Time,
+	debug bool,
 ) *URLCheckResponse {
 
 	response := &URLCheckResponse{
@@ -71,12 +85,17 @@ func (a *Aggregator) Aggregate(
 				response.Latency = time.Since(startTime).String()
 
 				// Añadir source info
-				response.Sources = append(response.Sources, SourceResult{
+				sourceResult := SourceResult{
 					Name:    result.Source,
 					Found:   false,
 					Latency: result.Latency.String(),
 					Weight:  a.getWeight(result.Source),
-				})
+				}
+				if debug {
+					sourceResult.RawData = result.RawData
+				}
+				response.Sources = append(response.Sources, sourceResult)
+				response.Scoring = &ScoreBreakdown{BoostFactor: 1.0, Fixed: scoreFixedWhitelisted}
 
 				log.Info().
 					Str("domain", normalized.NormalizedURL).
@@ -89,90 +108,53 @@ func (a *Aggregator) Aggregate(
 	}
 
 	// Procesar cada resultado
-	var totalWeight float64
-	var weightedScore float64
-	var threatsFound int
-
 	for _, result := range results {
-		// Obtener peso del checker
-		weight := a.getWeight(result.Source)
-
-		// Añadir a sources
+		// Añadir a sources (los errores no cuentan en el peso total)
 		sourceResult := SourceResult{
 			Name:    result.Source,
 			Found:   result.Found,
 			Latency: result.Latency.String(),
-			Weight:  weight,
+			Weight:  a.getWeight(result.Source),
 		}
-
 		if result.Error != nil {
 			sourceResult.Error = result.Error.Error()
-			// No contar errores en el peso total
-			response.Sources = append(response.Sources, sourceResult)
-			continue
 		}
-
+		if debug {
+			sourceResult.RawData = result.RawData
+		}
 		response.Sources = append(response.Sources, sourceResult)
-		totalWeight += weight
-
-		// Si encontró amenaza
-		if result.Found {
-			threatsFound++
 
-			// Añadir detalle de amenaza
-			threat := ThreatDetail{
+		// Si encontró amenaza, añadir detalle
+		if result.Error == nil && result.Found {
+			response.Threats = append(response.Threats, ThreatDetail{
 				Source:     result.Source,
 				Type:       result.ThreatType,
 				Confidence: result.Confidence,
 				Tags:       result.Tags,
-			}
-			response.Threats = append(response.Threats, threat)
-
-			// Calcular contribución al score
-			// Score = peso * confianza * 100
-			contribution := weight * result.Confidence * 100
-			weightedScore += contribution
-
-			log.Debug().
-				Str("source", result.Source).
-				Float64("weight", weight).
-				Float64("confidence", result.Confidence).
-				Float64("contribution", contribution).
-				Msg("[Aggregator] Threat contribution calculated")
+			})
 		}
 	}
 
-	// Calcular score final
-	if totalWeight > 0 && threatsFound > 0 {
-		// Normalizar por peso total
-		response.RiskScore = int(weightedScore / totalWeight)
-
-		// Aplicar boost si múltiples fuentes confirman
-		if threatsFound >= 2 {
-			boost := 1.0 + (float64(threatsFound-1) * 0.1) // +10% por cada fuente adicional
-			response.RiskScore = int(float64(response.RiskScore) * boost)
-			log.Debug().
-				Int("threats_found", threatsFound).
-				Float64("boost", boost).
-				Int("boosted_score", response.RiskScore).
-				Msg("[Aggregator] Applied multi-source boost")
-		}
+	// Calcular score final: peso * confianza * 100 normalizado por peso total, con boost
+	// si múltiples fuentes confirman y limitado a 100
+	response.RiskScore, response.Scoring = scoreSources(response.Sources, results)
 
-		// Limitar a 100
-		if response.RiskScore > 100 {
-			response.RiskScore = 100
-		}
-	} else if totalWeight == 0 {
-		// Ningún checker respondió correctamente
-		response.RiskScore = 50 // Incertidumbre
-	} else {
-		// Ninguna amenaza encontrada
-		response.RiskScore = 0
-	}
+	log.Debug().
+		Float64("points_total", response.Scoring.PointsTotal).
+		Float64("boost", response.Scoring.BoostFactor).
+		Int("raw_score", response.Scoring.RawScore).
+		Msg("[Aggregator] Score calculated")
 
 	// Determinar nivel de riesgo
 	response.RiskLevel = GetRiskLevel(response.RiskScore)
 
+	// Sin amenazas actuales pero con historial de amenazas: no se da por segura
+	if response.RiskLevel == RiskLevelSafe && hasHistoricalThreats(results) {
+		response.RiskScore = historicalThreatRisk
+		response.RiskLevel = RiskLevelWarning
+		response.Scoring.Fixed = scoreFixedHistorical
+	}
+
 	// Generar explicación y acción
 	response.Explanation = a.generateExplanation(response, normalized)
 	response.Action = GetRecommendedAction(response.RiskLevel)
@@ -192,6 +174,9 @@ func (a *Aggregator) Aggregate(
 
 // getWeight obtiene el peso de un checker
 func (a *Aggregator) getWeight(source string) float64 {
+	a.weightsMu.RLock()
+	defer a.weightsMu.RUnlock()
+
 	if weight, exists := a.defaultWeights[source]; exists {
 		return weight
 	}
@@ -216,7 +201,9 @@ func (a *Aggregator) generateExplanation(response *URLCheckResponse, normalized
 		}
 
 	case RiskLevelWarning:
-		if len(response.Threats) > 0 {
+		if response.Scoring != nil && response.Scoring.Fixed == scoreFixedHistorical {
+			explanation += ReasonsES["historical_threats"] + ". Se recomienda precaución."
+		} else if len(response.Threats) > 0 {
 			explanation += "Se encontraron indicios de posible amenaza. "
 			for _, t := range response.Threats {
 				explanation += fmt.Sprintf("[%s: %s] ", t.Source, t.Type)
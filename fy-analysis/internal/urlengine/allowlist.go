@@ -0,0 +1,76 @@
+package urlengine
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+// allowlistReason razón devuelta cuando el indicador está en la lista de confianza del usuario
+const allowlistReason = "En tu lista de confianza personal"
+
+// matchAllowlist comprueba si el indicador normalizado está en la lista de confianza del usuario.
+// Emails y teléfonos requieren coincidencia exacta; las URLs coinciden por sufijo de dominio.
+func matchAllowlist(indicators *checkers.Indicators, items []string) (string, bool) {
+	for _, item := range items {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+
+		switch indicators.InputType {
+		case checkers.InputTypeURL:
+			domain := allowlistDomain(item)
+			if domain == "" {
+				continue
+			}
+			if indicators.Domain == domain || strings.HasSuffix(indicators.Domain, "."+domain) {
+				return item, true
+			}
+		case checkers.InputTypeEmail, checkers.InputTypeHash:
+			if strings.ToLower(indicators.Normalized) == item {
+				return item, true
+			}
+		case checkers.InputTypePhone:
+			if indicators.Normalized == allowlistPhone(item) {
+				return item, true
+			}
+		}
+	}
+	return "", false
+}
+
+// allowlistDomain extrae el dominio de una entrada (acepta "empresa.com" o "https://empresa.com/...")
+func allowlistDomain(item string) string {
+	if strings.Contains(item, "://") {
+		if parsed, err := url.Parse(item); err == nil {
+			return strings.TrimPrefix(parsed.Hostname(), "www.")
+		}
+		return ""
+	}
+	item = strings.SplitN(item, "/", 2)[0]
+	return strings.TrimPrefix(item, "www.")
+}
+
+// allowlistPhone limpia un teléfono de la lista igual que NormalizePhone (dígitos y +, 00 -> +)
+func allowlistPhone(item string) string {
+	var b strings.Builder
+	for _, r := range item {
+		if (r >= '0' && r <= '9') || r == '+' {
+			b.WriteRune(r)
+		}
+	}
+	cleaned := b.String()
+	if strings.HasPrefix(cleaned, "00") {
+		cleaned = "+" + cleaned[2:]
+	}
+	if !strings.HasPrefix(cleaned, "+") {
+		if len(cleaned) == 9 {
+			cleaned = "+34" + cleaned
+		} else if len(cleaned) > 9 {
+			cleaned = "+" + cleaned
+		}
+	}
+	return cleaned
+}
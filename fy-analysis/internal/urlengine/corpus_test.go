@@ -0,0 +1,334 @@
+package urlengine
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/correlation"
+)
+
+// TestCorpus pasa un corpus etiquetado (URLs oficiales, phishing, premium, emails desechables...)
+// por las heurísticas y por el engine sin checkers, y comprueba dos capas:
+//
+//   - Heurísticas: el score de HeuristicEngine.Analyze cae en la banda de su etiqueta.
+//   - Extremo a extremo: el risk_score de Engine.Analyze (heurísticas + agregación, sin red y
+//     con una DB local que no conoce el input) cae en la banda de la etiqueta.
+//
+// Además compara cada entrada con el fichero golden: cualquier cambio de score o de flags
+// aparece como diff, aunque siga dentro de la banda. Tras cambiar una regla a propósito,
+// -update reescribe el golden y el diff del commit muestra exactamente qué entradas se movieron:
+//
+//	go test ./internal/urlengine -run Corpus -update
+var updateCorpus = flag.Bool("update", false, "reescribir el golden del corpus de heurísticas con los scores actuales")
+
+const (
+	corpusPath = "testdata/heuristics-corpus/corpus.jsonl"
+	goldenPath = "testdata/heuristics-corpus/golden.jsonl"
+)
+
+// corpusEntry input etiquetado del corpus (una línea JSON)
+type corpusEntry struct {
+	Label         string `json:"label"`
+	Type          string `json:"type"`
+	Input         string `json:"input"`
+	ClaimedSender string `json:"claimed_sender,omitempty"`
+	Text          string `json:"text,omitempty"`
+	// KnownIssue fallo de regla conocido: la entrada se sale de su banda sin fallar el test
+	// (el golden la sigue fijando). Se quita cuando se corrige la regla.
+	KnownIssue string `json:"known_issue,omitempty"`
+}
+
+// id clave estable de la entrada en el golden
+func (e corpusEntry) id() string {
+	id := e.Type + ":" + e.Input
+	if e.ClaimedSender != "" {
+		id += " as " + e.ClaimedSender
+	}
+	return id
+}
+
+// corpusScore resultado de una entrada (una línea JSON del golden)
+type corpusScore struct {
+	ID             string   `json:"id"`
+	Label          string   `json:"label"`
+	HeuristicScore int      `json:"heuristic_score"`
+	Flags          []string `json:"flags"`
+	RiskScore      int      `json:"risk_score"`
+	RiskLevel      string   `json:"risk_level"`
+}
+
+// corpusBand rangos esperados para una etiqueta: score de las heurísticas y risk_score de extremo a extremo
+type corpusBand struct {
+	minHeuristic, maxHeuristic int
+	minRisk, maxRisk           int
+}
+
+// corpusBands bandas por etiqueta. Un input benigno no suma puntos; uno malicioso suma al menos lo
+// que aporta su regla más débil. De extremo a extremo, con la DB local limpia las heurísticas pesan
+// 0.15 frente a 0.30 y por sí solas no sacan un input de safe (risk <= 20): la banda exige que
+// lo suban, no que lleguen a warning.
+var corpusBands = map[string]corpusBand{
+	"official_url":     {minHeuristic: 0, maxHeuristic: 0, minRisk: 0, maxRisk: 0},
+	"benign_url":       {minHeuristic: 0, maxHeuristic: 15, minRisk: 0, maxRisk: 5},
+	"phishing_url":     {minHeuristic: 25, maxHeuristic: 1000, minRisk: 8, maxRisk: 100},
+	"official_email":   {minHeuristic: 0, maxHeuristic: 0, minRisk: 0, maxRisk: 0},
+	"benign_email":     {minHeuristic: 0, maxHeuristic: 15, minRisk: 0, maxRisk: 5},
+	"phishing_email":   {minHeuristic: 25, maxHeuristic: 1000, minRisk: 8, maxRisk: 100},
+	"disposable_email": {minHeuristic: 30, maxHeuristic: 1000, minRisk: 10, maxRisk: 100},
+	"benign_phone":     {minHeuristic: 0, maxHeuristic: 15, minRisk: 0, maxRisk: 5},
+	"premium_phone":    {minHeuristic: 50, maxHeuristic: 1000, minRisk: 15, maxRisk: 100},
+	"scam_phone":       {minHeuristic: 35, maxHeuristic: 1000, minRisk: 10, maxRisk: 100},
+}
+
+func TestCorpus(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(level)
+
+	corpus, err := loadCorpus(corpusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := scoreCorpus(corpus)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *updateCorpus {
+		if err := writeGolden(goldenPath, results); err != nil {
+			t.Fatal(err)
+		}
+		t.Logf("golden updated: %d entries -> %s", len(results), goldenPath)
+	}
+
+	golden, err := loadGolden(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var bandFailures, known, moved int
+	for i, result := range results {
+		e := corpus[i]
+
+		bandMsg := checkBand(e.Label, result)
+		switch {
+		case bandMsg != "" && e.KnownIssue != "":
+			known++
+			t.Logf("KNOWN %s: %s (%s)", result.ID, bandMsg, e.KnownIssue)
+		case bandMsg != "":
+			bandFailures++
+			t.Errorf("%s %s: %s (heuristic=%d risk=%d %s flags=%s)", e.Label, result.ID, bandMsg,
+				result.HeuristicScore, result.RiskScore, result.RiskLevel, strings.Join(result.Flags, ","))
+		case e.KnownIssue != "":
+			t.Logf("FIXED %s is back in its band: remove known_issue from the corpus", result.ID)
+		}
+		if msg := diffGolden(golden[result.ID], result); msg != "" {
+			moved++
+			t.Errorf("%s %s: %s", e.Label, result.ID, msg)
+		}
+	}
+
+	t.Logf("entries=%d band_failures=%d known_issues=%d moved=%d", len(results), bandFailures, known, moved)
+	if moved > 0 {
+		t.Log("scores changed; if intended, run with -update and review the golden diff")
+	}
+}
+
+// loadCorpus lee el corpus y valida etiquetas, tipos e ids únicos
+func loadCorpus(path string) ([]corpusEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open corpus: %w", err)
+	}
+	defer file.Close()
+
+	var corpus []corpusEntry
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var e corpusEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return nil, fmt.Errorf("corpus line %d: %w", lineNum, err)
+		}
+		if _, ok := corpusBands[e.Label]; !ok {
+			return nil, fmt.Errorf("corpus line %d: unknown label %q", lineNum, e.Label)
+		}
+		switch checkers.InputType(e.Type) {
+		case checkers.InputTypeURL, checkers.InputTypeEmail, checkers.InputTypePhone:
+		default:
+			return nil, fmt.Errorf("corpus line %d: unknown type %q", lineNum, e.Type)
+		}
+		if seen[e.id()] {
+			return nil, fmt.Errorf("corpus line %d: duplicate entry %s", lineNum, e.id())
+		}
+		seen[e.id()] = true
+		corpus = append(corpus, e)
+	}
+	return corpus, scanner.Err()
+}
+
+// scoreCorpus puntúa cada entrada con las heurísticas y con el engine sin checkers
+func scoreCorpus(corpus []corpusEntry) ([]corpusScore, error) {
+	ctx := context.Background()
+
+	normalizer := NewNormalizer()
+	normalizer.SetOffline(true)
+	heuristics := correlation.NewHeuristicEngine()
+	engine := NewOfflineEngine(cleanLocalDB{})
+
+	results := make([]corpusScore, 0, len(corpus))
+	for _, e := range corpus {
+		inputType := checkers.InputType(e.Type)
+		var analysisCtx *checkers.AnalysisContext
+		if e.ClaimedSender != "" || e.Text != "" {
+			analysisCtx = &checkers.AnalysisContext{ClaimedSender: e.ClaimedSender, OriginalText: e.Text}
+		}
+
+		indicators, err := normalizer.NormalizeInput(ctx, e.Input, inputType)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.id(), err)
+		}
+		heuristic := heuristics.Analyze(ctx, indicators, analysisCtx)
+
+		response := engine.Analyze(ctx, &AnalysisRequest{
+			Input:   e.Input,
+			Type:    inputType,
+			Context: analysisCtx,
+		})
+
+		results = append(results, corpusScore{
+			ID:             e.id(),
+			Label:          e.Label,
+			HeuristicScore: heuristic.Score,
+			Flags:          uniqueSorted(heuristic.Flags),
+			RiskScore:      response.RiskScore,
+			RiskLevel:      response.RiskLevel,
+		})
+	}
+	return results, nil
+}
+
+// cleanLocalDB simula una DB local que no conoce ningún input: los scores de extremo a
+// extremo son los de un input nuevo, que solo pueden subir las heurísticas
+type cleanLocalDB struct{}
+
+func (cleanLocalDB) Name() string                     { return "localdb" }
+func (cleanLocalDB) Weight() float64                  { return 0.50 }
+func (cleanLocalDB) IsEnabled() bool                  { return true }
+func (cleanLocalDB) Health(ctx context.Context) error { return nil }
+func (cleanLocalDB) Close() error                     { return nil }
+
+func (cleanLocalDB) SupportedTypes() []checkers.InputType {
+	return []checkers.InputType{checkers.InputTypeURL, checkers.InputTypeEmail, checkers.InputTypePhone}
+}
+
+func (c cleanLocalDB) Check(ctx context.Context, indicators *checkers.Indicators) (*checkers.CheckResult, error) {
+	return &checkers.CheckResult{Source: c.Name(), Found: false, RawData: map[string]interface{}{}}, nil
+}
+
+// uniqueSorted flags sin repetir y ordenados (las reglas iteran mapas: el orden no es estable)
+func uniqueSorted(flags []string) []string {
+	set := map[string]bool{}
+	unique := []string{}
+	for _, f := range flags {
+		if !set[f] {
+			set[f] = true
+			unique = append(unique, f)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// checkBand describe por qué el resultado está fuera de la banda de su etiqueta ("" si no lo está)
+func checkBand(label string, result corpusScore) string {
+	b := corpusBands[label]
+	if result.HeuristicScore < b.minHeuristic || result.HeuristicScore > b.maxHeuristic {
+		return fmt.Sprintf("heuristic score %d outside band [%d, %d]", result.HeuristicScore, b.minHeuristic, b.maxHeuristic)
+	}
+	if result.RiskScore < b.minRisk || result.RiskScore > b.maxRisk {
+		return fmt.Sprintf("risk score %d outside band [%d, %d]", result.RiskScore, b.minRisk, b.maxRisk)
+	}
+	return ""
+}
+
+// diffGolden describe el cambio respecto al golden ("" si coincide)
+func diffGolden(golden *corpusScore, result corpusScore) string {
+	if golden == nil {
+		return "not in golden (run with -update)"
+	}
+
+	var changes []string
+	if golden.HeuristicScore != result.HeuristicScore {
+		changes = append(changes, fmt.Sprintf("heuristic %d -> %d", golden.HeuristicScore, result.HeuristicScore))
+	}
+	if strings.Join(golden.Flags, ",") != strings.Join(result.Flags, ",") {
+		changes = append(changes, fmt.Sprintf("flags [%s] -> [%s]", strings.Join(golden.Flags, ","), strings.Join(result.Flags, ",")))
+	}
+	if golden.RiskScore != result.RiskScore || golden.RiskLevel != result.RiskLevel {
+		changes = append(changes, fmt.Sprintf("risk %d/%s -> %d/%s", golden.RiskScore, golden.RiskLevel, result.RiskScore, result.RiskLevel))
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+	return "moved: " + strings.Join(changes, "; ")
+}
+
+// loadGolden lee el golden indexado por id (vacío si aún no existe)
+func loadGolden(path string) (map[string]*corpusScore, error) {
+	golden := map[string]*corpusScore{}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return golden, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open golden: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var s corpusScore
+		if err := json.Unmarshal(scanner.Bytes(), &s); err != nil {
+			return nil, fmt.Errorf("invalid golden line: %w", err)
+		}
+		golden[s.ID] = &s
+	}
+	return golden, scanner.Err()
+}
+
+// writeGolden una línea por entrada, en el orden del corpus, para que el diff sea legible
+func writeGolden(path string, results []corpusScore) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create golden: %w", err)
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	for _, result := range results {
+		line, err := json.Marshal(result)
+		if err != nil {
+			return err
+		}
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+	return w.Flush()
+}
@@ -0,0 +1,250 @@
+package urlengine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v3"
+)
+
+// EmailDomainRule cómo trata un proveedor las variantes de una misma dirección
+type EmailDomainRule struct {
+	Domains         []string `yaml:"domains"`
+	RemoveDots      bool     `yaml:"remove_dots"`      // u.s.e.r@gmail.com == user@gmail.com
+	StripAfter      string   `yaml:"strip_after"`      // Separador de sub-direcciones: "+" (user+tag), "-" (Yahoo)
+	CanonicalDomain string   `yaml:"canonical_domain"` // googlemail.com -> gmail.com
+}
+
+// EmailDomainRules reglas de normalización por dominio (fichero EMAIL_RULES_FILE)
+type EmailDomainRules struct {
+	Rules []EmailDomainRule `yaml:"rules"`
+
+	byDomain map[string]*EmailDomainRule
+}
+
+// EmailRuleAnyDomain dominio de la regla que se aplica a los dominios sin regla propia
+const EmailRuleAnyDomain = "*"
+
+// DefaultEmailDomainRules reglas incluidas: Gmail y Yahoo, y "+tag" para el resto de dominios
+// (la mayoría de proveedores entregan user+tag en el buzón de user). Los dominios de Google
+// Workspace se añaden en el fichero YAML (no se pueden detectar sin consultar el MX).
+func DefaultEmailDomainRules() *EmailDomainRules {
+	rules := &EmailDomainRules{Rules: []EmailDomainRule{
+		{Domains: []string{"gmail.com"}, RemoveDots: true, StripAfter: "+"},
+		{Domains: []string{"googlemail.com"}, RemoveDots: true, StripAfter: "+", CanonicalDomain: "gmail.com"},
+		{Domains: []string{"yahoo.com", "yahoo.es", "ymail.com"}, StripAfter: "-"},
+		{Domains: []string{EmailRuleAnyDomain}, StripAfter: "+"},
+	}}
+	rules.index()
+	return rules
+}
+
+// LoadEmailDomainRules carga las reglas desde un fichero YAML
+func LoadEmailDomainRules(path string) (*EmailDomainRules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read email rules: %w", err)
+	}
+
+	var rules EmailDomainRules
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid email rules: %w", err)
+	}
+	for i, rule := range rules.Rules {
+		if len(rule.Domains) == 0 {
+			return nil, fmt.Errorf("email rule %d has no domains", i)
+		}
+		if len(rule.StripAfter) > 1 {
+			return nil, fmt.Errorf("email rule %d: strip_after must be a single character", i)
+		}
+	}
+
+	rules.index()
+	return &rules, nil
+}
+
+func (r *EmailDomainRules) index() {
+	r.byDomain = make(map[string]*EmailDomainRule)
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		rule.CanonicalDomain = strings.ToLower(strings.TrimSpace(rule.CanonicalDomain))
+		for _, domain := range rule.Domains {
+			r.byDomain[strings.ToLower(strings.TrimSpace(domain))] = rule
+		}
+	}
+}
+
+// lookup regla del dominio o, si no tiene, la de EmailRuleAnyDomain (nil si tampoco existe)
+func (r *EmailDomainRules) lookup(domain string) *EmailDomainRule {
+	if r == nil {
+		return nil
+	}
+	if rule, ok := r.byDomain[domain]; ok {
+		return rule
+	}
+	return r.byDomain[EmailRuleAnyDomain]
+}
+
+// EmailNormalizer forma canónica de un email para buscarlo en las listas negras
+type EmailNormalizer struct {
+	rules *EmailDomainRules
+}
+
+// NewEmailNormalizer crea el normalizador (nil = reglas por defecto)
+func NewEmailNormalizer(rules *EmailDomainRules) *EmailNormalizer {
+	if rules == nil {
+		rules = DefaultEmailDomainRules()
+	}
+	return &EmailNormalizer{rules: rules}
+}
+
+// Rules reglas con las que normaliza
+func (n *EmailNormalizer) Rules() *EmailDomainRules {
+	return n.rules
+}
+
+// NormalizeForLookup aplica case folding y las reglas del dominio (sub-direcciones, puntos,
+// dominio canónico). domainRules nil usa las reglas del normalizador.
+// Si el email no tiene forma válida se devuelve solo en minúsculas.
+func (n *EmailNormalizer) NormalizeForLookup(email string, domainRules *EmailDomainRules) string {
+	if domainRules == nil {
+		domainRules = n.rules
+	}
+
+	email = strings.ToLower(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 {
+		return email
+	}
+	local, domain := email[:at], email[at+1:]
+
+	rule := domainRules.lookup(domain)
+	if rule == nil {
+		return email
+	}
+
+	if rule.StripAfter != "" {
+		if i := strings.Index(local, rule.StripAfter); i >= 0 {
+			local = local[:i]
+		}
+	}
+	if rule.RemoveDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+	if local == "" {
+		// "+tag@gmail.com" no es una dirección real: no se colapsa con otras
+		return email
+	}
+	if rule.CanonicalDomain != "" {
+		domain = rule.CanonicalDomain
+	}
+
+	return local + "@" + domain
+}
+
+// SyncToDB copia las reglas a email_normalization_rules, de la que el trigger de threat_emails
+// calcula email_normalized. Si cambian, recalcula los emails de los dominios afectados.
+// Un cambio de la regla EmailRuleAnyDomain afecta a toda la tabla: no se recalcula al arrancar,
+// se hace por lotes con la acción de fy-admin POST /api/actions/email-normalize.
+func (r *EmailDomainRules) SyncToDB(ctx context.Context, db *sql.DB) error {
+	current, err := loadDBEmailRules(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	desired := make(map[string]EmailDomainRule)
+	for domain, rule := range r.byDomain {
+		desired[domain] = *rule
+	}
+
+	var changed []string
+	for domain, rule := range desired {
+		if old, ok := current[domain]; !ok || old.RemoveDots != rule.RemoveDots ||
+			old.StripAfter != rule.StripAfter || old.CanonicalDomain != rule.CanonicalDomain {
+			changed = append(changed, domain)
+		}
+	}
+	for domain := range current {
+		if _, ok := desired[domain]; !ok {
+			changed = append(changed, domain)
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	sort.Strings(changed)
+
+	recalculate := make([]string, 0, len(changed))
+	for _, domain := range changed {
+		if domain == EmailRuleAnyDomain {
+			log.Warn().Msg("[EmailNorm] Default email rule changed: run the email normalization backfill from fy-admin")
+			continue
+		}
+		recalculate = append(recalculate, domain)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM email_normalization_rules`); err != nil {
+		return err
+	}
+	for domain, rule := range desired {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO email_normalization_rules (domain, remove_dots, strip_after, canonical_domain)
+			VALUES ($1, $2, NULLIF($3, ''), NULLIF($4, ''))
+		`, domain, rule.RemoveDots, rule.StripAfter, rule.CanonicalDomain); err != nil {
+			return err
+		}
+	}
+
+	// El trigger recalcula email_normalized al tocar la fila
+	result, err := tx.ExecContext(ctx, `
+		UPDATE threat_emails SET email_normalized = NULL
+		WHERE lower(split_part(email, '@', 2)) = ANY(string_to_array($1, ','))
+	`, strings.Join(recalculate, ","))
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	updated, _ := result.RowsAffected()
+	log.Info().
+		Strs("domains", changed).
+		Int64("emails_renormalized", updated).
+		Msg("[EmailNorm] Email normalization rules updated")
+	return nil
+}
+
+// loadDBEmailRules reglas guardadas en email_normalization_rules, por dominio
+func loadDBEmailRules(ctx context.Context, db *sql.DB) (map[string]EmailDomainRule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT domain, remove_dots, COALESCE(strip_after, ''), COALESCE(canonical_domain, '')
+		FROM email_normalization_rules
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[string]EmailDomainRule)
+	for rows.Next() {
+		var domain string
+		var rule EmailDomainRule
+		if err := rows.Scan(&domain, &rule.RemoveDots, &rule.StripAfter, &rule.CanonicalDomain); err != nil {
+			return nil, err
+		}
+		rules[domain] = rule
+	}
+	return rules, rows.Err()
+}
@@ -2,15 +2,27 @@ package urlengine
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	gosync "sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/analyzer/phone"
 	"github.com/trackfy/fy-analysis/internal/checkers"
 	"github.com/trackfy/fy-analysis/internal/correlation"
+	"github.com/trackfy/fy-analysis/internal/disposable"
+	"github.com/trackfy/fy-analysis/internal/models"
+	"github.com/trackfy/fy-analysis/internal/notifications"
+	"github.com/trackfy/fy-analysis/internal/promotion"
 	"github.com/trackfy/fy-analysis/internal/sync"
+	"github.com/trackfy/fy-analysis/internal/tldrisk"
+	"github.com/trackfy/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // normalizePhone normaliza un número de teléfono quitando espacios y caracteres especiales
@@ -26,42 +38,70 @@ func normalizePhone(phone string) string {
 
 // Engine es el motor principal de verificación de URLs, emails y teléfonos
 type Engine struct {
-	orchestrator       *Orchestrator
+	// mu protege orchestrator, weights y los campos recargables de config (ReloadConfig)
+	mu           gosync.RWMutex
+	orchestrator *Orchestrator
+	allCheckers  []checkers.ThreatChecker // Construidos al arrancar (incluye los desactivados en caliente)
+	weights      map[string]float64       // Pesos de Analyze: analysisSourceWeights + CheckerWeights
+
 	normalizer         *Normalizer
 	aggregator         *Aggregator
 	heuristics         *correlation.HeuristicEngine
+	phoneHeuristics    *correlation.PhoneHeuristicAdapter
 	dbSyncer           *sync.DBSyncer
+	promoter           *promotion.ThreatPromoter
 	userReportsChecker *checkers.UserReportsChecker
+	urlscanPoller      *checkers.ResultPoller // Resultados de las URLs enviadas a urlscan.io
+	localDB            *checkers.LocalDBChecker
+	phoneDB            checkers.ThreatChecker         // Reportes de LookupPhone (LocalDB; un stub en los tests)
+	webhooks           *notifications.WebhookNotifier // Notificaciones de amenazas (WEBHOOKS_FILE)
+	phoneCache         *phoneLookupCache
+	limiter            *analysisLimiter // Cuotas por llamante y análisis simultáneos del orquestador
 	config             *EngineConfig
 }
 
-// EngineConfig configuración del engine
-type EngineConfig struct {
-	CheckTimeout       time.Duration
-	URLhausDBPath      string
-	PhishTankDBPath    string
-	GoogleWebRiskKey   string
-	URLScanKey         string
-	PhishTankKey       string
-	EnableDBSync       bool
-	DatabaseURL        string
-	EnableLocalDB      bool
-	EnableUserReports  bool // Habilitar checker de reportes de usuarios
-}
+// EngineConfig configuración del engine (definida en checkers, que la pasa a las factories del registro)
+type EngineConfig = checkers.EngineConfig
 
 // DefaultConfig retorna la configuración por defecto
 func DefaultConfig() *EngineConfig {
 	return &EngineConfig{
-		CheckTimeout:      3 * time.Second,
-		URLhausDBPath:     getEnv("URLHAUS_DB_PATH", "/app/data/urlhaus.csv"),
-		PhishTankDBPath:   getEnv("PHISHTANK_DB_PATH", "/app/data/phishtank.json"),
-		GoogleWebRiskKey:  getEnv("GOOGLE_WEBRISK_KEY", ""),
-		URLScanKey:        getEnv("URLSCAN_KEY", ""),
-		PhishTankKey:      getEnv("PHISHTANK_KEY", ""),
-		EnableDBSync:      getEnv("ENABLE_DB_SYNC", "true") == "true",
-		DatabaseURL:       getEnv("DATABASE_URL", ""),
-		EnableLocalDB:     getEnv("ENABLE_LOCAL_DB", "true") == "true",
-		EnableUserReports: getEnv("ENABLE_USER_REPORTS", "true") == "true",
+		CheckTimeout:          3 * time.Second,
+		ConsensusThreshold:    getEnvInt("CONSENSUS_THRESHOLD", 1),
+		CheckerPriorityMode:   getEnv("CHECKER_PRIORITY_MODE", PriorityModeAll),
+		BreakerThreshold:      getEnvInt("CHECKER_BREAKER_THRESHOLD", 5),
+		BreakerCooldown:       defaultBreakerCooldown,
+		URLhausDBPath:         getEnv("URLHAUS_DB_PATH", "/app/data/urlhaus.csv"),
+		PhishTankDBPath:       getEnv("PHISHTANK_DB_PATH", "/app/data/phishtank.json"),
+		GoogleWebRiskKey:      getEnv("GOOGLE_WEBRISK_KEY", ""),
+		URLScanKey:            getEnv("URLSCAN_KEY", ""),
+		PhishTankKey:          getEnv("PHISHTANK_KEY", ""),
+		HIBPKey:               getEnv("HIBP_KEY", ""),
+		VirusTotalKey:         getEnv("VIRUSTOTAL_KEY", ""),
+		MalwareBazaarKey:      getEnv("MALWAREBAZAAR_KEY", ""),
+		SpamdAddress:          getEnv("SPAMD_ADDRESS", ""),
+		EnableDBSync:          getEnv("ENABLE_DB_SYNC", "true") == "true",
+		EnableFileSync:        getEnv("ENABLE_FILE_SYNC", "true") == "true",
+		EnableFeedDBSync:      getEnv("ENABLE_FEED_DB_SYNC", "true") == "true",
+		DatabaseURL:           getEnv("DATABASE_URL", ""),
+		EnableLocalDB:         getEnv("ENABLE_LOCAL_DB", "true") == "true",
+		LocalDBMaxConns:       10,
+		ConfidenceDecayLambda: getEnvFloat("CONFIDENCE_DECAY_LAMBDA", 0),
+		EnableUserReports:     getEnv("ENABLE_USER_REPORTS", "true") == "true",
+		EnableThreatPromotion: getEnv("ENABLE_THREAT_PROMOTION", "true") == "true",
+		EnableDomainAge:       getEnv("ENABLE_DOMAIN_AGE", "true") == "true",
+		EnableVisual:          getEnv("ENABLE_VISUAL_CHECKER", "false") == "true",
+		EnableEmailDNS:        getEnv("ENABLE_EMAIL_DNS", "true") == "true",
+		EmailRulesFile:        getEnv("EMAIL_RULES_FILE", ""),
+		WebhooksFile:          getEnv("WEBHOOKS_FILE", ""),
+		DisposableURL:         getEnv("DISPOSABLE_DOMAINS_URL", ""),
+		DisposableInterval:    24 * time.Hour,
+		TLDRiskFile:           getEnv("TLD_RISK_FILE", ""),
+		ChromeURL:             getEnv("CHROME_URL", ""),
+		PhoneLookupTimeout:    120 * time.Millisecond,
+		PhoneLookupTTL:        10 * time.Minute,
+		MinAnalysisTimeout:    200 * time.Millisecond,
+		MaxAnalysisTimeout:    10 * time.Second,
 	}
 }
 
@@ -70,6 +110,18 @@ func NewEngine(config *EngineConfig) *Engine {
 	if config == nil {
 		config = DefaultConfig()
 	}
+	if config.PhoneLookupTimeout <= 0 {
+		config.PhoneLookupTimeout = 120 * time.Millisecond
+	}
+	if config.PhoneLookupTTL <= 0 {
+		config.PhoneLookupTTL = 10 * time.Minute
+	}
+	if config.MinAnalysisTimeout <= 0 {
+		config.MinAnalysisTimeout = 200 * time.Millisecond
+	}
+	if config.MaxAnalysisTimeout < config.MinAnalysisTimeout {
+		config.MaxAnalysisTimeout = 10 * time.Second
+	}
 
 	log.Info().
 		Dur("timeout", config.CheckTimeout).
@@ -80,97 +132,147 @@ func NewEngine(config *EngineConfig) *Engine {
 		Bool("db_sync", config.EnableDBSync).
 		Msg("[Engine] Initializing URL Verification Engine")
 
-	// Crear checkers
-	var threatCheckers []checkers.ThreatChecker
+	// Checkers registrados en el init() de cada uno (DISABLED_CHECKERS los excluye)
+	threatCheckers := checkers.Registry.Build(config)
+
+	// Referencias tipadas para syncer, heurísticas y endpoints de reportes
+	var (
+		urlhausChecker     *checkers.URLhausChecker
+		phishtankChecker   *checkers.PhishTankChecker
+		localDBChecker     *checkers.LocalDBChecker
+		userReportsChecker *checkers.UserReportsChecker
+		urlscanPoller      *checkers.ResultPoller
+		pageDB             *checkers.PhishingPageDB
+	)
+	for _, c := range threatCheckers {
+		switch c := c.(type) {
+		case *checkers.URLhausChecker:
+			urlhausChecker = c
+		case *checkers.PhishTankChecker:
+			phishtankChecker = c
+		case *checkers.LocalDBChecker:
+			localDBChecker = c
+		case *checkers.UserReportsChecker:
+			userReportsChecker = c
+		case *checkers.URLScanChecker:
+			urlscanPoller = c.Poller()
+		case *checkers.VisualSimilarityChecker:
+			pageDB = c.PageDB()
+		}
+	}
 
-	// URLhaus (local DB)
-	urlhausChecker := checkers.NewURLhausChecker(config.URLhausDBPath)
-	threatCheckers = append(threatCheckers, urlhausChecker)
-	log.Info().Msg("[Engine] URLhaus checker initialized")
+	// Crear orchestrator
+	orchestrator := NewOrchestrator(threatCheckers, config.CheckTimeout)
+	orchestrator.aggregator.SetWeights(config.CheckerWeights)
+	orchestrator.configure(config.CheckerPriorityMode, config.BreakerThreshold, config.BreakerCooldown)
 
-	// PhishTank (local DB)
-	phishtankChecker := checkers.NewPhishTankChecker(config.PhishTankDBPath, config.PhishTankKey)
-	threatCheckers = append(threatCheckers, phishtankChecker)
-	log.Info().Msg("[Engine] PhishTank checker initialized")
+	// Crear syncer para DBs locales
+	var dbSyncer *sync.DBSyncer
+	if config.EnableDBSync {
+		syncerConfig := &sync.DBSyncerConfig{EnableFileSync: config.EnableFileSync, PageDB: pageDB}
+
+		// Escritura de feeds en PostgreSQL (independiente de los archivos locales)
+		if config.EnableFeedDBSync && config.DatabaseURL != "" {
+			writer, err := sync.NewThreatDBWriter(config.DBTLS.ApplyToDSN(config.DatabaseURL))
+			if err != nil {
+				log.Warn().Err(err).Msg("[Engine] Failed to initialize feed DB writer, feeds will not be written to PostgreSQL")
+			} else {
+				syncerConfig.Writer = writer
+				log.Info().Msg("[Engine] Feed DB writer initialized")
+			}
+		}
 
-	// Google Web Risk (API)
-	if config.GoogleWebRiskKey != "" {
-		webRiskChecker := checkers.NewWebRiskChecker(config.GoogleWebRiskKey)
-		threatCheckers = append(threatCheckers, webRiskChecker)
-		log.Info().Msg("[Engine] Google Web Risk checker initialized")
+		dbSyncer = sync.NewDBSyncer(urlhausChecker, phishtankChecker, syncerConfig)
 	}
 
-	// URLScan.io (API)
-	if config.URLScanKey != "" {
-		urlscanChecker := checkers.NewURLScanChecker(config.URLScanKey)
-		threatCheckers = append(threatCheckers, urlscanChecker)
-		log.Info().Msg("[Engine] URLScan.io checker initialized")
+	// Heurísticas (con antigüedad de dominio vía RDAP, cacheada en PostgreSQL si hay LocalDB)
+	heuristics := correlation.NewHeuristicEngine()
+	if config.EnableDomainAge {
+		var cacheDB *sql.DB
+		if localDBChecker != nil && localDBChecker.IsEnabled() {
+			cacheDB = localDBChecker.GetDB()
+		}
+		heuristics.SetDomainAgeLookup(correlation.NewDomainAgeLookup(cacheDB, config.CheckTimeout))
+		log.Info().Bool("cache", cacheDB != nil).Msg("[Engine] RDAP domain age lookup enabled")
 	}
 
-	// LocalDB (PostgreSQL) - Prioridad alta
-	log.Debug().
-		Bool("enable_local_db", config.EnableLocalDB).
-		Bool("has_database_url", config.DatabaseURL != "").
-		Msg("[Engine] Checking LocalDB configuration")
-
-	// Variable para guardar la conexión DB para otros checkers
-	var localDBChecker *checkers.LocalDBChecker
-
-	if config.EnableLocalDB && config.DatabaseURL != "" {
-		log.Info().Msg("[Engine] Initializing LocalDB checker...")
-		localDBChecker = checkers.NewLocalDBChecker(&checkers.LocalDBConfig{
-			DatabaseURL: config.DatabaseURL,
-			MaxConns:    10,
-			Weight:      0.50, // Peso alto para DB local
-		})
-		if localDBChecker.IsEnabled() {
-			// Insertar al inicio para mayor prioridad
-			threatCheckers = append([]checkers.ThreatChecker{localDBChecker}, threatCheckers...)
-			log.Info().Msg("[Engine] LocalDB checker initialized (PostgreSQL)")
-		} else {
-			log.Warn().Msg("[Engine] LocalDB checker failed to initialize")
+	// Operador de los teléfonos (Numverify/HLR, cacheado en PostgreSQL si hay LocalDB)
+	phoneHeuristics := correlation.NewPhoneHeuristicAdapter()
+	if config.EnableCarrierLookup {
+		var cacheDB *sql.DB
+		if localDBChecker != nil && localDBChecker.IsEnabled() {
+			cacheDB = localDBChecker.GetDB()
 		}
-	} else {
-		log.Info().Msg("[Engine] LocalDB checker disabled or no DATABASE_URL")
+		phoneHeuristics.SetCarrierLookup(phone.NewCarrierLookupService(cacheDB, config.CarrierLookupURL, config.CarrierLookupKey, config.CheckTimeout))
+		log.Info().Bool("cache", cacheDB != nil).Msg("[Engine] Phone carrier lookup enabled")
 	}
 
-	// UserReports Checker - Reportes de usuarios con anti-spam
-	var userReportsChecker *checkers.UserReportsChecker
-	if config.EnableUserReports && config.DatabaseURL != "" {
-		log.Info().Msg("[Engine] Initializing UserReports checker...")
-		userReportsChecker = checkers.NewUserReportsChecker(
-			localDBChecker.GetDB(),
-			&checkers.UserReportsConfig{
-				Weight:             0.10, // Peso bajo por ser crowdsourced
-				MinScoreForWarning: 40,
-				MinScoreForDanger:  70,
-				MinReportersForUse: 2,
-			},
-		)
-		if userReportsChecker.IsEnabled() {
-			threatCheckers = append(threatCheckers, userReportsChecker)
-			log.Info().Msg("[Engine] UserReports checker initialized")
+	// Reglas de normalización de emails (sub-direcciones, puntos de Gmail)
+	normalizer := NewNormalizer()
+	normalizer.SetRedirectTimeout(config.CheckTimeout)
+	if len(config.URLTrackingParams) > 0 {
+		normalizer.SetTrackingParams(config.URLTrackingParams)
+	}
+	if config.EmailRulesFile != "" {
+		rules, err := LoadEmailDomainRules(config.EmailRulesFile)
+		if err != nil {
+			log.Error().Err(err).Str("file", config.EmailRulesFile).Msg("[Engine] Failed to load email rules, using defaults")
+		} else {
+			normalizer.SetEmailRules(rules)
+			log.Info().Int("rules", len(rules.Rules)).Msg("[Engine] Email normalization rules loaded")
 		}
 	}
 
-	// Crear orchestrator
-	orchestrator := NewOrchestrator(threatCheckers, config.CheckTimeout)
-
-	// Crear syncer para DBs locales
-	var dbSyncer *sync.DBSyncer
-	if config.EnableDBSync {
-		dbSyncer = sync.NewDBSyncer(urlhausChecker, phishtankChecker)
+	if config.TLDRiskFile != "" {
+		if err := tldrisk.Default.LoadFile(config.TLDRiskFile); err != nil {
+			log.Error().Err(err).Str("file", config.TLDRiskFile).Msg("[Engine] Failed to load TLD risk table, using bundled")
+		} else {
+			log.Info().Int("tlds", tldrisk.Default.Stats().Count).Msg("[Engine] TLD risk table loaded")
+		}
 	}
 
 	engine := &Engine{
 		orchestrator:       orchestrator,
-		normalizer:         NewNormalizer(),
-		aggregator:         NewAggregator(),
-		heuristics:         correlation.NewHeuristicEngine(),
+		allCheckers:        threatCheckers,
+		weights:            mergeWeights(analysisSourceWeights, config.CheckerWeights),
+		normalizer:         normalizer,
+		aggregator:         orchestrator.aggregator,
+		heuristics:         heuristics,
+		phoneHeuristics:    phoneHeuristics,
 		dbSyncer:           dbSyncer,
 		userReportsChecker: userReportsChecker,
+		urlscanPoller:      urlscanPoller,
+		phoneCache:         newPhoneLookupCache(config.PhoneLookupTTL, 10000),
+		limiter:            newAnalysisLimiter(config.AnalysisLimits),
 		config:             config,
 	}
+	if localDBChecker != nil {
+		engine.localDB = localDBChecker
+		engine.phoneDB = localDBChecker
+
+		// Promoción de URLs muy reportadas a threat_domains (cada hora)
+		if config.EnableThreatPromotion && localDBChecker.GetDB() != nil {
+			engine.promoter = promotion.NewThreatPromoter(localDBChecker.GetDB(), time.Hour)
+			if config.GatewayURL != "" {
+				engine.promoter.SetReclassificationNotifier(notifications.NewReclassificationNotifier(config.GatewayURL, config.SigningSecret))
+			}
+		}
+	}
+
+	// Webhooks de amenazas detectadas (entregas registradas en PostgreSQL si hay LocalDB)
+	if config.WebhooksFile != "" {
+		webhooks, err := notifications.LoadWebhookConfigs(config.WebhooksFile)
+		if err != nil {
+			log.Error().Err(err).Str("file", config.WebhooksFile).Msg("[Engine] Failed to load webhooks, notifications disabled")
+		} else if len(webhooks) > 0 {
+			var deliveriesDB *sql.DB
+			if localDBChecker != nil && localDBChecker.IsEnabled() {
+				deliveriesDB = localDBChecker.GetDB()
+			}
+			engine.webhooks = notifications.NewWebhookNotifier(webhooks, deliveriesDB)
+			log.Info().Int("webhooks", len(webhooks)).Bool("tracking", deliveriesDB != nil).Msg("[Engine] Threat webhooks loaded")
+		}
+	}
 
 	log.Info().
 		Int("checkers", len(threatCheckers)).
@@ -187,6 +289,90 @@ func (e *Engine) Start(ctx context.Context) {
 		e.dbSyncer.Start(ctx)
 		log.Info().Msg("[Engine] DB synchronization started")
 	}
+
+	disposable.Default.StartRefresh(ctx, e.config.DisposableURL, e.config.DisposableInterval)
+
+	if e.localDB != nil {
+		go e.refreshStatsLoop(ctx)
+		go e.refreshOfficialDomainsLoop(ctx)
+		go e.syncEmailRules(ctx)
+		tldrisk.Default.StartRefresh(ctx, e.localDB.GetDB(), e.config.TLDRiskInterval)
+	}
+	if e.promoter != nil {
+		e.promoter.Start(ctx)
+	}
+	if e.userReportsChecker != nil && e.userReportsChecker.Bans() != nil {
+		e.userReportsChecker.Bans().Start(ctx)
+	}
+	if e.userReportsChecker != nil && e.userReportsChecker.TrustNetwork() != nil {
+		e.userReportsChecker.TrustNetwork().Start(ctx)
+	}
+	if e.urlscanPoller != nil {
+		e.urlscanPoller.Start(ctx)
+	}
+	if e.webhooks != nil {
+		e.webhooks.Start(ctx)
+	}
+}
+
+// syncEmailRules copia las reglas de normalización de emails a PostgreSQL (trigger de threat_emails)
+func (e *Engine) syncEmailRules(ctx context.Context) {
+	if !e.localDB.IsEnabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, emailRulesSyncTimeout)
+	defer cancel()
+
+	if err := e.normalizer.emailNormalizer.Rules().SyncToDB(ctx, e.localDB.GetDB()); err != nil {
+		log.Warn().Err(err).Msg("[Engine] Failed to sync email normalization rules (migration 007 applied?)")
+	}
+}
+
+// refreshStatsLoop refresca la vista materializada threat_metrics cada statsRefreshInterval
+func (e *Engine) refreshStatsLoop(ctx context.Context) {
+	ticker := time.NewTicker(statsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !e.localDB.IsEnabled() {
+				continue // PostgreSQL caído: se reintenta en el siguiente tick
+			}
+			start := time.Now()
+			if err := e.localDB.RefreshStatsView(ctx); err != nil {
+				log.Warn().Err(err).Msg("[Engine] Failed to refresh threat_metrics view")
+				continue
+			}
+			log.Debug().Dur("duration", time.Since(start)).Msg("[Engine] threat_metrics view refreshed")
+		}
+	}
+}
+
+// refreshOfficialDomainsLoop carga los dominios oficiales de las heurísticas desde
+// whitelist_domains al arrancar y cada statsRefreshInterval
+func (e *Engine) refreshOfficialDomainsLoop(ctx context.Context) {
+	ticker := time.NewTicker(statsRefreshInterval)
+	defer ticker.Stop()
+
+	for {
+		if e.localDB.IsEnabled() {
+			loadCtx, cancel := context.WithTimeout(ctx, officialDomainsLoadTimeout)
+			if err := e.heuristics.LoadOfficialDomains(loadCtx, e.localDB.GetDB()); err != nil {
+				log.Warn().Err(err).Msg("[Engine] Failed to load official domains, keeping previous list")
+			}
+			cancel()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
 }
 
 // Stop detiene el engine
@@ -196,18 +382,107 @@ func (e *Engine) Stop() {
 	if e.dbSyncer != nil {
 		e.dbSyncer.Stop()
 	}
+	if e.promoter != nil {
+		e.promoter.Stop()
+	}
+	if e.userReportsChecker != nil && e.userReportsChecker.Bans() != nil {
+		e.userReportsChecker.Bans().Stop()
+	}
+	if e.userReportsChecker != nil && e.userReportsChecker.TrustNetwork() != nil {
+		e.userReportsChecker.TrustNetwork().Stop()
+	}
+	if e.urlscanPoller != nil {
+		e.urlscanPoller.Stop()
+	}
+	if e.webhooks != nil {
+		e.webhooks.Stop()
+	}
+	disposable.Default.Stop()
+	tldrisk.Default.Stop()
+
+	// Al final: LocalDB cierra el pool que comparten reportes, índice visual y cache RDAP.
+	// Se cierran todos los construidos, también los desactivados en caliente.
+	closeCheckers(e.allCheckers)
 }
 
-// Check verifica una URL (método legacy para compatibilidad)
-func (e *Engine) Check(ctx context.Context, rawURL string) *URLCheckResponse {
+// Health estado real de los checkers habilitados (Health + fallos consecutivos en análisis)
+func (e *Engine) Health(ctx context.Context) *EngineHealth {
+	ctx, cancel := context.WithTimeout(ctx, checkerHealthTimeout)
+	defer cancel()
+
+	health := &EngineHealth{
+		Status:   "healthy",
+		Checkers: make(map[string]CheckerHealth),
+	}
+
+	orchestrator := e.currentOrchestrator()
+	for name, err := range orchestrator.Health(ctx) {
+		checker := CheckerHealth{
+			Healthy:             err == nil,
+			ConsecutiveFailures: orchestrator.consecutiveFailures(name),
+		}
+		if err != nil {
+			checker.Error = err.Error()
+		}
+		if checker.ConsecutiveFailures >= maxConsecutiveFailures {
+			checker.Healthy = false
+		}
+		if !checker.Healthy {
+			health.Status = "degraded"
+		}
+		health.Checkers[name] = checker
+	}
+
+	return health
+}
+
+// Check verifica una URL (método legacy para compatibilidad).
+// Con debug cada fuente incluye los datos crudos del checker.
+func (e *Engine) Check(ctx context.Context, rawURL string, debug bool) *URLCheckResponse {
 	log.Debug().Str("url", rawURL).Msg("[Engine] Check request received")
-	return e.orchestrator.Check(ctx, rawURL)
+	return e.currentOrchestrator().Check(ctx, rawURL, debug)
 }
 
 // Analyze es el punto de entrada unificado para analizar URLs, emails o teléfonos
 func (e *Engine) Analyze(ctx context.Context, req *AnalysisRequest) *AnalysisResponse {
+	return e.AnalyzeStream(ctx, req, nil)
+}
+
+// AnalyzeStream igual que Analyze, pero notifica cada resultado de checker (y de heurísticas)
+// a onResult en cuanto está disponible, antes de la agregación final.
+// Si la petición no declara el tipo, se detecta a partir del input.
+func (e *Engine) AnalyzeStream(ctx context.Context, req *AnalysisRequest, onResult func(*checkers.CheckResult)) *AnalysisResponse {
+	if req.Type != "" {
+		return e.analyze(ctx, req, onResult)
+	}
+
+	detection := e.normalizer.DetectType(req.Input)
+	log.Debug().
+		Str("detected_type", string(detection.Type)).
+		Float64("confidence", detection.Confidence).
+		Str("value", detection.Value).
+		Msg("[Engine] Input type auto-detected")
+
+	detected := *req
+	detected.Type = detection.Type
+	detected.Input = detection.Value
+
+	response := e.analyze(ctx, &detected, onResult)
+	response.Input = req.Input
+	response.TypeDetection = &detection
+	if detection.Ambiguous {
+		response.Reasons = append(response.Reasons, detection.Reason)
+	}
+	return response
+}
+
+// analyze ejecuta el análisis de un input con tipo conocido
+func (e *Engine) analyze(ctx context.Context, req *AnalysisRequest, onResult func(*checkers.CheckResult)) *AnalysisResponse {
 	startTime := time.Now()
 
+	ctx, span := tracing.Tracer().Start(ctx, "Engine.Analyze")
+	defer span.End()
+
 	log.Info().
 		Str("input", req.Input).
 		Str("type", string(req.Type)).
@@ -225,14 +500,45 @@ func (e *Engine) Analyze(ctx context.Context, req *AnalysisRequest) *AnalysisRes
 		Str("hash", indicators.Hash).
 		Msg("[Engine] Input normalized")
 
+	span.SetAttributes(attribute.String("analysis.input_type", string(indicators.InputType)))
+
+	// Lista de confianza personal: no se ejecutan checkers
+	if entry, ok := matchAllowlist(indicators, req.AllowlistItems); ok {
+		log.Info().
+			Str("input", req.Input).
+			Str("allowlist_entry", entry).
+			Msg("[Engine] Input is in user allowlist - returning safe")
+
+		span.SetAttributes(attribute.Bool("analysis.allowlisted", true))
+		return &AnalysisResponse{
+			Input:             req.Input,
+			Type:              req.Type,
+			NormalizedInput:   indicators.Normalized,
+			RiskScore:         0,
+			RiskLevel:         string(RiskLevelSafe),
+			Threats:           []ThreatDetail{},
+			Reasons:           []string{allowlistReason},
+			RecommendedAction: GetActionForLevel(RiskLevelSafe),
+			Sources:           []SourceResult{},
+			ResponseTimeMs:    time.Since(startTime).Milliseconds(),
+			CheckedAt:         time.Now().UTC(),
+			EngineInfo:        e.engineInfo(),
+		}
+	}
+
 	// TODO: 2. Check cache (Redis) - pendiente de implementar
 	// if cached, err := e.cache.Get(ctx, indicators.Hash); err == nil {
 	//     cached.CacheHit = true
 	//     return cached
 	// }
 
-	// 3. Búsqueda paralela en motores (filtrada por tipo)
-	results := e.orchestrator.CheckWithType(ctx, indicators)
+	// 3. Búsqueda paralela en motores (filtrada por tipo, con el contexto y el timeout del usuario)
+	checkCtx := checkers.WithAnalysisContext(ctx, req.Context)
+	if timeout := e.requestCheckTimeout(req.TimeoutMs); timeout > 0 {
+		checkCtx = withCheckTimeout(checkCtx, timeout)
+	}
+	results := e.currentOrchestrator().CheckWithTypeStream(checkCtx, indicators, onResult)
+	timedOut := timedOutCheckers(results)
 
 	log.Debug().
 		Int("checker_results", len(results)).
@@ -240,8 +546,19 @@ func (e *Engine) Analyze(ctx context.Context, req *AnalysisRequest) *AnalysisRes
 
 	// 4. Correlación heurística
 	heuristicResult := e.heuristics.Analyze(ctx, indicators, req.Context)
+
+	// Teléfonos: heurísticas de línea (país, VoIP, contexto) de phone.Analyzer
+	var phoneInfo *models.PhoneInfo
+	if indicators.InputType == checkers.InputTypePhone {
+		phoneInfo = e.phoneHeuristics.Apply(ctx, indicators, req.Context, heuristicResult)
+	}
+
 	if heuristicResult.Score > 0 {
-		results = append(results, e.heuristics.ToCheckResult(heuristicResult))
+		heuristicCheck := e.heuristics.ToCheckResult(heuristicResult)
+		results = append(results, heuristicCheck)
+		if onResult != nil {
+			onResult(heuristicCheck)
+		}
 		log.Debug().
 			Int("heuristic_score", heuristicResult.Score).
 			Strs("heuristic_flags", heuristicResult.Flags).
@@ -249,7 +566,8 @@ func (e *Engine) Analyze(ctx context.Context, req *AnalysisRequest) *AnalysisRes
 	}
 
 	// 5. Agregar resultados y calcular score
-	score, level, reasons := e.aggregateAnalysisResults(results, heuristicResult)
+	sources := e.buildSourceResults(results, req.Debug)
+	score, level, reasons, scoring := e.aggregateAnalysisResults(results, heuristicResult, sources)
 
 	// 6. Construir respuesta
 	response := &AnalysisResponse{
@@ -261,31 +579,116 @@ func (e *Engine) Analyze(ctx context.Context, req *AnalysisRequest) *AnalysisRes
 		Threats:           e.buildThreatDetails(results),
 		Reasons:           reasons,
 		RecommendedAction: GetActionForLevel(level),
-		Sources:           e.buildSourceResults(results),
+		Sources:           sources,
+		Scoring:           scoring,
+		PhoneInfo:         phoneInfo,
 		CacheHit:          false,
 		ResponseTimeMs:    time.Since(startTime).Milliseconds(),
 		CheckedAt:         time.Now().UTC(),
+		EngineInfo:        e.engineInfo(),
+		Partial:           len(timedOut) > 0,
+		TimedOutCheckers:  timedOut,
 	}
 
 	// TODO: 7. Cachear en Redis
 	// e.cache.Set(ctx, indicators.Hash, response, 24*time.Hour)
 
+	span.SetAttributes(
+		attribute.Int("analysis.risk_score", response.RiskScore),
+		attribute.String("analysis.risk_level", response.RiskLevel),
+	)
+
 	log.Info().
 		Str("input", req.Input).
 		Int("risk_score", response.RiskScore).
 		Str("risk_level", response.RiskLevel).
 		Int64("response_ms", response.ResponseTimeMs).
+		Strs("timed_out", timedOut).
 		Msg("[Engine] Analysis completed")
 
+	if e.webhooks != nil {
+		e.webhooks.Notify(threatEvent(response, indicators.InputType))
+	}
+
 	return response
 }
 
-// aggregateAnalysisResults agrega resultados de checkers y heurísticas
-func (e *Engine) aggregateAnalysisResults(results []*checkers.CheckResult, heuristic *correlation.HeuristicResult) (int, RiskLevel, []string) {
+// requestCheckTimeout timeout de checkers pedido en timeout_ms, limitado a
+// [MinAnalysisTimeout, MaxAnalysisTimeout]. 0 si no se pidió (se usa CheckTimeout)
+func (e *Engine) requestCheckTimeout(timeoutMs int) time.Duration {
+	if timeoutMs <= 0 {
+		return 0
+	}
+	timeout := time.Duration(timeoutMs) * time.Millisecond
+	if timeout < e.config.MinAnalysisTimeout {
+		return e.config.MinAnalysisTimeout
+	}
+	if timeout > e.config.MaxAnalysisTimeout {
+		return e.config.MaxAnalysisTimeout
+	}
+	return timeout
+}
+
+// timedOutCheckers checkers que no respondieron antes del timeout del análisis
+func timedOutCheckers(results []*checkers.CheckResult) []string {
+	var names []string
+	for _, result := range results {
+		if errors.Is(result.Error, errCheckerTimeout) {
+			names = append(names, result.Source)
+		}
+	}
+	return names
+}
+
+// threatEvent evento de webhook de un análisis (tipos de amenaza sin repetir)
+func threatEvent(response *AnalysisResponse, inputType checkers.InputType) notifications.ThreatEvent {
+	threatTypes := []string{}
+	seen := make(map[string]bool)
+	for _, t := range response.Threats {
+		if t.Type != "" && !seen[t.Type] {
+			seen[t.Type] = true
+			threatTypes = append(threatTypes, t.Type)
+		}
+	}
+	return notifications.ThreatEvent{
+		Event:       notifications.EventThreatDetected,
+		RiskScore:   response.RiskScore,
+		RiskLevel:   response.RiskLevel,
+		Input:       response.Input,
+		InputType:   string(inputType),
+		ThreatTypes: threatTypes,
+		Timestamp:   response.CheckedAt,
+	}
+}
+
+// analysisSourceWeights pesos por defecto de cada fuente en Analyze (LocalDB tiene mayor peso)
+var analysisSourceWeights = map[string]float64{
+	"localdb":      0.30, // DB local - máxima prioridad
+	"urlhaus":      0.15,
+	"phishtank":    0.15,
+	"webrisk":      0.15,
+	"urlscan":      0.10,
+	"user_reports": 0.10, // Reportes de usuarios - peso bajo (crowdsourced)
+	"visual":       0.15, // Similitud visual con phishing conocido
+	"email_dns":    0.15, // MX/SPF/DMARC del dominio del email
+	"hibp":         0.10, // Email en brechas de datos (Have I Been Pwned)
+	"spamassassin": 0.10, // Score de SpamAssassin (spamd)
+	"malware_hash": 0.30, // Hash de fichero en VirusTotal/MalwareBazaar
+	"heuristics":   0.15,
+}
+
+// analysisSourceWeight peso de una fuente en Analyze (0.1 para fuentes desconocidas)
+func analysisSourceWeight(weights map[string]float64, source string) float64 {
+	if weight, ok := weights[source]; ok {
+		return weight
+	}
+	return 0.1
+}
+
+// aggregateAnalysisResults agrega resultados de checkers y heurísticas. sources son las
+// fuentes de buildSourceResults (mismo orden que results); se completa su contribución al score.
+func (e *Engine) aggregateAnalysisResults(results []*checkers.CheckResult, heuristic *correlation.HeuristicResult, sources []SourceResult) (int, RiskLevel, []string, *ScoreBreakdown) {
 	var reasons []string
-	var totalScore float64
-	var totalWeight float64
-	threatsFound := 0
 
 	// PRIMERO: Verificar si algún checker marcó el dominio como whitelisted (SAFE)
 	for _, result := range results {
@@ -308,39 +711,14 @@ func (e *Engine) aggregateAnalysisResults(results []*checkers.CheckResult, heuri
 					Strs("reasons", safeReasons).
 					Msg("[Engine] Domain is WHITELISTED - returning safe")
 
-				return 0, RiskLevelSafe, safeReasons
+				return 0, RiskLevelSafe, safeReasons, &ScoreBreakdown{BoostFactor: 1.0, Fixed: scoreFixedWhitelisted}
 			}
 		}
 	}
 
-	// Pesos por fuente (LocalDB tiene mayor peso)
-	weights := map[string]float64{
-		"localdb":      0.30, // DB local - máxima prioridad
-		"urlhaus":      0.15,
-		"phishtank":    0.15,
-		"webrisk":      0.15,
-		"urlscan":      0.10,
-		"user_reports": 0.10, // Reportes de usuarios - peso bajo (crowdsourced)
-		"heuristics":   0.15,
-	}
-
+	// Añadir razones de los resultados con amenaza
 	for _, result := range results {
-		if result.Error != nil {
-			continue
-		}
-
-		weight := weights[result.Source]
-		if weight == 0 {
-			weight = 0.1 // Default para fuentes desconocidas
-		}
-		totalWeight += weight
-
-		if result.Found {
-			threatsFound++
-			contribution := weight * result.Confidence * 100
-			totalScore += contribution
-
-			// Añadir razones del resultado
+		if result.Error == nil && result.Found {
 			if rawReasons, ok := result.RawData["reasons"].([]string); ok {
 				reasons = append(reasons, rawReasons...)
 			}
@@ -362,31 +740,62 @@ func (e *Engine) aggregateAnalysisResults(results []*checkers.CheckResult, heuri
 	}
 
 	// Calcular score final
-	var finalScore int
-	if totalWeight > 0 && threatsFound > 0 {
-		finalScore = int(totalScore / totalWeight)
-
-		// Boost por múltiples fuentes
-		if threatsFound >= 2 {
-			boost := 1.0 + (float64(threatsFound-1) * 0.1)
-			finalScore = int(float64(finalScore) * boost)
-		}
-
-		if finalScore > 100 {
-			finalScore = 100
+	finalScore, breakdown := scoreSources(sources, results)
+
+	// Sin consenso (menos fuentes que CONSENSUS_THRESHOLD confirman) no se llega a danger
+	e.mu.RLock()
+	consensus := e.config.ConsensusThreshold
+	e.mu.RUnlock()
+	if confirmed := confirmingSources(results); consensus > 1 && confirmed < consensus && GetRiskLevel(finalScore) == RiskLevelDanger {
+		finalScore = noConsensusRisk
+		breakdown.Fixed = scoreFixedNoConsensus
+		reasons = append(reasons, fmt.Sprintf(ReasonsES["no_consensus"], confirmed, consensus))
+	}
+	if GetRiskLevel(finalScore) == RiskLevelSafe && hasHistoricalThreats(results) {
+		finalScore = historicalThreatRisk
+		breakdown.Fixed = scoreFixedHistorical
+		reasons = append(reasons, ReasonsES["historical_threats"])
+	}
+	// Checkers sin respuesta a tiempo: incertidumbre, no ausencia de amenazas
+	if timedOut := timedOutCheckers(results); len(timedOut) > 0 {
+		if GetRiskLevel(finalScore) == RiskLevelSafe && timedOutUncertain(results, sources) {
+			finalScore = incompleteCheckRisk
+			breakdown.Fixed = scoreFixedIncomplete
 		}
-	} else if totalWeight == 0 {
-		finalScore = 50 // Incertidumbre
-		reasons = append(reasons, ReasonsES["partial_check"])
+		reasons = append(reasons, fmt.Sprintf(ReasonsES["incomplete_check"], strings.Join(timedOut, ", ")))
 	} else {
-		finalScore = 0
-		if len(reasons) == 0 {
+		switch {
+		case breakdown.Fixed == scoreFixedNoSources:
+			reasons = append(reasons, ReasonsES["partial_check"])
+		case finalScore == 0 && len(reasons) == 0:
 			reasons = append(reasons, ReasonsES["no_threats_found"])
 		}
 	}
 
 	level := GetRiskLevel(finalScore)
-	return finalScore, level, reasons
+	return finalScore, level, reasons, breakdown
+}
+
+// confirmingSources fuentes que respondieron sin error y encontraron amenaza
+func confirmingSources(results []*checkers.CheckResult) int {
+	confirmed := 0
+	for _, result := range results {
+		if result.Error == nil && result.Found {
+			confirmed++
+		}
+	}
+	return confirmed
+}
+
+// timedOutUncertain true si no respondió a tiempo alguna fuente con peso >= uncertainTimeoutWeight.
+// sources[i] debe corresponder a results[i]
+func timedOutUncertain(results []*checkers.CheckResult, sources []SourceResult) bool {
+	for i, result := range results {
+		if errors.Is(result.Error, errCheckerTimeout) && sources[i].Weight >= uncertainTimeoutWeight {
+			return true
+		}
+	}
+	return false
 }
 
 // buildThreatDetails construye los detalles de amenazas desde los resultados
@@ -407,30 +816,27 @@ func (e *Engine) buildThreatDetails(results []*checkers.CheckResult) []ThreatDet
 	return threats
 }
 
-// buildSourceResults construye los resultados por fuente
-func (e *Engine) buildSourceResults(results []*checkers.CheckResult) []SourceResult {
-	var sources []SourceResult
+// buildSourceResults construye los resultados por fuente (con debug, incluye RawData)
+func (e *Engine) buildSourceResults(results []*checkers.CheckResult, debug bool) []SourceResult {
+	sources := make([]SourceResult, 0, len(results))
 
-	weights := map[string]float64{
-		"localdb":      0.30,
-		"urlhaus":      0.15,
-		"phishtank":    0.15,
-		"webrisk":      0.15,
-		"urlscan":      0.10,
-		"user_reports": 0.10,
-		"heuristics":   0.15,
-	}
+	e.mu.RLock()
+	weights := e.weights
+	e.mu.RUnlock()
 
 	for _, result := range results {
 		sr := SourceResult{
 			Name:    result.Source,
 			Found:   result.Found,
 			Latency: result.Latency.String(),
-			Weight:  weights[result.Source],
+			Weight:  analysisSourceWeight(weights, result.Source),
 		}
 		if result.Error != nil {
 			sr.Error = result.Error.Error()
 		}
+		if debug {
+			sr.RawData = result.RawData
+		}
 		sources = append(sources, sr)
 	}
 
@@ -452,22 +858,34 @@ func (e *Engine) buildErrorAnalysisResponse(req *AnalysisRequest, errorMsg strin
 		CacheHit:          false,
 		ResponseTimeMs:    time.Since(startTime).Milliseconds(),
 		CheckedAt:         time.Now().UTC(),
+		EngineInfo:        e.engineInfo(),
 	}
 }
 
 // GetStatus retorna el estado del engine
 func (e *Engine) GetStatus() map[string]interface{} {
 	status := map[string]interface{}{
-		"checkers": e.orchestrator.GetCheckerStatus(),
+		"checkers": e.currentOrchestrator().GetCheckerStatus(),
 	}
 
 	if e.dbSyncer != nil {
 		status["databases"] = e.dbSyncer.GetStatus()
 	}
+	status["disposable_domains"] = disposable.Default.Stats()
+	status["tld_risk"] = tldrisk.Default.Stats()
+	status["analysis"] = e.limiter.stats()
 
 	return status
 }
 
+// LocalDBPoolStats retorna el estado del pool de LocalDB (nil si no hay DB local)
+func (e *Engine) LocalDBPoolStats() *checkers.PoolStats {
+	if e.localDB == nil {
+		return nil
+	}
+	return e.localDB.ConnectionPoolStats()
+}
+
 // ForceDBSync fuerza sincronización de DBs
 func (e *Engine) ForceDBSync(ctx context.Context, dbName string) error {
 	if e.dbSyncer != nil {
@@ -476,6 +894,45 @@ func (e *Engine) ForceDBSync(ctx context.Context, dbName string) error {
 	return nil
 }
 
+// adminSyncTimeout límite de una re-descarga lanzada desde /admin/sync
+const adminSyncTimeout = 10 * time.Minute
+
+// ErrDBSyncDisabled el engine arrancó sin sincronizador de DBs (ENABLE_DB_SYNC=false)
+var ErrDBSyncDisabled = errors.New("db sync disabled")
+
+// ErrSyncInProgress ya hay una sincronización en curso para esa DB
+var ErrSyncInProgress = sync.ErrSyncInProgress
+
+// StartDBSync lanza ForceDBSync en background (urlhaus, phishtank o all).
+// Falla sin lanzar nada si la DB no existe o ya se está sincronizando.
+func (e *Engine) StartDBSync(dbName string) error {
+	if e.dbSyncer == nil {
+		return ErrDBSyncDisabled
+	}
+	switch dbName {
+	case "urlhaus", "phishtank", "all":
+	default:
+		return fmt.Errorf("unknown database: %s", dbName)
+	}
+	if e.dbSyncer.SyncInProgress(dbName) {
+		return ErrSyncInProgress
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), adminSyncTimeout)
+		defer cancel()
+
+		log.Info().Str("db", dbName).Msg("[Engine] Manual DB sync started")
+		if err := e.dbSyncer.ForceSync(ctx, dbName); err != nil {
+			log.Error().Err(err).Str("db", dbName).Msg("[Engine] Manual DB sync failed")
+			return
+		}
+		log.Info().Str("db", dbName).Msg("[Engine] Manual DB sync completed")
+	}()
+
+	return nil
+}
+
 // ReportURLRequest estructura para reportar una URL
 type ReportURLRequest struct {
 	URL           string `json:"url"`
@@ -602,6 +1059,14 @@ func (e *Engine) ReportURL(ctx context.Context, req *ReportURLRequest) *ReportUR
 	}
 }
 
+// GetThreatMetrics estadísticas precalculadas de la DB local (vista threat_metrics)
+func (e *Engine) GetThreatMetrics(ctx context.Context) (*checkers.ThreatMetrics, error) {
+	if e.localDB == nil || !e.localDB.IsEnabled() {
+		return nil, fmt.Errorf("local DB not enabled")
+	}
+	return e.localDB.GetThreatMetrics(ctx)
+}
+
 // GetUserReportsStats retorna estadísticas del sistema de reportes
 func (e *Engine) GetUserReportsStats(ctx context.Context) (map[string]interface{}, error) {
 	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
@@ -610,9 +1075,63 @@ func (e *Engine) GetUserReportsStats(ctx context.Context) (map[string]interface{
 	return e.userReportsChecker.GetStats(ctx)
 }
 
+// GetUserNotifications notificaciones in-app de un usuario (reportes confirmados)
+func (e *Engine) GetUserNotifications(ctx context.Context, userID string, limit int) ([]checkers.UserNotification, error) {
+	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
+		return nil, fmt.Errorf("user reports checker not enabled")
+	}
+	return e.userReportsChecker.GetUserNotifications(ctx, userID, limit)
+}
+
+// SubmitWhitelistRequest registra la propuesta de un usuario de añadir un dominio a la whitelist
+func (e *Engine) SubmitWhitelistRequest(ctx context.Context, req *checkers.WhitelistRequest) (*checkers.WhitelistRequestResult, error) {
+	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
+		return nil, fmt.Errorf("user reports checker not enabled")
+	}
+	return e.userReportsChecker.SubmitWhitelistRequest(ctx, req)
+}
+
+// MarkUserNotificationsRead marca como leídas las notificaciones de un usuario
+func (e *Engine) MarkUserNotificationsRead(ctx context.Context, userID string) (int64, error) {
+	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
+		return 0, fmt.Errorf("user reports checker not enabled")
+	}
+	return e.userReportsChecker.MarkNotificationsRead(ctx, userID)
+}
+
+// GetUserReportsSummary retorna los reportes de un usuario en [from, to)
+func (e *Engine) GetUserReportsSummary(ctx context.Context, userID string, from, to time.Time) (*checkers.UserReportsSummary, error) {
+	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
+		return nil, fmt.Errorf("user reports checker not enabled")
+	}
+	return e.userReportsChecker.GetUserReportsSummary(ctx, userID, from, to)
+}
+
+// AnonymizeUserReports desvincula los reportes de un usuario que ha borrado su cuenta
+func (e *Engine) AnonymizeUserReports(ctx context.Context, userID string) (int64, error) {
+	if e.userReportsChecker == nil || !e.userReportsChecker.IsEnabled() {
+		return 0, fmt.Errorf("user reports checker not enabled")
+	}
+	return e.userReportsChecker.AnonymizeUser(ctx, userID)
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
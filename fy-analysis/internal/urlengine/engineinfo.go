@@ -0,0 +1,60 @@
+package urlengine
+
+import (
+	"time"
+
+	"github.com/trackfy/fy-analysis/internal/correlation"
+)
+
+// Version build del servicio. Se inyecta al compilar:
+//
+//	go build -ldflags "-X github.com/trackfy/fy-analysis/internal/urlengine.Version=$(git describe --tags --always)"
+var Version = "dev"
+
+// EngineInfo qué produjo un veredicto: build, revisión de las heurísticas, checkers
+// habilitados con la fecha de sus DBs y pesos de Analyze. Permite reproducir un análisis
+// cuando se revisa una queja ("el martes dijisteis que era segura").
+type EngineInfo struct {
+	Version            string             `json:"version"`
+	HeuristicsRevision string             `json:"heuristics_revision"`
+	Checkers           []CheckerInfo      `json:"checkers"`
+	Weights            map[string]float64 `json:"weights"`
+}
+
+// CheckerInfo checker habilitado; LastUpdate solo en los que cargan una DB en memoria
+type CheckerInfo struct {
+	Name       string     `json:"name"`
+	LastUpdate *time.Time `json:"last_update,omitempty"`
+}
+
+// statsProvider checkers con DB en memoria (URLhaus, PhishTank); GetStats incluye last_update
+type statsProvider interface {
+	GetStats() map[string]interface{}
+}
+
+// engineInfo metadatos del engine en el momento del análisis. Los pesos no se copian:
+// ReloadConfig reemplaza el mapa en lugar de modificarlo.
+func (e *Engine) engineInfo() *EngineInfo {
+	e.mu.RLock()
+	weights := e.weights
+	e.mu.RUnlock()
+
+	enabled := e.currentOrchestrator().getEnabledCheckers()
+	infos := make([]CheckerInfo, 0, len(enabled))
+	for _, c := range enabled {
+		info := CheckerInfo{Name: c.Name()}
+		if sp, ok := c.(statsProvider); ok {
+			if lastUpdate, ok := sp.GetStats()["last_update"].(time.Time); ok && !lastUpdate.IsZero() {
+				info.LastUpdate = &lastUpdate
+			}
+		}
+		infos = append(infos, info)
+	}
+
+	return &EngineInfo{
+		Version:            Version,
+		HeuristicsRevision: correlation.RulesetRevision,
+		Checkers:           infos,
+		Weights:            weights,
+	}
+}
@@ -0,0 +1,61 @@
+package urlengine
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrExpandInvalidURL el input no es una URL con dominio o IP
+var ErrExpandInvalidURL = errors.New("invalid URL")
+
+// ExpandResult resultado de POST /api/v1/expand: adónde lleva una URL (acortada o no)
+type ExpandResult struct {
+	Original        string   `json:"original"`
+	Expanded        *string  `json:"expanded"`       // URL final; null si la expansión falló
+	RedirectChain   []string `json:"redirect_chain"` // URL inicial incluida; vacía si no redirige
+	IsShortener     bool     `json:"is_shortener"`   // El dominio original es un acortador conocido
+	Domain          string   `json:"domain"`         // Dominio de destino (el original si falló)
+	ExpansionTimeMs int64    `json:"expansion_time_ms"`
+	Cached          bool     `json:"cached"`
+	Error           string   `json:"error,omitempty"` // expansion_failed: 4xx/5xx o error de red
+}
+
+// Expand sigue los redirects de rawURL sin ejecutar checkers ni consultar bases de datos.
+// Un 4xx/5xx o un error de red en la cadena dejan Expanded a nil con Error = expansion_failed;
+// un 405/501 solo indica que el servidor no acepta HEAD y no cuenta como fallo.
+func (e *Engine) Expand(ctx context.Context, rawURL string) (*ExpandResult, error) {
+	start := time.Now()
+
+	normalized := e.normalizer.Normalize(ctx, rawURL)
+	if normalized.Error != nil || (!strings.Contains(normalized.Domain, ".") && !strings.Contains(normalized.Domain, ":")) {
+		return nil, ErrExpandInvalidURL
+	}
+
+	result := &ExpandResult{
+		Original:      rawURL,
+		RedirectChain: normalized.ExpandChain,
+		IsShortener:   normalized.IsShortener,
+		Domain:        normalized.Domain,
+	}
+
+	status := normalized.ExpandStatus
+	if normalized.ExpandError != nil || (status >= 400 && status != http.StatusMethodNotAllowed && status != http.StatusNotImplemented) {
+		result.Error = "expansion_failed"
+	} else {
+		final := normalized.NormalizedURL
+		if len(normalized.ExpandChain) > 1 {
+			final = normalized.ExpandChain[len(normalized.ExpandChain)-1]
+		}
+		result.Expanded = &final
+		if parsed, err := url.Parse(final); err == nil && parsed.Hostname() != "" {
+			result.Domain = strings.ToLower(parsed.Hostname())
+		}
+	}
+
+	result.ExpansionTimeMs = time.Since(start).Milliseconds()
+	return result, nil
+}
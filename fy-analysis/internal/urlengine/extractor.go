@@ -47,6 +47,9 @@ func (e *Extractor) Extract(normalized *NormalizeResult) *checkers.Indicators {
 		}
 	}
 	indicators.IP = normalized.IP
+	if len(normalized.ExpandChain) > 1 {
+		indicators.RedirectChain = normalized.ExpandChain
+	}
 
 	// Generar hashes
 	indicators.URLHash = hashSHA256(indicators.FullURL)
@@ -0,0 +1,174 @@
+package urlengine
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+// maxMessageIndicators límite de indicadores analizados por mensaje (acota el fan-out de checkers)
+const maxMessageIndicators = 10
+
+// emailInTextRegex emails dentro de un texto libre
+var emailInTextRegex = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}[^\s<>"']*`)
+
+// MessageAnalysisRequest solicitud de análisis de un mensaje completo (SMS, WhatsApp, email)
+type MessageAnalysisRequest struct {
+	Text           string                    `json:"text"`
+	Context        *checkers.AnalysisContext `json:"context,omitempty"`
+	AllowlistItems []string                  `json:"allowlist_items,omitempty"`
+}
+
+// ExtractedIndicator indicador encontrado en el texto de un mensaje
+type ExtractedIndicator struct {
+	Type  InputType `json:"type"`
+	Value string    `json:"value"`
+}
+
+// MessageAnalysisResponse veredicto combinado del mensaje y resultado de cada indicador
+type MessageAnalysisResponse struct {
+	RiskScore         int                 `json:"risk_score"`
+	RiskLevel         string              `json:"risk_level"`
+	Reasons           []string            `json:"reasons"`
+	RecommendedAction string              `json:"recommended_action"`
+	Indicators        []*AnalysisResponse `json:"indicators"`
+	Keywords          []string            `json:"keywords"`
+	ClaimedSender     string              `json:"claimed_sender,omitempty"`
+	ResponseTimeMs    int64               `json:"response_time_ms"`
+	CheckedAt         time.Time           `json:"checked_at"`
+	// EngineInfo una sola vez para todo el mensaje: los indicadores no lo repiten
+	EngineInfo *EngineInfo `json:"engine_info,omitempty"`
+}
+
+// ExtractIndicators extrae URLs, emails, hashes de ficheros y teléfonos de un texto libre, sin duplicados
+// y en orden de aparición (máximo maxMessageIndicators)
+func (n *Normalizer) ExtractIndicators(text string) []ExtractedIndicator {
+	var extracted []ExtractedIndicator
+	seen := make(map[string]bool)
+
+	add := func(detection TypeDetection) {
+		key := string(detection.Type) + "|" + strings.ToLower(detection.Value)
+		if seen[key] || len(extracted) >= maxMessageIndicators {
+			return
+		}
+		seen[key] = true
+		extracted = append(extracted, ExtractedIndicator{Type: detection.Type, Value: detection.Value})
+	}
+
+	// 1. Emails (o URLs con userinfo "paypal.com@evil.com"): se retiran del texto para no
+	// volver a extraer su dominio como URL
+	remaining := emailInTextRegex.ReplaceAllStringFunc(text, func(match string) string {
+		add(n.DetectType(strings.TrimRight(match, ".,;:!?)")))
+		return " "
+	})
+
+	// 2. URLs (tokens con esquema, www., IP o dominio) y hashes de adjuntos; los hashes se
+	// retiran para no leer sus rachas de dígitos como teléfonos
+	var rest []string
+	for _, raw := range strings.Fields(remaining) {
+		token := strings.TrimRight(strings.Trim(raw, tokenTrim), ".")
+		detection := n.DetectType(token)
+		if (detection.Type == InputTypeURL && detection.Confidence >= 0.75) || detection.Type == InputTypeHash {
+			add(detection)
+			continue
+		}
+		rest = append(rest, raw)
+	}
+
+	// 3. Teléfonos en lo que queda (los dígitos dentro de URLs ya no están)
+	for _, candidate := range phoneCandidateRegex.FindAllString(strings.Join(rest, " "), -1) {
+		if digits := countDigits(candidate); digits >= 9 && digits <= 15 {
+			add(TypeDetection{Type: InputTypePhone, Value: strings.TrimSpace(candidate)})
+		}
+	}
+
+	return extracted
+}
+
+// AnalyzeMessage extrae los indicadores de un mensaje, los analiza en paralelo y combina
+// el riesgo: el máximo de los indicadores más las heurísticas del propio texto
+// (urgencia, petición de pago, suplantación de marca)
+func (e *Engine) AnalyzeMessage(ctx context.Context, req *MessageAnalysisRequest) *MessageAnalysisResponse {
+	startTime := time.Now()
+
+	// 1. Heurísticas del texto; alimentan el contexto de los indicadores
+	message := e.heuristics.AnalyzeMessage(req.Text)
+
+	analysisCtx := &checkers.AnalysisContext{}
+	if req.Context != nil {
+		*analysisCtx = *req.Context
+	}
+	analysisCtx.OriginalText = req.Text
+	if analysisCtx.ClaimedSender == "" {
+		analysisCtx.ClaimedSender = message.ClaimedBrand
+	}
+
+	// 2. Extraer y analizar cada indicador en paralelo
+	extracted := e.normalizer.ExtractIndicators(req.Text)
+
+	log.Info().
+		Int("indicators", len(extracted)).
+		Int("message_score", message.Score).
+		Str("claimed_sender", analysisCtx.ClaimedSender).
+		Msg("[Engine] Message analysis request received")
+
+	indicators := make([]*AnalysisResponse, len(extracted))
+	var wg sync.WaitGroup
+	for i, indicator := range extracted {
+		wg.Add(1)
+		go func(i int, indicator ExtractedIndicator) {
+			defer wg.Done()
+			indicators[i] = e.Analyze(ctx, &AnalysisRequest{
+				Input:          indicator.Value,
+				Type:           indicator.Type,
+				Context:        analysisCtx,
+				AllowlistItems: req.AllowlistItems,
+			})
+		}(i, indicator)
+	}
+	wg.Wait()
+
+	// 3. Veredicto combinado
+	var riskiest *AnalysisResponse
+	for _, indicator := range indicators {
+		indicator.EngineInfo = nil
+		if riskiest == nil || indicator.RiskScore > riskiest.RiskScore {
+			riskiest = indicator
+		}
+	}
+
+	score := message.Score
+	reasons := append([]string{}, message.Reasons...)
+	if riskiest != nil {
+		score += riskiest.RiskScore
+		if riskiest.RiskScore > 20 {
+			reasons = append(reasons, riskiest.Reasons...)
+		}
+	}
+	if score > 100 {
+		score = 100
+	}
+	if len(reasons) == 0 {
+		reasons = append(reasons, ReasonsES["no_threats_found"])
+	}
+
+	level := GetRiskLevel(score)
+
+	return &MessageAnalysisResponse{
+		RiskScore:         score,
+		RiskLevel:         string(level),
+		Reasons:           reasons,
+		RecommendedAction: GetActionForLevel(level),
+		Indicators:        indicators,
+		Keywords:          message.Keywords,
+		ClaimedSender:     analysisCtx.ClaimedSender,
+		ResponseTimeMs:    time.Since(startTime).Milliseconds(),
+		CheckedAt:         time.Now().UTC(),
+		EngineInfo:        e.engineInfo(),
+	}
+}
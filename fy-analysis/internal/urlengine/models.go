@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/models"
 )
 
 // Re-exportar tipos de checkers para conveniencia
@@ -13,33 +14,43 @@ const (
 	InputTypeURL   = checkers.InputTypeURL
 	InputTypeEmail = checkers.InputTypeEmail
 	InputTypePhone = checkers.InputTypePhone
+	InputTypeHash  = checkers.InputTypeHash
 )
 
 // AnalysisRequest representa la solicitud unificada de análisis
 type AnalysisRequest struct {
 	Input   string                   `json:"input" validate:"required"`
-	Type    InputType                `json:"type" validate:"required,oneof=url email phone"`
+	Type    InputType                `json:"type" validate:"omitempty,oneof=url email phone hash"` // Vacío: se detecta automáticamente
 	Context *checkers.AnalysisContext `json:"context,omitempty"`
+	// Lista de confianza personal del usuario (dominios, emails, teléfonos)
+	AllowlistItems []string `json:"allowlist_items,omitempty"`
+	// Debug incluye en cada fuente los datos crudos del checker (RawData)
+	Debug bool `json:"debug,omitempty"`
+	// TimeoutMs timeout de los checkers en ms, limitado a [MinAnalysisTimeout, MaxAnalysisTimeout].
+	// 0: CheckTimeout del engine
+	TimeoutMs int `json:"timeout_ms,omitempty"`
 }
 
 // URLCheckRequest representa la solicitud de verificación (legacy, para compatibilidad)
 type URLCheckRequest struct {
-	URL string `json:"url" validate:"required"`
+	URL   string `json:"url" validate:"required"`
+	Debug bool   `json:"debug,omitempty"` // Incluir RawData de cada fuente
 }
 
 // URLCheckResponse representa la respuesta completa de verificación
 type URLCheckResponse struct {
-	URL           string         `json:"url"`
-	NormalizedURL string         `json:"normalized_url"`
-	RiskScore     int            `json:"risk_score"`      // 0-100
-	RiskLevel     RiskLevel      `json:"risk_level"`      // safe/warning/danger
-	Threats       []ThreatDetail `json:"threats"`
-	Explanation   string         `json:"explanation"`     // Explicación para el usuario/Fy
-	Action        string         `json:"recommended_action"`
-	Sources       []SourceResult `json:"sources"`         // Resultados por fuente
-	CheckedAt     time.Time      `json:"checked_at"`
-	Cached        bool           `json:"cached"`          // Si vino de cache
-	Latency       string         `json:"latency"`         // Tiempo total de verificación
+	URL           string          `json:"url"`
+	NormalizedURL string          `json:"normalized_url"`
+	RiskScore     int             `json:"risk_score"`      // 0-100
+	RiskLevel     RiskLevel       `json:"risk_level"`      // safe/warning/danger
+	Threats       []ThreatDetail  `json:"threats"`
+	Explanation   string          `json:"explanation"`     // Explicación para el usuario/Fy
+	Action        string          `json:"recommended_action"`
+	Sources       []SourceResult  `json:"sources"`         // Resultados por fuente
+	Scoring       *ScoreBreakdown `json:"scoring,omitempty"` // Desglose del cálculo del score
+	CheckedAt     time.Time       `json:"checked_at"`
+	Cached        bool            `json:"cached"`          // Si vino de cache
+	Latency       string          `json:"latency"`         // Tiempo total de verificación
 }
 
 // ThreatDetail detalle de una amenaza detectada
@@ -52,11 +63,121 @@ type ThreatDetail struct {
 
 // SourceResult resultado individual de cada fuente
 type SourceResult struct {
-	Name    string  `json:"name"`
-	Found   bool    `json:"found"`
-	Latency string  `json:"latency"`
-	Error   string  `json:"error,omitempty"`
-	Weight  float64 `json:"weight"`
+	Name       string  `json:"name"`
+	Found      bool    `json:"found"`
+	Latency    string  `json:"latency"`
+	Error      string  `json:"error,omitempty"`
+	Weight     float64 `json:"weight"`
+	Confidence float64 `json:"confidence"`
+	Points     float64 `json:"points"` // Puntos aportados al score: peso * confianza * 100 / peso total
+	// RawData datos crudos del checker (solo con debug=true)
+	RawData map[string]interface{} `json:"raw_data,omitempty"`
+}
+
+// ScoreBreakdown desglose del cálculo del risk score, para poder explicar un veredicto.
+// RiskScore = min(100, int(int(PointsTotal) * BoostFactor)), salvo si Fixed no está vacío.
+type ScoreBreakdown struct {
+	PointsTotal  float64 `json:"points_total"`    // Suma de los Points de las fuentes
+	TotalWeight  float64 `json:"total_weight"`    // Peso de las fuentes que respondieron sin error
+	BoostApplied bool    `json:"boost_applied"`   // Dos o más fuentes confirmaron la amenaza
+	BoostFactor  float64 `json:"boost_factor"`    // 1.0 sin boost, +0.1 por cada fuente adicional
+	BoostPoints  int     `json:"boost_points"`    // Puntos añadidos por el boost
+	RawScore     int     `json:"raw_score"`       // Score antes de limitar a 100
+	Fixed        string  `json:"fixed,omitempty"` // whitelisted / no_sources / historical_reputation / incomplete / no_consensus: score fijo
+}
+
+const (
+	scoreFixedWhitelisted = "whitelisted"
+	scoreFixedNoSources   = "no_sources"
+	scoreFixedHistorical  = "historical_reputation"
+	scoreFixedIncomplete  = "incomplete"
+	scoreFixedNoConsensus = "no_consensus"
+)
+
+// Un dominio sin amenazas actuales pero con historical_reputation > historicalThreatScore
+// (LocalDB, domain_reputation_scores) no se da por seguro: pasa a warning con historicalThreatRisk
+const (
+	historicalThreatScore = 50
+	historicalThreatRisk  = 21
+)
+
+// Un análisis sin amenazas en el que no respondió a tiempo una fuente con peso >= uncertainTimeoutWeight
+// (localdb) no se da por seguro: pasa a warning con incompleteCheckRisk
+const (
+	uncertainTimeoutWeight = 0.30
+	incompleteCheckRisk    = 21
+)
+
+// Un análisis que llega a danger con menos fuentes confirmando que CONSENSUS_THRESHOLD se queda
+// en el techo de warning (noConsensusRisk)
+const noConsensusRisk = 60
+
+// hasHistoricalThreats true si ninguna fuente encontró amenaza y alguna reporta un historial
+// de reputación por encima de historicalThreatScore
+func hasHistoricalThreats(results []*checkers.CheckResult) bool {
+	historical := false
+	for _, result := range results {
+		if result.Found {
+			return false
+		}
+		if score, ok := result.RawData["historical_reputation"].(int); ok && score > historicalThreatScore {
+			historical = true
+		}
+	}
+	return historical
+}
+
+// scoreSources calcula el score de los resultados. sources[i] debe corresponder a results[i]
+// con el Weight ya relleno; se completan Confidence y Points de cada fuente.
+func scoreSources(sources []SourceResult, results []*checkers.CheckResult) (int, *ScoreBreakdown) {
+	var totalWeight, weightedScore float64
+	threatsFound := 0
+
+	for i, result := range results {
+		if result.Error != nil {
+			continue
+		}
+		totalWeight += sources[i].Weight
+		sources[i].Confidence = result.Confidence
+		if result.Found {
+			threatsFound++
+			weightedScore += sources[i].Weight * result.Confidence * 100
+		}
+	}
+
+	breakdown := &ScoreBreakdown{TotalWeight: totalWeight, BoostFactor: 1.0}
+
+	if totalWeight == 0 {
+		// Ninguna fuente respondió: incertidumbre
+		breakdown.Fixed = scoreFixedNoSources
+		breakdown.RawScore = 50
+		return 50, breakdown
+	}
+	if threatsFound == 0 {
+		return 0, breakdown
+	}
+
+	for i, result := range results {
+		if result.Error == nil && result.Found {
+			sources[i].Points = sources[i].Weight * result.Confidence * 100 / totalWeight
+		}
+	}
+	breakdown.PointsTotal = weightedScore / totalWeight
+
+	// Normalizado por peso total, con boost del 10% por cada fuente adicional que confirma
+	baseScore := int(breakdown.PointsTotal)
+	breakdown.RawScore = baseScore
+	if threatsFound >= 2 {
+		breakdown.BoostApplied = true
+		breakdown.BoostFactor = 1.0 + (float64(threatsFound-1) * 0.1)
+		breakdown.RawScore = int(float64(baseScore) * breakdown.BoostFactor)
+		breakdown.BoostPoints = breakdown.RawScore - baseScore
+	}
+
+	if breakdown.RawScore > 100 {
+		return 100, breakdown
+	}
+	return breakdown.RawScore, breakdown
 }
 
 // RiskLevel niveles de riesgo
@@ -123,18 +244,53 @@ type AggregatedResult struct {
 
 // AnalysisResponse es la respuesta unificada de análisis
 type AnalysisResponse struct {
-	Input             string         `json:"input"`
-	Type              InputType      `json:"type"`
-	NormalizedInput   string         `json:"normalized_input"`
-	RiskScore         int            `json:"risk_score"`
-	RiskLevel         string         `json:"risk_level"`
-	Threats           []ThreatDetail `json:"threats"`
-	Reasons           []string       `json:"reasons"`
-	RecommendedAction string         `json:"recommended_action"`
-	Sources           []SourceResult `json:"sources"`
-	CacheHit          bool           `json:"cache_hit"`
-	ResponseTimeMs    int64          `json:"response_time_ms"`
-	CheckedAt         time.Time      `json:"checked_at"`
+	Input             string            `json:"input"`
+	Type              InputType         `json:"type"`
+	NormalizedInput   string            `json:"normalized_input"`
+	RiskScore         int               `json:"risk_score"`
+	RiskLevel         string            `json:"risk_level"`
+	Threats           []ThreatDetail    `json:"threats"`
+	Reasons           []string          `json:"reasons"`
+	RecommendedAction string            `json:"recommended_action"`
+	Sources           []SourceResult    `json:"sources"`
+	Scoring           *ScoreBreakdown   `json:"scoring,omitempty"`
+	PhoneInfo         *models.PhoneInfo `json:"phone_info,omitempty"` // Solo para teléfonos
+	CacheHit          bool              `json:"cache_hit"`
+	ResponseTimeMs    int64             `json:"response_time_ms"`
+	CheckedAt         time.Time         `json:"checked_at"`
+	// TypeDetection solo si el tipo no venía en la petición y se detectó automáticamente
+	TypeDetection *TypeDetection `json:"type_detection,omitempty"`
+	// EngineInfo build, reglas y pesos que produjeron el veredicto (se omite con ?engine_info=false)
+	EngineInfo *EngineInfo `json:"engine_info,omitempty"`
+	// Partial true si algún checker no respondió antes del timeout (TimedOutCheckers)
+	Partial          bool     `json:"partial"`
+	TimedOutCheckers []string `json:"timed_out_checkers,omitempty"`
+}
+
+const (
+	// checkerHealthTimeout presupuesto de los Health de todos los checkers
+	checkerHealthTimeout = 2 * time.Second
+	// maxConsecutiveFailures fallos seguidos a partir de los que un checker se da por roto
+	maxConsecutiveFailures = 5
+	// statsRefreshInterval cada cuánto se refresca la vista materializada threat_metrics
+	statsRefreshInterval = 5 * time.Minute
+	// emailRulesSyncTimeout incluye recalcular email_normalized de los dominios cuyas reglas cambian
+	emailRulesSyncTimeout = 2 * time.Minute
+	// officialDomainsLoadTimeout carga de whitelist_domains para las heurísticas de email
+	officialDomainsLoadTimeout = 10 * time.Second
+)
+
+// CheckerHealth estado de un checker en /health
+type CheckerHealth struct {
+	Healthy             bool   `json:"healthy"`
+	Error               string `json:"error,omitempty"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+}
+
+// EngineHealth estado agregado de los checkers: healthy o degraded
+type EngineHealth struct {
+	Status   string                   `json:"status"`
+	Checkers map[string]CheckerHealth `json:"checkers"`
 }
 
 // RecommendedAction constantes para acciones recomendadas
@@ -178,4 +334,7 @@ var ReasonsES = map[string]string{
 	"disposable_email":    "Esta dirección de email parece ser temporal/desechable",
 	"no_threats_found":    "No se encontraron amenazas en las fuentes consultadas",
 	"partial_check":       "Algunas fuentes no respondieron. Proceda con precaución",
+	"incomplete_check":    "Verificación incompleta: %s no respondieron a tiempo",
+	"historical_threats":  "Dominio con historial de amenazas previas",
+	"no_consensus":        "Solo %d de %d fuentes necesarias confirman la amenaza",
 }
\ No newline at end of file
@@ -2,6 +2,7 @@ package urlengine
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
@@ -9,81 +10,73 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/urlcanon"
 )
 
 // Normalizer maneja la normalización y expansión de URLs, emails y teléfonos
 type Normalizer struct {
-	httpClient       *http.Client
-	shortenerDomains map[string]bool
-	phoneRegex       *regexp.Regexp
-	premiumPrefixes  []string // Prefijos premium españoles
+	httpClient      *http.Client
+	phoneRegex      *regexp.Regexp
+	emailNormalizer *EmailNormalizer
+	canonicalizer   *urlcanon.Canonicalizer
+
+	// redirectTimeout límite para seguir la cadena de redirects (0 = solo el del ctx)
+	redirectTimeout atomic.Int64
+	// offline no resuelve DNS ni sigue redirects (resultados deterministas, ver SetOffline)
+	offline atomic.Bool
 }
 
+// maxRedirectHops saltos máximos que se siguen en una cadena de redirects
+const maxRedirectHops = 10
+
 // NewNormalizer crea un nuevo normalizador de URLs
 func NewNormalizer() *Normalizer {
 	return &Normalizer{
 		httpClient: &http.Client{
 			Timeout: 5 * time.Second,
+			// Las direcciones privadas se rechazan en el dial, con la IP ya resuelta (SSRF, DNS rebinding)
+			Transport: checkers.NewPublicOnlyTransport(),
 			CheckRedirect: func(req *http.Request, via []*http.Request) error {
 				// No seguir redirects automáticamente, queremos capturarlos
 				return http.ErrUseLastResponse
 			},
 		},
-		shortenerDomains: map[string]bool{
-			"bit.ly":       true,
-			"tinyurl.com":  true,
-			"t.co":         true,
-			"goo.gl":       true,
-			"ow.ly":        true,
-			"is.gd":        true,
-			"buff.ly":      true,
-			"adf.ly":       true,
-			"bl.ink":       true,
-			"lnkd.in":      true,
-			"rebrand.ly":   true,
-			"short.io":     true,
-			"cutt.ly":      true,
-			"shorturl.at":  true,
-			"rb.gy":        true,
-			"v.gd":         true,
-			"clck.ru":      true,
-			"shorte.st":    true,
-			"bc.vc":        true,
-			"j.mp":         true,
-		},
 		// Regex para limpiar teléfonos: solo dígitos y +
-		phoneRegex: regexp.MustCompile(`[^\d+]`),
-		// Prefijos premium españoles (tarificación adicional)
-		premiumPrefixes: []string{"803", "806", "807", "905", "907"},
+		phoneRegex:      regexp.MustCompile(`[^\d+]`),
+		emailNormalizer: NewEmailNormalizer(nil),
+		canonicalizer:   urlcanon.New(nil),
 	}
 }
 
-// DetectInputType detecta automáticamente el tipo de entrada (URL, email, teléfono)
-func (n *Normalizer) DetectInputType(input string) checkers.InputType {
-	input = strings.TrimSpace(input)
-
-	// Detectar teléfono: empieza con + o es todo dígitos (con posibles espacios/guiones)
-	cleaned := n.phoneRegex.ReplaceAllString(input, "")
-	if len(cleaned) >= 9 && len(cleaned) <= 15 {
-		// Verificar si parece un número de teléfono
-		if strings.HasPrefix(input, "+") || regexp.MustCompile(`^\d[\d\s\-()\.]*$`).MatchString(input) {
-			return checkers.InputTypePhone
-		}
-	}
+// SetRedirectTimeout limita el tiempo total dedicado a seguir redirects (el CheckTimeout del engine)
+func (n *Normalizer) SetRedirectTimeout(timeout time.Duration) {
+	n.redirectTimeout.Store(int64(timeout))
+}
 
-	// Detectar email: contiene @ y parece válido
-	if strings.Contains(input, "@") {
-		if _, err := mail.ParseAddress(input); err == nil {
-			return checkers.InputTypeEmail
-		}
-	}
+// SetOffline desactiva la resolución DNS y el seguimiento de redirects: los indicadores
+// dependen solo del input (corpus de regresión de heurísticas)
+func (n *Normalizer) SetOffline(offline bool) {
+	n.offline.Store(offline)
+}
+
+// SetTrackingParams parámetros de query que la URL canónica descarta (vacío = urlcanon.DefaultTrackingParams)
+func (n *Normalizer) SetTrackingParams(params []string) {
+	n.canonicalizer = urlcanon.New(params)
+}
 
-	// Por defecto, asumir URL
-	return checkers.InputTypeURL
+// SetEmailRules reglas por dominio para la forma canónica de los emails
+func (n *Normalizer) SetEmailRules(rules *EmailDomainRules) {
+	n.emailNormalizer = NewEmailNormalizer(rules)
+}
+
+// DetectInputType detecta automáticamente el tipo de entrada (URL, email, teléfono)
+func (n *Normalizer) DetectInputType(input string) checkers.InputType {
+	return n.DetectType(input).Type
 }
 
 // NormalizeInput es el punto de entrada unificado para normalizar cualquier tipo de input
@@ -95,12 +88,34 @@ func (n *Normalizer) NormalizeInput(ctx context.Context, input string, inputType
 		return n.NormalizeEmail(ctx, input)
 	case checkers.InputTypePhone:
 		return n.NormalizePhone(ctx, input)
+	case checkers.InputTypeHash:
+		return n.NormalizeHash(ctx, input)
 	default:
 		return nil, fmt.Errorf("unsupported input type: %s", inputType)
 	}
 }
 
-// NormalizeURLToIndicators normaliza una URL y retorna Indicators
+// NormalizeHash valida el hash de un fichero (MD5, SHA1 o SHA256 en hexadecimal) y lo
+// normaliza a minúsculas
+func (n *Normalizer) NormalizeHash(ctx context.Context, rawHash string) (*checkers.Indicators, error) {
+	hash := strings.ToLower(strings.TrimSpace(rawHash))
+	hashType := fileHashType(hash)
+	if hashType == "" {
+		return nil, fmt.Errorf("invalid file hash: expected MD5, SHA1 or SHA256 in hex")
+	}
+
+	return &checkers.Indicators{
+		Original:     rawHash,
+		Normalized:   hash,
+		Hash:         hashSHA256(hash),
+		InputType:    checkers.InputTypeHash,
+		FileHashType: hashType,
+	}, nil
+}
+
+// NormalizeURLToIndicators normaliza una URL y retorna Indicators. Normalized/FullURL son la URL
+// normalizada (la que se muestra y se consulta en las fuentes externas); Hash y CanonicalPath
+// salen de su forma canónica (urlcanon).
 func (n *Normalizer) NormalizeURLToIndicators(ctx context.Context, rawURL string) (*checkers.Indicators, error) {
 	result := n.Normalize(ctx, rawURL)
 	if result.Error != nil {
@@ -116,32 +131,39 @@ func (n *Normalizer) NormalizeURLToIndicators(ctx context.Context, rawURL string
 	// Extraer TLD
 	tld := extractTLD(result.Domain)
 
-	// Parsear para obtener path
+	// Parsear para obtener path y forma canónica
 	parsed, _ := url.Parse(finalURL)
 	path := ""
+	canonical := urlcanon.Canonical{URL: finalURL}
 	if parsed != nil {
 		path = parsed.Path
+		canonical = n.canonicalizer.Canonicalize(parsed)
 	}
 
 	indicators := &checkers.Indicators{
 		Original:   rawURL,
 		Normalized: finalURL,
-		Hash:       hashSHA256(finalURL),
+		Hash:       hashSHA256(canonical.URL),
 		InputType:  checkers.InputTypeURL,
 
-		FullURL:    finalURL,
-		Domain:     result.Domain,
-		DomainHash: hashSHA256(result.Domain),
-		IP:         result.IP,
-		TLD:        tld,
-		Scheme:     result.Scheme,
-		Path:       path,
-		URLHash:    hashSHA256(finalURL),
+		FullURL:       finalURL,
+		Domain:        result.Domain,
+		DomainHash:    hashSHA256(result.Domain),
+		IP:            result.IP,
+		TLD:           tld,
+		Scheme:        result.Scheme,
+		Path:          path,
+		CanonicalPath: canonical.Path,
+		URLHash:       hashSHA256(canonical.URL),
+	}
+	if len(result.ExpandChain) > 1 {
+		indicators.RedirectChain = result.ExpandChain
 	}
 
 	log.Debug().
 		Str("original", rawURL).
 		Str("normalized", finalURL).
+		Str("canonical", canonical.URL).
 		Str("domain", result.Domain).
 		Str("tld", tld).
 		Msg("[Normalizer] URL indicators extracted")
@@ -187,11 +209,14 @@ func (n *Normalizer) NormalizeEmail(ctx context.Context, rawEmail string) (*chec
 		TLD:         extractTLD(domain),
 		EmailUser:   user,
 		EmailDomain: domain,
+
+		EmailNormalized: n.emailNormalizer.NormalizeForLookup(email, nil),
 	}
 
 	log.Debug().
 		Str("email", email).
 		Str("domain", domain).
+		Str("normalized", indicators.EmailNormalized).
 		Msg("[Normalizer] Email indicators extracted")
 
 	return indicators, nil
@@ -225,33 +250,19 @@ func (n *Normalizer) NormalizePhone(ctx context.Context, rawPhone string) (*chec
 	nationalNum := cleaned
 
 	if strings.HasPrefix(cleaned, "+") {
-		// Códigos de país comunes (simplificado)
-		if strings.HasPrefix(cleaned, "+34") {
-			countryCode = "+34"
-			nationalNum = cleaned[3:]
-		} else if strings.HasPrefix(cleaned, "+1") {
-			countryCode = "+1"
-			nationalNum = cleaned[2:]
-		} else if strings.HasPrefix(cleaned, "+44") {
-			countryCode = "+44"
-			nationalNum = cleaned[3:]
-		} else {
+		if code, national, ok := checkers.SplitDialCode(cleaned); ok {
+			countryCode = code
+			nationalNum = national
+		} else if len(cleaned) > 3 {
 			// Asumir código de 2-3 dígitos
-			if len(cleaned) > 3 {
-				countryCode = cleaned[:3]
-				nationalNum = cleaned[3:]
-			}
+			countryCode = cleaned[:3]
+			nationalNum = cleaned[3:]
 		}
 	}
 
-	// Detectar si es número premium español
-	isPremium := false
-	for _, prefix := range n.premiumPrefixes {
-		if strings.HasPrefix(nationalNum, prefix) {
-			isPremium = true
-			break
-		}
-	}
+	// Detectar número de tarificación especial según el plan del país
+	premium := checkers.MatchPremium(countryCode, nationalNum)
+	isPremium := premium != nil
 
 	indicators := &checkers.Indicators{
 		Original:    rawPhone,
@@ -262,6 +273,7 @@ func (n *Normalizer) NormalizePhone(ctx context.Context, rawPhone string) (*chec
 		CountryCode: countryCode,
 		NationalNum: nationalNum,
 		IsPremium:   isPremium,
+		Premium:     premium,
 	}
 
 	log.Debug().
@@ -290,16 +302,29 @@ func extractTLD(domain string) string {
 	return parts[len(parts)-1]
 }
 
+// urlHost host y puerto para URL.Host (las IPv6 van entre corchetes)
+func urlHost(host, port string) string {
+	if port != "" {
+		return net.JoinHostPort(host, port)
+	}
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
 // NormalizeResult resultado de la normalización
 type NormalizeResult struct {
 	OriginalURL   string
 	NormalizedURL string
-	ExpandedURL   string   // URL expandida si era shortener
+	ExpandedURL   string   // URL final si era shortener
 	Domain        string
 	IP            string   // IP resuelta o directa si es IP-based URL
 	Scheme        string
 	IsShortener   bool
-	ExpandChain   []string // Cadena de redirects si es shortener
+	ExpandChain   []string // Cadena de redirects (URL inicial incluida); vacía si no redirige
+	ExpandStatus  int      // Status HTTP de la última respuesta de la cadena (0 = ninguna)
+	ExpandError   error    // Error de red que cortó la cadena
 	Error         error
 }
 
@@ -314,7 +339,9 @@ func (n *Normalizer) Normalize(ctx context.Context, rawURL string) *NormalizeRes
 	rawURL = strings.TrimSpace(rawURL)
 
 	// Añadir scheme si no tiene
-	if !strings.HasPrefix(rawURL, "http://") && !strings.HasPrefix(rawURL, "https://") {
+	// (sin distinguir mayúsculas: HTTP://Evil.COM es la misma URL que http://evil.com)
+	lowerURL := strings.ToLower(rawURL)
+	if !strings.HasPrefix(lowerURL, "http://") && !strings.HasPrefix(lowerURL, "https://") {
 		rawURL = "http://" + rawURL
 	}
 
@@ -330,12 +357,17 @@ func (n *Normalizer) Normalize(ctx context.Context, rawURL string) *NormalizeRes
 	parsed.Host = strings.ToLower(parsed.Host)
 	parsed.Scheme = strings.ToLower(parsed.Scheme)
 
-	// Remover puerto default
+	// Remover puerto default. Hostname() ya quita los corchetes de las IPv6 (http://[::1]/):
+	// las IPs se guardan en forma canónica para que la misma IP escrita de otra forma coincida
 	host := parsed.Hostname()
 	port := parsed.Port()
+	if ip := net.ParseIP(host); ip != nil {
+		host = ip.String()
+	}
 	if (parsed.Scheme == "http" && port == "80") || (parsed.Scheme == "https" && port == "443") {
-		parsed.Host = host
+		port = ""
 	}
+	parsed.Host = urlHost(host, port)
 
 	// Remover fragmento (#)
 	parsed.Fragment = ""
@@ -359,58 +391,61 @@ func (n *Normalizer) Normalize(ctx context.Context, rawURL string) *NormalizeRes
 	if ip := net.ParseIP(host); ip != nil {
 		result.IP = host
 		log.Debug().Str("ip", host).Msg("[Normalizer] URL uses direct IP")
-	} else {
+	} else if !n.offline.Load() {
 		// Resolver DNS para obtener IP
 		result.IP = n.resolveIP(ctx, host)
 	}
 
-	// Verificar si es shortener y expandir
-	if n.isShortener(host) {
-		result.IsShortener = true
-		log.Debug().Str("domain", host).Msg("[Normalizer] Detected URL shortener, expanding...")
-		expanded, chain := n.expandShortener(ctx, result.NormalizedURL)
-		if expanded != "" && expanded != result.NormalizedURL {
-			result.ExpandedURL = expanded
-			result.ExpandChain = chain
-			log.Debug().
-				Str("expanded", expanded).
-				Int("chain_length", len(chain)).
-				Msg("[Normalizer] Shortener expanded")
+	// Seguir redirects: los shorteners se sustituyen por su destino; en el resto se conserva la
+	// URL pero se guarda la cadena (redirectores intermedios usados para ofuscar el destino)
+	result.IsShortener = checkers.IsShortener(host)
+	if n.offline.Load() {
+		return result
+	}
+	chain, status, err := n.followRedirects(ctx, result.NormalizedURL)
+	result.ExpandStatus, result.ExpandError = status, err
+	if len(chain) > 1 {
+		result.ExpandChain = chain
+		if result.IsShortener {
+			result.ExpandedURL = chain[len(chain)-1]
 		}
+		log.Debug().
+			Str("final", chain[len(chain)-1]).
+			Int("hops", len(chain)-1).
+			Bool("shortener", result.IsShortener).
+			Msg("[Normalizer] Redirect chain followed")
 	}
 
 	return result
 }
 
-// isShortener verifica si el dominio es un servicio de acortamiento
-func (n *Normalizer) isShortener(domain string) bool {
-	// Verificar dominio exacto
-	if n.shortenerDomains[domain] {
-		return true
-	}
+// followRedirects sigue los redirects 3xx de startURL (sea cual sea el dominio) hasta
+// maxRedirectHops saltos o hasta agotar redirectTimeout. Devuelve la cadena con startURL
+// como primer elemento, el status de la última respuesta y el error de red que cortó la
+// cadena, si lo hubo. No sigue redirects a direcciones locales o privadas (SSRF).
+func (n *Normalizer) followRedirects(ctx context.Context, startURL string) ([]string, int, error) {
+	var status int
+	chain := []string{startURL}
 
-	// Verificar sin www
-	if strings.HasPrefix(domain, "www.") {
-		return n.shortenerDomains[domain[4:]]
+	if timeout := time.Duration(n.redirectTimeout.Load()); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
-	return false
-}
+	seen := map[string]bool{startURL: true}
+	currentURL := startURL
 
-// expandShortener expande una URL acortada siguiendo redirects
-func (n *Normalizer) expandShortener(ctx context.Context, shortURL string) (string, []string) {
-	chain := []string{shortURL}
-	currentURL := shortURL
-	maxRedirects := 10
-
-	for i := 0; i < maxRedirects; i++ {
-		select {
-		case <-ctx.Done():
-			log.Debug().Msg("[Normalizer] Context cancelled during expansion")
-			return currentURL, chain
-		default:
+	for i := 0; i < maxRedirectHops; i++ {
+		if ctx.Err() != nil {
+			log.Debug().Int("hops", len(chain)-1).Msg("[Normalizer] Redirect timeout reached, chain truncated")
+			break
 		}
 
+		base, err := url.Parse(currentURL)
+		if err != nil {
+			break
+		}
 		req, err := http.NewRequestWithContext(ctx, "HEAD", currentURL, nil)
 		if err != nil {
 			log.Debug().Err(err).Str("url", currentURL).Msg("[Normalizer] Failed to create request")
@@ -420,54 +455,54 @@ func (n *Normalizer) expandShortener(ctx context.Context, shortURL string) (stri
 		req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
 
 		resp, err := n.httpClient.Do(req)
+		if errors.Is(err, checkers.ErrPrivateAddress) {
+			log.Debug().Str("url", currentURL).Msg("[Normalizer] Not following redirect to private address")
+			break
+		}
 		if err != nil {
 			log.Debug().Err(err).Str("url", currentURL).Msg("[Normalizer] Failed to follow redirect")
-			break
+			return chain, 0, err
 		}
 		resp.Body.Close()
+		status = resp.StatusCode
 
-		// Verificar si hay redirect
-		if resp.StatusCode >= 300 && resp.StatusCode < 400 {
-			location := resp.Header.Get("Location")
-			if location == "" {
-				break
-			}
-
-			// Resolver URL relativa
-			base, _ := url.Parse(currentURL)
-			ref, err := url.Parse(location)
-			if err != nil {
-				break
-			}
-			nextURL := base.ResolveReference(ref).String()
-
-			log.Debug().
-				Int("status", resp.StatusCode).
-				Str("from", currentURL).
-				Str("to", nextURL).
-				Msg("[Normalizer] Following redirect")
-
-			chain = append(chain, nextURL)
-			currentURL = nextURL
-
-			// Verificar si el nuevo dominio ya no es shortener
-			parsed, _ := url.Parse(nextURL)
-			if parsed != nil && !n.isShortener(parsed.Hostname()) {
-				// Llegamos al destino final
-				break
-			}
-		} else {
+		if resp.StatusCode < 300 || resp.StatusCode >= 400 {
 			// No hay más redirects
 			break
 		}
-	}
 
-	finalURL := currentURL
-	if len(chain) > 1 {
-		finalURL = chain[len(chain)-1]
+		location := resp.Header.Get("Location")
+		if location == "" {
+			break
+		}
+
+		// Resolver URL relativa
+		ref, err := url.Parse(location)
+		if err != nil {
+			break
+		}
+		next := base.ResolveReference(ref)
+		if next.Scheme != "http" && next.Scheme != "https" {
+			break
+		}
+		nextURL := next.String()
+		if seen[nextURL] {
+			log.Debug().Str("url", nextURL).Msg("[Normalizer] Redirect loop detected")
+			break
+		}
+		seen[nextURL] = true
+
+		log.Debug().
+			Int("status", resp.StatusCode).
+			Str("from", currentURL).
+			Str("to", nextURL).
+			Msg("[Normalizer] Following redirect")
+
+		chain = append(chain, nextURL)
+		currentURL = nextURL
 	}
 
-	return finalURL, chain
+	return chain, status, nil
 }
 
 // resolveIP resuelve el dominio a IP
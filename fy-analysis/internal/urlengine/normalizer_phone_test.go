@@ -0,0 +1,76 @@
+package urlengine
+
+import (
+	"context"
+	"testing"
+
+	"github.com/trackfy/fy-analysis/internal/correlation"
+)
+
+func TestNormalizePhonePremium(t *testing.T) {
+	normalizer := NewNormalizer()
+	normalizer.SetOffline(true)
+
+	tests := []struct {
+		input      string
+		normalized string
+		premium    bool
+	}{
+		// Con y sin prefijo internacional
+		{"806123456", "+34806123456", true},
+		{"+34806123456", "+34806123456", true},
+		{"0034806123456", "+34806123456", true},
+		{"34806123456", "+34806123456", true},
+		{"+34 806 12 34 56", "+34806123456", true},
+		{"+529001234567", "+529001234567", true},
+		{"+19005550199", "+19005550199", true},
+		{"+449098765432", "+449098765432", true},
+		{"+44 (0)9098765432", "+4409098765432", true},
+		{"+5719001234567", "+5719001234567", true},
+		{"+56700123456", "+56700123456", true},
+		{"+5180812345", "+5180812345", true},
+		// Números nacionales más cortos de lo esperado: no se marcan
+		{"+3480612345", "+3480612345", false},
+		{"+4490987654", "+4490987654", false},
+		{"+34807", "+34807", false},
+		// Móviles que contienen un prefijo premium tras el inicio
+		{"+34612345678", "+34612345678", false},
+		{"+34612807123", "+34612807123", false},
+	}
+
+	for _, tt := range tests {
+		indicators, err := normalizer.NormalizePhone(context.Background(), tt.input)
+		if err != nil {
+			t.Errorf("NormalizePhone(%q): %v", tt.input, err)
+			continue
+		}
+		if indicators.Normalized != tt.normalized || indicators.IsPremium != tt.premium {
+			t.Errorf("NormalizePhone(%q) = %q premium=%v; want %q premium=%v",
+				tt.input, indicators.Normalized, indicators.IsPremium, tt.normalized, tt.premium)
+		}
+		if tt.premium && indicators.Premium == nil {
+			t.Errorf("NormalizePhone(%q): IsPremium without the matched prefix", tt.input)
+		}
+	}
+}
+
+func TestPremiumHeuristicExplainsCostClass(t *testing.T) {
+	normalizer := NewNormalizer()
+	normalizer.SetOffline(true)
+	heuristics := correlation.NewHeuristicEngine()
+
+	tests := map[string]string{
+		"+529001234567": "Este es un número de tarificación adicional en México (prefijo 900). Las llamadas tienen coste elevado.",
+		"806123456":     "Este es un número de tarificación adicional (ocio y entretenimiento) en España (prefijo 806). Las llamadas tienen coste elevado.",
+	}
+	for input, want := range tests {
+		indicators, err := normalizer.NormalizePhone(context.Background(), input)
+		if err != nil {
+			t.Fatalf("NormalizePhone(%q): %v", input, err)
+		}
+		result := heuristics.Analyze(context.Background(), indicators, nil)
+		if len(result.Reasons) == 0 || result.Reasons[0] != want {
+			t.Errorf("%s reasons = %q, want %q first", input, result.Reasons, want)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package urlengine
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFollowRedirectsRefusesPrivateDial(t *testing.T) {
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		http.Redirect(w, r, "/admin", http.StatusFound)
+	}))
+	defer server.Close()
+
+	n := NewNormalizer()
+	// IP literal y nombre que resuelve a loopback: sin comprobación previa de DNS, el dial
+	// es quien rechaza la IP que realmente se va a usar
+	for _, start := range []string{server.URL + "/", strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/"} {
+		chain, status, err := n.followRedirects(context.Background(), start)
+		if err != nil || status != 0 || len(chain) != 1 || chain[0] != start {
+			t.Errorf("followRedirects(%s) = %v, %d, %v; want the chain cut at the start URL", start, chain, status, err)
+		}
+	}
+	if hits != 0 {
+		t.Errorf("private server received %d requests", hits)
+	}
+}
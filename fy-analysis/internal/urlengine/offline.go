@@ -0,0 +1,38 @@
+package urlengine
+
+import (
+	"time"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/correlation"
+)
+
+// NewOfflineEngine engine sin base de datos y sin red (ni DNS ni redirects) con los checkers
+// indicados en lugar de los del registro: el score de Analyze sale de las heurísticas, de esos
+// checkers y de la agregación. Lo usa el corpus de regresión (TestCorpus) para fijar
+// los scores de extremo a extremo.
+func NewOfflineEngine(threatCheckers ...checkers.ThreatChecker) *Engine {
+	config := &EngineConfig{
+		CheckTimeout:       3 * time.Second,
+		PhoneLookupTimeout: 120 * time.Millisecond,
+		PhoneLookupTTL:     10 * time.Minute,
+	}
+
+	normalizer := NewNormalizer()
+	normalizer.SetOffline(true)
+
+	orchestrator := NewOrchestrator(threatCheckers, config.CheckTimeout)
+
+	return &Engine{
+		orchestrator:    orchestrator,
+		allCheckers:     threatCheckers,
+		weights:         mergeWeights(analysisSourceWeights, nil),
+		normalizer:      normalizer,
+		aggregator:      orchestrator.aggregator,
+		heuristics:      correlation.NewHeuristicEngine(),
+		phoneHeuristics: correlation.NewPhoneHeuristicAdapter(),
+		phoneCache:      newPhoneLookupCache(config.PhoneLookupTTL, 10000),
+		limiter:         newAnalysisLimiter(checkers.AnalysisLimitsConfig{}),
+		config:          config,
+	}
+}
@@ -2,11 +2,18 @@ package urlengine
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // Orchestrator coordina la verificación paralela con múltiples checkers
@@ -16,21 +23,106 @@ type Orchestrator struct {
 	normalizer *Normalizer
 	extractor  *Extractor
 	aggregator *Aggregator
+
+	// Fallos consecutivos por checker (un checker roto en silencio sigue "enabled")
+	failuresMu sync.Mutex
+	failures   map[string]*checkerFailures
+
+	// Recargables (ReloadConfig, con failuresMu): modo de espera de los checkers y circuito por checker
+	priorityMode     string
+	breakerThreshold int           // Fallos seguidos que abren el circuito (0 = sin circuito)
+	breakerCooldown  time.Duration // Tiempo sin llamar a un checker con el circuito abierto
+}
+
+// Modos de CHECKER_PRIORITY_MODE
+const (
+	// PriorityModeAll espera a todos los checkers (hasta el timeout)
+	PriorityModeAll = "all"
+	// PriorityModePriority una confirmación con confianza >= priorityConfidence da el análisis
+	// por terminado: los checkers pendientes se cancelan (menos latencia, menos fuentes)
+	PriorityModePriority = "priority"
+)
+
+// priorityConfidence confianza mínima de una amenaza que corta el análisis en PriorityModePriority
+const priorityConfidence = 0.9
+
+// defaultBreakerCooldown tiempo con el circuito abierto si no se configura
+const defaultBreakerCooldown = 30 * time.Second
+
+var (
+	// errCheckerTimeout el checker no respondió antes del timeout del análisis
+	errCheckerTimeout = errors.New("checker timed out")
+	// errCheckerSkipped el análisis terminó antes (PriorityModePriority) sin esperar al checker
+	errCheckerSkipped = errors.New("checker skipped: threat already confirmed")
+	// errCircuitOpen el checker acumula fallos seguidos y no se llama hasta que pase el cooldown
+	errCircuitOpen = errors.New("checker circuit open")
+)
+
+// ValidPriorityMode indica si mode es un valor de CHECKER_PRIORITY_MODE
+func ValidPriorityMode(mode string) bool {
+	return mode == PriorityModeAll || mode == PriorityModePriority
+}
+
+// checkTimeoutKey clave de contexto del timeout de checkers de una petición (timeout_ms)
+type checkTimeoutKey struct{}
+
+// withCheckTimeout fija el timeout de los checkers de un análisis en lugar del del orchestrator
+func withCheckTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, checkTimeoutKey{}, timeout)
+}
+
+// checkTimeout timeout de los checkers: el de la petición o, si no lo fija, el del orchestrator
+func (o *Orchestrator) checkTimeout(ctx context.Context) time.Duration {
+	if timeout, ok := ctx.Value(checkTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return timeout
+	}
+	return o.timeout
+}
+
+// checkerFailures fallos consecutivos de un checker desde su último éxito
+type checkerFailures struct {
+	consecutive int
+	lastError   string
+	lastFailure time.Time
+	openUntil   time.Time // Circuito abierto hasta entonces (cero = cerrado)
 }
 
 // NewOrchestrator crea un nuevo orchestrator
 func NewOrchestrator(threatCheckers []checkers.ThreatChecker, timeout time.Duration) *Orchestrator {
+	normalizer := NewNormalizer()
+	normalizer.SetRedirectTimeout(timeout)
+
 	return &Orchestrator{
 		checkers:   threatCheckers,
 		timeout:    timeout,
-		normalizer: NewNormalizer(),
+		normalizer: normalizer,
 		extractor:  NewExtractor(),
 		aggregator: NewAggregator(),
+		failures:   make(map[string]*checkerFailures),
+
+		priorityMode:    PriorityModeAll,
+		breakerCooldown: defaultBreakerCooldown,
 	}
 }
 
+// configure fija el modo de espera y el circuito de los checkers (antes de usar el orchestrator)
+func (o *Orchestrator) configure(priorityMode string, breakerThreshold int, breakerCooldown time.Duration) {
+	if !ValidPriorityMode(priorityMode) {
+		if priorityMode != "" {
+			log.Warn().Str("mode", priorityMode).Msg("[Orchestrator] Unknown checker priority mode, using all")
+		}
+		priorityMode = PriorityModeAll
+	}
+	if breakerCooldown <= 0 {
+		breakerCooldown = defaultBreakerCooldown
+	}
+	o.priorityMode = priorityMode
+	o.breakerThreshold = breakerThreshold
+	o.breakerCooldown = breakerCooldown
+}
+
 // Check realiza la verificación completa de una URL
-func (o *Orchestrator) Check(ctx context.Context, rawURL string) *URLCheckResponse {
+func (o *Orchestrator) Check(ctx context.Context, rawURL string, debug bool) *URLCheckResponse {
 	startTime := time.Now()
 
 	log.Info().
@@ -69,7 +161,7 @@ func (o *Orchestrator) Check(ctx context.Context, rawURL string) *URLCheckRespon
 		Msg("[Orchestrator] All checkers completed")
 
 	// 4. Agregar resultados y calcular score
-	response := o.aggregator.Aggregate(normalized, indicators, results, startTime)
+	response := o.aggregator.Aggregate(normalized, indicators, results, startTime, debug)
 
 	// Log resultado final
 	log.Info().
@@ -100,8 +192,12 @@ func (o *Orchestrator) runCheckersParallel(ctx context.Context, indicators *chec
 		Int("total", len(o.checkers)).
 		Msg("[Orchestrator] Running enabled checkers")
 
-	// Lanzar goroutine por cada checker
+	// Lanzar goroutine por cada checker (los de circuito abierto responden sin llamarlos)
 	for _, checker := range enabledCheckers {
+		if o.circuitOpen(checker.Name(), time.Now()) {
+			resultsChan <- &checkers.CheckResult{Source: checker.Name(), Error: errCircuitOpen}
+			continue
+		}
 		wg.Add(1)
 		go func(c checkers.ThreatChecker) {
 			defer wg.Done()
@@ -134,16 +230,32 @@ func (o *Orchestrator) runSingleChecker(
 	startTime := time.Now()
 	checkerName := checker.Name()
 
+	ctx, span := tracing.Tracer().Start(ctx, "Checker."+checkerName,
+		trace.WithAttributes(attribute.String("checker.name", checkerName)),
+	)
+	defer span.End()
+
 	log.Debug().
 		Str("checker", checkerName).
 		Str("url", indicators.FullURL).
 		Msg("[Orchestrator] Starting checker")
 
-	// Ejecutar checker
-	result, err := checker.Check(ctx, indicators)
+	// Ejecutar checker (si la URL redirige, los que soportan la cadena evalúan también los saltos)
+	var result *checkers.CheckResult
+	var err error
+	if chainChecker, ok := checker.(checkers.RedirectChainChecker); ok && len(indicators.RedirectChain) > 1 {
+		result, err = chainChecker.CheckRedirectChain(ctx, indicators, indicators.RedirectChain)
+	} else {
+		result, err = checker.Check(ctx, indicators)
+	}
 
 	latency := time.Since(startTime)
 
+	// Cancelado (cliente desconectado o análisis ya resuelto en modo priority): no es culpa del checker
+	if err == nil || !errors.Is(ctx.Err(), context.Canceled) {
+		o.recordOutcome(checkerName, err)
+	}
+
 	if err != nil {
 		log.Warn().
 			Err(err).
@@ -151,6 +263,10 @@ func (o *Orchestrator) runSingleChecker(
 			Dur("latency", latency).
 			Msg("[Orchestrator] Checker failed")
 
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		span.SetAttributes(attribute.Bool("checker.found", false))
+
 		// Enviar resultado con error (graceful degradation)
 		resultsChan <- &checkers.CheckResult{
 			Source:  checkerName,
@@ -164,6 +280,11 @@ func (o *Orchestrator) runSingleChecker(
 	result.Source = checkerName
 	result.Latency = latency
 
+	span.SetAttributes(attribute.Bool("checker.found", result.Found))
+	if result.Found {
+		span.SetAttributes(attribute.String("checker.threat_type", result.ThreatType))
+	}
+
 	log.Debug().
 		Str("checker", checkerName).
 		Bool("found", result.Found).
@@ -199,40 +320,100 @@ func (o *Orchestrator) getCheckersForType(inputType checkers.InputType) []checke
 
 // CheckWithType realiza verificación filtrando por tipo de input
 func (o *Orchestrator) CheckWithType(ctx context.Context, indicators *checkers.Indicators) []*checkers.CheckResult {
-	// Crear context con timeout
-	checkCtx, cancel := context.WithTimeout(ctx, o.timeout)
-	defer cancel()
+	return o.CheckWithTypeStream(ctx, indicators, nil)
+}
 
-	var wg sync.WaitGroup
+// CheckWithTypeStream igual que CheckWithType, pero llama a onResult (si no es nil) según
+// termina cada checker. onResult se ejecuta siempre en la goroutine del llamador.
+//
+// Al vencer el timeout no se espera a los checkers pendientes: se retorna lo recibido y cada
+// pendiente aparece como resultado con errCheckerTimeout (no como si estuviera desactivado).
+func (o *Orchestrator) CheckWithTypeStream(ctx context.Context, indicators *checkers.Indicators, onResult func(*checkers.CheckResult)) []*checkers.CheckResult {
+	// Crear context con timeout (el de la petición si lo fija)
+	timeout := o.checkTimeout(ctx)
+	checkCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
 	// Filtrar checkers por tipo
 	compatibleCheckers := o.getCheckersForType(indicators.InputType)
+	// Con buffer: los checkers que terminan tras el timeout no se bloquean al enviar
 	resultsChan := make(chan *checkers.CheckResult, len(compatibleCheckers))
 
 	log.Debug().
 		Str("input_type", string(indicators.InputType)).
 		Int("compatible_checkers", len(compatibleCheckers)).
+		Dur("timeout", timeout).
 		Msg("[Orchestrator] Running type-filtered checkers")
 
-	// Lanzar goroutine por cada checker compatible
+	// Lanzar goroutine por cada checker compatible (los de circuito abierto responden sin llamarlos)
+	pending := make(map[string]bool, len(compatibleCheckers))
 	for _, checker := range compatibleCheckers {
-		wg.Add(1)
-		go func(c checkers.ThreatChecker) {
-			defer wg.Done()
-			o.runSingleChecker(checkCtx, c, indicators, resultsChan)
-		}(checker)
+		pending[checker.Name()] = true
+		if o.circuitOpen(checker.Name(), time.Now()) {
+			resultsChan <- &checkers.CheckResult{Source: checker.Name(), Error: errCircuitOpen}
+			continue
+		}
+		go o.runSingleChecker(checkCtx, checker, indicators, resultsChan)
 	}
 
-	// Esperar a que terminen todos
-	go func() {
-		wg.Wait()
-		close(resultsChan)
-	}()
-
-	// Recolectar resultados
+	// Recolectar resultados hasta que respondan todos, venza el timeout o, en modo priority,
+	// un checker confirme la amenaza
 	var results []*checkers.CheckResult
-	for result := range resultsChan {
+	confirmed := false
+	o.failuresMu.Lock()
+	priorityMode := o.priorityMode
+	o.failuresMu.Unlock()
+	emit := func(result *checkers.CheckResult) {
 		results = append(results, result)
+		if onResult != nil {
+			onResult(result)
+		}
+	}
+collect:
+	for len(pending) > 0 {
+		select {
+		case result := <-resultsChan:
+			delete(pending, result.Source)
+			// Un checker que corta por el deadline también es un timeout
+			if result.Error != nil && errors.Is(checkCtx.Err(), context.DeadlineExceeded) {
+				result.Error = fmt.Errorf("%w: %v", errCheckerTimeout, result.Error)
+			}
+			emit(result)
+			if priorityMode == PriorityModePriority && result.Error == nil && result.Found && result.Confidence >= priorityConfidence {
+				confirmed = true
+				break collect
+			}
+		case <-checkCtx.Done():
+			break collect
+		}
+	}
+
+	if len(pending) > 0 {
+		// Cancelado por el llamador (cliente desconectado): no es un timeout
+		err := errCheckerTimeout
+		switch {
+		case confirmed:
+			err = errCheckerSkipped
+			cancel()
+		case !errors.Is(checkCtx.Err(), context.DeadlineExceeded):
+			err = checkCtx.Err()
+		}
+		for _, checker := range compatibleCheckers {
+			if pending[checker.Name()] {
+				emit(&checkers.CheckResult{Source: checker.Name(), Error: err, Latency: timeout})
+			}
+		}
+
+		if confirmed {
+			log.Debug().
+				Int("skipped", len(pending)).
+				Msg("[Orchestrator] Threat confirmed, pending checkers skipped (priority mode)")
+		} else {
+			log.Warn().
+				Int("pending", len(pending)).
+				Dur("timeout", timeout).
+				Msg("[Orchestrator] Checkers did not respond in time")
+		}
 	}
 
 	return results
@@ -267,13 +448,169 @@ func (o *Orchestrator) AddChecker(checker checkers.ThreatChecker) {
 
 // GetCheckerStatus retorna el estado de todos los checkers
 func (o *Orchestrator) GetCheckerStatus() []map[string]interface{} {
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+
 	var status []map[string]interface{}
 	for _, c := range o.checkers {
-		status = append(status, map[string]interface{}{
-			"name":    c.Name(),
-			"weight":  c.Weight(),
-			"enabled": c.IsEnabled(),
-		})
+		entry := map[string]interface{}{
+			"name":                 c.Name(),
+			"weight":               c.Weight(),
+			"enabled":              c.IsEnabled(),
+			"consecutive_failures": 0,
+		}
+		if f, ok := o.failures[c.Name()]; ok && f.consecutive > 0 {
+			entry["consecutive_failures"] = f.consecutive
+			entry["last_error"] = f.lastError
+			entry["last_failure_at"] = f.lastFailure
+			if !f.openUntil.IsZero() {
+				entry["circuit_open_until"] = f.openUntil
+			}
+		}
+		status = append(status, entry)
 	}
 	return status
 }
+
+// recordOutcome actualiza los fallos consecutivos de un checker (un éxito los reinicia y cierra
+// su circuito). Al llegar a breakerThreshold fallos seguidos el circuito se abre breakerCooldown;
+// pasado el cooldown el checker vuelve a llamarse y un nuevo fallo lo reabre.
+func (o *Orchestrator) recordOutcome(name string, err error) {
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+
+	f, ok := o.failures[name]
+	if !ok {
+		f = &checkerFailures{}
+		o.failures[name] = f
+	}
+	if err == nil {
+		if !f.openUntil.IsZero() {
+			log.Info().Str("checker", name).Msg("[Orchestrator] Checker recovered, circuit closed")
+		}
+		f.consecutive = 0
+		f.openUntil = time.Time{}
+		return
+	}
+	f.consecutive++
+	f.lastError = err.Error()
+	f.lastFailure = time.Now()
+	if o.breakerThreshold > 0 && f.consecutive >= o.breakerThreshold {
+		f.openUntil = f.lastFailure.Add(o.breakerCooldown)
+		log.Warn().
+			Str("checker", name).
+			Int("consecutive_failures", f.consecutive).
+			Time("open_until", f.openUntil).
+			Msg("[Orchestrator] Checker circuit opened")
+	}
+}
+
+// circuitOpen indica si el circuito del checker está abierto en now
+func (o *Orchestrator) circuitOpen(name string, now time.Time) bool {
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+
+	f, ok := o.failures[name]
+	return ok && now.Before(f.openUntil)
+}
+
+// reevaluateBreakers aplica el umbral vigente a los fallos acumulados: abre el circuito de los
+// checkers que ya lo alcanzan y cierra el de los que no (umbral subido o circuito desactivado).
+// Retorna los checkers cuyo circuito ha cambiado.
+func (o *Orchestrator) reevaluateBreakers(now time.Time) (opened, closed []string) {
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+
+	for name, f := range o.failures {
+		isOpen := now.Before(f.openUntil)
+		shouldOpen := o.breakerThreshold > 0 && f.consecutive >= o.breakerThreshold
+		switch {
+		case shouldOpen && !isOpen:
+			f.openUntil = now.Add(o.breakerCooldown)
+			opened = append(opened, name)
+		case !shouldOpen && !f.openUntil.IsZero():
+			f.openUntil = time.Time{}
+			if isOpen {
+				closed = append(closed, name)
+			}
+		}
+	}
+	sort.Strings(opened)
+	sort.Strings(closed)
+	return opened, closed
+}
+
+// consecutiveFailures fallos seguidos de un checker desde su último éxito
+func (o *Orchestrator) consecutiveFailures(name string) int {
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+
+	if f, ok := o.failures[name]; ok {
+		return f.consecutive
+	}
+	return 0
+}
+
+// Health ejecuta Health de los checkers habilitados en paralelo
+func (o *Orchestrator) Health(ctx context.Context) map[string]error {
+	enabled := o.getEnabledCheckers()
+	results := make(map[string]error, len(enabled))
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range enabled {
+		wg.Add(1)
+		go func(c checkers.ThreatChecker) {
+			defer wg.Done()
+			err := c.Health(ctx)
+			mu.Lock()
+			results[c.Name()] = err
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// Close cierra todos los checkers (conexiones, clientes HTTP, Chrome)
+func (o *Orchestrator) Close() {
+	closeCheckers(o.checkers)
+}
+
+func closeCheckers(list []checkers.ThreatChecker) {
+	for _, c := range list {
+		if err := c.Close(); err != nil {
+			log.Warn().Err(err).Str("checker", c.Name()).Msg("[Orchestrator] Failed to close checker")
+		}
+	}
+}
+
+// rebuild orchestrator con otro conjunto de checkers y timeout, sin reiniciarlos: comparte
+// normalizer, extractor y aggregator, y conserva los fallos consecutivos de los checkers que
+// siguen (los reactivados empiezan de cero en lugar de heredar fallos antiguos)
+func (o *Orchestrator) rebuild(active []checkers.ThreatChecker, timeout time.Duration) *Orchestrator {
+	rebuilt := &Orchestrator{
+		checkers:   active,
+		timeout:    timeout,
+		normalizer: o.normalizer,
+		extractor:  o.extractor,
+		aggregator: o.aggregator,
+		failures:   make(map[string]*checkerFailures),
+
+		priorityMode:     o.priorityMode,
+		breakerThreshold: o.breakerThreshold,
+		breakerCooldown:  o.breakerCooldown,
+	}
+	rebuilt.normalizer.SetRedirectTimeout(timeout)
+
+	o.failuresMu.Lock()
+	defer o.failuresMu.Unlock()
+	for _, c := range active {
+		if f, ok := o.failures[c.Name()]; ok {
+			copied := *f
+			rebuilt.failures[c.Name()] = &copied
+		}
+	}
+	return rebuilt
+}
@@ -0,0 +1,224 @@
+package urlengine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/analyzer/phone"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+	"github.com/trackfy/fy-analysis/internal/correlation"
+)
+
+// PhoneLookupResponse respuesta compacta para identificación de llamadas (caller-ID)
+type PhoneLookupResponse struct {
+	Number      string `json:"number"`
+	Normalized  string `json:"normalized"`
+	CountryCode string `json:"country_code,omitempty"`
+	Country     string `json:"country,omitempty"`
+	LineType    string `json:"line_type"` // mobile, landline, premium, toll_free, unknown
+	Label       string `json:"label"`     // Texto corto para la pantalla de llamada
+	RiskLevel   string `json:"risk_level"`
+	RiskScore   int    `json:"risk_score"`
+	Reason      string `json:"reason,omitempty"`
+	ThreatType  string `json:"threat_type,omitempty"`
+	ReportCount int    `json:"report_count"`
+	IsPremium   bool   `json:"is_premium"`
+	Partial     bool   `json:"partial"` // Alguna fuente no respondió a tiempo
+	Cached      bool   `json:"cached"`
+	LatencyMs   int64  `json:"latency_ms"`
+}
+
+// phoneLookupCache cache en memoria de lookups (call screening repite los mismos números)
+type phoneLookupCache struct {
+	mu         sync.RWMutex
+	entries    map[string]phoneLookupCacheEntry
+	ttl        time.Duration
+	maxEntries int
+}
+
+type phoneLookupCacheEntry struct {
+	response  PhoneLookupResponse
+	expiresAt time.Time
+}
+
+func newPhoneLookupCache(ttl time.Duration, maxEntries int) *phoneLookupCache {
+	return &phoneLookupCache{
+		entries:    make(map[string]phoneLookupCacheEntry),
+		ttl:        ttl,
+		maxEntries: maxEntries,
+	}
+}
+
+func (c *phoneLookupCache) get(key string) (PhoneLookupResponse, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[key]
+	c.mu.RUnlock()
+
+	if !ok || time.Now().After(entry.expiresAt) {
+		return PhoneLookupResponse{}, false
+	}
+	return entry.response, true
+}
+
+func (c *phoneLookupCache) set(key string, response PhoneLookupResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Al llenarse se descartan las entradas caducadas; si no basta, se vacía
+	if len(c.entries) >= c.maxEntries {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxEntries {
+			c.entries = make(map[string]phoneLookupCacheEntry)
+		}
+	}
+
+	c.entries[key] = phoneLookupCacheEntry{response: response, expiresAt: time.Now().Add(c.ttl)}
+}
+
+// LookupPhone identificación rápida de un número entrante.
+// Ejecuta LocalDB y heurísticas en paralelo con un presupuesto de tiempo;
+// lo que no responda a tiempo se omite y se marca la respuesta como parcial.
+func (e *Engine) LookupPhone(ctx context.Context, number string) *PhoneLookupResponse {
+	startTime := time.Now()
+
+	indicators, err := e.normalizer.NormalizePhone(ctx, number)
+	if err != nil || indicators.Normalized == "" {
+		return &PhoneLookupResponse{
+			Number:    number,
+			LineType:  "unknown",
+			Label:     "Número no válido",
+			RiskLevel: string(RiskLevelWarning),
+			RiskScore: 50,
+			Reason:    "No se pudo interpretar el número",
+			LatencyMs: time.Since(startTime).Milliseconds(),
+		}
+	}
+
+	if cached, ok := e.phoneCache.get(indicators.Normalized); ok {
+		cached.Number = number
+		cached.Cached = true
+		cached.LatencyMs = time.Since(startTime).Milliseconds()
+		return &cached
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.config.PhoneLookupTimeout)
+	defer cancel()
+
+	lineInfo := phone.NewAnalyzer().Analyze(indicators.Normalized, "", "").PhoneInfo
+
+	// LocalDB en paralelo; el canal con buffer evita bloquear la goroutine si se agota el tiempo
+	dbCh := make(chan *checkers.CheckResult, 1)
+	if e.phoneDB != nil && e.phoneDB.IsEnabled() {
+		go func() {
+			result, err := e.phoneDB.Check(ctx, indicators)
+			if err != nil {
+				log.Debug().Err(err).Msg("[Engine] Phone lookup LocalDB check failed")
+				result = nil
+			}
+			dbCh <- result
+		}()
+	} else {
+		dbCh <- nil
+	}
+
+	// Las heurísticas de teléfono son solo CPU, se ejecutan mientras responde la DB
+	heuristic := e.heuristics.Analyze(ctx, indicators, nil)
+
+	var dbResult *checkers.CheckResult
+	partial := false
+	select {
+	case dbResult = <-dbCh:
+	case <-ctx.Done():
+		partial = true
+	}
+
+	response := PhoneLookupResponse{
+		Number:      number,
+		Normalized:  indicators.Normalized,
+		CountryCode: indicators.CountryCode,
+		LineType:    "unknown",
+		IsPremium:   indicators.IsPremium,
+		Partial:     partial,
+	}
+	if lineInfo != nil {
+		response.Country = lineInfo.Country
+		response.LineType = lineInfo.Type
+	}
+
+	buildPhoneVerdict(&response, dbResult, heuristic)
+	response.LatencyMs = time.Since(startTime).Milliseconds()
+
+	// Las respuestas parciales no se cachean para no fijar un veredicto incompleto
+	if !partial {
+		e.phoneCache.set(indicators.Normalized, response)
+	}
+
+	log.Debug().
+		Str("phone", indicators.Normalized).
+		Str("label", response.Label).
+		Int("risk_score", response.RiskScore).
+		Bool("partial", partial).
+		Int64("latency_ms", response.LatencyMs).
+		Msg("[Engine] Phone lookup completed")
+
+	return &response
+}
+
+// buildPhoneVerdict combina LocalDB y heurísticas en etiqueta, nivel y razón corta
+func buildPhoneVerdict(response *PhoneLookupResponse, dbResult *checkers.CheckResult, heuristic *correlation.HeuristicResult) {
+	score := 0
+	reason := ""
+
+	if heuristic != nil && heuristic.Score > 0 {
+		score = heuristic.Score
+		if len(heuristic.Reasons) > 0 {
+			reason = heuristic.Reasons[0]
+		}
+	}
+
+	if dbResult != nil {
+		if count, ok := dbResult.RawData["report_count"].(int); ok {
+			response.ReportCount = count
+		}
+		if dbResult.Found {
+			response.ThreatType = dbResult.ThreatType
+			dbScore := int(dbResult.Confidence * 100)
+			if dbScore > score {
+				score = dbScore
+			}
+			if reasons, ok := dbResult.RawData["reasons"].([]string); ok && len(reasons) > 0 {
+				reason = reasons[0]
+			}
+		}
+	}
+
+	if score > 100 {
+		score = 100
+	}
+	response.RiskScore = score
+	response.RiskLevel = string(GetRiskLevel(score))
+	response.Reason = reason
+
+	switch {
+	case dbResult != nil && dbResult.Found:
+		response.Label = "Posible fraude"
+		if response.ThreatType == "spam" {
+			response.Label = "Spam reportado"
+		}
+	case response.IsPremium:
+		response.Label = "Tarificación adicional"
+	case response.RiskLevel == string(RiskLevelDanger):
+		response.Label = "Posible fraude"
+	case response.RiskLevel == string(RiskLevelWarning):
+		response.Label = "Sospechoso"
+	default:
+		response.Label = "Sin reportes"
+	}
+}
@@ -0,0 +1,161 @@
+package urlengine
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+// callScreeningNumbers mezcla de números repetidos (cache) y premium/móviles de varios países
+var callScreeningNumbers = []string{
+	"+34612345678", "+34806123456", "+34911234567", "+34900123456",
+	"+529001234567", "+5215512345678", "+19005550199", "+449098765432",
+	"+5491112345678", "+573001234567", "+56912345678", "+51987654321",
+}
+
+// newPhoneLookupEngine engine offline con una LocalDB simulada que tarda dbDelay en responder
+func newPhoneLookupEngine(dbDelay time.Duration) (*Engine, *stubChecker) {
+	db := &stubChecker{
+		name:  "localdb",
+		delay: dbDelay,
+		result: checkers.CheckResult{
+			Source:  "localdb",
+			RawData: map[string]interface{}{"report_count": 2},
+		},
+	}
+	engine := NewOfflineEngine()
+	engine.phoneDB = db
+	return engine, db
+}
+
+// stalledChecker LocalDB colgada: no responde ni al cancelar el contexto hasta que se cierra
+// release, así el lookup solo puede terminar por su presupuesto
+type stalledChecker struct {
+	stubChecker
+	release chan struct{}
+}
+
+func (s *stalledChecker) Check(context.Context, *checkers.Indicators) (*checkers.CheckResult, error) {
+	s.calls.Add(1)
+	<-s.release
+	return nil, context.Canceled
+}
+
+func TestLookupPhoneCacheAbsorbsRepeatedNumbers(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(level)
+
+	engine, db := newPhoneLookupEngine(0)
+
+	for round := 0; round < 5; round++ {
+		for _, number := range callScreeningNumbers {
+			response := engine.LookupPhone(context.Background(), number)
+			if response.Partial {
+				t.Fatalf("round %d, %s: partial with a responsive DB", round, number)
+			}
+			if response.Cached != (round > 0) {
+				t.Errorf("round %d, %s: cached=%v", round, number, response.Cached)
+			}
+		}
+	}
+	// Solo la primera vez de cada número llega a la DB
+	if calls := int(db.calls.Load()); calls != len(callScreeningNumbers) {
+		t.Errorf("db calls = %d, want %d (one per distinct number)", calls, len(callScreeningNumbers))
+	}
+}
+
+func TestLookupPhoneStalledDBIsPartialAndNotCached(t *testing.T) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(level)
+
+	db := &stalledChecker{stubChecker: stubChecker{name: "localdb"}, release: make(chan struct{})}
+	defer close(db.release)
+	engine := NewOfflineEngine()
+	engine.phoneDB = db
+	engine.config.PhoneLookupTimeout = 10 * time.Millisecond
+
+	for i := 0; i < 2; i++ {
+		response := engine.LookupPhone(context.Background(), "+34806123456")
+
+		// Sin la DB sigue habiendo veredicto de las heurísticas, pero no se cachea
+		if !response.Partial || response.Cached {
+			t.Errorf("lookup %d: partial=%v cached=%v, want partial and not cached", i, response.Partial, response.Cached)
+		}
+		if !response.IsPremium || response.Label != "Tarificación adicional" {
+			t.Errorf("lookup %d: premium=%v label=%q, want the premium verdict", i, response.IsPremium, response.Label)
+		}
+		if _, ok := engine.phoneCache.get(response.Normalized); ok {
+			t.Errorf("lookup %d: partial response was cached", i)
+		}
+	}
+	if calls := db.calls.Load(); calls != 2 {
+		t.Errorf("db calls = %d, want 2 (a partial response must not be served from cache)", calls)
+	}
+}
+
+// BenchmarkLookupPhone latencia de call screening (GET /analyze/phone/{number}); el
+// presupuesto p95 es 150ms. call-screening reporta p50/p95/p99 del peor caso: 20 llamadas
+// concurrentes sin cache contra una LocalDB de 20ms.
+func BenchmarkLookupPhone(b *testing.B) {
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	defer zerolog.SetGlobalLevel(level)
+
+	b.Run("cached", func(b *testing.B) {
+		engine, _ := newPhoneLookupEngine(0)
+		for i := 0; i < b.N; i++ {
+			engine.LookupPhone(context.Background(), callScreeningNumbers[i%len(callScreeningNumbers)])
+		}
+	})
+
+	b.Run("uncached", func(b *testing.B) {
+		engine, _ := newPhoneLookupEngine(0)
+		engine.phoneCache = newPhoneLookupCache(0, 1)
+		for i := 0; i < b.N; i++ {
+			engine.LookupPhone(context.Background(), callScreeningNumbers[i%len(callScreeningNumbers)])
+		}
+	})
+
+	b.Run("call-screening", func(b *testing.B) {
+		engine, _ := newPhoneLookupEngine(20 * time.Millisecond)
+		engine.phoneCache = newPhoneLookupCache(0, 1)
+
+		const workers = 20
+		latencies := make([]time.Duration, b.N)
+		var next atomic.Int64
+		next.Store(-1)
+
+		b.ResetTimer()
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					i := int(next.Add(1))
+					if i >= b.N {
+						return
+					}
+					start := time.Now()
+					engine.LookupPhone(context.Background(), callScreeningNumbers[i%len(callScreeningNumbers)])
+					latencies[i] = time.Since(start)
+				}
+			}()
+		}
+		wg.Wait()
+		b.StopTimer()
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		b.ReportMetric(float64(latencies[b.N/2].Microseconds())/1000, "p50-ms")
+		b.ReportMetric(float64(latencies[b.N*95/100].Microseconds())/1000, "p95-ms")
+		b.ReportMetric(float64(latencies[b.N*99/100].Microseconds())/1000, "p99-ms")
+	})
+}
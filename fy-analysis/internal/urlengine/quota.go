@@ -0,0 +1,285 @@
+package urlengine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+const (
+	// Límites por defecto de los análisis (AnalysisLimitsConfig con valores <= 0)
+	defaultCheapPerMinute     = 600
+	defaultExpensivePerMinute = 120
+	defaultMaxConcurrent      = 32
+	defaultMaxQueued          = 64
+	defaultQueueTimeout       = 2 * time.Second
+
+	// quotaWindow ventana de los presupuestos por llamante
+	quotaWindow = time.Minute
+	// quotaMaxCallers tope de llamantes en memoria (se purgan al llenarse)
+	quotaMaxCallers = 10000
+	// queueFullRetryAfter Retry-After cuando la cola está llena o la espera caduca
+	queueFullRetryAfter = time.Second
+)
+
+// AnalysisCost coste de un análisis para las cuotas por llamante
+type AnalysisCost string
+
+const (
+	CostCheap     AnalysisCost = "cheap"     // DB local, heurísticas o respuestas cacheadas
+	CostExpensive AnalysisCost = "expensive" // Llama al menos a una API externa
+)
+
+var (
+	analysisThrottledTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "analysis_throttled_total",
+		Help: "Análisis rechazados con 429 por presupuesto agotado (cheap, expensive) o por saturación (queue)",
+	}, []string{"reason"})
+
+	analysisInFlight = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "analysis_in_flight",
+		Help: "Análisis ejecutándose en este momento",
+	})
+
+	analysisQueued = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "analysis_queued",
+		Help: "Análisis esperando un hueco de ejecución",
+	})
+)
+
+// QuotaError análisis rechazado por cuota o saturación (el handler responde 429 + Retry-After)
+type QuotaError struct {
+	Reason     string // cheap, expensive o queue
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("analysis rejected (%s), retry after %s", e.Reason, e.RetryAfter)
+}
+
+func withAnalysisLimitDefaults(c checkers.AnalysisLimitsConfig) checkers.AnalysisLimitsConfig {
+	if c.CheapPerMinute <= 0 {
+		c.CheapPerMinute = defaultCheapPerMinute
+	}
+	if c.ExpensivePerMinute <= 0 {
+		c.ExpensivePerMinute = defaultExpensivePerMinute
+	}
+	if c.MaxConcurrent <= 0 {
+		c.MaxConcurrent = defaultMaxConcurrent
+	}
+	if c.MaxQueued <= 0 {
+		c.MaxQueued = defaultMaxQueued
+	}
+	if c.QueueTimeout <= 0 {
+		c.QueueTimeout = defaultQueueTimeout
+	}
+	return c
+}
+
+// callerBudget análisis por llamante en ventanas fijas de quotaWindow (en memoria, por
+// instancia: con varias réplicas el límite efectivo se multiplica)
+type callerBudget struct {
+	mu      sync.Mutex
+	windows map[string]budgetWindow
+	limit   int
+}
+
+type budgetWindow struct {
+	start time.Time
+	count int
+}
+
+func newCallerBudget(limit int) *callerBudget {
+	return &callerBudget{
+		windows: make(map[string]budgetWindow),
+		limit:   limit,
+	}
+}
+
+// allow consume una unidad del presupuesto de caller; si está agotado retorna cuánto falta
+// para que empiece la siguiente ventana
+func (b *callerBudget) allow(caller string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	window, ok := b.windows[caller]
+	if !ok || now.Sub(window.start) >= quotaWindow {
+		if !ok && len(b.windows) >= quotaMaxCallers {
+			b.purge(now)
+		}
+		window = budgetWindow{start: now}
+	}
+
+	if window.count >= b.limit {
+		return false, window.start.Add(quotaWindow).Sub(now)
+	}
+	window.count++
+	b.windows[caller] = window
+	return true, 0
+}
+
+// purge descarta las ventanas caducadas; si no basta, se vacía
+func (b *callerBudget) purge(now time.Time) {
+	for caller, window := range b.windows {
+		if now.Sub(window.start) >= quotaWindow {
+			delete(b.windows, caller)
+		}
+	}
+	if len(b.windows) >= quotaMaxCallers {
+		b.windows = make(map[string]budgetWindow)
+	}
+}
+
+// analysisLimiter presupuestos por llamante y huecos de ejecución del orquestador
+type analysisLimiter struct {
+	cheap     *callerBudget
+	expensive *callerBudget
+
+	slots        chan struct{} // Un elemento por análisis en ejecución
+	maxQueued    int64
+	queueTimeout time.Duration
+	inFlight     atomic.Int64
+	queued       atomic.Int64
+}
+
+func newAnalysisLimiter(config checkers.AnalysisLimitsConfig) *analysisLimiter {
+	config = withAnalysisLimitDefaults(config)
+
+	log.Info().
+		Int("cheap_per_minute", config.CheapPerMinute).
+		Int("expensive_per_minute", config.ExpensivePerMinute).
+		Int("max_concurrent", config.MaxConcurrent).
+		Int("max_queued", config.MaxQueued).
+		Dur("queue_timeout", config.QueueTimeout).
+		Msg("[Engine] Analysis quotas configured")
+
+	return &analysisLimiter{
+		cheap:        newCallerBudget(config.CheapPerMinute),
+		expensive:    newCallerBudget(config.ExpensivePerMinute),
+		slots:        make(chan struct{}, config.MaxConcurrent),
+		maxQueued:    int64(config.MaxQueued),
+		queueTimeout: config.QueueTimeout,
+	}
+}
+
+// admit consume el presupuesto de caller para cost y espera un hueco de ejecución
+// (como mucho queueTimeout). release libera el hueco al terminar el análisis.
+func (l *analysisLimiter) admit(ctx context.Context, caller string, cost AnalysisCost) (func(), error) {
+	budget := l.cheap
+	if cost == CostExpensive {
+		budget = l.expensive
+	}
+	if ok, retryAfter := budget.allow(caller, time.Now()); !ok {
+		return nil, l.reject(caller, string(cost), retryAfter)
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.started(), nil
+	default:
+	}
+
+	// Sin hueco libre: esperar en cola si no está llena
+	if l.queued.Add(1) > l.maxQueued {
+		l.queued.Add(-1)
+		return nil, l.reject(caller, "queue", queueFullRetryAfter)
+	}
+	analysisQueued.Inc()
+	defer func() {
+		l.queued.Add(-1)
+		analysisQueued.Dec()
+	}()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return l.started(), nil
+	case <-timer.C:
+		return nil, l.reject(caller, "queue", queueFullRetryAfter)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// started contabiliza un análisis en ejecución y retorna su release (idempotente)
+func (l *analysisLimiter) started() func() {
+	l.inFlight.Add(1)
+	analysisInFlight.Inc()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.slots
+			l.inFlight.Add(-1)
+			analysisInFlight.Dec()
+		})
+	}
+}
+
+func (l *analysisLimiter) reject(caller, reason string, retryAfter time.Duration) error {
+	analysisThrottledTotal.WithLabelValues(reason).Inc()
+	log.Warn().
+		Str("caller", caller).
+		Str("reason", reason).
+		Dur("retry_after", retryAfter).
+		Msg("[Engine] Analysis rejected")
+	return &QuotaError{Reason: reason, RetryAfter: retryAfter}
+}
+
+// stats carga actual para el endpoint de estado
+func (l *analysisLimiter) stats() map[string]interface{} {
+	return map[string]interface{}{
+		"in_flight":      l.inFlight.Load(),
+		"queued":         l.queued.Load(),
+		"max_concurrent": cap(l.slots),
+		"max_queued":     l.maxQueued,
+	}
+}
+
+// analysisCost expensive si algún checker activo para inputType llamaría a una API externa
+func (o *Orchestrator) analysisCost(inputType checkers.InputType, value string) AnalysisCost {
+	for _, c := range o.getCheckersForType(inputType) {
+		if external, ok := c.(checkers.ExternalAPIChecker); ok && external.NeedsExternalCall(inputType, value) {
+			return CostExpensive
+		}
+	}
+	return CostCheap
+}
+
+// AnalysisCost coste de analizar req (detectando el tipo si falta). Se calcula sobre el input
+// sin normalizar: normalizar una URL sigue sus redirects, que ya es trabajo de red.
+func (e *Engine) AnalysisCost(req *AnalysisRequest) AnalysisCost {
+	inputType, value := req.Type, req.Input
+	if inputType == "" {
+		detection := e.normalizer.DetectType(req.Input)
+		inputType, value = detection.Type, detection.Value
+	}
+	return e.currentOrchestrator().analysisCost(inputType, value)
+}
+
+// MessageCost coste de AnalyzeMessage: expensive si lo es alguno de los indicadores del texto
+func (e *Engine) MessageCost(text string) AnalysisCost {
+	orchestrator := e.currentOrchestrator()
+	for _, indicator := range e.normalizer.ExtractIndicators(text) {
+		if orchestrator.analysisCost(indicator.Type, indicator.Value) == CostExpensive {
+			return CostExpensive
+		}
+	}
+	return CostCheap
+}
+
+// Admit reserva un análisis de caller: consume su presupuesto de cost y espera un hueco de
+// ejecución. Retorna *QuotaError si se rechaza; si no, hay que llamar a release al terminar.
+func (e *Engine) Admit(ctx context.Context, caller string, cost AnalysisCost) (release func(), err error) {
+	return e.limiter.admit(ctx, caller, cost)
+}
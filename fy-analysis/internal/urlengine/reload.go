@@ -0,0 +1,212 @@
+package urlengine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/rs/zerolog/log"
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+var configReloadTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "config_reload_total",
+	Help: "Recargas de configuración del engine por resultado (applied, unchanged, error)",
+}, []string{"result"})
+
+// ReloadConfig aplica en caliente los campos recargables de newConfig: CheckerWeights,
+// CheckTimeout (0 = el actual), DisabledCheckers, ConsensusThreshold, CheckerPriorityMode
+// (vacío = el actual), BreakerThreshold y BreakerCooldown (0 = el actual). El resto de campos
+// se ignora. En cada recarga aplicada los circuitos se reevalúan con el umbral vigente.
+//
+// Pesos, umbrales, modo y timeout no reinician ningún checker. Activar o desactivar checkers reconstruye el
+// Orchestrator con los checkers ya construidos al arrancar; uno que no se construyó al arrancar
+// (DISABLED_CHECKERS, sin API key...) no se puede activar sin reiniciar.
+func (e *Engine) ReloadConfig(newConfig *EngineConfig) error {
+	for name, weight := range newConfig.CheckerWeights {
+		if weight < 0 || weight > 1 {
+			configReloadTotal.WithLabelValues("error").Inc()
+			return fmt.Errorf("invalid weight %.2f for checker %s", weight, name)
+		}
+	}
+	if newConfig.ConsensusThreshold < 0 || newConfig.BreakerThreshold < 0 {
+		configReloadTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("consensus and breaker thresholds must not be negative")
+	}
+	if newConfig.CheckerPriorityMode != "" && !ValidPriorityMode(newConfig.CheckerPriorityMode) {
+		configReloadTotal.WithLabelValues("error").Inc()
+		return fmt.Errorf("invalid checker priority mode %q", newConfig.CheckerPriorityMode)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var changes []string
+
+	weights := mergeWeights(analysisSourceWeights, newConfig.CheckerWeights)
+	if !equalWeights(weights, e.weights) {
+		e.weights = weights
+		e.orchestrator.aggregator.SetWeights(newConfig.CheckerWeights)
+		e.config.CheckerWeights = newConfig.CheckerWeights
+		changes = append(changes, "checker_weights")
+	}
+
+	timeout := newConfig.CheckTimeout
+	if timeout <= 0 {
+		timeout = e.config.CheckTimeout
+	}
+
+	active, unavailable := e.activeCheckers(newConfig)
+	for _, name := range unavailable {
+		log.Warn().Str("checker", name).Msg("[Engine] Checker was not built at startup, enabling it requires a restart")
+	}
+
+	checkersChanged := !sameCheckers(active, e.orchestrator.checkers)
+	timeoutChanged := timeout != e.orchestrator.timeout
+	if checkersChanged || timeoutChanged {
+		previous := checkerNames(e.orchestrator.checkers)
+		e.orchestrator = e.orchestrator.rebuild(active, timeout)
+		e.config.CheckTimeout = timeout
+		e.config.DisabledCheckers = newConfig.DisabledCheckers
+
+		if checkersChanged {
+			changes = append(changes, "checkers")
+			log.Info().
+				Strs("previous", previous).
+				Strs("current", checkerNames(active)).
+				Msg("[Engine] Orchestrator rebuilt with new checker set")
+		}
+		if timeoutChanged {
+			changes = append(changes, "check_timeout")
+		}
+	}
+
+	if newConfig.ConsensusThreshold != e.config.ConsensusThreshold {
+		e.config.ConsensusThreshold = newConfig.ConsensusThreshold
+		changes = append(changes, "consensus_threshold")
+	}
+
+	// El orchestrator vigente (quizá recién reconstruido) recibe modo y circuito nuevos
+	orchestrator := e.orchestrator
+	mode := newConfig.CheckerPriorityMode
+	if mode == "" {
+		mode = orchestrator.priorityMode
+	}
+	cooldown := newConfig.BreakerCooldown
+	if cooldown <= 0 {
+		cooldown = orchestrator.breakerCooldown
+	}
+	if mode != orchestrator.priorityMode {
+		changes = append(changes, "checker_priority_mode")
+	}
+	if newConfig.BreakerThreshold != orchestrator.breakerThreshold || cooldown != orchestrator.breakerCooldown {
+		changes = append(changes, "circuit_breaker")
+	}
+	orchestrator.failuresMu.Lock()
+	orchestrator.priorityMode = mode
+	orchestrator.breakerThreshold = newConfig.BreakerThreshold
+	orchestrator.breakerCooldown = cooldown
+	orchestrator.failuresMu.Unlock()
+	e.config.CheckerPriorityMode = mode
+	e.config.BreakerThreshold = newConfig.BreakerThreshold
+	e.config.BreakerCooldown = cooldown
+
+	if len(changes) == 0 {
+		configReloadTotal.WithLabelValues("unchanged").Inc()
+		log.Debug().Msg("[Engine] Config reload without changes")
+		return nil
+	}
+
+	opened, closed := orchestrator.reevaluateBreakers(time.Now())
+
+	configReloadTotal.WithLabelValues("applied").Inc()
+	log.Info().
+		Strs("changes", changes).
+		Dur("check_timeout", timeout).
+		Int("checkers", len(active)).
+		Interface("checker_weights", newConfig.CheckerWeights).
+		Int("consensus_threshold", e.config.ConsensusThreshold).
+		Str("checker_priority_mode", mode).
+		Int("breaker_threshold", newConfig.BreakerThreshold).
+		Strs("circuits_opened", opened).
+		Strs("circuits_closed", closed).
+		Msg("[Engine] Configuration reloaded")
+	return nil
+}
+
+// currentOrchestrator orchestrator vigente (ReloadConfig puede sustituirlo)
+func (e *Engine) currentOrchestrator() *Orchestrator {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.orchestrator
+}
+
+// activeCheckers checkers construidos al arrancar que newConfig no desactiva, y los nombres
+// de los que newConfig ya no desactiva pero no están construidos
+func (e *Engine) activeCheckers(newConfig *EngineConfig) ([]checkers.ThreatChecker, []string) {
+	var active []checkers.ThreatChecker
+	built := make(map[string]bool, len(e.allCheckers))
+	for _, c := range e.allCheckers {
+		built[c.Name()] = true
+		if !newConfig.CheckerDisabled(c.Name()) {
+			active = append(active, c)
+		}
+	}
+
+	var unavailable []string
+	for _, name := range e.config.DisabledCheckers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !built[name] && !newConfig.CheckerDisabled(name) {
+			unavailable = append(unavailable, name)
+		}
+	}
+	return active, unavailable
+}
+
+// mergeWeights copia de defaults con overrides aplicados
+func mergeWeights(defaults, overrides map[string]float64) map[string]float64 {
+	merged := make(map[string]float64, len(defaults)+len(overrides))
+	for name, weight := range defaults {
+		merged[name] = weight
+	}
+	for name, weight := range overrides {
+		merged[strings.ToLower(name)] = weight
+	}
+	return merged
+}
+
+func equalWeights(a, b map[string]float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for name, weight := range a {
+		if other, ok := b[name]; !ok || other != weight {
+			return false
+		}
+	}
+	return true
+}
+
+func sameCheckers(a, b []checkers.ThreatChecker) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func checkerNames(list []checkers.ThreatChecker) []string {
+	names := make([]string, 0, len(list))
+	for _, c := range list {
+		names = append(names, c.Name())
+	}
+	sort.Strings(names)
+	return names
+}
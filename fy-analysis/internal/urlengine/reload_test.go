@@ -0,0 +1,200 @@
+package urlengine
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+// stubChecker checker de URLs con respuesta fija; delay > 0 espera ese tiempo (o la cancelación)
+type stubChecker struct {
+	name   string
+	result checkers.CheckResult
+	err    error
+	delay  time.Duration
+	calls  atomic.Int32
+	done   chan struct{} // Se cierra al volver de la primera llamada (si no es nil)
+}
+
+func (s *stubChecker) Check(ctx context.Context, indicators *checkers.Indicators) (*checkers.CheckResult, error) {
+	s.calls.Add(1)
+	if s.done != nil {
+		defer close(s.done)
+	}
+	if s.delay > 0 {
+		select {
+		case <-time.After(s.delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if s.err != nil {
+		return nil, s.err
+	}
+	result := s.result
+	return &result, nil
+}
+
+func (s *stubChecker) Name() string    { return s.name }
+func (s *stubChecker) Weight() float64 { return 0.1 }
+func (s *stubChecker) IsEnabled() bool { return true }
+func (s *stubChecker) SupportedTypes() []checkers.InputType {
+	return []checkers.InputType{checkers.InputTypeURL}
+}
+func (s *stubChecker) Health(ctx context.Context) error { return nil }
+func (s *stubChecker) Close() error                     { return nil }
+
+var urlIndicators = &checkers.Indicators{InputType: checkers.InputTypeURL, FullURL: "http://bbva-clientes.xyz/login"}
+
+func resultBySource(results []*checkers.CheckResult, source string) *checkers.CheckResult {
+	for _, r := range results {
+		if r.Source == source {
+			return r
+		}
+	}
+	return nil
+}
+
+func TestConsensusThreshold(t *testing.T) {
+	e := NewOfflineEngine()
+	results := []*checkers.CheckResult{
+		{Source: "localdb", Found: true, Confidence: 1, ThreatType: checkers.ThreatTypePhishing},
+		{Source: "urlhaus"},
+	}
+
+	// localdb (0.30) confirma y urlhaus (0.15) no: 30/0.45 = 66 puntos, danger con una fuente
+	score, level, _, breakdown := e.aggregateAnalysisResults(results, nil, e.buildSourceResults(results, false))
+	if level != RiskLevelDanger || breakdown.Fixed != "" {
+		t.Fatalf("threshold 1: score %d level %s fixed %q, want danger", score, level, breakdown.Fixed)
+	}
+
+	if err := e.ReloadConfig(&EngineConfig{ConsensusThreshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	score, level, reasons, breakdown := e.aggregateAnalysisResults(results, nil, e.buildSourceResults(results, false))
+	if score != noConsensusRisk || level != RiskLevelWarning || breakdown.Fixed != scoreFixedNoConsensus {
+		t.Fatalf("threshold 2 with one source: score %d level %s fixed %q, want %d warning no_consensus", score, level, breakdown.Fixed, noConsensusRisk)
+	}
+	if len(reasons) == 0 || reasons[len(reasons)-1] != "Solo 1 de 2 fuentes necesarias confirman la amenaza" {
+		t.Errorf("reasons = %v", reasons)
+	}
+
+	results[1] = &checkers.CheckResult{Source: "urlhaus", Found: true, Confidence: 1, ThreatType: checkers.ThreatTypeMalware}
+	_, level, _, breakdown = e.aggregateAnalysisResults(results, nil, e.buildSourceResults(results, false))
+	if level != RiskLevelDanger || breakdown.Fixed != "" {
+		t.Fatalf("threshold 2 with two sources: level %s fixed %q, want danger", level, breakdown.Fixed)
+	}
+}
+
+func TestPriorityModeSkipsPendingCheckers(t *testing.T) {
+	fast := &stubChecker{name: "localdb", result: checkers.CheckResult{Found: true, Confidence: 0.95, ThreatType: checkers.ThreatTypePhishing}}
+	slow := &stubChecker{name: "urlscan", delay: 2 * time.Second, done: make(chan struct{})}
+	o := NewOrchestrator([]checkers.ThreatChecker{fast, slow}, 3*time.Second)
+	o.configure(PriorityModePriority, 2, time.Minute)
+
+	start := time.Now()
+	results := o.CheckWithType(context.Background(), urlIndicators)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("priority mode waited %v for the slow checker", elapsed)
+	}
+	if r := resultBySource(results, "urlscan"); r == nil || !errors.Is(r.Error, errCheckerSkipped) {
+		t.Fatalf("slow checker result = %+v, want errCheckerSkipped", r)
+	}
+
+	// La cancelación del checker saltado no cuenta como fallo suyo
+	<-slow.done
+	time.Sleep(20 * time.Millisecond)
+	if n := o.consecutiveFailures("urlscan"); n != 0 {
+		t.Errorf("skipped checker has %d failures, want 0", n)
+	}
+}
+
+func TestPriorityModeAllWaits(t *testing.T) {
+	fast := &stubChecker{name: "localdb", result: checkers.CheckResult{Found: true, Confidence: 0.95}}
+	slow := &stubChecker{name: "urlscan", delay: 50 * time.Millisecond}
+	o := NewOrchestrator([]checkers.ThreatChecker{fast, slow}, 3*time.Second)
+
+	results := o.CheckWithType(context.Background(), urlIndicators)
+	if r := resultBySource(results, "urlscan"); r == nil || r.Error != nil {
+		t.Fatalf("slow checker result = %+v, want a completed result", r)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	broken := &stubChecker{name: "webrisk", err: errors.New("503 from upstream")}
+	o := NewOrchestrator([]checkers.ThreatChecker{broken}, time.Second)
+	o.configure(PriorityModeAll, 2, time.Minute)
+
+	o.CheckWithType(context.Background(), urlIndicators)
+	o.CheckWithType(context.Background(), urlIndicators)
+	results := o.CheckWithType(context.Background(), urlIndicators)
+
+	if calls := broken.calls.Load(); calls != 2 {
+		t.Errorf("checker called %d times, want 2 (third call short-circuited)", calls)
+	}
+	if r := resultBySource(results, "webrisk"); r == nil || !errors.Is(r.Error, errCircuitOpen) {
+		t.Fatalf("result = %+v, want errCircuitOpen", r)
+	}
+
+	// Pasado el cooldown se vuelve a llamar; un éxito cierra el circuito
+	o.failures["webrisk"].openUntil = time.Now().Add(-time.Second)
+	broken.err = nil
+	o.CheckWithType(context.Background(), urlIndicators)
+	if calls := broken.calls.Load(); calls != 3 {
+		t.Errorf("checker called %d times after cooldown, want 3", calls)
+	}
+	if o.circuitOpen("webrisk", time.Now()) || o.consecutiveFailures("webrisk") != 0 {
+		t.Error("success did not close the circuit")
+	}
+}
+
+func TestReloadReevaluatesCircuitBreakers(t *testing.T) {
+	broken := &stubChecker{name: "webrisk", err: errors.New("timeout")}
+	e := NewOfflineEngine(broken)
+	e.orchestrator.configure(PriorityModeAll, 5, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		e.orchestrator.CheckWithType(context.Background(), urlIndicators)
+	}
+	if e.orchestrator.circuitOpen("webrisk", time.Now()) {
+		t.Fatal("circuit open below the threshold")
+	}
+
+	// Bajar el umbral abre en la recarga el circuito de un checker que ya lo supera
+	if err := e.ReloadConfig(&EngineConfig{BreakerThreshold: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if !e.orchestrator.circuitOpen("webrisk", time.Now()) {
+		t.Fatal("lowering breaker_threshold did not open the circuit")
+	}
+
+	// Desactivar el circuito lo cierra
+	if err := e.ReloadConfig(&EngineConfig{BreakerThreshold: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if e.orchestrator.circuitOpen("webrisk", time.Now()) {
+		t.Fatal("breaker_threshold 0 did not close the circuit")
+	}
+}
+
+func TestReloadPriorityMode(t *testing.T) {
+	e := NewOfflineEngine()
+	if err := e.ReloadConfig(&EngineConfig{CheckerPriorityMode: PriorityModePriority}); err != nil {
+		t.Fatal(err)
+	}
+	if e.orchestrator.priorityMode != PriorityModePriority {
+		t.Errorf("priority mode = %q after reload", e.orchestrator.priorityMode)
+	}
+
+	// Vacío mantiene el actual; un valor desconocido se rechaza
+	if err := e.ReloadConfig(&EngineConfig{}); err != nil || e.orchestrator.priorityMode != PriorityModePriority {
+		t.Errorf("empty mode changed priority mode to %q (err %v)", e.orchestrator.priorityMode, err)
+	}
+	if err := e.ReloadConfig(&EngineConfig{CheckerPriorityMode: "fastest"}); err == nil {
+		t.Error("unknown priority mode accepted")
+	}
+}
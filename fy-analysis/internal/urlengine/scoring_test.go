@@ -0,0 +1,139 @@
+package urlengine
+
+import (
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/trackfy/fy-analysis/internal/checkers"
+)
+
+func found(source string, confidence float64) *checkers.CheckResult {
+	return &checkers.CheckResult{Source: source, Found: true, Confidence: confidence, RawData: map[string]interface{}{"source": source}}
+}
+
+func clean(source string) *checkers.CheckResult {
+	return &checkers.CheckResult{Source: source, Confidence: 1, RawData: map[string]interface{}{"source": source}}
+}
+
+func failed(source string) *checkers.CheckResult {
+	return &checkers.CheckResult{Source: source, Error: errors.New("unavailable")}
+}
+
+// scoringCases resultados que llegan a scoreSources sin score fijo (salvo all_errors)
+var scoringCases = map[string][]*checkers.CheckResult{
+	"single source":         {found("urlhaus", 0.9), clean("webrisk"), clean("phishtank")},
+	"two sources boosted":   {found("urlhaus", 0.9), found("phishtank", 0.8), clean("webrisk")},
+	"three sources boosted": {found("urlhaus", 1), found("webrisk", 0.95), found("phishtank", 0.9), clean("urlscan")},
+	"clamped to 100":        {found("urlhaus", 1), found("webrisk", 1), found("phishtank", 1), found("urlscan", 1)},
+	"errors excluded":       {found("urlhaus", 0.7), failed("webrisk"), found("phishtank", 0.6)},
+	"nothing found":         {clean("urlhaus"), clean("webrisk")},
+	"all errors":            {failed("urlhaus"), failed("webrisk")},
+}
+
+// assertContributionsSum comprueba el contrato de ScoreBreakdown: los Points de las fuentes suman
+// PointsTotal y, con el boost, dan RiskScore (limitado a 100)
+func assertContributionsSum(t *testing.T, riskScore int, sources []SourceResult, breakdown *ScoreBreakdown) {
+	t.Helper()
+	if breakdown == nil {
+		t.Fatal("no score breakdown")
+	}
+	if breakdown.Fixed != "" {
+		if breakdown.Fixed != scoreFixedNoSources || riskScore != 50 {
+			t.Errorf("fixed score %q = %d", breakdown.Fixed, riskScore)
+		}
+		return
+	}
+
+	var points float64
+	for _, s := range sources {
+		if (s.Error != "" || !s.Found) && s.Points != 0 {
+			t.Errorf("%s contributes %.2f points without finding a threat", s.Name, s.Points)
+		}
+		points += s.Points
+	}
+	if math.Abs(points-breakdown.PointsTotal) > 1e-9 {
+		t.Errorf("source points sum to %.4f, breakdown says %.4f", points, breakdown.PointsTotal)
+	}
+
+	base := int(breakdown.PointsTotal)
+	if breakdown.RawScore != base+breakdown.BoostPoints {
+		t.Errorf("raw score %d != %d points + %d boost", breakdown.RawScore, base, breakdown.BoostPoints)
+	}
+	if want := int(float64(base) * breakdown.BoostFactor); breakdown.RawScore != want {
+		t.Errorf("raw score %d != int(%d * %.2f) = %d", breakdown.RawScore, base, breakdown.BoostFactor, want)
+	}
+	if breakdown.BoostApplied != (breakdown.BoostFactor > 1) || (!breakdown.BoostApplied && breakdown.BoostPoints != 0) {
+		t.Errorf("inconsistent boost: %+v", breakdown)
+	}
+	if want := min(breakdown.RawScore, 100); riskScore != want {
+		t.Errorf("risk score %d, contributions give %d", riskScore, want)
+	}
+}
+
+func TestScoreSourcesContributionsSumToRiskScore(t *testing.T) {
+	for name, results := range scoringCases {
+		t.Run(name, func(t *testing.T) {
+			sources := make([]SourceResult, len(results))
+			for i, r := range results {
+				sources[i] = SourceResult{Name: r.Source, Found: r.Found, Weight: analysisSourceWeights[r.Source]}
+				if r.Error != nil {
+					sources[i].Error = r.Error.Error()
+				}
+			}
+			score, breakdown := scoreSources(sources, results)
+			assertContributionsSum(t, score, sources, breakdown)
+		})
+	}
+
+	// El boost es del 10% por cada fuente adicional que confirma
+	results := scoringCases["three sources boosted"]
+	sources := []SourceResult{{Weight: 0.4}, {Weight: 0.3}, {Weight: 0.2}, {Weight: 0.1}}
+	_, breakdown := scoreSources(sources, results)
+	if !breakdown.BoostApplied || math.Abs(breakdown.BoostFactor-1.2) > 1e-9 {
+		t.Errorf("boost = %v x%.2f, want x1.2 for three sources", breakdown.BoostApplied, breakdown.BoostFactor)
+	}
+}
+
+func TestAnalyzeContributionsSumToRiskScore(t *testing.T) {
+	engine := NewOfflineEngine()
+
+	for name, results := range scoringCases {
+		t.Run(name, func(t *testing.T) {
+			sources := engine.buildSourceResults(results, false)
+			score, _, _, breakdown := engine.aggregateAnalysisResults(results, nil, sources)
+			assertContributionsSum(t, score, sources, breakdown)
+		})
+	}
+}
+
+func TestAggregatorContributionsSumToRiskScore(t *testing.T) {
+	aggregator := NewAggregator()
+	normalized := &NormalizeResult{OriginalURL: "http://bbva-clientes.xyz/login", NormalizedURL: "http://bbva-clientes.xyz/login"}
+
+	for name, results := range scoringCases {
+		t.Run(name, func(t *testing.T) {
+			response := aggregator.Aggregate(normalized, urlIndicators, results, time.Now(), false)
+			assertContributionsSum(t, response.RiskScore, response.Sources, response.Scoring)
+		})
+	}
+}
+
+func TestDebugIncludesRawData(t *testing.T) {
+	results := scoringCases["two sources boosted"]
+	normalized := &NormalizeResult{OriginalURL: "http://x.example", NormalizedURL: "http://x.example"}
+	engine := NewOfflineEngine()
+
+	for _, debug := range []bool{false, true} {
+		aggregated := NewAggregator().Aggregate(normalized, urlIndicators, results, time.Now(), debug).Sources
+		analyzed := engine.buildSourceResults(results, debug)
+		for _, sources := range [][]SourceResult{aggregated, analyzed} {
+			for _, s := range sources {
+				if (s.RawData != nil) != debug {
+					t.Errorf("debug=%v: %s raw_data = %v", debug, s.Name, s.RawData)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package urlengine
+
+import (
+	"net"
+	"regexp"
+	"strings"
+)
+
+// TypeDetection resultado de la detección automática del tipo de input
+type TypeDetection struct {
+	Type       InputType `json:"type"`
+	Confidence float64   `json:"confidence"`
+	Reason     string    `json:"reason,omitempty"`
+	// Value parte del input que se analiza ("+34 612 345 678 me ha llamado" -> "+34 612 345 678")
+	Value string `json:"-"`
+	// Ambiguous el input admitía otra interpretación y se eligió la más peligrosa
+	Ambiguous bool `json:"ambiguous,omitempty"`
+}
+
+var (
+	// hostRegex dominio con TLD alfabético (sin esquema), con puerto/path opcional
+	hostRegex = regexp.MustCompile(`(?i)^([a-z0-9](?:[a-z0-9-]{0,61}[a-z0-9])?\.)+([a-z]{2,63}|xn--[a-z0-9-]+)(:\d{1,5})?([/?#].*)?$`)
+	// phoneCandidateRegex secuencia de dígitos con separadores habituales de teléfono
+	phoneCandidateRegex = regexp.MustCompile(`\+?\(?\d[\d\s\-().]{6,}\d`)
+	// tokenTrim puntuación que rodea un token dentro de un texto
+	tokenTrim = "<>\"'()[]{},;:!?¡¿"
+)
+
+// commonTLDs TLDs frecuentes: un local part que acaba en uno de ellos ("paypal.com@")
+// se interpreta como userinfo de una URL y no como nombre de buzón
+var commonTLDs = map[string]bool{
+	"com": true, "net": true, "org": true, "info": true, "biz": true, "io": true,
+	"es": true, "eu": true, "co": true, "uk": true, "de": true, "fr": true, "it": true,
+	"pt": true, "us": true, "mx": true, "ar": true, "cl": true, "br": true, "ru": true,
+	"cn": true, "me": true, "app": true, "dev": true, "online": true, "site": true,
+	"xyz": true, "top": true, "tk": true, "ml": true, "ga": true, "cf": true, "gq": true,
+	"click": true, "link": true, "shop": true, "store": true, "live": true, "icu": true,
+	"cat": true, "gal": true, "eus": true,
+}
+
+// DetectType detecta el tipo de un input sin tipo declarado usando reglas estructurales:
+// esquema o host con path (URL), @ con dominio válido (email), hexadecimal de 32/40/64
+// caracteres (hash), densidad de dígitos (teléfono).
+// Ante inputs ambiguos ("paypal.com@evil.com") elige la interpretación más peligrosa (URL).
+func (n *Normalizer) DetectType(input string) TypeDetection {
+	input = strings.TrimSpace(input)
+	tokens := strings.Fields(input)
+	single := len(tokens) == 1
+
+	// confidence baja la confianza si el valor se extrajo de un texto más largo
+	confidence := func(base float64) float64 {
+		if single {
+			return base
+		}
+		return base - 0.15
+	}
+
+	// 1. URL con esquema (también defanged hxxp://) o www.
+	for _, raw := range tokens {
+		token := strings.Trim(raw, tokenTrim)
+		lower := strings.ToLower(token)
+		if strings.Contains(lower, "://") || strings.HasPrefix(lower, "www.") {
+			return TypeDetection{Type: InputTypeURL, Confidence: confidence(0.95), Value: token, Reason: "Contiene un esquema de URL"}
+		}
+	}
+
+	// 2. Tokens con @: email o URL con userinfo
+	for _, raw := range tokens {
+		token := strings.Trim(raw, tokenTrim)
+		at := strings.LastIndex(token, "@")
+		if at <= 0 || at == len(token)-1 {
+			continue
+		}
+		local, host := token[:at], token[at+1:]
+
+		if !hostRegex.MatchString(host) && net.ParseIP(host) == nil {
+			continue
+		}
+
+		// "paypal.com@evil.com": el navegador abre evil.com mostrando paypal.com
+		if looksLikeHost(local) {
+			return TypeDetection{
+				Type:       InputTypeURL,
+				Confidence: confidence(0.60),
+				Value:      token,
+				Ambiguous:  true,
+				Reason:     "Podría ser un email, pero se analiza como enlace: lo que hay antes de la @ imita un dominio y el enlace llevaría a " + hostOnly(host),
+			}
+		}
+		// "user@evil.com/login" lleva path: solo puede ser una URL
+		if strings.ContainsAny(host, "/?#") || net.ParseIP(host) != nil {
+			return TypeDetection{
+				Type:       InputTypeURL,
+				Confidence: confidence(0.70),
+				Value:      token,
+				Ambiguous:  true,
+				Reason:     "Podría ser un email, pero incluye una ruta o IP tras la @ y se analiza como enlace",
+			}
+		}
+
+		return TypeDetection{Type: InputTypeEmail, Confidence: confidence(0.95), Value: token, Reason: "Contiene @ seguida de un dominio válido"}
+	}
+
+	// 3. IP directa
+	for _, raw := range tokens {
+		token := strings.Trim(raw, tokenTrim)
+		if net.ParseIP(hostOnly(token)) != nil {
+			return TypeDetection{Type: InputTypeURL, Confidence: confidence(0.85), Value: token, Reason: "Es una dirección IP"}
+		}
+	}
+
+	// 4. Hash de un fichero (MD5/SHA1/SHA256): antes que el teléfono, un hash puede tener
+	// rachas largas de dígitos
+	for _, raw := range tokens {
+		token := strings.ToLower(strings.Trim(raw, tokenTrim))
+		if hashType := fileHashType(token); hashType != "" {
+			return TypeDetection{Type: InputTypeHash, Confidence: confidence(0.95), Value: token, Reason: "Es un hash " + strings.ToUpper(hashType) + " de un fichero"}
+		}
+	}
+
+	// 5. Teléfono: secuencia de 9-15 dígitos (con +, espacios, guiones o paréntesis). Los
+	// dígitos de un token con forma de host no cuentan: son parte de la URL
+	// (bbva-clientes.xyz/login?id=123456789)
+	var phoneTokens []string
+	for _, raw := range tokens {
+		if !hostRegex.MatchString(strings.TrimRight(strings.Trim(raw, tokenTrim), ".")) {
+			phoneTokens = append(phoneTokens, raw)
+		}
+	}
+	if candidate := phoneCandidateRegex.FindString(strings.Join(phoneTokens, " ")); candidate != "" {
+		digits := countDigits(candidate)
+		if digits >= 9 && digits <= 15 && !hostRegex.MatchString(strings.TrimSpace(candidate)) {
+			candidate = strings.TrimSpace(candidate)
+			// Densidad: proporción del input (sin espacios) que ocupan los dígitos del teléfono
+			density := float64(digits) / float64(len(strings.Join(tokens, "")))
+			base := 0.70
+			switch {
+			case density > 0.6:
+				base = 0.95
+			case density > 0.3:
+				base = 0.85
+			}
+			if strings.HasPrefix(candidate, "+") || strings.HasPrefix(candidate, "00") {
+				base += 0.05
+			}
+			if base > 0.95 {
+				base = 0.95
+			}
+			return TypeDetection{Type: InputTypePhone, Confidence: base, Value: candidate, Reason: "Contiene una secuencia de dígitos con formato de teléfono"}
+		}
+	}
+
+	// 6. Host sin esquema (bbva-clientes.xyz/login)
+	for _, raw := range tokens {
+		token := strings.TrimRight(strings.Trim(raw, tokenTrim), ".")
+		if hostRegex.MatchString(token) {
+			base := 0.75
+			if strings.ContainsAny(token, "/?") {
+				base = 0.90
+			}
+			return TypeDetection{Type: InputTypeURL, Confidence: confidence(base), Value: token, Reason: "Contiene un dominio"}
+		}
+	}
+
+	// 7. Sin estructura reconocible: URL por defecto, con confianza baja
+	return TypeDetection{Type: InputTypeURL, Confidence: 0.30, Value: input, Reason: "No se reconoce el formato; se analiza como enlace"}
+}
+
+// looksLikeHost indica si s parece un dominio con un TLD habitual (paypal.com, www.bbva.es)
+func looksLikeHost(s string) bool {
+	s = strings.ToLower(s)
+	if net.ParseIP(s) != nil {
+		return true
+	}
+	if !hostRegex.MatchString(s) {
+		return false
+	}
+	return commonTLDs[s[strings.LastIndex(s, ".")+1:]]
+}
+
+// hostOnly quita puerto y path de un host
+func hostOnly(s string) string {
+	if idx := strings.IndexAny(s, "/?#"); idx >= 0 {
+		s = s[:idx]
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	// IPv6 sin puerto ([::1])
+	if strings.HasPrefix(s, "[") && strings.HasSuffix(s, "]") {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+// fileHashType md5, sha1 o sha256 según la longitud si s es hexadecimal en minúsculas de
+// 32, 40 o 64 caracteres; "" en otro caso
+func fileHashType(s string) string {
+	var hashType string
+	switch len(s) {
+	case 32:
+		hashType = "md5"
+	case 40:
+		hashType = "sha1"
+	case 64:
+		hashType = "sha256"
+	default:
+		return ""
+	}
+	for _, r := range s {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'f') {
+			return ""
+		}
+	}
+	return hashType
+}
+
+// countDigits cuenta los dígitos de s
+func countDigits(s string) int {
+	count := 0
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			count++
+		}
+	}
+	return count
+}
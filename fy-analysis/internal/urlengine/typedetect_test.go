@@ -0,0 +1,82 @@
+package urlengine
+
+import "testing"
+
+func TestDetectType(t *testing.T) {
+	n := NewNormalizer()
+
+	tests := []struct {
+		input     string
+		wantType  InputType
+		wantValue string
+		ambiguous bool
+	}{
+		// URLs con esquema o www.
+		{"https://bbva.es", InputTypeURL, "https://bbva.es", false},
+		{"hxxp://evil.top/login", InputTypeURL, "hxxp://evil.top/login", false},
+		{"www.paypal.com", InputTypeURL, "www.paypal.com", false},
+		{"http://192.168.1.1/admin", InputTypeURL, "http://192.168.1.1/admin", false},
+		{"http://[2001:db8::1]/login", InputTypeURL, "http://[2001:db8::1]/login", false},
+		{"Paquete retenido, paga en https://correos-pagos.top", InputTypeURL, "https://correos-pagos.top", false},
+
+		// Hosts sin esquema: los dígitos del path o la query no son un teléfono
+		{"bbva-clientes.xyz/login?id=123456789", InputTypeURL, "bbva-clientes.xyz/login?id=123456789", false},
+		{"correos-pagos.top/track/2024-1234-5678", InputTypeURL, "correos-pagos.top/track/2024-1234-5678", false},
+		{"Tu paquete: correos-pagos.top/track/2024-1234-5678", InputTypeURL, "correos-pagos.top/track/2024-1234-5678", false},
+		{"mi.dominio.io?x=123456789012", InputTypeURL, "mi.dominio.io?x=123456789012", false},
+		{"bit.ly/3xYz123456", InputTypeURL, "bit.ly/3xYz123456", false},
+		{"bbva-clientes.xyz", InputTypeURL, "bbva-clientes.xyz", false},
+		{"bbva-clientes.xyz/login", InputTypeURL, "bbva-clientes.xyz/login", false},
+		{"correos.es.", InputTypeURL, "correos.es", false},
+		{"xn--pypal-4ve.com/login", InputTypeURL, "xn--pypal-4ve.com/login", false},
+		{"example.com:8080/path", InputTypeURL, "example.com:8080/path", false},
+		{"Entra en bbva-clientes.xyz/login para verificar", InputTypeURL, "bbva-clientes.xyz/login", false},
+
+		// Emails y URLs con userinfo
+		{"user@gmail.com", InputTypeEmail, "user@gmail.com", false},
+		{"User.Name+tag@Outlook.es", InputTypeEmail, "User.Name+tag@Outlook.es", false},
+		{"escribe a soporte@bbva-seguro.com ya", InputTypeEmail, "soporte@bbva-seguro.com", false},
+		{"paypal.com@evil.com", InputTypeURL, "paypal.com@evil.com", true},
+		{"user@evil.com/login", InputTypeURL, "user@evil.com/login", true},
+		{"soporte@192.168.0.1", InputTypeURL, "soporte@192.168.0.1", true},
+
+		// IPs
+		{"192.168.1.1", InputTypeURL, "192.168.1.1", false},
+		{"8.8.8.8:53", InputTypeURL, "8.8.8.8:53", false},
+		{"[2001:db8::1]", InputTypeURL, "2001:db8::1", false},
+
+		// Hashes
+		{"d41d8cd98f00b204e9800998ecf8427e", InputTypeHash, "d41d8cd98f00b204e9800998ecf8427e", false},
+		{"da39a3ee5e6b4b0d3255bfef95601890afd80709", InputTypeHash, "da39a3ee5e6b4b0d3255bfef95601890afd80709", false},
+		{"E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855", InputTypeHash, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", false},
+
+		// Teléfonos
+		{"+34 612 345 678", InputTypePhone, "+34 612 345 678", false},
+		{"612345678", InputTypePhone, "612345678", false},
+		{"612 34 56 78", InputTypePhone, "612 34 56 78", false},
+		{"0034612345678", InputTypePhone, "0034612345678", false},
+		{"+44 20 7946 0958", InputTypePhone, "+44 20 7946 0958", false},
+		{"Me ha llamado el +34 612 345 678 diciendo que es del banco", InputTypePhone, "+34 612 345 678", false},
+		{"Llama al 612345678 urgente", InputTypePhone, "612345678", false},
+		{"Llama al 612345678 o entra en bbva-clientes.xyz", InputTypePhone, "612345678", false},
+
+		// Sin estructura reconocible
+		{"12345", InputTypeURL, "12345", false},
+		{"1234-5678", InputTypeURL, "1234-5678", false},
+		{"hola que tal", InputTypeURL, "hola que tal", false},
+		{"", InputTypeURL, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := n.DetectType(tt.input)
+			if got.Type != tt.wantType || got.Value != tt.wantValue || got.Ambiguous != tt.ambiguous {
+				t.Errorf("DetectType(%q) = {%s %q ambiguous=%v}, want {%s %q ambiguous=%v}",
+					tt.input, got.Type, got.Value, got.Ambiguous, tt.wantType, tt.wantValue, tt.ambiguous)
+			}
+			if got.Confidence <= 0 || got.Confidence > 0.95 {
+				t.Errorf("DetectType(%q) confidence = %.2f, want (0, 0.95]", tt.input, got.Confidence)
+			}
+		})
+	}
+}
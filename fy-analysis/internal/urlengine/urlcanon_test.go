@@ -0,0 +1,151 @@
+package urlengine
+
+import (
+	"context"
+	"testing"
+)
+
+// Forma canónica de las URLs pasando por el Normalizer (sin red): la URL de la que sale el
+// hash y el path que se busca en threat_paths. Las variantes de una misma página (dot
+// segments, barras duplicadas, percent-encoding, tracking, orden de la query) deben
+// producir el mismo hash.
+//
+//	go test ./internal/urlengine -run Canonical -v
+
+// canonCase URL de entrada con su path canónico y su URL canónica esperados
+type canonCase struct {
+	name  string
+	input string
+	path  string // Indicators.CanonicalPath
+	url   string // URL canónica (se compara por hash con Indicators.Hash)
+}
+
+var canonCases = []canonCase{
+	// Dot segments
+	{"dot segment", "http://evil.com/login/../paypal/verify", "/paypal/verify", "http://evil.com/paypal/verify"},
+	{"single dot", "http://evil.com/./paypal/./verify", "/paypal/verify", "http://evil.com/paypal/verify"},
+	{"double dot above root", "http://evil.com/../../paypal", "/paypal", "http://evil.com/paypal"},
+	{"trailing double dot", "http://evil.com/a/b/..", "/a/", "http://evil.com/a/"},
+	{"trailing single dot", "http://evil.com/a/.", "/a/", "http://evil.com/a/"},
+	{"encoded dot segment", "http://evil.com/login/%2E%2E/paypal", "/paypal", "http://evil.com/paypal"},
+	{"dot inside segment", "http://evil.com/a/..b/c.", "/a/..b/c.", "http://evil.com/a/..b/c."},
+
+	// Barras
+	{"duplicate slashes", "http://evil.com//paypal///verify", "/paypal/verify", "http://evil.com/paypal/verify"},
+	{"trailing slash kept", "http://evil.com/paypal/", "/paypal/", "http://evil.com/paypal/"},
+	{"empty path", "http://evil.com", "/", "http://evil.com/"},
+	{"only slashes", "http://evil.com///", "/", "http://evil.com/"},
+
+	// Percent-encoding
+	{"uppercase hex", "http://evil.com/a%2Fb", "/a/b", "http://evil.com/a%2fb"},
+	{"unreserved decoded", "http://evil.com/%7Euser/%41%62c", "/~user/Abc", "http://evil.com/~user/Abc"},
+	{"reserved kept", "http://evil.com/a%3Fb", "/a?b", "http://evil.com/a%3fb"},
+	{"space kept encoded", "http://evil.com/a%20b", "/a b", "http://evil.com/a%20b"},
+	{"utf8 lowercased", "http://evil.com/pag%C3%B3", "/pagó", "http://evil.com/pag%c3%b3"},
+	{"path case kept", "http://evil.com/PayPal/Verify", "/PayPal/Verify", "http://evil.com/PayPal/Verify"},
+
+	// Query
+	{"utm stripped", "http://evil.com/p?utm_source=sms&utm_medium=x", "/p", "http://evil.com/p"},
+	{"fbclid stripped", "http://evil.com/p?id=1&fbclid=abc", "/p", "http://evil.com/p?id=1"},
+	{"gclid uppercase", "http://evil.com/p?GCLID=abc&id=1", "/p", "http://evil.com/p?id=1"},
+	{"query sorted", "http://evil.com/p?b=2&a=1", "/p", "http://evil.com/p?a=1&b=2"},
+	{"repeated key sorted by value", "http://evil.com/p?a=2&a=1", "/p", "http://evil.com/p?a=1&a=2"},
+	{"empty params dropped", "http://evil.com/p?&a=1&&", "/p", "http://evil.com/p?a=1"},
+	{"query hex lowercased", "http://evil.com/p?q=a%2Bb&r=%7E", "/p", "http://evil.com/p?q=a%2bb&r=~"},
+	{"param without value", "http://evil.com/p?debug&a=1", "/p", "http://evil.com/p?a=1&debug"},
+	{"utm prefix only", "http://evil.com/p?utmost=1", "/p", "http://evil.com/p?utmost=1"},
+
+	// Scheme, host, puerto y fragmento (ya los normalizaba Normalize)
+	{"host lowercased", "HTTP://Evil.COM/p", "/p", "http://evil.com/p"},
+	{"default port removed", "https://evil.com:443/p", "/p", "https://evil.com/p"},
+	{"other port kept", "http://evil.com:8080/p", "/p", "http://evil.com:8080/p"},
+	{"fragment removed", "http://evil.com/p#section", "/p", "http://evil.com/p"},
+	{"no scheme", "evil.com/a//b/../c", "/a/c", "http://evil.com/a/c"},
+
+	// IPv6: corchetes en la URL, forma canónica de la dirección
+	{"ipv6 host", "http://[2001:db8::1]/login", "/login", "http://[2001:db8::1]/login"},
+	{"ipv6 default port removed", "https://[2001:db8::1]:443/login", "/login", "https://[2001:db8::1]/login"},
+	{"ipv6 other port kept", "http://[2001:db8::1]:8080/login", "/login", "http://[2001:db8::1]:8080/login"},
+	{"ipv6 canonical form", "http://[2001:DB8:0:0:0:0:0:1]/login", "/login", "http://[2001:db8::1]/login"},
+	{"ipv6 no scheme", "[2001:db8::1]/login", "/login", "http://[2001:db8::1]/login"},
+}
+
+// sameHashGroups variantes que deben producir el mismo hash que la primera
+var sameHashGroups = [][]string{
+	{
+		"http://evil.com/paypal/verify",
+		"http://evil.com/login/../paypal/verify",
+		"http://evil.com//paypal//verify",
+		"http://evil.com/paypal/./verify#top",
+		"http://evil.com/%70aypal/verify",
+		"http://evil.com/paypal/verify?utm_source=sms&fbclid=x",
+	},
+	{
+		"http://evil.com/p?a=1&b=%2F",
+		"http://evil.com/p?b=%2f&a=1",
+		"http://evil.com/p?b=%2F&utm_campaign=c&a=1",
+	},
+}
+
+// differentHashPairs URLs que no son la misma página aunque se parezcan
+var differentHashPairs = [][2]string{
+	{"http://evil.com/paypal", "http://evil.com/paypal/"},
+	{"http://evil.com/p?a=1", "http://evil.com/p?a=2"},
+	{"http://evil.com/PayPal", "http://evil.com/paypal"},
+	{"http://evil.com/a%2Fb", "http://evil.com/a/b"},
+}
+
+func canonicalIndicators(t *testing.T, normalizer *Normalizer, rawURL string) (string, string) {
+	t.Helper()
+	indicators, err := normalizer.NormalizeURLToIndicators(context.Background(), rawURL)
+	if err != nil {
+		t.Fatalf("%s: %v", rawURL, err)
+	}
+	return indicators.Hash, indicators.CanonicalPath
+}
+
+func offlineNormalizer() *Normalizer {
+	normalizer := NewNormalizer()
+	normalizer.SetOffline(true)
+	return normalizer
+}
+
+func TestCanonicalURL(t *testing.T) {
+	normalizer := offlineNormalizer()
+	for _, c := range canonCases {
+		t.Run(c.name, func(t *testing.T) {
+			gotHash, gotPath := canonicalIndicators(t, normalizer, c.input)
+			// La URL esperada ya es canónica: su hash es el de sí misma
+			wantHash, _ := canonicalIndicators(t, normalizer, c.url)
+			if gotPath != c.path {
+				t.Errorf("%s: canonical path %q, want %q", c.input, gotPath, c.path)
+			}
+			if gotHash != wantHash {
+				t.Errorf("%s: hash differs from %s", c.input, c.url)
+			}
+		})
+	}
+}
+
+func TestCanonicalSameHash(t *testing.T) {
+	normalizer := offlineNormalizer()
+	for _, group := range sameHashGroups {
+		want, _ := canonicalIndicators(t, normalizer, group[0])
+		for _, variant := range group[1:] {
+			if got, _ := canonicalIndicators(t, normalizer, variant); got != want {
+				t.Errorf("%s: hash differs from %s", variant, group[0])
+			}
+		}
+	}
+}
+
+func TestCanonicalDifferentHash(t *testing.T) {
+	normalizer := offlineNormalizer()
+	for _, pair := range differentHashPairs {
+		a, _ := canonicalIndicators(t, normalizer, pair[0])
+		b, _ := canonicalIndicators(t, normalizer, pair[1])
+		if a == b {
+			t.Errorf("%s and %s: same hash", pair[0], pair[1])
+		}
+	}
+}
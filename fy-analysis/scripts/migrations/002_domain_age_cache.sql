@@ -0,0 +1,33 @@
+-- ============================================
+-- MIGRACIÓN: Cache de antigüedad de dominios (RDAP)
+-- Usada por las heurísticas para detectar dominios recién registrados
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS domain_age_cache (
+    -- Dominio registrable (ej: banco-seguro.com, sin subdominios)
+    domain TEXT PRIMARY KEY,
+
+    -- Fecha del evento "registration" de RDAP (NULL = desconocida)
+    registered_at TIMESTAMPTZ,
+    registrar TEXT,
+
+    -- Cuándo se consultó RDAP (la cache expira a los 7 días)
+    cached_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_domain_age_cached ON domain_age_cache(cached_at);
+
+COMMENT ON TABLE domain_age_cache IS 'Cache de consultas RDAP: fecha de registro de dominios';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Cache de antigüedad de dominios (RDAP)';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - domain_age_cache: fecha de registro por dominio';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,64 @@
+-- ============================================
+-- MIGRACIÓN: Audit log de operaciones de escritura de fy-admin
+-- Append-only: los triggers impiden UPDATE, DELETE y TRUNCATE
+-- ============================================
+
+CREATE EXTENSION IF NOT EXISTS pgcrypto;
+
+CREATE TABLE IF NOT EXISTS audit_log (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Acción realizada (add_phone, add_email, force_sync, import_csv...)
+    action TEXT NOT NULL,
+
+    -- Tabla afectada y clave del registro (phone_national, email, dominio, fuente)
+    table_name TEXT NOT NULL DEFAULT '',
+    record_id TEXT NOT NULL DEFAULT '',
+
+    -- Estado del registro antes y después de la operación (NULL = no existía / no aplica)
+    old_value JSONB,
+    new_value JSONB,
+
+    admin_ip TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Paginación por cursor (created_at, id) descendente
+CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_log_table ON audit_log(table_name, created_at DESC);
+
+COMMENT ON TABLE audit_log IS 'Registro inmutable de operaciones de escritura del panel de administración';
+
+-- ============================================
+-- APPEND-ONLY
+-- ============================================
+CREATE OR REPLACE FUNCTION audit_log_immutable()
+RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_log es append-only: % no permitido', TG_OP;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_audit_log_immutable ON audit_log;
+CREATE TRIGGER trg_audit_log_immutable
+    BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION audit_log_immutable();
+
+DROP TRIGGER IF EXISTS trg_audit_log_no_truncate ON audit_log;
+CREATE TRIGGER trg_audit_log_no_truncate
+    BEFORE TRUNCATE ON audit_log
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_log_immutable();
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Audit log de fy-admin';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - audit_log: operaciones de escritura (append-only)';
+    RAISE NOTICE '===========================================';
+END $$;
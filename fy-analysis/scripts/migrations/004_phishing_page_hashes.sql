@@ -0,0 +1,36 @@
+-- ============================================
+-- MIGRACIÓN: Hashes perceptuales de páginas de phishing
+-- Usada por el checker de similitud visual (ENABLE_VISUAL_CHECKER=true)
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS phishing_page_hashes (
+    -- Hash de la URL de la página de phishing
+    url_hash BYTEA PRIMARY KEY,
+
+    -- pHash de 64 bits de la captura (uint64 almacenado como BIGINT)
+    phash BIGINT NOT NULL,
+
+    source_url VARCHAR(2048) NOT NULL,
+    source source_enum NOT NULL DEFAULT 'phishtank',
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- La búsqueda por distancia de Hamming (bit_count(phash # $1)) recorre la tabla;
+-- el tamaño está acotado por lo que se indexa en cada sync
+CREATE INDEX IF NOT EXISTS idx_page_hashes_created ON phishing_page_hashes(created_at DESC);
+
+COMMENT ON TABLE phishing_page_hashes IS 'pHash de capturas de páginas de phishing conocidas (similitud visual)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Hashes perceptuales de páginas de phishing';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - phishing_page_hashes: pHash por URL de phishing';
+    RAISE NOTICE '===========================================';
+END $$;
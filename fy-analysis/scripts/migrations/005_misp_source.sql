@@ -0,0 +1,25 @@
+-- ============================================
+-- MIGRACIÓN: Fuente MISP (importación desde fy-dbsync)
+-- Los indicadores guardan "event_id:attribute_uuid" en source_id
+-- ============================================
+
+-- Nueva fuente (ADD VALUE no puede ir dentro de una transacción en PG < 12)
+ALTER TYPE source_enum ADD VALUE IF NOT EXISTS 'misp';
+
+-- threat_domains ya tiene source_id; los emails no
+ALTER TABLE threat_emails ADD COLUMN IF NOT EXISTS source_id VARCHAR(64);
+
+COMMENT ON COLUMN threat_emails.source_id IS 'ID en la fuente original (MISP: event_id:attribute_uuid)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Fuente MISP';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Enum actualizado: source_enum (+misp)';
+    RAISE NOTICE 'Columna añadida: threat_emails.source_id';
+    RAISE NOTICE '===========================================';
+END $$;
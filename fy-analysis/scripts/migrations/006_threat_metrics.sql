@@ -0,0 +1,97 @@
+-- ============================================
+-- MIGRACIÓN: Vista materializada de estadísticas
+-- Precalcula lo que leen GET /api/v1/stats (fy-analysis) y /api/stats/database (fy-admin)
+-- sin recorrer las tablas de amenazas en cada petición.
+-- fy-analysis la refresca cada 5 minutos (REFRESH MATERIALIZED VIEW CONCURRENTLY).
+-- ============================================
+
+DROP MATERIALIZED VIEW IF EXISTS threat_metrics;
+
+CREATE MATERIALIZED VIEW threat_metrics AS
+-- Totales por tabla
+SELECT
+    'table'::text as scope,
+    'domains'::text as key,
+    COUNT(*) as total,
+    COUNT(*) FILTER (WHERE (flags & 1) = 1) as active,
+    COUNT(*) FILTER (WHERE threat_type = 'phishing') as phishing,
+    COUNT(*) FILTER (WHERE threat_type = 'malware') as malware,
+    COUNT(*) FILTER (WHERE threat_type = 'scam') as scam,
+    pg_total_relation_size('threat_domains') as size_bytes,
+    NOW() as refreshed_at
+FROM threat_domains
+UNION ALL
+SELECT
+    'table', 'paths',
+    COUNT(*),
+    COUNT(*) FILTER (WHERE (flags & 1) = 1),
+    COUNT(*) FILTER (WHERE threat_type = 'phishing'),
+    COUNT(*) FILTER (WHERE threat_type = 'malware'),
+    COUNT(*) FILTER (WHERE threat_type = 'scam'),
+    pg_total_relation_size('threat_paths'),
+    NOW()
+FROM threat_paths
+UNION ALL
+SELECT
+    'table', 'emails',
+    COUNT(*),
+    COUNT(*) FILTER (WHERE (flags & 1) = 1),
+    COUNT(*) FILTER (WHERE threat_type = 'phishing'),
+    0,
+    COUNT(*) FILTER (WHERE threat_type = 'scam'),
+    pg_total_relation_size('threat_emails'),
+    NOW()
+FROM threat_emails
+UNION ALL
+SELECT
+    'table', 'phones',
+    COUNT(*),
+    COUNT(*) FILTER (WHERE (flags & 1) = 1),
+    0,
+    0,
+    COUNT(*) FILTER (WHERE threat_type = 'scam'),
+    pg_total_relation_size('threat_phones'),
+    NOW()
+FROM threat_phones
+UNION ALL
+SELECT
+    'table', 'whitelist',
+    COUNT(*), COUNT(*), 0, 0, 0,
+    pg_total_relation_size('whitelist_domains'),
+    NOW()
+FROM whitelist_domains
+UNION ALL
+-- Dominios por fuente
+SELECT 'domain_source', COALESCE(source::text, 'unknown'), COUNT(*), COUNT(*) FILTER (WHERE (flags & 1) = 1), 0, 0, 0, 0, NOW()
+FROM threat_domains
+GROUP BY source
+UNION ALL
+-- Emails por fuente
+SELECT 'email_source', COALESCE(source::text, 'unknown'), COUNT(*), COUNT(*) FILTER (WHERE (flags & 1) = 1), 0, 0, 0, 0, NOW()
+FROM threat_emails
+GROUP BY source
+UNION ALL
+-- Dominios por tipo de amenaza
+SELECT 'domain_threat_type', COALESCE(threat_type::text, 'unknown'), COUNT(*), COUNT(*) FILTER (WHERE (flags & 1) = 1), 0, 0, 0, 0, NOW()
+FROM threat_domains
+GROUP BY threat_type;
+
+-- REFRESH ... CONCURRENTLY requiere un índice único
+CREATE UNIQUE INDEX IF NOT EXISTS idx_threat_metrics_scope_key ON threat_metrics(scope, key);
+
+COMMENT ON MATERIALIZED VIEW threat_metrics IS 'Estadísticas precalculadas de las tablas de amenazas (refresco cada 5 min desde fy-analysis)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Vista materializada de estadísticas';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Vista creada: threat_metrics (scope, key)';
+    RAISE NOTICE '  - table: totales por tabla';
+    RAISE NOTICE '  - domain_source / email_source: por fuente';
+    RAISE NOTICE '  - domain_threat_type: dominios por tipo';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,97 @@
+-- ============================================
+-- MIGRACIÓN: Normalización de emails para búsqueda
+-- Gmail ignora los puntos y lo que va tras "+", Yahoo lo que va tras "-":
+-- u.s.e.r+promo@gmail.com y user@gmail.com son el mismo buzón.
+-- email_normalized guarda esa forma canónica para que las variantes no evadan la lista negra.
+-- Las reglas las sincroniza fy-analysis desde EMAIL_RULES_FILE al arrancar.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS email_normalization_rules (
+    domain VARCHAR(253) PRIMARY KEY,
+    remove_dots BOOLEAN NOT NULL DEFAULT false,
+    strip_after VARCHAR(1),          -- Separador de sub-direcciones ('+', '-'); NULL = ninguno
+    canonical_domain VARCHAR(253),   -- googlemail.com -> gmail.com
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+-- Reglas por defecto (las mismas que DefaultEmailDomainRules en fy-analysis)
+INSERT INTO email_normalization_rules (domain, remove_dots, strip_after, canonical_domain) VALUES
+    ('gmail.com', true, '+', NULL),
+    ('googlemail.com', true, '+', 'gmail.com'),
+    ('yahoo.com', false, '-', NULL),
+    ('yahoo.es', false, '-', NULL),
+    ('ymail.com', false, '-', NULL)
+ON CONFLICT (domain) DO NOTHING;
+
+-- Forma canónica de un email según email_normalization_rules (misma lógica que
+-- EmailNormalizer.NormalizeForLookup)
+CREATE OR REPLACE FUNCTION normalize_email_for_lookup(p_email TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    v_email TEXT := LOWER(TRIM(p_email));
+    v_local TEXT;
+    v_domain TEXT;
+    v_rule email_normalization_rules%ROWTYPE;
+BEGIN
+    v_local := substring(v_email from '^(.+)@[^@]+$');
+    v_domain := substring(v_email from '@([^@]+)$');
+    IF v_local IS NULL OR v_domain IS NULL THEN
+        RETURN v_email;
+    END IF;
+
+    SELECT * INTO v_rule FROM email_normalization_rules WHERE domain = v_domain;
+    IF NOT FOUND THEN
+        RETURN v_email;
+    END IF;
+
+    IF v_rule.strip_after IS NOT NULL AND v_rule.strip_after <> '' THEN
+        v_local := split_part(v_local, v_rule.strip_after, 1);
+    END IF;
+    IF v_rule.remove_dots THEN
+        v_local := replace(v_local, '.', '');
+    END IF;
+    IF v_local = '' THEN
+        RETURN v_email;
+    END IF;
+
+    RETURN v_local || '@' || COALESCE(v_rule.canonical_domain, v_domain);
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+ALTER TABLE threat_emails ADD COLUMN IF NOT EXISTS email_normalized VARCHAR(254);
+ALTER TABLE threat_emails ADD COLUMN IF NOT EXISTS email_normalized_hash BYTEA;
+
+COMMENT ON COLUMN threat_emails.email_normalized IS 'Forma canónica para búsqueda (sin sub-dirección ni puntos según el proveedor)';
+
+-- Los importadores (fy-dbsync, fy-admin) solo escriben email: el trigger calcula la forma normalizada
+CREATE OR REPLACE FUNCTION threat_emails_normalize() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.email_normalized := normalize_email_for_lookup(NEW.email);
+    NEW.email_normalized_hash := sha256_bytea(NEW.email_normalized);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_threat_emails_normalize ON threat_emails;
+CREATE TRIGGER trg_threat_emails_normalize
+    BEFORE INSERT OR UPDATE OF email, email_normalized ON threat_emails
+    FOR EACH ROW EXECUTE FUNCTION threat_emails_normalize();
+
+-- Rellenar las filas existentes (el trigger calcula los valores)
+UPDATE threat_emails SET email_normalized = NULL WHERE email_normalized_hash IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_threat_emails_normalized_hash ON threat_emails(email_normalized_hash);
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Normalización de emails';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tabla creada: email_normalization_rules';
+    RAISE NOTICE 'Columnas añadidas: threat_emails.email_normalized, email_normalized_hash';
+    RAISE NOTICE 'Trigger: trg_threat_emails_normalize';
+    RAISE NOTICE '===========================================';
+END $$;
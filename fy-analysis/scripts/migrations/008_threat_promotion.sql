@@ -0,0 +1,43 @@
+-- ============================================
+-- MIGRACIÓN: Promoción de URLs reportadas a threat_domains
+-- fy-analysis promueve cada hora las URLs con score >= 70 y >= 3 reportadores
+-- y avisa en la app a quienes las reportaron.
+-- ============================================
+
+-- Notificaciones in-app (las lee api-gateway en GET /api/v1/me/notifications)
+CREATE TABLE IF NOT EXISTS user_notifications (
+    id BIGSERIAL PRIMARY KEY,
+
+    -- Mismo identificador que user_url_reports.user_id
+    user_id VARCHAR(64) NOT NULL,
+
+    -- Tipo: report_confirmed
+    type VARCHAR(32) NOT NULL,
+    title VARCHAR(120) NOT NULL,
+    body VARCHAR(500) NOT NULL,
+    data JSONB,
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    read_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_notifications_user ON user_notifications(user_id, created_at DESC);
+
+COMMENT ON TABLE user_notifications IS 'Notificaciones in-app generadas por fy-analysis (p. ej. reporte confirmado)';
+
+-- Candidatas a promoción
+CREATE INDEX IF NOT EXISTS idx_reported_urls_promotable ON reported_urls(aggregated_score DESC)
+    WHERE promoted_to_threats = false AND status <> 'rejected';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Promoción de URLs reportadas';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tabla creada: user_notifications';
+    RAISE NOTICE 'Índice creado: idx_reported_urls_promotable';
+    RAISE NOTICE '===========================================';
+END $$;
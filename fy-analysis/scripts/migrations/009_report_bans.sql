@@ -0,0 +1,30 @@
+-- ============================================
+-- MIGRACIÓN: Baneos del sistema de reportes
+-- Un usuario está baneado si (flags & 1) = 1 y trust_score < 10. Los baneos
+-- manuales (fy-admin) y automáticos (fy-analysis) guardan motivo y origen,
+-- y el trust previo para restaurarlo al levantar el baneo.
+-- ============================================
+
+ALTER TABLE user_trust_scores ADD COLUMN IF NOT EXISTS ban_reason VARCHAR(200);
+ALTER TABLE user_trust_scores ADD COLUMN IF NOT EXISTS banned_at TIMESTAMP;
+-- Origen: admin, auto
+ALTER TABLE user_trust_scores ADD COLUMN IF NOT EXISTS banned_by VARCHAR(32);
+ALTER TABLE user_trust_scores ADD COLUMN IF NOT EXISTS trust_before_ban SMALLINT;
+
+CREATE INDEX IF NOT EXISTS idx_user_trust_scores_banned ON user_trust_scores(user_id) WHERE (flags & 1) = 1;
+
+-- Reportes rechazados recientes por usuario (baneo automático)
+CREATE INDEX IF NOT EXISTS idx_user_url_reports_user_status ON user_url_reports(user_id, status, created_at DESC);
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Baneos del sistema de reportes';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Columnas añadidas a user_trust_scores: ban_reason, banned_at, banned_by, trust_before_ban';
+    RAISE NOTICE 'Índices creados: idx_user_trust_scores_banned, idx_user_url_reports_user_status';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,29 @@
+-- ============================================
+-- MIGRACIÓN: Secreto TOTP de fy-admin
+-- Segundo factor de las acciones de alto riesgo del panel (sync, importaciones,
+-- baneos). Solo se usa si ADMIN_TOTP_SECRET no está configurado; el secreto se
+-- genera en /api/admin/totp/setup y no protege nada hasta que se confirma.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS admin_totp (
+    -- Una sola fila: el panel tiene un único secreto
+    id SMALLINT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    secret TEXT NOT NULL,
+    confirmed BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at TIMESTAMPTZ
+);
+
+COMMENT ON TABLE admin_totp IS 'Secreto TOTP (base32) de las acciones de alto riesgo de fy-admin';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Secreto TOTP de fy-admin';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tabla creada: admin_totp';
+    RAISE NOTICE '===========================================';
+END $$;
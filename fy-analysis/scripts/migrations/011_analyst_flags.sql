@@ -0,0 +1,49 @@
+-- ============================================
+-- MIGRACIÓN: Marcas de analista en flags
+-- Bits comunes a todas las tablas de amenazas (mismos valores que
+-- fy-analysis/internal/flags y fy-admin/flags.go):
+--   bit 5 (32): false_positive_candidate - los checkers ignoran el registro
+--   bit 6 (64): analyst_verified         - confirmado por un analista
+-- Se ponen y quitan desde fy-admin (POST /api/admin/flags) sin desactivar el registro.
+-- ============================================
+
+-- find_threat_domain ya no devuelve dominios marcados como posible falso positivo
+CREATE OR REPLACE FUNCTION find_threat_domain(p_domain TEXT)
+RETURNS TABLE (
+    domain_hash BYTEA,
+    domain VARCHAR,
+    threat_type threat_type_enum,
+    severity severity_enum,
+    confidence SMALLINT,
+    impersonates VARCHAR
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        td.domain_hash,
+        td.domain,
+        td.threat_type,
+        td.severity,
+        td.confidence,
+        wd.domain as impersonates
+    FROM threat_domains td
+    LEFT JOIN whitelist_domains wd ON td.impersonates_hash = wd.domain_hash
+    WHERE td.domain = LOWER(p_domain)
+      AND (td.flags & 1) = 1   -- active
+      AND (td.flags & 32) = 0  -- false_positive_candidate
+      AND (td.expires_at IS NULL OR td.expires_at > NOW());
+END;
+$$ LANGUAGE plpgsql;
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Marcas de analista en flags';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Bits: 32 = false_positive_candidate, 64 = analyst_verified';
+    RAISE NOTICE 'Función actualizada: find_threat_domain (ignora false_positive_candidate)';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,51 @@
+-- ============================================
+-- MIGRACIÓN: Solicitudes de whitelist de usuarios
+-- Los usuarios proponen dominios legítimos desde la app (api-gateway ->
+-- fy-analysis POST /api/v1/whitelist/requests). Un admin las revisa en
+-- fy-admin: al aprobar, el dominio pasa a whitelist_domains; en ambos casos
+-- se avisa al usuario en user_notifications.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS whitelist_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Usuario de api-gateway (users.id)
+    user_id UUID NOT NULL,
+
+    domain TEXT NOT NULL,
+    brand TEXT,
+    reason TEXT NOT NULL,
+    evidence_url TEXT,
+
+    -- pending, approved, rejected
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected')),
+    reviewed_by TEXT,
+    reviewed_at TIMESTAMPTZ,
+    -- Nota del revisor (se incluye en la notificación al usuario)
+    review_note TEXT,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Cola de revisión de fy-admin
+CREATE INDEX IF NOT EXISTS idx_whitelist_requests_status ON whitelist_requests(status, created_at);
+
+-- Una sola solicitud pendiente por usuario y dominio
+CREATE UNIQUE INDEX IF NOT EXISTS idx_whitelist_requests_pending_user_domain
+    ON whitelist_requests(user_id, domain) WHERE status = 'pending';
+
+COMMENT ON TABLE whitelist_requests IS 'Dominios legítimos propuestos por usuarios, pendientes de revisión en fy-admin';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Solicitudes de whitelist de usuarios';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tabla creada: whitelist_requests';
+    RAISE NOTICE 'Índices creados: idx_whitelist_requests_status, idx_whitelist_requests_pending_user_domain';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,59 @@
+-- ============================================
+-- MIGRACIÓN: API de inteligencia de amenazas para terceros
+-- Consultas de solo lectura (GET /v1/ti/domains|emails|phones/...) servidas por
+-- fy-admin y autenticadas con una API key por partner. Las claves se crean y
+-- revocan desde el panel; cada petición queda en api_key_usage para facturar.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS api_keys (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    -- Partner al que pertenece la clave
+    name TEXT NOT NULL,
+
+    -- La clave solo se muestra al crearla: se guarda su SHA256 y el prefijo
+    -- para reconocerla en el panel
+    key_hash BYTEA NOT NULL UNIQUE,
+    key_prefix VARCHAR(16) NOT NULL,
+
+    rate_limit_per_minute INTEGER NOT NULL DEFAULT 60 CHECK (rate_limit_per_minute > 0),
+
+    -- Contadores de uso (el detalle está en api_key_usage)
+    request_count BIGINT NOT NULL DEFAULT 0,
+    last_used_at TIMESTAMPTZ,
+
+    created_by TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS api_key_usage (
+    id BIGSERIAL PRIMARY KEY,
+    key_id UUID NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+
+    -- domains, emails, phones (no se guarda el indicador consultado)
+    endpoint VARCHAR(16) NOT NULL,
+    status SMALLINT NOT NULL,
+    found BOOLEAN NOT NULL,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Facturación: uso de una clave por periodo
+CREATE INDEX IF NOT EXISTS idx_api_key_usage_key_created ON api_key_usage(key_id, created_at);
+
+COMMENT ON TABLE api_keys IS 'API keys de partners para la API de inteligencia de amenazas de fy-admin';
+COMMENT ON TABLE api_key_usage IS 'Peticiones a la API de inteligencia de amenazas (facturación)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'API de inteligencia de amenazas';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas: api_keys, api_key_usage';
+    RAISE NOTICE 'Índices creados: idx_api_key_usage_key_created';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,37 @@
+-- ============================================
+-- MIGRACIÓN: Reputación histórica de dominios
+-- LocalDBChecker acumula el resultado de cada comprobación de URL por dominio.
+-- score = (positive_count * 10 / (positive_count + negative_count)) * 10 (0-100):
+-- un dominio que hoy no aparece en ninguna fuente pero con score > 50 se
+-- responde como warning en lugar de safe.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS domain_reputation_scores (
+    -- sha256_bytea(dominio en minúsculas), mismo hash que threat_domains
+    domain_hash BYTEA PRIMARY KEY,
+
+    score INTEGER NOT NULL DEFAULT 0 CHECK (score BETWEEN 0 AND 100),
+
+    -- Última comprobación con amenaza
+    last_positive TIMESTAMPTZ,
+
+    -- Comprobaciones con y sin amenaza
+    positive_count INTEGER NOT NULL DEFAULT 0,
+    negative_count INTEGER NOT NULL DEFAULT 0,
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON TABLE domain_reputation_scores IS 'Historial de comprobaciones de URL por dominio (LocalDBChecker)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Reputación histórica de dominios';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas: domain_reputation_scores';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,35 @@
+-- ============================================
+-- MIGRACIÓN: Cache de operadores de teléfono (Numverify / HLR)
+-- Usada por las heurísticas de teléfono con ENABLE_CARRIER_LOOKUP=true
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS phone_carrier_cache (
+    -- Número con prefijo de país, sin '+' (el mismo número nacional existe en
+    -- varios países)
+    phone_national TEXT PRIMARY KEY,
+
+    -- Respuesta del servicio (NULL = no reconoció el número)
+    carrier TEXT,
+    line_type TEXT,   -- mobile, landline, voip, prepaid... según el servicio
+    country TEXT,     -- ISO 3166-1 alfa-2 del operador
+
+    -- Cuándo se consultó el servicio (la cache expira a los 30 días)
+    cached_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_phone_carrier_cached ON phone_carrier_cache(cached_at);
+
+COMMENT ON TABLE phone_carrier_cache IS 'Cache de lookups de operador (Numverify/HLR) por número';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Cache de operadores de teléfono';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - phone_carrier_cache: operador, tipo de línea y país por número';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,20 @@
+-- ============================================
+-- MIGRACIÓN: Fuentes PhishStats y Spamhaus DBL (feeds de fy-admin)
+-- PhishStats guarda el hash de la URL en source_id; la DBL solo trae dominios
+-- ============================================
+
+-- Nuevas fuentes (ADD VALUE no puede ir dentro de una transacción en PG < 12)
+ALTER TYPE source_enum ADD VALUE IF NOT EXISTS 'phishstats';
+ALTER TYPE source_enum ADD VALUE IF NOT EXISTS 'spamhaus';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Fuentes PhishStats y Spamhaus DBL';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Enum actualizado: source_enum (+phishstats, +spamhaus)';
+    RAISE NOTICE '===========================================';
+END $$;
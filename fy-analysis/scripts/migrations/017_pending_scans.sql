@@ -0,0 +1,41 @@
+-- ============================================
+-- MIGRACIÓN: Envíos pendientes a urlscan.io
+-- Usada por URLScanChecker con ENABLE_URLSCAN_SUBMIT=true: las URLs sin scans
+-- previos se envían a escanear y ResultPoller recoge el veredicto cada 2 minutos
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS pending_scans (
+    -- UUID del scan en urlscan.io
+    uuid TEXT PRIMARY KEY,
+
+    url TEXT NOT NULL,
+    url_hash BYTEA NOT NULL,   -- sha256_bytea(url), para buscar el envío en Check
+    domain TEXT,
+
+    submitted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    -- Veredicto (resolved sin malicious = scan limpio o perdido tras 24h)
+    resolved BOOLEAN NOT NULL DEFAULT false,
+    malicious BOOLEAN,
+    threat_type TEXT,
+    confidence REAL,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_pending_scans_url_hash ON pending_scans(url_hash, submitted_at DESC);
+CREATE INDEX IF NOT EXISTS idx_pending_scans_unresolved ON pending_scans(submitted_at) WHERE resolved = false;
+
+COMMENT ON TABLE pending_scans IS 'URLs enviadas a urlscan.io pendientes de veredicto (ResultPoller)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Envíos pendientes a urlscan.io';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - pending_scans: UUID del scan, URL y veredicto diferido';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,126 @@
+-- ============================================
+-- MIGRACIÓN: Calidad de las fuentes de amenazas
+-- Los dominios activos de una fuente que se desactivan o se borran (analista, expiración,
+-- degradación de reportes) cuentan como falsos positivos en sync_status.false_positive_count.
+-- fy-dbsync calcula con ese contador source_quality cada 30 minutos y LocalDB escala la
+-- confianza de cada amenaza por el quality_score de su fuente.
+-- ============================================
+
+ALTER TABLE sync_status ADD COLUMN IF NOT EXISTS false_positive_count INTEGER NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS source_quality (
+    source TEXT PRIMARY KEY,
+
+    -- 1 - fp_count / (fp_count + tp_count)
+    quality_score DECIMAL(4,3) NOT NULL DEFAULT 1.0,
+
+    fp_count INTEGER NOT NULL DEFAULT 0,   -- Dominios desactivados o borrados
+    tp_count INTEGER NOT NULL DEFAULT 0,   -- Dominios activos (threat_metrics)
+
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMENT ON COLUMN sync_status.false_positive_count IS 'Dominios activos de la fuente desactivados o borrados después de importarse';
+COMMENT ON TABLE source_quality IS 'Calidad de cada fuente según sus falsos positivos (fy-dbsync, cada 30 min)';
+
+-- ============================================
+-- CONTADOR DE FALSOS POSITIVOS
+-- Triggers por sentencia con tablas de transición: un DELETE masivo de
+-- cleanup_expired_threats o un import por lotes hace un solo UPDATE por fuente.
+-- Solo cuentan las filas que estaban activas (una fila ya desactivada se contó al desactivarla).
+-- ============================================
+
+CREATE OR REPLACE FUNCTION count_removed_threat_domains() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE sync_status s
+    SET false_positive_count = s.false_positive_count + r.removed
+    FROM (
+        SELECT source, COUNT(*) AS removed
+        FROM removed_rows
+        WHERE (flags & 1) = 1
+        GROUP BY source
+    ) r
+    WHERE s.source = r.source;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE FUNCTION count_deactivated_threat_domains() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE sync_status s
+    SET false_positive_count = s.false_positive_count + d.deactivated
+    FROM (
+        SELECT o.source, COUNT(*) AS deactivated
+        FROM old_rows o
+        JOIN new_rows n ON n.domain_hash = o.domain_hash
+        WHERE (o.flags & 1) = 1
+          AND (COALESCE(n.flags, 0) & 1) = 0
+        GROUP BY o.source
+    ) d
+    WHERE s.source = d.source;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_threat_domains_removed ON threat_domains;
+CREATE TRIGGER trg_threat_domains_removed
+    AFTER DELETE ON threat_domains
+    REFERENCING OLD TABLE AS removed_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION count_removed_threat_domains();
+
+DROP TRIGGER IF EXISTS trg_threat_domains_deactivated ON threat_domains;
+CREATE TRIGGER trg_threat_domains_deactivated
+    AFTER UPDATE ON threat_domains
+    REFERENCING OLD TABLE AS old_rows NEW TABLE AS new_rows
+    FOR EACH STATEMENT EXECUTE FUNCTION count_deactivated_threat_domains();
+
+-- ============================================
+-- find_threat_domain con la fuente (LocalDB aplica su quality_score).
+-- Cambia el tipo de retorno: hay que borrarla antes de recrearla.
+-- ============================================
+DROP FUNCTION IF EXISTS find_threat_domain(TEXT);
+
+CREATE FUNCTION find_threat_domain(p_domain TEXT)
+RETURNS TABLE (
+    domain_hash BYTEA,
+    domain VARCHAR,
+    threat_type threat_type_enum,
+    severity severity_enum,
+    confidence SMALLINT,
+    impersonates VARCHAR,
+    source source_enum
+) AS $$
+BEGIN
+    RETURN QUERY
+    SELECT
+        td.domain_hash,
+        td.domain,
+        td.threat_type,
+        td.severity,
+        td.confidence,
+        wd.domain as impersonates,
+        td.source
+    FROM threat_domains td
+    LEFT JOIN whitelist_domains wd ON td.impersonates_hash = wd.domain_hash
+    WHERE td.domain = LOWER(p_domain)
+      AND (td.flags & 1) = 1   -- active
+      AND (td.flags & 32) = 0  -- false_positive_candidate
+      AND (td.expires_at IS NULL OR td.expires_at > NOW());
+END;
+$$ LANGUAGE plpgsql;
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Calidad de las fuentes de amenazas';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Columna añadida: sync_status.false_positive_count';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - source_quality: quality_score, fp_count y tp_count por fuente';
+    RAISE NOTICE 'Triggers: trg_threat_domains_removed, trg_threat_domains_deactivated';
+    RAISE NOTICE 'Función actualizada: find_threat_domain (incluye source)';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,72 @@
+-- ============================================
+-- MIGRACIÓN: Escalado de severidad entre fuentes
+-- threat_domains guarda una fila por dominio (la de la primera fuente que lo importó).
+-- domain_sources conserva la severidad y la confianza con la que lo reporta cada fuente;
+-- el SeverityEscalator de fy-admin sube tras cada sincronización la fila canónica a la
+-- severidad más alta (high o critical) y a la confianza máxima entre fuentes, y deja el
+-- cambio en threat_domains.severity_history.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS domain_sources (
+    domain_hash BYTEA NOT NULL REFERENCES threat_domains(domain_hash) ON DELETE CASCADE,
+    source source_enum NOT NULL,
+
+    severity severity_enum NOT NULL,
+    confidence SMALLINT NOT NULL,
+
+    first_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (domain_hash, source)
+);
+
+-- El escalador solo revisa los dominios vistos en la última sincronización
+CREATE INDEX IF NOT EXISTS idx_domain_sources_last_seen ON domain_sources(last_seen);
+
+-- [{source, old_severity, new_severity, at}] en orden de escalado
+ALTER TABLE threat_domains ADD COLUMN IF NOT EXISTS severity_history JSONB NOT NULL DEFAULT '[]'::jsonb;
+
+COMMENT ON TABLE domain_sources IS 'Severidad y confianza de cada fuente que reporta un dominio de threat_domains';
+COMMENT ON COLUMN threat_domains.severity_history IS 'Escalados de severidad entre fuentes: [{source, old_severity, new_severity, at}]';
+
+-- Fuente con la que se importó cada dominio existente
+INSERT INTO domain_sources (domain_hash, source, severity, confidence, first_seen, last_seen)
+SELECT domain_hash, source, severity, confidence, first_seen, last_seen
+FROM threat_domains
+ON CONFLICT (domain_hash, source) DO NOTHING;
+
+-- ============================================
+-- FUENTE CANÓNICA
+-- Cualquier INSERT en threat_domains (fy-dbsync, altas manuales, promoción de reportes)
+-- registra su fuente. Las fuentes que llegan después a un dominio existente las añade
+-- quien sincroniza (fy-admin en upsertFeedDomains).
+-- ============================================
+
+CREATE OR REPLACE FUNCTION record_threat_domain_source() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO domain_sources (domain_hash, source, severity, confidence, first_seen, last_seen)
+    VALUES (NEW.domain_hash, NEW.source, NEW.severity, NEW.confidence, NEW.first_seen, NEW.last_seen)
+    ON CONFLICT (domain_hash, source) DO NOTHING;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_threat_domains_source ON threat_domains;
+CREATE TRIGGER trg_threat_domains_source
+    AFTER INSERT ON threat_domains
+    FOR EACH ROW EXECUTE FUNCTION record_threat_domain_source();
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Escalado de severidad entre fuentes';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Columna añadida: threat_domains.severity_history';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - domain_sources: severidad y confianza por dominio y fuente';
+    RAISE NOTICE 'Trigger: trg_threat_domains_source';
+    RAISE NOTICE '===========================================';
+END $$;
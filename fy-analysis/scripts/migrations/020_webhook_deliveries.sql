@@ -0,0 +1,45 @@
+-- ============================================
+-- MIGRACIÓN: Entregas de webhooks
+-- Usada por WebhookNotifier (WEBHOOKS_FILE): una fila por notificación y webhook con
+-- el resultado tras los reintentos. Se consulta desde fy-admin
+-- (GET /api/admin/webhooks/deliveries). No se guarda el body: el input puede
+-- contener datos personales.
+-- ============================================
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+
+    webhook VARCHAR(255) NOT NULL,   -- name de webhooks.yaml (por defecto el host)
+    url TEXT NOT NULL,
+    event VARCHAR(50) NOT NULL,
+    risk_score SMALLINT NOT NULL,
+
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts SMALLINT NOT NULL DEFAULT 0,
+    response_status SMALLINT,        -- Último código HTTP recibido
+    last_error TEXT,
+
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_created ON webhook_deliveries(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(webhook, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_failed ON webhook_deliveries(created_at DESC) WHERE status = 'failed';
+
+COMMENT ON TABLE webhook_deliveries IS 'Notificaciones enviadas a webhooks externos (WebhookNotifier)';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Entregas de webhooks';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - webhook_deliveries: resultado de cada notificación';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,20 @@
+-- ============================================
+-- MIGRACIÓN: Fuente de sync de teléfonos de StopForumSpam (fy-admin)
+-- Los teléfonos se guardan con source 'osint' como los emails; el valor nuevo solo
+-- separa su fila de sync_status de la de la lista de emails
+-- ============================================
+
+-- ADD VALUE no puede ir dentro de una transacción en PG < 12
+ALTER TYPE source_enum ADD VALUE IF NOT EXISTS 'sfs_phones';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Sync de teléfonos de StopForumSpam';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Enum actualizado: source_enum (+sfs_phones)';
+    RAISE NOTICE '===========================================';
+END $$;
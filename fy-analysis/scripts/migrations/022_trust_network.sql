@@ -0,0 +1,54 @@
+-- ============================================
+-- MIGRACIÓN: Red de confianza entre reportadores
+-- Relaciones entre cuentas (posible colusión o dispositivo compartido) y ajustes de
+-- trust_score que TrustNetworkAnalyzer propaga por ellas cada noche
+-- ============================================
+
+-- Grafo no dirigido: cada par se guarda una vez con user_id_a < user_id_b.
+-- Los user_id son los de user_trust_scores (VARCHAR, no UUID)
+CREATE TABLE IF NOT EXISTS user_relationships (
+    user_id_a VARCHAR(64) NOT NULL,
+    user_id_b VARCHAR(64) NOT NULL,
+    relationship_type TEXT NOT NULL,       -- co_report, shared_subnet
+    confidence DECIMAL(3,2) NOT NULL,      -- 0-1; solo >= 0.50 propaga confianza
+    evidence_count INTEGER NOT NULL DEFAULT 1, -- URLs reportadas juntas o subredes compartidas
+    first_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_seen_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id_a, user_id_b, relationship_type),
+    CHECK (user_id_a < user_id_b)
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_relationships_b ON user_relationships(user_id_b);
+
+COMMENT ON TABLE user_relationships IS 'Cuentas vinculadas: reportes de la misma URL en menos de 1h o reportes desde la misma subred';
+
+-- Ajustes aplicados: cada penalización se aplica una vez por par y el bootstrap una vez por usuario
+CREATE TABLE IF NOT EXISTS trust_network_adjustments (
+    id BIGSERIAL PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    linked_user_id VARCHAR(64) NOT NULL,   -- Cuenta que origina el ajuste
+    reason TEXT NOT NULL,                  -- guilt_by_association, bootstrap
+    adjustment SMALLINT NOT NULL,
+    trust_before SMALLINT NOT NULL,
+    trust_after SMALLINT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, linked_user_id, reason)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_trust_network_adjustments_bootstrap
+    ON trust_network_adjustments(user_id) WHERE reason = 'bootstrap';
+CREATE INDEX IF NOT EXISTS idx_trust_network_adjustments_created
+    ON trust_network_adjustments(created_at DESC);
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Red de confianza entre reportadores';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Tablas creadas: user_relationships, trust_network_adjustments';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,58 @@
+-- ============================================
+-- MIGRACIÓN: Re-evaluación de dominios (recheck)
+-- fy-admin (POST /api/actions/recheck y lote diario) vuelve a analizar dominios de
+-- threat_domains con fy-analysis y compara el veredicto de las fuentes independientes
+-- con la clasificación guardada. Los dominios que varias fuentes dan por limpios se
+-- marcan como posible falso positivo (flags bit 5), nunca se desactivan.
+-- ============================================
+
+-- Última re-evaluación: el lote diario empieza por los nunca revisados y los más antiguos
+ALTER TABLE threat_domains ADD COLUMN IF NOT EXISTS last_rechecked_at TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_threat_domains_recheck
+    ON threat_domains(last_rechecked_at NULLS FIRST, first_seen);
+
+CREATE TABLE IF NOT EXISTS recheck_log (
+    id BIGSERIAL PRIMARY KEY,
+    domain_hash BYTEA NOT NULL REFERENCES threat_domains(domain_hash) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+
+    outcome VARCHAR(20) NOT NULL
+        CHECK (outcome IN ('agree', 'downgrade_candidate', 'upgrade')),
+
+    -- Clasificación guardada en el momento de la re-evaluación
+    old_threat_type TEXT NOT NULL,
+    old_severity TEXT NOT NULL,
+    old_confidence SMALLINT NOT NULL,
+
+    -- Veredicto de fy-analysis (risk_score incluye LocalDB; sources_* solo las independientes)
+    new_risk_score SMALLINT NOT NULL,
+    new_risk_level VARCHAR(20) NOT NULL,
+    new_threat_type TEXT,
+    sources_found TEXT[] NOT NULL DEFAULT '{}',  -- Fuentes independientes que lo detectan
+    sources_clean TEXT[] NOT NULL DEFAULT '{}',  -- Fuentes independientes que respondieron sin detectarlo
+
+    flagged BOOLEAN NOT NULL DEFAULT false,      -- Se marcó como false_positive_candidate
+    triggered_by VARCHAR(10) NOT NULL DEFAULT 'manual'
+        CHECK (triggered_by IN ('manual', 'scheduled')),
+    checked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_recheck_log_domain ON recheck_log(domain_hash, checked_at DESC);
+CREATE INDEX IF NOT EXISTS idx_recheck_log_checked ON recheck_log(checked_at DESC);
+
+COMMENT ON TABLE recheck_log IS 'Re-evaluaciones de threat_domains: veredicto nuevo frente a la clasificación guardada';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Re-evaluación de dominios';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Columna añadida: threat_domains.last_rechecked_at';
+    RAISE NOTICE 'Tablas creadas:';
+    RAISE NOTICE '  - recheck_log: resultado de cada re-evaluación';
+    RAISE NOTICE '===========================================';
+END $$;
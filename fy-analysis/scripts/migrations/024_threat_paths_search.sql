@@ -0,0 +1,29 @@
+-- ============================================
+-- MIGRACIÓN: Búsqueda en threat_paths
+-- fy-admin (GET /api/data/paths y /api/data/domains?include_paths=true) busca paths con
+-- ILIKE '%...%': sin índice de trigramas es un seq scan de toda la tabla
+-- ============================================
+
+-- init-db.sql ya la crea; se repite por las BDs creadas antes de añadirla
+CREATE EXTENSION IF NOT EXISTS "pg_trgm";
+
+-- GIN con trigramas: sirve para ILIKE/LIKE con comodines y para similarity()
+CREATE INDEX IF NOT EXISTS idx_paths_path_trgm
+    ON threat_paths USING GIN (path gin_trgm_ops);
+
+-- Listado de paths activos ordenado por last_seen
+CREATE INDEX IF NOT EXISTS idx_paths_last_seen ON threat_paths(last_seen DESC) WHERE (flags & 1) = 1;
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Búsqueda en threat_paths';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Índices creados:';
+    RAISE NOTICE '  - idx_paths_path_trgm: GIN (path gin_trgm_ops)';
+    RAISE NOTICE '  - idx_paths_last_seen: paths activos por last_seen';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,62 @@
+-- ============================================
+-- MIGRACIÓN: Regla de normalización por defecto para emails
+-- La mayoría de proveedores entregan user+tag@dominio en el buzón de user@dominio:
+-- la regla '*' se aplica a los dominios sin regla propia en email_normalization_rules.
+-- Los emails ya guardados NO se recalculan aquí (la tabla puede tener millones de filas):
+-- se hace por lotes con la acción de fy-admin POST /api/actions/email-normalize.
+-- ============================================
+
+INSERT INTO email_normalization_rules (domain, remove_dots, strip_after, canonical_domain) VALUES
+    ('*', false, '+', NULL)
+ON CONFLICT (domain) DO NOTHING;
+
+-- Misma lógica que EmailNormalizer.NormalizeForLookup: regla del dominio o, si no tiene, la de '*'
+CREATE OR REPLACE FUNCTION normalize_email_for_lookup(p_email TEXT)
+RETURNS TEXT AS $$
+DECLARE
+    v_email TEXT := LOWER(TRIM(p_email));
+    v_local TEXT;
+    v_domain TEXT;
+    v_rule email_normalization_rules%ROWTYPE;
+BEGIN
+    v_local := substring(v_email from '^(.+)@[^@]+$');
+    v_domain := substring(v_email from '@([^@]+)$');
+    IF v_local IS NULL OR v_domain IS NULL THEN
+        RETURN v_email;
+    END IF;
+
+    SELECT * INTO v_rule FROM email_normalization_rules WHERE domain = v_domain;
+    IF NOT FOUND THEN
+        SELECT * INTO v_rule FROM email_normalization_rules WHERE domain = '*';
+        IF NOT FOUND THEN
+            RETURN v_email;
+        END IF;
+    END IF;
+
+    IF v_rule.strip_after IS NOT NULL AND v_rule.strip_after <> '' THEN
+        v_local := split_part(v_local, v_rule.strip_after, 1);
+    END IF;
+    IF v_rule.remove_dots THEN
+        v_local := replace(v_local, '.', '');
+    END IF;
+    IF v_local = '' THEN
+        RETURN v_email;
+    END IF;
+
+    RETURN v_local || '@' || COALESCE(v_rule.canonical_domain, v_domain);
+END;
+$$ LANGUAGE plpgsql STABLE;
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Regla de normalización de emails por defecto';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Regla añadida: * (strip_after +)';
+    RAISE NOTICE 'Función actualizada: normalize_email_for_lookup';
+    RAISE NOTICE 'Pendiente: POST /api/actions/email-normalize en fy-admin para recalcular los emails existentes';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,27 @@
+-- ============================================
+-- MIGRACIÓN: Registros obsoletos (stale_candidate)
+-- LocalDB reduce la confianza de cada coincidencia según la antigüedad de last_seen
+-- (exp(-λ * días), CONFIDENCE_DECAY_LAMBDA). Si queda por debajo de 0.1 pone en flags
+-- el bit 7 (128, mismo valor que fy-analysis/internal/flags y fy-admin/flags.go):
+--   bit 7 (128): stale_candidate - candidato a desactivarse por el reaper
+-- El bit no cambia la búsqueda: el registro sigue activo hasta que se desactive.
+-- ============================================
+
+-- El reaper recorre los candidatos por antigüedad
+CREATE INDEX IF NOT EXISTS idx_domains_stale ON threat_domains(last_seen) WHERE (flags & 128) = 128;
+CREATE INDEX IF NOT EXISTS idx_paths_stale ON threat_paths(last_seen) WHERE (flags & 128) = 128;
+CREATE INDEX IF NOT EXISTS idx_emails_stale ON threat_emails(last_seen) WHERE (flags & 128) = 128;
+CREATE INDEX IF NOT EXISTS idx_phones_stale ON threat_phones(last_seen) WHERE (flags & 128) = 128;
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Registros obsoletos (stale_candidate)';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Bit de flags: 7 (128) stale_candidate';
+    RAISE NOTICE 'Índices creados: idx_domains_stale, idx_paths_stale, idx_emails_stale, idx_phones_stale';
+    RAISE NOTICE '===========================================';
+END $$;
@@ -0,0 +1,21 @@
+-- ============================================
+-- MIGRACIÓN: Fuente de sync de teléfonos de Lista Hũ (fy-admin)
+-- Los teléfonos se siguen guardando con source 'osint'; el valor nuevo solo da a la
+-- sync su propia fila de sync_status (last_sync, last_count y el error final tras los
+-- reintentos), como 'sfs_phones' en la migración 021
+-- ============================================
+
+-- ADD VALUE no puede ir dentro de una transacción en PG < 12
+ALTER TYPE source_enum ADD VALUE IF NOT EXISTS 'listahu_phones';
+
+-- ============================================
+-- MENSAJE DE CONFIRMACIÓN
+-- ============================================
+DO $$
+BEGIN
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Sync de teléfonos de Lista Hũ';
+    RAISE NOTICE '===========================================';
+    RAISE NOTICE 'Enum actualizado: source_enum (+listahu_phones)';
+    RAISE NOTICE '===========================================';
+END $$;
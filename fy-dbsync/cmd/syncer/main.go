@@ -9,11 +9,14 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/redis/go-redis/v9"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 
 	"github.com/trackfy/fy-dbsync/internal/config"
 	"github.com/trackfy/fy-dbsync/internal/syncer"
+	"github.com/trackfy/pkg/signature"
+	"github.com/trackfy/pkg/tracing"
 )
 
 func main() {
@@ -27,14 +30,29 @@ func main() {
 		Str("environment", cfg.Environment).
 		Msg("Starting Fy-DBSync Service")
 
+	// Inicializar tracing (OpenTelemetry)
+	shutdownTracing, err := tracing.Init(context.Background(), "fy-dbsync")
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize tracing")
+	}
+
 	// Verificar DATABASE_URL
 	if cfg.DatabaseURL == "" {
 		log.Fatal().Msg("DATABASE_URL is required")
 	}
 
 	// Crear syncer
+	mispInterval, err := time.ParseDuration(cfg.MISPInterval)
+	if err != nil {
+		log.Fatal().Err(err).Str("value", cfg.MISPInterval).Msg("Invalid MISP_INTERVAL")
+	}
+
 	dbSyncer, err := syncer.NewDBSyncer(&syncer.SyncerConfig{
-		DatabaseURL: cfg.DatabaseURL,
+		DatabaseURL:   cfg.DatabaseURL,
+		MISPURL:       cfg.MISPURL,
+		MISPKey:       cfg.MISPKey,
+		MISPVerifyTLS: cfg.MISPVerifyTLS,
+		MISPInterval:  mispInterval,
 	})
 	if err != nil {
 		log.Fatal().Err(err).Msg("Failed to create syncer")
@@ -80,9 +98,15 @@ func main() {
 		})
 	})
 
+	signed := signature.Middleware(signature.Options{
+		Secret:      cfg.InternalSigningSecret,
+		Nonces:      initNonceStore(cfg),
+		ExemptPaths: map[string]bool{"/health": true},
+	})
+
 	server := &http.Server{
 		Addr:         ":" + cfg.Port,
-		Handler:      mux,
+		Handler:      signed(mux),
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 	}
@@ -110,6 +134,10 @@ func main() {
 	defer shutdownCancel()
 	server.Shutdown(shutdownCtx)
 
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		log.Error().Err(err).Msg("Failed to flush traces")
+	}
+
 	log.Info().Msg("Fy-DBSync stopped")
 }
 
@@ -131,3 +159,28 @@ func setupLogger(cfg *config.Config) {
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
 	}
 }
+
+// initNonceStore usa Redis para los nonces de la firma si está configurado;
+// si no, o si Redis no responde, quedan en memoria
+func initNonceStore(cfg *config.Config) signature.NonceStore {
+	if cfg.InternalSigningSecret == "" || cfg.RedisURL == "" {
+		return signature.NewMemoryNonceStore()
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisURL,
+		Password: cfg.RedisPassword,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		log.Warn().Err(err).Str("addr", cfg.RedisURL).Msg("Redis not available, using in-memory signature nonces")
+		client.Close()
+		return signature.NewMemoryNonceStore()
+	}
+
+	log.Info().Str("addr", cfg.RedisURL).Msg("Signature nonces stored in Redis")
+	return signature.NewRedisNonceStore(client)
+}
@@ -10,6 +10,15 @@ type Config struct {
 	DatabaseURL       string
 	URLhausInterval   string
 	OpenPhishInterval string
+	MISPURL           string
+	MISPKey           string
+	MISPVerifyTLS     bool
+	MISPInterval      string
+
+	// Firma de peticiones internas (HMAC compartido con fy-admin)
+	InternalSigningSecret string
+	RedisURL              string // Nonces de la firma (vacío = en memoria)
+	RedisPassword         string
 }
 
 // Load carga la configuración desde variables de entorno
@@ -21,6 +30,14 @@ func Load() *Config {
 		DatabaseURL:       getEnv("DATABASE_URL", ""),
 		URLhausInterval:   getEnv("URLHAUS_INTERVAL", "5m"),
 		OpenPhishInterval: getEnv("OPENPHISH_INTERVAL", "1h"),
+		MISPURL:           getEnv("MISP_URL", ""),
+		MISPKey:           getEnv("MISP_KEY", ""),
+		MISPVerifyTLS:     getEnv("MISP_VERIFY_TLS", "true") == "true",
+		MISPInterval:      getEnv("MISP_INTERVAL", "1h"),
+
+		InternalSigningSecret: getEnv("INTERNAL_SIGNING_SECRET", ""),
+		RedisURL:              getEnv("REDIS_URL", ""),
+		RedisPassword:         getEnv("REDIS_PASSWORD", ""),
 	}
 }
 
@@ -0,0 +1,135 @@
+package importer
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// minRowRatio proporción mínima de filas respecto a la importación anterior para aceptar una descarga
+const minRowRatio = 0.5
+
+// Downloader obtiene el contenido de un feed. En producción es un HTTPDownloader; los tests
+// lo sustituyen para simular descargas vacías o truncadas.
+type Downloader interface {
+	Download(ctx context.Context) (io.ReadCloser, error)
+}
+
+// HTTPDownloader descarga un feed por GET
+type HTTPDownloader struct {
+	URL     string
+	Timeout time.Duration
+}
+
+// Download retorna el body de la respuesta (el llamante lo cierra); error si no es 200
+func (d *HTTPDownloader) Download(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", d.URL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", "Fy-DBSync/1.0")
+
+	client := &http.Client{Timeout: d.Timeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("download failed with status: %d", resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+// downloadValidated descarga el feed a un archivo temporal y lo valida antes de importar
+// nada: que no esté vacío, que se lea entero con countRows y que no tenga menos de
+// minRowRatio de las filas de la importación anterior (una caída brusca suele ser una
+// descarga truncada). Retorna el archivo rebobinado y sus filas; el llamante lo libera
+// con removeTemp.
+func downloadValidated(ctx context.Context, d Downloader, previousRows int64, countRows func(io.Reader) (int64, error)) (*os.File, int64, error) {
+	body, err := d.Download(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer body.Close()
+
+	tmp, err := os.CreateTemp("", "fy-dbsync-*")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to create temp file: %w", err)
+	}
+
+	rows, err := validateDownload(tmp, body, previousRows, countRows)
+	if err != nil {
+		removeTemp(tmp)
+		return nil, 0, err
+	}
+	return tmp, rows, nil
+}
+
+func validateDownload(tmp *os.File, body io.Reader, previousRows int64, countRows func(io.Reader) (int64, error)) (int64, error) {
+	size, err := io.Copy(tmp, body)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write temp file: %w", err)
+	}
+	if size == 0 {
+		return 0, fmt.Errorf("downloaded file is empty")
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+	rows, err := countRows(tmp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read downloaded file: %w", err)
+	}
+	if rows == 0 {
+		return 0, fmt.Errorf("downloaded file contains no rows")
+	}
+	if previousRows > 0 && float64(rows) < float64(previousRows)*minRowRatio {
+		return rows, fmt.Errorf("row count dropped from %d to %d, download rejected", previousRows, rows)
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to rewind temp file: %w", err)
+	}
+	return rows, nil
+}
+
+// removeTemp cierra y borra el archivo temporal de una descarga
+func removeTemp(tmp *os.File) {
+	tmp.Close()
+	os.Remove(tmp.Name())
+}
+
+// countLines filas de un feed de texto: líneas no vacías que no son comentarios (#)
+func countLines(r io.Reader) (int64, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var rows int64
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			rows++
+		}
+	}
+	return rows, scanner.Err()
+}
+
+// countGzipLines countLines sobre un feed comprimido; un gzip truncado da error
+func countGzipLines(r io.Reader) (int64, error) {
+	gzReader, err := gzip.NewReader(r)
+	if err != nil {
+		return 0, err
+	}
+	defer gzReader.Close()
+	return countLines(gzReader)
+}
@@ -0,0 +1,111 @@
+package importer
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// stubDownloader sirve un contenido fijo; err simula un corte a mitad de descarga
+type stubDownloader struct {
+	body []byte
+	err  error
+}
+
+func (d *stubDownloader) Download(ctx context.Context) (io.ReadCloser, error) {
+	var r io.Reader = bytes.NewReader(d.body)
+	if d.err != nil {
+		r = io.MultiReader(r, &failingReader{err: d.err})
+	}
+	return io.NopCloser(r), nil
+}
+
+type failingReader struct{ err error }
+
+func (r *failingReader) Read([]byte) (int, error) { return 0, r.err }
+
+func feedLines(n int) []byte {
+	return []byte("# feed\n" + strings.Repeat("http://bad.example.com/x\n", n))
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDownloadValidatedRejectsBadDownloads(t *testing.T) {
+	full := gzipped(t, feedLines(100))
+
+	tests := []struct {
+		name      string
+		download  *stubDownloader
+		countRows func(io.Reader) (int64, error)
+		want      string
+	}{
+		{"empty", &stubDownloader{}, countLines, "downloaded file is empty"},
+		{"only comments", &stubDownloader{body: []byte("# feed\n\n")}, countLines, "contains no rows"},
+		{"row count drop", &stubDownloader{body: feedLines(4)}, countLines, "row count dropped from 10 to 4"},
+		{"connection cut", &stubDownloader{body: feedLines(10), err: io.ErrUnexpectedEOF}, countLines, "unexpected EOF"},
+		{"truncated gzip", &stubDownloader{body: full[:len(full)/2]}, countGzipLines, "failed to read downloaded file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, _, err := downloadValidated(context.Background(), tt.download, 10, tt.countRows)
+			if err == nil {
+				removeTemp(file)
+				t.Fatal("download accepted, want rejection")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadValidatedAcceptsFullDownload(t *testing.T) {
+	body := gzipped(t, feedLines(6))
+
+	// La mitad exacta de las filas anteriores aún se acepta
+	file, rows, err := downloadValidated(context.Background(), &stubDownloader{body: body}, 12, countGzipLines)
+	if err != nil {
+		t.Fatalf("downloadValidated: %v", err)
+	}
+	defer removeTemp(file)
+
+	if rows != 6 {
+		t.Errorf("rows = %d, want 6", rows)
+	}
+	// El archivo queda rebobinado para importarlo
+	data, err := io.ReadAll(file)
+	if err != nil || !bytes.Equal(data, body) {
+		t.Errorf("temp file content differs from the download (err %v)", err)
+	}
+}
+
+func TestSyncImportsNothingFromRejectedDownload(t *testing.T) {
+	// Sin DB: cualquier intento de importar entraría en pánico
+	importers := []Importer{
+		&URLhausImporter{downloader: &stubDownloader{}},
+		&OpenPhishImporter{downloader: &stubDownloader{body: feedLines(2)}, lastRows: 100},
+		&StopForumSpamImporter{downloader: &stubDownloader{body: []byte("not gzip")}},
+	}
+
+	for _, imp := range importers {
+		if err := imp.Sync(context.Background()); err == nil || !strings.Contains(err.Error(), "download rejected") {
+			t.Errorf("%s: err = %v, want download rejected", imp.Name(), err)
+		}
+		if !imp.GetStats().LastImport.IsZero() {
+			t.Errorf("%s: stats updated after a rejected download", imp.Name())
+		}
+	}
+}
@@ -0,0 +1,456 @@
+package importer
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	mispPageSize = 1000
+	// mispMaxPages páginas por Sync; si no basta, el siguiente Sync sigue donde se quedó
+	mispMaxPages = 100
+	// mispInitialWindow ventana de la primera sincronización (sin sync previo en sync_status)
+	mispInitialWindow = "30d"
+	mispConfidence    = 80
+)
+
+// mispTypes tipos de atributo MISP que se importan
+var mispTypes = []string{"domain", "hostname", "url", "email", "email-src", "ip-dst"}
+
+// MISPConfig configuración de la instancia MISP
+type MISPConfig struct {
+	URL       string // Base de la instancia (https://misp.example.com)
+	Key       string // API key (cabecera Authorization)
+	VerifyTLS bool   // false para instancias con certificado autofirmado
+}
+
+// MISPImporter importa indicadores accionables (to_ids=1) de una instancia MISP
+type MISPImporter struct {
+	db            *sql.DB
+	baseURL       string
+	apiKey        string
+	client        *http.Client
+	pageSize      int
+	maxPages      int
+	lastTimestamp int64 // Timestamp MISP más reciente de la última pasada completa (sync incremental)
+	lastStats     ImportStats
+
+	// Pasada a medias (límite de páginas o error): el siguiente Sync sigue en resumePage con
+	// el mismo since, y resumeTimestamp acumula el máximo escrito hasta completarla
+	resumeSince     string
+	resumePage      int
+	resumeTimestamp int64
+}
+
+// NewMISPImporter crea un nuevo importer de MISP
+func NewMISPImporter(db *sql.DB, cfg MISPConfig) *MISPImporter {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if !cfg.VerifyTLS {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
+	return &MISPImporter{
+		db:       db,
+		baseURL:  strings.TrimSuffix(cfg.URL, "/"),
+		apiKey:   cfg.Key,
+		client:   &http.Client{Timeout: 120 * time.Second, Transport: transport},
+		pageSize: mispPageSize,
+		maxPages: mispMaxPages,
+	}
+}
+
+// Name retorna el nombre del importer
+func (i *MISPImporter) Name() string {
+	return "misp"
+}
+
+// GetStats retorna las estadísticas de la última importación
+func (i *MISPImporter) GetStats() ImportStats {
+	return i.lastStats
+}
+
+// mispAttribute atributo de la respuesta de /attributes/restSearch
+type mispAttribute struct {
+	EventID   string `json:"event_id"`
+	UUID      string `json:"uuid"`
+	Category  string `json:"category"`
+	Type      string `json:"type"`
+	Value     string `json:"value"`
+	Timestamp string `json:"timestamp"`
+}
+
+type mispSearchResponse struct {
+	Response struct {
+		Attribute []mispAttribute `json:"Attribute"`
+	} `json:"response"`
+}
+
+// Sync descarga los atributos nuevos desde el último sync y los importa a PostgreSQL, una
+// página por lote. El timestamp incremental solo avanza al completar la pasada: si se llega
+// al límite de páginas, el siguiente Sync continúa en la página siguiente con el mismo filtro.
+func (i *MISPImporter) Sync(ctx context.Context) error {
+	startTime := time.Now()
+	stats := ImportStats{LastImport: startTime}
+
+	since, firstPage, maxTimestamp := i.resumeSince, i.resumePage, i.resumeTimestamp
+	if since == "" {
+		var err error
+		if since, err = i.syncSince(ctx); err != nil {
+			return err
+		}
+		firstPage, maxTimestamp = 1, i.lastTimestamp
+	}
+
+	log.Info().Str("url", i.baseURL).Str("since", since).Int("page", firstPage).Msg("[MISP] Searching attributes and importing to PostgreSQL...")
+
+	inserted := int64(0)
+	complete := false
+	page := firstPage
+
+	for ; page < firstPage+i.maxPages; page++ {
+		select {
+		case <-ctx.Done():
+			i.resumeSince, i.resumePage, i.resumeTimestamp = since, page, maxTimestamp
+			return ctx.Err()
+		default:
+		}
+
+		attributes, err := i.search(ctx, since, page)
+		if err != nil {
+			i.resumeSince, i.resumePage, i.resumeTimestamp = since, page, maxTimestamp
+			return err
+		}
+
+		batch := newMISPBatch()
+		pageTimestamp := maxTimestamp
+		for _, attr := range attributes {
+			stats.TotalRecords++
+			if err := batch.add(attr); err != nil {
+				stats.Errors++
+				continue
+			}
+			if ts, err := strconv.ParseInt(attr.Timestamp, 10, 64); err == nil && ts > pageTimestamp {
+				pageTimestamp = ts
+			}
+		}
+
+		// Una página que no se escribe no cuenta para el timestamp: el siguiente Sync la repite
+		if err := i.writeBatch(ctx, batch, time.Now()); err != nil {
+			i.resumeSince, i.resumePage, i.resumeTimestamp = since, page, maxTimestamp
+			return fmt.Errorf("failed to import MISP page %d: %w", page, err)
+		}
+		inserted += batch.attributes
+		maxTimestamp = pageTimestamp
+
+		log.Info().Int("page", page).Int64("inserted", inserted).Msg("[MISP] Import progress")
+
+		if len(attributes) < i.pageSize {
+			complete = true
+			break
+		}
+	}
+
+	if complete {
+		i.lastTimestamp = maxTimestamp
+		i.resumeSince, i.resumePage, i.resumeTimestamp = "", 0, 0
+	} else {
+		i.resumeSince, i.resumePage, i.resumeTimestamp = since, page, maxTimestamp
+		log.Warn().
+			Int("max_pages", i.maxPages).
+			Int("next_page", page).
+			Msg("[MISP] Page limit reached, next sync resumes without advancing the timestamp")
+	}
+	i.updateSyncStatus(ctx, inserted, complete, page)
+
+	stats.Duration = time.Since(startTime)
+	i.lastStats = stats
+
+	log.Info().
+		Int64("total", stats.TotalRecords).
+		Int64("inserted", inserted).
+		Int64("errors", stats.Errors).
+		Bool("complete", complete).
+		Dur("duration", stats.Duration).
+		Msg("[MISP] Import completed")
+
+	return nil
+}
+
+// updateSyncStatus registra el sync en sync_status. last_sync (el since tras un reinicio) solo
+// avanza con la pasada completa; una pasada a medias deja el motivo en last_error.
+func (i *MISPImporter) updateSyncStatus(ctx context.Context, inserted int64, complete bool, nextPage int) {
+	var err error
+	if complete {
+		_, err = i.db.ExecContext(ctx, `
+			INSERT INTO sync_status (source, last_sync, last_count, last_error)
+			VALUES ('misp'::source_enum, NOW(), $1, NULL)
+			ON CONFLICT (source) DO UPDATE SET
+				last_sync = NOW(),
+				last_count = $1,
+				last_error = NULL
+		`, inserted)
+	} else {
+		// Sin fila previa (primer sync) last_sync se queda NULL y se usa la ventana inicial
+		_, err = i.db.ExecContext(ctx, `
+			INSERT INTO sync_status (source, last_count, last_error)
+			VALUES ('misp'::source_enum, $1, $2)
+			ON CONFLICT (source) DO UPDATE SET
+				last_count = $1,
+				last_error = $2
+		`, inserted, fmt.Sprintf("page limit reached, resuming at page %d", nextPage))
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("[MISP] Failed to update sync_status")
+	}
+}
+
+// syncSince valor del filtro timestamp de MISP: último timestamp importado,
+// el último sync registrado en sync_status o la ventana inicial
+func (i *MISPImporter) syncSince(ctx context.Context) (string, error) {
+	if i.lastTimestamp > 0 {
+		// El filtro es inclusivo: +1 para no reimportar el último atributo
+		return strconv.FormatInt(i.lastTimestamp+1, 10), nil
+	}
+
+	var lastSync int64
+	err := i.db.QueryRowContext(ctx, `
+		SELECT EXTRACT(EPOCH FROM last_sync)::bigint FROM sync_status WHERE source = 'misp'::source_enum
+	`).Scan(&lastSync)
+	if err == sql.ErrNoRows {
+		return mispInitialWindow, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read sync_status: %w", err)
+	}
+	return strconv.FormatInt(lastSync, 10), nil
+}
+
+// search consulta una página de /attributes/restSearch (solo indicadores accionables)
+func (i *MISPImporter) search(ctx context.Context, since string, page int) ([]mispAttribute, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"returnFormat": "json",
+		"type":         mispTypes,
+		"to_ids":       1,
+		"timestamp":    since,
+		"limit":        i.pageSize,
+		"page":         page,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.baseURL+"/attributes/restSearch", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", i.apiKey)
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Fy-DBSync/1.0")
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query MISP: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("MISP search failed with status: %d", resp.StatusCode)
+	}
+
+	var result mispSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse MISP response: %w", err)
+	}
+	return result.Response.Attribute, nil
+}
+
+// mispBatch atributos de una página agrupados por tabla. Cada clave va una sola vez: un
+// INSERT ... ON CONFLICT DO UPDATE no puede tocar la misma fila dos veces.
+type mispBatch struct {
+	domains    []mispDomainRow
+	paths      []mispPathRow
+	emails     []mispEmailRow
+	seen       map[string]bool
+	attributes int64 // Atributos válidos de la página
+}
+
+type mispDomainRow struct {
+	domain, threatType, sourceID, tld string
+}
+
+type mispPathRow struct {
+	domain, path, threatType string
+}
+
+type mispEmailRow struct {
+	email, domain, threatType, sourceID string
+}
+
+func newMISPBatch() *mispBatch {
+	return &mispBatch{seen: make(map[string]bool)}
+}
+
+// add valida un atributo y lo añade a la tabla que corresponde a su tipo
+func (b *mispBatch) add(attr mispAttribute) error {
+	threatType := mapMISPCategory(attr.Category)
+	sourceID := attr.EventID + ":" + attr.UUID
+	value := strings.ToLower(strings.TrimSpace(attr.Value))
+
+	switch attr.Type {
+	case "domain", "hostname", "ip-dst":
+		if net.ParseIP(value) == nil && !strings.Contains(value, ".") {
+			return fmt.Errorf("invalid domain: %s", value)
+		}
+		b.addDomain(value, threatType, sourceID)
+
+	case "url":
+		parsedURL, err := url.Parse(strings.TrimSpace(attr.Value))
+		if err != nil || parsedURL.Hostname() == "" {
+			return fmt.Errorf("invalid url: %s", attr.Value)
+		}
+		domain := strings.ToLower(parsedURL.Hostname())
+		b.addDomain(domain, threatType, sourceID)
+		if parsedURL.Path != "" && parsedURL.Path != "/" && !b.seen["path:"+domain+parsedURL.Path] {
+			b.seen["path:"+domain+parsedURL.Path] = true
+			b.paths = append(b.paths, mispPathRow{domain: domain, path: parsedURL.Path, threatType: threatType})
+		}
+
+	case "email", "email-src":
+		at := strings.LastIndex(value, "@")
+		if at <= 0 || at == len(value)-1 {
+			return fmt.Errorf("invalid email: %s", value)
+		}
+		if !b.seen["email:"+value] {
+			b.seen["email:"+value] = true
+			b.emails = append(b.emails, mispEmailRow{email: value, domain: value[at+1:], threatType: threatType, sourceID: sourceID})
+		}
+
+	default:
+		return fmt.Errorf("unsupported attribute type: %s", attr.Type)
+	}
+
+	b.attributes++
+	return nil
+}
+
+func (b *mispBatch) addDomain(domain, threatType, sourceID string) {
+	if b.seen["domain:"+domain] {
+		return
+	}
+	b.seen["domain:"+domain] = true
+
+	tld := ""
+	if net.ParseIP(domain) == nil {
+		tld = extractTLD(domain)
+	}
+	b.domains = append(b.domains, mispDomainRow{domain: domain, threatType: threatType, sourceID: sourceID, tld: tld})
+}
+
+// writeBatch escribe una página en una transacción: un INSERT multi-VALUES por tabla
+// (dominios antes que sus paths)
+func (i *MISPImporter) writeBatch(ctx context.Context, b *mispBatch, now time.Time) error {
+	if b.attributes == 0 {
+		return nil
+	}
+
+	tx, err := i.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if len(b.domains) > 0 {
+		args := []interface{}{mispConfidence, now}
+		values := make([]string, 0, len(b.domains))
+		for _, row := range b.domains {
+			n := len(args)
+			values = append(values, fmt.Sprintf(
+				"(sha256_bytea($%d), $%d, $%d::threat_type_enum, 'high'::severity_enum, $1, 'misp'::source_enum, $%d, $%d, $2, $2, 1)",
+				n+1, n+1, n+2, n+3, n+4))
+			args = append(args, row.domain, row.threatType, row.sourceID, row.tld)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO threat_domains (domain_hash, domain, threat_type, severity, confidence, source, source_id, tld, first_seen, last_seen, flags)
+			VALUES `+strings.Join(values, ", ")+`
+			ON CONFLICT (domain_hash) DO UPDATE SET
+				last_seen = EXCLUDED.last_seen,
+				hit_count = threat_domains.hit_count + 1,
+				confidence = GREATEST(threat_domains.confidence, EXCLUDED.confidence)
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("threat_domains: %w", err)
+		}
+	}
+
+	if len(b.paths) > 0 {
+		args := []interface{}{mispConfidence, now}
+		values := make([]string, 0, len(b.paths))
+		for _, row := range b.paths {
+			n := len(args)
+			values = append(values, fmt.Sprintf(
+				"(sha256_bytea($%d), sha256_bytea($%d), $%d, $%d::threat_type_enum, 'high'::severity_enum, $1, 'misp'::source_enum, $2, $2, 1)",
+				n+1, n+2, n+3, n+4))
+			args = append(args, row.domain+row.path, row.domain, row.path, row.threatType)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO threat_paths (path_hash, domain_hash, path, threat_type, severity, confidence, source, first_seen, last_seen, flags)
+			VALUES `+strings.Join(values, ", ")+`
+			ON CONFLICT (path_hash) DO UPDATE SET last_seen = EXCLUDED.last_seen
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("threat_paths: %w", err)
+		}
+	}
+
+	if len(b.emails) > 0 {
+		args := []interface{}{mispConfidence, now}
+		values := make([]string, 0, len(b.emails))
+		for _, row := range b.emails {
+			n := len(args)
+			values = append(values, fmt.Sprintf(
+				"(sha256_bytea($%d), $%d, sha256_bytea($%d), $%d::threat_type_enum, 'high'::severity_enum, $1, 'misp'::source_enum, $%d, $2, $2, 1)",
+				n+1, n+1, n+2, n+3, n+4))
+			args = append(args, row.email, row.domain, row.threatType, row.sourceID)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO threat_emails (email_hash, email, domain_hash, threat_type, severity, confidence, source, source_id, first_seen, last_seen, flags)
+			VALUES `+strings.Join(values, ", ")+`
+			ON CONFLICT (email_hash) DO UPDATE SET
+				last_seen = EXCLUDED.last_seen,
+				report_count = threat_emails.report_count + 1,
+				confidence = GREATEST(threat_emails.confidence, EXCLUDED.confidence)
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("threat_emails: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// mapMISPCategory traduce la categoría MISP a threat_type_enum
+func mapMISPCategory(category string) string {
+	switch strings.ToLower(strings.TrimSpace(category)) {
+	case "financial fraud":
+		return "phishing"
+	case "malware traffic", "payload delivery", "payload installation", "artifacts dropped", "persistence mechanism", "network activity":
+		return "malware"
+	case "social network":
+		return "scam"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,288 @@
+package importer
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// mispServer instancia MISP con attributes fijos, paginados como /attributes/restSearch
+type mispServer struct {
+	*httptest.Server
+	attributes []mispAttribute
+
+	mu       sync.Mutex
+	requests []string // "since page" de cada búsqueda
+}
+
+func newMISPServer(t *testing.T, attributes []mispAttribute) *mispServer {
+	s := &mispServer{attributes: attributes}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Timestamp string `json:"timestamp"`
+			Limit     int    `json:"limit"`
+			Page      int    `json:"page"`
+		}
+		if r.URL.Path != "/attributes/restSearch" || json.NewDecoder(r.Body).Decode(&body) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		s.mu.Lock()
+		s.requests = append(s.requests, fmt.Sprintf("%s %d", body.Timestamp, body.Page))
+		s.mu.Unlock()
+
+		// timestamp es inclusivo; una ventana ("30d") los incluye todos
+		matching := s.attributes
+		if since, err := strconv.ParseInt(body.Timestamp, 10, 64); err == nil {
+			matching = nil
+			for _, attr := range s.attributes {
+				if ts, _ := strconv.ParseInt(attr.Timestamp, 10, 64); ts >= since {
+					matching = append(matching, attr)
+				}
+			}
+		}
+		start := min((body.Page-1)*body.Limit, len(matching))
+		end := min(start+body.Limit, len(matching))
+		var resp mispSearchResponse
+		resp.Response.Attribute = matching[start:end]
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// mispDB registra las sentencias por tabla; failTable hace fallar los INSERT de esa tabla
+type mispDB struct {
+	mu         sync.Mutex
+	inserts    map[string][]int // tabla -> filas de cada INSERT confirmado
+	pending    map[string][]int
+	syncStatus []string
+	failTable  string
+}
+
+func newMISPDB() *mispDB {
+	return &mispDB{inserts: map[string][]int{}, pending: map[string][]int{}}
+}
+
+func (c *mispDB) Connect(context.Context) (driver.Conn, error) { return c, nil }
+func (c *mispDB) Driver() driver.Driver                        { return nil }
+func (c *mispDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+func (c *mispDB) Close() error                                 { return nil }
+func (c *mispDB) Begin() (driver.Tx, error)                    { return c, nil }
+
+func (c *mispDB) Commit() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for table, rows := range c.pending {
+		c.inserts[table] = append(c.inserts[table], rows...)
+	}
+	c.pending = map[string][]int{}
+	return nil
+}
+
+func (c *mispDB) Rollback() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pending = map[string][]int{}
+	return nil
+}
+
+func (c *mispDB) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if strings.Contains(query, "sync_status") {
+		status := "complete"
+		if len(args) > 1 {
+			status = args[1].Value.(string)
+		}
+		c.syncStatus = append(c.syncStatus, status)
+		return driver.RowsAffected(1), nil
+	}
+
+	for _, table := range []string{"threat_domains", "threat_paths", "threat_emails"} {
+		if strings.Contains(query, "INSERT INTO "+table) {
+			if table == c.failTable {
+				return nil, errors.New("deadlock detected")
+			}
+			c.pending[table] = append(c.pending[table], strings.Count(query, "'misp'::source_enum"))
+			return driver.RowsAffected(1), nil
+		}
+	}
+	return nil, errors.New("unexpected query: " + query)
+}
+
+func (c *mispDB) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &mispRows{}, nil // Sin sync previo en sync_status
+}
+
+type mispRows struct{}
+
+func (r *mispRows) Columns() []string         { return []string{"last_sync"} }
+func (r *mispRows) Close() error              { return nil }
+func (r *mispRows) Next([]driver.Value) error { return io.EOF }
+
+func newTestMISPImporter(t *testing.T, server *mispServer, fake *mispDB, pageSize, maxPages int) *MISPImporter {
+	t.Helper()
+	db := sql.OpenDB(fake)
+	t.Cleanup(func() { db.Close() })
+
+	i := NewMISPImporter(db, MISPConfig{URL: server.URL, Key: "key", VerifyTLS: true})
+	i.pageSize, i.maxPages = pageSize, maxPages
+	return i
+}
+
+// domainAttributes n atributos de dominio con timestamps 1001..1000+n
+func domainAttributes(n int) []mispAttribute {
+	attributes := make([]mispAttribute, n)
+	for k := range attributes {
+		attributes[k] = mispAttribute{
+			EventID:   "42",
+			UUID:      fmt.Sprintf("uuid-%d", k),
+			Category:  "Network activity",
+			Type:      "domain",
+			Value:     fmt.Sprintf("bad%d.example.com", k),
+			Timestamp: strconv.Itoa(1001 + k),
+		}
+	}
+	return attributes
+}
+
+func TestMISPSyncBatchesPages(t *testing.T) {
+	server := newMISPServer(t, domainAttributes(25))
+	fake := newMISPDB()
+	i := newTestMISPImporter(t, server, fake, 10, 100)
+
+	if err := i.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// Un INSERT multi-VALUES por página, no uno por atributo
+	if got := fmt.Sprint(fake.inserts["threat_domains"]); got != "[10 10 5]" {
+		t.Errorf("threat_domains inserts = %s, want [10 10 5]", got)
+	}
+	if i.lastTimestamp != 1025 || i.resumePage != 0 {
+		t.Errorf("lastTimestamp %d, resumePage %d; want 1025 and no resume", i.lastTimestamp, i.resumePage)
+	}
+	if got := fmt.Sprint(fake.syncStatus); got != "[complete]" {
+		t.Errorf("sync_status = %s, want [complete]", got)
+	}
+
+	// El siguiente Sync es incremental desde el último atributo
+	server.requests = nil
+	if err := i.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := fmt.Sprint(server.requests); got != "[1026 1]" {
+		t.Errorf("incremental requests = %s, want [1026 1]", got)
+	}
+}
+
+func TestMISPSyncPageLimit(t *testing.T) {
+	server := newMISPServer(t, domainAttributes(25))
+	fake := newMISPDB()
+	i := newTestMISPImporter(t, server, fake, 10, 2)
+
+	// Límite de 2 páginas: la pasada queda a medias y el timestamp no avanza
+	if err := i.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if i.lastTimestamp != 0 {
+		t.Errorf("lastTimestamp advanced to %d after an incomplete pass", i.lastTimestamp)
+	}
+	if fake.syncStatus[0] != "page limit reached, resuming at page 3" {
+		t.Errorf("sync_status = %q", fake.syncStatus)
+	}
+
+	// El siguiente Sync sigue en la página 3 con el mismo filtro y completa la pasada
+	if err := i.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := fmt.Sprint(server.requests); got != "[30d 1 30d 2 30d 3]" {
+		t.Errorf("requests = %s, want [30d 1 30d 2 30d 3]", got)
+	}
+	if got := fmt.Sprint(fake.inserts["threat_domains"]); got != "[10 10 5]" {
+		t.Errorf("threat_domains inserts = %s, want [10 10 5]", got)
+	}
+	if i.lastTimestamp != 1025 {
+		t.Errorf("lastTimestamp = %d, want 1025", i.lastTimestamp)
+	}
+}
+
+func TestMISPSyncWriteFailure(t *testing.T) {
+	attributes := domainAttributes(10)
+	attributes[3] = mispAttribute{EventID: "42", UUID: "u3", Category: "Financial fraud", Type: "email-src", Value: "Pagos@Banco-Falso.com", Timestamp: "1004"}
+	server := newMISPServer(t, attributes)
+	fake := newMISPDB()
+	fake.failTable = "threat_emails"
+	i := newTestMISPImporter(t, server, fake, 10, 100)
+
+	// Falla la página: no se confirma nada y el timestamp no avanza
+	if err := i.Sync(context.Background()); err == nil {
+		t.Fatal("Sync with a failing insert returned nil")
+	}
+	if len(fake.inserts) != 0 || i.lastTimestamp != 0 || i.resumePage != 1 {
+		t.Errorf("inserts %v, lastTimestamp %d, resumePage %d; want nothing committed and resume at page 1", fake.inserts, i.lastTimestamp, i.resumePage)
+	}
+
+	fake.failTable = ""
+	if err := i.Sync(context.Background()); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := fmt.Sprint(fake.inserts["threat_domains"], fake.inserts["threat_emails"]); got != "[9] [1]" {
+		t.Errorf("inserts = %s, want [9] [1]", got)
+	}
+	if i.lastTimestamp != 1010 {
+		t.Errorf("lastTimestamp = %d, want 1010", i.lastTimestamp)
+	}
+}
+
+func TestMISPBatchDedup(t *testing.T) {
+	b := newMISPBatch()
+	attributes := []mispAttribute{
+		{Type: "domain", Value: "phish.example.com", Category: "Financial fraud"},
+		{Type: "url", Value: "https://PHISH.example.com/login", Category: "Financial fraud"},
+		{Type: "url", Value: "https://phish.example.com/login", Category: "Financial fraud"},
+		{Type: "hostname", Value: "phish.example.com"},
+		{Type: "email", Value: "a@b.com"},
+		{Type: "email-src", Value: "A@B.com"},
+		{Type: "ip-dst", Value: "203.0.113.9"},
+	}
+	for _, attr := range attributes {
+		if err := b.add(attr); err != nil {
+			t.Fatalf("add(%+v): %v", attr, err)
+		}
+	}
+
+	// ON CONFLICT DO UPDATE no puede tocar la misma fila dos veces en un INSERT
+	if len(b.domains) != 2 || len(b.paths) != 1 || len(b.emails) != 1 || b.attributes != 7 {
+		t.Errorf("domains %v, paths %v, emails %v, attributes %d", b.domains, b.paths, b.emails, b.attributes)
+	}
+	if b.domains[1].tld != "" {
+		t.Errorf("IP got tld %q", b.domains[1].tld)
+	}
+
+	for _, attr := range []mispAttribute{
+		{Type: "domain", Value: "localhost"},
+		{Type: "url", Value: "not a url"},
+		{Type: "email", Value: "@b.com"},
+		{Type: "sha256", Value: "abc"},
+	} {
+		if err := b.add(attr); err == nil {
+			t.Errorf("add(%+v) = nil error", attr)
+		}
+	}
+	if b.attributes != 7 {
+		t.Errorf("invalid attributes counted: %d", b.attributes)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"database/sql"
 	"fmt"
 	"net"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -18,13 +17,19 @@ const openPhishURL = "https://openphish.com/feed.txt"
 
 // OpenPhishImporter descarga e importa datos de OpenPhish directamente a PostgreSQL
 type OpenPhishImporter struct {
-	db        *sql.DB
-	lastStats ImportStats
+	db         *sql.DB
+	downloader Downloader
+	lastStats  ImportStats
+	// Filas de la última descarga aceptada (referencia para rechazar una truncada)
+	lastRows int64
 }
 
 // NewOpenPhishImporter crea un nuevo importer de OpenPhish
 func NewOpenPhishImporter(db *sql.DB) *OpenPhishImporter {
-	return &OpenPhishImporter{db: db}
+	return &OpenPhishImporter{
+		db:         db,
+		downloader: &HTTPDownloader{URL: openPhishURL, Timeout: 60 * time.Second},
+	}
 }
 
 // Name retorna el nombre del importer
@@ -44,27 +49,17 @@ func (i *OpenPhishImporter) Sync(ctx context.Context) error {
 
 	log.Info().Str("url", openPhishURL).Msg("[OpenPhish] Downloading and importing to PostgreSQL...")
 
-	req, err := http.NewRequestWithContext(ctx, "GET", openPhishURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("User-Agent", "Fy-DBSync/1.0")
-
-	client := &http.Client{Timeout: 60 * time.Second}
-	resp, err := client.Do(req)
+	file, rows, err := downloadValidated(ctx, i.downloader, i.lastRows, countLines)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+		log.Warn().Err(err).Int64("previous_rows", i.lastRows).Msg("[OpenPhish] Download rejected, nothing imported")
+		return fmt.Errorf("openphish download rejected: %w", err)
 	}
+	defer removeTemp(file)
 
 	log.Info().Msg("[OpenPhish] Download complete, parsing and importing...")
 
 	// Parsear línea por línea
-	scanner := bufio.NewScanner(resp.Body)
+	scanner := bufio.NewScanner(file)
 	lineNum := 0
 	inserted := int64(0)
 	batchSize := 100
@@ -142,6 +137,7 @@ func (i *OpenPhishImporter) Sync(ctx context.Context) error {
 
 	stats.Duration = time.Since(startTime)
 	i.lastStats = stats
+	i.lastRows = rows
 
 	log.Info().
 		Int64("total", stats.TotalRecords).
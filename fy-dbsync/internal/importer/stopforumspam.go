@@ -6,7 +6,6 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"net/http"
 	"strings"
 	"time"
 
@@ -18,13 +17,19 @@ const stopForumSpamEmailsURL = "https://www.stopforumspam.com/downloads/listed_e
 
 // StopForumSpamImporter descarga e importa emails de spam desde Stop Forum Spam
 type StopForumSpamImporter struct {
-	db        *sql.DB
-	lastStats ImportStats
+	db         *sql.DB
+	downloader Downloader
+	lastStats  ImportStats
+	// Filas de la última descarga aceptada (referencia para rechazar una truncada)
+	lastRows int64
 }
 
 // NewStopForumSpamImporter crea un nuevo importer de Stop Forum Spam
 func NewStopForumSpamImporter(db *sql.DB) *StopForumSpamImporter {
-	return &StopForumSpamImporter{db: db}
+	return &StopForumSpamImporter{
+		db:         db,
+		downloader: &HTTPDownloader{URL: stopForumSpamEmailsURL, Timeout: 180 * time.Second},
+	}
 }
 
 // Name retorna el nombre del importer
@@ -44,25 +49,15 @@ func (i *StopForumSpamImporter) Sync(ctx context.Context) error {
 
 	log.Info().Str("url", stopForumSpamEmailsURL).Msg("[StopForumSpam] Downloading emails and importing to PostgreSQL...")
 
-	req, err := http.NewRequestWithContext(ctx, "GET", stopForumSpamEmailsURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("User-Agent", "Fy-DBSync/1.0")
-
-	client := &http.Client{Timeout: 180 * time.Second}
-	resp, err := client.Do(req)
+	file, rows, err := downloadValidated(ctx, i.downloader, i.lastRows, countGzipLines)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+		log.Warn().Err(err).Int64("previous_rows", i.lastRows).Msg("[StopForumSpam] Download rejected, nothing imported")
+		return fmt.Errorf("stopforumspam download rejected: %w", err)
 	}
+	defer removeTemp(file)
 
 	// Descomprimir gzip
-	gzReader, err := gzip.NewReader(resp.Body)
+	gzReader, err := gzip.NewReader(file)
 	if err != nil {
 		return fmt.Errorf("failed to create gzip reader: %w", err)
 	}
@@ -180,6 +175,7 @@ func (i *StopForumSpamImporter) Sync(ctx context.Context) error {
 
 	stats.Duration = time.Since(startTime)
 	i.lastStats = stats
+	i.lastRows = rows
 
 	log.Info().
 		Int64("total", stats.TotalRecords).
@@ -198,7 +194,7 @@ type emailEntry struct {
 	sourceID   string
 }
 
-// insertEmailBatch inserta un batch de emails
+// insertEmailBatch inserta un batch de emails (la forma canónica la calcula el trigger de threat_emails)
 func (i *StopForumSpamImporter) insertEmailBatch(ctx context.Context, batch []emailEntry) (int64, int64) {
 	var inserted, errors int64
 	now := time.Now()
@@ -6,7 +6,6 @@ import (
 	"database/sql"
 	"fmt"
 	"net"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -14,18 +13,25 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
-// URLhaus CSV en texto plano (más estable que el CSV normal)
+// URLhaus CSV en texto plano (más estable que el CSV normal). No trae tags: los importa a
+// domain_tags el feed CSV de URLhaus de fy-admin.
 const urlhausDownloadURL = "https://urlhaus.abuse.ch/downloads/text/"
 
 // URLhausImporter descarga e importa datos de URLhaus directamente a PostgreSQL
 type URLhausImporter struct {
-	db        *sql.DB
-	lastStats ImportStats
+	db         *sql.DB
+	downloader Downloader
+	lastStats  ImportStats
+	// Filas de la última descarga aceptada (referencia para rechazar una truncada)
+	lastRows int64
 }
 
 // NewURLhausImporter crea un nuevo importer de URLhaus
 func NewURLhausImporter(db *sql.DB) *URLhausImporter {
-	return &URLhausImporter{db: db}
+	return &URLhausImporter{
+		db:         db,
+		downloader: &HTTPDownloader{URL: urlhausDownloadURL, Timeout: 120 * time.Second},
+	}
 }
 
 // Name retorna el nombre del importer
@@ -46,27 +52,17 @@ func (i *URLhausImporter) Sync(ctx context.Context) error {
 	log.Info().Str("url", urlhausDownloadURL).Msg("[URLhaus] Downloading and importing to PostgreSQL...")
 
 	// Descargar datos
-	req, err := http.NewRequestWithContext(ctx, "GET", urlhausDownloadURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("User-Agent", "Fy-DBSync/1.0")
-
-	client := &http.Client{Timeout: 120 * time.Second}
-	resp, err := client.Do(req)
+	file, rows, err := downloadValidated(ctx, i.downloader, i.lastRows, countLines)
 	if err != nil {
-		return fmt.Errorf("failed to download: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("download failed with status: %d", resp.StatusCode)
+		log.Warn().Err(err).Int64("previous_rows", i.lastRows).Msg("[URLhaus] Download rejected, nothing imported")
+		return fmt.Errorf("urlhaus download rejected: %w", err)
 	}
+	defer removeTemp(file)
 
 	log.Info().Msg("[URLhaus] Download complete, parsing and importing...")
 
 	// Parsear línea por línea (formato texto: una URL por línea)
-	scanner := bufio.NewScanner(resp.Body)
+	scanner := bufio.NewScanner(file)
 	buf := make([]byte, 0, 64*1024)
 	scanner.Buffer(buf, 1024*1024)
 
@@ -153,6 +149,7 @@ func (i *URLhausImporter) Sync(ctx context.Context) error {
 
 	stats.Duration = time.Since(startTime)
 	i.lastStats = stats
+	i.lastRows = rows
 
 	log.Info().
 		Int64("total", stats.TotalRecords).
@@ -0,0 +1,81 @@
+package syncer
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	sourceQualityInterval = 30 * time.Minute
+	sourceQualityTimeout  = time.Minute
+)
+
+// qualityLoop recalcula source_quality al arrancar y cada sourceQualityInterval
+func (s *DBSyncer) qualityLoop(ctx context.Context) {
+	ticker := time.NewTicker(sourceQualityInterval)
+	defer ticker.Stop()
+
+	for {
+		runCtx, cancel := context.WithTimeout(ctx, sourceQualityTimeout)
+		if err := s.UpdateSourceQuality(runCtx); err != nil {
+			log.Error().Err(err).Msg("[DBSyncer] Failed to update source quality")
+		}
+		cancel()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// UpdateSourceQuality calcula la calidad de cada fuente de sync_status:
+// quality_score = 1 - falsos positivos / importados, donde importados son los dominios
+// activos de la fuente (threat_metrics, refrescada cada 5 min) más los falsos positivos.
+// Los falsos positivos los cuentan los triggers de threat_domains al desactivar o borrar.
+func (s *DBSyncer) UpdateSourceQuality(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `
+		INSERT INTO source_quality (source, quality_score, fp_count, tp_count, updated_at)
+		SELECT
+			ss.source::text,
+			CASE WHEN ss.false_positive_count + COALESCE(tm.active, 0) = 0 THEN 1.0
+			     ELSE 1.0 - ss.false_positive_count::numeric / (ss.false_positive_count + COALESCE(tm.active, 0))
+			END,
+			ss.false_positive_count,
+			COALESCE(tm.active, 0),
+			NOW()
+		FROM sync_status ss
+		LEFT JOIN threat_metrics tm ON tm.scope = 'domain_source' AND tm.key = ss.source::text
+		ON CONFLICT (source) DO UPDATE SET
+			quality_score = EXCLUDED.quality_score,
+			fp_count = EXCLUDED.fp_count,
+			tp_count = EXCLUDED.tp_count,
+			updated_at = EXCLUDED.updated_at
+		RETURNING source, quality_score, fp_count, tp_count
+	`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var source string
+		var quality float64
+		var fpCount, tpCount int64
+		if err := rows.Scan(&source, &quality, &fpCount, &tpCount); err != nil {
+			return err
+		}
+		log.Debug().
+			Str("source", source).
+			Float64("quality_score", quality).
+			Int64("fp_count", fpCount).
+			Int64("tp_count", tpCount).
+			Msg("[DBSyncer] Source quality updated")
+	}
+	return rows.Err()
+}
@@ -8,6 +8,11 @@ import (
 	_ "github.com/lib/pq"
 	"github.com/rs/zerolog/log"
 	"github.com/trackfy/fy-dbsync/internal/importer"
+	"github.com/trackfy/pkg/dbutil"
+	"github.com/trackfy/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // DBSyncer maneja la sincronización periódica de las bases de datos de amenazas
@@ -16,9 +21,11 @@ type DBSyncer struct {
 	urlhausImporter       importer.Importer
 	openphishImporter     importer.Importer
 	stopforumspamImporter importer.Importer
+	mispImporter          importer.Importer // nil si no hay MISP configurado
 	urlhausInterval       time.Duration
 	openphishInterval     time.Duration
 	emailsInterval        time.Duration
+	mispInterval          time.Duration
 	stopCh                chan struct{}
 }
 
@@ -28,6 +35,12 @@ type SyncerConfig struct {
 	URLhausInterval   time.Duration
 	OpenPhishInterval time.Duration
 	EmailsInterval    time.Duration
+
+	// MISP (opcional: solo si hay URL y API key)
+	MISPURL       string
+	MISPKey       string
+	MISPVerifyTLS bool
+	MISPInterval  time.Duration
 }
 
 // NewDBSyncer crea un nuevo sincronizador de DBs
@@ -36,6 +49,10 @@ func NewDBSyncer(cfg *SyncerConfig) (*DBSyncer, error) {
 		return nil, nil
 	}
 
+	if err := dbutil.ValidateDatabaseURL(cfg.DatabaseURL); err != nil {
+		return nil, err
+	}
+
 	// Conectar a PostgreSQL
 	db, err := sql.Open("postgres", cfg.DatabaseURL)
 	if err != nil {
@@ -69,7 +86,12 @@ func NewDBSyncer(cfg *SyncerConfig) (*DBSyncer, error) {
 		emailsInterval = 24 * time.Hour // Emails se actualizan cada 24h
 	}
 
-	return &DBSyncer{
+	mispInterval := cfg.MISPInterval
+	if mispInterval == 0 {
+		mispInterval = 1 * time.Hour
+	}
+
+	syncer := &DBSyncer{
 		db:                    db,
 		urlhausImporter:       importer.NewURLhausImporter(db),
 		openphishImporter:     importer.NewOpenPhishImporter(db),
@@ -77,8 +99,20 @@ func NewDBSyncer(cfg *SyncerConfig) (*DBSyncer, error) {
 		urlhausInterval:       urlhausInterval,
 		openphishInterval:     openphishInterval,
 		emailsInterval:        emailsInterval,
+		mispInterval:          mispInterval,
 		stopCh:                make(chan struct{}),
-	}, nil
+	}
+
+	if cfg.MISPURL != "" && cfg.MISPKey != "" {
+		syncer.mispImporter = importer.NewMISPImporter(db, importer.MISPConfig{
+			URL:       cfg.MISPURL,
+			Key:       cfg.MISPKey,
+			VerifyTLS: cfg.MISPVerifyTLS,
+		})
+		log.Info().Str("url", cfg.MISPURL).Msg("[DBSyncer] MISP importer enabled")
+	}
+
+	return syncer, nil
 }
 
 // Start inicia la sincronización periódica en background
@@ -100,6 +134,14 @@ func (s *DBSyncer) Start(ctx context.Context) {
 
 	// Goroutine para StopForumSpam (emails)
 	go s.syncLoop(ctx, s.stopforumspamImporter, s.emailsInterval)
+
+	// Goroutine para MISP (si está configurado)
+	if s.mispImporter != nil {
+		go s.syncLoop(ctx, s.mispImporter, s.mispInterval)
+	}
+
+	// Calidad de las fuentes (source_quality)
+	go s.qualityLoop(ctx)
 }
 
 // Stop detiene la sincronización
@@ -117,25 +159,32 @@ func (s *DBSyncer) syncNow(ctx context.Context) {
 
 	// URLhaus
 	if s.urlhausImporter != nil {
-		if err := s.urlhausImporter.Sync(ctx); err != nil {
+		if err := s.runSync(ctx, s.urlhausImporter); err != nil {
 			log.Error().Err(err).Msg("[DBSyncer] Failed to sync URLhaus")
 		}
 	}
 
 	// OpenPhish
 	if s.openphishImporter != nil {
-		if err := s.openphishImporter.Sync(ctx); err != nil {
+		if err := s.runSync(ctx, s.openphishImporter); err != nil {
 			log.Error().Err(err).Msg("[DBSyncer] Failed to sync OpenPhish")
 		}
 	}
 
 	// StopForumSpam (emails)
 	if s.stopforumspamImporter != nil {
-		if err := s.stopforumspamImporter.Sync(ctx); err != nil {
+		if err := s.runSync(ctx, s.stopforumspamImporter); err != nil {
 			log.Error().Err(err).Msg("[DBSyncer] Failed to sync StopForumSpam")
 		}
 	}
 
+	// MISP
+	if s.mispImporter != nil {
+		if err := s.runSync(ctx, s.mispImporter); err != nil {
+			log.Error().Err(err).Msg("[DBSyncer] Failed to sync MISP")
+		}
+	}
+
 	log.Info().Msg("[DBSyncer] Initial sync completed")
 }
 
@@ -161,7 +210,7 @@ func (s *DBSyncer) syncLoop(ctx context.Context, imp importer.Importer, interval
 			log.Info().Str("source", name).Msg("[DBSyncer] Running scheduled sync")
 
 			syncCtx, cancel := context.WithTimeout(ctx, 10*time.Minute)
-			if err := imp.Sync(syncCtx); err != nil {
+			if err := s.runSync(syncCtx, imp); err != nil {
 				log.Error().Err(err).Str("source", name).Msg("[DBSyncer] Scheduled sync failed")
 			} else {
 				stats := imp.GetStats()
@@ -177,6 +226,28 @@ func (s *DBSyncer) syncLoop(ctx context.Context, imp importer.Importer, interval
 	}
 }
 
+// runSync ejecuta la sincronización de un importer dentro de un span
+func (s *DBSyncer) runSync(ctx context.Context, imp importer.Importer) error {
+	ctx, span := tracing.Tracer().Start(ctx, "DBSyncer.Sync",
+		trace.WithAttributes(attribute.String("sync.source", imp.Name())),
+	)
+	defer span.End()
+
+	if err := imp.Sync(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+
+	stats := imp.GetStats()
+	span.SetAttributes(
+		attribute.Int64("sync.records", stats.TotalRecords),
+		attribute.Int64("sync.errors", stats.Errors),
+		attribute.Int64("sync.duration_ms", stats.Duration.Milliseconds()),
+	)
+	return nil
+}
+
 // GetStatus retorna el estado de las sincronizaciones
 func (s *DBSyncer) GetStatus() map[string]interface{} {
 	status := make(map[string]interface{})
@@ -211,6 +282,16 @@ func (s *DBSyncer) GetStatus() map[string]interface{} {
 		}
 	}
 
+	if s.mispImporter != nil {
+		stats := s.mispImporter.GetStats()
+		status["misp"] = map[string]interface{}{
+			"last_sync":     stats.LastImport.Format(time.RFC3339),
+			"total_records": stats.TotalRecords,
+			"errors":        stats.Errors,
+			"duration_ms":   stats.Duration.Milliseconds(),
+		}
+	}
+
 	return status
 }
 
@@ -219,15 +300,19 @@ func (s *DBSyncer) ForceSync(ctx context.Context, source string) error {
 	switch source {
 	case "urlhaus":
 		if s.urlhausImporter != nil {
-			return s.urlhausImporter.Sync(ctx)
+			return s.runSync(ctx, s.urlhausImporter)
 		}
 	case "openphish":
 		if s.openphishImporter != nil {
-			return s.openphishImporter.Sync(ctx)
+			return s.runSync(ctx, s.openphishImporter)
 		}
 	case "stopforumspam", "emails":
 		if s.stopforumspamImporter != nil {
-			return s.stopforumspamImporter.Sync(ctx)
+			return s.runSync(ctx, s.stopforumspamImporter)
+		}
+	case "misp":
+		if s.mispImporter != nil {
+			return s.runSync(ctx, s.mispImporter)
 		}
 	case "all":
 		s.syncNow(ctx)
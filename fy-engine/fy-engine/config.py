@@ -13,6 +13,8 @@ REDIS_PORT = int(os.getenv("REDIS_PORT", 6379))
 
 # Analysis Service
 ANALYSIS_SERVICE_URL = os.getenv("ANALYSIS_SERVICE_URL", "http://analysis:8081")
+# Secreto HMAC para firmar las peticiones a fy-analysis (vacío = sin firmar)
+INTERNAL_SIGNING_SECRET = os.getenv("INTERNAL_SIGNING_SECRET", "")
 
 # Fy Config
 MAX_CONTEXT_MESSAGES = 10
@@ -22,6 +22,7 @@ class ChatRequest(BaseModel):
     user_id: str
     message: str
     context: Optional[list[dict]] = None  # Historial previo
+    allowlist_items: list[str] = []       # Lista de confianza personal del usuario
 
 
 class AnalysisTrace(BaseModel):
@@ -43,6 +44,7 @@ class ChatResponse(BaseModel):
     intent: str
     analysis_performed: bool
     trace: AnalysisTrace | None = None    # Info de trazabilidad
+    entities: dict[str, list[str]] | None = None  # URLs, emails y teléfonos del mensaje (cualquier intent)
 
 
 # ============================================
@@ -90,14 +92,15 @@ async def chat(request: ChatRequest):
     # ─────────────────────────────────────────────
     analysis_result = None
     analysis_performed = False
-    
+
+    # Entidades del mensaje ORIGINAL (URLs no son PII). Se devuelven siempre: el gateway
+    # guarda todo lo que el usuario ha consultado, aunque el intent no sea de análisis
+    entities = get_entities_for_analysis(original_message)
+
     if needs_analysis(intent_result):
-        # Extraer entidades del mensaje ORIGINAL (URLs no son PII)
-        entities = get_entities_for_analysis(original_message)
-        
         if any(entities.values()):
             print(f"[Analysis] Entidades encontradas: {entities}")
-            analysis_result = await analyze_entities(entities)
+            analysis_result = await analyze_entities(entities, request.allowlist_items)
             analysis_performed = True
             print(f"[Analysis] Resultado: {analysis_result.get('verdict')} ({analysis_result.get('risk_score')}/100)")
     
@@ -150,6 +153,8 @@ async def chat(request: ChatRequest):
         intent=intent,
         analysis_performed=analysis_performed,
         trace=trace,
+        # El gateway analiza las entidades que no cubre el trace
+        entities=entities if entities and any(entities.values()) else None,
     )
 
 
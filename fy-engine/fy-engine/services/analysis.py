@@ -1,8 +1,36 @@
+import hashlib
+import hmac
+import json
+import secrets
+import time
+
 import httpx
-from config import ANALYSIS_SERVICE_URL
+from config import ANALYSIS_SERVICE_URL, INTERNAL_SIGNING_SECRET
+
+
+async def _post(client: httpx.AsyncClient, path: str, payload: dict) -> httpx.Response:
+    """
+    POST a fy-analysis firmado con HMAC-SHA256 (mismo esquema que api-gateway):
+    HMAC(secret, method + path + timestamp + nonce + sha256(body)).
+    """
+    body = json.dumps(payload).encode()
+    # X-Trackfy-Caller: fy-analysis aplica sus cuotas de análisis por llamante
+    headers = {"Content-Type": "application/json", "X-Trackfy-Caller": "fy-engine"}
+
+    if INTERNAL_SIGNING_SECRET:
+        timestamp = str(int(time.time()))
+        nonce = secrets.token_hex(16)
+        message = "POST" + path + timestamp + nonce + hashlib.sha256(body).hexdigest()
+        headers["X-Trackfy-Timestamp"] = timestamp
+        headers["X-Trackfy-Nonce"] = nonce
+        headers["X-Trackfy-Signature"] = hmac.new(
+            INTERNAL_SIGNING_SECRET.encode(), message.encode(), hashlib.sha256
+        ).hexdigest()
+
+    return await client.post(f"{ANALYSIS_SERVICE_URL}{path}", content=body, headers=headers)
 
 
-async def analyze_entities(entities: dict) -> dict | None:
+async def analyze_entities(entities: dict, allowlist_items: list[str] | None = None) -> dict | None:
     """
     Llama al servicio de análisis con las entidades extraídas.
     
@@ -12,6 +40,7 @@ async def analyze_entities(entities: dict) -> dict | None:
             "emails": ["test@example.com"],
             "phones": ["+34612345678"]
         }
+        allowlist_items: lista de confianza personal del usuario (se reenvía a fy-analysis)
     
     Returns:
         {
@@ -24,22 +53,23 @@ async def analyze_entities(entities: dict) -> dict | None:
     """
     # Prioridad: URLs > Emails > Phones
     if entities.get("urls"):
-        return await analyze_url(entities["urls"][0])
+        return await analyze_url(entities["urls"][0], allowlist_items)
     elif entities.get("emails"):
-        return await analyze_email(entities["emails"][0])
+        return await analyze_email(entities["emails"][0], allowlist_items)
     elif entities.get("phones"):
-        return await analyze_phone(entities["phones"][0])
+        return await analyze_phone(entities["phones"][0], allowlist_items)
     
     return None
 
 
-async def analyze_url(url: str) -> dict:
+async def analyze_url(url: str, allowlist_items: list[str] | None = None) -> dict:
     """Analiza una URL"""
     try:
         async with httpx.AsyncClient(timeout=30.0) as client:
-            response = await client.post(
-                f"{ANALYSIS_SERVICE_URL}/analyze/url",
-                json={"url": url}
+            response = await _post(
+                client,
+                "/analyze/url",
+                {"url": url, "allowlist_items": allowlist_items or []},
             )
             
             if response.status_code == 200:
@@ -58,13 +88,14 @@ async def analyze_url(url: str) -> dict:
     }
 
 
-async def analyze_email(email: str) -> dict:
+async def analyze_email(email: str, allowlist_items: list[str] | None = None) -> dict:
     """Analiza un email"""
     try:
         async with httpx.AsyncClient(timeout=30.0) as client:
-            response = await client.post(
-                f"{ANALYSIS_SERVICE_URL}/analyze/email",
-                json={"email": email}
+            response = await _post(
+                client,
+                "/analyze/email",
+                {"email": email, "allowlist_items": allowlist_items or []},
             )
             
             if response.status_code == 200:
@@ -82,13 +113,14 @@ async def analyze_email(email: str) -> dict:
     }
 
 
-async def analyze_phone(phone: str) -> dict:
+async def analyze_phone(phone: str, allowlist_items: list[str] | None = None) -> dict:
     """Analiza un teléfono"""
     try:
         async with httpx.AsyncClient(timeout=30.0) as client:
-            response = await client.post(
-                f"{ANALYSIS_SERVICE_URL}/analyze/phone",
-                json={"phone": phone}
+            response = await _post(
+                client,
+                "/analyze/phone",
+                {"phone": phone, "allowlist_items": allowlist_items or []},
             )
             
             if response.status_code == 200:
@@ -0,0 +1,102 @@
+// Package clientip resuelve la IP del cliente detrás de proxies inversos. X-Forwarded-For y
+// X-Real-IP solo se respetan si la conexión viene de un proxy de confianza (TRUSTED_PROXIES):
+// cualquier cliente puede enviarlas, y usarlas tal cual permite saltarse los rate limits por IP
+// o falsear la IP del audit log.
+package clientip
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseTrustedProxies IPs o rangos CIDR de los proxies inversos (TRUSTED_PROXIES, separados
+// por comas). Vacío = ninguno: se usa siempre la IP de la conexión.
+func ParseTrustedProxies(raw string) ([]*net.IPNet, error) {
+	var proxies []*net.IPNet
+	for _, entry := range strings.Split(raw, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			entry = fmt.Sprintf("%s/%d", entry, bits)
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, nil
+}
+
+// RealIP sustituye r.RemoteAddr por la IP del cliente resuelta con Resolve, para que los
+// logs y los rate limits por IP (httprate.LimitByIP) usen la del cliente. A diferencia de
+// middleware.RealIP de chi, no se fía de las cabeceras si no las pone un proxy de trusted.
+func RealIP(trusted []*net.IPNet) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = Resolve(r, trusted)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Resolve recorre X-Forwarded-For de derecha a izquierda saltando los proxies de confianza:
+// la primera IP que no lo es la ha añadido un proxy de confianza y es la del cliente. Las
+// entradas a su izquierda las controla el cliente y se ignoran.
+func Resolve(r *http.Request, trusted []*net.IPNet) string {
+	remote := RemoteHost(r)
+	if !ipTrusted(trusted, remote) {
+		return remote
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break // Entrada mal formada: no se puede seguir la cadena
+			}
+			if !ipTrusted(trusted, hop) {
+				return hop
+			}
+		}
+	}
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(realIP) != nil {
+		return realIP
+	}
+	return remote
+}
+
+// RemoteHost IP de r.RemoteAddr sin el puerto
+func RemoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ipTrusted(trusted []*net.IPNet, host string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
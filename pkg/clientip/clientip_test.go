@@ -0,0 +1,83 @@
+package clientip
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.10,::1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies: %v", err)
+	}
+	if len(proxies) != 3 || proxies[1].String() != "192.168.1.10/32" || proxies[2].String() != "::1/128" {
+		t.Errorf("proxies = %v", proxies)
+	}
+
+	for _, raw := range []string{"nginx", "10.0.0.0/33", "10.0.0.300"} {
+		if _, err := ParseTrustedProxies(raw); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) = nil error", raw)
+		}
+	}
+	if proxies, err := ParseTrustedProxies(""); err != nil || len(proxies) != 0 {
+		t.Errorf("empty TRUSTED_PROXIES = %v, %v; want no proxies", proxies, err)
+	}
+}
+
+func TestRealIP(t *testing.T) {
+	trusted, _ := ParseTrustedProxies("172.18.0.0/16")
+
+	tests := []struct {
+		name       string
+		trusted    bool
+		remoteAddr string
+		forwarded  []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", true, "203.0.113.7:51000", nil, "", "203.0.113.7"},
+		{"forged header without proxy", true, "203.0.113.7:51000", []string{"10.1.2.3"}, "", "203.0.113.7"},
+		{"forged real ip without proxy", true, "203.0.113.7:51000", nil, "10.1.2.3", "203.0.113.7"},
+		{"no proxies configured", false, "172.18.0.5:40000", []string{"198.51.100.4"}, "", "172.18.0.5"},
+		{"through proxy", true, "172.18.0.5:40000", []string{"198.51.100.4"}, "", "198.51.100.4"},
+		// El cliente manda su propio X-Forwarded-For: el proxy añade la IP real al final
+		{"forged entry before proxy", true, "172.18.0.5:40000", []string{"10.1.2.3, 198.51.100.4"}, "", "198.51.100.4"},
+		{"chained proxies", true, "172.18.0.5:40000", []string{"198.51.100.4, 172.18.0.9"}, "", "198.51.100.4"},
+		{"repeated headers", true, "172.18.0.5:40000", []string{"10.1.2.3", "198.51.100.4"}, "", "198.51.100.4"},
+		{"real ip from proxy", true, "172.18.0.5:40000", nil, "198.51.100.4", "198.51.100.4"},
+		{"malformed hop", true, "172.18.0.5:40000", []string{"198.51.100.4, <script>"}, "", "172.18.0.5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/actions/sync", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			if tt.realIP != "" {
+				r.Header.Set("X-Real-IP", tt.realIP)
+			}
+
+			proxies := trusted
+			if !tt.trusted {
+				proxies = nil
+			}
+			var got string
+			RealIP(proxies)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = RemoteHost(r)
+			})).ServeHTTP(httptest.NewRecorder(), r)
+
+			if got != tt.want {
+				t.Errorf("client IP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Fuera del middleware (p. ej. tests de handlers) se usa la conexión
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set("X-Forwarded-For", "10.1.2.3")
+	if got := RemoteHost(r); got != "192.0.2.1" {
+		t.Errorf("RemoteHost without middleware = %q, want RemoteAddr 192.0.2.1", got)
+	}
+}
@@ -0,0 +1,191 @@
+// Package dbtls conexión mTLS con PostgreSQL (lib/pq): parámetros de la cadena de conexión
+// y validación del handshake al arrancar. Lo usan api-gateway y fy-analysis.
+package dbtls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// postgresSSLRequestCode código del mensaje SSLRequest del protocolo de PostgreSQL
+const postgresSSLRequestCode = 80877103
+
+// Config certificados de la conexión mTLS con PostgreSQL (DB_SSL_CERT, DB_SSL_KEY, DB_SSL_CA)
+type Config struct {
+	CertFile string // Certificado de cliente
+	KeyFile  string // Clave privada del cliente (lib/pq exige permisos 0600)
+	CAFile   string // CA que firma el certificado del servidor
+}
+
+// Enabled indica si se ha configurado algún certificado
+func (c Config) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.CAFile != ""
+}
+
+// ApplyToDSN añade a la cadena de conexión los parámetros de lib/pq para mTLS.
+// sslmode=verify-full valida la cadena del servidor y que el SAN coincida con el host.
+func (c Config) ApplyToDSN(dsn string) string {
+	if !c.Enabled() || dsn == "" {
+		return dsn
+	}
+
+	params := [][2]string{{"sslmode", "verify-full"}}
+	if c.CertFile != "" {
+		params = append(params, [2]string{"sslcert", c.CertFile})
+	}
+	if c.KeyFile != "" {
+		params = append(params, [2]string{"sslkey", c.KeyFile})
+	}
+	if c.CAFile != "" {
+		params = append(params, [2]string{"sslrootcert", c.CAFile})
+	}
+
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return dsn
+		}
+		q := u.Query()
+		for _, p := range params {
+			q.Set(p[0], p[1])
+		}
+		u.RawQuery = q.Encode()
+		return u.String()
+	}
+
+	// Formato clave=valor: lib/pq se queda con el último valor de cada clave
+	for _, p := range params {
+		value := strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(p[1])
+		dsn += fmt.Sprintf(" %s='%s'", p[0], value)
+	}
+	return dsn
+}
+
+// clientTLSConfig configuración TLS equivalente a la que usa lib/pq con verify-full
+func (c Config) clientTLSConfig(serverName string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         serverName,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: false,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read DB CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in DB CA %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load DB client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// DialTLS abre una conexión TLS con el servidor de la cadena de conexión (SSLRequest + handshake)
+// y comprueba que el SAN del certificado coincide con el host
+func (c Config) DialTLS(ctx context.Context, dsn string) (*tls.ConnectionState, error) {
+	host, port, err := dsnHostPort(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := c.clientTLSConfig(host)
+	if err != nil {
+		return nil, err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	sslRequest := make([]byte, 8)
+	binary.BigEndian.PutUint32(sslRequest[0:4], 8)
+	binary.BigEndian.PutUint32(sslRequest[4:8], postgresSSLRequestCode)
+	if _, err := conn.Write(sslRequest); err != nil {
+		return nil, err
+	}
+
+	reply := make([]byte, 1)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return nil, err
+	}
+	if reply[0] != 'S' {
+		return nil, fmt.Errorf("server %s does not accept SSL connections", host)
+	}
+
+	tlsConn := tls.Client(conn, cfg)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		return nil, fmt.Errorf("TLS handshake failed: %w", err)
+	}
+
+	state := tlsConn.ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server %s sent no certificate", host)
+	}
+	if err := state.PeerCertificates[0].VerifyHostname(host); err != nil {
+		return nil, fmt.Errorf("server certificate SAN mismatch: %w", err)
+	}
+	return &state, nil
+}
+
+// dsnHostPort host y puerto de una cadena de conexión de lib/pq (URL o clave=valor)
+func dsnHostPort(dsn string) (string, string, error) {
+	host, port := "", ""
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		u, err := url.Parse(dsn)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		host, port = u.Hostname(), u.Port()
+	} else {
+		for _, field := range strings.Fields(dsn) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+			value = strings.Trim(value, "'")
+			switch key {
+			case "host":
+				host = value
+			case "port":
+				port = value
+			}
+		}
+	}
+
+	if host == "" {
+		host = "localhost"
+	}
+	if strings.HasPrefix(host, "/") {
+		return "", "", fmt.Errorf("unix socket %s does not use TLS", host)
+	}
+	if port == "" {
+		port = "5432"
+	}
+	return host, port, nil
+}
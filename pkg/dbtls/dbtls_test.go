@@ -0,0 +1,45 @@
+package dbtls
+
+import "testing"
+
+func TestApplyToDSN(t *testing.T) {
+	cfg := Config{CertFile: "/certs/client.crt", KeyFile: "/certs/client.key", CAFile: "/certs/ca's.crt"}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		dsn  string
+		want string
+	}{
+		{"disabled", Config{}, "postgres://u:p@db:5432/trackfy?sslmode=disable", "postgres://u:p@db:5432/trackfy?sslmode=disable"},
+		{"empty dsn", cfg, "", ""},
+		{"url overrides sslmode", cfg, "postgres://u:p@db:5432/trackfy?sslmode=disable",
+			"postgres://u:p@db:5432/trackfy?sslcert=%2Fcerts%2Fclient.crt&sslkey=%2Fcerts%2Fclient.key&sslmode=verify-full&sslrootcert=%2Fcerts%2Fca%27s.crt"},
+		{"key=value", Config{CAFile: `/certs/ca's.crt`}, "host=db dbname=trackfy",
+			`host=db dbname=trackfy sslmode='verify-full' sslrootcert='/certs/ca\'s.crt'`},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.ApplyToDSN(tt.dsn); got != tt.want {
+			t.Errorf("%s: ApplyToDSN = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestDSNHostPort(t *testing.T) {
+	tests := []struct {
+		dsn, host, port string
+		wantErr         bool
+	}{
+		{"postgres://u:p@db.internal:6432/trackfy", "db.internal", "6432", false},
+		{"postgresql://u@db/trackfy", "db", "5432", false},
+		{"host='db' port=5433 dbname=trackfy", "db", "5433", false},
+		{"dbname=trackfy", "localhost", "5432", false},
+		{"host=/var/run/postgresql dbname=trackfy", "", "", true},
+	}
+	for _, tt := range tests {
+		host, port, err := dsnHostPort(tt.dsn)
+		if (err != nil) != tt.wantErr || host != tt.host || port != tt.port {
+			t.Errorf("dsnHostPort(%q) = %q, %q, %v", tt.dsn, host, port, err)
+		}
+	}
+}
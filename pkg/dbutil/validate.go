@@ -0,0 +1,62 @@
+// Package dbutil utilidades comunes de las conexiones a PostgreSQL (DATABASE_URL de todos los servicios)
+package dbutil
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// sslModes valores de sslmode que acepta lib/pq
+var sslModes = map[string]bool{
+	"disable":     true,
+	"allow":       true,
+	"prefer":      true,
+	"require":     true,
+	"verify-ca":   true,
+	"verify-full": true,
+}
+
+// ValidateDatabaseURL comprueba el formato de DATABASE_URL antes de sql.Open: con una URL
+// mal formada lib/pq falla al conectar con errores poco claros. Exige una URL
+// postgres:// o postgresql:// con host y base de datos (también válidos como parámetros
+// host y dbname, p. ej. para sockets Unix), un sslmode conocido y max_conns positivo.
+func ValidateDatabaseURL(rawURL string) error {
+	if rawURL == "" {
+		return fmt.Errorf("DATABASE_URL is empty")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		// El error de url.Parse incluye la URL completa con la contraseña
+		return fmt.Errorf("DATABASE_URL is not a valid URL")
+	}
+	if u.Scheme != "postgres" && u.Scheme != "postgresql" {
+		return fmt.Errorf("DATABASE_URL scheme must be 'postgres' or 'postgresql', got %q", u.Scheme)
+	}
+
+	q := u.Query()
+	if u.Hostname() == "" && q.Get("host") == "" {
+		return fmt.Errorf("DATABASE_URL missing 'host' component")
+	}
+	if port := u.Port(); port != "" {
+		if n, err := strconv.Atoi(port); err != nil || n <= 0 || n > 65535 {
+			return fmt.Errorf("DATABASE_URL has invalid port %q", port)
+		}
+	}
+	if strings.Trim(u.Path, "/") == "" && q.Get("dbname") == "" {
+		return fmt.Errorf("DATABASE_URL missing 'dbname' component")
+	}
+
+	if q.Has("sslmode") && !sslModes[q.Get("sslmode")] {
+		return fmt.Errorf("DATABASE_URL has invalid sslmode %q (expected disable, allow, prefer, require, verify-ca or verify-full)", q.Get("sslmode"))
+	}
+	if q.Has("max_conns") {
+		if n, err := strconv.Atoi(q.Get("max_conns")); err != nil || n <= 0 {
+			return fmt.Errorf("DATABASE_URL max_conns must be a positive integer, got %q", q.Get("max_conns"))
+		}
+	}
+
+	return nil
+}
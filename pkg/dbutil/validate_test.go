@@ -0,0 +1,38 @@
+package dbutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateDatabaseURL(t *testing.T) {
+	tests := []struct {
+		url     string
+		wantErr string // "" = válida
+	}{
+		{"postgres://trackfy:secret@db:5432/trackfy?sslmode=disable", ""},
+		{"postgresql://trackfy@db/trackfy?max_conns=20", ""},
+		{"postgres:///trackfy?host=/var/run/postgresql", ""},
+		{"", "empty"},
+		{"mysql://db/trackfy", "scheme"},
+		{"postgres://trackfy:secret@/trackfy", "host"},
+		{"postgres://db:99999/trackfy", "port"},
+		{"postgres://db/", "dbname"},
+		{"postgres://db/trackfy?sslmode=strict", "sslmode"},
+		{"postgres://db/trackfy?max_conns=0", "max_conns"},
+		{"postgres://trackfy:s%zz@db/trackfy", "not a valid URL"},
+	}
+	for _, tt := range tests {
+		err := ValidateDatabaseURL(tt.url)
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("ValidateDatabaseURL(%q) = %v, want nil", tt.url, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("ValidateDatabaseURL(%q) = %v, want error containing %q", tt.url, err, tt.wantErr)
+		}
+		// La contraseña nunca aparece en el error
+		if err != nil && strings.Contains(err.Error(), "secret") {
+			t.Errorf("ValidateDatabaseURL(%q) leaks the password: %v", tt.url, err)
+		}
+	}
+}
@@ -0,0 +1,204 @@
+// Package httpgzip middleware de compresión gzip de las respuestas HTTP. Lo usan api-gateway,
+// fy-analysis y fy-admin.
+package httpgzip
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// minCompressSize tamaño mínimo del body para comprimir (evitar overhead en respuestas pequeñas)
+const minCompressSize = 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// Options configuración opcional del middleware
+type Options struct {
+	// OnCompress se llama por cada respuesta enviada comprimida (p.ej. un contador de Prometheus)
+	OnCompress func()
+}
+
+// Middleware comprime con gzip las respuestas de más de 1KB si el cliente lo acepta.
+// SSE y WebSocket nunca se comprimen.
+func Middleware(next http.Handler) http.Handler {
+	return New(Options{})(next)
+}
+
+// New como Middleware, con Options
+func New(opts Options) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || !acceptsGzip(r) || isStreamingRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			cw := &compressResponseWriter{ResponseWriter: w, onCompress: opts.OnCompress}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// acceptsGzip verifica Accept-Encoding: gzip (ignorando gzip;q=0)
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(enc, ";")
+		if strings.TrimSpace(name) != "gzip" {
+			continue
+		}
+		q := strings.ReplaceAll(strings.TrimSpace(params), " ", "")
+		return q != "q=0" && q != "q=0.0" && q != "q=0.00" && q != "q=0.000"
+	}
+	return false
+}
+
+// isStreamingRequest detecta SSE y upgrades a WebSocket
+func isStreamingRequest(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream") ||
+		strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+// compressResponseWriter acumula hasta minCompressSize bytes antes de decidir si comprime
+type compressResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	decided     bool // Ya se enviaron headers (comprimido o no)
+	passthrough bool // Se decidió no comprimir
+	onCompress  func()
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+	}
+}
+
+func (cw *compressResponseWriter) Write(p []byte) (int, error) {
+	if cw.decided {
+		if cw.passthrough {
+			return cw.ResponseWriter.Write(p)
+		}
+		return cw.gz.Write(p)
+	}
+
+	cw.buf.Write(p)
+	if cw.buf.Len() < minCompressSize {
+		return len(p), nil
+	}
+
+	if err := cw.decide(true); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// decide envía los headers y el buffer acumulado, comprimido si canCompress y la respuesta lo permite.
+// Comprimida: sin Content-Length (el tamaño final no se conoce). Sin comprimir: se respeta el
+// Content-Length del handler; si no lo puso y el body entero está en el buffer, se calcula.
+func (cw *compressResponseWriter) decide(canCompress bool) error {
+	cw.decided = true
+	header := cw.Header()
+
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+
+	contentType := header.Get("Content-Type")
+	if contentType == "" && cw.buf.Len() > 0 {
+		contentType = http.DetectContentType(cw.buf.Bytes())
+		header.Set("Content-Type", contentType)
+	}
+
+	if !canCompress || header.Get("Content-Encoding") != "" || header.Get("Content-Range") != "" ||
+		strings.HasPrefix(contentType, "text/event-stream") || !bodyAllowed(cw.status) {
+		cw.passthrough = true
+		if !canCompress && header.Get("Content-Length") == "" && bodyAllowed(cw.status) {
+			header.Set("Content-Length", strconv.Itoa(cw.buf.Len()))
+		}
+		cw.ResponseWriter.WriteHeader(cw.status)
+		_, err := cw.ResponseWriter.Write(cw.buf.Bytes())
+		cw.buf.Reset()
+		return err
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Del("Content-Length")
+	cw.ResponseWriter.WriteHeader(cw.status)
+
+	cw.gz = gzipWriterPool.Get().(*gzip.Writer)
+	cw.gz.Reset(cw.ResponseWriter)
+
+	if cw.onCompress != nil {
+		cw.onCompress()
+	}
+
+	_, err := cw.gz.Write(cw.buf.Bytes())
+	cw.buf.Reset()
+	return err
+}
+
+// bodyAllowed indica si el status admite body (1xx, 204 y 304 no)
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
+
+// Flush antes de llegar al umbral implica streaming: se envía sin comprimir y sin Content-Length
+func (cw *compressResponseWriter) Flush() {
+	if !cw.decided {
+		cw.decided = true
+		cw.passthrough = true
+		if cw.status == 0 {
+			cw.status = http.StatusOK
+		}
+		cw.ResponseWriter.WriteHeader(cw.status)
+		_, _ = cw.ResponseWriter.Write(cw.buf.Bytes())
+		cw.buf.Reset()
+	}
+	if cw.gz != nil {
+		_ = cw.gz.Flush()
+	}
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack permite upgrades de conexión a través del wrapper
+func (cw *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := cw.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, errors.New("response writer does not support hijacking")
+}
+
+// Close envía lo pendiente: respuestas pequeñas sin comprimir, o cierra el stream gzip
+func (cw *compressResponseWriter) Close() {
+	if !cw.decided {
+		if cw.status == 0 && cw.buf.Len() == 0 {
+			return
+		}
+		_ = cw.decide(false)
+		return
+	}
+
+	if cw.gz != nil {
+		_ = cw.gz.Close()
+		gzipWriterPool.Put(cw.gz)
+		cw.gz = nil
+	}
+}
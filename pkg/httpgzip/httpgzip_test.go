@@ -0,0 +1,194 @@
+package httpgzip
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// compressedRequest pasa GET / por Middleware con handler y acceptEncoding
+func compressedRequest(t *testing.T, acceptEncoding, accept string, handler http.HandlerFunc) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/api/data/domains", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	if accept != "" {
+		r.Header.Set("Accept", accept)
+	}
+	rec := httptest.NewRecorder()
+	Middleware(handler).ServeHTTP(rec, r)
+	return rec
+}
+
+func gunzip(t *testing.T, body []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	plain, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("read gzip body: %v", err)
+	}
+	return plain
+}
+
+func TestCompressionLargeResponse(t *testing.T) {
+	body := []byte(`{"data":[` + strings.Repeat(`{"domain":"phishing.example","active":true},`, 100) + `{}]}`)
+
+	// El handler escribe en trozos y pone Content-Length: comprimido no puede mantenerse
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(http.StatusCreated)
+		for start := 0; start < len(body); start += 300 {
+			_, _ = w.Write(body[start:min(start+300, len(body))])
+		}
+	}
+
+	rec := compressedRequest(t, "br, gzip;q=0.8", "", handler)
+	if rec.Code != http.StatusCreated {
+		t.Errorf("status = %d, want 201", rec.Code)
+	}
+	if got := rec.Header().Get("Content-Encoding"); got != "gzip" {
+		t.Fatalf("Content-Encoding = %q, want gzip", got)
+	}
+	if got := rec.Header().Get("Content-Length"); got != "" {
+		t.Errorf("compressed response kept Content-Length %s", got)
+	}
+	if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", got)
+	}
+	if rec.Body.Len() >= len(body) {
+		t.Errorf("compressed body %d bytes, plain %d", rec.Body.Len(), len(body))
+	}
+	if plain := gunzip(t, rec.Body.Bytes()); !bytes.Equal(plain, body) {
+		t.Errorf("decompressed body differs: %d bytes, want %d", len(plain), len(body))
+	}
+}
+
+func TestCompressionIdentity(t *testing.T) {
+	large := bytes.Repeat([]byte("a"), 4*minCompressSize)
+	small := []byte(`{"ok":true}`)
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		body           []byte
+		contentLength  string // El que pone el handler ("" = ninguno)
+		wantLength     string
+	}{
+		{"small response", "gzip", small, "", strconv.Itoa(len(small))},
+		{"small response keeps handler length", "gzip", small, strconv.Itoa(len(small)), strconv.Itoa(len(small))},
+		{"client without gzip", "", large, strconv.Itoa(len(large)), strconv.Itoa(len(large))},
+		{"gzip refused with q=0", "gzip;q=0, deflate", large, strconv.Itoa(len(large)), strconv.Itoa(len(large))},
+		{"identity only", "identity", large, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := compressedRequest(t, tt.acceptEncoding, "", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if tt.contentLength != "" {
+					w.Header().Set("Content-Length", tt.contentLength)
+				}
+				_, _ = w.Write(tt.body)
+			})
+
+			if got := rec.Header().Get("Content-Encoding"); got != "" {
+				t.Errorf("Content-Encoding = %q, want identity", got)
+			}
+			if got := rec.Header().Get("Content-Length"); got != tt.wantLength {
+				t.Errorf("Content-Length = %q, want %q", got, tt.wantLength)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), tt.body) {
+				t.Errorf("body = %d bytes, want %d", rec.Body.Len(), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressionSkipsSSE(t *testing.T) {
+	event := []byte("data: " + strings.Repeat("x", 2*minCompressSize) + "\n\n")
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write(event)
+		w.(http.Flusher).Flush()
+	}
+
+	// Con Accept: text/event-stream ni siquiera se envuelve el writer
+	rec := compressedRequest(t, "gzip", "text/event-stream", handler)
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" || !bytes.Equal(rec.Body.Bytes(), event) {
+		t.Errorf("SSE with Accept: headers = %v", rec.Header())
+	}
+
+	// Sin Accept la respuesta se reconoce por su Content-Type
+	rec = compressedRequest(t, "gzip", "", handler)
+	if rec.Header().Get("Content-Encoding") != "" || !bytes.Equal(rec.Body.Bytes(), event) {
+		t.Errorf("SSE by Content-Type: headers = %v", rec.Header())
+	}
+	if !rec.Flushed {
+		t.Error("Flush did not reach the underlying writer")
+	}
+}
+
+func TestCompressionEarlyFlush(t *testing.T) {
+	// Flush antes del umbral: streaming sin comprimir y sin Content-Length
+	rec := compressedRequest(t, "gzip", "", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("progreso 1\n"))
+		w.(http.Flusher).Flush()
+		_, _ = w.Write([]byte(strings.Repeat("progreso\n", 200)))
+	})
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Content-Length") != "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+	if !strings.HasPrefix(rec.Body.String(), "progreso 1\n") || rec.Body.Len() != 11+9*200 {
+		t.Errorf("body = %d bytes", rec.Body.Len())
+	}
+}
+
+func TestCompressionNoBody(t *testing.T) {
+	rec := compressedRequest(t, "gzip", "", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Content-Length") != "" || rec.Body.Len() != 0 {
+		t.Errorf("204: status %d, headers %v, body %d bytes", rec.Code, rec.Header(), rec.Body.Len())
+	}
+}
+
+func TestCompressionSkipsWebSocket(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/ws", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+	r.Header.Set("Upgrade", "websocket")
+	rec := httptest.NewRecorder()
+	Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := w.(*compressResponseWriter); ok {
+			t.Error("WebSocket upgrade got the compressing writer")
+		}
+	})).ServeHTTP(rec, r)
+	if rec.Header().Get("Vary") != "" {
+		t.Errorf("headers = %v", rec.Header())
+	}
+}
+
+func TestCompressionOnCompress(t *testing.T) {
+	compressed := 0
+	mw := New(Options{OnCompress: func() { compressed++ }})
+
+	for _, body := range [][]byte{[]byte(`{"ok":true}`), bytes.Repeat([]byte("a"), 2*minCompressSize)} {
+		r := httptest.NewRequest(http.MethodGet, "/api/data/domains", nil)
+		r.Header.Set("Accept-Encoding", "gzip")
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write(body)
+		})).ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if compressed != 1 {
+		t.Errorf("OnCompress called %d times, want 1 (only the large response)", compressed)
+	}
+}
@@ -0,0 +1,46 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+)
+
+// swaggerUIVersion versión de swagger-ui-dist que carga /docs desde el CDN
+const swaggerUIVersion = "5.17.14"
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="es">
+<head>
+<meta charset="utf-8">
+<title>%[1]s</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@%[2]s/swagger-ui-bundle.js" crossorigin></script>
+<script>
+window.onload = function () {
+  window.ui = SwaggerUIBundle({ url: %[3]q, dom_id: "#swagger-ui" });
+};
+</script>
+</body>
+</html>
+`
+
+// SpecHandler sirve el documento ya serializado (el openapi.json embebido)
+func SpecHandler(spec []byte) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(spec)
+	}
+}
+
+// SwaggerUIHandler página de Swagger UI que carga el documento de specURL
+func SwaggerUIHandler(title, specURL string) http.HandlerFunc {
+	page := fmt.Sprintf(swaggerUIPage, html.EscapeString(title), swaggerUIVersion, specURL)
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	}
+}
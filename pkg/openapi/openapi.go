@@ -0,0 +1,384 @@
+// Package openapi construye el documento OpenAPI 3.0 de la API a partir de la tabla de rutas
+// y de los structs de request/response (por reflexión, con los tags json). El documento se
+// genera con go generate y se sirve embebido; no se calcula en runtime. Lo comparten
+// api-gateway y fy-analysis.
+package openapi
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Document documento OpenAPI 3.0 (solo lo que usan nuestras APIs)
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+
+	schemaNames map[reflect.Type]string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem operaciones de una ruta por método en minúsculas (get, post...)
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string             `json:"tags,omitempty"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	OperationID string               `json:"operationId"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	// Security nil hereda la global; vacío = ruta pública
+	Security *[]SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"` // path, query, header
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"` // http, apiKey
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"`
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+// SecurityRequirement esquema -> scopes (siempre vacíos en nuestras APIs)
+type SecurityRequirement map[string][]string
+
+// New crea un documento vacío
+func New(title, version, description string) *Document {
+	return &Document{
+		OpenAPI:     "3.0.3",
+		Info:        Info{Title: title, Version: version, Description: description},
+		Paths:       make(map[string]PathItem),
+		Components:  Components{Schemas: make(map[string]*Schema)},
+		schemaNames: make(map[reflect.Type]string),
+	}
+}
+
+// AddSecurityScheme registra un esquema; si global, se aplica a todas las rutas salvo Public()
+func (d *Document) AddSecurityScheme(name string, scheme *SecurityScheme, global bool) {
+	if d.Components.SecuritySchemes == nil {
+		d.Components.SecuritySchemes = make(map[string]*SecurityScheme)
+	}
+	d.Components.SecuritySchemes[name] = scheme
+	if global {
+		d.Security = append(d.Security, SecurityRequirement{name: {}})
+	}
+}
+
+// AddTag describe una etiqueta de agrupación
+func (d *Document) AddTag(name, description string) {
+	d.Tags = append(d.Tags, Tag{Name: name, Description: description})
+}
+
+// Has indica si la ruta (formato chi: /x/{id}) y el método están documentados
+func (d *Document) Has(method, path string) bool {
+	item, ok := d.Paths[path]
+	return ok && item[strings.ToLower(method)] != nil
+}
+
+var pathParamRe = regexp.MustCompile(`\{(\w+)\}`)
+
+// Op añade una operación. Los parámetros de ruta ({id}) se declaran solos como string.
+func (d *Document) Op(method, path, tag, summary string) *OperationBuilder {
+	method = strings.ToLower(method)
+	op := &Operation{
+		Tags:        []string{tag},
+		Summary:     summary,
+		OperationID: operationID(method, path),
+		Responses:   make(map[string]*Response),
+	}
+	for _, m := range pathParamRe.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+
+	if d.Paths[path] == nil {
+		d.Paths[path] = make(PathItem)
+	}
+	d.Paths[path][method] = op
+	return &OperationBuilder{doc: d, op: op}
+}
+
+// OperationBuilder completa una operación con encadenamiento
+type OperationBuilder struct {
+	doc *Document
+	op  *Operation
+}
+
+// Describe texto largo de la operación
+func (b *OperationBuilder) Describe(description string) *OperationBuilder {
+	b.op.Description = description
+	return b
+}
+
+// Public ruta sin autenticación
+func (b *OperationBuilder) Public() *OperationBuilder {
+	b.op.Security = &[]SecurityRequirement{}
+	return b
+}
+
+// Security sustituye la autenticación global por los esquemas indicados (todos requeridos)
+func (b *OperationBuilder) Security(schemes ...string) *OperationBuilder {
+	req := SecurityRequirement{}
+	for _, s := range schemes {
+		req[s] = []string{}
+	}
+	b.op.Security = &[]SecurityRequirement{req}
+	return b
+}
+
+// Query parámetro de query opcional; typ es un tipo OpenAPI (string, integer, boolean)
+func (b *OperationBuilder) Query(name, typ, description string) *OperationBuilder {
+	b.op.Parameters = append(b.op.Parameters, Parameter{Name: name, In: "query", Description: description, Schema: &Schema{Type: typ}})
+	return b
+}
+
+// Body request JSON con el schema del struct v
+func (b *OperationBuilder) Body(v interface{}) *OperationBuilder {
+	b.op.RequestBody = &RequestBody{
+		Required: true,
+		Content:  map[string]MediaType{"application/json": {Schema: b.doc.SchemaOf(v)}},
+	}
+	return b
+}
+
+// JSON respuesta JSON con el schema de v (nil = sin cuerpo)
+func (b *OperationBuilder) JSON(status int, v interface{}) *OperationBuilder {
+	resp := b.response(status, http.StatusText(status))
+	if v != nil {
+		resp.Content["application/json"] = MediaType{Schema: b.doc.SchemaOf(v)}
+	}
+	return b
+}
+
+// Content respuesta con otro tipo de contenido (text/event-stream, text/html...). Con el
+// mismo status que una JSON, se documentan ambos tipos en la misma respuesta.
+func (b *OperationBuilder) Content(status int, contentType, description string) *OperationBuilder {
+	resp := b.response(status, description)
+	resp.Content[contentType] = MediaType{Schema: &Schema{Type: "string"}}
+	return b
+}
+
+func (b *OperationBuilder) response(status int, description string) *Response {
+	key := strconv.Itoa(status)
+	resp, ok := b.op.Responses[key]
+	if !ok {
+		resp = &Response{Description: description, Content: make(map[string]MediaType)}
+		b.op.Responses[key] = resp
+	}
+	return resp
+}
+
+// Errors respuestas de error con el schema errorBody (el cuerpo de respondError)
+func (b *OperationBuilder) Errors(errorBody interface{}, statuses ...int) *OperationBuilder {
+	for _, status := range statuses {
+		b.JSON(status, errorBody)
+	}
+	return b
+}
+
+// SchemaOf schema de v: los structs con nombre se registran en components y se referencian
+func (d *Document) SchemaOf(v interface{}) *Schema {
+	return d.schemaFor(reflect.TypeOf(v))
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+func (d *Document) schemaFor(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType):
+		if t.Name() == "UUID" {
+			return &Schema{Type: "string", Format: "uuid"}
+		}
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: d.schemaFor(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: d.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + d.registerStruct(t)}
+	default:
+		// interface{}: cualquier valor
+		return &Schema{}
+	}
+}
+
+// registerStruct añade el struct a components (una vez) y devuelve su nombre. Si dos
+// paquetes tienen un struct con el mismo nombre, el segundo lleva el paquete como prefijo.
+func (d *Document) registerStruct(t reflect.Type) string {
+	if name, ok := d.schemaNames[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := d.Components.Schemas[name]; taken {
+		pkg := t.PkgPath()
+		pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	d.schemaNames[t] = name
+	d.Components.Schemas[name] = &Schema{} // Reserva el nombre (structs recursivos)
+	d.Components.Schemas[name] = d.structSchema(t)
+	return name
+}
+
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	d.addFields(schema, t)
+	if len(schema.Properties) == 0 {
+		schema.Properties = nil
+	}
+	return schema
+}
+
+// addFields propiedades de los campos exportados según su tag json; los structs
+// embebidos sin tag aportan sus campos (como hace encoding/json)
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addFields(schema, embedded)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		schema.Properties[name] = d.schemaFor(field.Type)
+		if !strings.Contains(opts, "omitempty") && field.Type.Kind() != reflect.Pointer {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+var nonAlnumRe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+
+// operationID método + ruta en camelCase: GET /api/v1/me/{id} -> getApiV1MeId
+func operationID(method, path string) string {
+	parts := nonAlnumRe.Split(path, -1)
+	var b strings.Builder
+	b.WriteString(method)
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(p[:1]) + p[1:])
+	}
+	return b.String()
+}
+
+// Undocumented rutas de routes (método y ruta chi) que no están en el documento, ordenadas
+func (d *Document) Undocumented(routes [][2]string) []string {
+	var missing []string
+	for _, r := range routes {
+		if !d.Has(r[0], r[1]) {
+			missing = append(missing, fmt.Sprintf("%s %s", r[0], r[1]))
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
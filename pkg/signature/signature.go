@@ -0,0 +1,288 @@
+// Package signature firma y valida las peticiones internas entre servicios de Trackfy
+// (api-gateway, fy-engine, fy-admin, fy-analysis, fy-dbsync):
+// HMAC-SHA256(secret, method + path + timestamp + nonce + hex(SHA256(body))), donde path
+// incluye la query. El esquema tiene que coincidir con el cliente Python de fy-engine.
+package signature
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog/log"
+)
+
+// Cabeceras de la firma de peticiones internas
+const (
+	HeaderTimestamp = "X-Trackfy-Timestamp"
+	HeaderNonce     = "X-Trackfy-Nonce"
+	HeaderSignature = "X-Trackfy-Signature"
+)
+
+const (
+	// MaxAge antigüedad máxima de una petición firmada (protección contra replay)
+	MaxAge = 60 * time.Second
+	// nonceTTL el nonce se guarda el doble de la ventana: cubre también el desfase de reloj hacia el futuro
+	nonceTTL = 2 * MaxAge
+	// defaultMaxBodySize límite del body que se lee para calcular la firma si Options no da otro
+	defaultMaxBodySize = 1 << 20
+	nonceKeyPrefix     = "sig_nonce:"
+)
+
+// NonceStore registra los nonces usados durante la ventana de replay
+type NonceStore interface {
+	// Use marca el nonce como usado; retorna false si ya se había usado
+	Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error)
+}
+
+// RedisNonceStore nonces compartidos entre réplicas (SET NX con TTL)
+type RedisNonceStore struct {
+	client *redis.Client
+}
+
+// NewRedisNonceStore crea el store de nonces sobre Redis
+func NewRedisNonceStore(client *redis.Client) *RedisNonceStore {
+	return &RedisNonceStore{client: client}
+}
+
+// Use marca el nonce en Redis
+func (s *RedisNonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	return s.client.SetNX(ctx, nonceKeyPrefix+nonce, 1, ttl).Result()
+}
+
+// MemoryNonceStore nonces en memoria (una sola réplica o sin Redis). Los caducados se
+// purgan cada MaxAge en segundo plano hasta Stop, no en cada petición.
+type MemoryNonceStore struct {
+	mu     sync.Mutex
+	nonces map[string]time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMemoryNonceStore crea el store de nonces en memoria y arranca su purga periódica
+func NewMemoryNonceStore() *MemoryNonceStore {
+	s := &MemoryNonceStore{
+		nonces: make(map[string]time.Time),
+		stopCh: make(chan struct{}),
+	}
+	go s.purgeLoop(MaxAge)
+	return s
+}
+
+// Use marca el nonce en memoria. Un nonce caducado que aún no se ha purgado se puede reutilizar.
+func (s *MemoryNonceStore) Use(ctx context.Context, nonce string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := s.nonces[nonce]; ok && now.Before(expiresAt) {
+		return false, nil
+	}
+	s.nonces[nonce] = now.Add(ttl)
+	return true, nil
+}
+
+// Stop detiene la purga periódica
+func (s *MemoryNonceStore) Stop() {
+	s.stopOnce.Do(func() { close(s.stopCh) })
+}
+
+func (s *MemoryNonceStore) purgeLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case now := <-ticker.C:
+			s.purge(now)
+		}
+	}
+}
+
+// purge borra los nonces caducados en now
+func (s *MemoryNonceStore) purge(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for nonce, expiresAt := range s.nonces {
+		if now.After(expiresAt) {
+			delete(s.nonces, nonce)
+		}
+	}
+}
+
+// Options configuración del middleware de validación
+type Options struct {
+	// Secret secreto compartido (INTERNAL_SIGNING_SECRET). Vacío = no se valida nada
+	// (desarrollo local); el servicio que no lo admita debe comprobarlo antes.
+	Secret string
+	// Nonces store de nonces para el replay; nil = en memoria
+	Nonces NonceStore
+	// ExemptPaths rutas sin firma (healthchecks, métricas, documentación...)
+	ExemptPaths map[string]bool
+	// MaxBodySize bytes del body que se leen para la firma (0 = 1MB)
+	MaxBodySize int64
+	// Reject escribe la respuesta de rechazo con el formato de error del servicio:
+	// status 401 (firma inválida) o 503 (no se puede comprobar el nonce).
+	// nil = JSON {"error", "code"} con INVALID_SIGNATURE / NONCE_STORE_UNAVAILABLE.
+	Reject func(w http.ResponseWriter, status int)
+}
+
+// Middleware valida la firma HMAC-SHA256 de las peticiones internas. Rechaza con 401 las
+// firmas inválidas, las peticiones de más de MaxAge y los nonces repetidos, y con 503 si el
+// store de nonces falla: sin comprobar el nonce una petición capturada se podría repetir.
+func Middleware(opts Options) func(http.Handler) http.Handler {
+	nonces := opts.Nonces
+	if nonces == nil {
+		nonces = NewMemoryNonceStore()
+	}
+	maxBodySize := opts.MaxBodySize
+	if maxBodySize <= 0 {
+		maxBodySize = defaultMaxBodySize
+	}
+	reject := opts.Reject
+	if reject == nil {
+		reject = writeRejection
+	}
+
+	return func(next http.Handler) http.Handler {
+		if opts.Secret == "" {
+			log.Warn().Msg("[Signature] INTERNAL_SIGNING_SECRET not set - request signature validation disabled")
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if opts.ExemptPaths[r.URL.Path] || r.Method == http.MethodOptions {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			timestamp := r.Header.Get(HeaderTimestamp)
+			nonce := r.Header.Get(HeaderNonce)
+			signature := r.Header.Get(HeaderSignature)
+			if timestamp == "" || nonce == "" || signature == "" {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "missing signature headers")
+				return
+			}
+
+			ts, err := strconv.ParseInt(timestamp, 10, 64)
+			if err != nil {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "invalid timestamp")
+				return
+			}
+			if age := time.Since(time.Unix(ts, 0)); age > MaxAge || age < -MaxAge {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "request expired")
+				return
+			}
+
+			body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize))
+			if err != nil {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "unreadable body")
+				return
+			}
+			r.Body.Close()
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			expected := Sign(opts.Secret, r.Method, r.URL.RequestURI(), timestamp, nonce, body)
+			if !hmac.Equal([]byte(expected), []byte(signature)) {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "invalid signature")
+				return
+			}
+
+			fresh, err := nonces.Use(r.Context(), nonce, nonceTTL)
+			if err != nil {
+				log.Error().Err(err).Msg("[Signature] Nonce store unavailable, rejecting request")
+				rejectRequest(w, r, reject, http.StatusServiceUnavailable, "nonce store unavailable")
+				return
+			}
+			if !fresh {
+				rejectRequest(w, r, reject, http.StatusUnauthorized, "nonce already used")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Sign calcula la firma hex de una petición
+func Sign(secret, method, path, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(method + path + timestamp + nonce + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest firma req con un nonce aleatorio y la hora actual. El body se lee de
+// req.GetBody (lo rellena http.NewRequest). Sin secret no firma (desarrollo local).
+func SignRequest(req *http.Request, secret string) error {
+	if secret == "" {
+		return nil
+	}
+
+	var body []byte
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		body, err = io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return err
+	}
+	nonce := hex.EncodeToString(nonceBytes)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderNonce, nonce)
+	req.Header.Set(HeaderSignature, Sign(secret, req.Method, req.URL.RequestURI(), timestamp, nonce, body))
+	return nil
+}
+
+func rejectRequest(w http.ResponseWriter, r *http.Request, reject func(http.ResponseWriter, int), status int, reason string) {
+	log.Warn().
+		Str("path", r.URL.Path).
+		Str("remote_addr", r.RemoteAddr).
+		Str("reason", reason).
+		Msg("[Signature] Request rejected")
+	reject(w, status)
+}
+
+// writeRejection respuesta de rechazo por defecto
+func writeRejection(w http.ResponseWriter, status int) {
+	body := map[string]string{
+		"error": "Firma de petición interna inválida",
+		"code":  "INVALID_SIGNATURE",
+	}
+	if status == http.StatusServiceUnavailable {
+		body = map[string]string{
+			"error": "No se puede comprobar la firma de la petición interna",
+			"code":  "NONCE_STORE_UNAVAILABLE",
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,167 @@
+package signature
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "test-secret"
+
+// failingStore simula Redis caído
+type failingStore struct{}
+
+func (failingStore) Use(context.Context, string, time.Duration) (bool, error) {
+	return false, errors.New("dial tcp: connection refused")
+}
+
+func signedRequest(t *testing.T, method, target, body string) *http.Request {
+	t.Helper()
+	req, err := http.NewRequest(method, target, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := SignRequest(req, testSecret); err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	return req
+}
+
+func serve(handler http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestMiddleware(t *testing.T) {
+	var gotBody string
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b := make([]byte, 64)
+		n, _ := r.Body.Read(b)
+		gotBody = string(b[:n])
+	})
+	nonces := NewMemoryNonceStore()
+	defer nonces.Stop()
+	handler := Middleware(Options{Secret: testSecret, Nonces: nonces, ExemptPaths: map[string]bool{"/health": true}})(next)
+
+	// Firma válida: el handler recibe el body intacto
+	req := signedRequest(t, http.MethodPost, "http://fy-analysis/api/v1/analyze?mode=full", `{"url":"x"}`)
+	if rec := serve(handler, req); rec.Code != http.StatusOK || gotBody != `{"url":"x"}` {
+		t.Fatalf("valid request: status %d, body %q", rec.Code, gotBody)
+	}
+
+	// La misma petición otra vez es un replay
+	replay, _ := http.NewRequest(http.MethodPost, "http://fy-analysis/api/v1/analyze?mode=full", strings.NewReader(`{"url":"x"}`))
+	replay.Header = req.Header.Clone()
+	if rec := serve(handler, replay); rec.Code != http.StatusUnauthorized {
+		t.Errorf("replay: status %d, want 401", rec.Code)
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(r *http.Request)
+	}{
+		{"missing headers", func(r *http.Request) { r.Header.Del(HeaderSignature) }},
+		{"bad signature", func(r *http.Request) { r.Header.Set(HeaderSignature, strings.Repeat("0", 64)) }},
+		{"other query", func(r *http.Request) { r.URL.RawQuery = "mode=fast" }},
+		{"expired", func(r *http.Request) {
+			ts := strconv.FormatInt(time.Now().Add(-2*MaxAge).Unix(), 10)
+			r.Header.Set(HeaderTimestamp, ts)
+			r.Header.Set(HeaderSignature, Sign(testSecret, r.Method, r.URL.RequestURI(), ts, r.Header.Get(HeaderNonce), []byte("{}")))
+		}},
+		{"invalid timestamp", func(r *http.Request) { r.Header.Set(HeaderTimestamp, "yesterday") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := signedRequest(t, http.MethodPost, "http://fy-analysis/api/v1/analyze?mode=full", "{}")
+			tt.mutate(r)
+			rec := serve(handler, r)
+			if rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "INVALID_SIGNATURE") {
+				t.Errorf("status %d, body %s; want 401 INVALID_SIGNATURE", rec.Code, rec.Body)
+			}
+		})
+	}
+
+	// Rutas exentas y preflight pasan sin firma
+	for _, r := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/health", nil),
+		httptest.NewRequest(http.MethodOptions, "/api/v1/analyze", nil),
+	} {
+		if rec := serve(handler, r); rec.Code != http.StatusOK {
+			t.Errorf("%s %s: status %d, want 200", r.Method, r.URL.Path, rec.Code)
+		}
+	}
+}
+
+func TestMiddlewareNonceStoreDown(t *testing.T) {
+	called := false
+	handler := Middleware(Options{Secret: testSecret, Nonces: failingStore{}})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+
+	// Sin poder comprobar el nonce la petición no pasa: se podría repetir
+	rec := serve(handler, signedRequest(t, http.MethodPost, "http://fy-dbsync/sync", ""))
+	if called || rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "NONCE_STORE_UNAVAILABLE") {
+		t.Errorf("called %v, status %d, body %s; want 503 without calling the handler", called, rec.Code, rec.Body)
+	}
+}
+
+func TestMiddlewareCustomReject(t *testing.T) {
+	var statuses []int
+	handler := Middleware(Options{
+		Secret: testSecret,
+		Nonces: failingStore{},
+		Reject: func(w http.ResponseWriter, status int) {
+			statuses = append(statuses, status)
+			w.WriteHeader(status)
+		},
+	})(http.NotFoundHandler())
+
+	serve(handler, httptest.NewRequest(http.MethodPost, "/internal/webhooks/reclassification", nil))
+	serve(handler, signedRequest(t, http.MethodPost, "http://api-gateway/internal/webhooks/reclassification", "{}"))
+	if len(statuses) != 2 || statuses[0] != http.StatusUnauthorized || statuses[1] != http.StatusServiceUnavailable {
+		t.Errorf("reject statuses = %v, want [401 503]", statuses)
+	}
+}
+
+func TestMiddlewareWithoutSecret(t *testing.T) {
+	called := false
+	handler := Middleware(Options{})(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	if rec := serve(handler, httptest.NewRequest(http.MethodPost, "/sync", nil)); !called || rec.Code != http.StatusOK {
+		t.Errorf("without secret: called %v, status %d; want the request through", called, rec.Code)
+	}
+}
+
+func TestMemoryNonceStorePurge(t *testing.T) {
+	s := NewMemoryNonceStore()
+	defer s.Stop()
+	ctx := context.Background()
+
+	if fresh, _ := s.Use(ctx, "a", time.Minute); !fresh {
+		t.Fatal("first use of a nonce is not fresh")
+	}
+	if fresh, _ := s.Use(ctx, "a", time.Minute); fresh {
+		t.Error("second use of a nonce is fresh")
+	}
+	s.Use(ctx, "b", time.Millisecond)
+
+	// La purga borra solo los caducados
+	s.purge(time.Now().Add(time.Second))
+	if _, ok := s.nonces["b"]; ok {
+		t.Error("expired nonce not purged")
+	}
+	if _, ok := s.nonces["a"]; !ok {
+		t.Error("live nonce purged")
+	}
+
+	// Un nonce caducado pendiente de purga se puede volver a usar
+	s.Use(ctx, "c", -time.Second)
+	if fresh, _ := s.Use(ctx, "c", time.Minute); !fresh {
+		t.Error("expired nonce still rejected")
+	}
+}
@@ -0,0 +1,68 @@
+// Package tracing configuración de OpenTelemetry común a api-gateway, fy-analysis y fy-dbsync.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName instrumentación de los spans del servicio ("github.com/trackfy/<servicio>"), la fija Init
+var tracerName = "github.com/trackfy"
+
+// Init configura el TracerProvider global y la propagación W3C (traceparent).
+// Si OTEL_EXPORTER_OTLP_ENDPOINT no está definido, solo se propaga el contexto
+// y los spans no se exportan.
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	tracerName = "github.com/trackfy/" + serviceName
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	if os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" {
+		log.Info().Msg("[Tracing] OTEL_EXPORTER_OTLP_ENDPOINT not set, span export disabled")
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// El exporter lee OTEL_EXPORTER_OTLP_ENDPOINT / OTEL_EXPORTER_OTLP_HEADERS del entorno
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	log.Info().
+		Str("service", serviceName).
+		Str("endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")).
+		Msg("[Tracing] OTLP exporter initialized")
+
+	return provider.Shutdown, nil
+}
+
+// Tracer retorna el tracer del servicio
+func Tracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}